@@ -47,6 +47,7 @@ var _ orz.Application = (*PrismApp)(nil)
 
 type AppComponents struct {
 	TradingHandler *handler.TradingHandler
+	MarketHandler  *handler.MarketHandler
 	AdminHandler   *handler.AdminHandler
 	AuthHandler    *handler.AuthHandler
 	SetupHandler   *handler.SetupHandler
@@ -59,6 +60,10 @@ type AppComponents struct {
 	AgentService          *service.AgentService
 	AuthService           *service.AuthService
 	AdminConfigService    *service.AdminConfigService
+	FundingService        *service.FundingService
+	RetentionService      *service.RetentionService
+	OutcomeService        *service.OutcomeService
+	ExchangeHealthService *service.ExchangeHealthService
 
 	tg *telegram.Telegram
 }
@@ -78,11 +83,27 @@ func (r *PrismApp) Configure(app *orz.App) error {
 	e := app.GetEcho()
 	db := app.GetDatabase()
 
-	var conf config.Config
+	conf := config.Default()
 	err := app.GetConfig().App.Unmarshal(&conf)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal config: %v", err)
 	}
+	if err := conf.ApplySecretOverrides(); err != nil {
+		return fmt.Errorf("failed to apply secret overrides: %v", err)
+	}
+	for _, deprecated := range conf.ApplyDeprecatedKeys() {
+		logger.Warn("deprecated config key", zap.String("detail", deprecated))
+	}
+	if err := conf.ValidateTradingMode(); err != nil {
+		return fmt.Errorf("invalid trading mode: %v", err)
+	}
+	if err := conf.ValidateDisplayTimezone(); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	logger.Info("trading mode", zap.String("mode", conf.TradingMode()))
+	if err := configureDatabase(db, conf.Database, logger); err != nil {
+		return fmt.Errorf("failed to configure database: %v", err)
+	}
 
 	components, err := InitializeApp(logger, db, &conf)
 	if err != nil {
@@ -91,14 +112,23 @@ func (r *PrismApp) Configure(app *orz.App) error {
 	r.components = components
 	r.conf = &conf
 
+	// 旧版本的交易配置没有最长持仓时间，迁移增加该列后补上默认值；之后保存的 0 表示不限制
+	backfillMaxHoldingHours := db.Migrator().HasTable(&models.TradingConfig{}) &&
+		!db.Migrator().HasColumn(&models.TradingConfig{}, "MaxHoldingHours")
 	if err := db.AutoMigrate(
 		// Trading system models
-		models.AccountHistory{}, models.Position{}, models.Trade{}, models.Decision{}, models.LLMLog{}, models.Order{},
+		models.AccountHistory{}, models.Position{}, models.Trade{}, models.Decision{}, models.LLMLog{}, models.Order{}, models.FundingPayment{}, models.CapitalFlow{}, models.Event{}, models.MarketRegimeRecord{},
 		// Admin models
 		models.TradingConfig{}, models.AdminUser{}, models.SystemPrompt{},
 	); err != nil {
 		logger.Fatal("database auto migrate failed", zap.Error(err))
 	}
+	if backfillMaxHoldingHours {
+		if err := db.Model(&models.TradingConfig{}).Where("1 = 1").
+			Update("max_holding_hours", service.DefaultTradingConfig.MaxHoldingHours).Error; err != nil {
+			logger.Fatal("failed to backfill max holding hours", zap.Error(err))
+		}
+	}
 
 	if err := r.Init(logger); err != nil {
 		logger.Fatal("app init failed", zap.Error(err))
@@ -153,6 +183,21 @@ func (r *PrismApp) Configure(app *orz.App) error {
 		// Trading API routes (无需认证)
 		if r.components.TradingHandler != nil {
 			r.components.TradingHandler.RegisterRoutes(api)
+
+			// 需要JWT认证的交易接口（人工干预持仓）
+			if r.components.AuthService != nil {
+				jwtMiddleware := authmw.JWTAuth(authmw.JWTAuthConfig{
+					AuthService: r.components.AuthService,
+					Logger:      logger,
+				})
+				tradingProtected := api.Group("/trading", jwtMiddleware)
+				r.components.TradingHandler.RegisterProtectedRoutes(tradingProtected)
+			}
+		}
+
+		// Market API routes (无需认证)
+		if r.components.MarketHandler != nil {
+			r.components.MarketHandler.RegisterRoutes(api)
 		}
 
 		// Auth API routes (登录等公开接口)
@@ -233,10 +278,51 @@ func (r *PrismApp) Init(logger *zap.Logger) error {
 
 	// 启动持仓同步worker（每3秒同步一次，保证数据实时性）
 	if components.PositionService != nil {
+		// 分批止盈某档成交后由 AgentService 按剩余数量调整止损
+		if components.AgentService != nil {
+			components.PositionService.SetLadderFillHandler(components.AgentService.ResizeStopAfterLadderFill)
+		}
 		logger.Info("Starting position sync worker...")
 		components.PositionService.StartSyncWorker(context.Background(), 3*time.Second)
 	}
 
+	// 启动交易对信息预热worker（一次请求缓存所有交易对的精度和过滤器，避免下单时临时拉取）
+	if interval := r.conf.Binance.SymbolInfoRefreshInterval(); interval > 0 && components.MarketService != nil && components.AdminConfigService != nil {
+		logger.Info("Starting symbol info worker...")
+		adminConfigService := components.AdminConfigService
+		components.MarketService.StartSymbolInfoWorker(context.Background(), interval, func(ctx context.Context) ([]string, error) {
+			tradingConfig, err := adminConfigService.GetTradingConfig(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return tradingConfig.Symbols, nil
+		})
+	}
+
+	// 启动对时worker（本地时钟漂移会导致签名请求被拒绝，并让交易所时间戳与本地时间错位）
+	if interval := r.conf.Binance.TimeSyncInterval(); interval > 0 && components.ExchangeHealthService != nil {
+		logger.Info("Starting time sync worker...")
+		components.ExchangeHealthService.StartTimeSyncWorker(context.Background(), interval)
+	}
+
+	// 启动资金流水同步worker（资金费每8小时结算一次，出入金不要求实时，按小时拉取即可）
+	if components.FundingService != nil {
+		logger.Info("Starting funding sync worker...")
+		components.FundingService.StartSyncWorker(context.Background(), time.Hour)
+	}
+
+	// 启动数据保留清理worker（按保留天数分批清理LLM日志、决策记录并降采样账户历史，避免表无限增长）
+	if interval := r.conf.Retention.Interval(); interval > 0 && components.RetentionService != nil {
+		logger.Info("Starting retention worker...")
+		components.RetentionService.StartWorker(context.Background(), interval)
+	}
+
+	// 启动决策结果评估worker（决策执行后经过评估窗口，记录价格是否朝决策方向运动）
+	if r.conf.Trading.DecisionOutcomeHorizonHours > 0 && components.OutcomeService != nil {
+		logger.Info("Starting decision outcome worker...")
+		components.OutcomeService.StartWorker(context.Background(), 15*time.Minute)
+	}
+
 	logger.Info("Trading loop initialized, starting...")
 
 	go func() {
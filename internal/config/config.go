@@ -1,17 +1,202 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // 运行环境（如精简镜像）缺少时区数据库时也能解析 display_timezone
+)
+
 type Config struct {
-	Telegram TelegramConf `json:"telegram"`
-	Binance  BinanceConf  `json:"binance"`
-	Trading  TradingConf  `json:"trading"`
-	LLM      LlmConf      `json:"llm"`
-	Admin    AdminConf    `json:"admin"`
+	DisplayTimezone string `json:"display_timezone"` // 提示词和界面展示时间所用的时区（IANA 名称，如 Asia/Shanghai），默认 UTC
+
+	Telegram  TelegramConf  `json:"telegram"`
+	Exchange  ExchangeConf  `json:"exchange"`
+	Binance   BinanceConf   `json:"binance"`
+	Trading   TradingConf   `json:"trading"`
+	LLM       LlmConf       `json:"llm"`
+	Admin     AdminConf     `json:"admin"`
+	Database  DatabaseConf  `json:"database"`
+	Retention RetentionConf `json:"retention"`
+
+	displayLocation *time.Location // ValidateDisplayTimezone 解析后的展示时区
+}
+
+// Default 返回带默认值的配置，配置文件中未填写的字段将保留这里的默认值
+func Default() Config {
+	return Config{
+		DisplayTimezone: "UTC",
+		Trading: TradingConf{
+			RequireStopOnOpen:            true,
+			MinNotionalTolerancePercent:  10,
+			PartialFillWarnPercent:       5,
+			MaxFillSlippagePercent:       0.3,
+			PostFillStopGuard:            true,
+			FallbackStopATRMultiple:      1.5,
+			TwapSlices:                   5,
+			TwapDurationSeconds:          60,
+			MinEntryExplanationLength:    20,
+			RequireEntryKeywords:         true,
+			MaxSpreadPercent:             0.5,
+			PromptRecentTradesLimit:      20,
+			CandleCloseDelaySeconds:      5,
+			MinCycleSpacingSeconds:       60,
+			MaxToolCallsPerDecision:      30,
+			MaxOpensPerDecision:          2,
+			OpenWindowHours:              24,
+			StopOrderRetries:             2,
+			StopExecution:                "market",
+			HTFTrendGuard:                "off",
+			HTFTrendADX:                  25,
+			VolatilitySpikeGuard:         "off",
+			VolatilitySpikeMargin:        0.5,
+			VolatilitySpikeStopATR:       1.5,
+			StopLimitOffsetPercent:       0.5,
+			KeyLevelLookback:             200,
+			RiskPercentPerTrade:          2,
+			SizingMode:                   "margin",
+			CloseDelistedPositions:       true,
+			EnforceDrawdownLimits:        true,
+			DrawdownReduceFraction:       0.5,
+			AccountFallbackMaxAgeMinutes: 60,
+			CollectionBudgetPercent:      50,
+			PositionSyncTolerancePercent: 0.01,
+			DecisionOutcomeHorizonHours:  4,
+			RecordMarketRegimes:          true,
+			PositionHealthWeights:        DefaultPositionHealthWeights,
+			ConfidenceSizing: []ConfidenceSizeTier{
+				{MaxConfidence: 4, SizeMultiplier: 0.5},
+				{MaxConfidence: 6, SizeMultiplier: 0.75},
+			},
+		},
+		Binance: BinanceConf{
+			SymbolInfoRefreshMinutes: 5,
+			TimeSyncMinutes:          60,
+			MaxTimeDriftMillis:       1000,
+			ErrorAlert: ExchangeErrorAlertConf{
+				Enabled:          true,
+				WindowMinutes:    10,
+				ThresholdPercent: 50,
+				MinCalls:         10,
+				CooldownMinutes:  60,
+			},
+		},
+		LLM: LlmConf{
+			PromptLanguage: "zh",
+			StorePrompt:    true,
+		},
+		Retention: RetentionConf{
+			LLMLogDays:             30,
+			DecisionDays:           365,
+			MarketRegimeDays:       90,
+			AccountHistoryFullDays: 90,
+			IntervalMinutes:        360,
+			BatchSize:              1000,
+		},
+	}
+}
+
+// 交易模式
+const (
+	TradingModePaper = "paper" // 纸钱包模拟交易，不实际下单
+	TradingModeLive  = "live"  // 在交易所真实下单
+)
+
+// ExchangeConf 交易模式配置
+type ExchangeConf struct {
+	Mode        string `json:"mode"`         // paper 或 live，未配置时沿用 trading.enabled
+	ConfirmLive bool   `json:"confirm_live"` // live 模式必须显式设为 true 才会启动交易循环，防止误开实盘
+}
+
+// TradingMode 返回生效的交易模式，exchange.mode 未配置时按 trading.enabled 决定
+func (c *Config) TradingMode() string {
+	mode := strings.ToLower(strings.TrimSpace(c.Exchange.Mode))
+	if mode == "" {
+		if c.Trading.Enabled {
+			return TradingModeLive
+		}
+		return TradingModePaper
+	}
+	return mode
+}
+
+// IsLive 是否为真实交易模式
+func (c *Config) IsLive() bool {
+	return c.TradingMode() == TradingModeLive
+}
+
+// ValidateTradingMode 校验交易模式：只允许 paper/live，且 exchange.mode 与 trading.enabled 同时配置时不能矛盾
+func (c *Config) ValidateTradingMode() error {
+	mode := c.TradingMode()
+	if mode != TradingModePaper && mode != TradingModeLive {
+		return fmt.Errorf("invalid exchange.mode %q, must be %s or %s", c.Exchange.Mode, TradingModePaper, TradingModeLive)
+	}
+	if c.Exchange.Mode != "" && c.Trading.Enabled && mode != TradingModeLive {
+		return fmt.Errorf("exchange.mode is %s but trading.enabled is true, remove trading.enabled or set exchange.mode to %s", mode, TradingModeLive)
+	}
+	return nil
+}
+
+// ValidateDisplayTimezone 解析展示时区，时区名无效时返回错误，需在加载配置后调用
+func (c *Config) ValidateDisplayTimezone() error {
+	name := strings.TrimSpace(c.DisplayTimezone)
+	if name == "" {
+		name = "UTC"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid display_timezone %q: %w", c.DisplayTimezone, err)
+	}
+	c.DisplayTimezone = name
+	c.displayLocation = loc
+	return nil
+}
+
+// DisplayLocation 展示时间所用的时区，未校验或时区名无效时为 UTC
+func (c *Config) DisplayLocation() *time.Location {
+	if c.displayLocation != nil {
+		return c.displayLocation
+	}
+	if loc, err := time.LoadLocation(strings.TrimSpace(c.DisplayTimezone)); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// ApplyDeprecatedKeys 把仍在使用的已废弃配置项映射到新配置项，返回需要告警的配置项说明
+//
+// 新配置项不是默认值时视为已显式配置，以新配置项为准
+func (c *Config) ApplyDeprecatedKeys() []string {
+	var deprecated []string
+	if days := c.LLM.LogRetentionDays; days != nil {
+		if c.Retention.LLMLogDays == Default().Retention.LLMLogDays {
+			c.Retention.LLMLogDays = *days
+		}
+		deprecated = append(deprecated, "llm.log_retention_days is deprecated, use retention.llm_log_days instead")
+	}
+	return deprecated
+}
+
+// CheckLiveConfirmed live 模式下未设置 exchange.confirm_live 时返回错误
+func (c *Config) CheckLiveConfirmed() error {
+	if c.IsLive() && !c.Exchange.ConfirmLive {
+		return fmt.Errorf("live trading mode requires exchange.confirm_live: true")
+	}
+	return nil
 }
 
 type TelegramConf struct {
 	Enabled bool   `json:"enabled"`
 	Token   string `json:"token"`
 	ChatID  string `json:"chat_id"`
+
+	Digest CycleDigestConf `json:"digest"` // 交易周期结束后的摘要通知
+}
+
+// CycleDigestConf 交易周期摘要通知配置
+type CycleDigestConf struct {
+	Enabled          bool `json:"enabled"`           // 每个交易周期结束后发送净值、持仓和本周期操作的摘要，默认false
+	HeartbeatMinutes int  `json:"heartbeat_minutes"` // 周期内没有交易且持仓数量未变化时，距上次摘要至少该分钟数才发送，0表示无变化时不发送
 }
 
 type BinanceConf struct {
@@ -19,11 +204,175 @@ type BinanceConf struct {
 	Secret   string `json:"secret"`
 	ProxyURL string `json:"proxy_url"` // 代理地址，例如: http://127.0.0.1:7890
 	Testnet  bool   `json:"testnet"`   // 是否使用测试网
+
+	WeightPerMinute          int `json:"weight_per_minute"`           // 所有REST请求共享的每分钟权重上限，默认1200
+	SymbolInfoRefreshMinutes int `json:"symbol_info_refresh_minutes"` // 启动时预热交易对信息并按该间隔刷新，默认5，小于0时关闭预热
+	TimeSyncMinutes          int `json:"time_sync_minutes"`           // 启动时与服务器对时并按该间隔重新对时，默认60，小于0时关闭
+	MaxTimeDriftMillis       int `json:"max_time_drift_millis"`       // 本地时钟与服务器时间偏差超过该值（毫秒）时告警，默认1000
+
+	ErrorAlert ExchangeErrorAlertConf `json:"error_alert"` // 交易所调用失败率告警
+}
+
+// ExchangeErrorAlertConf 交易所调用失败率告警配置
+type ExchangeErrorAlertConf struct {
+	Enabled          bool    `json:"enabled"`           // 是否启用，默认true
+	WindowMinutes    int     `json:"window_minutes"`    // 统计窗口（分钟），默认10
+	ThresholdPercent float64 `json:"threshold_percent"` // 失败率达到该百分比时告警并标记降级，默认50
+	MinCalls         int     `json:"min_calls"`         // 窗口内调用次数达到该值才判断失败率，默认10
+	CooldownMinutes  int     `json:"cooldown_minutes"`  // 两次告警的最小间隔（分钟），默认60
+}
+
+// Window 失败率统计窗口
+func (c ExchangeErrorAlertConf) Window() time.Duration {
+	if c.WindowMinutes <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.WindowMinutes) * time.Minute
+}
+
+// Cooldown 两次告警的最小间隔
+func (c ExchangeErrorAlertConf) Cooldown() time.Duration {
+	if c.CooldownMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.CooldownMinutes) * time.Minute
+}
+
+// TimeSyncInterval 与交易所服务器对时的间隔，返回0表示关闭对时
+func (c BinanceConf) TimeSyncInterval() time.Duration {
+	if c.TimeSyncMinutes < 0 {
+		return 0
+	}
+	if c.TimeSyncMinutes == 0 {
+		return time.Hour
+	}
+	return time.Duration(c.TimeSyncMinutes) * time.Minute
+}
+
+// MaxTimeDrift 本地时钟与服务器时间的告警阈值
+func (c BinanceConf) MaxTimeDrift() time.Duration {
+	if c.MaxTimeDriftMillis <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.MaxTimeDriftMillis) * time.Millisecond
+}
+
+// SymbolInfoRefreshInterval 交易对信息预热刷新间隔，返回0表示关闭预热
+func (c BinanceConf) SymbolInfoRefreshInterval() time.Duration {
+	if c.SymbolInfoRefreshMinutes < 0 {
+		return 0
+	}
+	if c.SymbolInfoRefreshMinutes == 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.SymbolInfoRefreshMinutes) * time.Minute
 }
 
 type TradingConf struct {
-	Enabled     bool            `json:"enabled"`      // 是否启用真实交易，false时使用纸钱包模式
+	Enabled     bool            `json:"enabled"`      // 旧配置：是否启用真实交易，已被 exchange.mode 取代，exchange.mode 未配置时生效
 	PaperWallet PaperWalletConf `json:"paper_wallet"` // 纸钱包配置
+
+	MinOperatingBalance         float64 `json:"min_operating_balance"`          // 账户净值低于该值（USDT）时跳过AI决策，只同步和监控现有持仓，净值恢复后自动继续，默认0表示不限制
+	CloseOnly                   bool    `json:"close_only"`                     // 启动时处于只平仓模式：禁止新开仓，只允许管理和平掉现有持仓，运行中可通过接口切换
+	RequireStopOnOpen           bool    `json:"require_stop_on_open"`           // 开仓后止损单创建失败时立即平仓，默认true
+	MinNotionalTolerancePercent float64 `json:"min_notional_tolerance_percent"` // 名义价值低于交易对最小名义价值的幅度在该比例内时自动补足数量，默认10
+	PartialFillWarnPercent      float64 `json:"partial_fill_warn_percent"`      // 市价开仓成交数量比下单数量少该百分比以上时视为部分成交并告警，默认5
+	MaxFillSlippagePercent      float64 `json:"max_fill_slippage_percent"`      // 市价成交价相对下单前价格的不利滑点超过该百分比时告警，开仓时标记持仓待复核，默认0.3，0表示不检查
+	TightenStopOnSlippage       bool    `json:"tighten_stop_on_slippage"`       // 开仓滑点超限时按成交价平移止损，保持开仓时计划的每单位风险不变
+	PostFillStopGuard           bool    `json:"post_fill_stop_guard"`           // 成交后按成交均价重新校验止损止盈，止损位于错误一侧时从成交价重设，止盈位于错误一侧时不创建，默认true
+	FallbackStopATRMultiple     float64 `json:"fallback_stop_atr_multiple"`     // 成交后重设止损距成交价的1小时ATR倍数，默认1.5，ATR不可用时沿用计划的止损距离
+	TwapSlices                  int     `json:"twap_slices"`                    // closePosition 选择 twap 执行时拆分的市价子订单笔数，默认5
+	TwapDurationSeconds         int     `json:"twap_duration_seconds"`          // TWAP 平仓从第一笔到最后一笔子订单的总时长（秒），默认60，应明显短于交易周期
+	MinEntryExplanationLength   int     `json:"min_entry_explanation_length"`   // 开仓理由 reason 和退出计划 exit_plan 各自至少需要的字符数，默认20，0表示不检查
+	RequireEntryKeywords        bool    `json:"require_entry_keywords"`         // 开仓说明必须提及时间框架、具体信号和具体止损价位，默认true
+	MaxSpreadPercent            float64 `json:"max_spread_percent"`             // 开仓前盘口买卖价差（占中间价%）超过该值时拒绝市价开仓，默认0.5，0表示不检查
+	PromptRecentTradesLimit     int     `json:"prompt_recent_trades_limit"`     // 提示词中展示的最近交易笔数，默认20
+	PromptMaxSymbols            int     `json:"prompt_max_symbols"`             // 提示词行情部分最多展示的交易对数量，持仓交易对始终展示，其余按相关度排序截取，默认0表示不限制
+	CandleCloseDelaySeconds     int     `json:"candle_close_delay_seconds"`     // 交易周期在K线收盘后延迟执行的秒数，默认5
+	MinCycleSpacingSeconds      int     `json:"min_cycle_spacing_seconds"`      // 两个交易周期开始时间的最小间隔（秒），不超过交易周期的一半，默认60，0表示不限制
+	KeyLevelLookback            int     `json:"key_level_lookback"`             // 计算关键支撑/阻力所用的1小时K线根数，默认200，0表示不计算
+	MaxToolCallsPerDecision     int     `json:"max_tool_calls_per_decision"`    // 单次决策最多执行的工具调用次数，默认30，0表示不限制
+	MaxOpensPerDecision         int     `json:"max_opens_per_decision"`         // 单次决策最多成功开仓次数，默认2，0表示不限制
+	MaxOpensPerWindow           int     `json:"max_opens_per_window"`           // 滚动时间窗口内最多开仓次数（含加仓），默认0表示不限制
+	OpenWindowHours             int     `json:"open_window_hours"`              // 开仓次数限制的滚动窗口（小时），默认24
+	MaxClosesPerDecision        int     `json:"max_closes_per_decision"`        // 单次决策最多成功平仓次数，默认0（不限制，避免妨碍风控平仓）
+	StopOrderRetries            int     `json:"stop_order_retries"`             // 更新止损/止盈时新单创建失败的重试次数，默认2
+	StopExecution               string  `json:"stop_execution"`                 // 止损单触发后的执行方式：market（市价，默认）或 limit（限价）
+	StopLimitOffsetPercent      float64 `json:"stop_limit_offset_percent"`      // 限价止损的限价相对触发价向不利方向的偏移（%），默认0.5
+	AutoLeverage                bool    `json:"auto_leverage"`                  // 根据单笔风险和止损距离自动计算杠杆，覆盖AI选择
+	RiskPercentPerTrade         float64 `json:"risk_percent_per_trade"`         // 自动杠杆模式下的单笔目标风险（占净值%），默认2
+	SizingMode                  string  `json:"sizing_mode"`                    // openPosition 的 quantity 含义：margin（保证金，默认）、notional（名义价值）、percent_equity（可用余额百分比）
+	CloseDelistedPositions      bool    `json:"close_delisted_positions"`       // 持仓交易对下架或暂停交易时立即尝试平仓，失败则冻结仓位并通知，默认true
+	EnforceDrawdownLimits       bool    `json:"enforce_drawdown_limits"`        // 由系统强制执行回撤分级：达到最大回撤自动减仓，达到强制清仓阈值平掉全部持仓，默认true
+	DrawdownReduceFraction      float64 `json:"drawdown_reduce_fraction"`       // 达到最大回撤时每个持仓自动减仓的比例（0-1），默认0.5，0表示只禁止开仓不减仓
+	MaxCumulativeFundingPercent float64 `json:"max_cumulative_funding_percent"` // 持仓累计资金费支出占保证金的上限（%），超过时在提示词中重点提示，默认0表示不检查
+	CloseOnFundingLimit         bool    `json:"close_on_funding_limit"`         // 累计资金费支出超过上限时由系统自动平仓，默认false只提示
+	BreakevenTriggerPercent     float64 `json:"breakeven_trigger_percent"`      // 持仓盈亏%（按保证金，含杠杆）达到该值且止损仍劣于开仓价时，系统自动把止损移到开仓价，默认0表示不启用
+	BreakevenOffsetTicks        int     `json:"breakeven_offset_ticks"`         // 保本止损在开仓价基础上向有利方向偏移的最小价格变动单位数，用于覆盖手续费，默认0
+	LadderBreakevenStop         bool    `json:"ladder_breakeven_stop"`          // 分批止盈某档成交后，调整剩余仓位止损的同时把仍劣于开仓价的止损移到开仓价（加 breakeven_offset_ticks 偏移），默认false
+	TrendingRegimeOnly          bool    `json:"trending_regime_only"`           // 只允许在1小时级别为趋势行情的交易对上开仓，震荡/不确定行情需AI给出理由
+	HTFTrendGuard               string  `json:"htf_trend_guard"`                // 逆1小时强趋势开仓的处理：off（不检查，默认）、block（拒绝）、justify（必须给出 counter_trend_reason）
+	HTFTrendADX                 float64 `json:"htf_trend_adx"`                  // 判断1小时强趋势的ADX阈值，默认25
+	ConfirmationCandles         int     `json:"confirmation_candles"`           // 15分钟突破需持续收在区间外的已收盘K线数才视为确认，在提示词中标注确认情况，默认0表示不计算
+	BlockUnconfirmedOpens       bool    `json:"block_unconfirmed_opens"`        // 拒绝顺着尚未确认的突破方向开仓，需同时设置 confirmation_candles
+	VolatilitySpikeRatio        float64 `json:"volatility_spike_ratio"`         // 1小时短周期ATR达到长周期ATR的该倍数时视为波动异常放大，在提示词中标注，默认0表示不检查
+	VolatilitySpikeGuard        string  `json:"volatility_spike_guard"`         // 波动异常放大时开仓的处理：off（只标注，默认）、block（拒绝）、reduce（缩减保证金）、widen（要求更宽的止损）
+	VolatilitySpikeMargin       float64 `json:"volatility_spike_margin"`        // reduce 模式下保证金缩减到的比例（0-1]，默认0.5
+	VolatilitySpikeStopATR      float64 `json:"volatility_spike_stop_atr"`      // widen 模式下止损距开仓价至少为短周期ATR的倍数，默认1.5
+	MinListingAgeDays           int     `json:"min_listing_age_days"`           // 交易对上线不足该天数时不参与交易，默认0表示不限制
+	MinKlineCount               int     `json:"min_kline_count"`                // 交易对至少需要的1小时K线根数，不足时不参与交易，默认0表示取指标计算所需的最少K线数
+	MinDailyQuoteVolume         float64 `json:"min_daily_quote_volume"`         // 交易对24小时成交额（USDT）低于该值时不参与交易，默认0表示不限制
+	IdleCapital                 float64 `json:"idle_capital"`                   // 存放在合约账户之外（如理财产品）的闲置资金（USDT），设置后账户接口另按合约净值加该金额计算总资金收益率，默认0
+
+	PositionSyncTolerancePercent float64 `json:"position_sync_tolerance_percent"`  // 持仓同步时数量/价格/盈亏等的相对变化低于该百分比时不写库，默认0.01
+	RiskFreeRatePercent          float64 `json:"risk_free_rate_percent"`           // 年化无风险利率（%），按交易周期间隔复利折算后从每期收益中扣除，用于夏普/索提诺比率，默认0
+	DecisionOutcomeHorizonHours  int     `json:"decision_outcome_horizon_hours"`   // 决策后经过该小时数评估决策结果（价格是否朝决策方向运动），默认4，0表示不评估
+	RecordMarketRegimes          bool    `json:"record_market_regimes"`            // 每个交易周期按交易对记录市场状态（趋势/震荡/不确定），用于按市场状态分析表现，默认true
+	AccountFallbackMaxAgeMinutes int     `json:"account_fallback_max_age_minutes"` // 获取账户指标失败时，使用不早于该分钟数的最近账户历史继续执行风控（跳过AI决策），默认60，0表示直接中止本周期
+	MaxSymbolsPerCycle           int     `json:"max_symbols_per_cycle"`            // 每个交易周期最多收集行情的交易对数量，行情按交易对逐个收集，收集耗时由该上限约束；持仓交易对优先且始终保留，默认0表示不限制
+	CollectionBudgetPercent      float64 `json:"collection_budget_percent"`        // 行情收集最多使用交易周期间隔的百分比，到期后跳过剩余交易对，用已收集的数据继续，默认50，0表示不限制
+
+	PositionHealthWeights PositionHealthWeights `json:"position_health_weights"` // 持仓健康分各因子的权重
+
+	ConfidenceSizing []ConfidenceSizeTier `json:"confidence_sizing"` // 按开仓信心缩减保证金，信心高于所有档位时不缩减
+}
+
+// PositionHealthWeights 持仓健康分各因子的权重，只看相对大小，全部为0时使用默认权重
+type PositionHealthWeights struct {
+	Liquidation float64 `json:"liquidation"` // 距强平价格
+	Stop        float64 `json:"stop"`        // 距止损价
+	Time        float64 `json:"time"`        // 剩余持仓时间
+	Giveback    float64 `json:"giveback"`    // 盈利回吐
+	Funding     float64 `json:"funding"`     // 资金费成本
+}
+
+// DefaultPositionHealthWeights 默认的持仓健康分权重
+var DefaultPositionHealthWeights = PositionHealthWeights{Liquidation: 30, Stop: 25, Time: 15, Giveback: 15, Funding: 15}
+
+// Sum 权重之和
+func (w PositionHealthWeights) Sum() float64 {
+	return w.Liquidation + w.Stop + w.Time + w.Giveback + w.Funding
+}
+
+// Normalized 负权重按0处理，全部为0时返回默认权重
+func (w PositionHealthWeights) Normalized() PositionHealthWeights {
+	w = PositionHealthWeights{
+		Liquidation: max(w.Liquidation, 0),
+		Stop:        max(w.Stop, 0),
+		Time:        max(w.Time, 0),
+		Giveback:    max(w.Giveback, 0),
+		Funding:     max(w.Funding, 0),
+	}
+	if w.Sum() <= 0 {
+		return DefaultPositionHealthWeights
+	}
+	return w
+}
+
+// ConfidenceSizeTier 信心档位：信心不超过 MaxConfidence 时保证金乘以 SizeMultiplier
+type ConfidenceSizeTier struct {
+	MaxConfidence  int     `json:"max_confidence"`
+	SizeMultiplier float64 `json:"size_multiplier"`
 }
 
 type PaperWalletConf struct {
@@ -35,6 +384,46 @@ type LlmConf struct {
 	APIKey   string `json:"api_key"`   // LLM API密钥
 	Model    string `json:"model"`     // 模型名称
 	ProxyURL string `json:"proxy_url"` // 代理地址，例如: http://127.0.0.1:7890
+
+	PromptLanguage string `json:"prompt_language"` // 提示词、工具描述和校验信息的语言：zh（默认）或 en
+
+	// 采样参数，未配置时使用模型服务端默认值；固定 temperature 与 seed 便于复现决策（seed 需模型服务支持）
+	Temperature *float64 `json:"temperature"` // 采样温度
+	TopP        *float64 `json:"top_p"`       // 核采样概率
+	Seed        *int64   `json:"seed"`        // 随机种子
+
+	StoreRawResponse bool `json:"store_raw_response"` // 是否在LLM日志中保存模型服务返回的原始响应，便于审计，会显著增加存储
+	StorePrompt      bool `json:"store_prompt"`       // 是否在决策记录中保存完整的系统提示词和用户提示词，用于复现决策，默认true
+
+	LogRetentionDays *int `json:"log_retention_days"` // 已废弃，改用 retention.llm_log_days，启动时映射到新配置项
+
+	// 上下文窗口：每次调用前估算输入 token 数，超出主模型窗口时改用备用模型，避免请求被拒导致整个周期失败
+	MaxContextTokens map[string]int `json:"max_context_tokens"` // 各模型的上下文窗口（token），键为模型名称，未配置的模型不检查
+	FallbackModel    string         `json:"fallback_model"`     // 输入超出主模型上下文窗口时改用的模型，需在同一 base_url 下可用
+}
+
+// DatabaseConf 数据库连接池配置，数据库类型与连接地址仍在顶层 database 中配置
+// 未配置（0）时按数据库类型使用默认值：SQLite 单连接串行写入，Postgres/MySQL 使用连接池
+type DatabaseConf struct {
+	MaxOpenConns           int `json:"max_open_conns"`             // 最大打开连接数
+	MaxIdleConns           int `json:"max_idle_conns"`             // 最大空闲连接数
+	ConnMaxLifetimeMinutes int `json:"conn_max_lifetime_minutes"`  // 连接最大存活时间（分钟）
+	BusyTimeoutMillis      int `json:"sqlite_busy_timeout_millis"` // SQLite 等待写锁的超时时间（毫秒）
+}
+
+// RetentionConf 数据保留配置，天数为0表示不清理对应的表
+type RetentionConf struct {
+	LLMLogDays             int `json:"llm_log_days"`              // LLM日志保留天数
+	DecisionDays           int `json:"decision_days"`             // 决策记录保留天数
+	MarketRegimeDays       int `json:"market_regime_days"`        // 市场状态记录保留天数
+	AccountHistoryFullDays int `json:"account_history_full_days"` // 账户历史保留完整精度的天数，更早的记录每天只保留第一条
+	IntervalMinutes        int `json:"interval_minutes"`          // 清理间隔（分钟）
+	BatchSize              int `json:"batch_size"`                // 每批删除的行数，避免长时间锁表
+}
+
+// Interval 清理间隔，未配置时返回0表示不启动清理
+func (c RetentionConf) Interval() time.Duration {
+	return time.Duration(c.IntervalMinutes) * time.Minute
 }
 
 type AdminConf struct {
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTradingMode(t *testing.T) {
+	conf := Default()
+	if conf.TradingMode() != TradingModePaper {
+		t.Fatalf("default mode = %s, want paper", conf.TradingMode())
+	}
+
+	// 未配置 exchange.mode 时沿用旧的 trading.enabled
+	conf.Trading.Enabled = true
+	if !conf.IsLive() {
+		t.Fatal("trading.enabled without exchange.mode should be live")
+	}
+	if err := conf.CheckLiveConfirmed(); err == nil {
+		t.Fatal("live mode without confirm_live should be refused")
+	}
+	conf.Exchange.ConfirmLive = true
+	if err := conf.CheckLiveConfirmed(); err != nil {
+		t.Fatalf("confirmed live mode: %v", err)
+	}
+
+	// 显式配置与旧配置矛盾时拒绝启动
+	conf.Exchange.Mode = "Paper"
+	if err := conf.ValidateTradingMode(); err == nil {
+		t.Fatal("exchange.mode paper with trading.enabled should be invalid")
+	}
+	conf.Trading.Enabled = false
+	if err := conf.ValidateTradingMode(); err != nil || conf.IsLive() {
+		t.Fatalf("paper mode: live = %v, err = %v", conf.IsLive(), err)
+	}
+
+	conf.Exchange.Mode = "real"
+	if err := conf.ValidateTradingMode(); err == nil {
+		t.Fatal("unknown mode should be invalid")
+	}
+}
+
+func TestDisplayTimezone(t *testing.T) {
+	conf := Default()
+	if err := conf.ValidateDisplayTimezone(); err != nil {
+		t.Fatalf("default timezone: %v", err)
+	}
+	if conf.DisplayLocation().String() != "UTC" {
+		t.Fatalf("default location = %s, want UTC", conf.DisplayLocation())
+	}
+
+	conf.DisplayTimezone = "Asia/Shanghai"
+	if err := conf.ValidateDisplayTimezone(); err != nil {
+		t.Fatalf("valid timezone: %v", err)
+	}
+	if conf.DisplayLocation().String() != "Asia/Shanghai" {
+		t.Fatalf("location = %s, want Asia/Shanghai", conf.DisplayLocation())
+	}
+
+	conf = Default()
+	conf.DisplayTimezone = "Mars/Olympus"
+	if err := conf.ValidateDisplayTimezone(); err == nil {
+		t.Fatal("unknown timezone should be invalid")
+	}
+	if conf.DisplayLocation() != time.UTC {
+		t.Fatal("invalid timezone should fall back to UTC")
+	}
+}
+
+// 旧的 llm.log_retention_days 映射到 retention.llm_log_days，显式配置的新配置项优先
+func TestApplyDeprecatedKeys(t *testing.T) {
+	conf := Default()
+	if deprecated := conf.ApplyDeprecatedKeys(); len(deprecated) != 0 {
+		t.Fatalf("deprecated = %v, want none", deprecated)
+	}
+
+	days := 7
+	conf.LLM.LogRetentionDays = &days
+	if deprecated := conf.ApplyDeprecatedKeys(); len(deprecated) != 1 || conf.Retention.LLMLogDays != 7 {
+		t.Fatalf("deprecated = %v, llm log days = %d, want one warning and 7", deprecated, conf.Retention.LLMLogDays)
+	}
+
+	conf = Default()
+	conf.Retention.LLMLogDays = 14
+	conf.LLM.LogRetentionDays = &days
+	if deprecated := conf.ApplyDeprecatedKeys(); len(deprecated) != 1 || conf.Retention.LLMLogDays != 14 {
+		t.Fatalf("deprecated = %v, llm log days = %d, want the explicit 14 kept", deprecated, conf.Retention.LLMLogDays)
+	}
+}
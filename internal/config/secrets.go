@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretEnvPrefix 密钥环境变量前缀
+const SecretEnvPrefix = "PRISM_"
+
+// secretField 可通过环境变量覆盖的密钥字段
+type secretField struct {
+	env   string  // 环境变量名（不含前缀）
+	value *string // 配置中对应的字段
+}
+
+func (c *Config) secretFields() []secretField {
+	return []secretField{
+		{env: "BINANCE_APIKEY", value: &c.Binance.APIKey},
+		{env: "BINANCE_SECRET", value: &c.Binance.Secret},
+		{env: "LLM_APIKEY", value: &c.LLM.APIKey},
+		{env: "TELEGRAM_TOKEN", value: &c.Telegram.Token},
+		{env: "ADMIN_JWTSECRET", value: &c.Admin.JWTSecret},
+	}
+}
+
+// ApplySecretOverrides 使用环境变量覆盖配置文件中的密钥
+//
+// 优先级：环境变量（如 PRISM_BINANCE_APIKEY） > 文件间接引用（如 PRISM_BINANCE_APIKEY_FILE，
+// 读取该路径文件内容，适用于 Docker/K8s secrets） > 配置文件
+func (c *Config) ApplySecretOverrides() error {
+	return c.applySecretOverrides(os.LookupEnv, os.ReadFile)
+}
+
+func (c *Config) applySecretOverrides(lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error)) error {
+	for _, field := range c.secretFields() {
+		name := SecretEnvPrefix + field.env
+
+		if value, ok := lookupEnv(name); ok && value != "" {
+			*field.value = value
+			continue
+		}
+
+		path, ok := lookupEnv(name + "_FILE")
+		if !ok || path == "" {
+			continue
+		}
+		data, err := readFile(path)
+		if err != nil {
+			return fmt.Errorf("读取 %s_FILE 指定的密钥文件失败: %w", name, err)
+		}
+		*field.value = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplySecretOverrides(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "binance_secret")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "binance_key")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"PRISM_BINANCE_APIKEY":      "env-key",
+		"PRISM_BINANCE_APIKEY_FILE": keyFile,
+		"PRISM_BINANCE_SECRET_FILE": secretFile,
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	conf := Config{
+		Binance: BinanceConf{APIKey: "yaml-key", Secret: "yaml-secret"},
+		LLM:     LlmConf{APIKey: "yaml-llm"},
+	}
+	if err := conf.applySecretOverrides(lookup, os.ReadFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conf.Binance.APIKey != "env-key" {
+		t.Errorf("APIKey = %q, want env value", conf.Binance.APIKey)
+	}
+	if conf.Binance.Secret != "file-secret" {
+		t.Errorf("Secret = %q, want file value", conf.Binance.Secret)
+	}
+	if conf.LLM.APIKey != "yaml-llm" {
+		t.Errorf("LLM.APIKey = %q, want yaml value", conf.LLM.APIKey)
+	}
+
+	env["PRISM_LLM_APIKEY_FILE"] = filepath.Join(dir, "missing")
+	if err := conf.applySecretOverrides(lookup, os.ReadFile); err == nil {
+		t.Error("expected error for missing secret file")
+	}
+}
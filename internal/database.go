@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 连接池默认值
+const (
+	defaultPoolMaxOpenConns        = 20
+	defaultPoolMaxIdleConns        = 10
+	defaultPoolConnMaxLifetime     = 30 * time.Minute
+	defaultSqliteBusyTimeoutMillis = 5000
+)
+
+// databasePool 连接池参数
+type databasePool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// resolveDatabasePool 根据数据库类型和配置计算连接池参数
+//
+// SQLite 同一时刻只允许一个写入者，后台同步与交易循环并发写入时容易出现 database is locked，
+// 因此默认只保留一个连接让写入在连接池层面排队；Postgres/MySQL 使用常规连接池。
+func resolveDatabasePool(dialect string, conf config.DatabaseConf) databasePool {
+	pool := databasePool{
+		MaxOpenConns:    defaultPoolMaxOpenConns,
+		MaxIdleConns:    defaultPoolMaxIdleConns,
+		ConnMaxLifetime: defaultPoolConnMaxLifetime,
+	}
+	if dialect == "sqlite" {
+		// SQLite 的 PRAGMA 只对当前连接生效，连接不过期以免丢失设置
+		pool = databasePool{MaxOpenConns: 1, MaxIdleConns: 1}
+	}
+
+	if conf.MaxOpenConns > 0 {
+		pool.MaxOpenConns = conf.MaxOpenConns
+	}
+	if conf.MaxIdleConns > 0 {
+		pool.MaxIdleConns = conf.MaxIdleConns
+	}
+	if pool.MaxIdleConns > pool.MaxOpenConns {
+		pool.MaxIdleConns = pool.MaxOpenConns
+	}
+	if conf.ConnMaxLifetimeMinutes > 0 {
+		pool.ConnMaxLifetime = time.Duration(conf.ConnMaxLifetimeMinutes) * time.Minute
+	}
+	return pool
+}
+
+// configureDatabase 设置连接池，SQLite 额外开启 WAL 并设置写锁等待时间
+func configureDatabase(db *gorm.DB, conf config.DatabaseConf, logger *zap.Logger) error {
+	dialect := db.Dialector.Name()
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql db: %w", err)
+	}
+
+	pool := resolveDatabasePool(dialect, conf)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
+	if dialect == "sqlite" {
+		busyTimeout := conf.BusyTimeoutMillis
+		if busyTimeout <= 0 {
+			busyTimeout = defaultSqliteBusyTimeoutMillis
+		}
+		if err := db.Exec("PRAGMA journal_mode=WAL").Error; err != nil {
+			return fmt.Errorf("failed to enable sqlite wal: %w", err)
+		}
+		if err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout=%d", busyTimeout)).Error; err != nil {
+			return fmt.Errorf("failed to set sqlite busy timeout: %w", err)
+		}
+	}
+
+	logger.Info("database pool configured",
+		zap.String("dialect", dialect),
+		zap.Int("max_open_conns", pool.MaxOpenConns),
+		zap.Int("max_idle_conns", pool.MaxIdleConns),
+		zap.Duration("conn_max_lifetime", pool.ConnMaxLifetime))
+	return nil
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/service"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestResolveDatabasePool(t *testing.T) {
+	pool := resolveDatabasePool("sqlite", config.DatabaseConf{})
+	if pool.MaxOpenConns != 1 || pool.MaxIdleConns != 1 || pool.ConnMaxLifetime != 0 {
+		t.Fatalf("sqlite default pool = %+v", pool)
+	}
+
+	pool = resolveDatabasePool("postgres", config.DatabaseConf{})
+	if pool.MaxOpenConns != defaultPoolMaxOpenConns || pool.MaxIdleConns != defaultPoolMaxIdleConns ||
+		pool.ConnMaxLifetime != defaultPoolConnMaxLifetime {
+		t.Fatalf("postgres default pool = %+v", pool)
+	}
+
+	pool = resolveDatabasePool("postgres", config.DatabaseConf{MaxOpenConns: 5, MaxIdleConns: 8, ConnMaxLifetimeMinutes: 10})
+	if pool.MaxOpenConns != 5 || pool.MaxIdleConns != 5 || pool.ConnMaxLifetime != 10*time.Minute {
+		t.Fatalf("configured pool = %+v", pool)
+	}
+}
+
+// 单连接的 SQLite 下，事务内的仓储调用必须复用事务连接，否则会互相等待导致卡死
+func TestSqliteSingleConnectionTransaction(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := configureDatabase(db, config.DatabaseConf{}, zap.NewNop()); err != nil {
+		t.Fatalf("configure database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if got := sqlDB.Stats().MaxOpenConnections; got != 1 {
+		t.Fatalf("max open connections = %d, want 1", got)
+	}
+	if err := db.AutoMigrate(models.SystemPrompt{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	adminConfigService := service.NewAdminConfigService(zap.NewNop(), db)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if _, err := adminConfigService.SetSystemPrompt(ctx, "最多 {{max_positions}} 个仓位", "test"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("set system prompt: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("set system prompt deadlocked on single sqlite connection")
+	}
+
+	prompt, err := adminConfigService.GetSystemPrompt(context.Background())
+	if err != nil {
+		t.Fatalf("get system prompt: %v", err)
+	}
+	if prompt.Version != 2 {
+		t.Fatalf("active prompt version = %d, want 2", prompt.Version)
+	}
+}
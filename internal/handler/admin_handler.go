@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/service"
@@ -13,16 +16,25 @@ import (
 type AdminHandler struct {
 	logger             *zap.Logger
 	adminConfigService *service.AdminConfigService
+	accountService     *service.TradingAccountService
+	retentionService   *service.RetentionService
+	tradingLoop        *service.TradingLoop
 }
 
 // NewAdminHandler 创建管理员处理器
 func NewAdminHandler(
 	logger *zap.Logger,
 	adminConfigService *service.AdminConfigService,
+	accountService *service.TradingAccountService,
+	retentionService *service.RetentionService,
+	tradingLoop *service.TradingLoop,
 ) *AdminHandler {
 	return &AdminHandler{
 		logger:             logger,
 		adminConfigService: adminConfigService,
+		accountService:     accountService,
+		retentionService:   retentionService,
+		tradingLoop:        tradingLoop,
 	}
 }
 
@@ -56,13 +68,29 @@ func (h *AdminHandler) SetTradingConfig(c echo.Context) error {
 	}
 
 	if err := h.adminConfigService.SetTradingConfig(ctx, tradingConfig); err != nil {
+		if errors.Is(err, service.ErrInvalidIndicatorParams) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	return c.JSON(http.StatusOK, map[string]interface{}{
+
+	// 上线时长、K线历史或成交额不足的交易对仍然保存，但在响应中提示，交易循环会自动跳过它们
+	response := map[string]interface{}{
 		"message": "update success",
-	})
+	}
+	if saved, err := h.adminConfigService.GetTradingConfig(ctx); err == nil {
+		if immature := h.tradingLoop.ImmatureSymbols(ctx, saved); len(immature) > 0 {
+			response["immature_symbols"] = immature
+		}
+		if illiquid := h.tradingLoop.IlliquidSymbols(ctx, saved); len(illiquid) > 0 {
+			response["illiquid_symbols"] = illiquid
+		}
+	}
+	return c.JSON(http.StatusOK, response)
 }
 
 // GetSystemPrompt 获取当前激活的系统提示词
@@ -104,6 +132,13 @@ func (h *AdminHandler) SetSystemPrompt(c echo.Context) error {
 	}
 
 	prompt, err := h.adminConfigService.SetSystemPrompt(ctx, req.Content, req.Remark)
+	var placeholderErr *service.UnknownPlaceholdersError
+	if errors.As(err, &placeholderErr) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":                placeholderErr.Error(),
+			"unknown_placeholders": placeholderErr.Unknown,
+		})
+	}
 	if err != nil {
 		h.logger.Error("failed to set system prompt", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
@@ -114,6 +149,14 @@ func (h *AdminHandler) SetSystemPrompt(c echo.Context) error {
 	return c.JSON(http.StatusOK, prompt)
 }
 
+// GetSystemPromptPlaceholders 获取系统提示词可用的占位符
+// GET /api/admin/system-prompt/placeholders
+func (h *AdminHandler) GetSystemPromptPlaceholders(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"placeholders": service.SystemPromptPlaceholders(),
+	})
+}
+
 // GetSystemPromptHistory 获取系统提示词历史记录
 // GET /api/admin/system-prompt/history
 func (h *AdminHandler) GetSystemPromptHistory(c echo.Context) error {
@@ -155,6 +198,139 @@ func (h *AdminHandler) RollbackSystemPrompt(c echo.Context) error {
 	})
 }
 
+// GetStateDump 导出系统完整状态快照（配置、提示词版本、循环状态、持仓、活跃订单、账户指标、最近决策），密钥已脱敏
+// GET /api/admin/state-dump?decisions=10
+func (h *AdminHandler) GetStateDump(c echo.Context) error {
+	limit := 0
+	if l := c.QueryParam("decisions"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": "decisions must be a positive integer",
+			})
+		}
+		limit = n
+	}
+
+	return c.JSON(http.StatusOK, h.tradingLoop.StateDump(c.Request().Context(), limit))
+}
+
+// GetCapitalFlows 获取出入金记录
+// GET /api/admin/capital-flows
+func (h *AdminHandler) GetCapitalFlows(c echo.Context) error {
+	flows, err := h.accountService.ListCapitalFlows(c.Request().Context())
+	if err != nil {
+		h.logger.Error("failed to get capital flows", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, flows)
+}
+
+// CreateCapitalFlow 手动录入出入金，amount 正数为入金、负数为出金
+// POST /api/admin/capital-flows
+func (h *AdminHandler) CreateCapitalFlow(c echo.Context) error {
+	var req struct {
+		Amount     float64   `json:"amount"`
+		Note       string    `json:"note"`
+		OccurredAt time.Time `json:"occurred_at"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request body",
+		})
+	}
+
+	flow, err := h.accountService.RecordCapitalFlow(c.Request().Context(), req.Amount, req.Note, req.OccurredAt)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, flow)
+}
+
+// DeleteCapitalFlow 删除手动录入的出入金记录
+// DELETE /api/admin/capital-flows/:id
+func (h *AdminHandler) DeleteCapitalFlow(c echo.Context) error {
+	if err := h.accountService.DeleteCapitalFlow(c.Request().Context(), c.Param("id")); err != nil {
+		h.logger.Error("failed to delete capital flow", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "delete success",
+	})
+}
+
+// GetPaperAccount 获取模拟账户状态
+// GET /api/admin/paper
+func (h *AdminHandler) GetPaperAccount(c echo.Context) error {
+	state, err := h.accountService.GetPaperAccount()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
+// ResetPaperAccount 重置模拟账户，清空持仓、交易、决策和资金曲线
+// POST /api/admin/paper/reset
+func (h *AdminHandler) ResetPaperAccount(c echo.Context) error {
+	return h.resetPaperAccount(c, 0)
+}
+
+// SetPaperBalance 修改模拟账户初始资金并重置
+// PUT /api/admin/paper/balance
+// 请求体 {"initial_balance": 10000}
+func (h *AdminHandler) SetPaperBalance(c echo.Context) error {
+	var req struct {
+		InitialBalance float64 `json:"initial_balance"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request body",
+		})
+	}
+	if req.InitialBalance <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "initial_balance must be positive",
+		})
+	}
+	return h.resetPaperAccount(c, req.InitialBalance)
+}
+
+// resetPaperAccount 交易循环运行中时拒绝重置，避免重置过程中产生新的交易
+func (h *AdminHandler) resetPaperAccount(c echo.Context, initialBalance float64) error {
+	if h.tradingLoop != nil && h.tradingLoop.IsRunning() {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error": "请先停止交易循环再重置模拟账户",
+		})
+	}
+
+	state, err := h.accountService.ResetPaperAccount(c.Request().Context(), initialBalance)
+	if err != nil {
+		if errors.Is(err, service.ErrNotPaperMode) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("failed to reset paper account", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	h.logger.Info("paper account reset via API", zap.Float64("initial_balance", state.InitialBalance))
+	return c.JSON(http.StatusOK, state)
+}
+
 // RegisterRoutesWithGroup 注册路由到指定的组（支持中间件）
 func (h *AdminHandler) RegisterRoutesWithGroup(admin *echo.Group) {
 	// 通用配置接口
@@ -164,9 +340,35 @@ func (h *AdminHandler) RegisterRoutesWithGroup(admin *echo.Group) {
 	admin.GET("/system-prompt", h.GetSystemPrompt)
 	admin.PUT("/system-prompt", h.SetSystemPrompt)
 
+	admin.GET("/system-prompt/placeholders", h.GetSystemPromptPlaceholders)
 	admin.GET("/system-prompt/history", h.GetSystemPromptHistory)
 	admin.GET("/system-prompt/history/:id/rollback", h.RollbackSystemPrompt)
 	admin.DELETE("/system-prompt/history/:id", h.DeleteSystemPromptHistory)
+
+	admin.GET("/state-dump", h.GetStateDump)
+
+	admin.GET("/capital-flows", h.GetCapitalFlows)
+	admin.POST("/capital-flows", h.CreateCapitalFlow)
+	admin.DELETE("/capital-flows/:id", h.DeleteCapitalFlow)
+
+	admin.GET("/retention", h.GetRetentionStatus)
+
+	admin.GET("/paper", h.GetPaperAccount)
+	admin.POST("/paper/reset", h.ResetPaperAccount)
+	admin.PUT("/paper/balance", h.SetPaperBalance)
+}
+
+// GetRetentionStatus 获取数据保留状态（各表行数和最近一次清理情况）
+// GET /api/admin/retention
+func (h *AdminHandler) GetRetentionStatus(c echo.Context) error {
+	status, err := h.retentionService.Status(c.Request().Context())
+	if err != nil {
+		h.logger.Error("failed to get retention status", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, status)
 }
 
 // DeleteSystemPromptHistory 删除系统提示词历史记录
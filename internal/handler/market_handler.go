@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dushixiang/prism/internal/service"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// MarketHandler 市场数据HTTP处理器
+type MarketHandler struct {
+	logger             *zap.Logger
+	marketService      *service.MarketService
+	adminConfigService *service.AdminConfigService
+}
+
+// NewMarketHandler 创建市场数据处理器
+func NewMarketHandler(logger *zap.Logger, marketService *service.MarketService, adminConfigService *service.AdminConfigService) *MarketHandler {
+	return &MarketHandler{
+		logger:             logger,
+		marketService:      marketService,
+		adminConfigService: adminConfigService,
+	}
+}
+
+// GetMultiTimeframe 获取交易对的多时间框架指标快照及共振方向
+// GET /api/market/multi-timeframe?symbol=BTCUSDT
+// 只允许查询已配置的交易对，指标周期与交易循环一致
+func (h *MarketHandler) GetMultiTimeframe(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	symbol := exchange.NormalizeSymbol(c.QueryParam("symbol"))
+	if symbol == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "symbol is required",
+		})
+	}
+
+	tradingConfig, err := h.adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		h.logger.Error("failed to get trading config", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if !slices.Contains(tradingConfig.Symbols, symbol) {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "symbol is not configured for trading",
+		})
+	}
+
+	snapshot, err := h.marketService.GetMultiTimeframeSnapshot(ctx, symbol, tradingConfig.IndicatorParamsFor(symbol))
+	if err != nil {
+		h.logger.Error("failed to get multi-timeframe snapshot", zap.String("symbol", symbol), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// 市场状态时间线查询
+const (
+	defaultRegimeWindow = 7 * 24 * time.Hour // 未指定 from 时查询最近7天
+	defaultRegimeLimit  = 2000
+	maxRegimeLimit      = 20000
+)
+
+// GetRegimeTimeline 查询交易对的市场状态时间线
+// GET /api/market/regimes?symbol=BTCUSDT&from=2025-01-01T00:00:00Z&to=2025-01-08T00:00:00Z&limit=2000
+//
+// symbol 为空时返回全部交易对；records 为每个周期的原始记录，segments 为同一交易对连续相同状态合并后的区间。
+func (h *MarketHandler) GetRegimeTimeline(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	symbol := exchange.NormalizeSymbol(c.QueryParam("symbol"))
+	from, to, err := parseTimeRange(c, defaultRegimeWindow)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	limit, err := parseLimit(c, defaultRegimeLimit, maxRegimeLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	timeline, err := h.marketService.GetRegimeTimeline(ctx, symbol, from, to, limit)
+	if err != nil {
+		h.logger.Error("failed to get regime timeline", zap.String("symbol", symbol), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"symbol":   symbol,
+		"from":     from,
+		"to":       to,
+		"count":    len(timeline.Records),
+		"records":  timeline.Records,
+		"segments": timeline.Segments,
+	})
+}
+
+// RegisterRoutes 注册路由
+func (h *MarketHandler) RegisterRoutes(g *echo.Group) {
+	market := g.Group("/market")
+
+	market.GET("/multi-timeframe", h.GetMultiTimeframe)
+	market.GET("/regimes", h.GetRegimeTimeline)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dushixiang/prism/internal/repo"
+	"github.com/labstack/echo/v4"
+)
+
+// parseTimeRange 解析 RFC3339 格式的 from/to 查询参数，to 默认为当前时间，from 默认为 to 之前 window
+func parseTimeRange(c echo.Context, window time.Duration) (from, to time.Time, err error) {
+	to = time.Now()
+	if raw := c.QueryParam("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			return from, to, errors.New("invalid to, expected RFC3339 time")
+		}
+	}
+	from = to.Add(-window)
+	if raw := c.QueryParam("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			return from, to, errors.New("invalid from, expected RFC3339 time")
+		}
+	}
+	if from.After(to) {
+		return from, to, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// parseLimit 解析 limit 查询参数，未指定时返回 defaultLimit，超过 maxLimit 时截断
+func parseLimit(c echo.Context, defaultLimit, maxLimit int) (int, error) {
+	raw := c.QueryParam("limit")
+	if raw == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("invalid limit")
+	}
+	return min(limit, maxLimit), nil
+}
+
+// parsePagination 解析 page/page_size 查询参数，page 默认1
+//
+// 未指定 page_size 时兼容旧的 limit 参数，都未指定时返回 defaultSize，超过 maxSize 时截断。
+func parsePagination(c echo.Context, defaultSize, maxSize int) (repo.Pagination, error) {
+	page := repo.Pagination{Page: 1, PageSize: defaultSize}
+	if raw := c.QueryParam("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return page, errors.New("invalid page")
+		}
+		page.Page = n
+	}
+
+	raw := c.QueryParam("page_size")
+	if raw == "" {
+		raw = c.QueryParam("limit")
+	}
+	if raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return page, errors.New("invalid page_size")
+		}
+		page.PageSize = min(n, maxSize)
+	}
+	return page, nil
+}
+
+// pageFields 分页响应的公共字段，next_page 为0表示没有下一页
+func pageFields(page repo.Pagination, count int, total int64) map[string]interface{} {
+	return map[string]interface{}{
+		"count":     count,
+		"total":     total,
+		"page":      page.Page,
+		"page_size": page.PageSize,
+		"next_page": page.NextPage(total),
+	}
+}
@@ -2,12 +2,15 @@ package handler
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/dushixiang/prism/internal/service"
+	"github.com/dushixiang/prism/pkg/exchange"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // TradingHandler 交易系统HTTP处理器
@@ -16,6 +19,7 @@ type TradingHandler struct {
 	accountService  *service.TradingAccountService
 	positionService *service.PositionService
 	agentService    *service.AgentService
+	binanceClient   *exchange.BinanceClient
 	logger          *zap.Logger
 	loopCtx         context.Context
 	loopCancel      context.CancelFunc
@@ -27,6 +31,7 @@ func NewTradingHandler(
 	accountService *service.TradingAccountService,
 	positionService *service.PositionService,
 	agentService *service.AgentService,
+	binanceClient *exchange.BinanceClient,
 	logger *zap.Logger,
 ) *TradingHandler {
 	return &TradingHandler{
@@ -34,6 +39,7 @@ func NewTradingHandler(
 		accountService:  accountService,
 		positionService: positionService,
 		agentService:    agentService,
+		binanceClient:   binanceClient,
 		logger:          logger,
 	}
 }
@@ -54,7 +60,8 @@ func (h *TradingHandler) GetStatus(c echo.Context) error {
 	if err != nil {
 		h.logger.Error("failed to get account metrics", zap.Error(err))
 		return c.JSON(http.StatusOK, map[string]interface{}{
-			"loop": loopStatus,
+			"loop":       loopStatus,
+			"rate_limit": h.binanceClient.RateLimitUsage(),
 		})
 	}
 
@@ -67,21 +74,24 @@ func (h *TradingHandler) GetStatus(c echo.Context) error {
 	positionsData := make([]map[string]interface{}, 0, len(positions))
 	for _, pos := range positions {
 		positionsData = append(positionsData, map[string]interface{}{
-			"id":             pos.ID,
-			"symbol":         pos.Symbol,
-			"side":           pos.Side,
-			"quantity":       pos.Quantity,
-			"entry_price":    pos.EntryPrice,
-			"current_price":  pos.CurrentPrice,
-			"unrealized_pnl": pos.UnrealizedPnl,
-			"pnl_percent":    pos.CalculatePnlPercent(),
-			"leverage":       pos.Leverage,
-			"holding":        pos.CalculateHoldingStr(),
-			"opened_at":      pos.OpenedAt,
-			"entry_reason":   pos.EntryReason,
-			"exit_plan":      pos.ExitPlan,
-			"stop_loss":      pos.StopLoss,
-			"take_profit":    pos.TakeProfit,
+			"id":                 pos.ID,
+			"symbol":             pos.Symbol,
+			"side":               pos.Side,
+			"quantity":           pos.Quantity,
+			"entry_price":        pos.EntryPrice,
+			"current_price":      pos.CurrentPrice,
+			"unrealized_pnl":     pos.UnrealizedPnl,
+			"pnl_percent":        pos.CalculatePnlPercent(),
+			"leverage":           pos.Leverage,
+			"holding":            pos.CalculateHoldingStr(),
+			"opened_at":          pos.OpenedAt,
+			"entry_reason":       pos.EntryReason,
+			"exit_plan":          pos.ExitPlan,
+			"stop_loss":          pos.StopLoss,
+			"take_profit":        pos.TakeProfit,
+			"invalidation_price": pos.InvalidationPrice,
+			"thesis_invalidated": pos.IsThesisInvalidated(),
+			"delisted_status":    pos.DelistedStatus,
 		})
 	}
 
@@ -95,8 +105,12 @@ func (h *TradingHandler) GetStatus(c echo.Context) error {
 			"drawdown_from_peak":    accountMetrics.DrawdownFromPeak,
 			"drawdown_from_initial": accountMetrics.DrawdownFromInitial,
 			"sharpe_ratio":          accountMetrics.SharpeRatio,
+			"sortino_ratio":         accountMetrics.SortinoRatio,
+			"cumulative_funding":    accountMetrics.CumulativeFunding,
+			"mode":                  accountMetrics.Mode,
 		},
-		"positions": positionsData,
+		"positions":  positionsData,
+		"rate_limit": h.binanceClient.RateLimitUsage(),
 	})
 }
 
@@ -117,18 +131,28 @@ func (h *TradingHandler) GetAccount(c echo.Context) error {
 		"available":             accountMetrics.Available,
 		"unrealised_pnl":        accountMetrics.UnrealisedPnl,
 		"initial_balance":       accountMetrics.InitialBalance,
+		"net_deposits":          accountMetrics.NetDeposits,
 		"peak_balance":          accountMetrics.PeakBalance,
 		"return_percent":        accountMetrics.ReturnPercent,
 		"drawdown_from_peak":    accountMetrics.DrawdownFromPeak,
 		"drawdown_from_initial": accountMetrics.DrawdownFromInitial,
 		"sharpe_ratio":          accountMetrics.SharpeRatio,
+		"sortino_ratio":         accountMetrics.SortinoRatio,
+		"cumulative_funding":    accountMetrics.CumulativeFunding,
+		"margin_used":           accountMetrics.MarginUsed,
+		"capital_utilization":   accountMetrics.CapitalUtilization,
+		"idle_capital":          accountMetrics.IdleCapital,
+		"total_capital":         accountMetrics.TotalCapital,
+		"total_capital_return":  accountMetrics.TotalCapitalReturn,
+		"mode":                  accountMetrics.Mode,
 	})
 }
 
 // GetPositions 获取持仓列表
-// GET /api/trading/positions
+// GET /api/trading/positions?symbol_format=slash
 func (h *TradingHandler) GetPositions(c echo.Context) error {
 	ctx := c.Request().Context()
+	symbolFormat := exchange.SymbolFormat(c.QueryParam("symbol_format"))
 
 	positions, err := h.positionService.GetAllPositions(ctx)
 	if err != nil {
@@ -137,27 +161,35 @@ func (h *TradingHandler) GetPositions(c echo.Context) error {
 		})
 	}
 
+	health := h.agentService.PositionsHealth(ctx, positions)
+
 	positionsData := make([]map[string]interface{}, 0, len(positions))
 	for _, pos := range positions {
 		positionsData = append(positionsData, map[string]interface{}{
-			"id":                pos.ID,
-			"symbol":            pos.Symbol,
-			"side":              pos.Side,
-			"quantity":          pos.Quantity,
-			"entry_price":       pos.EntryPrice,
-			"current_price":     pos.CurrentPrice,
-			"liquidation_price": pos.LiquidationPrice,
-			"unrealized_pnl":    pos.UnrealizedPnl,
-			"pnl_percent":       pos.CalculatePnlPercent(),
-			"leverage":          pos.Leverage,
-			"margin":            pos.Margin,
-			"peak_pnl_percent":  pos.PeakPnlPercent,
-			"holding":           pos.CalculateHoldingStr(),
-			"opened_at":         pos.OpenedAt,
-			"entry_reason":      pos.EntryReason,
-			"exit_plan":         pos.ExitPlan,
-			"stop_loss":         pos.StopLoss,
-			"take_profit":       pos.TakeProfit,
+			"id":                     pos.ID,
+			"symbol":                 exchange.DenormalizeSymbol(pos.Symbol, symbolFormat),
+			"side":                   pos.Side,
+			"quantity":               pos.Quantity,
+			"entry_price":            pos.EntryPrice,
+			"current_price":          pos.CurrentPrice,
+			"liquidation_price":      pos.LiquidationPrice,
+			"unrealized_pnl":         pos.UnrealizedPnl,
+			"pnl_percent":            pos.CalculatePnlPercent(),
+			"leverage":               pos.Leverage,
+			"margin":                 pos.Margin,
+			"notional":               pos.Notional(),
+			"peak_pnl_percent":       pos.PeakPnlPercent,
+			"holding":                pos.CalculateHoldingStr(),
+			"opened_at":              pos.OpenedAt,
+			"entry_reason":           pos.EntryReason,
+			"exit_plan":              pos.ExitPlan,
+			"stop_loss":              pos.StopLoss,
+			"take_profit":            pos.TakeProfit,
+			"invalidation_price":     pos.InvalidationPrice,
+			"thesis_invalidated":     pos.IsThesisInvalidated(),
+			"delisted_status":        pos.DelistedStatus,
+			"entry_slippage_percent": pos.EntrySlippagePercent,
+			"health":                 health[pos.ID],
 		})
 	}
 
@@ -167,50 +199,57 @@ func (h *TradingHandler) GetPositions(c echo.Context) error {
 	})
 }
 
+// maxHistoryPageSize 决策、交易和LLM日志分页查询的每页最大条数
+const maxHistoryPageSize = 200
+
 // GetDecisions 获取决策历史
-// GET /api/trading/decisions?limit=10
+// GET /api/trading/decisions?page=1&page_size=10
+// 按执行时间倒序分页，兼容旧的 limit 参数；响应中 total 为总数，next_page 为0表示没有下一页
 func (h *TradingHandler) GetDecisions(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	limit := 10
-	if l := c.QueryParam("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+	page, err := parsePagination(c, 10, maxHistoryPageSize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
 	}
 
-	decisions, err := h.agentService.GetRecentDecisions(ctx, limit)
+	decisions, total, err := h.agentService.GetDecisionsPage(ctx, page)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"count":     len(decisions),
-		"decisions": decisions,
-	})
+	resp := pageFields(page, len(decisions), total)
+	resp["decisions"] = decisions
+	return c.JSON(http.StatusOK, resp)
 }
 
 // GetTrades 获取交易历史
-// GET /api/trading/trades?limit=20
+// GET /api/trading/trades?page=1&page_size=20
+// 按执行时间倒序分页，兼容旧的 limit 参数
 func (h *TradingHandler) GetTrades(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	limit := 20
-	if l := c.QueryParam("limit"); l != "" {
-		fmt.Sscanf(l, "%d", &limit)
+	page, err := parsePagination(c, 20, maxHistoryPageSize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
 	}
 
-	trades, err := h.agentService.GetRecentTrades(ctx, limit)
+	trades, total, err := h.agentService.GetTradesPage(ctx, page)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"count":  len(trades),
-		"trades": trades,
-	})
+	resp := pageFields(page, len(trades), total)
+	resp["trades"] = trades
+	return c.JSON(http.StatusOK, resp)
 }
 
 // GetStats 获取交易统计数据
@@ -263,6 +302,21 @@ func (h *TradingHandler) GetEquityCurve(c echo.Context) error {
 	})
 }
 
+// GetDrawdown 获取回撤分析
+// GET /api/trading/drawdown
+func (h *TradingHandler) GetDrawdown(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	analysis, err := h.accountService.GetDrawdownAnalysis(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, analysis)
+}
+
 // Start 启动交易循环
 // POST /api/trading/start
 func (h *TradingHandler) Start(c echo.Context) error {
@@ -271,6 +325,11 @@ func (h *TradingHandler) Start(c echo.Context) error {
 			"error": "trading loop is already running",
 		})
 	}
+	if err := h.tradingLoop.CheckLiveConfirmed(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
 
 	// 创建新的context
 	h.loopCtx, h.loopCancel = context.WithCancel(context.Background())
@@ -310,9 +369,185 @@ func (h *TradingHandler) Stop(c echo.Context) error {
 	})
 }
 
+// RunOnce 立即执行一个交易周期并返回结果摘要，不受定时调度限制，交易循环未启动时也可执行
+// POST /api/trading/run-once
+func (h *TradingHandler) RunOnce(c echo.Context) error {
+	// 周期可能持续数分钟，客户端断开连接不应中断正在执行的决策
+	ctx := context.WithoutCancel(c.Request().Context())
+	summary, err := h.tradingLoop.RunOnce(ctx)
+	if err != nil {
+		if errors.Is(err, service.ErrCycleInProgress) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("on-demand trading cycle failed", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	h.logger.Info("trading cycle executed via API",
+		zap.Int("iteration", summary.Iteration),
+		zap.String("decision_id", summary.DecisionID))
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// SetCloseOnly 切换只平仓模式，开启后AI仍可平仓和调整止损，但不允许新开仓
+// POST /api/trading/close-only
+// 请求体 {"enabled": true}
+func (h *TradingHandler) SetCloseOnly(c echo.Context) error {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.Bind(&req); err != nil || req.Enabled == nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request body, expected {\"enabled\": true|false}",
+		})
+	}
+
+	h.agentService.SetCloseOnly(*req.Enabled)
+	h.logger.Info("close-only mode set via API", zap.Bool("close_only", *req.Enabled))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"close_only": *req.Enabled,
+	})
+}
+
+// GetDecisionPrompt 获取决策使用的完整提示词
+// GET /api/trading/decisions/:id/prompt
+// 系统提示词包含交易策略，与人工干预接口一样需要认证
+func (h *TradingHandler) GetDecisionPrompt(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	decision, err := h.agentService.GetDecisionPrompt(ctx, c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrDecisionNotFound), errors.Is(err, service.ErrDecisionPromptNotStored):
+			status = http.StatusNotFound
+		default:
+			h.logger.Error("failed to get decision prompt", zap.Error(err))
+		}
+		return c.JSON(status, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"decision_id":   decision.ID,
+		"iteration":     decision.Iteration,
+		"model":         decision.Model,
+		"executed_at":   decision.ExecutedAt,
+		"system_prompt": decision.SystemPrompt,
+		"user_prompt":   decision.UserPrompt,
+	})
+}
+
+// UpdatePositionStops 人工调整持仓的止损止盈
+// PUT /api/trading/positions/:id/stops
+// 请求体：{"stop_loss": 95000, "take_profit": 105000, "stop_type": "market", "reason": "..."}，未传的字段保持不变，take_profit 为0表示取消止盈单
+func (h *TradingHandler) UpdatePositionStops(c echo.Context) error {
+	var req service.ManualStopUpdate
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request body",
+		})
+	}
+
+	positionID := c.Param("id")
+	result, err := h.agentService.UpdatePositionStopsManually(c.Request().Context(), positionID, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"error": "position not found",
+			})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	h.logger.Info("position stops updated via API",
+		zap.String("position_id", positionID),
+		zap.String("symbol", result.Symbol),
+		zap.Float64("stop_loss", result.NewStopLoss),
+		zap.Float64("take_profit", result.NewTakeProfit))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"position_id":     positionID,
+		"symbol":          result.Symbol,
+		"old_stop_loss":   result.OldStopLoss,
+		"new_stop_loss":   result.NewStopLoss,
+		"old_take_profit": result.OldTakeProfit,
+		"new_take_profit": result.NewTakeProfit,
+		"stop_type":       result.StopType,
+		"reason":          result.Reason,
+	})
+}
+
+// CancelCurrentDecision 取消正在执行的AI决策
+// POST /api/trading/decisions/current/cancel
+func (h *TradingHandler) CancelCurrentDecision(c echo.Context) error {
+	decisionID, err := h.tradingLoop.CancelCurrentDecision()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	h.logger.Info("in-flight decision canceled via API", zap.String("decision_id", decisionID))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":     "decision canceled",
+		"decision_id": decisionID,
+	})
+}
+
+// ReplayDecision 反事实回放历史决策
+// POST /api/trading/decisions/:id/replay
+// 请求体可选 {"actions": [...]}，为空时回放决策日志中 AI 的开平仓操作
+func (h *TradingHandler) ReplayDecision(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req struct {
+		Actions []service.ReplayAction `json:"actions"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid request body",
+		})
+	}
+
+	replay, err := h.agentService.ReplayDecision(ctx, c.Param("id"), req.Actions)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrDecisionNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrNoReplayActions), errors.Is(err, service.ErrReplayTooOld):
+			status = http.StatusBadRequest
+		default:
+			h.logger.Error("failed to replay decision", zap.Error(err))
+		}
+		return c.JSON(status, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, replay)
+}
+
 // Restart 重启交易循环
 // POST /api/trading/restart
 func (h *TradingHandler) Restart(c echo.Context) error {
+	if err := h.tradingLoop.CheckLiveConfirmed(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
 	wasRunning := h.tradingLoop.IsRunning()
 
 	// 如果正在运行，先停止
@@ -342,23 +577,19 @@ func (h *TradingHandler) Restart(c echo.Context) error {
 }
 
 // GetLLMLogs 获取LLM通信日志
-// GET /api/trading/llm-logs?decision_id=xxx 或 ?limit=100&iteration=1
+// GET /api/trading/llm-logs?decision_id=xxx&page=1&page_size=20
+// 指定 decision_id 时按轮次升序返回该决策的日志，否则按执行时间倒序分页返回全部日志
 func (h *TradingHandler) GetLLMLogs(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	// 获取查询参数
-	decisionID := c.QueryParam("decision_id")
-	if decisionID == "" {
+	page, err := parsePagination(c, 20, maxHistoryPageSize)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "decision_id is required",
+			"error": err.Error(),
 		})
 	}
 
-	var logs interface{}
-	var err error
-
-	// 优先按决策ID查询
-	logs, err = h.agentService.GetLLMLogsByDecisionID(ctx, decisionID)
+	logs, total, err := h.agentService.GetLLMLogsPage(ctx, c.QueryParam("decision_id"), page)
 	if err != nil {
 		h.logger.Error("failed to get LLM logs", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
@@ -366,8 +597,51 @@ func (h *TradingHandler) GetLLMLogs(c echo.Context) error {
 		})
 	}
 
+	resp := pageFields(page, len(logs), total)
+	resp["logs"] = logs
+	return c.JSON(http.StatusOK, resp)
+}
+
+// 运行事件查询
+const (
+	defaultEventHistoryWindow = 24 * time.Hour // 未指定 from 时查询最近24小时
+	defaultEventHistoryLimit  = 500
+	maxEventHistoryLimit      = 5000
+)
+
+// GetEventHistory 查询交易循环的运行事件
+// GET /api/trading/events/history?from=2025-01-01T00:00:00Z&to=2025-01-02T00:00:00Z&limit=500
+//
+// from/to 为 RFC3339 时间，默认查询最近24小时，按发生时间升序返回。
+func (h *TradingHandler) GetEventHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	from, to, err := parseTimeRange(c, defaultEventHistoryWindow)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	limit, err := parseLimit(c, defaultEventHistoryLimit, maxEventHistoryLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	events, err := h.agentService.GetEventHistory(ctx, from, to, limit)
+	if err != nil {
+		h.logger.Error("failed to get event history", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"logs": logs,
+		"from":   from,
+		"to":     to,
+		"count":  len(events),
+		"events": events,
 	})
 }
 
@@ -383,10 +657,22 @@ func (h *TradingHandler) RegisterRoutes(g *echo.Group) {
 	trading.GET("/trades", h.GetTrades)
 	trading.GET("/stats", h.GetStats)
 	trading.GET("/equity-curve", h.GetEquityCurve)
+	trading.GET("/drawdown", h.GetDrawdown)
 	trading.GET("/llm-logs", h.GetLLMLogs)
+	trading.GET("/events/history", h.GetEventHistory)
 
 	// 控制接口
 	trading.POST("/start", h.Start)
 	trading.POST("/stop", h.Stop)
 	trading.POST("/restart", h.Restart)
 }
+
+// RegisterProtectedRoutes 注册需要认证的交易接口
+func (h *TradingHandler) RegisterProtectedRoutes(g *echo.Group) {
+	g.PUT("/positions/:id/stops", h.UpdatePositionStops)
+	g.POST("/run-once", h.RunOnce)
+	g.POST("/close-only", h.SetCloseOnly)
+	g.POST("/decisions/current/cancel", h.CancelCurrentDecision)
+	g.POST("/decisions/:id/replay", h.ReplayDecision)
+	g.GET("/decisions/:id/prompt", h.GetDecisionPrompt)
+}
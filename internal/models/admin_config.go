@@ -30,6 +30,13 @@ type TradingConfig struct {
 	MaxPositions       int                         `json:"max_positions"`
 	MaxLeverage        int                         `json:"max_leverage"`
 	MinLeverage        int                         `json:"min_leverage"`
+	MaxHoldingHours    int                         `json:"max_holding_hours"`                          // 最长持仓小时数，到期由系统强制平仓，0表示不限制，默认见 DefaultTradingConfig
+	SymbolBatchSize    int                         `json:"symbol_batch_size"`                          // 交易对轮换时每个周期分析的交易对数量，持仓交易对始终包含，0表示每个周期分析全部交易对
+	InitialBalance     float64                     `json:"initial_balance"`                            // 计算收益率的初始资金锚点(USDT)，0表示使用第一条账户历史
+	InitialBalanceAt   *time.Time                  `json:"initial_balance_at"`                         // 初始资金锚点时间，之后的出入金计入初始资金
+	Indicators         IndicatorParams             `gorm:"serializer:json" json:"indicators"`          // 全局技术指标周期，未设置的周期使用默认值
+	SymbolIndicators   map[string]IndicatorParams  `gorm:"serializer:json" json:"symbol_indicators"`   // 按交易对覆盖的技术指标周期
+	SymbolMaxLeverage  map[string]int              `gorm:"serializer:json" json:"symbol_max_leverage"` // 按交易对覆盖的最大杠杆，未配置的交易对使用 MaxLeverage
 	CreatedAt          time.Time                   `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt          time.Time                   `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -37,3 +44,64 @@ type TradingConfig struct {
 func (TradingConfig) TableName() string {
 	return "trading_config"
 }
+
+// IndicatorParams 技术指标周期参数，0表示使用上一级配置或默认值
+type IndicatorParams struct {
+	EMAFast int `json:"ema_fast"` // 快速EMA，默认20
+	EMASlow int `json:"ema_slow"` // 慢速EMA，默认50
+	RSIFast int `json:"rsi_fast"` // 短周期RSI，默认7
+	RSISlow int `json:"rsi_slow"` // 长周期RSI，默认14
+	ATRFast int `json:"atr_fast"` // 短周期ATR，默认3
+	ATRSlow int `json:"atr_slow"` // 长周期ATR，默认14
+	ADX     int `json:"adx"`      // ADX，默认14
+	BBands  int `json:"bbands"`   // 布林带，默认20
+}
+
+// DefaultIndicatorParams 默认技术指标周期
+var DefaultIndicatorParams = IndicatorParams{
+	EMAFast: 20,
+	EMASlow: 50,
+	RSIFast: 7,
+	RSISlow: 14,
+	ATRFast: 3,
+	ATRSlow: 14,
+	ADX:     14,
+	BBands:  20,
+}
+
+// Merge 用 override 中已设置的周期覆盖当前参数
+func (p IndicatorParams) Merge(override IndicatorParams) IndicatorParams {
+	pick := func(base, value int) int {
+		if value > 0 {
+			return value
+		}
+		return base
+	}
+	return IndicatorParams{
+		EMAFast: pick(p.EMAFast, override.EMAFast),
+		EMASlow: pick(p.EMASlow, override.EMASlow),
+		RSIFast: pick(p.RSIFast, override.RSIFast),
+		RSISlow: pick(p.RSISlow, override.RSISlow),
+		ATRFast: pick(p.ATRFast, override.ATRFast),
+		ATRSlow: pick(p.ATRSlow, override.ATRSlow),
+		ADX:     pick(p.ADX, override.ADX),
+		BBands:  pick(p.BBands, override.BBands),
+	}
+}
+
+// IndicatorParamsFor 返回交易对生效的指标周期：交易对覆盖 > 全局配置 > 默认值
+func (c *TradingConfig) IndicatorParamsFor(symbol string) IndicatorParams {
+	params := DefaultIndicatorParams.Merge(c.Indicators)
+	if override, ok := c.SymbolIndicators[symbol]; ok {
+		params = params.Merge(override)
+	}
+	return params
+}
+
+// MaxLeverageFor 返回交易对生效的最大杠杆，第二个返回值表示是否来自按交易对的配置
+func (c *TradingConfig) MaxLeverageFor(symbol string) (int, bool) {
+	if override, ok := c.SymbolMaxLeverage[symbol]; ok && override > 0 {
+		return override, true
+	}
+	return c.MaxLeverage, false
+}
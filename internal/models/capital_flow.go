@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 出入金记录来源
+const (
+	CapitalFlowSourceExchange = "exchange" // 从交易所资金流水同步
+	CapitalFlowSourceManual   = "manual"   // 管理员手动录入
+)
+
+// CapitalFlow 出入金记录，用于按净投入资金计算收益率
+type CapitalFlow struct {
+	ID         string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TranID     *int64         `gorm:"uniqueIndex" json:"tran_id,omitempty"` // 交易所流水ID，用于去重，手动录入时为空
+	Source     string         `gorm:"not null;index" json:"source"`         // 来源：exchange/manual
+	Amount     float64        `gorm:"not null" json:"amount"`               // 金额(USDT)，正数为入金，负数为出金
+	Asset      string         `json:"asset"`                                // 资产
+	Note       string         `json:"note"`                                 // 备注
+	OccurredAt time.Time      `gorm:"not null;index" json:"occurred_at"`    // 发生时间
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName 指定表名
+func (CapitalFlow) TableName() string {
+	return "capital_flows"
+}
@@ -8,18 +8,30 @@ import (
 
 // Decision AI决策记录
 type Decision struct {
-	ID               string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	Iteration        int            `gorm:"not null;index" json:"iteration"`   // 调用次数
-	AccountValue     float64        `json:"account_value"`                     // 决策时账户价值
-	PositionCount    int            `json:"position_count"`                    // 持仓数量
-	DecisionContent  string         `json:"decision_content"`                  // AI决策内容
-	PromptTokens     int            `json:"prompt_tokens"`                     // 提示词token数
-	CompletionTokens int            `json:"completion_tokens"`                 // 完成token数
-	Model            string         `json:"model"`                             // 使用的AI模型
-	ExecutedAt       time.Time      `gorm:"not null;index" json:"executed_at"` // 执行时间
-	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt        time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID               string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Iteration        int       `gorm:"not null;index" json:"iteration"`   // 调用次数
+	AccountValue     float64   `json:"account_value"`                     // 决策时账户价值
+	PositionCount    int       `json:"position_count"`                    // 持仓数量
+	DecisionContent  string    `json:"decision_content"`                  // AI决策内容
+	PromptTokens     int       `json:"prompt_tokens"`                     // 提示词token数
+	CompletionTokens int       `json:"completion_tokens"`                 // 完成token数
+	Model            string    `json:"model"`                             // 使用的AI模型
+	Temperature      *float64  `json:"temperature,omitempty"`             // 生效的采样温度，空表示模型默认值
+	TopP             *float64  `json:"top_p,omitempty"`                   // 生效的核采样概率，空表示模型默认值
+	Seed             *int64    `json:"seed,omitempty"`                    // 生效的随机种子，空表示未指定
+	Mode             string    `gorm:"index" json:"mode"`                 // 交易模式：paper/live
+	SystemPrompt     string    `json:"-"`                                 // 决策使用的完整系统提示词，开启 store_prompt 时保存
+	UserPrompt       string    `json:"-"`                                 // 决策使用的完整用户提示词，开启 store_prompt 时保存
+	ExecutedAt       time.Time `gorm:"not null;index" json:"executed_at"` // 执行时间
+
+	OutcomeEvaluatedAt *time.Time `gorm:"index" json:"outcome_evaluated_at,omitempty"` // 结果评估时间，空表示尚未评估
+	OutcomeScore       *float64   `json:"outcome_score,omitempty"`                     // 评估窗口结束时价格在决策隐含方向上的平均变动（%），没有开平仓的决策为空
+	OutcomeCorrect     *bool      `json:"outcome_correct,omitempty"`                   // 价格是否朝决策隐含的方向运动（OutcomeScore > 0）
+	OutcomePnl         float64    `json:"outcome_pnl"`                                 // 评估窗口内相关交易的已实现盈亏
+
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
 
 // TableName 指定表名
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// EventType 运行事件类型
+type EventType string
+
+const (
+	EventCycleStart      EventType = "cycle_start"      // 交易周期开始
+	EventCycleEnd        EventType = "cycle_end"        // 交易周期结束
+	EventMarketData      EventType = "market_data"      // 行情收集完成
+	EventDecisionCreated EventType = "decision_created" // 创建决策记录
+	EventToolCall        EventType = "tool_call"        // 工具调用
+	EventOrderPlaced     EventType = "order_placed"     // 挂出止损止盈单
+	EventTrade           EventType = "trade"            // 市价成交
+	EventError           EventType = "error"            // 周期执行出错
+)
+
+// Event 交易循环的运行事件，只追加不修改，用于事后还原系统做了什么
+type Event struct {
+	ID         string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Iteration  int       `gorm:"index" json:"iteration"`            // 所属交易周期，周期外的操作为0
+	DecisionID string    `gorm:"index" json:"decision_id"`          // 所属决策，决策外的操作为空
+	Type       EventType `gorm:"not null;index" json:"type"`        // 事件类型
+	Symbol     string    `json:"symbol"`                            // 相关交易对
+	Message    string    `json:"message"`                           // 事件说明
+	Data       string    `json:"data"`                              // 事件详情(JSON格式)
+	OccurredAt time.Time `gorm:"not null;index" json:"occurred_at"` // 发生时间
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Event) TableName() string {
+	return "events"
+}
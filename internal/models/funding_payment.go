@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FundingPayment 资金费结算记录
+type FundingPayment struct {
+	ID        string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	TranID    int64          `gorm:"not null;uniqueIndex" json:"tran_id"` // 交易所流水ID，用于去重
+	Symbol    string         `gorm:"not null;index" json:"symbol"`        // 交易对
+	Amount    float64        `gorm:"not null" json:"amount"`              // 金额(USDT)，正数为收到，负数为支付
+	Asset     string         `json:"asset"`                               // 结算资产
+	PaidAt    time.Time      `gorm:"not null;index" json:"paid_at"`       // 结算时间
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName 指定表名
+func (FundingPayment) TableName() string {
+	return "funding_payments"
+}
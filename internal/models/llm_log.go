@@ -25,6 +25,7 @@ type LLMLog struct {
 	FinishReason     string         `json:"finish_reason"`                     // 结束原因
 	Duration         int64          `json:"duration"`                          // 请求耗时(毫秒)
 	Error            string         `json:"error"`                             // 错误信息(如果有)
+	RawResponse      string         `json:"raw_response,omitempty"`            // 模型服务返回的原始响应(JSON格式)，开启 store_raw_response 时记录
 	ExecutedAt       time.Time      `gorm:"not null;index" json:"executed_at"` // 执行时间
 	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt        time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MarketRegimeRecord 每个交易周期按交易对记录的市场状态，用于按市场状态分析交易表现
+type MarketRegimeRecord struct {
+	ID         string    `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Iteration  int       `gorm:"index" json:"iteration"`            // 交易周期数
+	Symbol     string    `gorm:"not null;index" json:"symbol"`      // 交易对
+	Regime     string    `gorm:"not null;index" json:"regime"`      // trending/ranging/uncertain
+	ADX        float64   `json:"adx"`                               // 判断市场状态所用的1小时ADX
+	Price      float64   `json:"price"`                             // 记录时的价格
+	RecordedAt time.Time `gorm:"not null;index" json:"recorded_at"` // 记录时间
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (MarketRegimeRecord) TableName() string {
+	return "market_regimes"
+}
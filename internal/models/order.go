@@ -32,10 +32,13 @@ type Order struct {
 	PositionSide string         `gorm:"not null" json:"position_side"`           // 持仓方向 (long/short)
 	OrderType    OrderType      `gorm:"not null" json:"order_type"`              // 订单类型 (stop_loss/take_profit)
 	TriggerPrice float64        `gorm:"not null" json:"trigger_price"`           // 触发价格
+	Execution    string         `gorm:"default:'market'" json:"execution"`       // 止损单触发后的执行方式 (market/limit)
+	LimitPrice   float64        `json:"limit_price,omitempty"`                   // 限价止损的限价，市价止损为0
 	Quantity     float64        `gorm:"not null" json:"quantity"`                // 订单数量
 	ExchangeID   string         `json:"exchange_id"`                             // 交易所订单ID
 	Status       OrderStatus    `gorm:"not null;default:'active'" json:"status"` // 订单状态
 	Reason       string         `json:"reason"`                                  // 创建/更新原因
+	LadderLevel  int            `json:"ladder_level,omitempty"`                  // 分批止盈的档位（从1开始），0表示普通止损/止盈单
 	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	TriggeredAt  *time.Time     `json:"triggered_at,omitempty"` // 触发时间
@@ -9,26 +9,32 @@ import (
 
 // Position 持仓信息
 type Position struct {
-	ID               string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	Symbol           string         `gorm:"not null;index" json:"symbol"`      // 交易对,如 BTCUSDT
-	Side             string         `gorm:"not null" json:"side"`              // long/short
-	Quantity         float64        `gorm:"not null" json:"quantity"`          // 持仓数量
-	EntryPrice       float64        `gorm:"not null" json:"entry_price"`       // 开仓价格
-	CurrentPrice     float64        `json:"current_price"`                     // 当前价格
-	LiquidationPrice float64        `json:"liquidation_price"`                 // 强平价格
-	UnrealizedPnl    float64        `json:"unrealized_pnl"`                    // 未实现盈亏(USDT)
-	Leverage         int            `gorm:"not null" json:"leverage"`          // 杠杆倍数
-	Margin           float64        `json:"margin"`                            // 保证金(USDT)
-	OrderID          string         `json:"order_id"`                          // 开仓订单ID
-	EntryReason      string         `json:"entry_reason"`                      // 开仓理由
-	ExitPlan         string         `json:"exit_plan"`                         // 退出条件/计划
-	StopLoss         float64        `json:"stop_loss"`                         // 止损价格
-	TakeProfit       float64        `json:"take_profit"`                       // 止盈价格
-	PeakPnlPercent   float64        `gorm:"default:0" json:"peak_pnl_percent"` // 历史最高盈亏百分比
-	OpenedAt         time.Time      `gorm:"not null" json:"opened_at"`         // 开仓时间
-	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt        time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                   string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Symbol               string         `gorm:"not null;index" json:"symbol"`      // 交易对,如 BTCUSDT
+	Side                 string         `gorm:"not null" json:"side"`              // long/short
+	Quantity             float64        `gorm:"not null" json:"quantity"`          // 持仓数量
+	EntryPrice           float64        `gorm:"not null" json:"entry_price"`       // 开仓价格
+	CurrentPrice         float64        `json:"current_price"`                     // 当前价格
+	LiquidationPrice     float64        `json:"liquidation_price"`                 // 强平价格
+	UnrealizedPnl        float64        `json:"unrealized_pnl"`                    // 未实现盈亏(USDT)
+	Leverage             int            `gorm:"not null" json:"leverage"`          // 杠杆倍数
+	Margin               float64        `json:"margin"`                            // 保证金(USDT)
+	OrderID              string         `json:"order_id"`                          // 开仓订单ID
+	EntryReason          string         `json:"entry_reason"`                      // 开仓理由
+	ExitPlan             string         `json:"exit_plan"`                         // 退出条件/计划
+	StopLoss             float64        `json:"stop_loss"`                         // 止损价格
+	TakeProfit           float64        `json:"take_profit"`                       // 止盈价格
+	InvalidationPrice    float64        `json:"invalidation_price"`                // 论点失效价格（区别于保护性止损）
+	Confidence           int            `json:"confidence"`                        // 开仓信心（1-10），0表示未记录
+	PlannedRR            float64        `json:"planned_rr"`                        // 开仓时计划的盈亏比（止盈距离/止损距离），未设置止盈时为0
+	RiskPerUnit          float64        `json:"risk_per_unit"`                     // 开仓时每单位数量承担的初始风险 |开仓价-止损价|，用于计算平仓的R倍数，0表示未记录
+	PeakPnlPercent       float64        `gorm:"default:0" json:"peak_pnl_percent"` // 历史最高盈亏百分比
+	DelistedStatus       string         `json:"delisted_status,omitempty"`         // 交易对下架或暂停交易时的状态，非空表示仓位已冻结、无法正常管理
+	EntrySlippagePercent float64        `json:"entry_slippage_percent"`            // 开仓滑点超过 max_fill_slippage_percent 时记录的滑点（%），非0表示需要复核执行质量
+	OpenedAt             time.Time      `gorm:"not null" json:"opened_at"`         // 开仓时间
+	CreatedAt            time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
 
 // TableName 指定表名
@@ -36,6 +42,30 @@ func (*Position) TableName() string {
 	return "positions"
 }
 
+// IsFrozen 交易对已下架或暂停交易，仓位无法获取行情和下单
+func (p *Position) IsFrozen() bool {
+	return p.DelistedStatus != ""
+}
+
+// Notional 按当前价格计算的持仓名义价值(USDT)
+func (p *Position) Notional() float64 {
+	return p.CurrentPrice * p.Quantity
+}
+
+// StopRisk 价格从当前价格运行到止损价时的亏损(USDT)，未设置止损时第二个返回值为 false
+//
+// 当前价格已越过止损价（止损单即将触发）时按0计算。
+func (p *Position) StopRisk() (float64, bool) {
+	if p.StopLoss <= 0 {
+		return 0, false
+	}
+	distance := p.CurrentPrice - p.StopLoss
+	if p.Side == "short" {
+		distance = -distance
+	}
+	return max(distance, 0) * p.Quantity, true
+}
+
 // CalculatePnlPercent 计算盈亏百分比(考虑杠杆)
 func (p *Position) CalculatePnlPercent() float64 {
 	if p.EntryPrice == 0 {
@@ -53,8 +83,66 @@ func (p *Position) CalculatePnlPercent() float64 {
 	return priceChange * float64(p.Leverage)
 }
 
+// IsThesisInvalidated 当前价格是否已越过开仓时承诺的论点失效价格
+func (p *Position) IsThesisInvalidated() bool {
+	if p.InvalidationPrice <= 0 || p.CurrentPrice <= 0 {
+		return false
+	}
+	if p.Side == "short" {
+		return p.CurrentPrice >= p.InvalidationPrice
+	}
+	return p.CurrentPrice <= p.InvalidationPrice
+}
+
+// PlannedRewardRisk 按开仓价、止损价和止盈价计算计划盈亏比，缺少止损或止盈时返回0
+func PlannedRewardRisk(side string, entry, stopLoss, takeProfit float64) float64 {
+	if entry <= 0 || stopLoss <= 0 || takeProfit <= 0 {
+		return 0
+	}
+	risk := entry - stopLoss
+	reward := takeProfit - entry
+	if side == "short" {
+		risk, reward = -risk, -reward
+	}
+	if risk <= 0 || reward <= 0 {
+		return 0
+	}
+	return reward / risk
+}
+
 func (p *Position) CalculateHoldingStr() string {
 	holding := time.Since(p.OpenedAt)
 	holdingStr, _ := strings.CutSuffix(holding.Round(time.Minute).String(), "0s")
 	return holdingStr
 }
+
+// HoldingDeadline 按最长持仓小时数计算的强制平仓时间，maxHoldingHours<=0 表示不限制
+func (p *Position) HoldingDeadline(maxHoldingHours int) (time.Time, bool) {
+	if maxHoldingHours <= 0 || p.OpenedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return p.OpenedAt.Add(time.Duration(maxHoldingHours) * time.Hour), true
+}
+
+// IsHoldingExpired 持仓时间是否已超过最长持仓小时数
+func (p *Position) IsHoldingExpired(maxHoldingHours int, now time.Time) bool {
+	deadline, ok := p.HoldingDeadline(maxHoldingHours)
+	return ok && !now.Before(deadline)
+}
+
+// RemainingHoldingStr 距强制平仓的剩余时间，已到期时返回 0m
+func (p *Position) RemainingHoldingStr(maxHoldingHours int) string {
+	deadline, ok := p.HoldingDeadline(maxHoldingHours)
+	if !ok {
+		return ""
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	remainingStr, _ := strings.CutSuffix(remaining.Round(time.Minute).String(), "0s")
+	if remainingStr == "" {
+		return "0m"
+	}
+	return remainingStr
+}
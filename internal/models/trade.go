@@ -8,25 +8,115 @@ import (
 
 // Trade 交易记录
 type Trade struct {
-	ID         string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	Symbol     string         `gorm:"not null;index" json:"symbol"`      // 交易对
-	Type       string         `gorm:"not null" json:"type"`              // open/close
-	Side       string         `gorm:"not null" json:"side"`              // long/short
-	Price      float64        `gorm:"not null" json:"price"`             // 成交价格
-	Quantity   float64        `gorm:"not null" json:"quantity"`          // 成交数量
-	Leverage   int            `json:"leverage"`                          // 杠杆倍数
-	Fee        float64        `json:"fee"`                               // 手续费
-	Pnl        float64        `json:"pnl"`                               // 平仓盈亏(仅平仓时有值)
-	Reason     string         `json:"reason"`                            // 开仓/平仓原因
-	OrderID    string         `gorm:"index" json:"order_id"`             // 订单ID
-	PositionID string         `gorm:"index" json:"position_id"`          // 关联的持仓ID
-	ExecutedAt time.Time      `gorm:"not null;index" json:"executed_at"` // 执行时间
-	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID              string          `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Symbol          string          `gorm:"not null;index" json:"symbol"`      // 交易对
+	Type            string          `gorm:"not null" json:"type"`              // open/close
+	Side            string          `gorm:"not null" json:"side"`              // long/short
+	Price           float64         `gorm:"not null" json:"price"`             // 成交价格
+	Quantity        float64         `gorm:"not null" json:"quantity"`          // 成交数量
+	Leverage        int             `json:"leverage"`                          // 杠杆倍数
+	Fee             float64         `json:"fee"`                               // 手续费
+	Pnl             float64         `json:"pnl"`                               // 平仓盈亏(仅平仓时有值)
+	Reason          string          `json:"reason"`                            // 开仓/平仓原因
+	ReasonCode      CloseReasonCode `gorm:"index" json:"reason_code"`          // 平仓原因分类(仅平仓时有值)
+	Confidence      int             `json:"confidence"`                        // 开仓信心（1-10），平仓交易沿用对应持仓的信心，0表示未记录
+	PlannedRR       float64         `json:"planned_rr"`                        // 开仓时计划的盈亏比，平仓交易沿用对应持仓，0表示未设置止盈
+	InitialRisk     float64         `json:"initial_risk"`                      // 平仓数量对应的初始风险（USDT），0表示未记录，仅平仓时有值
+	RMultiple       float64         `json:"r_multiple"`                        // 已实现R倍数 = 平仓盈亏 / 初始风险，仅 InitialRisk>0 时有效
+	ExpectedPrice   float64         `json:"expected_price"`                    // 下单前的参考价格（市价单为下单前的最新价，止损止盈单为触发价），0表示未记录
+	SlippagePercent float64         `json:"slippage_percent"`                  // 成交价相对参考价的滑点（%），正数表示不利，仅 ExpectedPrice>0 时有效
+	OrderID         string          `gorm:"index" json:"order_id"`             // 订单ID
+	PositionID      string          `gorm:"index" json:"position_id"`          // 关联的持仓ID
+	Mode            string          `gorm:"index" json:"mode"`                 // 交易模式：paper/live
+	ExecutedAt      time.Time       `gorm:"not null;index" json:"executed_at"` // 执行时间
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt       gorm.DeletedAt  `gorm:"index" json:"deleted_at,omitempty"`
 }
 
 // TableName 指定表名
 func (Trade) TableName() string {
 	return "trades"
 }
+
+// ApplyPositionRisk 按持仓记录的计划盈亏比和每单位初始风险计算平仓交易的R倍数，需在设置 Quantity 和 Pnl 之后调用
+func (t *Trade) ApplyPositionRisk(position *Position) {
+	t.PlannedRR = position.PlannedRR
+	if position.RiskPerUnit <= 0 || t.Quantity <= 0 {
+		return
+	}
+	t.InitialRisk = position.RiskPerUnit * t.Quantity
+	t.RMultiple = t.Pnl / t.InitialRisk
+}
+
+// IsBuy 交易是否为买入：开多和平空为买入，开空和平多为卖出
+func (t *Trade) IsBuy() bool {
+	return (t.Type == "open") == (t.Side == "long")
+}
+
+// ApplySlippage 记录参考价格并计算成交滑点，需在设置 Type、Side 和 Price 之后调用
+func (t *Trade) ApplySlippage(expectedPrice float64) {
+	if expectedPrice <= 0 || t.Price <= 0 {
+		return
+	}
+	t.ExpectedPrice = expectedPrice
+	t.SlippagePercent = SlippagePercent(t.IsBuy(), expectedPrice, t.Price)
+}
+
+// SlippageCost 滑点造成的成本（USDT），正数表示不利
+func (t *Trade) SlippageCost() float64 {
+	if t.ExpectedPrice <= 0 {
+		return 0
+	}
+	cost := (t.Price - t.ExpectedPrice) * t.Quantity
+	if !t.IsBuy() {
+		cost = -cost
+	}
+	return cost
+}
+
+// SlippagePercent 成交价相对参考价的滑点百分比，买入成交价高于参考价或卖出成交价低于参考价为正（不利）
+func SlippagePercent(buy bool, expectedPrice, fillPrice float64) float64 {
+	if expectedPrice <= 0 || fillPrice <= 0 {
+		return 0
+	}
+	slippage := (fillPrice - expectedPrice) / expectedPrice * 100
+	if !buy {
+		slippage = -slippage
+	}
+	return slippage
+}
+
+// CloseReasonCode 平仓原因分类，与自由文本的平仓理由互补，便于统计各类退出的占比与盈亏
+type CloseReasonCode string
+
+const (
+	CloseReasonStopLoss          CloseReasonCode = "stop_loss"          // 止损
+	CloseReasonTakeProfit        CloseReasonCode = "take_profit"        // 止盈
+	CloseReasonStructureBreak    CloseReasonCode = "structure_break"    // 结构破坏
+	CloseReasonTimeExit          CloseReasonCode = "time_exit"          // 时间退出
+	CloseReasonThesisInvalidated CloseReasonCode = "thesis_invalidated" // 论点失效
+	CloseReasonRiskManagement    CloseReasonCode = "risk_management"    // 风险管理
+	CloseReasonOther             CloseReasonCode = "other"              // 其他
+)
+
+// CloseReasonCodes 全部平仓原因分类
+var CloseReasonCodes = []CloseReasonCode{
+	CloseReasonStopLoss,
+	CloseReasonTakeProfit,
+	CloseReasonStructureBreak,
+	CloseReasonTimeExit,
+	CloseReasonThesisInvalidated,
+	CloseReasonRiskManagement,
+	CloseReasonOther,
+}
+
+// Valid 是否为已定义的平仓原因分类
+func (c CloseReasonCode) Valid() bool {
+	for _, code := range CloseReasonCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
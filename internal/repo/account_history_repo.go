@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/go-orz/orz"
@@ -27,15 +28,27 @@ func (r AccountHistoryRepo) FindInitialBalance(ctx context.Context) (m models.Ac
 	return m, err
 }
 
-// FindPeakBalance 获取峰值余额记录
-func (r AccountHistoryRepo) FindPeakBalance(ctx context.Context) (m models.AccountHistory, err error) {
+// FindLatest 获取最近一条账户历史记录
+func (r AccountHistoryRepo) FindLatest(ctx context.Context) (m models.AccountHistory, err error) {
 	db := r.GetDB(ctx)
 	err = db.Table(r.GetTableName()).
-		Order("peak_balance DESC").
+		Order("recorded_at DESC").
 		First(&m).Error
 	return m, err
 }
 
+// FindBalanceSeries 获取全部账户历史的净值和记录时间（按时间排序），用于计算峰值
+func (r AccountHistoryRepo) FindBalanceSeries(ctx context.Context) ([]models.AccountHistory, error) {
+	var histories []models.AccountHistory
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Select("total_balance", "recorded_at").
+		Where("deleted_at IS NULL").
+		Order("recorded_at ASC").
+		Find(&histories).Error
+	return histories, err
+}
+
 // FindAllOrderByRecordedAt 获取所有账户历史记录（按时间排序）
 func (r AccountHistoryRepo) FindAllOrderByRecordedAt(ctx context.Context) ([]models.AccountHistory, error) {
 	var histories []models.AccountHistory
@@ -45,3 +58,36 @@ func (r AccountHistoryRepo) FindAllOrderByRecordedAt(ctx context.Context) ([]mod
 		Find(&histories).Error
 	return histories, err
 }
+
+// FindRecordedSinceOrderByRecordedAt 获取记录时间不早于 since 的账户历史（按时间排序）
+func (r AccountHistoryRepo) FindRecordedSinceOrderByRecordedAt(ctx context.Context, since time.Time) ([]models.AccountHistory, error) {
+	var histories []models.AccountHistory
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("recorded_at >= ?", since).
+		Order("recorded_at ASC").
+		Find(&histories).Error
+	return histories, err
+}
+
+// FindRecordedBefore 获取记录时间早于 cutoff 的账户历史（按时间排序），只查询ID、净值和时间
+func (r AccountHistoryRepo) FindRecordedBefore(ctx context.Context, cutoff time.Time) ([]models.AccountHistory, error) {
+	var histories []models.AccountHistory
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Select("id", "total_balance", "recorded_at").
+		Where("recorded_at < ?", cutoff).
+		Order("recorded_at ASC").
+		Find(&histories).Error
+	return histories, err
+}
+
+// HardDeleteByIdIn 按ID物理删除账户历史，返回删除条数
+func (r AccountHistoryRepo) HardDeleteByIdIn(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	db := r.GetDB(ctx)
+	result := db.Unscoped().Where("id IN ?", ids).Delete(&models.AccountHistory{})
+	return result.RowsAffected, result.Error
+}
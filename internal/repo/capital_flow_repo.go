@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func NewCapitalFlowRepo(db *gorm.DB) *CapitalFlowRepo {
+	return &CapitalFlowRepo{
+		Repository: orz.NewRepository[models.CapitalFlow, string](db),
+	}
+}
+
+type CapitalFlowRepo struct {
+	orz.Repository[models.CapitalFlow, string]
+}
+
+// CreateIgnoreDuplicates 批量写入出入金记录，已存在的流水ID会被忽略
+func (r CapitalFlowRepo) CreateIgnoreDuplicates(ctx context.Context, flows []models.CapitalFlow) error {
+	if len(flows) == 0 {
+		return nil
+	}
+	db := r.GetDB(ctx)
+	return db.Table(r.GetTableName()).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "tran_id"}}, DoNothing: true}).
+		Create(&flows).Error
+}
+
+// FindLatestOccurredAt 获取指定来源最近一条记录的发生时间，没有记录时返回零值
+func (r CapitalFlowRepo) FindLatestOccurredAt(ctx context.Context, source string) (time.Time, error) {
+	var flow models.CapitalFlow
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("source = ? AND deleted_at IS NULL", source).
+		Order("occurred_at DESC").
+		First(&flow).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return flow.OccurredAt, nil
+}
+
+// SumAmountSince 统计指定时间之后的净入金（正数为净入金，负数为净出金），since 为零值时统计全部
+func (r CapitalFlowRepo) SumAmountSince(ctx context.Context, since time.Time) (float64, error) {
+	var total float64
+	db := r.GetDB(ctx).Table(r.GetTableName()).
+		Where("deleted_at IS NULL")
+	if !since.IsZero() {
+		db = db.Where("occurred_at > ?", since)
+	}
+	err := db.Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// FindAllOrderByOccurredAt 获取全部出入金记录（按发生时间倒序）
+func (r CapitalFlowRepo) FindAllOrderByOccurredAt(ctx context.Context) ([]models.CapitalFlow, error) {
+	var flows []models.CapitalFlow
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("deleted_at IS NULL").
+		Order("occurred_at DESC").
+		Find(&flows).Error
+	return flows, err
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/go-orz/orz"
@@ -23,13 +24,17 @@ type DecisionRepo struct {
 func (r DecisionRepo) FindRecentDecisions(ctx context.Context, limit int) ([]models.Decision, error) {
 	var decisions []models.Decision
 	db := r.GetDB(ctx)
-	err := db.Table(r.GetTableName()).
-		Order("executed_at DESC").
+	err := newestFirst(db.Table(r.GetTableName())).
 		Limit(limit).
 		Find(&decisions).Error
 	return decisions, err
 }
 
+// FindDecisionsPage 按执行时间倒序分页查询决策记录，返回当前页和总数
+func (r DecisionRepo) FindDecisionsPage(ctx context.Context, page Pagination) ([]models.Decision, int64, error) {
+	return findPage[models.Decision](r.GetDB(ctx), page, newestFirst)
+}
+
 // FindLatestIteration 获取最新的迭代编号
 func (r DecisionRepo) FindLatestIteration(ctx context.Context) (int, error) {
 	var decision models.Decision
@@ -45,3 +50,107 @@ func (r DecisionRepo) FindLatestIteration(ctx context.Context) (int, error) {
 	}
 	return decision.Iteration, nil
 }
+
+// UpdatePrompt 保存决策使用的系统提示词和用户提示词
+func (r DecisionRepo) UpdatePrompt(ctx context.Context, id, systemPrompt, userPrompt string) error {
+	return r.GetDB(ctx).Model(&models.Decision{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"system_prompt": systemPrompt,
+			"user_prompt":   userPrompt,
+		}).Error
+}
+
+// DeleteExecutedBefore 物理删除最多 limit 条执行时间早于 cutoff 的决策，返回删除条数
+func (r DecisionRepo) DeleteExecutedBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var ids []string
+	db := r.GetDB(ctx)
+	if err := db.Table(r.GetTableName()).
+		Where("executed_at < ?", cutoff).
+		Order("executed_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	result := db.Unscoped().Where("id IN ?", ids).Delete(&models.Decision{})
+	return result.RowsAffected, result.Error
+}
+
+// FindPendingOutcomes 按执行时间升序获取最多 limit 条执行时间不晚于 before 且尚未评估结果的决策
+func (r DecisionRepo) FindPendingOutcomes(ctx context.Context, before time.Time, limit int) ([]models.Decision, error) {
+	var decisions []models.Decision
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("outcome_evaluated_at IS NULL AND executed_at <= ?", before).
+		Order("executed_at ASC").
+		Limit(limit).
+		Find(&decisions).Error
+	return decisions, err
+}
+
+// DecisionAccuracy 决策结果评估的汇总
+type DecisionAccuracy struct {
+	Evaluated       int                   `json:"evaluated"`        // 有开平仓、已评估方向的决策数
+	Correct         int                   `json:"correct"`          // 价格朝决策方向运动的决策数
+	AccuracyPercent float64               `json:"accuracy_percent"` // 决策准确率(%)
+	AvgScore        float64               `json:"avg_score"`        // 平均方向变动(%)
+	TotalPnl        float64               `json:"total_pnl"`        // 评估窗口内相关交易的已实现盈亏合计
+	Daily           []DecisionAccuracyDay `json:"daily"`            // 按决策执行日期分组，日期升序
+}
+
+// DecisionAccuracyDay 单日的决策结果评估
+type DecisionAccuracyDay struct {
+	Date            string  `json:"date"` // 2006-01-02
+	Evaluated       int     `json:"evaluated"`
+	Correct         int     `json:"correct"`
+	AccuracyPercent float64 `json:"accuracy_percent"`
+	AvgScore        float64 `json:"avg_score"`
+	TotalPnl        float64 `json:"total_pnl"`
+}
+
+// GetDecisionAccuracy 汇总 since 之后执行、已评估方向的决策结果
+func (r DecisionRepo) GetDecisionAccuracy(ctx context.Context, since time.Time) (*DecisionAccuracy, error) {
+	var decisions []models.Decision
+	db := r.GetDB(ctx)
+	if err := db.Table(r.GetTableName()).
+		Select("executed_at", "outcome_score", "outcome_correct", "outcome_pnl").
+		Where("outcome_score IS NOT NULL AND executed_at >= ?", since).
+		Order("executed_at ASC").
+		Find(&decisions).Error; err != nil {
+		return nil, err
+	}
+
+	accuracy := &DecisionAccuracy{Daily: []DecisionAccuracyDay{}}
+	var totalScore float64
+	dayScores := make(map[string]float64)
+	for _, decision := range decisions {
+		date := decision.ExecutedAt.Format("2006-01-02")
+		if n := len(accuracy.Daily); n == 0 || accuracy.Daily[n-1].Date != date {
+			accuracy.Daily = append(accuracy.Daily, DecisionAccuracyDay{Date: date})
+		}
+		day := &accuracy.Daily[len(accuracy.Daily)-1]
+		correct := decision.OutcomeCorrect != nil && *decision.OutcomeCorrect
+
+		accuracy.Evaluated++
+		day.Evaluated++
+		if correct {
+			accuracy.Correct++
+			day.Correct++
+		}
+		totalScore += *decision.OutcomeScore
+		dayScores[date] += *decision.OutcomeScore
+		accuracy.TotalPnl += decision.OutcomePnl
+		day.TotalPnl += decision.OutcomePnl
+	}
+
+	if accuracy.Evaluated > 0 {
+		accuracy.AccuracyPercent = float64(accuracy.Correct) / float64(accuracy.Evaluated) * 100
+		accuracy.AvgScore = totalScore / float64(accuracy.Evaluated)
+	}
+	for i := range accuracy.Daily {
+		day := &accuracy.Daily[i]
+		day.AccuracyPercent = float64(day.Correct) / float64(day.Evaluated) * 100
+		day.AvgScore = dayScores[day.Date] / float64(day.Evaluated)
+	}
+	return accuracy, nil
+}
@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+func NewEventRepo(db *gorm.DB) *EventRepo {
+	return &EventRepo{
+		Repository: orz.NewRepository[models.Event, string](db),
+	}
+}
+
+type EventRepo struct {
+	orz.Repository[models.Event, string]
+}
+
+// FindByDecision 按发生时间升序查询决策内指定类型的事件
+func (r EventRepo) FindByDecision(ctx context.Context, decisionID string, eventType models.EventType) ([]models.Event, error) {
+	var events []models.Event
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("decision_id = ? AND type = ?", decisionID, eventType).
+		Order("occurred_at ASC, id ASC").
+		Find(&events).Error
+	return events, err
+}
+
+// FindBetween 按发生时间升序查询 [from, to] 区间内最多 limit 条事件
+func (r EventRepo) FindBetween(ctx context.Context, from, to time.Time, limit int) ([]models.Event, error) {
+	var events []models.Event
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("occurred_at >= ? AND occurred_at <= ?", from, to).
+		Order("occurred_at ASC, id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func NewFundingPaymentRepo(db *gorm.DB) *FundingPaymentRepo {
+	return &FundingPaymentRepo{
+		Repository: orz.NewRepository[models.FundingPayment, string](db),
+	}
+}
+
+type FundingPaymentRepo struct {
+	orz.Repository[models.FundingPayment, string]
+}
+
+// CreateIgnoreDuplicates 批量写入资金费记录，已存在的流水ID会被忽略
+func (r FundingPaymentRepo) CreateIgnoreDuplicates(ctx context.Context, payments []models.FundingPayment) error {
+	if len(payments) == 0 {
+		return nil
+	}
+	db := r.GetDB(ctx)
+	return db.Table(r.GetTableName()).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "tran_id"}}, DoNothing: true}).
+		Create(&payments).Error
+}
+
+// FindLatestPaidAt 获取最近一次资金费结算时间，没有记录时返回零值
+func (r FundingPaymentRepo) FindLatestPaidAt(ctx context.Context) (time.Time, error) {
+	var payment models.FundingPayment
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Order("paid_at DESC").
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return payment.PaidAt, nil
+}
+
+// SumBySymbolSince 统计交易对自指定时间以来的资金费（正数为净收入，负数为净支出）
+func (r FundingPaymentRepo) SumBySymbolSince(ctx context.Context, symbol string, since time.Time) (float64, error) {
+	var total float64
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("deleted_at IS NULL AND symbol = ? AND paid_at >= ?", symbol, since).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// SumAmount 统计累计资金费（正数为净收入，负数为净支出）
+func (r FundingPaymentRepo) SumAmount(ctx context.Context) (float64, error) {
+	var total float64
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("deleted_at IS NULL").
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
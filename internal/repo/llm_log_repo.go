@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/go-orz/orz"
@@ -44,13 +45,40 @@ func (r LLMLogRepo) FindByIteration(ctx context.Context, iteration int) ([]model
 func (r LLMLogRepo) FindRecentLogs(ctx context.Context, limit int) ([]models.LLMLog, error) {
 	var logs []models.LLMLog
 	db := r.GetDB(ctx)
-	err := db.Table(r.GetTableName()).
-		Order("executed_at DESC").
+	err := newestFirst(db.Table(r.GetTableName())).
 		Limit(limit).
 		Find(&logs).Error
 	return logs, err
 }
 
+// FindLogsPage 分页查询日志，返回当前页和总数
+//
+// 指定 decisionID 时只查询该决策的日志，按轮次升序排列便于按对话顺序阅读；否则按执行时间倒序。
+func (r LLMLogRepo) FindLogsPage(ctx context.Context, decisionID string, page Pagination) ([]models.LLMLog, int64, error) {
+	db := r.GetDB(ctx)
+	if decisionID == "" {
+		return findPage[models.LLMLog](db, page, newestFirst)
+	}
+	return findPage[models.LLMLog](db.Where("decision_id = ?", decisionID), page, func(db *gorm.DB) *gorm.DB {
+		return db.Order("round_number ASC").Order("id ASC")
+	})
+}
+
+// DeleteExecutedBefore 物理删除最多 limit 条执行时间早于 cutoff 的日志，返回删除条数
+func (r LLMLogRepo) DeleteExecutedBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var ids []string
+	db := r.GetDB(ctx)
+	if err := db.Table(r.GetTableName()).
+		Where("executed_at < ?", cutoff).
+		Order("executed_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	result := db.Unscoped().Where("id IN ?", ids).Delete(&models.LLMLog{})
+	return result.RowsAffected, result.Error
+}
+
 // CountByDecisionID 统计某个决策的日志数量
 func (r LLMLogRepo) CountByDecisionID(ctx context.Context, decisionID string) (int64, error) {
 	var count int64
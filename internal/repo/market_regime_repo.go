@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+func NewMarketRegimeRepo(db *gorm.DB) *MarketRegimeRepo {
+	return &MarketRegimeRepo{
+		Repository: orz.NewRepository[models.MarketRegimeRecord, string](db),
+	}
+}
+
+type MarketRegimeRepo struct {
+	orz.Repository[models.MarketRegimeRecord, string]
+}
+
+// FindTimeline 按记录时间升序查询 [from, to] 区间内最多 limit 条市场状态，symbol 为空时查询全部交易对
+func (r MarketRegimeRepo) FindTimeline(ctx context.Context, symbol string, from, to time.Time, limit int) ([]models.MarketRegimeRecord, error) {
+	var records []models.MarketRegimeRecord
+	db := r.GetDB(ctx).Table(r.GetTableName()).
+		Where("recorded_at >= ? AND recorded_at <= ?", from, to)
+	if symbol != "" {
+		db = db.Where("symbol = ?", symbol)
+	}
+	err := db.Order("recorded_at ASC, symbol ASC").
+		Limit(limit).
+		Find(&records).Error
+	return records, err
+}
+
+// DeleteRecordedBefore 删除最多 limit 条记录时间早于 cutoff 的市场状态，返回删除条数
+func (r MarketRegimeRepo) DeleteRecordedBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var ids []string
+	db := r.GetDB(ctx)
+	if err := db.Table(r.GetTableName()).
+		Where("recorded_at < ?", cutoff).
+		Order("recorded_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	result := db.Where("id IN ?", ids).Delete(&models.MarketRegimeRecord{})
+	return result.RowsAffected, result.Error
+}
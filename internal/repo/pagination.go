@@ -0,0 +1,43 @@
+package repo
+
+import "gorm.io/gorm"
+
+// Pagination 分页参数，Page 从1开始
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+// Offset 当前页之前的记录数
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// NextPage 还有下一页时返回下一页页码，否则返回0
+func (p Pagination) NextPage(total int64) int {
+	if int64(p.Page)*int64(p.PageSize) < total {
+		return p.Page + 1
+	}
+	return 0
+}
+
+// newestFirst 按执行时间倒序排列，执行时间相同时按ID倒序，保证翻页时顺序稳定
+func newestFirst(db *gorm.DB) *gorm.DB {
+	return db.Order("executed_at DESC").Order("id DESC")
+}
+
+// findPage 统计 query 匹配的记录总数，并按 order 排序查询指定页
+func findPage[T any](query *gorm.DB, page Pagination, order func(*gorm.DB) *gorm.DB) ([]T, int64, error) {
+	query = query.Model(new(T))
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]T, 0, page.PageSize)
+	err := order(query.Session(&gorm.Session{})).
+		Offset(page.Offset()).
+		Limit(page.PageSize).
+		Find(&items).Error
+	return items, total, err
+}
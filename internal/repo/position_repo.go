@@ -40,6 +40,20 @@ func (r PositionRepo) FindActiveBySymbolAndSide(ctx context.Context, symbol, sid
 	return m, err
 }
 
+// FindByIDsUnscoped 根据ID批量查找持仓记录（包括已平仓删除的）
+func (r PositionRepo) FindByIDsUnscoped(ctx context.Context, ids []string) ([]models.Position, error) {
+	var positions []models.Position
+	if len(ids) == 0 {
+		return positions, nil
+	}
+	db := r.GetDB(ctx)
+	err := db.Unscoped().
+		Table(r.GetTableName()).
+		Where("id IN ?", ids).
+		Find(&positions).Error
+	return positions, err
+}
+
 // DeleteAll 删除所有持仓记录
 func (r PositionRepo) DeleteAll(ctx context.Context) error {
 	db := r.GetDB(ctx)
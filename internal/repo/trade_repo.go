@@ -2,6 +2,8 @@ package repo
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/go-orz/orz"
@@ -22,13 +24,17 @@ type TradeRepo struct {
 func (r TradeRepo) FindRecentTrades(ctx context.Context, limit int) ([]models.Trade, error) {
 	var trades []models.Trade
 	db := r.GetDB(ctx)
-	err := db.Table(r.GetTableName()).
-		Order("executed_at DESC").
+	err := newestFirst(db.Table(r.GetTableName())).
 		Limit(limit).
 		Find(&trades).Error
 	return trades, err
 }
 
+// FindTradesPage 按执行时间倒序分页查询交易记录，返回当前页和总数
+func (r TradeRepo) FindTradesPage(ctx context.Context, page Pagination) ([]models.Trade, int64, error) {
+	return findPage[models.Trade](r.GetDB(ctx), page, newestFirst)
+}
+
 // FindFirstTrade 获取第一笔交易记录
 func (r TradeRepo) FindFirstTrade(ctx context.Context) (*models.Trade, error) {
 	var trade models.Trade
@@ -42,6 +48,67 @@ func (r TradeRepo) FindFirstTrade(ctx context.Context) (*models.Trade, error) {
 	return &trade, nil
 }
 
+// FindLatestOpenTrade 获取指定交易对和方向在 since 之后最近的一笔开仓交易
+func (r TradeRepo) FindLatestOpenTrade(ctx context.Context, symbol, side string, since time.Time) (*models.Trade, error) {
+	var trade models.Trade
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("symbol = ? AND side = ? AND type = ? AND executed_at >= ?", symbol, side, "open", since).
+		Order("executed_at DESC").
+		First(&trade).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+// FindOpenTradesSince 获取 since 之后的全部开仓交易，按执行时间升序
+func (r TradeRepo) FindOpenTradesSince(ctx context.Context, since time.Time) ([]models.Trade, error) {
+	var trades []models.Trade
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("type = ? AND executed_at >= ?", "open", since).
+		Order("executed_at ASC").
+		Find(&trades).Error
+	return trades, err
+}
+
+// FindTradesSince 获取 since 之后的全部交易（开仓和平仓），按执行时间升序
+func (r TradeRepo) FindTradesSince(ctx context.Context, since time.Time) ([]models.Trade, error) {
+	var trades []models.Trade
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("executed_at >= ?", since).
+		Order("executed_at ASC").
+		Find(&trades).Error
+	return trades, err
+}
+
+// FindLatestOpenTradeBefore 获取指定交易对在 before 之前最近的一笔开仓交易
+func (r TradeRepo) FindLatestOpenTradeBefore(ctx context.Context, symbol string, before time.Time) (*models.Trade, error) {
+	var trade models.Trade
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("symbol = ? AND type = ? AND executed_at < ?", symbol, "open", before).
+		Order("executed_at DESC").
+		First(&trade).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
+
+// FindCloseTradesSince 获取指定交易对在 since 之后的平仓交易
+func (r TradeRepo) FindCloseTradesSince(ctx context.Context, symbol string, since time.Time) ([]models.Trade, error) {
+	var trades []models.Trade
+	db := r.GetDB(ctx)
+	err := db.Table(r.GetTableName()).
+		Where("symbol = ? AND type = ? AND executed_at >= ?", symbol, "close", since).
+		Order("executed_at ASC").
+		Find(&trades).Error
+	return trades, err
+}
+
 // TradeStats 交易统计数据
 type TradeStats struct {
 	TotalTrades   int     `json:"total_trades"`   // 总交易数
@@ -56,6 +123,90 @@ type TradeStats struct {
 	LargestWin    float64 `json:"largest_win"`    // 最大盈利
 	LargestLoss   float64 `json:"largest_loss"`   // 最大亏损
 	ProfitFactor  float64 `json:"profit_factor"`  // 盈亏比(总盈利/总亏损)
+	TotalFunding  float64 `json:"total_funding"`  // 累计资金费（正数为净收入，负数为净支出）
+	RTrades       int     `json:"r_trades"`       // 记录了初始风险、可计算R倍数的平仓交易数
+	Expectancy    float64 `json:"expectancy"`     // 期望值：平均每笔平仓交易的R倍数
+
+	SlippageTrades     int     `json:"slippage_trades"`      // 记录了参考价格、可计算滑点的交易数（含开仓和平仓）
+	AvgSlippagePercent float64 `json:"avg_slippage_percent"` // 平均滑点（%），正数表示不利
+	MaxSlippagePercent float64 `json:"max_slippage_percent"` // 最大不利滑点（%）
+	TotalSlippageCost  float64 `json:"total_slippage_cost"`  // 累计滑点成本（USDT），正数表示不利
+
+	ByConfidence []ConfidenceStats `json:"by_confidence"`  // 按开仓信心分组的平仓统计（未记录信心的交易不计入）
+	ByReasonCode []ReasonCodeStats `json:"by_reason_code"` // 按平仓原因分类的平仓统计（未记录分类的交易不计入）
+	ByPlannedRR  []PlannedRRStats  `json:"by_planned_rr"`  // 按开仓时计划盈亏比分档的平仓统计
+
+	DecisionAccuracy *DecisionAccuracy `json:"decision_accuracy,omitempty"` // 最近的决策结果评估
+}
+
+// GroupPnlStats 一组平仓交易的盈亏统计
+type GroupPnlStats struct {
+	Trades        int     `json:"trades"`         // 平仓交易数
+	WinningTrades int     `json:"winning_trades"` // 盈利交易数
+	WinRate       float64 `json:"win_rate"`       // 胜率(%)
+	TotalPnl      float64 `json:"total_pnl"`      // 已实现盈亏
+	AvgPnl        float64 `json:"avg_pnl"`        // 平均每笔盈亏
+}
+
+func (g *GroupPnlStats) add(pnl float64) {
+	g.Trades++
+	g.TotalPnl += pnl
+	if pnl > 0 {
+		g.WinningTrades++
+	}
+}
+
+func (g *GroupPnlStats) finish() {
+	if g.Trades == 0 {
+		return
+	}
+	g.WinRate = float64(g.WinningTrades) / float64(g.Trades) * 100
+	g.AvgPnl = g.TotalPnl / float64(g.Trades)
+}
+
+// ConfidenceStats 单个开仓信心等级的平仓统计
+type ConfidenceStats struct {
+	Confidence int `json:"confidence"` // 开仓信心（1-10）
+	GroupPnlStats
+}
+
+// ReasonCodeStats 单个平仓原因分类的平仓统计
+type ReasonCodeStats struct {
+	ReasonCode models.CloseReasonCode `json:"reason_code"` // 平仓原因分类
+	Percent    float64                `json:"percent"`     // 占已分类平仓交易的比例(%)
+	GroupPnlStats
+}
+
+// PlannedRRStats 单个计划盈亏比档位的平仓统计
+type PlannedRRStats struct {
+	Bucket  string  `json:"bucket"`   // 计划盈亏比档位，none 表示开仓时未设置止盈
+	RTrades int     `json:"r_trades"` // 可计算R倍数的平仓交易数
+	AvgR    float64 `json:"avg_r"`    // 平均R倍数
+	GroupPnlStats
+}
+
+// plannedRRBuckets 计划盈亏比档位，按上限升序排列，最后一档不设上限
+var plannedRRBuckets = []struct {
+	name  string
+	upper float64
+}{
+	{"<1", 1},
+	{"1-2", 2},
+	{"2-3", 3},
+	{">=3", 0},
+}
+
+// plannedRRBucket 返回计划盈亏比所在的档位
+func plannedRRBucket(plannedRR float64) string {
+	if plannedRR <= 0 {
+		return "none"
+	}
+	for _, bucket := range plannedRRBuckets {
+		if bucket.upper == 0 || plannedRR < bucket.upper {
+			return bucket.name
+		}
+	}
+	return plannedRRBuckets[len(plannedRRBuckets)-1].name
 }
 
 // GetTradeStats 获取交易统计数据
@@ -71,6 +222,10 @@ func (r TradeRepo) GetTradeStats(ctx context.Context) (*TradeStats, error) {
 	}
 	stats.TotalTrades = int(totalCount)
 
+	if err := r.applySlippageStats(ctx, stats); err != nil {
+		return nil, err
+	}
+
 	// 获取所有平仓交易
 	var closeTrades []models.Trade
 	if err := db.Table(r.GetTableName()).
@@ -80,6 +235,9 @@ func (r TradeRepo) GetTradeStats(ctx context.Context) (*TradeStats, error) {
 	}
 
 	stats.CloseTrades = len(closeTrades)
+	stats.ByConfidence = []ConfidenceStats{}
+	stats.ByReasonCode = []ReasonCodeStats{}
+	stats.ByPlannedRR = []PlannedRRStats{}
 
 	// 如果没有平仓交易,直接返回
 	if stats.CloseTrades == 0 {
@@ -87,13 +245,17 @@ func (r TradeRepo) GetTradeStats(ctx context.Context) (*TradeStats, error) {
 	}
 
 	// 计算各项统计数据
-	var totalWin, totalLoss float64
+	var totalWin, totalLoss, totalR float64
 	stats.LargestWin = 0
 	stats.LargestLoss = 0
 
 	for _, trade := range closeTrades {
 		stats.TotalPnl += trade.Pnl
 		stats.TotalFee += trade.Fee
+		if trade.InitialRisk > 0 {
+			stats.RTrades++
+			totalR += trade.RMultiple
+		}
 
 		if trade.Pnl > 0 {
 			stats.WinningTrades++
@@ -128,5 +290,133 @@ func (r TradeRepo) GetTradeStats(ctx context.Context) (*TradeStats, error) {
 		stats.ProfitFactor = totalWin / (-totalLoss)
 	}
 
+	// 计算期望值（平均R倍数）
+	if stats.RTrades > 0 {
+		stats.Expectancy = totalR / float64(stats.RTrades)
+	}
+
+	stats.ByConfidence = groupTradesByConfidence(closeTrades)
+	stats.ByReasonCode = groupTradesByReasonCode(closeTrades)
+	stats.ByPlannedRR = groupTradesByPlannedRR(closeTrades)
+
 	return stats, nil
 }
+
+// applySlippageStats 汇总记录了参考价格的开仓和平仓交易的滑点
+func (r TradeRepo) applySlippageStats(ctx context.Context, stats *TradeStats) error {
+	var trades []models.Trade
+	if err := r.GetDB(ctx).Table(r.GetTableName()).
+		Where("expected_price > 0").
+		Find(&trades).Error; err != nil {
+		return err
+	}
+
+	var totalSlippage float64
+	for i := range trades {
+		trade := &trades[i]
+		stats.SlippageTrades++
+		totalSlippage += trade.SlippagePercent
+		stats.TotalSlippageCost += trade.SlippageCost()
+		if trade.SlippagePercent > stats.MaxSlippagePercent {
+			stats.MaxSlippagePercent = trade.SlippagePercent
+		}
+	}
+	if stats.SlippageTrades > 0 {
+		stats.AvgSlippagePercent = totalSlippage / float64(stats.SlippageTrades)
+	}
+	return nil
+}
+
+// groupTradesByConfidence 按开仓信心汇总平仓交易，结果按信心升序排列
+func groupTradesByConfidence(closeTrades []models.Trade) []ConfidenceStats {
+	groups := make(map[int]*ConfidenceStats)
+	for _, trade := range closeTrades {
+		if trade.Confidence <= 0 {
+			continue
+		}
+		group, ok := groups[trade.Confidence]
+		if !ok {
+			group = &ConfidenceStats{Confidence: trade.Confidence}
+			groups[trade.Confidence] = group
+		}
+		group.add(trade.Pnl)
+	}
+
+	result := make([]ConfidenceStats, 0, len(groups))
+	for _, group := range groups {
+		group.finish()
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Confidence < result[j].Confidence
+	})
+	return result
+}
+
+// groupTradesByReasonCode 按平仓原因分类汇总平仓交易，结果按 models.CloseReasonCodes 的顺序排列
+func groupTradesByReasonCode(closeTrades []models.Trade) []ReasonCodeStats {
+	groups := make(map[models.CloseReasonCode]*ReasonCodeStats)
+	total := 0
+	for _, trade := range closeTrades {
+		if !trade.ReasonCode.Valid() {
+			continue
+		}
+		group, ok := groups[trade.ReasonCode]
+		if !ok {
+			group = &ReasonCodeStats{ReasonCode: trade.ReasonCode}
+			groups[trade.ReasonCode] = group
+		}
+		group.add(trade.Pnl)
+		total++
+	}
+
+	result := make([]ReasonCodeStats, 0, len(groups))
+	for _, code := range models.CloseReasonCodes {
+		group, ok := groups[code]
+		if !ok {
+			continue
+		}
+		group.finish()
+		group.Percent = float64(group.Trades) / float64(total) * 100
+		result = append(result, *group)
+	}
+	return result
+}
+
+// groupTradesByPlannedRR 按计划盈亏比档位汇总平仓交易，结果按档位升序排列，未设置止盈的交易排在最后
+func groupTradesByPlannedRR(closeTrades []models.Trade) []PlannedRRStats {
+	groups := make(map[string]*PlannedRRStats)
+	for _, trade := range closeTrades {
+		bucket := plannedRRBucket(trade.PlannedRR)
+		group, ok := groups[bucket]
+		if !ok {
+			group = &PlannedRRStats{Bucket: bucket}
+			groups[bucket] = group
+		}
+		group.add(trade.Pnl)
+		if trade.InitialRisk > 0 {
+			group.RTrades++
+			group.AvgR += trade.RMultiple
+		}
+	}
+
+	order := make([]string, 0, len(plannedRRBuckets)+1)
+	for _, bucket := range plannedRRBuckets {
+		order = append(order, bucket.name)
+	}
+	order = append(order, "none")
+
+	result := make([]PlannedRRStats, 0, len(groups))
+	for _, bucket := range order {
+		group, ok := groups[bucket]
+		if !ok {
+			continue
+		}
+		group.finish()
+		if group.RTrades > 0 {
+			group.AvgR /= float64(group.RTrades)
+		}
+		result = append(result, *group)
+	}
+	return result
+}
@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	_ "embed"
+	"fmt"
 	"sort"
 	"time"
 
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/repo"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/go-orz/orz"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -21,6 +24,7 @@ var DefaultTradingConfig = models.TradingConfig{
 	MaxPositions:       3,
 	MaxLeverage:        10,
 	MinLeverage:        3,
+	MaxHoldingHours:    36,
 	CreatedAt:          time.Now(),
 	UpdatedAt:          time.Now(),
 }
@@ -36,6 +40,7 @@ var defaultSystemPrompt = models.SystemPrompt{
 }
 
 type AdminConfigService struct {
+	*orz.Service
 	logger            *zap.Logger
 	tradingConfigRepo *repo.TradingConfigRepo
 	systemPromptRepo  *repo.SystemPromptRepo
@@ -44,6 +49,7 @@ type AdminConfigService struct {
 
 func NewAdminConfigService(logger *zap.Logger, db *gorm.DB) *AdminConfigService {
 	return &AdminConfigService{
+		Service:           orz.NewService(db),
 		logger:            logger,
 		tradingConfigRepo: repo.NewTradingConfigRepo(db),
 		systemPromptRepo:  repo.NewSystemPromptRepo(db),
@@ -116,6 +122,16 @@ func (s *AdminConfigService) GetTradingConfig(ctx context.Context) (*models.Trad
 }
 
 func (s *AdminConfigService) SetTradingConfig(ctx context.Context, newTradingConfig models.TradingConfig) error {
+	symbolIndicators, err := normalizeSymbolIndicators(newTradingConfig.Indicators, newTradingConfig.SymbolIndicators)
+	if err != nil {
+		return err
+	}
+
+	symbolMaxLeverage, err := normalizeSymbolMaxLeverage(newTradingConfig.SymbolMaxLeverage)
+	if err != nil {
+		return err
+	}
+
 	config, err := s.GetTradingConfig(ctx)
 	if err != nil {
 		return err
@@ -125,15 +141,22 @@ func (s *AdminConfigService) SetTradingConfig(ctx context.Context, newTradingCon
 	oldInterval := config.IntervalMinutes
 	intervalChanged := oldInterval != newTradingConfig.IntervalMinutes
 
-	config.Symbols = newTradingConfig.Symbols
+	config.Symbols = normalizeSymbols(newTradingConfig.Symbols)
 	config.IntervalMinutes = newTradingConfig.IntervalMinutes
 	config.MaxDrawdownPercent = newTradingConfig.MaxDrawdownPercent
 	config.MaxPositions = newTradingConfig.MaxPositions
 	config.MaxLeverage = newTradingConfig.MaxLeverage
 	config.MinLeverage = newTradingConfig.MinLeverage
+	config.MaxHoldingHours = newTradingConfig.MaxHoldingHours
+	config.SymbolBatchSize = max(newTradingConfig.SymbolBatchSize, 0)
+	config.InitialBalance, config.InitialBalanceAt = resolveBalanceAnchor(config, newTradingConfig, time.Now())
+	config.Indicators = newTradingConfig.Indicators
+	config.SymbolIndicators = symbolIndicators
+	config.SymbolMaxLeverage = symbolMaxLeverage
 	config.UpdatedAt = time.Now()
 
-	err = s.tradingConfigRepo.UpdateById(ctx, config)
+	// 使用 Save 写入全部字段，Updates 会跳过零值导致“0 表示不限制”无法保存
+	err = s.tradingConfigRepo.Save(ctx, config)
 	if err != nil {
 		return err
 	}
@@ -160,6 +183,90 @@ func (s *AdminConfigService) SetTradingConfig(ctx context.Context, newTradingCon
 	return nil
 }
 
+// ResetBalanceAnchor 已设置初始资金锚点时将其更新为指定资金和时间，未设置时不做修改
+func (s *AdminConfigService) ResetBalanceAnchor(ctx context.Context, balance float64, at time.Time) error {
+	config, err := s.GetTradingConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.InitialBalance <= 0 {
+		return nil
+	}
+	config.InitialBalance = balance
+	config.InitialBalanceAt = &at
+	config.UpdatedAt = time.Now()
+	return s.tradingConfigRepo.Save(ctx, config)
+}
+
+// resolveBalanceAnchor 确定初始资金锚点：未设置初始资金时清空锚点；
+// 未指定锚点时间时，初始资金未变则保留原锚点时间，否则以当前时间为锚点
+func resolveBalanceAnchor(current *models.TradingConfig, updated models.TradingConfig, now time.Time) (float64, *time.Time) {
+	if updated.InitialBalance <= 0 {
+		return 0, nil
+	}
+	if updated.InitialBalanceAt != nil {
+		return updated.InitialBalance, updated.InitialBalanceAt
+	}
+	if current.InitialBalanceAt != nil && current.InitialBalance == updated.InitialBalance {
+		return updated.InitialBalance, current.InitialBalanceAt
+	}
+	return updated.InitialBalance, &now
+}
+
+// normalizeSymbols 将交易对统一为内部格式（BTC/USDT、BTC-USDT → BTCUSDT）并去重
+func normalizeSymbols(symbols []string) []string {
+	result := make([]string, 0, len(symbols))
+	seen := make(map[string]struct{}, len(symbols))
+	for _, symbol := range symbols {
+		normalized := exchange.NormalizeSymbol(symbol)
+		if normalized == "" {
+			continue
+		}
+		if _, ok := seen[normalized]; ok {
+			continue
+		}
+		seen[normalized] = struct{}{}
+		result = append(result, normalized)
+	}
+	return result
+}
+
+// normalizeSymbolIndicators 校验全局和按交易对的指标周期，并统一交易对格式
+func normalizeSymbolIndicators(global models.IndicatorParams, symbolIndicators map[string]models.IndicatorParams) (map[string]models.IndicatorParams, error) {
+	if err := ValidateIndicatorParams(global); err != nil {
+		return nil, err
+	}
+	result := make(map[string]models.IndicatorParams, len(symbolIndicators))
+	for symbol, params := range symbolIndicators {
+		normalized := exchange.NormalizeSymbol(symbol)
+		if normalized == "" {
+			continue
+		}
+		// 快慢周期约束以叠加全局配置后的实际生效值为准
+		if err := ValidateIndicatorParams(global.Merge(params)); err != nil {
+			return nil, fmt.Errorf("%s: %w", normalized, err)
+		}
+		result[normalized] = params
+	}
+	return result, nil
+}
+
+// normalizeSymbolMaxLeverage 校验按交易对的最大杠杆，并统一交易对格式
+func normalizeSymbolMaxLeverage(symbolMaxLeverage map[string]int) (map[string]int, error) {
+	result := make(map[string]int, len(symbolMaxLeverage))
+	for symbol, leverage := range symbolMaxLeverage {
+		normalized := exchange.NormalizeSymbol(symbol)
+		if normalized == "" {
+			continue
+		}
+		if leverage <= 0 {
+			return nil, fmt.Errorf("%s: 最大杠杆必须大于0", normalized)
+		}
+		result[normalized] = leverage
+	}
+	return result, nil
+}
+
 // GetSystemPrompt 获取当前激活的系统提示词
 func (s *AdminConfigService) GetSystemPrompt(ctx context.Context) (*models.SystemPrompt, error) {
 	prompt, err := s.systemPromptRepo.GetActiveSystemPrompt(ctx)
@@ -171,6 +278,10 @@ func (s *AdminConfigService) GetSystemPrompt(ctx context.Context) (*models.Syste
 
 // SetSystemPrompt 设置新的系统提示词(创建新版本并激活)
 func (s *AdminConfigService) SetSystemPrompt(ctx context.Context, content, remark string) (*models.SystemPrompt, error) {
+	if err := validatePromptPlaceholders(content); err != nil {
+		return nil, err
+	}
+
 	// 获取当前最大版本号
 	maxVersion, err := s.systemPromptRepo.GetMaxVersion(ctx)
 	if err != nil {
@@ -187,7 +298,8 @@ func (s *AdminConfigService) SetSystemPrompt(ctx context.Context, content, remar
 	}
 
 	// 开启事务:先将所有提示词设为非激活,再创建新版本
-	err = s.systemPromptRepo.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+	// 事务通过 ctx 传递给仓储，保证语句在同一连接上执行（SQLite 单连接时不会自锁）
+	err = s.Transaction(ctx, func(ctx context.Context) error {
 		// 将所有提示词设为非激活
 		if err := s.systemPromptRepo.DeactivateAll(ctx); err != nil {
 			return err
@@ -232,7 +344,7 @@ func (s *AdminConfigService) RollbackSystemPrompt(ctx context.Context, id string
 	}
 
 	// 开启事务:先将所有提示词设为非激活,再激活指定版本
-	return s.systemPromptRepo.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+	return s.Transaction(ctx, func(ctx context.Context) error {
 		// 将所有提示词设为非激活
 		if err := s.systemPromptRepo.DeactivateAll(ctx); err != nil {
 			return err
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// summarizeAccountRisk 汇总账户整体风险，tradingConfig 为 nil 时不填持仓上限和最大回撤
+func summarizeAccountRisk(metrics *AccountMetrics, positions []models.Position, tradingConfig *models.TradingConfig) *AccountRiskResult {
+	result := &AccountRiskResult{
+		Equity:           metrics.TotalBalance,
+		Available:        metrics.Available,
+		UnrealizedPnl:    metrics.UnrealisedPnl,
+		TotalStopRisk:    metrics.TotalStopRisk,
+		TotalStopRiskPct: metrics.TotalStopRiskPct,
+		UnprotectedCount: metrics.UnprotectedCount,
+		PositionCount:    len(positions),
+		DrawdownFromPeak: metrics.DrawdownFromPeak,
+	}
+	if tradingConfig != nil {
+		result.MaxPositions = tradingConfig.MaxPositions
+		result.MaxDrawdownPercent = tradingConfig.MaxDrawdownPercent
+	}
+	for i := range positions {
+		result.MarginUsed += positions[i].Margin
+		result.GrossNotional += positions[i].Notional()
+	}
+	if result.Equity > 0 {
+		result.GrossLeverage = result.GrossNotional / result.Equity
+	}
+	return result
+}
+
+// toolGetAccountRisk 查询实时的账户整体风险
+//
+// 提示词中的账户信息是决策开始前的快照，同一次决策中开仓后需要按最新的净值、保证金和止损风险确定下一笔仓位。
+func (s *AgentService) toolGetAccountRisk(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	metrics, err := s.accountService.GetAccountMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := s.positionService.GetAllPositions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trading config: %w", err)
+	}
+
+	result := summarizeAccountRisk(metrics, positions, tradingConfig)
+	result.CloseOnly = s.closeOnly.Load()
+	return newToolResult("getAccountRisk", "", result), nil
+}
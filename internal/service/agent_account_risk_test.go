@@ -0,0 +1,44 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestSummarizeAccountRisk(t *testing.T) {
+	metrics := &AccountMetrics{
+		TotalBalance:     1000,
+		Available:        600,
+		UnrealisedPnl:    15,
+		TotalStopRisk:    40,
+		TotalStopRiskPct: 4,
+		UnprotectedCount: 1,
+		DrawdownFromPeak: -3.5,
+	}
+	positions := []models.Position{
+		{Symbol: "BTCUSDT", Side: "long", Quantity: 0.02, CurrentPrice: 50000, Margin: 200},
+		{Symbol: "ETHUSDT", Side: "short", Quantity: 0.5, CurrentPrice: 3000, Margin: 150},
+	}
+	tradingConfig := &models.TradingConfig{MaxPositions: 3, MaxDrawdownPercent: 15}
+
+	got := summarizeAccountRisk(metrics, positions, tradingConfig)
+	if got.GrossNotional != 2500 || got.MarginUsed != 350 {
+		t.Errorf("gross notional = %v, margin used = %v, want 2500, 350", got.GrossNotional, got.MarginUsed)
+	}
+	if math.Abs(got.GrossLeverage-2.5) > 1e-9 {
+		t.Errorf("gross leverage = %v, want 2.5", got.GrossLeverage)
+	}
+	if got.PositionCount != 2 || got.MaxPositions != 3 || got.MaxDrawdownPercent != 15 {
+		t.Errorf("positions = %d/%d, max drawdown = %v", got.PositionCount, got.MaxPositions, got.MaxDrawdownPercent)
+	}
+	if got.Equity != 1000 || got.TotalStopRisk != 40 || got.UnprotectedCount != 1 || got.DrawdownFromPeak != -3.5 {
+		t.Errorf("unexpected account fields: %+v", got)
+	}
+
+	// 净值为0时不计算总杠杆
+	if got := summarizeAccountRisk(&AccountMetrics{}, positions, nil); got.GrossLeverage != 0 || got.MaxPositions != 0 {
+		t.Errorf("zero equity = %+v", got)
+	}
+}
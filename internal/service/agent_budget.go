@@ -0,0 +1,65 @@
+package service
+
+import (
+	"github.com/dushixiang/prism/internal/config"
+)
+
+// toolBudget 单次决策内的工具调用预算，限制一次异常决策可能造成的影响
+//
+// 上限为0表示不限制。开仓/平仓次数只统计执行成功的调用，被规则拒绝或执行失败的调用不占用开平仓额度。
+type toolBudget struct {
+	maxToolCalls int
+	maxOpens     int
+	maxCloses    int
+
+	toolCalls int
+	opens     int
+	closes    int
+}
+
+// newToolBudget 按交易配置创建单次决策的工具调用预算
+func newToolBudget(conf config.TradingConf) *toolBudget {
+	return &toolBudget{
+		maxToolCalls: max(conf.MaxToolCallsPerDecision, 0),
+		maxOpens:     max(conf.MaxOpensPerDecision, 0),
+		maxCloses:    max(conf.MaxClosesPerDecision, 0),
+	}
+}
+
+// reserve 在执行工具前检查预算，超出上限时返回错误，否则计入一次工具调用
+func (b *toolBudget) reserve(lang, functionName string) error {
+	if b == nil {
+		return nil
+	}
+	if b.maxToolCalls > 0 && b.toolCalls >= b.maxToolCalls {
+		return localizeError(lang, "budget.tool_calls", b.maxToolCalls)
+	}
+	switch functionName {
+	case "openPosition":
+		if b.maxOpens > 0 && b.opens >= b.maxOpens {
+			return localizeError(lang, "budget.opens", b.maxOpens)
+		}
+	case "closePosition":
+		if b.maxCloses > 0 && b.closes >= b.maxCloses {
+			return localizeError(lang, "budget.closes", b.maxCloses)
+		}
+	}
+	b.toolCalls++
+	return nil
+}
+
+// record 记录工具执行结果，执行成功的开仓/平仓计入对应次数
+func (b *toolBudget) record(functionName string, result *ToolResult, err error) {
+	if b == nil || err != nil {
+		return
+	}
+	if result != nil && !result.Success {
+		return
+	}
+	switch functionName {
+	case "openPosition":
+		b.opens++
+	case "closePosition":
+		b.closes++
+	}
+}
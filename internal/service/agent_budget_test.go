@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/config"
+)
+
+func TestToolBudgetOpens(t *testing.T) {
+	budget := newToolBudget(config.TradingConf{MaxOpensPerDecision: 1})
+
+	// 被规则拒绝的开仓不占用开仓额度
+	if err := budget.reserve(PromptLanguageZh, "openPosition"); err != nil {
+		t.Fatalf("first reserve: %v", err)
+	}
+	budget.record("openPosition", toolErrorResult("openPosition", "rejected", nil), nil)
+	if err := budget.reserve(PromptLanguageZh, "openPosition"); err != nil {
+		t.Fatalf("reserve after rejected open: %v", err)
+	}
+	budget.record("openPosition", newToolResult("openPosition", "", nil), nil)
+
+	if err := budget.reserve(PromptLanguageZh, "openPosition"); err == nil {
+		t.Fatal("open beyond per-decision cap should be rejected")
+	}
+	// 开仓额度用完不影响平仓和查询
+	if err := budget.reserve(PromptLanguageZh, "closePosition"); err != nil {
+		t.Fatalf("close after open cap: %v", err)
+	}
+	budget.record("closePosition", nil, errors.New("failed"))
+	if budget.closes != 0 {
+		t.Fatalf("failed close counted, closes = %d", budget.closes)
+	}
+}
+
+func TestExecuteToolFunctionBudget(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+	budget := newToolBudget(config.TradingConf{MaxToolCallsPerDecision: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := agent.executeToolFunction(ctx, budget, "unknownTool", nil); err == nil || !strings.Contains(err.Error(), "unknown function") {
+			t.Fatalf("call %d err = %v, want unknown function", i+1, err)
+		}
+	}
+	_, err := agent.executeToolFunction(ctx, budget, "unknownTool", nil)
+	if err == nil || !strings.Contains(err.Error(), "上限 2 次") {
+		t.Fatalf("call beyond tool budget err = %v", err)
+	}
+}
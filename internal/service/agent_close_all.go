@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// toolCloseAllPositions 一次性市价平掉全部持仓
+//
+// 逐个按 closePosition 的立即平仓流程处理（记录平仓交易、取消止损止盈单），单个持仓失败不影响其他持仓。
+// 清仓是降低风险的操作，只计入一次工具调用，不受单次决策平仓次数上限限制。
+func (s *AgentService) toolCloseAllPositions(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	reason, _ := args["reason"].(string)
+	reason = strings.TrimSpace(reason)
+	reasonCodeRaw, _ := args["reason_code"].(string)
+	reasonCode := models.CloseReasonCode(strings.TrimSpace(reasonCodeRaw))
+	if reasonCode == "" {
+		reasonCode = models.CloseReasonRiskManagement
+	}
+
+	s.logger.Info("attempting to close all positions",
+		zap.String("reason_code", string(reasonCode)),
+		zap.String("reason", reason))
+
+	if !reasonCode.Valid() {
+		return nil, localizeError(s.language(), "close.invalid_reason_code", reasonCode, closeReasonCodeList())
+	}
+	if err := s.validateCloseReason(reason); err != nil {
+		return nil, err
+	}
+
+	positions, err := s.positionService.GetAllPositions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	result := &CloseAllResult{
+		Reason:     reason,
+		ReasonCode: reasonCode,
+		Results:    make([]CloseAllItem, 0, len(positions)),
+	}
+	lang := s.language()
+	if len(positions) == 0 {
+		return newToolResult("closeAllPositions", localize(lang, "close_all.none"), result), nil
+	}
+
+	var failed []string
+	for i := range positions {
+		position := &positions[i]
+		item := CloseAllItem{Symbol: position.Symbol, Side: position.Side}
+
+		trade, order, err := s.closePositionQuantity(ctx, position, position.Quantity, reasonCode, reason)
+		switch {
+		case errors.Is(err, exchange.ErrReduceOnlyRejected):
+			// 快照之后仓位已被止损等订单平掉，只清理遗留订单
+			if err := s.cancelPositionStopOrders(ctx, position.ID, position.Symbol); err != nil {
+				s.logger.Error("failed to cancel orphaned stop orders",
+					zap.String("position_id", position.ID),
+					zap.Error(err))
+			}
+			item.AlreadyFlat = true
+			result.Closed++
+		case err != nil:
+			s.logger.Error("failed to close position in close all",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+			item.Error = err.Error()
+			result.Failed++
+			failed = append(failed, position.Symbol)
+		default:
+			item.OrderID = order.OrderID
+			item.Pnl = trade.Pnl
+			result.TotalPnl += trade.Pnl
+			result.Closed++
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	if err := s.positionService.SyncPositions(ctx); err != nil {
+		s.logger.Warn("failed to sync positions after closing all positions", zap.Error(err))
+	}
+
+	s.logger.Info("close all positions finished",
+		zap.Int("closed", result.Closed),
+		zap.Int("failed", result.Failed),
+		zap.Float64("total_pnl", result.TotalPnl))
+
+	if result.Failed > 0 {
+		return toolErrorResult("closeAllPositions", localizef(lang, "close_all.partial",
+			result.Closed, strings.Join(failed, localize(lang, "close_all.separator"))), result), nil
+	}
+	message := localizef(lang, "close_all.done", result.Closed, result.TotalPnl) + localizef(lang, "close.reason_suffix", reason)
+	return newToolResult("closeAllPositions", message, result), nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestCloseAllPositions(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	ctx := context.Background()
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		if err := wallet.SetLeverage(ctx, symbol, 5); err != nil {
+			t.Fatalf("set leverage: %v", err)
+		}
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if _, err := wallet.OpenShortPosition(ctx, "ETHUSDT", 2); err != nil {
+		t.Fatalf("open short: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+
+	args := map[string]interface{}{"reason": "大盘急跌，BTC 1h 跌破关键支撑，全部持仓风险过大，立即清仓"}
+	if _, err := agent.toolCloseAllPositions(ctx, map[string]interface{}{"reason": "清仓"}); err == nil {
+		t.Fatal("short reason should be rejected")
+	}
+
+	result, err := agent.toolCloseAllPositions(ctx, args)
+	if err != nil {
+		t.Fatalf("close all returned error: %v", err)
+	}
+	if !strings.HasPrefix(result.Message, "已平掉全部 2 个持仓") || !strings.HasSuffix(result.Message, "（理由："+args["reason"].(string)+"）") {
+		t.Fatalf("message = %q", result.Message)
+	}
+	data := result.Data.(*CloseAllResult)
+	if !result.Success || data.Closed != 2 || data.Failed != 0 || len(data.Results) != 2 {
+		t.Fatalf("unexpected result: %+v %+v", result, data)
+	}
+	if data.ReasonCode != models.CloseReasonRiskManagement {
+		t.Fatalf("reason code = %s, want risk_management", data.ReasonCode)
+	}
+
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 0 {
+		t.Fatalf("positions after close all = %d, err = %v", len(positions), err)
+	}
+	trades, err := agent.TradeRepo.FindAll(ctx)
+	if err != nil || len(trades) != 2 {
+		t.Fatalf("close trades = %d, err = %v", len(trades), err)
+	}
+
+	// 没有持仓时返回空结果
+	if empty, err := agent.toolCloseAllPositions(ctx, args); err != nil || !empty.Success || empty.Data.(*CloseAllResult).Closed != 0 {
+		t.Fatalf("close all without positions: %+v, %v", empty, err)
+	}
+
+	agent.conf.LLM.PromptLanguage = PromptLanguageEn
+	if empty, err := agent.toolCloseAllPositions(ctx, args); err != nil || empty.Message != "no open positions" {
+		t.Fatalf("english close all without positions: %+v, %v", empty, err)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// 市价单成交确认
+const (
+	fillSettleAttempts = 3                      // 下单响应未结束时最多查询订单状态的次数
+	fillSettleInterval = 200 * time.Millisecond // 两次查询之间的间隔
+)
+
+// settleMarketOrder 确认市价单的最终成交
+//
+// 下单响应可能只是受理（ACK，成交数量为0）或订单尚未结束，此时查询订单状态直到订单结束或达到查询次数。
+// 查询失败时返回已知的最新结果，由调用方决定如何处理未确认的成交数量。
+func (s *AgentService) settleMarketOrder(ctx context.Context, symbol string, order *exchange.OrderResult) *exchange.OrderResult {
+	settled := order
+	for attempt := 0; attempt < fillSettleAttempts && !exchange.OrderStatus(settled.Status).Final(); attempt++ {
+		select {
+		case <-ctx.Done():
+			return settled
+		case <-time.After(fillSettleInterval):
+		}
+		status, err := s.exchange.GetOrderStatus(ctx, symbol, order.OrderID)
+		if err != nil {
+			s.logger.Warn("failed to query market order status",
+				zap.String("symbol", symbol),
+				zap.Int64("order_id", order.OrderID),
+				zap.Error(err))
+			continue
+		}
+		settled = status
+	}
+	return settled
+}
+
+// openFill 市价开仓的实际成交
+type openFill struct {
+	Order       *exchange.OrderResult // 确认后的订单
+	AvgPrice    float64               // 成交均价，交易所未返回时为下单前的价格
+	ExecutedQty float64               // 实际成交数量
+	OrderedQty  float64               // 下单数量（按交易对精度格式化后）
+	Partial     bool                  // 成交数量比下单数量少的幅度超过配置的告警阈值
+}
+
+// resolveOpenFill 确认市价开仓的实际成交数量和价格
+//
+// 订单已结束但没有任何成交时返回错误；无法确认成交数量（查询订单失败）时按下单数量处理。
+// 部分成交只记录告警，调用方按实际成交数量设置止损止盈。
+func (s *AgentService) resolveOpenFill(ctx context.Context, symbol string, order *exchange.OrderResult, requestedQty, price float64) (*openFill, error) {
+	fill := &openFill{OrderedQty: order.Quantity}
+	if fill.OrderedQty <= 0 {
+		fill.OrderedQty = requestedQty
+	}
+
+	order = s.settleMarketOrder(ctx, symbol, order)
+	fill.Order = order
+	fill.AvgPrice = order.AvgPrice
+	if fill.AvgPrice <= 0 {
+		fill.AvgPrice = price
+	}
+
+	fill.ExecutedQty = order.ExecutedQty
+	if fill.ExecutedQty <= 0 {
+		if exchange.OrderStatus(order.Status).Final() {
+			return nil, fmt.Errorf("failed to open position: market order %d not filled, status %s", order.OrderID, order.Status)
+		}
+		s.logger.Warn("market order fill not confirmed, assume fully filled",
+			zap.String("symbol", symbol),
+			zap.Int64("order_id", order.OrderID),
+			zap.String("status", order.Status))
+		fill.ExecutedQty = fill.OrderedQty
+	}
+
+	shortfall := partialFillShortfall(fill.OrderedQty, fill.ExecutedQty)
+	fill.Partial = shortfall > s.conf.Trading.PartialFillWarnPercent
+	if fill.Partial {
+		s.logger.Warn("market open partially filled, protection sized to the actual fill",
+			zap.String("symbol", symbol),
+			zap.Int64("order_id", order.OrderID),
+			zap.Float64("ordered_quantity", fill.OrderedQty),
+			zap.Float64("executed_quantity", fill.ExecutedQty),
+			zap.Float64("shortfall_percent", shortfall),
+			zap.String("status", order.Status))
+	}
+	return fill, nil
+}
+
+// partialFillShortfall 实际成交比下单数量少的百分比，成交不少于下单数量时为0
+func partialFillShortfall(requested, filled float64) float64 {
+	if requested <= 0 || filled >= requested {
+		return 0
+	}
+	return (requested - filled) / requested * 100
+}
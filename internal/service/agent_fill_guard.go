@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// defaultFallbackStopATRMultiple 未配置时成交后重设止损使用的1小时ATR倍数
+const defaultFallbackStopATRMultiple = 1.5
+
+// postFillStops 按成交均价重新校验后的止损止盈
+type postFillStops struct {
+	StopLoss          float64
+	TakeProfit        float64
+	StopReset         bool // 止损位于成交价错误一侧，已从成交价重设
+	TakeProfitDropped bool // 止盈位于成交价错误一侧，不再创建止盈单
+}
+
+// stopBeyondFill 止损是否位于成交价的错误一侧（做多不低于成交价、做空不高于成交价）
+func stopBeyondFill(side string, fillPrice, stopLoss float64) bool {
+	if side == "long" {
+		return stopLoss >= fillPrice
+	}
+	return stopLoss <= fillPrice
+}
+
+// takeProfitBeyondFill 止盈是否位于成交价的错误一侧，未设置止盈时返回 false
+func takeProfitBeyondFill(side string, fillPrice, takeProfit float64) bool {
+	if takeProfit <= 0 {
+		return false
+	}
+	if side == "long" {
+		return takeProfit <= fillPrice
+	}
+	return takeProfit >= fillPrice
+}
+
+// fallbackStopLoss 从成交价按 ATR 倍数计算止损，ATR不可用时沿用下单前计划的止损距离
+func fallbackStopLoss(side string, expectedPrice, fillPrice, plannedStop, atr, multiple float64) float64 {
+	distance := atr * multiple
+	if distance <= 0 || distance >= fillPrice {
+		return preserveRiskStop(side, expectedPrice, fillPrice, plannedStop)
+	}
+	if side == "long" {
+		return fillPrice - distance
+	}
+	return fillPrice + distance
+}
+
+// revalidateStopsAfterFill 按成交均价重新校验止损止盈
+//
+// 开仓前规则按下单前价格校验，滑点可能让原本有效的止损落到成交价的错误一侧（如做多止损高于成交价），
+// 这样的止损单会立即触发或被交易所拒绝。此时按1小时ATR倍数从成交价重设止损；
+// 止盈落到错误一侧时不创建止盈单，留给AI后续调整。
+func (s *AgentService) revalidateStopsAfterFill(ctx context.Context, symbol, side string, expectedPrice, fillPrice, stopLoss, takeProfit float64) postFillStops {
+	stops := postFillStops{StopLoss: stopLoss, TakeProfit: takeProfit}
+	if !s.conf.Trading.PostFillStopGuard || fillPrice <= 0 {
+		return stops
+	}
+
+	if stopBeyondFill(side, fillPrice, stopLoss) {
+		multiple := s.conf.Trading.FallbackStopATRMultiple
+		if multiple <= 0 {
+			multiple = defaultFallbackStopATRMultiple
+		}
+		params := models.DefaultIndicatorParams
+		if tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx); err == nil {
+			params = tradingConfig.IndicatorParamsFor(symbol)
+		}
+		atr := 0.0
+		if ind := s.currentRegimeIndicators(ctx, symbol, params); ind != nil {
+			atr = ind.ATRSlow
+		}
+		stops.StopLoss = fallbackStopLoss(side, expectedPrice, fillPrice, stopLoss, atr, multiple)
+		stops.StopReset = true
+		s.logger.Warn("stop loss on wrong side of fill price, reset from fill",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Float64("expected_price", expectedPrice),
+			zap.Float64("avg_price", fillPrice),
+			zap.Float64("planned_stop_loss", stopLoss),
+			zap.Float64("atr", atr),
+			zap.Float64("stop_loss", stops.StopLoss))
+	}
+
+	if takeProfitBeyondFill(side, fillPrice, takeProfit) {
+		stops.TakeProfit = 0
+		stops.TakeProfitDropped = true
+		s.logger.Warn("take profit on wrong side of fill price, skipping take profit order",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Float64("avg_price", fillPrice),
+			zap.Float64("planned_take_profit", takeProfit))
+	}
+	return stops
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPostFillStopChecks(t *testing.T) {
+	// 做多下单前价格 100、止损 99.8，成交在 99.7 时止损已高于成交价
+	if !stopBeyondFill("long", 99.7, 99.8) || stopBeyondFill("long", 100.1, 99.8) {
+		t.Fatal("long stop side check failed")
+	}
+	if !stopBeyondFill("short", 100.3, 100.2) || stopBeyondFill("short", 99.9, 100.2) {
+		t.Fatal("short stop side check failed")
+	}
+	if !takeProfitBeyondFill("long", 101, 100.5) || takeProfitBeyondFill("long", 101, 0) || !takeProfitBeyondFill("short", 99, 99.5) {
+		t.Fatal("take profit side check failed")
+	}
+
+	if stop := fallbackStopLoss("long", 100, 99.7, 99.8, 0.4, 1.5); math.Abs(stop-99.1) > 1e-9 {
+		t.Fatalf("long atr stop = %v, want 99.1", stop)
+	}
+	if stop := fallbackStopLoss("short", 100, 100.3, 100.2, 0.4, 1.5); math.Abs(stop-100.9) > 1e-9 {
+		t.Fatalf("short atr stop = %v, want 100.9", stop)
+	}
+	// ATR 不可用时沿用计划的止损距离
+	if stop := fallbackStopLoss("long", 100, 99.7, 99.8, 0, 1.5); math.Abs(stop-99.5) > 1e-9 {
+		t.Fatalf("planned distance stop = %v, want 99.5", stop)
+	}
+}
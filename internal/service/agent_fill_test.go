@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+// partialFillExchange 下单只返回受理结果，查询订单时才返回最终的（部分）成交
+type partialFillExchange struct {
+	exchange.Exchange
+	final   *exchange.OrderResult
+	queries int
+	err     error
+}
+
+func (e *partialFillExchange) GetOrderStatus(ctx context.Context, symbol string, orderID int64) (*exchange.OrderResult, error) {
+	e.queries++
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.final, nil
+}
+
+func TestResolveOpenFill(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+	ack := &exchange.OrderResult{OrderID: 7, Quantity: 1.0, Status: string(exchange.OrderStatusNew)}
+	ctx := context.Background()
+
+	// 受理后只成交了40%，剩余部分因盘口深度不足过期
+	ex := &partialFillExchange{final: &exchange.OrderResult{OrderID: 7, Quantity: 1.0, ExecutedQty: 0.4, AvgPrice: 100.5,
+		Status: string(exchange.OrderStatusExpired)}}
+	agent.exchange = ex
+	fill, err := agent.resolveOpenFill(ctx, "BTCUSDT", ack, 1.02, 100)
+	if err != nil {
+		t.Fatalf("resolve fill: %v", err)
+	}
+	if fill.ExecutedQty != 0.4 || fill.OrderedQty != 1.0 || fill.AvgPrice != 100.5 || !fill.Partial || ex.queries != 1 {
+		t.Fatalf("fill = %+v, queries = %d", fill, ex.queries)
+	}
+
+	// 成交缺口在告警阈值内不算部分成交
+	ex.final = &exchange.OrderResult{OrderID: 7, Quantity: 1.0, ExecutedQty: 0.97, Status: string(exchange.OrderStatusFilled)}
+	if fill, err := agent.resolveOpenFill(ctx, "BTCUSDT", ack, 1.0, 100); err != nil || fill.Partial || fill.AvgPrice != 100 {
+		t.Fatalf("fill = %+v, err = %v", fill, err)
+	}
+
+	// 订单已结束但没有任何成交，不能当作开仓成功
+	ex.final = &exchange.OrderResult{OrderID: 7, Quantity: 1.0, Status: string(exchange.OrderStatusExpired)}
+	if _, err := agent.resolveOpenFill(ctx, "BTCUSDT", ack, 1.0, 100); err == nil || !strings.Contains(err.Error(), "not filled") {
+		t.Fatalf("err = %v, want not filled", err)
+	}
+
+	// 查询订单一直失败时按下单数量处理
+	ex.err = errors.New("binance server error (HTTP 503)")
+	ex.queries = 0
+	fill, err = agent.resolveOpenFill(ctx, "BTCUSDT", ack, 1.0, 100)
+	if err != nil || fill.ExecutedQty != 1.0 || ex.queries != fillSettleAttempts {
+		t.Fatalf("fill = %+v, err = %v, queries = %d", fill, err, ex.queries)
+	}
+}
+
+func TestFillSlippage(t *testing.T) {
+	cases := []struct {
+		typ, side string
+		expected  float64
+		fill      float64
+		want      float64
+	}{
+		{"open", "long", 100, 100.5, 0.5},   // 买入成交更贵，不利
+		{"open", "short", 100, 100.5, -0.5}, // 卖出成交更贵，有利
+		{"close", "long", 100, 99.5, 0.5},   // 平多卖出成交更低，不利
+		{"close", "short", 100, 99.5, -0.5}, // 平空买入成交更低，有利
+	}
+	for _, c := range cases {
+		trade := &models.Trade{Type: c.typ, Side: c.side, Price: c.fill, Quantity: 2}
+		trade.ApplySlippage(c.expected)
+		if math.Abs(trade.SlippagePercent-c.want) > 1e-9 {
+			t.Errorf("%s %s slippage = %v, want %v", c.typ, c.side, trade.SlippagePercent, c.want)
+		}
+		if wantCost := c.want / 100 * c.expected * 2; math.Abs(trade.SlippageCost()-wantCost) > 1e-9 {
+			t.Errorf("%s %s slippage cost = %v, want %v", c.typ, c.side, trade.SlippageCost(), wantCost)
+		}
+	}
+
+	if got := preserveRiskStop("long", 100, 101, 95); got != 96 {
+		t.Errorf("long stop = %v, want 96", got)
+	}
+	if got := preserveRiskStop("short", 100, 99, 105); got != 104 {
+		t.Errorf("short stop = %v, want 104", got)
+	}
+}
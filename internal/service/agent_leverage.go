@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// positionMargin 按开仓价计算持仓在指定杠杆下占用的保证金，与持仓同步的口径一致
+func positionMargin(position *models.Position, leverage int) float64 {
+	return exchange.InitialMargin(position.EntryPrice, position.Quantity, leverage)
+}
+
+// toolAdjustLeverage 调整已有持仓的杠杆，不平仓
+//
+// 降低杠杆会占用更多保证金，新增部分超过可用余额时拒绝调整；调整后立即更新本地持仓的杠杆、保证金和强平价。
+func (s *AgentService) toolAdjustLeverage(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	symbol, _ := args["symbol"].(string)
+	symbol = exchange.NormalizeSymbol(symbol)
+	leverageFloat, _ := args["leverage"].(float64)
+	leverage := int(leverageFloat)
+	reason, _ := args["reason"].(string)
+	reason = strings.TrimSpace(reason)
+	lang := s.language()
+
+	s.logger.Info("attempting to adjust leverage",
+		zap.String("symbol", symbol),
+		zap.Int("leverage", leverage),
+		zap.String("reason", reason))
+
+	if symbol == "" {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+
+	if err := s.validateLeverage(symbol, leverage); err != nil {
+		return nil, err
+	}
+
+	positions, err := s.positionService.GetAllPositions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var targetPosition *models.Position
+	for i := range positions {
+		if positions[i].Symbol == symbol {
+			targetPosition = &positions[i]
+			break
+		}
+	}
+
+	if targetPosition == nil {
+		return nil, fmt.Errorf("no position found for symbol %s", symbol)
+	}
+	if targetPosition.Leverage == leverage {
+		return nil, localizeError(lang, "leverage.unchanged", symbol, leverage)
+	}
+
+	oldLeverage := targetPosition.Leverage
+	oldMargin := targetPosition.Margin
+	newMargin := positionMargin(targetPosition, leverage)
+
+	// 降低杠杆需要追加保证金，先确认可用余额足够，避免交易所拒绝
+	if extraMargin := newMargin - oldMargin; extraMargin > 0 {
+		accountInfo, err := s.exchange.GetAccountInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account info: %w", err)
+		}
+		if extraMargin > accountInfo.AvailableBalance {
+			return nil, localizeError(lang, "leverage.margin_short", leverage, extraMargin, accountInfo.AvailableBalance)
+		}
+	}
+
+	if err := s.exchange.SetLeverage(ctx, symbol, leverage); err != nil {
+		return nil, fmt.Errorf("failed to set leverage: %w", err)
+	}
+
+	// 以交易所返回的持仓为准获取新的保证金和强平价
+	liquidationPrice := 0.0
+	if exchangePositions, err := s.exchange.GetPositions(ctx); err != nil {
+		s.logger.Warn("failed to get positions after adjusting leverage", zap.Error(err))
+	} else {
+		for _, p := range exchangePositions {
+			if p.Symbol == symbol && p.Side == targetPosition.Side {
+				liquidationPrice = p.LiquidationPrice
+				break
+			}
+		}
+	}
+
+	if err := s.positionService.UpdateLeverage(ctx, targetPosition.ID, leverage, newMargin, liquidationPrice); err != nil {
+		s.logger.Error("failed to update local position leverage",
+			zap.String("position_id", targetPosition.ID),
+			zap.Error(err))
+	}
+	if liquidationPrice <= 0 {
+		liquidationPrice = targetPosition.LiquidationPrice
+	}
+
+	s.logger.Info("adjust leverage successful",
+		zap.String("symbol", symbol),
+		zap.Int("old_leverage", oldLeverage),
+		zap.Int("leverage", leverage),
+		zap.Float64("margin", newMargin),
+		zap.Float64("liquidation_price", liquidationPrice),
+		zap.String("reason", reason))
+
+	message := localizef(lang, "leverage.adjusted", symbol, oldLeverage, leverage, newMargin)
+	return newToolResult("adjustLeverage", message, &AdjustLeverageResult{
+		Symbol:           symbol,
+		OldLeverage:      oldLeverage,
+		Leverage:         leverage,
+		OldMargin:        oldMargin,
+		Margin:           newMargin,
+		LiquidationPrice: liquidationPrice,
+		Reason:           reason,
+	}), nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAdjustLeverage(t *testing.T) {
+	agent, wallet := newTestAgent(t, 95000)
+	ctx := context.Background()
+
+	// 10倍杠杆开 0.04 BTC：名义价值 3800，保证金 380
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 10); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 0.04); err != nil {
+		t.Fatalf("open position: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+
+	var message string
+	adjust := func(leverage int) (*AdjustLeverageResult, error) {
+		result, err := agent.toolAdjustLeverage(ctx, map[string]interface{}{
+			"symbol":   "BTCUSDT",
+			"leverage": float64(leverage),
+			"reason":   "波动率上升，降低杠杆减少强平风险",
+		})
+		if err != nil {
+			return nil, err
+		}
+		message = result.Message
+		return result.Data.(*AdjustLeverageResult), nil
+	}
+
+	if _, err := adjust(20); err == nil {
+		t.Fatal("leverage above the allowed range should be rejected")
+	}
+	if _, err := adjust(10); err == nil {
+		t.Fatal("unchanged leverage should be rejected")
+	}
+
+	result, err := adjust(8)
+	if err != nil {
+		t.Fatalf("adjust leverage: %v", err)
+	}
+	if result.Leverage != 8 || result.OldLeverage != 10 || math.Abs(result.Margin-475) > 1e-6 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if message != "BTCUSDT 杠杆已从 10x 调整为 8x，保证金 $475.00" {
+		t.Fatalf("message = %q", message)
+	}
+	positions, _ := agent.positionService.GetAllPositions(ctx)
+	if len(positions) != 1 || positions[0].Leverage != 8 || math.Abs(positions[0].Margin-475) > 1e-6 {
+		t.Fatalf("local position not updated: %+v", positions)
+	}
+
+	// 降到3倍需要保证金约1266.67，追加约791.67，超过可用余额 1000-475
+	if _, err := adjust(3); err == nil {
+		t.Fatal("lowering leverage beyond available margin should be rejected")
+	}
+	if positions, _ := agent.positionService.GetAllPositions(ctx); positions[0].Leverage != 8 {
+		t.Fatalf("rejected adjustment should keep leverage, got %dx", positions[0].Leverage)
+	}
+
+	agent.conf.LLM.PromptLanguage = PromptLanguageEn
+	if _, err := adjust(5); err != nil {
+		t.Fatalf("adjust leverage: %v", err)
+	}
+	if !strings.HasPrefix(message, "BTCUSDT leverage adjusted from 8x to 5x") {
+		t.Fatalf("message = %q, want the english message", message)
+	}
+}
@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// openRequest 开仓请求及其校验所需的市场/账户上下文
+type openRequest struct {
+	Symbol            string
+	Side              string
+	Leverage          int
+	Margin            float64 // 保证金（USDT）
+	StopLossPrice     float64
+	TakeProfitPrice   float64
+	InvalidationPrice float64 // 论点失效价格
+	Reason            string
+	ExitPlan          string
+	Confidence        int // 开仓信心（1-10）
+
+	MinExplanationLength       int  // reason 和 exit_plan 各自的最少字符数，0表示不检查
+	RequireExplanationKeywords bool // 开仓说明是否必须提及时间框架、信号和止损价位
+
+	// 以下为校验上下文，由 buildOpenRequestContext 填充
+	Price                       float64 // 当前价格
+	MinLeverage                 int
+	MaxLeverage                 int
+	SymbolLeverageCap           bool                  // MaxLeverage 来自按交易对配置的杠杆上限
+	MinNotional                 float64               // 交易对最小名义价值
+	MinNotionalTolerancePercent float64               // 允许自动补足的不足比例
+	AvailableBalance            float64               // 可用余额，小于0表示未知
+	TotalBalance                float64               // 账户净值，0表示未知
+	MaxPositions                int                   // 最大持仓数量，0表示不限制
+	OpenPositionCount           int                   // 当前持仓数量
+	HasSamePosition             bool                  // 是否已有同交易对同方向持仓（加仓不占用新仓位）
+	MaxDrawdownPercent          float64               // 最大回撤限制（正数百分比），0表示不限制
+	DrawdownFromPeak            float64               // 当前距峰值回撤（负数百分比）
+	DrawdownKnown               bool                  // 是否成功获取账户回撤
+	TrendingRegimeOnly          bool                  // 是否只允许在趋势行情中开仓
+	Regime                      MarketRegime          // 交易对当前的市场状态，仅在 TrendingRegimeOnly 时计算
+	RegimeADX                   float64               // 判断市场状态所用的1小时ADX
+	RegimeOverride              string                // AI 在非趋势行情中开仓给出的理由
+	HTFTrendGuard               string                // 逆1小时强趋势开仓的处理方式
+	HTFTrend                    *HigherTimeframeTrend // 交易对1小时趋势状态，未开启检查或指标缺失时为 nil
+	CounterTrendReason          string                // AI 逆强趋势开仓给出的理由
+	BlockUnconfirmedOpens       bool                  // 是否拒绝顺着未确认突破方向开仓
+	Breakout                    *SignalConfirmation   // 交易对15分钟突破的持续情况，未开启检查或行情获取失败时为 nil
+	VolatilitySpikeGuard        string                // 波动异常放大时开仓的处理方式
+	VolatilitySpikeStopATR      float64               // widen 模式下止损距离的短周期ATR倍数
+	VolatilitySpike             *VolatilitySpike      // 交易对1小时ATR放大情况，未开启检查或指标缺失时为 nil
+	MaxSpreadPercent            float64               // 允许的最大买卖价差（%），0表示不限制
+	SpreadPercent               float64               // 当前买卖价差（%）
+	SpreadKnown                 bool                  // 是否成功获取盘口
+	OpenWindow                  *OpenWindowUsage      // 滚动窗口内的开仓次数，nil表示不限制或查询失败
+	Language                    string                // 校验信息的语言
+}
+
+// Notional 名义价值 = 保证金 × 杠杆
+func (r *openRequest) Notional() float64 {
+	return r.Margin * float64(r.Leverage)
+}
+
+// preTradeRule 开仓前检查规则，check 返回非nil错误表示规则未通过
+type preTradeRule struct {
+	Name  string
+	check func(req *openRequest) error
+}
+
+// RuleResult 单条规则的检查结果
+type RuleResult struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// preTradeRules 开仓前统一执行的检查规则，新增或移除检查只需修改这里
+var preTradeRules = []preTradeRule{
+	{Name: "required_params", check: checkRequiredParams},
+	{Name: "entry_explanation", check: checkEntryExplanation},
+	{Name: "confidence", check: checkConfidence},
+	{Name: "leverage_range", check: checkLeverageRange},
+	{Name: "stop_prices", check: checkStopPrices},
+	{Name: "invalidation_price", check: checkInvalidationPrice},
+	{Name: "min_notional", check: checkMinNotional},
+	{Name: "available_margin", check: checkAvailableMargin},
+	{Name: "max_positions", check: checkMaxPositions},
+	{Name: "max_drawdown", check: checkMaxDrawdown},
+	{Name: "market_regime", check: checkMarketRegime},
+	{Name: "htf_trend", check: checkHTFTrend},
+	{Name: "breakout_confirmation", check: checkBreakoutConfirmation},
+	{Name: "volatility_spike", check: checkVolatilitySpike},
+	{Name: "max_spread", check: checkMaxSpread},
+	{Name: "open_window", check: checkOpenWindow},
+}
+
+// evaluatePreTradeRules 依次执行所有规则（不会在第一条失败时中止），返回全部结果和未通过的结果
+func evaluatePreTradeRules(req *openRequest, rules []preTradeRule) (results []RuleResult, rejected []RuleResult) {
+	results = make([]RuleResult, 0, len(rules))
+	for _, rule := range rules {
+		result := RuleResult{Rule: rule.Name, Passed: true}
+		if err := rule.check(req); err != nil {
+			result.Passed = false
+			result.Message = err.Error()
+			rejected = append(rejected, result)
+		}
+		results = append(results, result)
+	}
+	return results, rejected
+}
+
+func checkRequiredParams(req *openRequest) error {
+	if req.Symbol == "" || req.Side == "" {
+		return localizeError(req.Language, "rule.symbol_side_required")
+	}
+	if req.Side != "long" && req.Side != "short" {
+		return localizeError(req.Language, "rule.invalid_side", req.Side)
+	}
+	if req.Margin <= 0 {
+		return localizeError(req.Language, "rule.margin_positive", req.Margin)
+	}
+	if req.StopLossPrice <= 0 {
+		return localizeError(req.Language, "rule.stop_loss_required")
+	}
+	return nil
+}
+
+// 开仓说明需要提及的内容，中英文关键词均可
+var (
+	explanationTimeframeKeywords = []string{
+		"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "12h", "1d", "1w",
+		"分钟", "小时", "日线", "周线", "时间框架", "周期", "timeframe", "hourly", "daily", "weekly",
+	}
+	explanationSignalKeywords = []string{
+		"突破", "跌破", "回踩", "回调", "反弹", "均线", "支撑", "阻力", "背离", "金叉", "死叉", "放量", "缩量", "趋势", "形态", "结构",
+		"ema", "sma", "macd", "rsi", "adx", "atr", "布林", "bollinger",
+		"breakout", "breakdown", "pullback", "support", "resistance", "divergence", "cross", "trend", "volume", "structure",
+	}
+	explanationStopKeywords = []string{"止损", "stop"}
+)
+
+func checkEntryExplanation(req *openRequest) error {
+	reason := strings.TrimSpace(req.Reason)
+	exitPlan := strings.TrimSpace(req.ExitPlan)
+	if reason == "" {
+		return localizeError(req.Language, "rule.reason_required")
+	}
+	if exitPlan == "" {
+		return localizeError(req.Language, "rule.exit_plan_required")
+	}
+
+	if minLength := req.MinExplanationLength; minLength > 0 {
+		if n := utf8.RuneCountInString(reason); n < minLength {
+			return localizeError(req.Language, "rule.reason_too_short", n, minLength)
+		}
+		if n := utf8.RuneCountInString(exitPlan); n < minLength {
+			return localizeError(req.Language, "rule.exit_plan_too_short", n, minLength)
+		}
+	}
+
+	if !req.RequireExplanationKeywords {
+		return nil
+	}
+	explanation := reason + "\n" + exitPlan
+	var missing []string
+	if !containsAnyKeyword(explanation, explanationTimeframeKeywords) {
+		missing = append(missing, localize(req.Language, "rule.explanation_timeframe"))
+	}
+	if !containsAnyKeyword(explanation, explanationSignalKeywords) {
+		missing = append(missing, localize(req.Language, "rule.explanation_signal"))
+	}
+	// 止损价位需写在退出计划中，且包含具体数字
+	if !containsAnyKeyword(exitPlan, explanationStopKeywords) || !strings.ContainsAny(exitPlan, "0123456789") {
+		missing = append(missing, localize(req.Language, "rule.explanation_stop"))
+	}
+	if len(missing) > 0 {
+		return localizeError(req.Language, "rule.explanation_missing", strings.Join(missing, " / "))
+	}
+	return nil
+}
+
+func checkConfidence(req *openRequest) error {
+	if req.Confidence < minConfidence || req.Confidence > maxConfidence {
+		return localizeError(req.Language, "rule.confidence_range", minConfidence, maxConfidence, req.Confidence)
+	}
+	return nil
+}
+
+func checkLeverageRange(req *openRequest) error {
+	if req.Leverage < req.MinLeverage || req.Leverage > req.MaxLeverage {
+		return leverageRangeError(req.Language, req.Symbol, req.Leverage, req.MinLeverage, req.MaxLeverage, req.SymbolLeverageCap)
+	}
+	return nil
+}
+
+func checkStopPrices(req *openRequest) error {
+	if req.Price <= 0 || req.StopLossPrice <= 0 {
+		// 缺少价格或止损时由其他规则报告
+		return nil
+	}
+	return validateStopPrices(req.Language, req.Price, req.Side, req.StopLossPrice, req.TakeProfitPrice)
+}
+
+func checkInvalidationPrice(req *openRequest) error {
+	if req.InvalidationPrice <= 0 {
+		return localizeError(req.Language, "rule.invalidation_required")
+	}
+	if req.Price <= 0 {
+		return nil
+	}
+	if req.Side == "long" && req.InvalidationPrice >= req.Price {
+		return localizeError(req.Language, "rule.invalidation_long", req.InvalidationPrice, req.Price)
+	}
+	if req.Side == "short" && req.InvalidationPrice <= req.Price {
+		return localizeError(req.Language, "rule.invalidation_short", req.InvalidationPrice, req.Price)
+	}
+	return nil
+}
+
+func checkMinNotional(req *openRequest) error {
+	if req.MinNotional <= 0 || req.Margin <= 0 || req.Leverage <= 0 {
+		return nil
+	}
+	notional := req.Notional()
+	if notional >= req.MinNotional {
+		return nil
+	}
+	shortfallPercent := (req.MinNotional - notional) / req.MinNotional * 100
+	if shortfallPercent <= req.MinNotionalTolerancePercent {
+		// 不足幅度较小，下单时自动补足
+		return nil
+	}
+	return localizeError(req.Language, "rule.min_notional",
+		req.Symbol, req.MinNotional, notional, req.Margin, req.Leverage, req.MinNotional/float64(req.Leverage))
+}
+
+func checkAvailableMargin(req *openRequest) error {
+	if req.AvailableBalance < 0 {
+		return nil
+	}
+	if req.Margin > req.AvailableBalance {
+		return localizeError(req.Language, "rule.available_margin", req.Margin, req.AvailableBalance)
+	}
+	return nil
+}
+
+func checkMaxPositions(req *openRequest) error {
+	if req.MaxPositions <= 0 || req.HasSamePosition {
+		return nil
+	}
+	if req.OpenPositionCount >= req.MaxPositions {
+		return localizeError(req.Language, "rule.max_positions", req.MaxPositions, req.OpenPositionCount)
+	}
+	return nil
+}
+
+func checkMaxDrawdown(req *openRequest) error {
+	if req.MaxDrawdownPercent <= 0 || !req.DrawdownKnown {
+		return nil
+	}
+	if -req.DrawdownFromPeak >= req.MaxDrawdownPercent {
+		return localizeError(req.Language, "rule.max_drawdown", -req.DrawdownFromPeak, req.MaxDrawdownPercent)
+	}
+	return nil
+}
+
+func checkMarketRegime(req *openRequest) error {
+	if !req.TrendingRegimeOnly || req.Regime == MarketRegimeTrending || req.RegimeOverride != "" {
+		return nil
+	}
+	return localizeError(req.Language, "rule.regime_blocked",
+		req.Symbol, localize(req.Language, "regime."+string(req.Regime)), req.RegimeADX)
+}
+
+// checkHTFTrend 开仓方向与1小时强趋势相反时按配置拒绝，或要求给出逆趋势理由
+func checkHTFTrend(req *openRequest) error {
+	guard := normalizeHTFTrendGuard(req.HTFTrendGuard)
+	trend := req.HTFTrend
+	if guard == HTFTrendGuardOff || !trend.Against(req.Side) {
+		return nil
+	}
+	direction := localize(req.Language, "htf_trend."+trend.Direction)
+	if guard == HTFTrendGuardBlock {
+		return localizeError(req.Language, "rule.htf_trend_blocked",
+			req.Symbol, trend.Timeframe, direction, trend.ADX, trend.Threshold, trend.EMAFast, trend.EMASlow)
+	}
+	if req.CounterTrendReason != "" {
+		return nil
+	}
+	return localizeError(req.Language, "rule.htf_trend_justify",
+		req.Symbol, trend.Timeframe, direction, trend.ADX, trend.Threshold, trend.EMAFast, trend.EMASlow)
+}
+
+// checkBreakoutConfirmation 顺着尚未确认的突破方向开仓时拒绝，逆突破方向或没有突破时不受影响
+func checkBreakoutConfirmation(req *openRequest) error {
+	breakout := req.Breakout
+	if !req.BlockUnconfirmedOpens || !breakout.Unconfirmed(req.Side) {
+		return nil
+	}
+	return localizeError(req.Language, "rule.breakout_pending",
+		req.Symbol, breakout.Timeframe, localize(req.Language, "breakout."+breakout.Direction),
+		breakout.Level, breakout.Candles, breakout.Required)
+}
+
+func checkMaxSpread(req *openRequest) error {
+	if req.MaxSpreadPercent <= 0 || !req.SpreadKnown || req.SpreadPercent <= req.MaxSpreadPercent {
+		return nil
+	}
+	return localizeError(req.Language, "rule.max_spread", req.Symbol, req.SpreadPercent, req.MaxSpreadPercent)
+}
+
+// buildOpenRequestContext 填充规则校验所需的配置、交易对和账户信息
+func (s *AgentService) buildOpenRequestContext(ctx context.Context, req *openRequest) {
+	req.Language = s.language()
+	req.MinExplanationLength = s.conf.Trading.MinEntryExplanationLength
+	req.RequireExplanationKeywords = s.conf.Trading.RequireEntryKeywords
+	req.MinLeverage, req.MaxLeverage, req.SymbolLeverageCap = s.leverageBounds(req.Symbol)
+	req.MinNotionalTolerancePercent = s.conf.Trading.MinNotionalTolerancePercent
+
+	req.MinNotional = defaultMinNotional
+	if info, err := s.exchange.GetSymbolInfo(ctx, req.Symbol); err != nil {
+		s.logger.Warn("failed to get symbol info for pre-trade checks",
+			zap.String("symbol", req.Symbol),
+			zap.Error(err))
+	} else if info.MinNotional > 0 {
+		req.MinNotional = info.MinNotional
+	}
+
+	req.AvailableBalance = -1
+	if metrics, err := s.accountService.GetAccountMetrics(ctx); err != nil {
+		s.logger.Warn("failed to get account metrics for pre-trade checks", zap.Error(err))
+	} else {
+		req.AvailableBalance = metrics.Available
+		req.TotalBalance = metrics.TotalBalance
+		req.DrawdownFromPeak = metrics.DrawdownFromPeak
+		req.DrawdownKnown = true
+	}
+
+	if usage, err := s.OpenWindowUsage(ctx); err != nil {
+		s.logger.Warn("failed to count opens in window for pre-trade checks", zap.Error(err))
+	} else {
+		req.OpenWindow = usage
+	}
+
+	tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get trading config for pre-trade checks", zap.Error(err))
+	} else {
+		req.MaxPositions = tradingConfig.MaxPositions
+		req.MaxDrawdownPercent = tradingConfig.MaxDrawdownPercent
+	}
+
+	req.HTFTrendGuard = normalizeHTFTrendGuard(s.conf.Trading.HTFTrendGuard)
+	req.VolatilitySpikeGuard = normalizeVolatilitySpikeGuard(s.conf.Trading.VolatilitySpikeGuard)
+	req.VolatilitySpikeStopATR = s.conf.Trading.VolatilitySpikeStopATR
+	if s.conf.Trading.TrendingRegimeOnly || req.HTFTrendGuard != HTFTrendGuardOff || s.conf.Trading.VolatilitySpikeRatio > 0 {
+		params := models.DefaultIndicatorParams
+		if tradingConfig != nil {
+			params = tradingConfig.IndicatorParamsFor(req.Symbol)
+		}
+		ind := s.currentRegimeIndicators(ctx, req.Symbol, params)
+		if req.HTFTrendGuard != HTFTrendGuardOff {
+			req.HTFTrend = determineHTFTrend(ind, s.conf.Trading.HTFTrendADX)
+			if req.HTFTrend.Against(req.Side) {
+				s.logger.Warn("opening against strong higher-timeframe trend",
+					zap.String("symbol", req.Symbol),
+					zap.String("side", req.Side),
+					zap.String("trend", req.HTFTrend.Direction),
+					zap.Float64("adx", req.HTFTrend.ADX),
+					zap.String("guard", req.HTFTrendGuard),
+					zap.String("counter_trend_reason", req.CounterTrendReason))
+			}
+		}
+		req.VolatilitySpike = detectVolatilitySpike(ind, s.conf.Trading.VolatilitySpikeRatio)
+		if req.VolatilitySpike.Active() {
+			s.logger.Warn("opening during volatility spike",
+				zap.String("symbol", req.Symbol),
+				zap.String("side", req.Side),
+				zap.Float64("atr_fast", req.VolatilitySpike.ATRFast),
+				zap.Float64("atr_slow", req.VolatilitySpike.ATRSlow),
+				zap.Float64("ratio", req.VolatilitySpike.Ratio),
+				zap.String("guard", req.VolatilitySpikeGuard))
+		}
+		if s.conf.Trading.TrendingRegimeOnly {
+			req.TrendingRegimeOnly = true
+			req.Regime, req.RegimeADX = s.marketRegimeOf(ind)
+		}
+		if req.TrendingRegimeOnly && req.Regime != MarketRegimeTrending && req.RegimeOverride != "" {
+			s.logger.Warn("opening outside trending regime with override",
+				zap.String("symbol", req.Symbol),
+				zap.String("regime", string(req.Regime)),
+				zap.Float64("adx", req.RegimeADX),
+				zap.String("override_reason", req.RegimeOverride))
+		}
+	}
+
+	if required := s.conf.Trading.ConfirmationCandles; s.conf.Trading.BlockUnconfirmedOpens && required > 0 {
+		req.BlockUnconfirmedOpens = true
+		req.Breakout = s.currentBreakoutConfirmation(ctx, req.Symbol, required)
+	}
+
+	req.MaxSpreadPercent = s.conf.Trading.MaxSpreadPercent
+	if req.MaxSpreadPercent > 0 {
+		// 盘口获取失败不能说明流动性不足，跳过价差检查
+		if ticker, err := s.bookTicker(ctx, req.Symbol); err != nil {
+			s.logger.Warn("failed to get book ticker for pre-trade checks",
+				zap.String("symbol", req.Symbol),
+				zap.Error(err))
+		} else {
+			req.SpreadPercent = ticker.SpreadPercent()
+			req.SpreadKnown = true
+		}
+	}
+
+	if positions, err := s.positionService.GetAllPositions(ctx); err != nil {
+		s.logger.Warn("failed to get positions for pre-trade checks", zap.Error(err))
+	} else {
+		req.OpenPositionCount = len(positions)
+		for _, pos := range positions {
+			if pos.Symbol == req.Symbol && pos.Side == req.Side {
+				req.HasSamePosition = true
+				break
+			}
+		}
+	}
+}
+
+// rejectedOpenResult 构建开仓被拒绝时返回给AI的结构化结果
+func rejectedOpenResult(req *openRequest, results, rejected []RuleResult) *ToolResult {
+	messages := make([]string, 0, len(rejected))
+	for _, r := range rejected {
+		messages = append(messages, r.Message)
+	}
+
+	message := localize(req.Language, "rule.rejected") + strings.Join(messages, localize(req.Language, "rule.separator"))
+	return toolErrorResult("openPosition", message, &OpenRejectedResult{
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		RejectedRules: rejected,
+		RuleResults:   results,
+		HTFTrend:      req.HTFTrend,
+		Volatility:    req.VolatilitySpike,
+	})
+}
+
+// checkOpenWindow 滚动窗口内开仓次数达到上限时拒绝，与单次决策的开仓上限不同，限制的是跨决策的整体交易频率
+func checkOpenWindow(req *openRequest) error {
+	usage := req.OpenWindow
+	if !usage.Exhausted() {
+		return nil
+	}
+	return localizeError(req.Language, "rule.open_window",
+		int(usage.Window.Hours()), usage.Count, usage.Limit, usage.untilReset(time.Now()))
+}
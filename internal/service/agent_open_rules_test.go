@@ -0,0 +1,347 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+func validOpenRequest() *openRequest {
+	return &openRequest{
+		Symbol:                      "BTCUSDT",
+		Side:                        "long",
+		Leverage:                    5,
+		Margin:                      50,
+		StopLossPrice:               95000,
+		TakeProfitPrice:             110000,
+		InvalidationPrice:           97000,
+		Reason:                      "1h 趋势向上，15m 回踩 EMA20 企稳",
+		ExitPlan:                    "跌破 95000 止损，到达 110000 止盈",
+		Confidence:                  7,
+		Price:                       100000,
+		MinLeverage:                 3,
+		MaxLeverage:                 10,
+		MinNotional:                 100,
+		MinNotionalTolerancePercent: 10,
+		AvailableBalance:            1000,
+		MaxPositions:                3,
+		OpenPositionCount:           1,
+		MaxDrawdownPercent:          10,
+		DrawdownFromPeak:            -2,
+		DrawdownKnown:               true,
+	}
+}
+
+func TestEvaluatePreTradeRulesPass(t *testing.T) {
+	results, rejected := evaluatePreTradeRules(validOpenRequest(), preTradeRules)
+	if len(rejected) != 0 {
+		t.Fatalf("expected no rejected rules, got %+v", rejected)
+	}
+	if len(results) != len(preTradeRules) {
+		t.Fatalf("expected %d results, got %d", len(preTradeRules), len(results))
+	}
+}
+
+func TestEvaluatePreTradeRulesCollectsAllFailures(t *testing.T) {
+	req := validOpenRequest()
+	req.Leverage = 20          // 超出杠杆范围
+	req.StopLossPrice = 101000 // 做多止损高于当前价
+	req.Margin = 2000          // 超出可用余额
+
+	_, rejected := evaluatePreTradeRules(req, preTradeRules)
+
+	got := make(map[string]bool)
+	for _, r := range rejected {
+		got[r.Rule] = true
+		if r.Passed || r.Message == "" {
+			t.Errorf("rejected rule %s should carry a message", r.Rule)
+		}
+	}
+	for _, want := range []string{"leverage_range", "stop_prices", "available_margin"} {
+		if !got[want] {
+			t.Errorf("expected rule %s to be rejected, got %+v", want, rejected)
+		}
+	}
+}
+
+func TestCheckMinNotional(t *testing.T) {
+	tests := []struct {
+		name    string
+		margin  float64
+		wantErr bool
+	}{
+		{"enough", 20, false},           // 20 × 5 = 100
+		{"within tolerance", 19, false}, // 95，不足 5%，允许补足
+		{"too small", 10, true},         // 50，不足 50%
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validOpenRequest()
+			req.Margin = tt.margin
+			if err := checkMinNotional(req); (err != nil) != tt.wantErr {
+				t.Errorf("checkMinNotional() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckMaxPositionsFollowsConfig(t *testing.T) {
+	req := validOpenRequest()
+	req.OpenPositionCount = 3
+
+	req.MaxPositions = 3
+	if err := checkMaxPositions(req); err == nil {
+		t.Fatal("expected rejection when open positions reach MaxPositions=3")
+	}
+
+	req.MaxPositions = 5
+	if err := checkMaxPositions(req); err != nil {
+		t.Fatalf("expected open allowed with MaxPositions=5, got %v", err)
+	}
+
+	// 同方向加仓不占用新的仓位
+	req.MaxPositions = 3
+	req.HasSamePosition = true
+	if err := checkMaxPositions(req); err != nil {
+		t.Fatalf("expected adding to existing position allowed, got %v", err)
+	}
+}
+
+func TestCheckMaxDrawdownFollowsConfig(t *testing.T) {
+	req := validOpenRequest()
+	req.DrawdownFromPeak = -12
+
+	req.MaxDrawdownPercent = 10
+	if err := checkMaxDrawdown(req); err == nil {
+		t.Fatal("expected rejection when drawdown exceeds MaxDrawdownPercent=10")
+	}
+
+	req.MaxDrawdownPercent = 15
+	if err := checkMaxDrawdown(req); err != nil {
+		t.Fatalf("expected open allowed with MaxDrawdownPercent=15, got %v", err)
+	}
+
+	req.DrawdownKnown = false
+	req.MaxDrawdownPercent = 10
+	if err := checkMaxDrawdown(req); err != nil {
+		t.Fatalf("unknown drawdown should not block, got %v", err)
+	}
+}
+
+func TestCheckMarketRegime(t *testing.T) {
+	req := validOpenRequest()
+	req.Regime = MarketRegimeRanging
+	req.RegimeADX = 15
+	if err := checkMarketRegime(req); err != nil {
+		t.Fatalf("regime filter disabled should not block, got %v", err)
+	}
+
+	req.TrendingRegimeOnly = true
+	if err := checkMarketRegime(req); err == nil {
+		t.Fatal("expected rejection in ranging market")
+	}
+
+	req.RegimeOverride = "区间下沿放量反转，止损放在区间外"
+	if err := checkMarketRegime(req); err != nil {
+		t.Fatalf("override reason should allow the open, got %v", err)
+	}
+
+	req.RegimeOverride = ""
+	req.Regime = MarketRegimeTrending
+	if err := checkMarketRegime(req); err != nil {
+		t.Fatalf("trending market should pass, got %v", err)
+	}
+}
+
+func TestCheckLeverageRangeSymbolCap(t *testing.T) {
+	tradingConfig := models.TradingConfig{
+		MinLeverage:       3,
+		MaxLeverage:       10,
+		SymbolMaxLeverage: map[string]int{"BTCUSDT": 20, "DOGEUSDT": 5},
+	}
+	if got, capped := tradingConfig.MaxLeverageFor("ETHUSDT"); got != 10 || capped {
+		t.Fatalf("unlisted symbol should use global max, got %d capped=%v", got, capped)
+	}
+
+	req := validOpenRequest()
+	req.Leverage = 15
+	req.MaxLeverage, req.SymbolLeverageCap = tradingConfig.MaxLeverageFor("BTCUSDT")
+	if err := checkLeverageRange(req); err != nil {
+		t.Fatalf("BTC cap should allow 15x above the global max, got %v", err)
+	}
+
+	req.Symbol = "DOGEUSDT"
+	req.Leverage = 8
+	req.MaxLeverage, req.SymbolLeverageCap = tradingConfig.MaxLeverageFor("DOGEUSDT")
+	if err := checkLeverageRange(req); err == nil || !strings.Contains(err.Error(), "DOGEUSDT") {
+		t.Fatalf("expected DOGEUSDT cap rejection, got %v", err)
+	}
+}
+
+func TestCheckMaxSpread(t *testing.T) {
+	ticker := &exchange.BookTicker{BidPrice: 0.995, AskPrice: 1.005}
+	if spread := ticker.SpreadPercent(); math.Abs(spread-1) > 1e-9 {
+		t.Fatalf("spread = %v, want 1%%", spread)
+	}
+
+	req := validOpenRequest()
+	req.MaxSpreadPercent = 0.5
+	if err := checkMaxSpread(req); err != nil {
+		t.Fatalf("unknown spread should not block, got %v", err)
+	}
+
+	req.SpreadKnown = true
+	req.SpreadPercent = ticker.SpreadPercent()
+	if err := checkMaxSpread(req); err == nil || !strings.Contains(err.Error(), "1.000%") {
+		t.Fatalf("expected rejection for 1%% spread, got %v", err)
+	}
+
+	req.MaxSpreadPercent = 0
+	if err := checkMaxSpread(req); err != nil {
+		t.Fatalf("spread check disabled should not block, got %v", err)
+	}
+}
+
+func TestCheckEntryExplanation(t *testing.T) {
+	req := validOpenRequest()
+	req.MinExplanationLength = 15
+	req.RequireExplanationKeywords = true
+	if err := checkEntryExplanation(req); err != nil {
+		t.Fatalf("valid explanation rejected: %v", err)
+	}
+
+	req.Reason = "看涨"
+	if err := checkEntryExplanation(req); err == nil || !strings.Contains(err.Error(), "过于简单") {
+		t.Fatalf("short reason err = %v", err)
+	}
+
+	// 长度足够但缺少时间框架、信号和具体止损价位
+	req.Reason = "感觉市场情绪不错，价格应该还会继续上涨一段时间"
+	req.ExitPlan = "如果行情不对就离场，赚够了也离场，看情况灵活处理"
+	err := checkEntryExplanation(req)
+	if err == nil {
+		t.Fatal("lazy explanation should be rejected")
+	}
+	for _, item := range []string{"时间框架", "具体信号", "具体止损价位"} {
+		if !strings.Contains(err.Error(), item) {
+			t.Fatalf("err = %v, want missing %s", err, item)
+		}
+	}
+
+	// 关闭关键词检查后只检查长度
+	req.RequireExplanationKeywords = false
+	if err := checkEntryExplanation(req); err != nil {
+		t.Fatalf("keywords disabled: %v", err)
+	}
+}
+
+func TestCheckOpenWindow(t *testing.T) {
+	req := validOpenRequest()
+	if err := checkOpenWindow(req); err != nil {
+		t.Fatalf("no window limit: %v", err)
+	}
+
+	now := time.Now()
+	opens := []models.Trade{
+		{ExecutedAt: now.Add(-20 * time.Hour)},
+		{ExecutedAt: now.Add(-6 * time.Hour)},
+		{ExecutedAt: now.Add(-time.Hour)},
+	}
+	req.OpenWindow = newOpenWindowUsage(opens, 3, 24*time.Hour)
+	err := checkOpenWindow(req)
+	if err == nil || !strings.Contains(err.Error(), "已开仓 3 次") {
+		t.Fatalf("exhausted window err = %v", err)
+	}
+	// 最早一笔开仓移出窗口后恢复
+	if want := opens[0].ExecutedAt.Add(24 * time.Hour); !req.OpenWindow.ResetAt.Equal(want) {
+		t.Fatalf("reset at = %v, want %v", req.OpenWindow.ResetAt, want)
+	}
+
+	req.OpenWindow = newOpenWindowUsage(opens, 4, 24*time.Hour)
+	if err := checkOpenWindow(req); err != nil || !req.OpenWindow.ResetAt.IsZero() {
+		t.Fatalf("window below limit: err = %v, reset = %v", err, req.OpenWindow.ResetAt)
+	}
+}
+
+func TestCheckHTFTrend(t *testing.T) {
+	uptrend := &TimeframeIndicators{Price: 110, EMAFast: 105, EMASlow: 100, ADX: 32}
+	req := validOpenRequest()
+	req.Side = "short"
+	req.HTFTrend = determineHTFTrend(uptrend, 25)
+	if req.HTFTrend.Direction != HTFTrendUp || !req.HTFTrend.Strong {
+		t.Fatalf("trend = %+v, want strong uptrend", req.HTFTrend)
+	}
+	if err := checkHTFTrend(req); err != nil {
+		t.Fatalf("guard off: %v", err)
+	}
+
+	req.HTFTrendGuard = HTFTrendGuardBlock
+	if err := checkHTFTrend(req); err == nil || !strings.Contains(err.Error(), "禁止逆大级别趋势开仓") {
+		t.Fatalf("block err = %v", err)
+	}
+	req.Side = "long"
+	if err := checkHTFTrend(req); err != nil {
+		t.Fatalf("with-trend long: %v", err)
+	}
+
+	req.Side = "short"
+	req.HTFTrendGuard = HTFTrendGuardJustify
+	if err := checkHTFTrend(req); err == nil || !strings.Contains(err.Error(), "counter_trend_reason") {
+		t.Fatalf("justify err = %v", err)
+	}
+	req.CounterTrendReason = "4h 顶背离且跌破上升趋势线"
+	if err := checkHTFTrend(req); err != nil {
+		t.Fatalf("justified short: %v", err)
+	}
+
+	// ADX 未达阈值时不视为强趋势
+	req.CounterTrendReason = ""
+	req.HTFTrend = determineHTFTrend(uptrend, 40)
+	if err := checkHTFTrend(req); err != nil || req.HTFTrend.Strong {
+		t.Fatalf("weak trend: err = %v, trend = %+v", err, req.HTFTrend)
+	}
+}
+
+func TestCheckVolatilitySpike(t *testing.T) {
+	ind := &TimeframeIndicators{ATRFast: 900, ATRSlow: 300, Params: models.DefaultIndicatorParams}
+	if spike := detectVolatilitySpike(ind, 0); spike != nil {
+		t.Fatalf("disabled spike = %+v, want nil", spike)
+	}
+
+	req := validOpenRequest()
+	req.VolatilitySpike = detectVolatilitySpike(ind, 2.5)
+	if !req.VolatilitySpike.Active() || math.Abs(req.VolatilitySpike.Ratio-3) > 1e-9 {
+		t.Fatalf("spike = %+v, want active ratio 3", req.VolatilitySpike)
+	}
+	if err := checkVolatilitySpike(req); err != nil {
+		t.Fatalf("guard off: %v", err)
+	}
+
+	req.VolatilitySpikeGuard = VolatilitySpikeGuardBlock
+	if err := checkVolatilitySpike(req); err == nil {
+		t.Fatal("block mode should reject open during spike")
+	}
+
+	// 加宽止损模式：1.5 倍 ATR3 = 1350，做多止损不得高于 98650
+	req.VolatilitySpikeGuard = VolatilitySpikeGuardWiden
+	req.StopLossPrice = 99000
+	if err := checkVolatilitySpike(req); err == nil {
+		t.Fatal("widen mode should reject tight stop")
+	}
+	req.StopLossPrice = 98000
+	if err := checkVolatilitySpike(req); err != nil {
+		t.Fatalf("wide stop: %v", err)
+	}
+
+	// 未达阈值时任何模式都放行
+	req.VolatilitySpikeGuard = VolatilitySpikeGuardBlock
+	req.VolatilitySpike = detectVolatilitySpike(ind, 3.5)
+	if err := checkVolatilitySpike(req); err != nil {
+		t.Fatalf("below threshold: %v", err)
+	}
+}
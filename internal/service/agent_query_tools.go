@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+const (
+	decisionPlaceholderContent  = "执行中..." // 决策执行期间的占位内容
+	defaultRecentDecisionsLimit = 3
+	maxRecentDecisionsLimit     = 10
+	maxDecisionSummaryRunes     = 600 // 单条决策理由的最大字符数，控制 token 消耗
+
+	defaultKlinesLimit = 100
+	minKlinesLimit     = 50 // 指标计算至少需要50根K线
+	maxKlinesLimit     = 200
+	klinesRecentCount  = 10 // 结果中附带的最近K线数量
+)
+
+// klineQueryIntervals getKlines 允许查询的K线周期，限制在较高周期以控制成本和延迟
+var klineQueryIntervals = []string{"15m", "1h", "4h", "1d", "1w"}
+
+// toolGetRecentDecisions 查询最近的决策记录，帮助模型延续之前的交易论点
+func (s *AgentService) toolGetRecentDecisions(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	limit := defaultRecentDecisionsLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+	if limit > maxRecentDecisionsLimit {
+		limit = maxRecentDecisionsLimit
+	}
+
+	// 多取一条，跳过当前正在执行的占位决策
+	decisions, err := s.DecisionRepo.FindRecentDecisions(ctx, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]map[string]interface{}, 0, limit)
+	for _, decision := range decisions {
+		if len(items) >= limit {
+			break
+		}
+		if decision.DecisionContent == decisionPlaceholderContent {
+			continue
+		}
+		items = append(items, summarizeDecision(decision, s.conf.DisplayLocation()))
+	}
+
+	return newToolResult("getRecentDecisions", "", &RecentDecisionsResult{
+		Count:     len(items),
+		Decisions: items,
+	}), nil
+}
+
+// toolGetKlines 按需查询历史K线，返回区间汇总、关键指标和最近几根K线
+func (s *AgentService) toolGetKlines(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	symbol, _ := args["symbol"].(string)
+	symbol = exchange.NormalizeSymbol(symbol)
+	interval, _ := args["interval"].(string)
+	interval = strings.TrimSpace(interval)
+
+	if symbol == "" {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	if !isKlineQueryInterval(interval) {
+		return nil, localizeError(s.language(), "klines.invalid_interval", interval, strings.Join(klineQueryIntervals, ", "))
+	}
+
+	limit := defaultKlinesLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+	limit = max(minKlinesLimit, min(limit, maxKlinesLimit))
+
+	klines, err := s.exchange.GetKlines(ctx, symbol, interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get klines: %w", err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("no klines returned for %s %s", symbol, interval)
+	}
+
+	result := &KlinesResult{
+		Symbol:        symbol,
+		Interval:      interval,
+		Count:         len(klines),
+		Summary:       summarizeKlines(klines, s.conf.DisplayLocation()),
+		CandleFields:  []string{"open_time", "open", "high", "low", "close", "volume"},
+		RecentCandles: compactKlines(klines, klinesRecentCount, s.conf.DisplayLocation()),
+	}
+
+	// K线不足以计算指标（如新上线的交易对或指标周期较长）时指标为空，只返回价格汇总
+	params := s.indicatorParamsFor(ctx, symbol)
+	if indicators := s.indicatorService.CalculateIndicators(klines, params); indicators != nil {
+		result.Indicators = map[string]interface{}{
+			fmt.Sprintf("ema%d", params.EMAFast): indicators.EMAFast,
+			fmt.Sprintf("ema%d", params.EMASlow): indicators.EMASlow,
+			fmt.Sprintf("rsi%d", params.RSISlow): indicators.RSISlow,
+			"macd":                               indicators.MACD,
+			"macd_hist":                          indicators.MACDHist,
+			fmt.Sprintf("atr%d", params.ATRSlow): indicators.ATRSlow,
+			fmt.Sprintf("adx%d", params.ADX):     indicators.ADX,
+			"bbands_upper":                       indicators.BBandsUpper,
+			"bbands_middle":                      indicators.BBandsMiddle,
+			"bbands_lower":                       indicators.BBandsLower,
+		}
+	}
+	return newToolResult("getKlines", "", result), nil
+}
+
+// indicatorParamsFor 读取交易对生效的指标周期，交易配置读取失败时使用默认值
+func (s *AgentService) indicatorParamsFor(ctx context.Context, symbol string) models.IndicatorParams {
+	tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get trading config, using default indicator params", zap.Error(err))
+		return models.DefaultIndicatorParams
+	}
+	return tradingConfig.IndicatorParamsFor(symbol)
+}
+
+// isKlineQueryInterval 检查K线周期是否在允许查询的范围内
+func isKlineQueryInterval(interval string) bool {
+	for _, allowed := range klineQueryIntervals {
+		if interval == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeKlines 汇总K线区间的开高低收、涨跌幅和总成交量，时间按 loc 展示
+func summarizeKlines(klines []*exchange.Kline, loc *time.Location) map[string]interface{} {
+	first, last := klines[0], klines[len(klines)-1]
+	high, low, volume := first.High, first.Low, 0.0
+	for _, k := range klines {
+		high = max(high, k.High)
+		low = min(low, k.Low)
+		volume += k.Volume
+	}
+
+	changePercent := 0.0
+	if first.Open > 0 {
+		changePercent = (last.Close - first.Open) / first.Open * 100
+	}
+
+	return map[string]interface{}{
+		"start_time":     first.OpenTime.In(loc).Format("2006-01-02 15:04"),
+		"end_time":       last.CloseTime.In(loc).Format("2006-01-02 15:04"),
+		"open":           first.Open,
+		"high":           high,
+		"low":            low,
+		"close":          last.Close,
+		"change_percent": changePercent,
+		"volume":         volume,
+	}
+}
+
+// compactKlines 将最近 n 根K线压缩为数组，字段顺序见 candle_fields，时间按 loc 展示，减少 token 消耗
+func compactKlines(klines []*exchange.Kline, n int, loc *time.Location) [][]interface{} {
+	if len(klines) > n {
+		klines = klines[len(klines)-n:]
+	}
+	candles := make([][]interface{}, 0, len(klines))
+	for _, k := range klines {
+		candles = append(candles, []interface{}{k.OpenTime.In(loc).Format("2006-01-02 15:04"), k.Open, k.High, k.Low, k.Close, k.Volume})
+	}
+	return candles
+}
+
+// summarizeDecision 将决策记录压缩为摘要：操作列表 + 截断后的分析理由，时间按 loc 展示
+func summarizeDecision(decision models.Decision, loc *time.Location) map[string]interface{} {
+	actions := make([]string, 0)
+	var rationale []string
+	for _, line := range strings.Split(decision.DecisionContent, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", line == "**思考**", line == "**操作**":
+			continue
+		case strings.HasPrefix(line, "✓") || strings.HasPrefix(line, "✗"):
+			actions = append(actions, line)
+		default:
+			rationale = append(rationale, line)
+		}
+	}
+
+	return map[string]interface{}{
+		"iteration":      decision.Iteration,
+		"executed_at":    decision.ExecutedAt.In(loc).Format("2006-01-02 15:04"),
+		"account_value":  decision.AccountValue,
+		"position_count": decision.PositionCount,
+		"actions":        actions,
+		"rationale":      truncateRunes(strings.Join(rationale, "\n"), maxDecisionSummaryRunes),
+	}
+}
+
+// truncateRunes 按字符截断字符串，避免截断多字节字符
+func truncateRunes(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "..."
+}
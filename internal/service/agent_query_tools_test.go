@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+func TestSummarizeDecision(t *testing.T) {
+	decision := models.Decision{
+		Iteration: 12,
+		DecisionContent: "**思考**\nBTC 1h 趋势向上，回踩 EMA20 企稳\n\n**操作**\n✓ 开仓 LONG BTCUSDT - 成功开仓\n✗ 平仓 ETHUSDT - 错误: 未找到持仓\n\n" +
+			strings.Repeat("长", maxDecisionSummaryRunes+10),
+	}
+
+	summary := summarizeDecision(decision, time.UTC)
+
+	actions := summary["actions"].([]string)
+	if len(actions) != 2 || !strings.HasPrefix(actions[0], "✓ 开仓") || !strings.HasPrefix(actions[1], "✗ 平仓") {
+		t.Fatalf("unexpected actions: %q", actions)
+	}
+
+	rationale := summary["rationale"].(string)
+	if !strings.HasPrefix(rationale, "BTC 1h 趋势向上") {
+		t.Fatalf("unexpected rationale prefix: %q", rationale)
+	}
+	if n := len([]rune(rationale)); n != maxDecisionSummaryRunes+3 {
+		t.Fatalf("rationale length = %d, want %d", n, maxDecisionSummaryRunes+3)
+	}
+}
+
+func TestSummarizeKlines(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := make([]*exchange.Kline, 0, 12)
+	for i := 0; i < 12; i++ {
+		price := 100 + float64(i)
+		klines = append(klines, &exchange.Kline{
+			OpenTime:  start.Add(time.Duration(i) * 24 * time.Hour),
+			Open:      price,
+			High:      price + 5,
+			Low:       price - 5,
+			Close:     price + 1,
+			Volume:    10,
+			CloseTime: start.Add(time.Duration(i+1)*24*time.Hour - time.Millisecond),
+		})
+	}
+
+	summary := summarizeKlines(klines, time.UTC)
+	if summary["open"] != 100.0 || summary["close"] != 112.0 || summary["high"] != 116.0 || summary["low"] != 95.0 {
+		t.Fatalf("unexpected summary: %v", summary)
+	}
+	if summary["volume"] != 120.0 || summary["change_percent"] != 12.0 {
+		t.Fatalf("unexpected volume/change: %v", summary)
+	}
+
+	candles := compactKlines(klines, klinesRecentCount, time.UTC)
+	if len(candles) != klinesRecentCount {
+		t.Fatalf("candles = %d, want %d", len(candles), klinesRecentCount)
+	}
+	if candles[len(candles)-1][4] != 112.0 {
+		t.Fatalf("last candle close = %v, want 112", candles[len(candles)-1][4])
+	}
+}
+
+func TestIsKlineQueryInterval(t *testing.T) {
+	if !isKlineQueryInterval("1d") {
+		t.Fatal("1d should be allowed")
+	}
+	if isKlineQueryInterval("1m") {
+		t.Fatal("1m should not be allowed")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dushixiang/prism/internal/config"
@@ -32,11 +34,19 @@ type AgentService struct {
 	*repo.LLMLogRepo
 	*repo.OrderRepo
 
+	fundingRepo        *repo.FundingPaymentRepo
+	events             *EventService
 	openAIClient       *openai.Client
 	exchange           exchange.Exchange
+	indicatorService   *IndicatorService
 	positionService    *PositionService
+	accountService     *TradingAccountService
 	adminConfigService *AdminConfigService
 	model              string
+	conf               *config.Config
+
+	bookTickers bookTickerCache // 开仓前价差检查使用的盘口缓存
+	closeOnly   atomic.Bool     // 只平仓模式：拒绝新开仓，平仓和调整止损不受影响
 }
 
 // NewAgentService 创建AI Agent服务
@@ -45,31 +55,53 @@ func NewAgentService(
 	db *gorm.DB,
 	openAIClient *openai.Client,
 	exchange exchange.Exchange,
+	indicatorService *IndicatorService,
 	positionService *PositionService,
+	accountService *TradingAccountService,
 	adminConfigService *AdminConfigService,
 	config *config.Config,
 ) *AgentService {
-	return &AgentService{
+	s := &AgentService{
 		logger:             logger,
 		Service:            orz.NewService(db),
 		TradeRepo:          repo.NewTradeRepo(db),
 		DecisionRepo:       repo.NewDecisionRepo(db),
 		LLMLogRepo:         repo.NewLLMLogRepo(db),
 		OrderRepo:          repo.NewOrderRepo(db),
+		fundingRepo:        repo.NewFundingPaymentRepo(db),
+		events:             NewEventService(db, logger),
 		openAIClient:       openAIClient,
 		exchange:           exchange,
+		indicatorService:   indicatorService,
 		positionService:    positionService,
+		accountService:     accountService,
 		adminConfigService: adminConfigService,
 		model:              config.LLM.Model,
+		conf:               config,
+	}
+	s.closeOnly.Store(config.Trading.CloseOnly)
+	return s
+}
+
+// SetCloseOnly 切换只平仓模式
+func (s *AgentService) SetCloseOnly(enabled bool) {
+	if s.closeOnly.Swap(enabled) != enabled {
+		s.logger.Info("close-only mode changed", zap.Bool("close_only", enabled))
 	}
 }
 
+// IsCloseOnly 是否处于只平仓模式
+func (s *AgentService) IsCloseOnly() bool {
+	return s.closeOnly.Load()
+}
+
 // DecisionResult AI决策结果
 type DecisionResult struct {
 	DecisionText     string `json:"decision_text"`
 	ToolsCalled      int    `json:"tools_called"`
 	PromptTokens     int    `json:"prompt_tokens"`
 	CompletionTokens int    `json:"completion_tokens"`
+	Model            string `json:"model"` // 各轮实际使用的模型，改用备用模型时以逗号分隔
 }
 
 // DecisionRound 决策轮次记录
@@ -78,10 +110,18 @@ type DecisionRound struct {
 	ToolCalls []string // 本轮调用的工具
 }
 
+// ErrDecisionCanceled 决策被手动取消，作为 context 的取消原因传入 ExecuteDecision
+var ErrDecisionCanceled = errors.New("decision canceled")
+
 // ExecuteDecision 执行AI决策
+//
+// ctx 以 ErrDecisionCanceled 为原因取消时，会中断进行中的LLM调用并不再执行后续工具，
+// 返回已执行部分的结果和 ErrDecisionCanceled。已开始的工具调用和日志写入不受取消影响，避免下单流程执行到一半。
 func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, systemInstructions string, prompt string, accountMetrics *AccountMetrics) (*DecisionResult, error) {
 	s.logger.Info("executing LLM decision", zap.String("decision_id", decisionID))
 
+	execCtx := context.WithoutCancel(ctx)
+
 	// 构建工具函数定义
 	tools := s.buildOpenAITools(accountMetrics)
 
@@ -92,30 +132,59 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 	}
 
 	// 处理响应和工具调用
+	budget := newToolBudget(s.conf.Trading)
 	toolsCalled := 0
 	var finalText string
 	var rounds []DecisionRound
 	totalPromptTokens := 0
 	totalCompletionTokens := 0
+	var callModels []string
+
+	// canceled 返回已执行部分的结果
+	canceled := func() (*DecisionResult, error) {
+		s.logger.Warn("LLM decision canceled",
+			zap.String("decision_id", decisionID),
+			zap.Int("tools_called", toolsCalled))
+		return &DecisionResult{
+			DecisionText:     s.buildDecisionText(rounds, finalText),
+			ToolsCalled:      toolsCalled,
+			PromptTokens:     totalPromptTokens,
+			CompletionTokens: totalCompletionTokens,
+			Model:            usedModels(callModels),
+		}, ErrDecisionCanceled
+	}
 
 	maxIterations := 10 // 防止无限循环
 	for iteration := 0; iteration < maxIterations; iteration++ {
+		if isDecisionCanceled(ctx) {
+			return canceled()
+		}
+
 		// 记录请求开始时间
 		startTime := time.Now()
 
+		// 按估算的输入长度选择模型，超出主模型上下文窗口时改用备用模型
+		model := s.selectModel(estimateRequestTokens(messages, tools))
+		callModels = append(callModels, model)
+
 		// 调用 OpenAI API
-		resp, err := s.openAIClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-			Model:    s.model,
+		params := openai.ChatCompletionNewParams{
+			Model:    model,
 			Messages: messages,
 			Tools:    tools,
-		})
+		}
+		applySamplingParams(&params, s.conf.LLM)
+		resp, err := s.openAIClient.Chat.Completions.New(ctx, params)
 
 		// 计算请求耗时
 		duration := time.Since(startTime).Milliseconds()
 
 		if err != nil {
 			// 记录失败的LLM调用
-			s.saveLLMLog(ctx, decisionID, iteration+1, iteration+1, systemInstructions, prompt, messages, "", nil, nil, 0, 0, "", duration, err.Error())
+			s.saveLLMLog(execCtx, decisionID, model, iteration+1, iteration+1, systemInstructions, prompt, messages, "", nil, nil, 0, 0, "", duration, err.Error(), "")
+			if isDecisionCanceled(ctx) {
+				return canceled()
+			}
 			return nil, fmt.Errorf("failed to call OpenAI API: %w", err)
 		}
 
@@ -148,10 +217,10 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 			if choice.FinishReason != "" {
 				finishReason = choice.FinishReason
 			}
-			s.saveLLMLog(ctx, decisionID, iteration+1, iteration+1, systemInstructions, prompt, messages,
+			s.saveLLMLog(execCtx, decisionID, model, iteration+1, iteration+1, systemInstructions, prompt, messages,
 				message.Content, nil, nil,
 				int(resp.Usage.PromptTokens), int(resp.Usage.CompletionTokens),
-				finishReason, duration, "")
+				finishReason, duration, "", s.rawLLMResponse(resp))
 			break
 		}
 
@@ -165,20 +234,23 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 		var toolMessages []openai.ChatCompletionMessageParamUnion
 
 		for _, toolCall := range message.ToolCalls {
+			// 已取消时跳过剩余工具，告知模型未执行
+			if isDecisionCanceled(ctx) {
+				result := toolErrorResult(toolCall.Function.Name, ErrDecisionCanceled.Error(), nil)
+				toolMessages = append(toolMessages, openai.ToolMessage(marshalToolResult(result), toolCall.ID))
+				continue
+			}
 			toolsCalled++
 
-			// 解析参数
-			var args map[string]interface{}
-			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			// 解析参数，轻微的格式问题（代码块、多余文本、末尾逗号）容错处理，避免浪费一轮
+			args, recovered, err := parseToolArguments(toolCall.Function.Arguments)
+			if err != nil {
 				s.logger.Error("failed to parse tool arguments",
 					zap.String("function", toolCall.Function.Name),
 					zap.Error(err))
 				// 即使解析失败，也要返回错误响应，不能跳过
-				result := map[string]interface{}{
-					"error": fmt.Sprintf("failed to parse arguments: %v", err),
-				}
-				resultJSON, _ := json.Marshal(result)
-				toolMessages = append(toolMessages, openai.ToolMessage(toolCall.ID, string(resultJSON)))
+				result := toolErrorResult(toolCall.Function.Name, fmt.Sprintf("failed to parse arguments: %v", err), nil)
+				toolMessages = append(toolMessages, openai.ToolMessage(marshalToolResult(result), toolCall.ID))
 
 				// 记录错误的工具调用
 				toolSummary := s.formatToolCall(toolCall.Function.Name, args)
@@ -198,6 +270,11 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 				})
 				continue
 			}
+			if recovered {
+				s.logger.Warn("tool arguments recovered from malformed JSON",
+					zap.String("function", toolCall.Function.Name),
+					zap.String("arguments", toolCall.Function.Arguments))
+			}
 
 			s.logger.Info("LLM called tool",
 				zap.String("function", toolCall.Function.Name),
@@ -207,21 +284,17 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 			toolSummary := s.formatToolCall(toolCall.Function.Name, args)
 
 			// 执行工具函数
-			result, err := s.executeToolFunction(ctx, toolCall.Function.Name, args)
+			result, err := s.executeToolFunction(execCtx, budget, toolCall.Function.Name, args)
 			if err != nil {
 				s.logger.Error("tool execution failed",
 					zap.String("function", toolCall.Function.Name),
 					zap.Error(err))
-				result = map[string]interface{}{
-					"error": err.Error(),
-				}
+				result = toolErrorResult(toolCall.Function.Name, err.Error(), nil)
 			}
 
-			// 将结果转换为 JSON
-			resultJSON, _ := json.Marshal(result)
-
 			// 添加工具响应消息
-			toolMessages = append(toolMessages, openai.ToolMessage(string(resultJSON), toolCall.ID))
+			toolMessages = append(toolMessages, openai.ToolMessage(marshalToolResult(result), toolCall.ID))
+			s.recordToolCallEvent(execCtx, toolCall.Function.Name, toolSummary, args, result)
 
 			// 记录工具调用和响应到当前轮次
 			currentRound.ToolCalls = append(currentRound.ToolCalls, s.formatToolCallWithResult(toolSummary, result))
@@ -246,10 +319,10 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 		if choice.FinishReason != "" {
 			finishReason = choice.FinishReason
 		}
-		s.saveLLMLog(ctx, decisionID, iteration+1, iteration+1, systemInstructions, prompt, messages,
+		s.saveLLMLog(execCtx, decisionID, model, iteration+1, iteration+1, systemInstructions, prompt, messages,
 			message.Content, toolCallsForLog, toolResponsesForLog,
 			int(resp.Usage.PromptTokens), int(resp.Usage.CompletionTokens),
-			finishReason, duration, "")
+			finishReason, duration, "", s.rawLLMResponse(resp))
 
 		// 将工具响应添加到对话历史
 		messages = append(messages, toolMessages...)
@@ -268,9 +341,15 @@ func (s *AgentService) ExecuteDecision(ctx context.Context, decisionID string, s
 		ToolsCalled:      toolsCalled,
 		PromptTokens:     totalPromptTokens,
 		CompletionTokens: totalCompletionTokens,
+		Model:            usedModels(callModels),
 	}, nil
 }
 
+// isDecisionCanceled 决策是否已被手动取消
+func isDecisionCanceled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrDecisionCanceled)
+}
+
 // formatToolCall 格式化工具调用为易读的文本
 func (s *AgentService) formatToolCall(functionName string, args map[string]interface{}) string {
 	switch functionName {
@@ -281,22 +360,44 @@ func (s *AgentService) formatToolCall(functionName string, args map[string]inter
 
 	case "closePosition":
 		symbol, _ := args["symbol"].(string)
+		if reasonCode, _ := args["reason_code"].(string); reasonCode != "" {
+			return fmt.Sprintf("平仓 %s [%s]", symbol, reasonCode)
+		}
 		return fmt.Sprintf("平仓 %s", symbol)
 
+	case "closeAllPositions":
+		return "平掉全部持仓"
+
+	case "adjustLeverage":
+		symbol, _ := args["symbol"].(string)
+		leverage, _ := args["leverage"].(float64)
+		return fmt.Sprintf("调整杠杆 %s %dx", symbol, int(leverage))
+
+	case "getRecentDecisions":
+		return "查询最近决策"
+
+	case "getKlines":
+		symbol, _ := args["symbol"].(string)
+		interval, _ := args["interval"].(string)
+		return fmt.Sprintf("查询K线 %s %s", symbol, interval)
+
+	case "getAccountRisk":
+		return "查询账户风险"
+
 	default:
 		return fmt.Sprintf("调用工具 %s", functionName)
 	}
 }
 
 // formatToolCallWithResult 格式化工具调用结果
-func (s *AgentService) formatToolCallWithResult(toolSummary string, result map[string]interface{}) string {
+func (s *AgentService) formatToolCallWithResult(toolSummary string, result *ToolResult) string {
 	// 提取关键信息
-	if msg, ok := result["message"].(string); ok && msg != "" {
-		return fmt.Sprintf("✓ %s - %s", toolSummary, msg)
+	if errMsg := result.errorMessage(); errMsg != "" {
+		return fmt.Sprintf("✗ %s - 错误: %s", toolSummary, errMsg)
 	}
 
-	if errMsg, ok := result["error"].(string); ok && errMsg != "" {
-		return fmt.Sprintf("✗ %s - 错误: %s", toolSummary, errMsg)
+	if result != nil && result.Message != "" {
+		return fmt.Sprintf("✓ %s - %s", toolSummary, result.Message)
 	}
 
 	return fmt.Sprintf("✓ %s", toolSummary)
@@ -344,51 +445,110 @@ func (s *AgentService) buildDecisionText(rounds []DecisionRound, finalText strin
 // buildOpenAITools 构建 OpenAI 工具函数定义
 func (s *AgentService) buildOpenAITools(accountMetrics *AccountMetrics) []openai.ChatCompletionToolParam {
 	functionType := constant.Function("").Default()
+	lang := s.language()
 
 	return []openai.ChatCompletionToolParam{
 		{
 			Type: functionType,
 			Function: shared.FunctionDefinitionParam{
 				Name:        "openPosition",
-				Description: openai.String("开仓交易（做多或做空）。开仓后将自动在交易所创建止损单作为最后防线。"),
+				Description: openai.String(toolDescription(lang, "openPosition")),
 				Parameters: shared.FunctionParameters{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"symbol": map[string]interface{}{
 							"type":        "string",
-							"description": "交易对，如 BTCUSDT",
+							"description": localize(lang, "tool.openPosition.symbol"),
 						},
 						"side": map[string]interface{}{
 							"type":        "string",
-							"description": "方向：long（做多）或 short（做空）",
+							"description": localize(lang, "tool.openPosition.side"),
 							"enum":        []string{"long", "short"},
 						},
 						"leverage": map[string]interface{}{
 							"type":        "integer",
-							"description": "杠杆倍数（3-15），必须根据信号强度选择",
+							"description": localize(lang, "tool.openPosition.leverage"),
 						},
 						"quantity": map[string]interface{}{
 							"type":        "number",
-							"description": "保证金金额（USDT）。注意：实际开仓的名义价值 = 保证金 × 杠杆。例如用100 USDT保证金，10倍杠杆，实际开仓价值1000 USDT",
+							"description": localize(lang, sizingModeQuantityKey(s.conf.Trading.SizingMode)),
 						},
 						"stop_loss_price": map[string]interface{}{
 							"type":        "number",
-							"description": "【必填】止损价格。开仓后会立即在交易所创建止损单。做多时必须低于当前价，做空时必须高于当前价。建议：根据ATR、关键支撑阻力位或风险承受度设置，通常为入场价的3-5%（考虑杠杆后的账户风险）。",
+							"description": localize(lang, "tool.openPosition.stop_loss_price"),
+						},
+						"stop_loss_percent": map[string]interface{}{
+							"type":        "number",
+							"description": localize(lang, "tool.openPosition.stop_percent"),
+						},
+						"stop_type": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.stop_type"),
+							"enum":        []string{string(exchange.StopExecutionMarket), string(exchange.StopExecutionLimit)},
+						},
+						"stop_limit_offset_percent": map[string]interface{}{
+							"type":        "number",
+							"description": localize(lang, "tool.stop_limit_offset"),
 						},
 						"take_profit_price": map[string]interface{}{
 							"type":        "number",
-							"description": "【可选】止盈价格。如果设置，开仓后会在交易所创建止盈单。做多时必须高于当前价，做空时必须低于当前价。建议：基于关键阻力位或风险回报比设置（如2:1或3:1）。不设置则由AI动态管理。",
+							"description": localize(lang, "tool.openPosition.take_profit_price"),
+						},
+						"take_profit_percent": map[string]interface{}{
+							"type":        "number",
+							"description": localize(lang, "tool.openPosition.target_percent"),
+						},
+						"take_profit_levels": map[string]interface{}{
+							"type":        "array",
+							"description": localize(lang, "tool.openPosition.take_profit_levels"),
+							"maxItems":    maxTakeProfitLevels,
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"price": map[string]interface{}{
+										"type":        "number",
+										"description": localize(lang, "tool.openPosition.level_price"),
+									},
+									"percent": map[string]interface{}{
+										"type":        "number",
+										"description": localize(lang, "tool.openPosition.level_percent"),
+									},
+									"allocation_percent": map[string]interface{}{
+										"type":        "number",
+										"description": localize(lang, "tool.openPosition.level_allocation"),
+									},
+								},
+								"required": []string{"allocation_percent"},
+							},
 						},
 						"reason": map[string]interface{}{
 							"type":        "string",
-							"description": "开仓理由，说明信号来源和时间框架共振情况",
+							"description": localize(lang, "tool.openPosition.reason"),
 						},
 						"exit_plan": map[string]interface{}{
 							"type":        "string",
-							"description": "详细的退出计划，必须明确包含以下至少一种条件：1)止损条件（价格/百分比/指标）；2)止盈条件（目标价/阻力位）；3)结构破坏条件；4)时间条件。平仓时的 reason 必须明确对应这些条件之一。",
+							"description": localize(lang, "tool.openPosition.exit_plan"),
+						},
+						"invalidation_price": map[string]interface{}{
+							"type":        "number",
+							"description": localize(lang, "tool.openPosition.invalidation_price"),
+						},
+						"regime_override_reason": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.openPosition.regime_override"),
+						},
+						"counter_trend_reason": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.openPosition.counter_trend"),
+						},
+						"confidence": map[string]interface{}{
+							"type":        "integer",
+							"description": localize(lang, "tool.openPosition.confidence"),
+							"minimum":     minConfidence,
+							"maximum":     maxConfidence,
 						},
 					},
-					"required": []string{"symbol", "side", "leverage", "quantity", "stop_loss_price", "reason", "exit_plan"},
+					"required": []string{"symbol", "side", "leverage", "quantity", "reason", "exit_plan", "invalidation_price", "confidence"},
 				},
 			},
 		},
@@ -396,20 +556,52 @@ func (s *AgentService) buildOpenAITools(accountMetrics *AccountMetrics) []openai
 			Type: functionType,
 			Function: shared.FunctionDefinitionParam{
 				Name:        "closePosition",
-				Description: openai.String("平仓指定持仓。重要：平仓理由必须严格对应该仓位开仓时设置的退出计划(exit_plan)中的具体条件，不能随意平仓。"),
+				Description: openai.String(toolDescription(lang, "closePosition")),
 				Parameters: shared.FunctionParameters{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"symbol": map[string]interface{}{
 							"type":        "string",
-							"description": "交易对",
+							"description": localize(lang, "tool.closePosition.symbol"),
+						},
+						"reason_code": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.closePosition.reason_code"),
+							"enum":        models.CloseReasonCodes,
 						},
 						"reason": map[string]interface{}{
 							"type":        "string",
-							"description": "平仓理由。必须明确说明触发了该仓位退出计划中的哪个具体条件（如止损、止盈、结构破坏等）。理由必须包含退出计划中的关键要素（价格、指标、条件等）。示例：\"触发止损，价格跌破 $95,000\" 或 \"达到目标价 $105,000，突破阻力位\" 或 \"市场结构破坏，跌破上升趋势线\"。不能使用模糊或无关的理由。",
+							"description": localize(lang, "tool.closePosition.reason"),
+						},
+						"execution": map[string]interface{}{
+							"type":        "string",
+							"description": localizef(lang, "tool.closePosition.execution", s.conf.Trading.TwapSlices, s.conf.Trading.TwapDurationSeconds),
+							"enum":        closeExecutions,
 						},
 					},
-					"required": []string{"symbol", "reason"},
+					"required": []string{"symbol", "reason_code", "reason"},
+				},
+			},
+		},
+		{
+			Type: functionType,
+			Function: shared.FunctionDefinitionParam{
+				Name:        "closeAllPositions",
+				Description: openai.String(toolDescription(lang, "closeAllPositions")),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"reason_code": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.closeAllPositions.reason_code"),
+							"enum":        models.CloseReasonCodes,
+						},
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.closeAllPositions.reason"),
+						},
+					},
+					"required": []string{"reason"},
 				},
 			},
 		},
@@ -417,56 +609,166 @@ func (s *AgentService) buildOpenAITools(accountMetrics *AccountMetrics) []openai
 			Type: functionType,
 			Function: shared.FunctionDefinitionParam{
 				Name:        "updateStopOrders",
-				Description: openai.String("更新持仓的止损止盈单。用于移动止损保护利润、调整止盈目标等。会取消旧的止损止盈单并创建新的。"),
+				Description: openai.String(toolDescription(lang, "updateStopOrders")),
 				Parameters: shared.FunctionParameters{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"symbol": map[string]interface{}{
 							"type":        "string",
-							"description": "交易对",
+							"description": localize(lang, "tool.updateStopOrders.symbol"),
 						},
 						"new_stop_loss_price": map[string]interface{}{
 							"type":        "number",
-							"description": "【可选】新的止损价格。如果不提供则保持原止损不变。常见场景：持仓盈利后移动止损到盈亏平衡点或更高位置保护利润。",
+							"description": localize(lang, "tool.updateStopOrders.stop_loss"),
 						},
 						"new_take_profit_price": map[string]interface{}{
 							"type":        "number",
-							"description": "【可选】新的止盈价格。如果不提供则保持原止盈不变（或取消止盈单让AI灵活管理）。设为0表示取消止盈单。",
+							"description": localize(lang, "tool.updateStopOrders.take_profit"),
+						},
+						"stop_type": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.stop_type"),
+							"enum":        []string{string(exchange.StopExecutionMarket), string(exchange.StopExecutionLimit)},
+						},
+						"stop_limit_offset_percent": map[string]interface{}{
+							"type":        "number",
+							"description": localize(lang, "tool.stop_limit_offset"),
 						},
 						"reason": map[string]interface{}{
 							"type":        "string",
-							"description": "更新理由。说明为什么要调整止损止盈（如：持仓盈利5%，移动止损至盈亏平衡点；市场环境变化，调高止盈目标等）。",
+							"description": localize(lang, "tool.updateStopOrders.reason"),
 						},
 					},
 					"required": []string{"symbol", "reason"},
 				},
 			},
 		},
+		{
+			Type: functionType,
+			Function: shared.FunctionDefinitionParam{
+				Name:        "adjustLeverage",
+				Description: openai.String(toolDescription(lang, "adjustLeverage")),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"symbol": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.adjustLeverage.symbol"),
+						},
+						"leverage": map[string]interface{}{
+							"type":        "integer",
+							"description": localize(lang, "tool.adjustLeverage.leverage"),
+						},
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.adjustLeverage.reason"),
+						},
+					},
+					"required": []string{"symbol", "leverage", "reason"},
+				},
+			},
+		},
+		{
+			Type: functionType,
+			Function: shared.FunctionDefinitionParam{
+				Name:        "getRecentDecisions",
+				Description: openai.String(toolDescription(lang, "getRecentDecisions")),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": localize(lang, "tool.getRecentDecisions.limit"),
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: functionType,
+			Function: shared.FunctionDefinitionParam{
+				Name:        "getKlines",
+				Description: openai.String(toolDescription(lang, "getKlines")),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"symbol": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.getKlines.symbol"),
+						},
+						"interval": map[string]interface{}{
+							"type":        "string",
+							"description": localize(lang, "tool.getKlines.interval"),
+							"enum":        klineQueryIntervals,
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": localize(lang, "tool.getKlines.limit"),
+							"minimum":     minKlinesLimit,
+							"maximum":     maxKlinesLimit,
+						},
+					},
+					"required": []string{"symbol", "interval"},
+				},
+			},
+		},
+		{
+			Type: functionType,
+			Function: shared.FunctionDefinitionParam{
+				Name:        "getAccountRisk",
+				Description: openai.String(toolDescription(lang, "getAccountRisk")),
+				Parameters: shared.FunctionParameters{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
 	}
 }
 
 // executeToolFunction 执行工具函数
-func (s *AgentService) executeToolFunction(ctx context.Context, functionName string, args map[string]interface{}) (map[string]interface{}, error) {
+func (s *AgentService) executeToolFunction(ctx context.Context, budget *toolBudget, functionName string, args map[string]interface{}) (*ToolResult, error) {
+	if err := budget.reserve(s.language(), functionName); err != nil {
+		return nil, err
+	}
+	result, err := s.dispatchTool(ctx, functionName, args)
+	budget.record(functionName, result, err)
+	return result, err
+}
+
+// dispatchTool 按名称调用工具函数
+func (s *AgentService) dispatchTool(ctx context.Context, functionName string, args map[string]interface{}) (*ToolResult, error) {
 	switch functionName {
 	case "openPosition":
 		return s.toolOpenPosition(ctx, args)
 	case "closePosition":
 		return s.toolClosePosition(ctx, args)
+	case "closeAllPositions":
+		return s.toolCloseAllPositions(ctx, args)
 	case "updateStopOrders":
 		return s.toolUpdateStopOrders(ctx, args)
+	case "adjustLeverage":
+		return s.toolAdjustLeverage(ctx, args)
+	case "getRecentDecisions":
+		return s.toolGetRecentDecisions(ctx, args)
+	case "getKlines":
+		return s.toolGetKlines(ctx, args)
+	case "getAccountRisk":
+		return s.toolGetAccountRisk(ctx, args)
 	default:
 		return nil, fmt.Errorf("unknown function: %s", functionName)
 	}
 }
 
 // toolOpenPosition 开仓
-func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
 	// 解析参数
 	symbol, _ := args["symbol"].(string)
+	symbol = exchange.NormalizeSymbol(symbol)
 	side, _ := args["side"].(string)
 	leverageFloat, _ := args["leverage"].(float64)
 	leverage := int(leverageFloat)
-	quantity, _ := args["quantity"].(float64)
+	requestedQuantity, _ := args["quantity"].(float64)
 	reason, _ := args["reason"].(string)
 	exitPlanRaw, _ := args["exit_plan"].(string)
 	exitPlan := strings.TrimSpace(exitPlanRaw)
@@ -474,59 +776,145 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 	// 新增：止损止盈价格
 	stopLossPrice, _ := args["stop_loss_price"].(float64)
 	takeProfitPrice, _ := args["take_profit_price"].(float64)
+	invalidationPrice, _ := args["invalidation_price"].(float64)
+	confidenceFloat, _ := args["confidence"].(float64)
+	confidence := int(confidenceFloat)
+	stopExec := s.stopExecutionFromArgs(args, s.defaultStopExecution())
+	regimeOverride, _ := args["regime_override_reason"].(string)
+	counterTrendReason, _ := args["counter_trend_reason"].(string)
+
+	sizingMode := normalizeSizingMode(s.conf.Trading.SizingMode)
 
 	s.logger.Info("opening position",
 		zap.String("symbol", symbol),
 		zap.String("side", side),
 		zap.Int("leverage", leverage),
-		zap.Float64("margin_usdt", quantity),
+		zap.String("sizing_mode", sizingMode),
+		zap.Float64("quantity", requestedQuantity),
 		zap.Float64("stop_loss_price", stopLossPrice),
+		zap.String("stop_type", string(stopExec.Type)),
 		zap.Float64("take_profit_price", takeProfitPrice),
+		zap.Any("take_profit_levels", args["take_profit_levels"]),
+		zap.Float64("invalidation_price", invalidationPrice),
+		zap.Int("confidence", confidence),
 		zap.String("reason", reason),
 		zap.String("exit_plan", exitPlan))
 
-	// 验证参数
-	if symbol == "" || side == "" {
-		return nil, fmt.Errorf("symbol and side are required")
-	}
-	if quantity <= 0 {
-		return nil, fmt.Errorf("quantity must be positive, got %.8f USDT", quantity)
+	if symbol == "" {
+		return nil, fmt.Errorf("symbol is required")
 	}
-	if quantity < 5 {
-		return nil, fmt.Errorf("保证金太小（%.2f USDT），最少需要 5 USDT 才能满足币安最小名义价值要求", quantity)
+	percentStops, err := parsePercentStops(s.language(), args)
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(reason) == "" {
-		return nil, fmt.Errorf("开仓理由 reason 不能为空")
+	ladder, err := parseTakeProfitLadder(s.language(), args)
+	if err != nil {
+		return nil, err
 	}
-	if exitPlan == "" {
-		return nil, fmt.Errorf("退出计划 exit_plan 不能为空，请明确止损与退出逻辑")
+
+	// 只平仓模式下不允许任何新开仓，无需再检查行情和开仓规则
+	if s.IsCloseOnly() {
+		s.logger.Warn("open position rejected, close-only mode",
+			zap.String("symbol", symbol),
+			zap.String("side", side))
+		return toolErrorResult("openPosition", localize(s.language(), "rule.close_only"), nil), nil
 	}
 
-	// 验证止损价格（必填）
-	if stopLossPrice <= 0 {
-		return nil, fmt.Errorf("止损价格 stop_loss_price 必须设置且大于0")
+	// 获取当前价格计算数量
+	price, err := s.exchange.GetCurrentPrice(ctx, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current price: %w", err)
+	}
+	// 按百分比给出的止损止盈先用当前价格换算参与开仓前检查，成交后再按成交价重新计算
+	stopLossPrice, takeProfitPrice = percentStops.apply(side, price, stopLossPrice, takeProfitPrice)
+	// 分批止盈以最近一档参与开仓前检查，位于错误一侧的档位会排在最前
+	if len(ladder) > 0 {
+		ladder = ladder.resolve(side, price)
+		takeProfitPrice = ladder.target()
+	}
+
+	// 统一执行开仓前检查，返回所有未通过的规则
+	req := &openRequest{
+		Symbol:             symbol,
+		Side:               side,
+		Leverage:           leverage,
+		StopLossPrice:      stopLossPrice,
+		TakeProfitPrice:    takeProfitPrice,
+		InvalidationPrice:  invalidationPrice,
+		Reason:             reason,
+		ExitPlan:           exitPlan,
+		Confidence:         confidence,
+		Price:              price,
+		RegimeOverride:     strings.TrimSpace(regimeOverride),
+		CounterTrendReason: strings.TrimSpace(counterTrendReason),
+	}
+	s.buildOpenRequestContext(ctx, req)
+
+	// 按配置的计价方式把 quantity 换算为保证金
+	if sizingMode == SizingModePercentEquity {
+		if requestedQuantity <= 0 || requestedQuantity > 100 {
+			return nil, localizeError(req.Language, "sizing.invalid_percent", requestedQuantity)
+		}
+		if req.AvailableBalance <= 0 {
+			return nil, localizeError(req.Language, "sizing.balance_unknown")
+		}
 	}
+	req.Margin = marginFromQuantity(sizingMode, requestedQuantity, leverage, req.AvailableBalance)
+	quantity := req.Margin
 
-	// 验证杠杆
-	if !s.validateLeverage(leverage) {
-		minLeverage, maxLeverage := s.leverageBounds()
-		return nil, fmt.Errorf("invalid leverage: %d (allowed range %d-%d)", leverage, minLeverage, maxLeverage)
+	// 自动杠杆：按单笔目标风险和止损距离覆盖AI选择的杠杆
+	var autoLeverage *autoLeverageResult
+	if s.conf.Trading.AutoLeverage {
+		autoLeverage = applyAutoLeverage(req, s.conf.Trading.RiskPercentPerTrade)
+		if autoLeverage != nil {
+			s.logger.Info("auto leverage applied",
+				zap.String("symbol", symbol),
+				zap.Int("requested_leverage", autoLeverage.RequestedLeverage),
+				zap.Int("leverage", autoLeverage.Leverage),
+				zap.Float64("margin_usdt", autoLeverage.Margin),
+				zap.Float64("implied_risk_percent", autoLeverage.RiskPercent))
+			leverage = req.Leverage
+			quantity = req.Margin
+		}
 	}
 
-	// 设置杠杆
-	if err := s.setupPositionLeverage(ctx, symbol, leverage); err != nil {
-		return nil, fmt.Errorf("failed to setup leverage: %w", err)
+	// 低信心交易按配置缩减保证金
+	sizeMultiplier := confidenceSizeMultiplier(confidence, s.conf.Trading.ConfidenceSizing)
+	if sizeMultiplier < 1 {
+		req.Margin *= sizeMultiplier
+		quantity = req.Margin
+		s.logger.Info("margin scaled down by confidence",
+			zap.String("symbol", symbol),
+			zap.Int("confidence", confidence),
+			zap.Float64("size_multiplier", sizeMultiplier),
+			zap.Float64("margin_usdt", quantity))
+	}
+
+	// 波动异常放大时按配置缩减保证金
+	volatilityMargin := 0.0
+	if req.VolatilitySpikeGuard == VolatilitySpikeGuardReduce && req.VolatilitySpike.Active() {
+		volatilityMargin = volatilitySpikeMarginMultiplier(s.conf.Trading.VolatilitySpikeMargin)
+		req.Margin *= volatilityMargin
+		quantity = req.Margin
+		s.logger.Info("margin scaled down by volatility spike",
+			zap.String("symbol", symbol),
+			zap.Float64("atr_ratio", req.VolatilitySpike.Ratio),
+			zap.Float64("margin_multiplier", volatilityMargin),
+			zap.Float64("margin_usdt", quantity))
 	}
 
-	// 获取当前价格计算数量
-	price, err := s.exchange.GetCurrentPrice(ctx, symbol)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current price: %w", err)
+	ruleResults, rejectedRules := evaluatePreTradeRules(req, preTradeRules)
+	if len(rejectedRules) > 0 {
+		s.logger.Warn("open position rejected by pre-trade rules",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Any("rejected_rules", rejectedRules))
+		return rejectedOpenResult(req, ruleResults, rejectedRules), nil
 	}
 
-	// 验证止损止盈价格的合理性
-	if err := s.validateStopPrices(price, side, stopLossPrice, takeProfitPrice); err != nil {
-		return nil, err
+	// 设置杠杆
+	if err := s.setupPositionLeverage(ctx, symbol, leverage); err != nil {
+		return nil, fmt.Errorf("failed to setup leverage: %w", err)
 	}
 
 	// 计算实际数量
@@ -535,6 +923,14 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 	notionalValue := quantity * float64(leverage)
 	actualQuantity := notionalValue / price
 
+	// 校验交易对的最小名义价值，略有不足时向上补足数量
+	actualQuantity, err = s.ensureMinNotional(ctx, symbol, actualQuantity, price, quantity, leverage, req.AvailableBalance)
+	if err != nil {
+		return nil, err
+	}
+	notionalValue = actualQuantity * price
+	quantity = notionalValue / float64(leverage)
+
 	s.logger.Info("calculated order quantity",
 		zap.Float64("margin_usdt", quantity),
 		zap.Int("leverage", leverage),
@@ -542,6 +938,12 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 		zap.Float64("price", price),
 		zap.Float64("coin_quantity", actualQuantity))
 
+	// 记录开仓前的同方向持仓，止损失败回滚时只撤回本次加仓部分
+	var priorPosition *models.Position
+	if existing, err := s.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, symbol, side); err == nil {
+		priorPosition = &existing
+	}
+
 	// 执行开仓
 	var order *exchange.OrderResult
 	if side == "long" {
@@ -554,16 +956,70 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 		return nil, fmt.Errorf("failed to open position: %w", err)
 	}
 
-	// 如果 AvgPrice 为 0,使用当前价格
-	avgPrice := order.AvgPrice
-	if avgPrice == 0 {
-		avgPrice = price
+	// 以交易所确认的最终成交为准，交易记录、止损止盈数量和返回结果都按实际成交数量
+	fill, err := s.resolveOpenFill(ctx, symbol, order, actualQuantity, price)
+	if err != nil {
+		return nil, err
+	}
+	order = fill.Order
+	avgPrice := fill.AvgPrice
+	executedQty := fill.ExecutedQty
+	orderedQty := fill.OrderedQty
+	partialFill := fill.Partial
+	// 保证金按实际成交折算
+	quantity = avgPrice * executedQty / float64(leverage)
+
+	if percentStops.StopLoss > 0 || percentStops.TakeProfit > 0 {
+		stopLossPrice, takeProfitPrice = percentStops.apply(side, avgPrice, stopLossPrice, takeProfitPrice)
+		s.logger.Info("stop prices computed from fill price",
+			zap.String("symbol", symbol),
+			zap.Float64("avg_price", avgPrice),
+			zap.Float64("stop_loss_percent", percentStops.StopLoss),
+			zap.Float64("stop_loss_price", stopLossPrice),
+			zap.Float64("take_profit_percent", percentStops.TakeProfit),
+			zap.Float64("take_profit_price", takeProfitPrice))
 	}
 
-	// 如果 ExecutedQty 为 0,使用实际下单数量
-	executedQty := order.ExecutedQty
-	if executedQty == 0 {
-		executedQty = actualQuantity
+	// 成交滑点超限时告警，按配置平移止损以保持计划的每单位风险
+	slippage := models.SlippagePercent(side == "long", price, avgPrice)
+	slippageExceeded := s.slippageExceeded(slippage)
+	stopAdjusted := false
+	if slippageExceeded {
+		s.logger.Warn("market open filled with excessive slippage",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Float64("expected_price", price),
+			zap.Float64("avg_price", avgPrice),
+			zap.Float64("slippage_percent", slippage),
+			zap.Float64("max_slippage_percent", s.conf.Trading.MaxFillSlippagePercent))
+		// 按百分比设置的止损已按成交价计算，无需再平移
+		if s.conf.Trading.TightenStopOnSlippage && percentStops.StopLoss == 0 {
+			adjusted := preserveRiskStop(side, price, avgPrice, stopLossPrice)
+			s.logger.Info("stop loss shifted to preserve planned risk after slippage",
+				zap.String("symbol", symbol),
+				zap.Float64("planned_stop_loss", stopLossPrice),
+				zap.Float64("stop_loss", adjusted))
+			stopLossPrice = adjusted
+			stopAdjusted = true
+		}
+	}
+
+	// 按百分比给出的止盈档位按成交价重新计算
+	if len(ladder) > 0 {
+		ladder = ladder.resolve(side, avgPrice)
+		takeProfitPrice = ladder.target()
+	}
+
+	// 滑点可能让止损止盈落到成交价的错误一侧，按成交价重新校验
+	postFill := s.revalidateStopsAfterFill(ctx, symbol, side, price, avgPrice, stopLossPrice, takeProfitPrice)
+	plannedTakeProfit := takeProfitPrice
+	stopLossPrice, takeProfitPrice = postFill.StopLoss, postFill.TakeProfit
+	// 分批止盈只去掉被成交价越过的档位，其余档位照常创建
+	droppedLevels := 0
+	if len(ladder) > 0 && postFill.TakeProfitDropped {
+		ladder, droppedLevels = ladder.withoutBeyondFill(side, avgPrice)
+		takeProfitPrice = ladder.target()
+		postFill.TakeProfitDropped = false
 	}
 
 	// 计算手续费 (0.10% = 0.001)
@@ -582,20 +1038,31 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 		Leverage:   leverage,
 		Fee:        fee,
 		Reason:     reason,
+		Confidence: confidence,
+		PlannedRR:  models.PlannedRewardRisk(side, avgPrice, stopLossPrice, takeProfitPrice),
 		OrderID:    fmt.Sprintf("%d", order.OrderID),
+		Mode:       s.conf.TradingMode(),
 		ExecutedAt: time.Now(),
 	}
+	trade.ApplySlippage(price)
 
 	if err := s.TradeRepo.Create(ctx, trade); err != nil {
 		s.logger.Error("failed to save trade", zap.Error(err))
 	}
+	s.recordTradeEvent(ctx, trade)
 
 	// 同步本地持仓，保证前端能立即看到最新仓位
 	if err := s.positionService.SyncPositions(ctx); err != nil {
 		s.logger.Warn("failed to sync positions after opening position", zap.Error(err))
 	}
+	if err := s.positionService.LinkOpenTrade(ctx, trade); err != nil {
+		s.logger.Warn("failed to link open trade to position",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Error(err))
+	}
 
-	if err := s.positionService.UpdatePositionPlan(ctx, symbol, side, reason, exitPlan); err != nil {
+	if err := s.positionService.UpdatePositionPlan(ctx, symbol, side, reason, exitPlan, invalidationPrice, confidence); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("unable to record position plan, position not found after sync",
 				zap.String("symbol", symbol),
@@ -608,13 +1075,25 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 		}
 	}
 
+	if slippageExceeded {
+		if err := s.positionService.FlagEntrySlippage(ctx, symbol, side, slippage); err != nil {
+			s.logger.Error("failed to flag position entry slippage",
+				zap.String("symbol", symbol),
+				zap.Error(err))
+		}
+	}
+
 	// ⭐ 创建止损单（硬止损）
 	stopLossOrderID := int64(0)
-	if err := s.createStopLossOrder(ctx, symbol, side, executedQty, stopLossPrice); err != nil {
+	if err := s.createStopLossOrder(ctx, symbol, side, executedQty, stopLossPrice, stopExec); err != nil {
 		s.logger.Error("failed to create stop loss order",
 			zap.String("symbol", symbol),
 			zap.Float64("stop_loss_price", stopLossPrice),
 			zap.Error(err))
+		if s.conf.Trading.RequireStopOnOpen {
+			// 不允许持有无止损保护的仓位，立即回滚
+			return nil, s.rollbackUnprotectedOpen(ctx, symbol, side, executedQty, avgPrice, leverage, priorPosition, err)
+		}
 		// 不阻止开仓，但记录警告
 	} else {
 		s.logger.Info("stop loss order created",
@@ -622,9 +1101,12 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 			zap.Float64("stop_loss_price", stopLossPrice))
 	}
 
-	// ⭐ 创建止盈单（可选）
+	// ⭐ 创建止盈单（可选），指定分批止盈时按档位拆分数量
 	takeProfitOrderID := int64(0)
-	if takeProfitPrice > 0 {
+	var takeProfitLevels []LadderLevelResult
+	if len(ladder) > 0 {
+		takeProfitLevels = s.createTakeProfitLadder(ctx, symbol, side, executedQty, ladder)
+	} else if takeProfitPrice > 0 {
 		if err := s.createTakeProfitOrder(ctx, symbol, side, executedQty, takeProfitPrice); err != nil {
 			s.logger.Error("failed to create take profit order",
 				zap.String("symbol", symbol),
@@ -643,44 +1125,219 @@ func (s *AgentService) toolOpenPosition(ctx context.Context, args map[string]int
 			zap.String("symbol", symbol),
 			zap.Error(err))
 	}
+	if err := s.positionService.RecordRiskPlan(ctx, symbol, side, stopLossPrice, takeProfitPrice); err != nil {
+		s.logger.Error("failed to record position risk plan",
+			zap.String("symbol", symbol),
+			zap.Error(err))
+	}
 
-	message := fmt.Sprintf("成功开仓 %s %s，杠杆 %dx，保证金 %.2fU，价格 %.2f，止损 %.2f",
-		side, symbol, leverage, quantity, avgPrice, stopLossPrice)
-	if takeProfitPrice > 0 {
-		message += fmt.Sprintf("，止盈 %.2f", takeProfitPrice)
-	}
-
-	return map[string]interface{}{
-		"success":              true,
-		"order_id":             order.OrderID,
-		"symbol":               symbol,
-		"side":                 side,
-		"price":                avgPrice,
-		"quantity":             executedQty,
-		"leverage":             leverage,
-		"stop_loss_price":      stopLossPrice,
-		"take_profit_price":    takeProfitPrice,
-		"stop_loss_order_id":   stopLossOrderID,
-		"take_profit_order_id": takeProfitOrderID,
-		"message":              message,
-	}, nil
+	lang := req.Language
+	message := localizef(lang, "open.done", side, symbol, leverage, quantity, avgPrice, stopLossPrice)
+	if len(takeProfitLevels) > 0 {
+		message += localizef(lang, "open.take_profit_ladder", formatTakeProfitLevels(lang, takeProfitLevels))
+	} else if takeProfitPrice > 0 {
+		message += localizef(lang, "open.take_profit", takeProfitPrice)
+	}
+	if autoLeverage != nil {
+		message += localizef(lang, "open.auto_leverage",
+			autoLeverage.RequestedLeverage, autoLeverage.Leverage, autoLeverage.RiskPercent)
+	}
+	if sizeMultiplier < 1 {
+		message += localizef(lang, "open.confidence_scaled", confidence, sizeMultiplier)
+	}
+	if volatilityMargin > 0 {
+		spike := req.VolatilitySpike
+		message += localizef(lang, "open.volatility_scaled",
+			spike.Timeframe, spike.FastPeriod, spike.SlowPeriod, spike.Ratio, volatilityMargin)
+	}
+	if partialFill {
+		message += localizef(lang, "open.partial_fill", orderedQty, executedQty)
+	}
+	if slippageExceeded {
+		key := "open.slippage"
+		if stopAdjusted {
+			key = "open.slippage_stop_shifted"
+		}
+		message += localizef(lang, key, slippage, s.conf.Trading.MaxFillSlippagePercent)
+	}
+	if postFill.StopReset {
+		message += localizef(lang, "open.stop_reset", avgPrice, stopLossPrice)
+	}
+	if postFill.TakeProfitDropped {
+		message += localizef(lang, "open.take_profit_dropped", plannedTakeProfit, avgPrice)
+	}
+	if droppedLevels > 0 {
+		message += localizef(lang, "open.levels_dropped", droppedLevels, avgPrice)
+	}
+
+	// 按实际成交计算止损触发时的风险占比
+	req.Margin = quantity
+	req.Price = avgPrice
+	return newToolResult("openPosition", message, &OpenResult{
+		OrderID:            order.OrderID,
+		Symbol:             symbol,
+		Side:               side,
+		Price:              avgPrice,
+		Quantity:           executedQty,
+		OrderedQuantity:    orderedQty,
+		PartialFill:        partialFill,
+		Leverage:           leverage,
+		StopLossPrice:      stopLossPrice,
+		StopType:           string(stopExec.Type),
+		TakeProfitPrice:    takeProfitPrice,
+		InvalidationPrice:  invalidationPrice,
+		Confidence:         confidence,
+		SizeMultiplier:     sizeMultiplier,
+		SizingMode:         sizingMode,
+		RequestedQuantity:  requestedQuantity,
+		StopLossOrderID:    stopLossOrderID,
+		TakeProfitOrderID:  takeProfitOrderID,
+		ImpliedRiskPercent: impliedRiskPercent(req),
+		AutoLeverage:       autoLeverage,
+		HTFTrend:           req.HTFTrend,
+		ExpectedPrice:      price,
+		SlippagePercent:    slippage,
+		StopAdjusted:       stopAdjusted,
+		StopLossPercent:    percentStops.StopLoss,
+		TakeProfitPercent:  percentStops.TakeProfit,
+		StopReset:          postFill.StopReset,
+		TakeProfitDropped:  postFill.TakeProfitDropped,
+		TakeProfitLevels:   takeProfitLevels,
+		VolatilitySpike:    req.VolatilitySpike,
+		VolatilityMargin:   volatilityMargin,
+	}), nil
+}
+
+// rollbackUnprotectedOpen 开仓后止损单创建失败时，立即市价平掉刚开的仓位并记录本次失败。
+// priorPosition 为开仓前已存在的同方向持仓，非空时本次是加仓：只平掉加仓数量，
+// 保留原有止损止盈单并恢复原来的交易计划
+func (s *AgentService) rollbackUnprotectedOpen(ctx context.Context, symbol, side string, quantity, entryPrice float64, leverage int, priorPosition *models.Position, stopErr error) error {
+	s.logger.Warn("stop loss order failed after open, closing unprotected position",
+		zap.String("symbol", symbol),
+		zap.String("side", side),
+		zap.Float64("quantity", quantity),
+		zap.Error(stopErr))
+
+	position, posErr := s.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, symbol, side)
+
+	var order *exchange.OrderResult
+	var err error
+	if side == "long" {
+		order, err = s.exchange.CloseLongPosition(ctx, symbol, quantity)
+	} else {
+		order, err = s.exchange.CloseShortPosition(ctx, symbol, quantity)
+	}
+	if err != nil {
+		s.logger.Error("failed to close unprotected position",
+			zap.String("symbol", symbol),
+			zap.String("side", side),
+			zap.Error(err))
+		return localizeError(s.language(), "rollback.close_failed", stopErr, err, symbol)
+	}
+
+	avgPrice := order.AvgPrice
+	if avgPrice == 0 {
+		avgPrice = entryPrice
+	}
+	executedQty := order.ExecutedQty
+	if executedQty == 0 {
+		executedQty = quantity
+	}
+
+	pnl := (avgPrice - entryPrice) * executedQty
+	if side == "short" {
+		pnl = -pnl
+	}
+
+	// 计算手续费 (0.10% = 0.001)
+	feeRate := 0.001
+	fee := avgPrice * executedQty * feeRate
+
+	trade := &models.Trade{
+		ID:         ulid.Make().String(),
+		Symbol:     symbol,
+		Type:       "close",
+		Side:       side,
+		Price:      avgPrice,
+		Quantity:   executedQty,
+		Leverage:   leverage,
+		Fee:        fee,
+		Pnl:        pnl,
+		Reason:     localizef(s.language(), "rollback.trade_reason", stopErr),
+		ReasonCode: models.CloseReasonRiskManagement,
+		OrderID:    fmt.Sprintf("%d", order.OrderID),
+		Mode:       s.conf.TradingMode(),
+		ExecutedAt: time.Now(),
+	}
+
+	if posErr == nil && priorPosition != nil {
+		trade.PositionID = position.ID
+		trade.Confidence = priorPosition.Confidence
+		trade.ApplyPositionRisk(&position)
+		if err := s.positionService.RestorePositionPlan(ctx, position.ID, priorPosition); err != nil {
+			s.logger.Error("failed to restore position plan after rollback",
+				zap.String("position_id", position.ID),
+				zap.Error(err))
+		}
+	} else if posErr == nil {
+		trade.PositionID = position.ID
+		trade.Confidence = position.Confidence
+		trade.ApplyPositionRisk(&position)
+		// 取消可能已经挂出的止盈单
+		if err := s.cancelPositionStopOrders(ctx, position.ID, symbol); err != nil {
+			s.logger.Warn("failed to cancel orders of rolled back position",
+				zap.String("position_id", position.ID),
+				zap.Error(err))
+		}
+		if err := s.positionService.DeletePosition(ctx, position.ID); err != nil {
+			s.logger.Error("failed to delete rolled back position", zap.Error(err))
+		}
+	}
+
+	if err := s.TradeRepo.Create(ctx, trade); err != nil {
+		s.logger.Error("failed to save rollback trade", zap.Error(err))
+	}
+	s.recordTradeEvent(ctx, trade)
+
+	if err := s.positionService.SyncPositions(ctx); err != nil {
+		s.logger.Warn("failed to sync positions after rollback", zap.Error(err))
+	}
+
+	return localizeError(s.language(), "rollback.closed", stopErr, symbol, side, pnl)
 }
 
 // toolClosePosition 平仓
-func (s *AgentService) toolClosePosition(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+func (s *AgentService) toolClosePosition(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
 	symbol, _ := args["symbol"].(string)
-	symbol = strings.TrimSpace(symbol)
+	symbol = exchange.NormalizeSymbol(symbol)
 	reason, _ := args["reason"].(string)
 	reason = strings.TrimSpace(reason)
+	reasonCodeRaw, _ := args["reason_code"].(string)
+	reasonCode := models.CloseReasonCode(strings.TrimSpace(reasonCodeRaw))
+	execution, _ := args["execution"].(string)
+	execution = strings.TrimSpace(execution)
+	if execution == "" {
+		execution = closeExecutionImmediate
+	}
 
 	s.logger.Info("attempting to close position",
 		zap.String("symbol", symbol),
-		zap.String("reason", reason))
+		zap.String("reason_code", string(reasonCode)),
+		zap.String("reason", reason),
+		zap.String("execution", execution))
 
 	if symbol == "" {
 		return nil, fmt.Errorf("symbol is required")
 	}
 
+	if execution != closeExecutionImmediate && execution != closeExecutionTwap {
+		return nil, fmt.Errorf("invalid execution %q, must be one of %s", execution, strings.Join(closeExecutions, ", "))
+	}
+
+	if !reasonCode.Valid() {
+		return nil, localizeError(s.language(), "close.invalid_reason_code", reasonCode, closeReasonCodeList())
+	}
+
 	// 基础理由验证
 	if err := s.validateCloseReason(reason); err != nil {
 		return nil, err
@@ -713,29 +1370,106 @@ func (s *AgentService) toolClosePosition(ctx context.Context, args map[string]in
 			zap.Error(err))
 	}
 
+	if execution == closeExecutionTwap {
+		return s.closePositionTwap(ctx, targetPosition, reasonCode, reason)
+	}
+
+	trade, order, err := s.closePositionQuantity(ctx, targetPosition, targetPosition.Quantity, reasonCode, reason)
+	if errors.Is(err, exchange.ErrReduceOnlyRejected) {
+		return s.cleanupFlatPosition(ctx, targetPosition, reasonCode, reason), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pnl := trade.Pnl
+
+	if err := s.positionService.SyncPositions(ctx); err != nil {
+		s.logger.Warn("failed to sync positions after closing position", zap.Error(err))
+	}
+
+	lang := s.language()
+	message := localizef(lang, "close.done", symbol, pnl)
+	if reason != "" {
+		message += localizef(lang, "close.reason_suffix", reason)
+	}
+
+	s.logger.Info("close position successful",
+		zap.String("symbol", symbol),
+		zap.Float64("pnl", pnl),
+		zap.String("reason_code", string(reasonCode)),
+		zap.String("reason", reason))
+
+	return newToolResult("closePosition", message, &CloseResult{
+		OrderID:    order.OrderID,
+		Symbol:     symbol,
+		Pnl:        pnl,
+		Reason:     reason,
+		ReasonCode: reasonCode,
+	}), nil
+}
+
+// cleanupFlatPosition 平仓时交易所已无该持仓（快照之后被止损单或其他订单平掉），
+// 取消遗留的止损止盈单并重新同步持仓，向AI返回成功结果而不是交易所的原始错误
+func (s *AgentService) cleanupFlatPosition(ctx context.Context, position *models.Position,
+	reasonCode models.CloseReasonCode, reason string) *ToolResult {
+	s.logger.Warn("reduce-only close rejected, position already flat",
+		zap.String("symbol", position.Symbol),
+		zap.String("position_id", position.ID))
+
+	if err := s.cancelPositionStopOrders(ctx, position.ID, position.Symbol); err != nil {
+		s.logger.Error("failed to cancel orphaned stop orders",
+			zap.String("position_id", position.ID),
+			zap.Error(err))
+	}
+
+	if err := s.positionService.SyncPositions(ctx); err != nil {
+		s.logger.Warn("failed to sync positions after reduce-only rejection", zap.Error(err))
+	}
+
+	return newToolResult("closePosition", localizef(s.language(), "close.already_flat", position.Symbol), &CloseResult{
+		Symbol:      position.Symbol,
+		Reason:      reason,
+		ReasonCode:  reasonCode,
+		AlreadyFlat: true,
+	})
+}
+
+// closePositionQuantity 市价平掉持仓的指定数量并记录平仓交易
+//
+// 数量不小于持仓数量时视为全部平仓，同时取消该持仓的止损止盈单并删除持仓；部分平仓按成交数量折算盈亏，
+// 剩余持仓由下一次同步更新。调用方负责在之后同步持仓。
+func (s *AgentService) closePositionQuantity(ctx context.Context, position *models.Position, quantity float64,
+	reasonCode models.CloseReasonCode, reason string) (*models.Trade, *exchange.OrderResult, error) {
+	symbol := position.Symbol
+	fullClose := quantity >= position.Quantity
+	if fullClose {
+		quantity = position.Quantity
+	}
+
 	currentPrice, err := s.exchange.GetCurrentPrice(ctx, symbol)
 	if err != nil {
 		s.logger.Warn("failed to get current price for close position", zap.Error(err))
-		currentPrice = targetPosition.CurrentPrice
+		currentPrice = position.CurrentPrice
 	}
 
 	s.logger.Info("executing close position",
 		zap.String("symbol", symbol),
-		zap.String("side", targetPosition.Side),
-		zap.Float64("quantity", targetPosition.Quantity))
+		zap.String("side", position.Side),
+		zap.Float64("quantity", quantity),
+		zap.Bool("full_close", fullClose))
 
 	var order *exchange.OrderResult
-	if targetPosition.Side == "long" {
-		order, err = s.exchange.CloseLongPosition(ctx, symbol, targetPosition.Quantity)
+	if position.Side == "long" {
+		order, err = s.exchange.CloseLongPosition(ctx, symbol, quantity)
 	} else {
-		order, err = s.exchange.CloseShortPosition(ctx, symbol, targetPosition.Quantity)
+		order, err = s.exchange.CloseShortPosition(ctx, symbol, quantity)
 	}
 
 	if err != nil {
 		s.logger.Error("failed to execute close position",
 			zap.String("symbol", symbol),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to close position: %w", err)
+		return nil, nil, fmt.Errorf("failed to close position: %w", err)
 	}
 
 	avgPrice := order.AvgPrice
@@ -745,79 +1479,181 @@ func (s *AgentService) toolClosePosition(ctx context.Context, args map[string]in
 
 	executedQty := order.ExecutedQty
 	if executedQty == 0 {
-		executedQty = targetPosition.Quantity
+		executedQty = quantity
+	}
+
+	pnl := position.UnrealizedPnl
+	if !fullClose {
+		pnl = closedQuantityPnl(position, executedQty)
 	}
 
-	pnl := targetPosition.UnrealizedPnl
+	trade := s.recordCloseTrade(ctx, position, currentPrice, avgPrice, executedQty, pnl, order.OrderID, reasonCode, reason)
+	if fullClose {
+		s.finishFullClose(ctx, position)
+	}
+	return trade, order, nil
+}
+
+// closedQuantityPnl 按平掉的数量折算持仓的未实现盈亏，一笔市价平仓和 TWAP 分笔平仓使用同一口径
+func closedQuantityPnl(position *models.Position, quantity float64) float64 {
+	if position.Quantity <= 0 || quantity >= position.Quantity {
+		return position.UnrealizedPnl
+	}
+	return position.UnrealizedPnl * quantity / position.Quantity
+}
 
+// recordCloseTrade 记录一笔平仓交易，expectedPrice 为下单前的参考价格，用于计算滑点；保存失败只记录日志
+func (s *AgentService) recordCloseTrade(ctx context.Context, position *models.Position, expectedPrice, price, quantity, pnl float64,
+	orderID int64, reasonCode models.CloseReasonCode, reason string) *models.Trade {
 	// 计算手续费 (0.10% = 0.001)
 	feeRate := 0.001
-	notionalTraded := avgPrice * executedQty
+	notionalTraded := price * quantity
 	fee := notionalTraded * feeRate
 
 	trade := &models.Trade{
 		ID:         ulid.Make().String(),
-		Symbol:     symbol,
+		Symbol:     position.Symbol,
 		Type:       "close",
-		Side:       targetPosition.Side,
-		Price:      avgPrice,
-		Quantity:   executedQty,
-		Leverage:   targetPosition.Leverage,
+		Side:       position.Side,
+		Price:      price,
+		Quantity:   quantity,
+		Leverage:   position.Leverage,
 		Fee:        fee,
 		Pnl:        pnl,
 		Reason:     reason,
-		OrderID:    fmt.Sprintf("%d", order.OrderID),
-		PositionID: targetPosition.ID,
+		ReasonCode: reasonCode,
+		Confidence: position.Confidence,
+		OrderID:    fmt.Sprintf("%d", orderID),
+		PositionID: position.ID,
+		Mode:       s.conf.TradingMode(),
 		ExecutedAt: time.Now(),
 	}
+	trade.ApplyPositionRisk(position)
+	trade.ApplySlippage(expectedPrice)
+	if s.slippageExceeded(trade.SlippagePercent) {
+		s.logger.Warn("market close filled with excessive slippage",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.Float64("expected_price", expectedPrice),
+			zap.Float64("avg_price", price),
+			zap.Float64("slippage_percent", trade.SlippagePercent),
+			zap.Float64("max_slippage_percent", s.conf.Trading.MaxFillSlippagePercent))
+	}
 
 	if err := s.TradeRepo.Create(ctx, trade); err != nil {
 		s.logger.Error("failed to save trade", zap.Error(err))
 	}
+	s.recordTradeEvent(ctx, trade)
+	return trade
+}
 
+// slippageExceeded 不利滑点是否超过配置的上限，未配置上限时不检查
+func (s *AgentService) slippageExceeded(slippagePercent float64) bool {
+	limit := s.conf.Trading.MaxFillSlippagePercent
+	return limit > 0 && slippagePercent > limit
+}
+
+// preserveRiskStop 按实际成交价平移止损，使每单位风险仍等于按参考价计划的 |参考价-止损价|
+func preserveRiskStop(side string, expectedPrice, fillPrice, stopLoss float64) float64 {
+	risk := math.Abs(expectedPrice - stopLoss)
+	if side == "long" {
+		return fillPrice - risk
+	}
+	return fillPrice + risk
+}
+
+// finishFullClose 持仓全部平掉后取消该持仓的止损止盈单并删除持仓，失败只记录日志
+func (s *AgentService) finishFullClose(ctx context.Context, position *models.Position) {
 	// 取消该持仓的所有止损止盈订单
-	if err := s.cancelPositionStopOrders(ctx, targetPosition.ID, symbol); err != nil {
+	if err := s.cancelPositionStopOrders(ctx, position.ID, position.Symbol); err != nil {
 		s.logger.Error("failed to cancel position stop orders",
-			zap.String("position_id", targetPosition.ID),
+			zap.String("position_id", position.ID),
 			zap.Error(err))
 		// 不阻止继续执行
 	}
 
-	if err := s.positionService.DeletePosition(ctx, targetPosition.ID); err != nil {
+	if err := s.positionService.DeletePosition(ctx, position.ID); err != nil {
 		s.logger.Error("failed to delete position", zap.Error(err))
 	}
+}
 
-	if err := s.positionService.SyncPositions(ctx); err != nil {
-		s.logger.Warn("failed to sync positions after closing position", zap.Error(err))
+// ReducePosition 按比例市价减仓，按交易对步长取整后不小于持仓数量时全部平仓
+func (s *AgentService) ReducePosition(ctx context.Context, position *models.Position, fraction float64,
+	reasonCode models.CloseReasonCode, reason string) (*models.Trade, error) {
+	if fraction <= 0 {
+		return nil, fmt.Errorf("invalid reduce fraction %v", fraction)
 	}
 
-	message := fmt.Sprintf("成功平仓 %s，盈亏 $%.2f", symbol, pnl)
-	if reason != "" {
-		message += fmt.Sprintf("（理由：%s）", reason)
+	quantity := position.Quantity
+	if fraction < 1 {
+		formatted, err := s.exchange.FormatQuantity(ctx, position.Symbol, position.Quantity*fraction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format reduce quantity: %w", err)
+		}
+		if formatted <= 0 {
+			return nil, fmt.Errorf("reduce quantity of %s is below the minimum step", position.Symbol)
+		}
+		quantity = formatted
 	}
 
-	s.logger.Info("close position successful",
-		zap.String("symbol", symbol),
-		zap.Float64("pnl", pnl),
+	trade, _, err := s.closePositionQuantity(ctx, position, quantity, reasonCode, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("reduce position successful",
+		zap.String("symbol", position.Symbol),
+		zap.String("side", position.Side),
+		zap.Float64("quantity", trade.Quantity),
+		zap.Float64("pnl", trade.Pnl),
 		zap.String("reason", reason))
+	return trade, nil
+}
 
-	return map[string]interface{}{
-		"success":  true,
-		"order_id": order.OrderID,
-		"symbol":   symbol,
-		"pnl":      pnl,
-		"reason":   reason,
-		"message":  message,
-	}, nil
+// CloseAllPositions 市价平掉全部持仓，单个持仓失败不影响其它持仓，返回成功的平仓交易和汇总错误
+func (s *AgentService) CloseAllPositions(ctx context.Context, reasonCode models.CloseReasonCode, reason string) ([]*models.Trade, error) {
+	positions, err := s.positionService.GetAllPositions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var trades []*models.Trade
+	var errs []error
+	for i := range positions {
+		position := &positions[i]
+		trade, _, err := s.closePositionQuantity(ctx, position, position.Quantity, reasonCode, reason)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", position.Symbol, err))
+			continue
+		}
+		trades = append(trades, trade)
+	}
+
+	if err := s.positionService.SyncPositions(ctx); err != nil {
+		s.logger.Warn("failed to sync positions after closing all positions", zap.Error(err))
+	}
+
+	s.logger.Info("close all positions finished",
+		zap.Int("closed", len(trades)),
+		zap.Int("failed", len(errs)),
+		zap.String("reason", reason))
+	return trades, errors.Join(errs...)
 }
 
-func (s *AgentService) leverageBounds() (int, int) {
+// language 返回提示词、工具描述和校验信息使用的语言
+func (s *AgentService) language() string {
+	return normalizePromptLanguage(s.conf.LLM.PromptLanguage)
+}
+
+// leverageBounds 返回交易对允许的杠杆范围，symbolCap 表示最大杠杆来自按交易对的配置
+func (s *AgentService) leverageBounds(symbol string) (minLeverage, maxLeverage int, symbolCap bool) {
 	tradingConfig, err := s.adminConfigService.GetTradingConfig(context.Background())
 	if err != nil {
 		s.logger.Error("failed to get trading config", zap.Error(err))
+		tradingConfig = &DefaultTradingConfig
 	}
-	minLeverage := tradingConfig.MinLeverage
-	maxLeverage := tradingConfig.MaxLeverage
+	minLeverage = tradingConfig.MinLeverage
+	maxLeverage, symbolCap = tradingConfig.MaxLeverageFor(symbol)
 
 	if minLeverage <= 0 {
 		minLeverage = 1
@@ -829,18 +1665,28 @@ func (s *AgentService) leverageBounds() (int, int) {
 		maxLeverage = minLeverage
 	}
 
-	return minLeverage, maxLeverage
+	return minLeverage, maxLeverage, symbolCap
 }
 
-func (s *AgentService) validateLeverage(leverage int) bool {
-	minLeverage, maxLeverage := s.leverageBounds()
-	return leverage >= minLeverage && leverage <= maxLeverage
+// leverageRangeError 杠杆超出允许范围时的校验信息，超出交易对单独配置的上限时明确指出
+func leverageRangeError(lang, symbol string, leverage, minLeverage, maxLeverage int, symbolCap bool) error {
+	if symbolCap && leverage > maxLeverage {
+		return localizeError(lang, "rule.symbol_leverage_cap", leverage, symbol, maxLeverage)
+	}
+	return localizeError(lang, "rule.leverage_range", leverage, minLeverage, maxLeverage)
+}
+
+func (s *AgentService) validateLeverage(symbol string, leverage int) error {
+	minLeverage, maxLeverage, symbolCap := s.leverageBounds(symbol)
+	if leverage < minLeverage || leverage > maxLeverage {
+		return leverageRangeError(s.language(), symbol, leverage, minLeverage, maxLeverage, symbolCap)
+	}
+	return nil
 }
 
 func (s *AgentService) setupPositionLeverage(ctx context.Context, symbol string, leverage int) error {
-	if !s.validateLeverage(leverage) {
-		minLeverage, maxLeverage := s.leverageBounds()
-		return fmt.Errorf("leverage %d out of allowed range %d-%d", leverage, minLeverage, maxLeverage)
+	if err := s.validateLeverage(symbol, leverage); err != nil {
+		return err
 	}
 
 	if err := s.exchange.SetMarginType(ctx, symbol, exchange.MarginTypeCrossed); err != nil {
@@ -857,43 +1703,111 @@ func (s *AgentService) setupPositionLeverage(ctx context.Context, symbol string,
 	return nil
 }
 
-// validateStopPrices 验证止损止盈价格的合理性
-func (s *AgentService) validateStopPrices(currentPrice float64, side string, stopLossPrice, takeProfitPrice float64) error {
+// defaultMinNotional 获取不到交易对信息时使用的最小名义价值（币安多数USDT合约为5 USDT）
+const defaultMinNotional = 5.0
+
+// ensureMinNotional 校验开仓名义价值不低于交易对的最小名义价值
+// 不足幅度在容忍范围内时向上补足到满足要求的最小数量，否则返回明确的错误；
+// 补足后的保证金超过可用余额 availableBalance 时同样拒绝，availableBalance 小于0表示未知
+func (s *AgentService) ensureMinNotional(ctx context.Context, symbol string, coinQuantity, price, margin float64, leverage int,
+	availableBalance float64) (float64, error) {
+	minNotional := defaultMinNotional
+	stepSize := 0.0
+	info, err := s.exchange.GetSymbolInfo(ctx, symbol)
+	if err != nil {
+		s.logger.Warn("failed to get symbol info, using default min notional",
+			zap.String("symbol", symbol),
+			zap.Error(err))
+	} else {
+		if info.MinNotional > 0 {
+			minNotional = info.MinNotional
+		}
+		stepSize = info.StepSize
+	}
+
+	notional := coinQuantity * price
+	if notional >= minNotional {
+		return coinQuantity, nil
+	}
+
+	tolerance := s.conf.Trading.MinNotionalTolerancePercent
+	shortfallPercent := (minNotional - notional) / minNotional * 100
+	if shortfallPercent > tolerance {
+		return 0, localizeError(s.language(), "rule.min_notional",
+			symbol, minNotional, notional, margin, leverage, minNotional/float64(leverage))
+	}
+
+	snapped := snapQuantityToMinNotional(minNotional, price, stepSize)
+	// 开仓前检查按补足前的保证金校验可用余额，补足后需要重新确认
+	snappedMargin := snapped * price / float64(leverage)
+	if availableBalance >= 0 && snappedMargin > availableBalance {
+		return 0, localizeError(s.language(), "rule.available_margin", snappedMargin, availableBalance)
+	}
+	s.logger.Info("quantity snapped up to satisfy min notional",
+		zap.String("symbol", symbol),
+		zap.Float64("min_notional", minNotional),
+		zap.Float64("notional", notional),
+		zap.Float64("coin_quantity", coinQuantity),
+		zap.Float64("snapped_quantity", snapped))
+
+	return snapped, nil
+}
+
+// snapQuantityToMinNotional 计算满足最小名义价值的最小下单数量（按 stepSize 向上取整）
+// 预留少量余量，避免下单瞬间价格波动导致名义价值再次不足
+func snapQuantityToMinNotional(minNotional, price, stepSize float64) float64 {
+	const buffer = 1.01
+	quantity := minNotional * buffer / price
+	if stepSize <= 0 {
+		return quantity
+	}
+	return math.Ceil(quantity/stepSize) * stepSize
+}
+
+// validateStopPrices 验证止损止盈价格的合理性，lang 为错误信息的语言
+func validateStopPrices(lang string, currentPrice float64, side string, stopLossPrice, takeProfitPrice float64) error {
 	if side == "long" {
 		// 做多：止损必须低于当前价，止盈必须高于当前价
 		if stopLossPrice >= currentPrice {
-			return fmt.Errorf("做多时止损价%.2f必须低于当前价%.2f", stopLossPrice, currentPrice)
+			return localizeError(lang, "stop.long_stop_loss", stopLossPrice, currentPrice)
 		}
 		if takeProfitPrice > 0 && takeProfitPrice <= currentPrice {
-			return fmt.Errorf("做多时止盈价%.2f必须高于当前价%.2f", takeProfitPrice, currentPrice)
+			return localizeError(lang, "stop.long_take_profit", takeProfitPrice, currentPrice)
 		}
 	} else {
 		// 做空：止损必须高于当前价，止盈必须低于当前价
 		if stopLossPrice <= currentPrice {
-			return fmt.Errorf("做空时止损价%.2f必须高于当前价%.2f", stopLossPrice, currentPrice)
+			return localizeError(lang, "stop.short_stop_loss", stopLossPrice, currentPrice)
 		}
 		if takeProfitPrice > 0 && takeProfitPrice >= currentPrice {
-			return fmt.Errorf("做空时止盈价%.2f必须低于当前价%.2f", takeProfitPrice, currentPrice)
+			return localizeError(lang, "stop.short_take_profit", takeProfitPrice, currentPrice)
 		}
 	}
 	return nil
 }
 
 // createStopLossOrder 创建止损单
-func (s *AgentService) createStopLossOrder(ctx context.Context, symbol, side string, quantity, stopPrice float64) error {
-	return s.createStopLossOrderWithReason(ctx, symbol, side, quantity, stopPrice, "开仓时设置止损")
+func (s *AgentService) createStopLossOrder(ctx context.Context, symbol, side string, quantity, stopPrice float64, exec stopExecution) error {
+	return s.createStopLossOrderWithReason(ctx, symbol, side, quantity, stopPrice, "开仓时设置止损", exec)
 }
 
 // createStopLossOrderWithReason 创建止损单（带原因说明）
-func (s *AgentService) createStopLossOrderWithReason(ctx context.Context, symbol, side string, quantity, stopPrice float64, reason string) error {
+func (s *AgentService) createStopLossOrderWithReason(ctx context.Context, symbol, side string, quantity, stopPrice float64, reason string, exec stopExecution) error {
 	// 做多止损 = 卖出；做空止损 = 买入
 	stopSide := exchange.OrderSideSell
 	if side == "short" {
 		stopSide = exchange.OrderSideBuy
 	}
 
+	// 按交易对 tickSize 对齐止损价，避免因价格精度被交易所拒单
+	stopPrice = s.roundTriggerPrice(ctx, symbol, stopPrice)
+	limitPrice := exec.limitPrice(side, stopPrice)
+	if limitPrice > 0 {
+		limitPrice = s.roundTriggerPrice(ctx, symbol, limitPrice)
+	}
+
 	// 在交易所创建订单
-	orderResult, err := s.exchange.CreateStopLossOrder(ctx, symbol, stopSide, quantity, stopPrice)
+	orderResult, err := s.exchange.CreateStopLossOrder(ctx, symbol, stopSide, quantity, stopPrice, limitPrice)
 	if err != nil {
 		return err
 	}
@@ -917,6 +1831,8 @@ func (s *AgentService) createStopLossOrderWithReason(ctx context.Context, symbol
 		PositionSide: side,
 		OrderType:    models.OrderTypeStopLoss,
 		TriggerPrice: stopPrice,
+		Execution:    string(exec.Type),
+		LimitPrice:   limitPrice,
 		Quantity:     quantity,
 		ExchangeID:   fmt.Sprintf("%d", orderResult.OrderID),
 		Status:       models.OrderStatusActive,
@@ -929,10 +1845,30 @@ func (s *AgentService) createStopLossOrderWithReason(ctx context.Context, symbol
 			zap.Error(err))
 		// 不阻止订单创建
 	}
+	s.recordOrderEvent(ctx, order)
 
 	return nil
 }
 
+// roundTriggerPrice 将触发价对齐到交易对的 tickSize，获取交易对信息失败时返回原价
+func (s *AgentService) roundTriggerPrice(ctx context.Context, symbol string, price float64) float64 {
+	rounded, err := s.exchange.FormatPrice(ctx, symbol, price)
+	if err != nil || rounded <= 0 {
+		s.logger.Warn("failed to round trigger price to tick size",
+			zap.String("symbol", symbol),
+			zap.Float64("price", price),
+			zap.Error(err))
+		return price
+	}
+	if rounded != price {
+		s.logger.Debug("trigger price rounded to tick size",
+			zap.String("symbol", symbol),
+			zap.Float64("price", price),
+			zap.Float64("rounded", rounded))
+	}
+	return rounded
+}
+
 // createTakeProfitOrder 创建止盈单
 func (s *AgentService) createTakeProfitOrder(ctx context.Context, symbol, side string, quantity, takeProfitPrice float64) error {
 	return s.createTakeProfitOrderWithReason(ctx, symbol, side, quantity, takeProfitPrice, "开仓时设置止盈")
@@ -940,12 +1876,20 @@ func (s *AgentService) createTakeProfitOrder(ctx context.Context, symbol, side s
 
 // createTakeProfitOrderWithReason 创建止盈单（带原因说明）
 func (s *AgentService) createTakeProfitOrderWithReason(ctx context.Context, symbol, side string, quantity, takeProfitPrice float64, reason string) error {
+	return s.createTakeProfitOrderAtLevel(ctx, symbol, side, quantity, takeProfitPrice, reason, 0)
+}
+
+// createTakeProfitOrderAtLevel 创建止盈单，level 为分批止盈的档位，0表示普通止盈单
+func (s *AgentService) createTakeProfitOrderAtLevel(ctx context.Context, symbol, side string, quantity, takeProfitPrice float64, reason string, level int) error {
 	// 做多止盈 = 卖出；做空止盈 = 买入
 	takeProfitSide := exchange.OrderSideSell
 	if side == "short" {
 		takeProfitSide = exchange.OrderSideBuy
 	}
 
+	// 按交易对 tickSize 对齐止盈价
+	takeProfitPrice = s.roundTriggerPrice(ctx, symbol, takeProfitPrice)
+
 	// 在交易所创建订单
 	orderResult, err := s.exchange.CreateTakeProfitOrder(ctx, symbol, takeProfitSide, quantity, takeProfitPrice)
 	if err != nil {
@@ -975,6 +1919,7 @@ func (s *AgentService) createTakeProfitOrderWithReason(ctx context.Context, symb
 		ExchangeID:   fmt.Sprintf("%d", orderResult.OrderID),
 		Status:       models.OrderStatusActive,
 		Reason:       reason,
+		LadderLevel:  level,
 	}
 
 	if err := s.OrderRepo.Create(ctx, order); err != nil {
@@ -983,14 +1928,15 @@ func (s *AgentService) createTakeProfitOrderWithReason(ctx context.Context, symb
 			zap.Error(err))
 		// 不阻止订单创建
 	}
+	s.recordOrderEvent(ctx, order)
 
 	return nil
 }
 
 // toolUpdateStopOrders 更新止损止盈单
-func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
 	symbol, _ := args["symbol"].(string)
-	symbol = strings.TrimSpace(symbol)
+	symbol = exchange.NormalizeSymbol(symbol)
 	reason, _ := args["reason"].(string)
 	reason = strings.TrimSpace(reason)
 	newStopLossPrice, hasStopLoss := args["new_stop_loss_price"].(float64)
@@ -1030,6 +1976,36 @@ func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string
 		return nil, fmt.Errorf("no position found for symbol %s", symbol)
 	}
 
+	result, err := s.updatePositionStops(ctx, targetPosition, newStopLossPrice, hasStopLoss, newTakeProfitPrice, hasTakeProfit, reason, args)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("成功更新 %s 的止损止盈单", symbol)
+	if hasStopLoss && result.NewStopLoss > 0 {
+		message += fmt.Sprintf("，止损: %.2f → %.2f", result.OldStopLoss, result.NewStopLoss)
+	}
+	if hasTakeProfit {
+		if result.NewTakeProfit > 0 {
+			message += fmt.Sprintf("，止盈: %.2f → %.2f", result.OldTakeProfit, result.NewTakeProfit)
+		} else {
+			message += "，已取消止盈单"
+		}
+	}
+	message += fmt.Sprintf("（理由：%s）", reason)
+
+	return newToolResult("updateStopOrders", message, result), nil
+}
+
+// updatePositionStops 校验新的止损止盈价格并替换持仓的止损止盈单，同时更新持仓记录
+//
+// hasStopLoss/hasTakeProfit 为 false 或新止损不大于0时保持原价格，新止盈为0表示取消止盈单；
+// args 中的 stop_type、stop_limit_offset_percent 可覆盖止损执行方式。
+func (s *AgentService) updatePositionStops(ctx context.Context, targetPosition *models.Position,
+	newStopLossPrice float64, hasStopLoss bool, newTakeProfitPrice float64, hasTakeProfit bool,
+	reason string, args map[string]interface{}) (*UpdateStopsResult, error) {
+	symbol := targetPosition.Symbol
+
 	// 获取当前价格
 	currentPrice, err := s.exchange.GetCurrentPrice(ctx, symbol)
 	if err != nil {
@@ -1039,14 +2015,14 @@ func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string
 
 	// 如果提供了新止损价格，验证合理性
 	if hasStopLoss && newStopLossPrice > 0 {
-		if err := s.validateStopPrices(currentPrice, targetPosition.Side, newStopLossPrice, 0); err != nil {
+		if err := validateStopPrices(s.language(), currentPrice, targetPosition.Side, newStopLossPrice, 0); err != nil {
 			return nil, fmt.Errorf("invalid new stop loss price: %w", err)
 		}
 	}
 
 	// 如果提供了新止盈价格且不为0，验证合理性
 	if hasTakeProfit && newTakeProfitPrice > 0 {
-		if err := s.validateStopPrices(currentPrice, targetPosition.Side, 0, newTakeProfitPrice); err != nil {
+		if err := validateStopPrices(s.language(), currentPrice, targetPosition.Side, 0, newTakeProfitPrice); err != nil {
 			return nil, fmt.Errorf("invalid new take profit price: %w", err)
 		}
 	}
@@ -1060,77 +2036,50 @@ func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string
 		activeOrders = []models.Order{}
 	}
 
-	// 精准取消：只取消需要更新的订单类型
-	for i := range activeOrders {
-		order := &activeOrders[i]
-		if !order.IsActive() {
-			continue
-		}
-
-		shouldCancel := false
-		if hasStopLoss && order.IsStopLoss() {
-			shouldCancel = true
-		}
-		if hasTakeProfit && order.IsTakeProfit() {
-			shouldCancel = true
-		}
-
-		if shouldCancel && order.ExchangeID != "" {
-			// 从交易所取消订单
-			var exchangeOrderID = cast.ToInt64(order.ExchangeID)
-			if exchangeOrderID > 0 {
-				if err := s.exchange.CancelOrder(ctx, symbol, exchangeOrderID); err != nil {
-					s.logger.Warn("failed to cancel order on exchange",
-						zap.String("symbol", symbol),
-						zap.String("order_id", order.ExchangeID),
-						zap.String("order_type", string(order.OrderType)),
-						zap.Error(err))
-				} else {
-					s.logger.Info("cancelled order on exchange",
-						zap.String("symbol", symbol),
-						zap.String("order_id", order.ExchangeID),
-						zap.String("order_type", string(order.OrderType)))
-				}
-			}
-
-			// 更新数据库订单状态为已取消
-			if err := s.OrderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusCanceled); err != nil {
-				s.logger.Error("failed to update order status in database",
-					zap.String("order_id", order.ID),
-					zap.Error(err))
-			}
-		}
+	// 未指定执行方式时沿用当前止损单的设置，没有止损单时使用配置的默认值
+	stopExec, ok := stopExecutionOfOrders(activeOrders)
+	if !ok {
+		stopExec = s.defaultStopExecution()
 	}
+	stopExec = s.stopExecutionFromArgs(args, stopExec)
 
-	// 创建新的止损单
+	// 先创建新单、成功后再取消旧单，替换过程中持仓始终保留原有保护；新单创建失败时旧单保持不变
+	lang := s.language()
+	var failures []string
+	stopLossUpdated := false
 	if hasStopLoss && newStopLossPrice > 0 {
-		if err := s.createStopLossOrderWithReason(ctx, symbol, targetPosition.Side, targetPosition.Quantity, newStopLossPrice, reason); err != nil {
-			s.logger.Error("failed to create new stop loss order",
+		if err := s.replaceStopOrder(ctx, targetPosition, models.OrderTypeStopLoss, activeOrders, newStopLossPrice, reason, stopExec); err != nil {
+			s.logger.Error("failed to replace stop loss order",
 				zap.String("symbol", symbol),
 				zap.Float64("new_stop_loss_price", newStopLossPrice),
 				zap.Error(err))
-			// 不阻止继续执行，记录错误
+			if hasActiveOrder(activeOrders, models.OrderTypeStopLoss) {
+				failures = append(failures, localizef(lang, "update.sl_kept", newStopLossPrice, err, targetPosition.StopLoss))
+			} else {
+				failures = append(failures, localizef(lang, "update.sl_unprotected", newStopLossPrice, err))
+			}
+			newStopLossPrice = targetPosition.StopLoss
 		} else {
+			stopLossUpdated = true
 			s.logger.Info("new stop loss order created",
 				zap.String("symbol", symbol),
 				zap.Float64("old_stop_loss", targetPosition.StopLoss),
 				zap.Float64("new_stop_loss", newStopLossPrice))
 		}
-	} else if hasStopLoss {
-		// 如果显式传入0，表示不更新止损
-		newStopLossPrice = targetPosition.StopLoss
 	} else {
-		// 如果没有传入，保持原止损
+		// 未传入或显式传入0，保持原止损
 		newStopLossPrice = targetPosition.StopLoss
 	}
 
 	// 创建新的止盈单（0表示取消）
 	if hasTakeProfit && newTakeProfitPrice > 0 {
-		if err := s.createTakeProfitOrderWithReason(ctx, symbol, targetPosition.Side, targetPosition.Quantity, newTakeProfitPrice, reason); err != nil {
-			s.logger.Error("failed to create new take profit order",
+		if err := s.replaceStopOrder(ctx, targetPosition, models.OrderTypeTakeProfit, activeOrders, newTakeProfitPrice, reason, stopExec); err != nil {
+			s.logger.Error("failed to replace take profit order",
 				zap.String("symbol", symbol),
 				zap.Float64("new_take_profit_price", newTakeProfitPrice),
 				zap.Error(err))
+			failures = append(failures, localizef(lang, "update.tp_failed", newTakeProfitPrice, err))
+			newTakeProfitPrice = targetPosition.TakeProfit
 		} else {
 			s.logger.Info("new take profit order created",
 				zap.String("symbol", symbol),
@@ -1138,7 +2087,8 @@ func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string
 				zap.Float64("new_take_profit", newTakeProfitPrice))
 		}
 	} else if hasTakeProfit && newTakeProfitPrice == 0 {
-		// 设为0表示取消止盈单（已在CancelAllOrders中取消）
+		// 设为0表示取消止盈单
+		s.cancelOrdersOfType(ctx, symbol, activeOrders, models.OrderTypeTakeProfit)
 		s.logger.Info("take profit order cancelled",
 			zap.String("symbol", symbol),
 			zap.Float64("old_take_profit", targetPosition.TakeProfit))
@@ -1155,31 +2105,37 @@ func (s *AgentService) toolUpdateStopOrders(ctx context.Context, args map[string
 			zap.Error(err))
 	}
 
-	message := fmt.Sprintf("成功更新 %s 的止损止盈单", symbol)
-	if hasStopLoss && newStopLossPrice > 0 {
-		message += fmt.Sprintf("，止损: %.2f → %.2f", targetPosition.StopLoss, newStopLossPrice)
-	}
-	if hasTakeProfit {
-		if newTakeProfitPrice > 0 {
-			message += fmt.Sprintf("，止盈: %.2f → %.2f", targetPosition.TakeProfit, newTakeProfitPrice)
-		} else {
-			message += "，已取消止盈单"
+	if len(failures) > 0 {
+		if stopLossUpdated {
+			failures = append(failures, localizef(lang, "update.sl_done", newStopLossPrice))
 		}
+		return nil, errors.New(strings.Join(failures, localize(lang, "rule.separator")))
 	}
-	message += fmt.Sprintf("（理由：%s）", reason)
 
-	return map[string]interface{}{
-		"success":         true,
-		"symbol":          symbol,
-		"old_stop_loss":   targetPosition.StopLoss,
-		"new_stop_loss":   newStopLossPrice,
-		"old_take_profit": targetPosition.TakeProfit,
-		"new_take_profit": newTakeProfitPrice,
-		"reason":          reason,
-		"message":         message,
+	return &UpdateStopsResult{
+		Symbol:        symbol,
+		OldStopLoss:   targetPosition.StopLoss,
+		NewStopLoss:   newStopLossPrice,
+		OldTakeProfit: targetPosition.TakeProfit,
+		NewTakeProfit: newTakeProfitPrice,
+		StopType:      string(stopExec.Type),
+		Reason:        reason,
 	}, nil
 }
 
+// applySamplingParams 将配置的采样参数写入请求，未配置的参数不发送，由模型服务使用默认值
+func applySamplingParams(params *openai.ChatCompletionNewParams, conf config.LlmConf) {
+	if conf.Temperature != nil {
+		params.Temperature = openai.Float(*conf.Temperature)
+	}
+	if conf.TopP != nil {
+		params.TopP = openai.Float(*conf.TopP)
+	}
+	if conf.Seed != nil {
+		params.Seed = openai.Int(*conf.Seed)
+	}
+}
+
 // SaveDecision 保存AI决策记录，返回决策ID
 func (s *AgentService) SaveDecision(ctx context.Context, iteration int, accountValue float64, positionCount int,
 	decisionContent string, promptTokens int, completionTokens int) (string, error) {
@@ -1193,6 +2149,10 @@ func (s *AgentService) SaveDecision(ctx context.Context, iteration int, accountV
 		PromptTokens:     promptTokens,
 		CompletionTokens: completionTokens,
 		Model:            s.model,
+		Temperature:      s.conf.LLM.Temperature,
+		TopP:             s.conf.LLM.TopP,
+		Seed:             s.conf.LLM.Seed,
+		Mode:             s.conf.TradingMode(),
 		ExecutedAt:       time.Now(),
 	}
 
@@ -1204,7 +2164,9 @@ func (s *AgentService) SaveDecision(ctx context.Context, iteration int, accountV
 }
 
 // UpdateDecision 更新决策记录
-func (s *AgentService) UpdateDecision(ctx context.Context, decisionID string, decisionContent string, promptTokens int, completionTokens int) error {
+//
+// model 为实际使用的模型，为空时保留创建决策时记录的主模型。
+func (s *AgentService) UpdateDecision(ctx context.Context, decisionID string, decisionContent string, model string, promptTokens int, completionTokens int) error {
 	decision, err := s.DecisionRepo.FindById(ctx, decisionID)
 	if err != nil {
 		return err
@@ -1213,6 +2175,9 @@ func (s *AgentService) UpdateDecision(ctx context.Context, decisionID string, de
 	decision.DecisionContent = decisionContent
 	decision.PromptTokens = promptTokens
 	decision.CompletionTokens = completionTokens
+	if model != "" {
+		decision.Model = model
+	}
 
 	return s.DecisionRepo.Save(ctx, &decision)
 }
@@ -1237,6 +2202,11 @@ func (s *AgentService) GetRecentDecisions(ctx context.Context, limit int) ([]*mo
 	return result, nil
 }
 
+// GetDecisionsPage 分页获取决策记录，返回当前页和总数
+func (s *AgentService) GetDecisionsPage(ctx context.Context, page repo.Pagination) ([]models.Decision, int64, error) {
+	return s.DecisionRepo.FindDecisionsPage(ctx, page)
+}
+
 // GetRecentTrades 获取最近的交易记录
 func (s *AgentService) GetRecentTrades(ctx context.Context, limit int) ([]models.Trade, error) {
 	trades, err := s.TradeRepo.FindRecentTrades(ctx, limit)
@@ -1247,18 +2217,41 @@ func (s *AgentService) GetRecentTrades(ctx context.Context, limit int) ([]models
 	return trades, nil
 }
 
-// GetTradeStats 获取交易统计数据
-func (s *AgentService) GetTradeStats(ctx context.Context) (*repo.TradeStats, error) {
-	return s.TradeRepo.GetTradeStats(ctx)
+// GetTradesPage 分页获取交易记录，返回当前页和总数
+func (s *AgentService) GetTradesPage(ctx context.Context, page repo.Pagination) ([]models.Trade, int64, error) {
+	return s.TradeRepo.FindTradesPage(ctx, page)
 }
 
-// GetLLMLogsByDecisionID 根据决策ID获取LLM日志
-func (s *AgentService) GetLLMLogsByDecisionID(ctx context.Context, decisionID string) ([]models.LLMLog, error) {
-	logs, err := s.LLMLogRepo.FindByDecisionID(ctx, decisionID)
+// GetTradeStats 获取交易统计数据
+func (s *AgentService) GetTradeStats(ctx context.Context) (*repo.TradeStats, error) {
+	stats, err := s.TradeRepo.GetTradeStats(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return logs, nil
+
+	// 资金费不属于交易盈亏，单独统计以便解释余额变化
+	totalFunding, err := s.fundingRepo.SumAmount(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get total funding", zap.Error(err))
+	}
+	stats.TotalFunding = totalFunding
+
+	accuracy, err := s.DecisionRepo.GetDecisionAccuracy(ctx, time.Now().AddDate(0, 0, -outcomeAccuracyDays))
+	if err != nil {
+		s.logger.Warn("failed to get decision accuracy", zap.Error(err))
+	}
+	stats.DecisionAccuracy = accuracy
+	return stats, nil
+}
+
+// GetEventHistory 查询时间区间内的运行事件
+func (s *AgentService) GetEventHistory(ctx context.Context, from, to time.Time, limit int) ([]models.Event, error) {
+	return s.events.History(ctx, from, to, limit)
+}
+
+// GetLLMLogsPage 分页获取LLM日志，decisionID 为空时查询全部决策的日志
+func (s *AgentService) GetLLMLogsPage(ctx context.Context, decisionID string, page repo.Pagination) ([]models.LLMLog, int64, error) {
+	return s.LLMLogRepo.FindLogsPage(ctx, decisionID, page)
 }
 
 // MarshalJSON 用于调试
@@ -1274,11 +2267,11 @@ func (r *DecisionResult) MarshalJSON() ([]byte, error) {
 // validateCloseReason 验证平仓理由的基础格式
 func (s *AgentService) validateCloseReason(reason string) error {
 	if reason == "" {
-		return fmt.Errorf("平仓理由不能为空")
+		return localizeError(s.language(), "close.reason_required")
 	}
 
 	if len(reason) < 20 {
-		return fmt.Errorf("平仓理由过于简单（当前 %d 字符），请详细说明触发的退出条件（至少 20 字符）", len(reason))
+		return localizeError(s.language(), "close.reason_too_short", len(reason))
 	}
 
 	return nil
@@ -1306,7 +2299,7 @@ func (s *AgentService) validateExitPlanCompliance(position *models.Position, rea
 
 	// 检查理由是否包含至少一个关键词
 	if !containsAnyKeyword(reason, exitKeywords) {
-		return fmt.Errorf("平仓理由未体现退出计划的关键条件（如止损、止盈、支撑/阻力、结构破坏等）")
+		return localizeError(s.language(), "close.exit_plan_mismatch")
 	}
 
 	// 记录成功验证
@@ -1318,6 +2311,15 @@ func (s *AgentService) validateExitPlanCompliance(position *models.Position, rea
 	return nil
 }
 
+// closeReasonCodeList 以逗号分隔的平仓原因分类列表，用于错误提示
+func closeReasonCodeList() string {
+	codes := make([]string, 0, len(models.CloseReasonCodes))
+	for _, code := range models.CloseReasonCodes {
+		codes = append(codes, string(code))
+	}
+	return strings.Join(codes, ", ")
+}
+
 // containsAnyKeyword 检查文本是否包含任意一个关键词（不区分大小写）
 func containsAnyKeyword(text string, keywords []string) bool {
 	lowerText := strings.ToLower(text)
@@ -1384,10 +2386,27 @@ func (s *AgentService) cancelPositionStopOrders(ctx context.Context, positionID,
 	return nil
 }
 
+// rawLLMResponse 返回模型服务的原始响应JSON，未开启 store_raw_response 时返回空字符串
+func (s *AgentService) rawLLMResponse(resp *openai.ChatCompletion) string {
+	if !s.conf.LLM.StoreRawResponse || resp == nil {
+		return ""
+	}
+	if raw := resp.RawJSON(); raw != "" {
+		return raw
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Warn("failed to marshal raw LLM response", zap.Error(err))
+		return ""
+	}
+	return string(data)
+}
+
 // saveLLMLog 保存LLM通信日志
 func (s *AgentService) saveLLMLog(
 	ctx context.Context,
 	decisionID string,
+	model string,
 	iteration int,
 	roundNumber int,
 	systemPrompt string,
@@ -1401,6 +2420,7 @@ func (s *AgentService) saveLLMLog(
 	finishReason string,
 	duration int64,
 	errorMsg string,
+	rawResponse string,
 ) {
 	// 将消息历史序列化为JSON
 	messagesJSON, err := json.Marshal(messages)
@@ -1431,7 +2451,7 @@ func (s *AgentService) saveLLMLog(
 		DecisionID:       decisionID,
 		Iteration:        iteration,
 		RoundNumber:      roundNumber,
-		Model:            s.model,
+		Model:            model,
 		SystemPrompt:     systemPrompt,
 		UserPrompt:       userPrompt,
 		Messages:         string(messagesJSON),
@@ -1444,6 +2464,7 @@ func (s *AgentService) saveLLMLog(
 		FinishReason:     finishReason,
 		Duration:         duration,
 		Error:            errorMsg,
+		RawResponse:      rawResponse,
 		ExecutedAt:       time.Now(),
 	}
 
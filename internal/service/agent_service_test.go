@@ -0,0 +1,548 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/repo"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/glebarez/sqlite"
+	"github.com/openai/openai-go"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// newTestAgent 使用临时 SQLite 和纸钱包构造 AgentService，价格固定为 price
+func newTestAgent(t *testing.T, price float64) (*AgentService, *exchange.PaperWallet) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.Position{}, models.Trade{}, models.Order{}, models.TradingConfig{}, models.LLMLog{}, models.Event{},
+		models.AccountHistory{}, models.CapitalFlow{}, models.FundingPayment{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	wallet := exchange.NewPaperWallet(nil, 1000, logger)
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		return price, nil
+	})
+
+	positionService := NewPositionService(db, wallet, repo.NewOrderRepo(db), repo.NewTradeRepo(db), logger, &conf)
+	adminConfigService := NewAdminConfigService(logger, db)
+	accountService := NewTradingAccountService(db, wallet, adminConfigService, &conf, logger)
+	return NewAgentService(logger, db, nil, wallet, nil, positionService, accountService, adminConfigService, &conf), wallet
+}
+
+// 快照之后持仓已被止损单平掉，平仓时交易所拒绝只减仓订单，应清理遗留订单而不是把错误返回给AI
+func TestClosePositionAlreadyFlat(t *testing.T) {
+	// 纸钱包中没有持仓，模拟本地快照之后仓位已被平掉
+	agent, _ := newTestAgent(t, 95000)
+	positionService := agent.positionService
+	orderRepo := agent.OrderRepo
+
+	ctx := context.Background()
+	position := &models.Position{ID: "pos-1", Symbol: "BTCUSDT", Side: "long", Quantity: 0.01, EntryPrice: 94000, Leverage: 5}
+	if err := positionService.PositionRepo.Create(ctx, position); err != nil {
+		t.Fatalf("create position: %v", err)
+	}
+	stopOrder := &models.Order{ID: "sl-1", Symbol: "BTCUSDT", PositionID: "pos-1", PositionSide: "long",
+		OrderType: models.OrderTypeStopLoss, TriggerPrice: 93000, Quantity: 0.01, Status: models.OrderStatusActive}
+	if err := orderRepo.Create(ctx, stopOrder); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	result, err := agent.toolClosePosition(ctx, map[string]interface{}{
+		"symbol":      "BTCUSDT",
+		"reason_code": string(models.CloseReasonThesisInvalidated),
+		"reason":      "4小时结构跌破前低，做多论点失效，平仓离场",
+	})
+	if err != nil {
+		t.Fatalf("close position returned error: %v", err)
+	}
+	if !result.Success || !result.Data.(*CloseResult).AlreadyFlat {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if _, exists, err := positionService.PositionRepo.FindByIdExists(ctx, "pos-1"); err != nil || exists {
+		t.Fatalf("stale position should be removed by resync, exists=%v err=%v", exists, err)
+	}
+	order, err := orderRepo.FindById(ctx, "sl-1")
+	if err != nil {
+		t.Fatalf("find order: %v", err)
+	}
+	if order.Status != models.OrderStatusCanceled {
+		t.Fatalf("orphaned stop order status = %s, want canceled", order.Status)
+	}
+	if trades, err := agent.TradeRepo.FindAll(ctx); err != nil || len(trades) != 0 {
+		t.Fatalf("no close trade should be recorded, trades=%d err=%v", len(trades), err)
+	}
+}
+
+func TestApplySamplingParams(t *testing.T) {
+	var params openai.ChatCompletionNewParams
+	applySamplingParams(&params, config.LlmConf{})
+	if params.Temperature.Valid() || params.TopP.Valid() || params.Seed.Valid() {
+		t.Fatalf("unset sampling params should not be sent: %+v", params)
+	}
+
+	temperature, topP, seed := 0.0, 0.9, int64(42)
+	applySamplingParams(&params, config.LlmConf{Temperature: &temperature, TopP: &topP, Seed: &seed})
+	if !params.Temperature.Valid() || params.Temperature.Value != 0 {
+		t.Fatalf("temperature = %+v, want 0", params.Temperature)
+	}
+	if params.TopP.Value != 0.9 || params.Seed.Value != 42 {
+		t.Fatalf("top_p = %v, seed = %v", params.TopP.Value, params.Seed.Value)
+	}
+}
+
+// 原始响应只在开启 store_raw_response 时记录，且保留模型服务返回的全部字段
+func TestRawLLMResponse(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+	var resp openai.ChatCompletion
+	raw := `{"id":"c1","object":"chat.completion","model":"m","system_fingerprint":"fp_1","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok","refusal":null}}]}`
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if got := agent.rawLLMResponse(&resp); got != "" {
+		t.Fatalf("raw response stored while disabled: %s", got)
+	}
+	agent.conf.LLM.StoreRawResponse = true
+	if got := agent.rawLLMResponse(&resp); !strings.Contains(got, "fp_1") {
+		t.Fatalf("raw response = %s, want system fingerprint", got)
+	}
+}
+
+// 开仓记录计划盈亏比和初始风险，平仓按平仓数量计算R倍数并计入期望值
+func TestClosePositionRecordsRMultiple(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 0.1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	if err := agent.positionService.RecordRiskPlan(ctx, "BTCUSDT", "long", 95, 110); err != nil {
+		t.Fatalf("record risk plan: %v", err)
+	}
+	position, err := agent.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, "BTCUSDT", "long")
+	if err != nil {
+		t.Fatalf("find position: %v", err)
+	}
+	if position.RiskPerUnit != 5 || position.PlannedRR != 2 {
+		t.Fatalf("risk per unit = %v, planned rr = %v, want 5 and 2", position.RiskPerUnit, position.PlannedRR)
+	}
+
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		return 105, nil
+	})
+	if _, err := agent.toolClosePosition(ctx, map[string]interface{}{
+		"symbol":      "BTCUSDT",
+		"reason_code": string(models.CloseReasonTakeProfit),
+		"reason":      "到达第一目标位，落袋为安",
+	}); err != nil {
+		t.Fatalf("close position: %v", err)
+	}
+
+	trades, err := agent.GetRecentTrades(ctx, 10)
+	if err != nil {
+		t.Fatalf("recent trades: %v", err)
+	}
+	var closeTrade *models.Trade
+	for i := range trades {
+		if trades[i].Type == "close" {
+			closeTrade = &trades[i]
+		}
+	}
+	if closeTrade == nil {
+		t.Fatalf("close trade not recorded: %+v", trades)
+	}
+	if closeTrade.PlannedRR != 2 || math.Abs(closeTrade.InitialRisk-0.5) > 1e-9 ||
+		math.Abs(closeTrade.RMultiple-closeTrade.Pnl/0.5) > 1e-9 {
+		t.Fatalf("close trade = %+v, want planned rr 2 and initial risk 0.5", *closeTrade)
+	}
+
+	stats, err := agent.GetTradeStats(ctx)
+	if err != nil {
+		t.Fatalf("trade stats: %v", err)
+	}
+	if stats.RTrades != 1 || math.Abs(stats.Expectancy-closeTrade.RMultiple) > 1e-9 {
+		t.Fatalf("stats r trades = %d, expectancy = %v", stats.RTrades, stats.Expectancy)
+	}
+	if len(stats.ByPlannedRR) != 1 || stats.ByPlannedRR[0].Bucket != "2-3" {
+		t.Fatalf("by planned rr = %+v", stats.ByPlannedRR)
+	}
+}
+
+// 只平仓模式下开仓被拒绝，不会向交易所下单
+func TestOpenPositionRejectedInCloseOnly(t *testing.T) {
+	ctx := context.Background()
+	agent, wallet := newTestAgent(t, 100)
+	agent.SetCloseOnly(true)
+
+	result, err := agent.toolOpenPosition(ctx, map[string]interface{}{
+		"symbol": "BTCUSDT", "side": "long", "leverage": 5.0, "quantity": 100.0, "stop_loss_price": 95.0,
+	})
+	if err != nil {
+		t.Fatalf("open position: %v", err)
+	}
+	if result.Success || !strings.Contains(result.errorMessage(), "只平仓模式") {
+		t.Fatalf("result = %+v, want close-only rejection", result)
+	}
+	positions, err := wallet.GetPositions(ctx)
+	if err != nil || len(positions) != 0 {
+		t.Fatalf("positions = %v, err = %v, want none", positions, err)
+	}
+
+	agent.SetCloseOnly(false)
+	if agent.IsCloseOnly() {
+		t.Fatal("close-only should be disabled")
+	}
+}
+
+// 分页按执行时间倒序，执行时间相同时按ID倒序，翻页结果不重复不遗漏
+func TestHistoryPagination(t *testing.T) {
+	agent, _ := newTestAgent(t, 95000)
+	ctx := context.Background()
+
+	executedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"t1", "t2", "t3", "t4", "t5"} {
+		at := executedAt.Add(time.Duration(i) * time.Minute)
+		if id == "t4" {
+			at = executedAt.Add(2 * time.Minute) // 与 t3 同一时间
+		}
+		trade := &models.Trade{ID: id, Symbol: "BTCUSDT", Type: "open", Side: "long", ExecutedAt: at}
+		if err := agent.TradeRepo.Create(ctx, trade); err != nil {
+			t.Fatalf("create trade: %v", err)
+		}
+	}
+
+	var got []string
+	page := repo.Pagination{Page: 1, PageSize: 2}
+	for page.Page > 0 {
+		trades, total, err := agent.GetTradesPage(ctx, page)
+		if err != nil {
+			t.Fatalf("trades page %d: %v", page.Page, err)
+		}
+		if total != 5 {
+			t.Fatalf("total = %d, want 5", total)
+		}
+		for _, trade := range trades {
+			got = append(got, trade.ID)
+		}
+		page.Page = page.NextPage(total)
+	}
+	if want := []string{"t5", "t4", "t3", "t2", "t1"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("paged trades = %v, want %v", got, want)
+	}
+
+	// 指定决策时按轮次升序
+	for _, round := range []int{2, 1} {
+		log := &models.LLMLog{ID: fmt.Sprintf("log-%d", round), DecisionID: "d1", Iteration: 1, RoundNumber: round, ExecutedAt: executedAt}
+		if err := agent.LLMLogRepo.Create(ctx, log); err != nil {
+			t.Fatalf("create llm log: %v", err)
+		}
+	}
+	logs, total, err := agent.GetLLMLogsPage(ctx, "d1", repo.Pagination{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("llm logs page: %v", err)
+	}
+	if total != 2 || len(logs) != 2 || logs[0].RoundNumber != 1 || logs[1].RoundNumber != 2 {
+		t.Fatalf("llm logs = %+v (total %d), want rounds 1, 2", logs, total)
+	}
+}
+
+// stopFailExchange 在纸钱包之上模拟止损单创建失败，并记录取消的订单
+type stopFailExchange struct {
+	*exchange.PaperWallet
+	cancelled []int64
+}
+
+func (e *stopFailExchange) CreateStopLossOrder(ctx context.Context, symbol string, side exchange.OrderSide, quantity float64, stopPrice float64, limitPrice float64) (*exchange.OrderResult, error) {
+	return nil, fmt.Errorf("stop price would trigger immediately")
+}
+
+func (e *stopFailExchange) CancelOrder(ctx context.Context, symbol string, orderID int64) error {
+	e.cancelled = append(e.cancelled, orderID)
+	return nil
+}
+
+// GetOrderStatus 已挂出的止损止盈单在被取消前保持挂单状态
+func (e *stopFailExchange) GetOrderStatus(ctx context.Context, symbol string, orderID int64) (*exchange.OrderResult, error) {
+	status := "NEW"
+	if slices.Contains(e.cancelled, orderID) {
+		status = "CANCELED"
+	}
+	return &exchange.OrderResult{OrderID: orderID, Symbol: symbol, Status: status}, nil
+}
+
+func (e *stopFailExchange) FormatQuantity(ctx context.Context, symbol string, quantity float64) (float64, error) {
+	return quantity, nil
+}
+
+func (e *stopFailExchange) GetSymbolInfo(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	return &exchange.SymbolInfo{Symbol: symbol, MinNotional: 5}, nil
+}
+
+func (e *stopFailExchange) FormatPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	return price, nil
+}
+
+func newStopFailAgent(t *testing.T) (*AgentService, *stopFailExchange) {
+	t.Helper()
+	agent, wallet := newTestAgent(t, 100)
+	ex := &stopFailExchange{PaperWallet: wallet}
+	agent.exchange = ex
+	agent.positionService.exchange = ex
+	agent.accountService.exchange = ex
+	return agent, ex
+}
+
+// 开仓后止损单创建失败：立即市价平掉刚开的仓位，记录风控平仓交易，工具返回错误
+func TestOpenPositionRollsBackWithoutStop(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newStopFailAgent(t)
+
+	_, err := agent.toolOpenPosition(ctx, map[string]interface{}{
+		"symbol": "BTCUSDT", "side": "long", "leverage": 5.0, "quantity": 100.0, "stop_loss_price": 95.0,
+		"take_profit_price": 110.0, "confidence": 7.0, "invalidation_price": 96.0,
+		"reason":    "1小时级别放量突破前高，15分钟回踩确认支撑后顺势做多",
+		"exit_plan": "1小时收盘跌破95止损离场，到110前高阻力位止盈，结构破坏提前离场",
+	})
+	if err == nil || !strings.Contains(err.Error(), "止损单创建失败") {
+		t.Fatalf("err = %v, want the rollback error", err)
+	}
+
+	positions, err := ex.GetPositions(ctx)
+	if err != nil || len(positions) != 0 {
+		t.Fatalf("exchange positions = %v, err = %v, want the open rolled back", positions, err)
+	}
+	if local, _ := agent.positionService.GetAllPositions(ctx); len(local) != 0 {
+		t.Fatalf("local positions = %+v, want none", local)
+	}
+	closes := closeTrades(t, agent)
+	if len(closes) != 1 || closes[0].ReasonCode != models.CloseReasonRiskManagement || closes[0].Quantity <= 0 {
+		t.Fatalf("close trades = %+v, want one risk management close", closes)
+	}
+	// 止损失败后不再挂止盈单
+	var active int64
+	if err := agent.OrderRepo.GetDB(ctx).Model(&models.Order{}).Where("status = ?", models.OrderStatusActive).Count(&active).Error; err != nil || active != 0 {
+		t.Fatalf("active orders = %d, err = %v, want none", active, err)
+	}
+}
+
+// 回滚时持仓已有挂出的止盈单，应取消止盈单并删除持仓
+func TestRollbackUnprotectedOpenCancelsTakeProfit(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newStopFailAgent(t)
+	if err := ex.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := ex.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	if err := agent.createTakeProfitOrder(ctx, "BTCUSDT", "long", 1, 110); err != nil {
+		t.Fatalf("create take profit: %v", err)
+	}
+	position, err := agent.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, "BTCUSDT", "long")
+	if err != nil {
+		t.Fatalf("find position: %v", err)
+	}
+	orders, err := agent.OrderRepo.FindActiveByPositionID(ctx, position.ID)
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("orders = %+v, err = %v, want the take profit", orders, err)
+	}
+
+	err = agent.rollbackUnprotectedOpen(ctx, "BTCUSDT", "long", 1, 100, 5, nil, fmt.Errorf("stop rejected"))
+	if err == nil {
+		t.Fatal("rollback must return an error to the tool caller")
+	}
+	if len(ex.cancelled) != 1 || fmt.Sprintf("%d", ex.cancelled[0]) != orders[0].ExchangeID {
+		t.Fatalf("cancelled = %v, want the take profit %s", ex.cancelled, orders[0].ExchangeID)
+	}
+	if positions, _ := ex.GetPositions(ctx); len(positions) != 0 {
+		t.Fatalf("exchange positions = %v, want flat", positions)
+	}
+	closes := closeTrades(t, agent)
+	if len(closes) != 1 || closes[0].PositionID != position.ID {
+		t.Fatalf("close trades = %+v, want one close linked to %s", closes, position.ID)
+	}
+}
+
+// 加仓后止损单创建失败：只平掉加仓数量，原持仓、原止损单和原交易计划保持不变
+func TestOpenPositionRollsBackOnlyTheAdd(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newStopFailAgent(t)
+	if err := ex.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := ex.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	position, err := agent.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, "BTCUSDT", "long")
+	if err != nil {
+		t.Fatalf("find position: %v", err)
+	}
+	if err := agent.positionService.UpdatePositionPlan(ctx, "BTCUSDT", "long", "原始开仓理由", "原始退出计划", 90, 6); err != nil {
+		t.Fatalf("update plan: %v", err)
+	}
+	// 原止损单绕过失败的 CreateStopLossOrder 直接挂到纸钱包
+	placed, err := ex.PaperWallet.CreateStopLossOrder(ctx, "BTCUSDT", exchange.OrderSideSell, 1, 90, 0)
+	if err != nil {
+		t.Fatalf("place stop: %v", err)
+	}
+	stop := &models.Order{
+		ID: "stop-1", Symbol: "BTCUSDT", PositionID: position.ID, PositionSide: "long",
+		OrderType: models.OrderTypeStopLoss, TriggerPrice: 90, Quantity: 1,
+		ExchangeID: fmt.Sprintf("%d", placed.OrderID), Status: models.OrderStatusActive,
+	}
+	if err := agent.OrderRepo.Create(ctx, stop); err != nil {
+		t.Fatalf("create stop: %v", err)
+	}
+
+	_, err = agent.toolOpenPosition(ctx, map[string]interface{}{
+		"symbol": "BTCUSDT", "side": "long", "leverage": 5.0, "quantity": 100.0, "stop_loss_price": 95.0,
+		"confidence": 8.0, "invalidation_price": 96.0,
+		"reason":    "1小时级别放量突破前高，15分钟回踩确认支撑后顺势加仓",
+		"exit_plan": "1小时收盘跌破95止损离场，到110前高阻力位止盈，结构破坏提前离场",
+	})
+	if err == nil || !strings.Contains(err.Error(), "止损单创建失败") {
+		t.Fatalf("err = %v, want the rollback error", err)
+	}
+
+	positions, err := ex.GetPositions(ctx)
+	if err != nil || len(positions) != 1 || math.Abs(positions[0].PositionAmount-1) > 1e-9 {
+		t.Fatalf("exchange positions = %+v, err = %v, want the original 1 BTC", positions, err)
+	}
+	if len(ex.cancelled) != 0 {
+		t.Fatalf("cancelled = %v, want the original stop kept", ex.cancelled)
+	}
+	kept, err := agent.positionService.PositionRepo.FindActiveBySymbolAndSide(ctx, "BTCUSDT", "long")
+	if err != nil || kept.ID != position.ID {
+		t.Fatalf("position = %+v, err = %v, want %s kept", kept, err, position.ID)
+	}
+	if kept.EntryReason != "原始开仓理由" || kept.ExitPlan != "原始退出计划" || kept.InvalidationPrice != 90 || kept.Confidence != 6 {
+		t.Fatalf("position plan = %q %q %v %d, want the original plan", kept.EntryReason, kept.ExitPlan, kept.InvalidationPrice, kept.Confidence)
+	}
+	orders, err := agent.OrderRepo.FindActiveByPositionID(ctx, position.ID)
+	if err != nil || len(orders) != 1 || orders[0].ID != stop.ID {
+		t.Fatalf("active orders = %+v, err = %v, want the original stop", orders, err)
+	}
+	closes := closeTrades(t, agent)
+	if len(closes) != 1 || closes[0].PositionID != position.ID || math.Abs(closes[0].Quantity-5) > 1e-9 {
+		t.Fatalf("close trades = %+v, want one close of the added quantity", closes)
+	}
+}
+
+// minNotionalExchange 提供交易对最小名义价值和数量步长的交易所
+type minNotionalExchange struct {
+	*exchange.PaperWallet
+	minNotional float64
+	stepSize    float64
+}
+
+func (e *minNotionalExchange) GetSymbolInfo(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	return &exchange.SymbolInfo{Symbol: symbol, MinNotional: e.minNotional, StepSize: e.stepSize}, nil
+}
+
+func TestSnapQuantityToMinNotional(t *testing.T) {
+	tests := []struct {
+		name                         string
+		minNotional, price, stepSize float64
+		want                         float64
+	}{
+		{"no step size keeps the buffered quantity", 5, 100, 0, 0.0505},
+		{"rounds up to the step size", 5, 100, 0.001, 0.051},
+		{"whole step size", 5, 3, 1, 2},
+	}
+	for _, tt := range tests {
+		if got := snapQuantityToMinNotional(tt.minNotional, tt.price, tt.stepSize); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: snap = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 名义价值略低于最小值时向上补足，补足后的保证金仍需不超过可用余额
+func TestEnsureMinNotional(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	agent.exchange = &minNotionalExchange{PaperWallet: wallet, minNotional: 5, stepSize: 0.001}
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		quantity  float64
+		available float64
+		want      float64
+		wantErr   string
+	}{
+		{"above min notional", 0.06, 100, 0.06, ""},
+		{"shortfall within tolerance is snapped up", 0.0475, 100, 0.051, ""},
+		{"unknown balance is snapped up", 0.0475, -1, 0.051, ""},
+		{"shortfall beyond tolerance", 0.04, 100, 0, "最小名义价值"},
+		{"snapped margin exceeds available", 0.0475, 1, 0, "可用保证金不足"},
+	}
+	for _, tt := range tests {
+		margin := tt.quantity * 100 / 5
+		got, err := agent.ensureMinNotional(ctx, "BTCUSDT", tt.quantity, 100, margin, 5, tt.available)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: quantity = %v, err = %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// 平仓结果消息按提示词语言输出
+func TestClosePositionMessageLocalized(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	agent.conf.LLM.PromptLanguage = PromptLanguageEn
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 0.1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+
+	result, err := agent.toolClosePosition(ctx, map[string]interface{}{
+		"symbol":      "BTCUSDT",
+		"reason_code": string(models.CloseReasonTakeProfit),
+		"reason":      "reached the first target at the 1h resistance level",
+	})
+	if err != nil {
+		t.Fatalf("close position: %v", err)
+	}
+	if !strings.HasPrefix(result.Message, "closed BTCUSDT") || !strings.Contains(result.Message, "(reason: reached the first target") {
+		t.Fatalf("message = %q, want the english close message", result.Message)
+	}
+}
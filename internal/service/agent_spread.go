@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+// bookTickerTTL 盘口缓存时间，同一次决策内对同一交易对的多次开仓检查复用盘口，避免重复请求
+const bookTickerTTL = 10 * time.Second
+
+type cachedBookTicker struct {
+	ticker    *exchange.BookTicker
+	fetchedAt time.Time
+}
+
+// bookTickerCache 按交易对短暂缓存盘口，零值可直接使用
+type bookTickerCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedBookTicker
+}
+
+// bookTicker 获取交易对的盘口最优买卖价，缓存未过期时直接返回
+func (s *AgentService) bookTicker(ctx context.Context, symbol string) (*exchange.BookTicker, error) {
+	cache := &s.bookTickers
+	now := time.Now()
+
+	cache.mu.Lock()
+	if entry, ok := cache.entries[symbol]; ok && now.Sub(entry.fetchedAt) < bookTickerTTL {
+		cache.mu.Unlock()
+		return entry.ticker, nil
+	}
+	cache.mu.Unlock()
+
+	ticker, err := s.exchange.GetBookTicker(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	if cache.entries == nil {
+		cache.entries = make(map[string]cachedBookTicker)
+	}
+	cache.entries[symbol] = cachedBookTicker{ticker: ticker, fetchedAt: now}
+	cache.mu.Unlock()
+	return ticker, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+// percentStops openPosition 以百分比给出的止损止盈距离
+//
+// 百分比为价格相对开仓价的距离（不含杠杆），由服务端按方向换算为绝对价格，
+// 避免 AI 自行计算时把止损止盈放错方向或算错量级。
+type percentStops struct {
+	StopLoss   float64 // 止损距离（%），0表示按 stop_loss_price 设置
+	TakeProfit float64 // 止盈距离（%），0表示按 take_profit_price 设置
+}
+
+// parsePercentStops 解析 stop_loss_percent 和 take_profit_percent
+//
+// 同一项同时给出价格和百分比时无法判断以哪个为准，直接拒绝；百分比需大于0且小于100。
+func parsePercentStops(lang string, args map[string]interface{}) (percentStops, error) {
+	var stops percentStops
+	for _, item := range []struct {
+		percentKey string
+		priceKey   string
+		target     *float64
+	}{
+		{"stop_loss_percent", "stop_loss_price", &stops.StopLoss},
+		{"take_profit_percent", "take_profit_price", &stops.TakeProfit},
+	} {
+		percent, ok := args[item.percentKey].(float64)
+		if !ok || percent == 0 {
+			continue
+		}
+		if price, _ := args[item.priceKey].(float64); price > 0 {
+			return percentStops{}, localizeError(lang, "stop.percent_ambiguous", item.priceKey, item.percentKey)
+		}
+		if percent <= 0 || percent >= 100 {
+			return percentStops{}, localizeError(lang, "stop.percent_invalid", item.percentKey, percent)
+		}
+		*item.target = percent
+	}
+	return stops, nil
+}
+
+// apply 按参考价格和持仓方向把百分比换算为止损止盈价格，未按百分比设置的一项保持原价格
+func (p percentStops) apply(side string, price, stopLoss, takeProfit float64) (float64, float64) {
+	direction := 1.0
+	if side == "short" {
+		direction = -1
+	}
+	if p.StopLoss > 0 {
+		stopLoss = price * (1 - direction*p.StopLoss/100)
+	}
+	if p.TakeProfit > 0 {
+		takeProfit = price * (1 + direction*p.TakeProfit/100)
+	}
+	return stopLoss, takeProfit
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPercentStops(t *testing.T) {
+	stops, err := parsePercentStops("zh", map[string]interface{}{"stop_loss_percent": 2.0, "take_profit_percent": 6.0})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	sl, tp := stops.apply("long", 100, 0, 0)
+	if math.Abs(sl-98) > 1e-9 || math.Abs(tp-106) > 1e-9 {
+		t.Fatalf("long stops = %v / %v, want 98 / 106", sl, tp)
+	}
+	sl, tp = stops.apply("short", 100, 0, 0)
+	if math.Abs(sl-102) > 1e-9 || math.Abs(tp-94) > 1e-9 {
+		t.Fatalf("short stops = %v / %v, want 102 / 94", sl, tp)
+	}
+
+	// 只按百分比设置止损时保留给出的止盈价格
+	stops, err = parsePercentStops("zh", map[string]interface{}{"stop_loss_percent": 1.5, "take_profit_price": 110.0})
+	if err != nil {
+		t.Fatalf("parse stop only: %v", err)
+	}
+	if sl, tp := stops.apply("long", 100, 0, 110); math.Abs(sl-98.5) > 1e-9 || tp != 110 {
+		t.Fatalf("stop only = %v / %v", sl, tp)
+	}
+
+	if _, err := parsePercentStops("zh", map[string]interface{}{"stop_loss_percent": 2.0, "stop_loss_price": 95.0}); err == nil || !strings.Contains(err.Error(), "不能同时设置") {
+		t.Fatalf("ambiguous stop err = %v", err)
+	}
+	for _, percent := range []float64{-1, 100} {
+		if _, err := parsePercentStops("zh", map[string]interface{}{"take_profit_percent": percent}); err == nil {
+			t.Fatalf("take_profit_percent %v should be rejected", percent)
+		}
+	}
+}
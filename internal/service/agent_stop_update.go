@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+)
+
+// stopOrderRetryDelay 止损/止盈单创建失败后重试前的等待时间
+const stopOrderRetryDelay = 500 * time.Millisecond
+
+// stopExecution 止损单的执行方式
+type stopExecution struct {
+	Type               exchange.StopExecutionType
+	LimitOffsetPercent float64 // 限价止损的限价相对触发价的偏移（%）
+}
+
+// newStopExecution 解析止损执行方式，无法识别或限价偏移无效时回退为市价止损
+func newStopExecution(execType string, limitOffsetPercent float64) stopExecution {
+	if exchange.StopExecutionType(strings.ToLower(strings.TrimSpace(execType))) == exchange.StopExecutionLimit && limitOffsetPercent > 0 {
+		return stopExecution{Type: exchange.StopExecutionLimit, LimitOffsetPercent: limitOffsetPercent}
+	}
+	return stopExecution{Type: exchange.StopExecutionMarket}
+}
+
+// limitPrice 计算限价止损的限价：做多止损（卖出）低于触发价，做空止损（买入）高于触发价；市价止损返回0
+func (e stopExecution) limitPrice(side string, stopPrice float64) float64 {
+	if e.Type != exchange.StopExecutionLimit {
+		return 0
+	}
+	if side == "short" {
+		return stopPrice * (1 + e.LimitOffsetPercent/100)
+	}
+	return stopPrice * (1 - e.LimitOffsetPercent/100)
+}
+
+// defaultStopExecution 配置的默认止损执行方式
+func (s *AgentService) defaultStopExecution() stopExecution {
+	return newStopExecution(s.conf.Trading.StopExecution, s.conf.Trading.StopLimitOffsetPercent)
+}
+
+// stopExecutionFromArgs 读取工具参数 stop_type 和 stop_limit_offset_percent，未提供的部分沿用 fallback
+func (s *AgentService) stopExecutionFromArgs(args map[string]interface{}, fallback stopExecution) stopExecution {
+	execType, _ := args["stop_type"].(string)
+	if strings.TrimSpace(execType) == "" {
+		execType = string(fallback.Type)
+	}
+	offset, _ := args["stop_limit_offset_percent"].(float64)
+	if offset <= 0 {
+		offset = fallback.LimitOffsetPercent
+	}
+	if offset <= 0 {
+		offset = s.conf.Trading.StopLimitOffsetPercent
+	}
+	return newStopExecution(execType, offset)
+}
+
+// stopExecutionOfOrders 还原持仓当前止损单的执行方式，用于更新止损时沿用原设置；没有活跃止损单时返回 false
+func stopExecutionOfOrders(orders []models.Order) (stopExecution, bool) {
+	for _, order := range orders {
+		if !order.IsActive() || !order.IsStopLoss() {
+			continue
+		}
+		if order.Execution == string(exchange.StopExecutionLimit) && order.LimitPrice > 0 && order.TriggerPrice > 0 {
+			offset := math.Abs(order.LimitPrice-order.TriggerPrice) / order.TriggerPrice * 100
+			return newStopExecution(order.Execution, offset), true
+		}
+		return stopExecution{Type: exchange.StopExecutionMarket}, true
+	}
+	return stopExecution{}, false
+}
+
+// replaceStopOrder 替换持仓的止损或止盈单：先创建新单，成功后再取消同类型的旧单
+//
+// 止损止盈单均为只减仓的条件单，新旧单短暂共存不会导致反向开仓。新单创建失败时按配置重试，
+// 仍失败则返回错误且不取消旧单，保证持仓的原有保护不受影响。exec 仅对止损单生效。
+func (s *AgentService) replaceStopOrder(ctx context.Context, position *models.Position, orderType models.OrderType,
+	oldOrders []models.Order, price float64, reason string, exec stopExecution) error {
+	create := func() error {
+		if orderType == models.OrderTypeTakeProfit {
+			return s.createTakeProfitOrderWithReason(ctx, position.Symbol, position.Side, position.Quantity, price, reason)
+		}
+		return s.createStopLossOrderWithReason(ctx, position.Symbol, position.Side, position.Quantity, price, reason, exec)
+	}
+
+	retries := max(s.conf.Trading.StopOrderRetries, 0)
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			s.logger.Warn("retrying stop order creation",
+				zap.String("symbol", position.Symbol),
+				zap.String("order_type", string(orderType)),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(stopOrderRetryDelay):
+			}
+		}
+		if err = create(); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s.cancelOrdersOfType(ctx, position.Symbol, oldOrders, orderType)
+	return nil
+}
+
+// cancelOrdersOfType 取消列表中指定类型的活跃订单，并将数据库中的订单标记为已取消
+func (s *AgentService) cancelOrdersOfType(ctx context.Context, symbol string, orders []models.Order, orderType models.OrderType) {
+	for i := range orders {
+		order := &orders[i]
+		if !order.IsActive() || order.OrderType != orderType {
+			continue
+		}
+
+		if exchangeOrderID := cast.ToInt64(order.ExchangeID); exchangeOrderID > 0 {
+			if err := s.exchange.CancelOrder(ctx, symbol, exchangeOrderID); err != nil {
+				s.logger.Warn("failed to cancel order on exchange",
+					zap.String("symbol", symbol),
+					zap.String("order_id", order.ExchangeID),
+					zap.String("order_type", string(order.OrderType)),
+					zap.Error(err))
+			} else {
+				s.logger.Info("cancelled order on exchange",
+					zap.String("symbol", symbol),
+					zap.String("order_id", order.ExchangeID),
+					zap.String("order_type", string(order.OrderType)))
+			}
+		}
+
+		if err := s.OrderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusCanceled); err != nil {
+			s.logger.Error("failed to update order status in database",
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+		}
+	}
+}
+
+// hasActiveOrder 列表中是否存在指定类型的活跃订单
+func hasActiveOrder(orders []models.Order, orderType models.OrderType) bool {
+	for i := range orders {
+		if orders[i].IsActive() && orders[i].OrderType == orderType {
+			return true
+		}
+	}
+	return false
+}
+
+// manualStopReason 人工调整止损止盈未填写理由时记录的理由
+const manualStopReason = "人工调整止损止盈"
+
+// ManualStopUpdate 人工调整止损止盈的请求，字段为 nil 时保持原价格
+type ManualStopUpdate struct {
+	StopLoss               *float64 `json:"stop_loss"`                 // 新止损价，必须大于0
+	TakeProfit             *float64 `json:"take_profit"`               // 新止盈价，0表示取消止盈单
+	StopType               string   `json:"stop_type"`                 // 止损执行方式 market/limit，为空时沿用当前止损单
+	StopLimitOffsetPercent float64  `json:"stop_limit_offset_percent"` // 限价止损的偏移（%）
+	Reason                 string   `json:"reason"`
+}
+
+// UpdatePositionStopsManually 人工调整持仓的止损止盈，与 updateStopOrders 工具使用相同的校验和替换流程
+//
+// 持仓不存在时返回 gorm.ErrRecordNotFound。
+func (s *AgentService) UpdatePositionStopsManually(ctx context.Context, positionID string, update ManualStopUpdate) (*UpdateStopsResult, error) {
+	if update.StopLoss == nil && update.TakeProfit == nil {
+		return nil, fmt.Errorf("must provide at least one of stop_loss or take_profit")
+	}
+	if update.StopLoss != nil && *update.StopLoss <= 0 {
+		return nil, fmt.Errorf("stop_loss must be positive")
+	}
+	if update.TakeProfit != nil && *update.TakeProfit < 0 {
+		return nil, fmt.Errorf("take_profit must not be negative")
+	}
+
+	position, err := s.positionService.GetPosition(ctx, positionID)
+	if err != nil {
+		return nil, err
+	}
+	if position.IsFrozen() {
+		return nil, fmt.Errorf("position %s is frozen (symbol status %s)", position.Symbol, position.DelistedStatus)
+	}
+
+	reason := strings.TrimSpace(update.Reason)
+	if reason == "" {
+		reason = manualStopReason
+	}
+	args := map[string]interface{}{
+		"stop_type":                 update.StopType,
+		"stop_limit_offset_percent": update.StopLimitOffsetPercent,
+	}
+
+	var stopLoss, takeProfit float64
+	if update.StopLoss != nil {
+		stopLoss = *update.StopLoss
+	}
+	if update.TakeProfit != nil {
+		takeProfit = *update.TakeProfit
+	}
+
+	s.logger.Info("manually updating stop orders",
+		zap.String("position_id", positionID),
+		zap.String("symbol", position.Symbol),
+		zap.Float64("new_stop_loss", stopLoss),
+		zap.Float64("new_take_profit", takeProfit),
+		zap.String("reason", reason))
+
+	result, err := s.updatePositionStops(ctx, position, stopLoss, update.StopLoss != nil,
+		takeProfit, update.TakeProfit != nil, reason, args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("manual stop update successful",
+		zap.String("symbol", position.Symbol),
+		zap.Float64("old_stop_loss", result.OldStopLoss),
+		zap.Float64("new_stop_loss", result.NewStopLoss),
+		zap.Float64("old_take_profit", result.OldTakeProfit),
+		zap.Float64("new_take_profit", result.NewTakeProfit))
+	return result, nil
+}
+
+// breakevenStopReason 系统自动移动保本止损时订单记录的理由
+const breakevenStopReason = "自动移动止损至盈亏平衡"
+
+// needsBreakevenStop 持仓盈亏%达到触发值且止损仍在开仓价不利一侧（或未设置止损）时返回 true，triggerPercent 不大于0时不启用
+func needsBreakevenStop(pos *models.Position, triggerPercent float64) bool {
+	if triggerPercent <= 0 || pos.EntryPrice <= 0 || pos.IsFrozen() || pos.CalculatePnlPercent() < triggerPercent {
+		return false
+	}
+	return pos.StopLoss <= 0 || stopWorseThanEntry(pos.Side, pos.EntryPrice, pos.StopLoss)
+}
+
+// stopWorseThanEntry 止损是否仍在开仓价的不利一侧（做多低于开仓价、做空高于开仓价）
+func stopWorseThanEntry(side string, entryPrice, stopLoss float64) bool {
+	if side == "short" {
+		return stopLoss > entryPrice
+	}
+	return stopLoss < entryPrice
+}
+
+// breakevenStopPrice 保本止损价：开仓价向有利方向偏移 offsetTicks 个最小价格变动单位
+func breakevenStopPrice(side string, entryPrice, tickSize float64, offsetTicks int) float64 {
+	offset := tickSize * float64(max(offsetTicks, 0))
+	if side == "short" {
+		return entryPrice - offset
+	}
+	return entryPrice + offset
+}
+
+// MoveStopToBreakeven 把持仓止损移到开仓价（加配置的偏移），与 updateStopOrders 使用相同的替换流程
+func (s *AgentService) MoveStopToBreakeven(ctx context.Context, position *models.Position, offsetTicks int) (*UpdateStopsResult, error) {
+	stopPrice := s.breakevenPrice(ctx, position, offsetTicks)
+
+	s.logger.Info("moving stop loss to breakeven",
+		zap.String("symbol", position.Symbol),
+		zap.String("side", position.Side),
+		zap.Float64("entry_price", position.EntryPrice),
+		zap.Float64("old_stop_loss", position.StopLoss),
+		zap.Float64("new_stop_loss", stopPrice))
+	return s.updatePositionStops(ctx, position, stopPrice, true, 0, false, breakevenStopReason, nil)
+}
+
+// breakevenPrice 持仓的保本止损价，获取不到交易对的最小价格变动单位时不加偏移
+func (s *AgentService) breakevenPrice(ctx context.Context, position *models.Position, offsetTicks int) float64 {
+	tickSize := 0.0
+	if offsetTicks > 0 {
+		if info, err := s.exchange.GetSymbolInfo(ctx, position.Symbol); err != nil {
+			s.logger.Warn("failed to get symbol info for breakeven offset, move stop to entry",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+		} else {
+			tickSize = info.TickSize
+		}
+	}
+	return breakevenStopPrice(position.Side, position.EntryPrice, tickSize, offsetTicks)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"gorm.io/gorm"
+)
+
+// flakyStopExchange 前 failures 次创建止损单失败的交易所
+type flakyStopExchange struct {
+	exchange.Exchange
+	failures   int
+	calls      int
+	limitPrice float64 // 最近一次创建止损单的限价
+}
+
+func (e *flakyStopExchange) CreateStopLossOrder(ctx context.Context, symbol string, side exchange.OrderSide, quantity float64, stopPrice float64, limitPrice float64) (*exchange.OrderResult, error) {
+	e.calls++
+	e.limitPrice = limitPrice
+	if e.calls <= e.failures {
+		return nil, errors.New("binance server error (HTTP 503)")
+	}
+	return e.Exchange.CreateStopLossOrder(ctx, symbol, side, quantity, stopPrice, limitPrice)
+}
+
+// FormatPrice 纸钱包没有交易对精度信息，按原价返回
+func (e *flakyStopExchange) FormatPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	return price, nil
+}
+
+// openTestPositionWithStop 在纸钱包开多仓、同步持仓并设置初始止损，返回之后可注入失败的交易所
+func openTestPositionWithStop(t *testing.T, agent *AgentService, wallet *exchange.PaperWallet, stopPrice float64) *flakyStopExchange {
+	t.Helper()
+	flaky := &flakyStopExchange{Exchange: wallet}
+	agent.exchange = flaky
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 0.01); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	if err := agent.createStopLossOrder(ctx, "BTCUSDT", "long", 0.01, stopPrice, agent.defaultStopExecution()); err != nil {
+		t.Fatalf("create stop loss: %v", err)
+	}
+	flaky.calls = 0
+	return flaky
+}
+
+func activeStopLosses(t *testing.T, agent *AgentService) []models.Order {
+	t.Helper()
+	positions, err := agent.positionService.GetAllPositions(context.Background())
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	orders, err := agent.OrderRepo.FindByPositionID(context.Background(), positions[0].ID)
+	if err != nil {
+		t.Fatalf("find orders: %v", err)
+	}
+	var stops []models.Order
+	for _, order := range orders {
+		if order.IsActive() && order.OrderType == models.OrderTypeStopLoss {
+			stops = append(stops, order)
+		}
+	}
+	return stops
+}
+
+// 新止损单创建失败时不能取消旧止损单，持仓要保留原有保护
+func TestUpdateStopOrdersKeepsOldStopOnFailure(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	agent.conf.Trading.StopOrderRetries = 0
+	openTestPositionWithStop(t, agent, wallet, 95).failures = 1
+
+	_, err := agent.toolUpdateStopOrders(context.Background(), map[string]interface{}{
+		"symbol":              "BTCUSDT",
+		"new_stop_loss_price": 98.0,
+		"reason":              "价格站稳100上方，上移止损锁定部分利润",
+	})
+	if err == nil || !strings.Contains(err.Error(), "原止损单") {
+		t.Fatalf("err = %v, want old stop kept", err)
+	}
+
+	stops := activeStopLosses(t, agent)
+	if len(stops) != 1 || stops[0].TriggerPrice != 95 {
+		t.Fatalf("active stops = %+v, want the original 95 stop", stops)
+	}
+}
+
+// 新止损单重试成功后才取消旧止损单
+func TestUpdateStopOrdersRetriesBeforeCancel(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	agent.conf.Trading.StopOrderRetries = 1
+	flaky := openTestPositionWithStop(t, agent, wallet, 95)
+	flaky.failures = 1
+
+	result, err := agent.toolUpdateStopOrders(context.Background(), map[string]interface{}{
+		"symbol":              "BTCUSDT",
+		"new_stop_loss_price": 98.0,
+		"reason":              "价格站稳100上方，上移止损锁定部分利润",
+	})
+	if err != nil {
+		t.Fatalf("update stop orders: %v", err)
+	}
+	if result.Data.(*UpdateStopsResult).NewStopLoss != 98.0 || flaky.calls != 2 {
+		t.Fatalf("result = %v, calls = %d", result, flaky.calls)
+	}
+
+	stops := activeStopLosses(t, agent)
+	if len(stops) != 1 || stops[0].TriggerPrice != 98 {
+		t.Fatalf("active stops = %+v, want only the new 98 stop", stops)
+	}
+}
+
+// 限价止损按偏移计算限价并记录到订单，更新止损时未指定执行方式则沿用原设置
+func TestStopLimitExecution(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	agent.conf.Trading.StopExecution = "limit"
+	agent.conf.Trading.StopLimitOffsetPercent = 1
+	flaky := openTestPositionWithStop(t, agent, wallet, 95)
+
+	stops := activeStopLosses(t, agent)
+	if len(stops) != 1 || stops[0].Execution != "limit" || math.Abs(stops[0].LimitPrice-94.05) > 1e-9 {
+		t.Fatalf("active stops = %+v, want limit stop at 94.05", stops)
+	}
+
+	agent.conf.Trading.StopExecution = "market"
+	if _, err := agent.toolUpdateStopOrders(context.Background(), map[string]interface{}{
+		"symbol":              "BTCUSDT",
+		"new_stop_loss_price": 98.0,
+		"reason":              "价格站稳100上方，上移止损锁定部分利润",
+	}); err != nil {
+		t.Fatalf("update stop orders: %v", err)
+	}
+	if math.Abs(flaky.limitPrice-97.02) > 1e-9 {
+		t.Fatalf("limit price = %v, want 97.02 inherited from the old stop", flaky.limitPrice)
+	}
+
+	if _, err := agent.toolUpdateStopOrders(context.Background(), map[string]interface{}{
+		"symbol":              "BTCUSDT",
+		"new_stop_loss_price": 99.0,
+		"stop_type":           "market",
+		"reason":              "流动性恢复，改回市价止损",
+	}); err != nil {
+		t.Fatalf("update stop orders: %v", err)
+	}
+	stops = activeStopLosses(t, agent)
+	if flaky.limitPrice != 0 || len(stops) != 1 || stops[0].Execution != "market" || stops[0].LimitPrice != 0 {
+		t.Fatalf("limit price = %v, stops = %+v, want a market stop", flaky.limitPrice, stops)
+	}
+}
+
+// 人工调整止损与工具使用相同的替换流程，并同步更新持仓记录
+func TestUpdatePositionStopsManually(t *testing.T) {
+	agent, wallet := newTestAgent(t, 100)
+	openTestPositionWithStop(t, agent, wallet, 95)
+	ctx := context.Background()
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	positionID := positions[0].ID
+	if err := agent.positionService.UpdateStopPrices(ctx, "BTCUSDT", "long", 95, 0); err != nil {
+		t.Fatalf("update stop prices: %v", err)
+	}
+
+	if _, err := agent.UpdatePositionStopsManually(ctx, positionID, ManualStopUpdate{}); err == nil {
+		t.Fatal("empty update should be rejected")
+	}
+	invalid := 101.0 // 多单止损不能高于当前价格
+	if _, err := agent.UpdatePositionStopsManually(ctx, positionID, ManualStopUpdate{StopLoss: &invalid}); err == nil {
+		t.Fatal("stop above price should be rejected")
+	}
+	newStop := 97.0
+	if _, err := agent.UpdatePositionStopsManually(ctx, "missing", ManualStopUpdate{StopLoss: &newStop}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("missing position err = %v", err)
+	}
+
+	result, err := agent.UpdatePositionStopsManually(ctx, positionID, ManualStopUpdate{StopLoss: &newStop})
+	if err != nil {
+		t.Fatalf("manual update: %v", err)
+	}
+	if result.OldStopLoss != 95 || result.NewStopLoss != 97 || result.Reason != manualStopReason {
+		t.Fatalf("result = %+v", result)
+	}
+	stops := activeStopLosses(t, agent)
+	if len(stops) != 1 || stops[0].TriggerPrice != 97 {
+		t.Fatalf("active stops = %+v, want only the new 97 stop", stops)
+	}
+	position, err := agent.positionService.GetPosition(ctx, positionID)
+	if err != nil || position.StopLoss != 97 {
+		t.Fatalf("position stop = %v, err = %v", position, err)
+	}
+}
+
+// tickSizeExchange 提供交易对最小价格变动单位的交易所
+type tickSizeExchange struct {
+	*flakyStopExchange
+	tickSize float64
+}
+
+func (e *tickSizeExchange) GetSymbolInfo(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	return &exchange.SymbolInfo{Symbol: symbol, TickSize: e.tickSize}, nil
+}
+
+func TestMoveStopToBreakeven(t *testing.T) {
+	long := &models.Position{Side: "long", EntryPrice: 100, CurrentPrice: 104, Leverage: 5, StopLoss: 95}
+	if !needsBreakevenStop(long, 10) {
+		t.Fatal("long up 20% with stop below entry should move to breakeven")
+	}
+	if needsBreakevenStop(long, 25) || needsBreakevenStop(long, 0) {
+		t.Fatal("trigger not reached or disabled should not move")
+	}
+	moved := *long
+	moved.StopLoss = 100
+	if needsBreakevenStop(&moved, 10) {
+		t.Fatal("stop already at entry should not move again")
+	}
+	short := &models.Position{Side: "short", EntryPrice: 100, CurrentPrice: 96, Leverage: 5, StopLoss: 105}
+	if !needsBreakevenStop(short, 10) || breakevenStopPrice("short", 100, 0.5, 2) != 99 {
+		t.Fatal("short breakeven should move the stop below entry")
+	}
+
+	agent, wallet := newTestAgent(t, 100)
+	flaky := openTestPositionWithStop(t, agent, wallet, 95)
+	agent.exchange = &tickSizeExchange{flakyStopExchange: flaky, tickSize: 0.5}
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		return 104, nil
+	})
+	ctx := context.Background()
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+
+	result, err := agent.MoveStopToBreakeven(ctx, &positions[0], 2)
+	if err != nil {
+		t.Fatalf("move stop to breakeven: %v", err)
+	}
+	if result.NewStopLoss != 101 || result.Reason != breakevenStopReason {
+		t.Fatalf("result = %+v, want stop 101", result)
+	}
+	stops := activeStopLosses(t, agent)
+	if len(stops) != 1 || stops[0].TriggerPrice != 101 || stops[0].Reason != breakevenStopReason {
+		t.Fatalf("active stops = %+v, want only the breakeven stop", stops)
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// maxTakeProfitLevels 分批止盈最多的档位数
+const maxTakeProfitLevels = 5
+
+// 分批止盈成交后调整剩余仓位止损时订单记录的理由
+const (
+	ladderStopReason          = "分批止盈成交后按剩余仓位调整止损"
+	ladderBreakevenStopReason = "分批止盈成交后按剩余仓位调整止损并移至盈亏平衡"
+)
+
+// takeProfitLevel 分批止盈的一档
+type takeProfitLevel struct {
+	Price             float64 // 止盈价，按百分比给出时由参考价格换算
+	Percent           float64 // 止盈距开仓价的百分比，0表示按 Price 设置
+	AllocationPercent float64 // 该档平掉的仓位占开仓数量的比例（%）
+}
+
+// takeProfitLadder openPosition 的分批止盈计划，按距开仓价由近到远排列
+//
+// 各档分配比例合计不足100%时，剩余部分不设止盈，作为跟踪仓位由止损或后续决策平仓。
+type takeProfitLadder []takeProfitLevel
+
+// parseTakeProfitLadder 解析 take_profit_levels
+//
+// 不能与 take_profit_price / take_profit_percent 同时使用；每档需给出 price 或 percent 之一，
+// allocation_percent 大于0且合计不超过100。
+func parseTakeProfitLadder(lang string, args map[string]interface{}) (takeProfitLadder, error) {
+	raw, _ := args["take_profit_levels"].([]interface{})
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	price, _ := args["take_profit_price"].(float64)
+	percent, _ := args["take_profit_percent"].(float64)
+	if price > 0 || percent != 0 {
+		return nil, localizeError(lang, "ladder.conflict")
+	}
+	if len(raw) > maxTakeProfitLevels {
+		return nil, localizeError(lang, "ladder.too_many", maxTakeProfitLevels, len(raw))
+	}
+
+	ladder := make(takeProfitLadder, 0, len(raw))
+	total := 0.0
+	for i, item := range raw {
+		fields, _ := item.(map[string]interface{})
+		level := takeProfitLevel{}
+		level.Price, _ = fields["price"].(float64)
+		level.Percent, _ = fields["percent"].(float64)
+		level.AllocationPercent, _ = fields["allocation_percent"].(float64)
+		if (level.Price > 0) == (level.Percent > 0) || level.Price < 0 || level.Percent < 0 || level.Percent >= 100 ||
+			level.AllocationPercent <= 0 {
+			return nil, localizeError(lang, "ladder.invalid_level", i+1)
+		}
+		total += level.AllocationPercent
+		ladder = append(ladder, level)
+	}
+	// 允许分配比例合计有极小的浮点误差
+	if total > 100+1e-6 {
+		return nil, localizeError(lang, "ladder.allocation_sum", total)
+	}
+	return ladder, nil
+}
+
+// resolve 按参考价格把百分比档位换算为价格，并按距参考价由近到远排序（做多价格升序，做空价格降序）
+//
+// 位于参考价格错误一侧的档位会排在最前，开仓前对最近一档的校验即可拒绝整个计划。
+func (l takeProfitLadder) resolve(side string, price float64) takeProfitLadder {
+	resolved := make(takeProfitLadder, len(l))
+	copy(resolved, l)
+	for i := range resolved {
+		if resolved[i].Percent > 0 {
+			_, resolved[i].Price = percentStops{TakeProfit: resolved[i].Percent}.apply(side, price, 0, 0)
+		}
+	}
+	sort.SliceStable(resolved, func(i, j int) bool {
+		if side == "short" {
+			return resolved[i].Price > resolved[j].Price
+		}
+		return resolved[i].Price < resolved[j].Price
+	})
+	return resolved
+}
+
+// target 最近一档的止盈价，作为持仓记录和开仓检查使用的止盈价；没有档位时返回0
+func (l takeProfitLadder) target() float64 {
+	if len(l) == 0 {
+		return 0
+	}
+	return l[0].Price
+}
+
+// allocated 各档分配比例是否合计为100%（没有不设止盈的剩余仓位）
+func (l takeProfitLadder) allocated() bool {
+	total := 0.0
+	for _, level := range l {
+		total += level.AllocationPercent
+	}
+	return total >= 100-1e-6
+}
+
+// withoutBeyondFill 去掉位于成交价错误一侧的档位，返回保留的档位和去掉的档数
+func (l takeProfitLadder) withoutBeyondFill(side string, fillPrice float64) (takeProfitLadder, int) {
+	kept := make(takeProfitLadder, 0, len(l))
+	for _, level := range l {
+		if takeProfitBeyondFill(side, fillPrice, level.Price) {
+			continue
+		}
+		kept = append(kept, level)
+	}
+	return kept, len(l) - len(kept)
+}
+
+// ladderQuantities 按分配比例拆分各档止盈数量，format 按交易对步长向下取整
+//
+// 分配比例合计为100%时最后一档取剩余的全部数量，避免取整后残留无止盈的零头；
+// 取整后数量为0的档位返回0，由调用方跳过。
+func ladderQuantities(total float64, ladder takeProfitLadder, format func(float64) float64) []float64 {
+	quantities := make([]float64, len(ladder))
+	assigned := 0.0
+	for i, level := range ladder {
+		raw := total * level.AllocationPercent / 100
+		if i == len(ladder)-1 && ladder.allocated() {
+			raw = total - assigned
+		}
+		quantity := format(raw)
+		if quantity <= 0 || assigned+quantity > total+1e-12 {
+			continue
+		}
+		quantities[i] = quantity
+		assigned += quantity
+	}
+	return quantities
+}
+
+// ladderRemainingQuantity 分批止盈某档成交后剩余的持仓数量
+//
+// 按原止损单数量减去成交数量计算，同步到的持仓数量更小时以持仓为准（持仓同步可能早于或晚于订单成交）；
+// 返回0表示该档成交后已无剩余仓位。
+func ladderRemainingQuantity(stopQuantity, filledQuantity, positionQuantity float64) float64 {
+	remaining := stopQuantity - filledQuantity
+	if remaining <= 0 {
+		return 0
+	}
+	if positionQuantity > 0 && positionQuantity < remaining {
+		return positionQuantity
+	}
+	return remaining
+}
+
+// createTakeProfitLadder 为刚开的仓位按分批止盈计划逐档创建止盈单，单档失败不影响其他档位
+func (s *AgentService) createTakeProfitLadder(ctx context.Context, symbol, side string, quantity float64, ladder takeProfitLadder) []LadderLevelResult {
+	quantities := ladderQuantities(quantity, ladder, func(raw float64) float64 {
+		formatted, err := s.exchange.FormatQuantity(ctx, symbol, raw)
+		if err != nil {
+			s.logger.Warn("failed to format take profit level quantity",
+				zap.String("symbol", symbol),
+				zap.Float64("quantity", raw),
+				zap.Error(err))
+			return 0
+		}
+		return formatted
+	})
+
+	results := make([]LadderLevelResult, 0, len(ladder))
+	for i, level := range ladder {
+		result := LadderLevelResult{
+			Level:             i + 1,
+			Price:             level.Price,
+			Quantity:          quantities[i],
+			AllocationPercent: level.AllocationPercent,
+		}
+		if result.Quantity <= 0 {
+			s.logger.Warn("take profit level quantity below step size, skipping",
+				zap.String("symbol", symbol),
+				zap.Int("level", result.Level),
+				zap.Float64("allocation_percent", level.AllocationPercent))
+			results = append(results, result)
+			continue
+		}
+		reason := fmt.Sprintf("开仓时设置分批止盈第%d档（%.0f%%）", result.Level, level.AllocationPercent)
+		if err := s.createTakeProfitOrderAtLevel(ctx, symbol, side, result.Quantity, level.Price, reason, result.Level); err != nil {
+			s.logger.Error("failed to create take profit level order",
+				zap.String("symbol", symbol),
+				zap.Int("level", result.Level),
+				zap.Float64("take_profit_price", level.Price),
+				zap.Float64("quantity", result.Quantity),
+				zap.Error(err))
+		} else {
+			result.Created = true
+			s.logger.Info("take profit level order created",
+				zap.String("symbol", symbol),
+				zap.Int("level", result.Level),
+				zap.Float64("take_profit_price", level.Price),
+				zap.Float64("quantity", result.Quantity))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// formatTakeProfitLevels 开仓结果消息中的分批止盈描述，如 "105.00×50% / 110.00×30%"
+func formatTakeProfitLevels(lang string, levels []LadderLevelResult) string {
+	parts := make([]string, 0, len(levels))
+	for _, level := range levels {
+		part := fmt.Sprintf("%.2f×%.0f%%", level.Price, level.AllocationPercent)
+		if !level.Created {
+			part += localize(lang, "open.level_not_created")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " / ")
+}
+
+// ResizeStopAfterLadderFill 分批止盈某档成交、持仓仍有剩余时，把止损单调整为剩余数量（position.Quantity）
+//
+// 开启 ladder_breakeven_stop 且止损仍劣于开仓价时同时把止损移到开仓价（加 breakeven_offset_ticks 偏移）。
+// 持仓记录的止盈价更新为下一档仍活跃的止盈单，没有剩余档位时为0。
+func (s *AgentService) ResizeStopAfterLadderFill(ctx context.Context, filled *models.Order, position *models.Position) {
+	orders, err := s.OrderRepo.FindActiveByPositionID(ctx, position.ID)
+	if err != nil {
+		s.logger.Error("failed to load active orders after take profit level fill",
+			zap.String("position_id", position.ID),
+			zap.Error(err))
+		return
+	}
+
+	nextTakeProfit, nextLevel := 0.0, 0
+	var stopOrder *models.Order
+	for i := range orders {
+		order := &orders[i]
+		switch {
+		case order.IsStopLoss() && stopOrder == nil:
+			stopOrder = order
+		case order.IsTakeProfit() && order.LadderLevel > 0 && (nextLevel == 0 || order.LadderLevel < nextLevel):
+			nextTakeProfit, nextLevel = order.TriggerPrice, order.LadderLevel
+		}
+	}
+
+	stopLoss := position.StopLoss
+	if stopOrder == nil {
+		s.logger.Warn("no active stop loss order to resize after take profit level fill",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.Int("level", filled.LadderLevel))
+	} else {
+		remaining := position.Quantity
+		if formatted, err := s.exchange.FormatQuantity(ctx, position.Symbol, remaining); err == nil && formatted > 0 {
+			remaining = formatted
+		}
+
+		stopLoss = stopOrder.TriggerPrice
+		reason := ladderStopReason
+		if s.conf.Trading.LadderBreakevenStop && stopWorseThanEntry(position.Side, position.EntryPrice, stopLoss) {
+			stopLoss = s.breakevenPrice(ctx, position, s.conf.Trading.BreakevenOffsetTicks)
+			reason = ladderBreakevenStopReason
+		}
+
+		resized := *position
+		resized.Quantity = remaining
+		exec, _ := stopExecutionOfOrders(orders)
+		if err := s.replaceStopOrder(ctx, &resized, models.OrderTypeStopLoss, orders, stopLoss, reason, exec); err != nil {
+			// 原止损单为只减仓单，数量大于剩余持仓时仍能完整保护，保留原止损
+			s.logger.Error("failed to resize stop loss after take profit level fill",
+				zap.String("symbol", position.Symbol),
+				zap.Int("level", filled.LadderLevel),
+				zap.Float64("remaining_quantity", remaining),
+				zap.Float64("stop_loss", stopLoss),
+				zap.Error(err))
+			stopLoss = position.StopLoss
+		} else {
+			s.logger.Info("stop loss resized after take profit level fill",
+				zap.String("symbol", position.Symbol),
+				zap.String("side", position.Side),
+				zap.Int("level", filled.LadderLevel),
+				zap.Float64("remaining_quantity", remaining),
+				zap.Float64("old_stop_loss", stopOrder.TriggerPrice),
+				zap.Float64("stop_loss", stopLoss))
+		}
+	}
+
+	if err := s.positionService.UpdateStopPrices(ctx, position.Symbol, position.Side, stopLoss, nextTakeProfit); err != nil {
+		s.logger.Error("failed to update stop prices after take profit level fill",
+			zap.String("symbol", position.Symbol),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTakeProfitLadder(t *testing.T) {
+	args := map[string]interface{}{
+		"take_profit_levels": []interface{}{
+			map[string]interface{}{"percent": 6.0, "allocation_percent": 30.0},
+			map[string]interface{}{"price": 103.0, "allocation_percent": 50.0},
+		},
+	}
+	ladder, err := parseTakeProfitLadder("zh", args)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	// 按距开仓价由近到远排序，百分比档位按参考价格换算
+	long := ladder.resolve("long", 100)
+	if long[0].Price != 103 || math.Abs(long[1].Price-106) > 1e-9 || long.target() != 103 {
+		t.Fatalf("long ladder = %+v", long)
+	}
+	if long.allocated() {
+		t.Fatal("80% allocation leaves a runner")
+	}
+	if kept, dropped := long.withoutBeyondFill("long", 104); dropped != 1 || len(kept) != 1 || kept.target() != long[1].Price {
+		t.Fatalf("levels beyond fill: kept %+v dropped %d", kept, dropped)
+	}
+	// 做空时价格在开仓价上方的档位位于错误一侧，排在最前由开仓检查拒绝
+	short := ladder.resolve("short", 100)
+	if short[0].Price != 103 || math.Abs(short[1].Price-94) > 1e-9 {
+		t.Fatalf("short ladder = %+v", short)
+	}
+
+	for name, bad := range map[string]map[string]interface{}{
+		"conflict":   {"take_profit_price": 110.0, "take_profit_levels": args["take_profit_levels"]},
+		"both":       {"take_profit_levels": []interface{}{map[string]interface{}{"price": 110.0, "percent": 5.0, "allocation_percent": 50.0}}},
+		"allocation": {"take_profit_levels": []interface{}{map[string]interface{}{"price": 110.0}}},
+		"over 100": {"take_profit_levels": []interface{}{
+			map[string]interface{}{"percent": 3.0, "allocation_percent": 60.0},
+			map[string]interface{}{"percent": 6.0, "allocation_percent": 50.0},
+		}},
+	} {
+		if _, err := parseTakeProfitLadder("zh", bad); err == nil || !strings.Contains(err.Error(), "take_profit_levels") {
+			t.Fatalf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestLadderQuantities(t *testing.T) {
+	floor := func(q float64) float64 { return math.Floor(q*1000+1e-9) / 1000 }
+	full := takeProfitLadder{{AllocationPercent: 50}, {AllocationPercent: 30}, {AllocationPercent: 20}}
+
+	// 合计100%时最后一档取剩余数量，不留零头
+	got := ladderQuantities(0.012, full, floor)
+	if got[0] != 0.006 || got[1] != 0.003 || math.Abs(got[2]-0.003) > 1e-12 {
+		t.Fatalf("quantities = %v, want [0.006 0.003 0.003]", got)
+	}
+
+	// 不足一个步长的档位数量为0
+	runner := takeProfitLadder{{AllocationPercent: 50}, {AllocationPercent: 5}}
+	if got := ladderQuantities(0.01, runner, floor); got[0] != 0.005 || got[1] != 0 {
+		t.Fatalf("runner quantities = %v", got)
+	}
+
+	if got := ladderRemainingQuantity(1, 0.5, 1); got != 0.5 {
+		t.Fatalf("remaining = %v, want 0.5", got)
+	}
+	if got := ladderRemainingQuantity(1, 0.5, 0.3); got != 0.3 {
+		t.Fatalf("remaining with smaller position = %v, want 0.3", got)
+	}
+	if got := ladderRemainingQuantity(0.5, 0.5, 0.5); got != 0 {
+		t.Fatalf("remaining after last level = %v, want 0", got)
+	}
+}
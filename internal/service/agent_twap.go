@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// 平仓执行方式
+const (
+	closeExecutionImmediate = "immediate" // 一笔市价单全部平仓
+	closeExecutionTwap      = "twap"      // 拆成多笔市价子订单在一段时间内均匀平仓
+)
+
+// closeExecutions closePosition 支持的执行方式
+var closeExecutions = []string{closeExecutionImmediate, closeExecutionTwap}
+
+// twapRemainderEpsilon 剩余数量低于该值时视为已全部平掉，避免浮点误差多下一笔
+const twapRemainderEpsilon = 1e-9
+
+// CloseSlice TWAP 平仓的一笔子订单成交
+type CloseSlice struct {
+	OrderID       int64   `json:"order_id"`
+	Quantity      float64 `json:"quantity"`
+	Price         float64 `json:"price"`
+	ExpectedPrice float64 `json:"expected_price"` // 下单前的最新价格
+}
+
+// twapSchedule 返回 TWAP 平仓的子订单笔数和相邻子订单的间隔
+func (s *AgentService) twapSchedule() (int, time.Duration) {
+	slices := max(s.conf.Trading.TwapSlices, 1)
+	if slices == 1 {
+		return 1, 0
+	}
+	duration := time.Duration(max(s.conf.Trading.TwapDurationSeconds, 0)) * time.Second
+	return slices, duration / time.Duration(slices-1)
+}
+
+// aggregateCloseSlices 汇总子订单成交，返回总成交数量、成交量加权均价和按成交量加权的参考价格
+func aggregateCloseSlices(slices []CloseSlice) (float64, float64, float64) {
+	var quantity, notional, expectedNotional float64
+	for _, slice := range slices {
+		quantity += slice.Quantity
+		notional += slice.Quantity * slice.Price
+		expectedNotional += slice.Quantity * slice.ExpectedPrice
+	}
+	if quantity <= 0 {
+		return 0, 0, 0
+	}
+	return quantity, notional / quantity, expectedNotional / quantity
+}
+
+// exchangePositionQuantity 查询交易所上该方向的持仓数量，无持仓时为0
+func (s *AgentService) exchangePositionQuantity(ctx context.Context, symbol, side string) (float64, error) {
+	positions, err := s.exchange.GetPositions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range positions {
+		if p.Symbol == symbol && p.Side == side {
+			return p.PositionAmount, nil
+		}
+	}
+	return 0, nil
+}
+
+// twapClosePosition 将全部平仓拆成多笔市价子订单，在配置的时长内均匀执行，汇总为一笔平仓交易
+//
+// 每笔子订单前从交易所确认持仓仍存在，持仓已被止损单等其他订单平掉时停止，剩余部分由持仓同步按触发的订单记录；
+// 上下文取消或子订单失败时同样停止，按已成交部分记录平仓交易并返回错误，剩余持仓仍由止损止盈单保护。
+// 一笔都没有成交且持仓已不存在时返回 exchange.ErrReduceOnlyRejected。调用方负责在之后同步持仓。
+func (s *AgentService) twapClosePosition(ctx context.Context, position *models.Position,
+	reasonCode models.CloseReasonCode, reason string) (*models.Trade, []CloseSlice, error) {
+	symbol := position.Symbol
+	count, interval := s.twapSchedule()
+
+	sliceQty, err := s.exchange.FormatQuantity(ctx, symbol, position.Quantity/float64(count))
+	if err != nil || sliceQty <= 0 || count == 1 {
+		// 持仓太小无法按步长拆分时退化为一笔市价平仓
+		s.logger.Info("position too small for twap slicing, close immediately",
+			zap.String("symbol", symbol),
+			zap.Float64("quantity", position.Quantity),
+			zap.Int("slices", count),
+			zap.Error(err))
+		trade, order, err := s.closePositionQuantity(ctx, position, position.Quantity, reasonCode, reason)
+		if err != nil {
+			return nil, nil, err
+		}
+		return trade, []CloseSlice{{OrderID: order.OrderID, Quantity: trade.Quantity, Price: trade.Price, ExpectedPrice: trade.ExpectedPrice}}, nil
+	}
+
+	s.logger.Info("executing twap close",
+		zap.String("symbol", symbol),
+		zap.String("side", position.Side),
+		zap.Float64("quantity", position.Quantity),
+		zap.Int("slices", count),
+		zap.Duration("interval", interval))
+
+	remaining := position.Quantity
+	var fills []CloseSlice
+	var stopErr error
+	flat := false
+	for i := 0; i < count && remaining > twapRemainderEpsilon; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				stopErr = fmt.Errorf("twap close interrupted: %w", ctx.Err())
+			case <-time.After(interval):
+			}
+			if stopErr != nil {
+				break
+			}
+			open, err := s.exchangePositionQuantity(ctx, symbol, position.Side)
+			if err != nil {
+				s.logger.Warn("failed to check position before twap slice", zap.String("symbol", symbol), zap.Error(err))
+			} else if open <= 0 {
+				flat = true
+				break
+			} else {
+				remaining = min(remaining, open)
+			}
+		}
+
+		quantity := sliceQty
+		if i == count-1 || quantity >= remaining {
+			quantity = remaining
+		}
+
+		currentPrice := position.CurrentPrice
+		if price, err := s.exchange.GetCurrentPrice(ctx, symbol); err == nil {
+			currentPrice = price
+		}
+
+		var order *exchange.OrderResult
+		if position.Side == "long" {
+			order, err = s.exchange.CloseLongPosition(ctx, symbol, quantity)
+		} else {
+			order, err = s.exchange.CloseShortPosition(ctx, symbol, quantity)
+		}
+		if errors.Is(err, exchange.ErrReduceOnlyRejected) {
+			flat = true
+			break
+		}
+		if err != nil {
+			stopErr = fmt.Errorf("failed to close twap slice %d/%d: %w", i+1, count, err)
+			break
+		}
+
+		order = s.settleMarketOrder(ctx, symbol, order)
+		fill := CloseSlice{OrderID: order.OrderID, Quantity: order.ExecutedQty, Price: order.AvgPrice, ExpectedPrice: currentPrice}
+		if fill.Quantity <= 0 {
+			fill.Quantity = quantity
+		}
+		if fill.Price <= 0 {
+			fill.Price = currentPrice
+		}
+		fills = append(fills, fill)
+		remaining -= fill.Quantity
+
+		s.logger.Info("twap slice filled",
+			zap.String("symbol", symbol),
+			zap.Int("slice", i+1),
+			zap.Int("slices", count),
+			zap.Int64("order_id", fill.OrderID),
+			zap.Float64("quantity", fill.Quantity),
+			zap.Float64("price", fill.Price),
+			zap.Float64("remaining", max(remaining, 0)))
+	}
+
+	if flat {
+		s.logger.Warn("position closed by another order during twap, stop slicing",
+			zap.String("symbol", symbol),
+			zap.Int("filled_slices", len(fills)))
+	}
+	if len(fills) == 0 {
+		if flat {
+			return nil, nil, fmt.Errorf("%w: %s closed before twap started", exchange.ErrReduceOnlyRejected, symbol)
+		}
+		return nil, nil, stopErr
+	}
+
+	quantity, avgPrice, expectedPrice := aggregateCloseSlices(fills)
+	trade := s.recordCloseTrade(ctx, position, expectedPrice, avgPrice, quantity, closedQuantityPnl(position, quantity), fills[len(fills)-1].OrderID, reasonCode, reason)
+
+	// 全部由本次子订单平掉时清理止损止盈单和持仓；被其他订单平掉的剩余部分交给持仓同步处理
+	if !flat && remaining <= twapRemainderEpsilon {
+		s.finishFullClose(ctx, position)
+	}
+	return trade, fills, stopErr
+}
+
+// closePositionTwap closePosition 按 TWAP 执行，中途停止时返回失败结果并附带已成交的子订单
+func (s *AgentService) closePositionTwap(ctx context.Context, position *models.Position,
+	reasonCode models.CloseReasonCode, reason string) (*ToolResult, error) {
+	symbol := position.Symbol
+	trade, slices, err := s.twapClosePosition(ctx, position, reasonCode, reason)
+	if trade == nil {
+		if errors.Is(err, exchange.ErrReduceOnlyRejected) {
+			return s.cleanupFlatPosition(ctx, position, reasonCode, reason), nil
+		}
+		return nil, err
+	}
+
+	if syncErr := s.positionService.SyncPositions(ctx); syncErr != nil {
+		s.logger.Warn("failed to sync positions after twap close", zap.Error(syncErr))
+	}
+
+	result := &CloseResult{
+		OrderID:    slices[len(slices)-1].OrderID,
+		Symbol:     symbol,
+		Pnl:        trade.Pnl,
+		Reason:     reason,
+		ReasonCode: reasonCode,
+		Execution:  closeExecutionTwap,
+		Quantity:   trade.Quantity,
+		Price:      trade.Price,
+		Slices:     slices,
+	}
+	if err != nil {
+		s.logger.Error("twap close stopped before completion",
+			zap.String("symbol", symbol),
+			zap.Int("filled_slices", len(slices)),
+			zap.Float64("filled_quantity", trade.Quantity),
+			zap.Error(err))
+		return toolErrorResult("closePosition", localizef(s.language(), "close.twap_interrupted",
+			symbol, len(slices), trade.Quantity, err), result), nil
+	}
+
+	s.logger.Info("twap close successful",
+		zap.String("symbol", symbol),
+		zap.Int("slices", len(slices)),
+		zap.Float64("avg_price", trade.Price),
+		zap.Float64("pnl", trade.Pnl),
+		zap.String("reason_code", string(reasonCode)),
+		zap.String("reason", reason))
+
+	lang := s.language()
+	message := localizef(lang, "close.twap_done", len(slices), symbol, trade.Price, trade.Pnl)
+	if reason != "" {
+		message += localizef(lang, "close.reason_suffix", reason)
+	}
+	return newToolResult("closePosition", message, result), nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+func TestTwapSchedule(t *testing.T) {
+	conf := config.Default()
+	s := &AgentService{conf: &conf}
+	if slices, interval := s.twapSchedule(); slices != 5 || interval != 15*time.Second {
+		t.Fatalf("default schedule = %d, %v, want 5 slices every 15s", slices, interval)
+	}
+
+	conf.Trading.TwapSlices = 0
+	if slices, interval := s.twapSchedule(); slices != 1 || interval != 0 {
+		t.Fatalf("schedule = %d, %v, want a single order", slices, interval)
+	}
+}
+
+func TestAggregateCloseSlices(t *testing.T) {
+	quantity, price, expected := aggregateCloseSlices([]CloseSlice{
+		{OrderID: 1, Quantity: 1, Price: 100, ExpectedPrice: 101},
+		{OrderID: 2, Quantity: 3, Price: 104, ExpectedPrice: 105},
+	})
+	if quantity != 4 || math.Abs(price-103) > 1e-9 || math.Abs(expected-104) > 1e-9 {
+		t.Fatalf("aggregate = %v @ %v (expected %v), want 4 @ 103 (expected 104)", quantity, price, expected)
+	}
+	if quantity, price, _ := aggregateCloseSlices(nil); quantity != 0 || price != 0 {
+		t.Fatalf("empty aggregate = %v @ %v", quantity, price)
+	}
+}
+
+// twapExchange 在纸钱包之上记录每笔平多子订单，afterClose 在第 n 笔子订单成交后调用
+type twapExchange struct {
+	*exchange.PaperWallet
+	closes     []float64
+	afterClose func(n int)
+}
+
+func (e *twapExchange) CloseLongPosition(ctx context.Context, symbol string, quantity float64) (*exchange.OrderResult, error) {
+	order, err := e.PaperWallet.CloseLongPosition(ctx, symbol, quantity)
+	if err != nil {
+		return nil, err
+	}
+	e.closes = append(e.closes, quantity)
+	if e.afterClose != nil {
+		e.afterClose(len(e.closes))
+	}
+	return order, nil
+}
+
+func (e *twapExchange) FormatQuantity(ctx context.Context, symbol string, quantity float64) (float64, error) {
+	return math.Floor(quantity*1000+1e-9) / 1000, nil
+}
+
+// newTwapPosition 开多1个BTC，按 slices 笔、durationSeconds 秒配置 TWAP，返回本地持仓（未实现盈亏20）
+func newTwapPosition(t *testing.T, slices, durationSeconds int) (*AgentService, *twapExchange, *models.Position) {
+	t.Helper()
+	agent, wallet := newTestAgent(t, 100)
+	ex := &twapExchange{PaperWallet: wallet}
+	agent.exchange = ex
+	agent.positionService.exchange = ex
+	agent.conf.Trading.TwapSlices = slices
+	agent.conf.Trading.TwapDurationSeconds = durationSeconds
+
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	position := positions[0]
+	position.UnrealizedPnl = 20
+	return agent, ex, &position
+}
+
+// 持仓按配置拆成多笔子订单平掉，汇总为一笔平仓交易并删除持仓
+func TestTwapClosePositionSlices(t *testing.T) {
+	agent, ex, position := newTwapPosition(t, 4, 0)
+	ctx := context.Background()
+
+	trade, fills, err := agent.twapClosePosition(ctx, position, models.CloseReasonTakeProfit, "reached the planned target")
+	if err != nil {
+		t.Fatalf("twap close: %v", err)
+	}
+	if len(ex.closes) != 4 || len(fills) != 4 {
+		t.Fatalf("closes = %v, fills = %d, want 4 slices", ex.closes, len(fills))
+	}
+	for _, quantity := range ex.closes {
+		if math.Abs(quantity-0.25) > 1e-9 {
+			t.Fatalf("slice quantities = %v, want 0.25 each", ex.closes)
+		}
+	}
+
+	trades := closeTrades(t, agent)
+	if len(trades) != 1 || trades[0].ID != trade.ID {
+		t.Fatalf("close trades = %+v, want the single aggregated trade", trades)
+	}
+	if math.Abs(trades[0].Quantity-1) > 1e-9 || math.Abs(trades[0].Pnl-20) > 1e-9 || trades[0].Price != 100 {
+		t.Fatalf("aggregated trade = %v @ %v pnl %v, want 1 @ 100 pnl 20", trades[0].Quantity, trades[0].Price, trades[0].Pnl)
+	}
+	if positions, _ := agent.positionService.GetAllPositions(ctx); len(positions) != 0 {
+		t.Fatalf("positions after full twap close = %+v", positions)
+	}
+}
+
+// 子订单之间持仓被其他订单平掉时停止拆单，只按已成交部分记录平仓，剩余部分交给持仓同步
+func TestTwapClosePositionStopsWhenFlat(t *testing.T) {
+	agent, ex, position := newTwapPosition(t, 4, 0)
+	ctx := context.Background()
+	ex.afterClose = func(n int) {
+		if n == 2 {
+			// 模拟止损单在第二笔之后平掉了剩余仓位
+			if _, err := ex.PaperWallet.CloseLongPosition(ctx, "BTCUSDT", 0.5); err != nil {
+				t.Fatalf("close remaining: %v", err)
+			}
+		}
+	}
+
+	trade, fills, err := agent.twapClosePosition(ctx, position, models.CloseReasonTakeProfit, "reached the planned target")
+	if err != nil {
+		t.Fatalf("twap close: %v", err)
+	}
+	if len(ex.closes) != 2 || len(fills) != 2 {
+		t.Fatalf("closes = %v, want slicing to stop after 2", ex.closes)
+	}
+	if math.Abs(trade.Quantity-0.5) > 1e-9 || math.Abs(trade.Pnl-10) > 1e-9 {
+		t.Fatalf("trade = %v pnl %v, want 0.5 pnl 10", trade.Quantity, trade.Pnl)
+	}
+	if len(closeTrades(t, agent)) != 1 {
+		t.Fatalf("want a single close trade for the twap slices")
+	}
+}
+
+// 上下文取消时停止拆单，按已成交部分记录平仓交易并返回错误
+func TestTwapClosePositionContextCancel(t *testing.T) {
+	agent, ex, position := newTwapPosition(t, 4, 30)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ex.afterClose = func(n int) {
+		if n == 1 {
+			cancel()
+		}
+	}
+
+	trade, fills, err := agent.twapClosePosition(ctx, position, models.CloseReasonTakeProfit, "reached the planned target")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context canceled", err)
+	}
+	if trade == nil || len(fills) != 1 || math.Abs(trade.Quantity-0.25) > 1e-9 || math.Abs(trade.Pnl-5) > 1e-9 {
+		t.Fatalf("trade = %+v, fills = %d, want the first 0.25 slice with pnl 5", trade, len(fills))
+	}
+	if len(closeTrades(t, agent)) != 1 {
+		t.Fatalf("want the filled slice recorded as one close trade")
+	}
+	if positions, _ := agent.positionService.GetAllPositions(context.Background()); len(positions) != 1 {
+		t.Fatalf("interrupted twap close must keep the local position, got %+v", positions)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// enforceBreakevenStops 盈利达到配置的触发值后把止损仍劣于开仓价的持仓止损移到开仓价，返回是否移动了止损
+func (t *TradingLoop) enforceBreakevenStops(ctx context.Context, positions []models.Position) bool {
+	trigger := t.conf.Trading.BreakevenTriggerPercent
+	if trigger <= 0 {
+		return false
+	}
+
+	moved := false
+	for i := range positions {
+		position := &positions[i]
+		if !needsBreakevenStop(position, trigger) {
+			continue
+		}
+
+		pnlPercent := position.CalculatePnlPercent()
+		t.logger.Info("[RISK] breakeven trigger reached, moving stop loss",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.Float64("pnl_percent", pnlPercent),
+			zap.Float64("trigger_percent", trigger))
+
+		result, err := t.agentService.MoveStopToBreakeven(ctx, position, t.conf.Trading.BreakevenOffsetTicks)
+		if err != nil {
+			// 价格回落到保本价附近时新止损可能无效，下个周期重新检查，不重复通知
+			t.logger.Warn("[RISK] failed to move stop loss to breakeven",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+			continue
+		}
+		moved = true
+		t.notifyService.Notify(fmt.Sprintf("🛡️ %s %s 盈亏 %.2f%% 达到 %.2f%%，%s：%s → %s",
+			position.Symbol, position.Side, pnlPercent, trigger, breakevenStopReason,
+			formatStopPrice(result.OldStopLoss), formatStopPrice(result.NewStopLoss)))
+	}
+	return moved
+}
+
+// formatStopPrice 通知中展示的止损价，未设置时显示为“无”
+func formatStopPrice(price float64) string {
+	if price <= 0 {
+		return "无"
+	}
+	return formatFixed(price, getPricePrecision(price))
+}
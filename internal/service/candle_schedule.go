@@ -0,0 +1,36 @@
+package service
+
+import "time"
+
+// candleCloseSchedule 对齐K线收盘时间的调度计划
+//
+// 交易所的K线以 Unix 纪元为起点按周期切分（如 15m K线收盘于每小时的 0/15/30/45 分），
+// 因此按纪元对齐即可匹配收盘时间；对于不能整除60的周期（如7分钟）同样保持等间隔，
+// 不会像 "*/7 * * * *" 那样在整点处出现不均匀的间隔。
+type candleCloseSchedule struct {
+	interval time.Duration // K线周期
+	delay    time.Duration // 收盘后延迟执行的时间
+}
+
+func newCandleCloseSchedule(interval, delay time.Duration) candleCloseSchedule {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	// 延迟超过一个周期没有意义，截断到周期内
+	delay %= interval
+	return candleCloseSchedule{interval: interval, delay: delay}
+}
+
+// Next 返回严格晚于 t 的下一次执行时间（满足 cron.Schedule 接口）
+func (s candleCloseSchedule) Next(t time.Time) time.Time {
+	// 注意 time.Truncate 以公元1年为起点，周期不能整除一天时与 Unix 纪元不对齐，这里按纪元计算
+	offset := time.Duration(t.UnixNano() % int64(s.interval))
+	next := t.Add(-offset).Add(s.delay)
+	for !next.After(t) {
+		next = next.Add(s.interval)
+	}
+	return next
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCandleCloseScheduleNext(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		delay    time.Duration
+		now      time.Time
+		want     time.Time
+	}{
+		{"after close within delay", 15 * time.Minute, 5 * time.Second, base.Add(2 * time.Second), base.Add(5 * time.Second)},
+		{"exactly at run time", 15 * time.Minute, 5 * time.Second, base.Add(5 * time.Second), base.Add(15*time.Minute + 5*time.Second)},
+		{"mid candle", 15 * time.Minute, 5 * time.Second, base.Add(7 * time.Minute), base.Add(15*time.Minute + 5*time.Second)},
+		{"non divisor interval aligns to epoch", 7 * time.Minute, 0, base, time.Unix((base.Unix()/420+1)*420, 0)},
+		{"hourly", time.Hour, 5 * time.Second, base.Add(30 * time.Minute), base.Add(time.Hour + 5*time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newCandleCloseSchedule(tt.interval, tt.delay).Next(tt.now)
+			if !got.Equal(tt.want) {
+				t.Fatalf("Next(%s) = %s, want %s", tt.now, got, tt.want)
+			}
+		})
+	}
+
+	// 非整除周期的相邻两次执行间隔保持一致
+	s := newCandleCloseSchedule(7*time.Minute, 0)
+	first := s.Next(base.Add(58 * time.Minute))
+	second := s.Next(first)
+	if second.Sub(first) != 7*time.Minute {
+		t.Fatalf("interval across hour boundary = %s, want 7m", second.Sub(first))
+	}
+}
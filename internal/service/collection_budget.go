@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// collectionBudget 行情收集可用的时长，按交易周期间隔的百分比计算，返回0表示不限制
+func collectionBudget(intervalMinutes int, percent float64) time.Duration {
+	if intervalMinutes <= 0 || percent <= 0 {
+		return 0
+	}
+	interval := time.Duration(intervalMinutes) * time.Minute
+	return time.Duration(float64(interval) * min(percent, 100) / 100)
+}
+
+// prioritizeSymbols 把持仓交易对排在最前，limit 大于0时截取前 limit 个
+//
+// 返回本周期收集的交易对和被截掉的交易对。持仓交易对即使超过 limit 也全部保留，
+// 排在最前也保证收集超时时优先拿到持仓的行情。
+func prioritizeSymbols(symbols, held []string, limit int) ([]string, []string) {
+	ordered := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		if slices.Contains(held, symbol) {
+			ordered = append(ordered, symbol)
+		}
+	}
+	heldCount := len(ordered)
+	for _, symbol := range symbols {
+		if !slices.Contains(held, symbol) {
+			ordered = append(ordered, symbol)
+		}
+	}
+
+	if limit <= 0 || len(ordered) <= limit {
+		return ordered, nil
+	}
+	keep := max(limit, heldCount)
+	return ordered[:keep], ordered[keep:]
+}
+
+// prioritizeCycleSymbols 返回持仓交易对（held）优先、按 max_symbols_per_cycle 截取后的配置副本
+func (t *TradingLoop) prioritizeCycleSymbols(tradingConfig *models.TradingConfig, held map[string]bool) *models.TradingConfig {
+	limit := t.conf.Trading.MaxSymbolsPerCycle
+	symbols, skipped := prioritizeSymbols(tradingConfig.Symbols, slices.Collect(maps.Keys(held)), limit)
+	if len(skipped) > 0 {
+		t.logger.Warn("symbols per cycle capped, skipped symbols",
+			zap.Int("max_symbols", limit),
+			zap.Strings("skipped", skipped))
+	}
+
+	prioritized := *tradingConfig
+	prioritized.Symbols = symbols
+	return &prioritized
+}
+
+// collectionContext 为行情收集设置截止时间，未配置收集时限时只派生可取消的 context
+func (t *TradingLoop) collectionContext(ctx context.Context, intervalMinutes int) (context.Context, context.CancelFunc) {
+	budget := collectionBudget(intervalMinutes, t.conf.Trading.CollectionBudgetPercent)
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCollectionBudget(t *testing.T) {
+	tests := []struct {
+		interval int
+		percent  float64
+		want     time.Duration
+	}{
+		{15, 50, 7*time.Minute + 30*time.Second},
+		{15, 0, 0},
+		{0, 50, 0},
+		// 超过100%按整个周期间隔
+		{5, 150, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := collectionBudget(tt.interval, tt.percent); got != tt.want {
+			t.Errorf("collectionBudget(%d, %v) = %v, want %v", tt.interval, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestPrioritizeSymbols(t *testing.T) {
+	symbols := []string{"A", "B", "C", "D", "E"}
+
+	tests := []struct {
+		name        string
+		held        []string
+		limit       int
+		want        []string
+		wantSkipped []string
+	}{
+		{"no limit", nil, 0, symbols, nil},
+		{"held first", []string{"D"}, 0, []string{"D", "A", "B", "C", "E"}, nil},
+		{"capped", []string{"D"}, 3, []string{"D", "A", "B"}, []string{"C", "E"}},
+		// 持仓交易对超过上限时全部保留
+		{"held beyond limit", []string{"C", "E"}, 1, []string{"C", "E"}, []string{"A", "B", "D"}},
+		{"limit above count", nil, 10, symbols, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped := prioritizeSymbols(symbols, tt.held, tt.limit)
+			if !slices.Equal(got, tt.want) || !slices.Equal(skipped, tt.wantSkipped) {
+				t.Errorf("prioritizeSymbols() = %v, %v, want %v, %v", got, skipped, tt.want, tt.wantSkipped)
+			}
+		})
+	}
+}
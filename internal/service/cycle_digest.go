@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// cycleActions 一个交易周期内的开平仓统计
+type cycleActions struct {
+	Opens       int
+	Closes      int
+	RealizedPnl float64 // 平仓交易的已实现盈亏
+}
+
+// summarizeCycleTrades 统计周期内的开平仓次数和已实现盈亏
+func summarizeCycleTrades(trades []models.Trade) cycleActions {
+	var actions cycleActions
+	for i := range trades {
+		if trades[i].Type == "close" {
+			actions.Closes++
+			actions.RealizedPnl += trades[i].Pnl
+		} else {
+			actions.Opens++
+		}
+	}
+	return actions
+}
+
+// digestDue 是否发送本周期的摘要
+//
+// 有开平仓、持仓数量变化或周期是否跳过发生变化时总是发送；没有变化时只在开启心跳且距上次摘要达到心跳间隔时发送。
+func digestDue(lastSentAt time.Time, changed bool, heartbeat time.Duration, now time.Time) bool {
+	if changed || lastSentAt.IsZero() {
+		return true
+	}
+	return heartbeat > 0 && now.Sub(lastSentAt) >= heartbeat
+}
+
+// formatCycleDigest 格式化周期摘要通知
+func formatCycleDigest(summary *CycleSummary, metrics *AccountMetrics, positions []models.Position, actions cycleActions) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 第 %d 周期 | 净值 $%.2f | 收益 %+.2f%% | 回撤 %.2f%%\n",
+		summary.Iteration, metrics.TotalBalance, metrics.ReturnPercent, metrics.DrawdownFromPeak))
+
+	if len(positions) == 0 {
+		sb.WriteString("持仓：无\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("持仓 %d 个：\n", len(positions)))
+		for i := range positions {
+			pos := &positions[i]
+			sb.WriteString(fmt.Sprintf("- %s %s %dx 盈亏 $%.2f (%+.2f%%)\n",
+				pos.Symbol, pos.Side, pos.Leverage, pos.UnrealizedPnl, pos.CalculatePnlPercent()))
+		}
+	}
+
+	if actions.Opens == 0 && actions.Closes == 0 {
+		sb.WriteString(fmt.Sprintf("本周期：无开平仓，工具调用 %d 次", summary.ToolsCalled))
+	} else {
+		sb.WriteString(fmt.Sprintf("本周期：开仓 %d 笔，平仓 %d 笔（已实现 $%.2f），工具调用 %d 次",
+			actions.Opens, actions.Closes, actions.RealizedPnl, summary.ToolsCalled))
+	}
+	if summary.Skipped != "" {
+		sb.WriteString(fmt.Sprintf("\n跳过：%s", summary.Skipped))
+	}
+	return sb.String()
+}
+
+// sendCycleDigest 交易周期结束后按配置发送摘要通知，未开启时不做任何事
+func (t *TradingLoop) sendCycleDigest(ctx context.Context, cycleStart time.Time, summary *CycleSummary, metrics *AccountMetrics, positions []models.Position) {
+	conf := t.conf.Telegram.Digest
+	if !conf.Enabled || summary == nil || metrics == nil {
+		return
+	}
+
+	trades, err := t.agentService.TradeRepo.FindTradesSince(ctx, cycleStart)
+	if err != nil {
+		t.logger.Warn("failed to load cycle trades for digest", zap.Error(err))
+	}
+	actions := summarizeCycleTrades(trades)
+
+	now := time.Now()
+	t.mu.Lock()
+	changed := actions.Opens > 0 || actions.Closes > 0 || len(positions) != t.digestPositions ||
+		(summary.Skipped != "") != t.digestSkipped
+	due := digestDue(t.lastDigestAt, changed, time.Duration(conf.HeartbeatMinutes)*time.Minute, now)
+	if due {
+		t.lastDigestAt = now
+		t.digestPositions = len(positions)
+		t.digestSkipped = summary.Skipped != ""
+	}
+	t.mu.Unlock()
+	if !due {
+		return
+	}
+
+	t.notifyService.Notify(formatCycleDigest(summary, metrics, positions, actions))
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCycleDigest(t *testing.T) {
+	now := time.Now()
+	if !digestDue(time.Time{}, false, 0, now) {
+		t.Fatal("first digest should be sent")
+	}
+	if !digestDue(now.Add(-time.Minute), true, 0, now) {
+		t.Fatal("changed cycle should be sent")
+	}
+	if digestDue(now.Add(-time.Minute), false, 0, now) || digestDue(now.Add(-time.Minute), false, time.Hour, now) {
+		t.Fatal("unchanged cycle within heartbeat should be skipped")
+	}
+	if !digestDue(now.Add(-2*time.Hour), false, time.Hour, now) {
+		t.Fatal("heartbeat should be sent after the interval")
+	}
+
+	actions := summarizeCycleTrades([]models.Trade{
+		{Type: "open"},
+		{Type: "close", Pnl: 12.5},
+		{Type: "close", Pnl: -2.5},
+	})
+	if actions.Opens != 1 || actions.Closes != 2 || actions.RealizedPnl != 10 {
+		t.Fatalf("actions = %+v", actions)
+	}
+
+	msg := formatCycleDigest(&CycleSummary{Iteration: 42, ToolsCalled: 5},
+		&AccountMetrics{TotalBalance: 1050, ReturnPercent: 5, DrawdownFromPeak: -1.2},
+		[]models.Position{{Symbol: "BTCUSDT", Side: "long", Leverage: 5, EntryPrice: 100, CurrentPrice: 102, Quantity: 1, UnrealizedPnl: 2}},
+		actions)
+	for _, want := range []string{"第 42 周期", "$1050.00", "+5.00%", "-1.20%", "BTCUSDT long 5x", "开仓 1 笔，平仓 2 笔（已实现 $10.00）"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("digest missing %q:\n%s", want, msg)
+		}
+	}
+	if msg := formatCycleDigest(&CycleSummary{Iteration: 1}, &AccountMetrics{}, nil, cycleActions{}); !strings.Contains(msg, "持仓：无") || !strings.Contains(msg, "无开平仓") {
+		t.Fatalf("empty digest:\n%s", msg)
+	}
+}
+
+// 跳过AI决策的周期同样发送摘要并写明跳过原因，连续跳过且没有其他变化时按心跳发送
+func TestCycleDigestSkipped(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+	agent.conf.Telegram.Digest.Enabled = true
+	core, logs := observer.New(zap.InfoLevel)
+	loop := &TradingLoop{
+		logger:        zap.NewNop(),
+		agentService:  agent,
+		notifyService: NewNotifyService(nil, agent.conf, zap.New(core)),
+		conf:          agent.conf,
+	}
+	ctx := context.Background()
+	metrics := &AccountMetrics{TotalBalance: 3}
+	sent := func() []string {
+		var messages []string
+		for _, entry := range logs.TakeAll() {
+			messages = append(messages, entry.ContextMap()["message"].(string))
+		}
+		return messages
+	}
+
+	skipped := &CycleSummary{Iteration: 1, Skipped: "balance 3.00 below operating minimum 10.00"}
+	loop.sendCycleDigest(ctx, time.Now(), skipped, metrics, nil)
+	if messages := sent(); len(messages) != 1 || !strings.Contains(messages[0], "跳过：balance 3.00 below operating minimum") {
+		t.Fatalf("digest = %q, want one digest with the skip reason", messages)
+	}
+
+	loop.sendCycleDigest(ctx, time.Now(), &CycleSummary{Iteration: 2, Skipped: "balance 2.90 below operating minimum 10.00"}, metrics, nil)
+	if messages := sent(); len(messages) != 0 {
+		t.Fatalf("digest = %q, want repeated skips without changes suppressed", messages)
+	}
+
+	loop.sendCycleDigest(ctx, time.Now(), &CycleSummary{Iteration: 3}, metrics, nil)
+	if messages := sent(); len(messages) != 1 || strings.Contains(messages[0], "跳过") {
+		t.Fatalf("digest = %q, want a digest when the cycle runs again", messages)
+	}
+}
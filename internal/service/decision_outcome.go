@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/repo"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	outcomeBatchSize     = 50    // 每次评估的最多决策数
+	outcomeKlineInterval = "15m" // 取评估时点价格的K线周期
+	outcomeKlineDuration = 15 * time.Minute
+	outcomeMaxKlines     = 1500 // 单次请求的最多K线数，评估时点早于该范围的决策不计算方向
+	outcomeAccuracyDays  = 30   // 统计接口汇总最近多少天的决策准确率
+)
+
+// OutcomeService 决策结果评估服务
+//
+// 决策执行后经过评估窗口（decision_outcome_horizon_hours），按决策中的开平仓判断隐含方向：
+// 开仓为持仓方向，平仓为持仓的反方向。评估时点的价格相对成交价在该方向上的变动即为方向得分，
+// 多笔交易取平均；同时统计窗口内相关交易的已实现盈亏。两者分开记录，用于区分“判断对不对”和“单子管得好不好”。
+type OutcomeService struct {
+	logger *zap.Logger
+
+	decisionRepo *repo.DecisionRepo
+	tradeRepo    *repo.TradeRepo
+	eventRepo    *repo.EventRepo
+	exchange     exchange.Exchange
+	conf         *config.Config
+
+	mu sync.Mutex
+}
+
+// NewOutcomeService 创建决策结果评估服务
+func NewOutcomeService(db *gorm.DB, exchange exchange.Exchange, conf *config.Config, logger *zap.Logger) *OutcomeService {
+	return &OutcomeService{
+		logger:       logger,
+		decisionRepo: repo.NewDecisionRepo(db),
+		tradeRepo:    repo.NewTradeRepo(db),
+		eventRepo:    repo.NewEventRepo(db),
+		exchange:     exchange,
+		conf:         conf,
+	}
+}
+
+// horizon 评估窗口，未开启时返回0
+func (s *OutcomeService) horizon() time.Duration {
+	return time.Duration(s.conf.Trading.DecisionOutcomeHorizonHours) * time.Hour
+}
+
+// StartWorker 启动后台评估worker
+func (s *OutcomeService) StartWorker(ctx context.Context, interval time.Duration) {
+	s.logger.Info("starting decision outcome worker",
+		zap.Duration("interval", interval),
+		zap.Duration("horizon", s.horizon()))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// 立即评估一次
+		s.evaluatePending(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.evaluatePending(ctx)
+			case <-ctx.Done():
+				s.logger.Info("decision outcome worker stopped by context")
+				return
+			}
+		}
+	}()
+}
+
+// evaluatePending 评估已过评估窗口的决策，直到没有待评估的决策或出错
+func (s *OutcomeService) evaluatePending(ctx context.Context) {
+	for {
+		evaluated, err := s.EvaluateDue(ctx, time.Now())
+		if err != nil {
+			s.logger.Error("failed to evaluate decision outcomes", zap.Error(err))
+			return
+		}
+		if evaluated < outcomeBatchSize {
+			return
+		}
+	}
+}
+
+// EvaluateDue 评估一批在 now 之前已过评估窗口的决策，返回评估的决策数
+func (s *OutcomeService) EvaluateDue(ctx context.Context, now time.Time) (int, error) {
+	horizon := s.horizon()
+	if horizon <= 0 {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decisions, err := s.decisionRepo.FindPendingOutcomes(ctx, now.Add(-horizon), outcomeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find pending decisions: %w", err)
+	}
+
+	klines := make(map[string][]*exchange.Kline)
+	for i := range decisions {
+		if err := s.evaluate(ctx, &decisions[i], horizon, now, klines); err != nil {
+			// 单个决策无法评估（如交易对已下架拉不到K线）时记为已评估、不计分，避免阻塞后续决策
+			s.logger.Warn("failed to evaluate decision outcome, marking as evaluated without score",
+				zap.String("decision_id", decisions[i].ID),
+				zap.Error(err))
+			if err := s.decisionRepo.UpdateColumnsById(ctx, decisions[i].ID, map[string]interface{}{"outcome_evaluated_at": now}); err != nil {
+				return i, fmt.Errorf("failed to mark decision %s as evaluated: %w", decisions[i].ID, err)
+			}
+		}
+	}
+	return len(decisions), nil
+}
+
+// evaluate 评估单个决策并写回结果，klines 缓存本批次已拉取的K线
+func (s *OutcomeService) evaluate(ctx context.Context, decision *models.Decision, horizon time.Duration, now time.Time, klines map[string][]*exchange.Kline) error {
+	target := decision.ExecutedAt.Add(horizon)
+	trades, err := s.decisionTrades(ctx, decision.ID)
+	if err != nil {
+		return err
+	}
+
+	columns := map[string]interface{}{"outcome_evaluated_at": now}
+	var totalScore, totalPnl float64
+	scored := 0
+	for i := range trades {
+		trade := &trades[i]
+		pnl, err := s.realizedPnl(ctx, trade, target)
+		if err != nil {
+			return err
+		}
+		totalPnl += pnl
+
+		price, err := s.priceAt(ctx, klines, trade.Symbol, target, now)
+		if err != nil {
+			return err
+		}
+		if score, ok := directionalMove(trade, price); ok {
+			totalScore += score
+			scored++
+		}
+	}
+	columns["outcome_pnl"] = totalPnl
+	if scored > 0 {
+		score := totalScore / float64(scored)
+		columns["outcome_score"] = score
+		columns["outcome_correct"] = score > 0
+	}
+	return s.decisionRepo.UpdateColumnsById(ctx, decision.ID, columns)
+}
+
+// decisionTrades 通过运行事件找到决策中成交的交易
+func (s *OutcomeService) decisionTrades(ctx context.Context, decisionID string) ([]models.Trade, error) {
+	events, err := s.eventRepo.FindByDecision(ctx, decisionID, models.EventTrade)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trade events: %w", err)
+	}
+	var ids []string
+	for _, event := range events {
+		var data struct {
+			TradeID string `json:"trade_id"`
+		}
+		if err := json.Unmarshal([]byte(event.Data), &data); err == nil && data.TradeID != "" {
+			ids = append(ids, data.TradeID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.tradeRepo.FindByIdIn(ctx, ids)
+}
+
+// realizedPnl 交易在评估时点之前的已实现盈亏
+//
+// 平仓交易即其盈亏；开仓交易汇总同一持仓在开仓后、评估时点前的平仓盈亏。
+// 未关联持仓的旧开仓交易按同一交易对、同一方向汇总。
+func (s *OutcomeService) realizedPnl(ctx context.Context, trade *models.Trade, target time.Time) (float64, error) {
+	if trade.Type == "close" {
+		return trade.Pnl, nil
+	}
+	closes, err := s.tradeRepo.FindCloseTradesSince(ctx, trade.Symbol, trade.ExecutedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find close trades: %w", err)
+	}
+	return closedPnlOf(trade, closes, target), nil
+}
+
+// closedPnlOf 汇总归属于开仓交易、且不晚于 target 的平仓盈亏
+func closedPnlOf(open *models.Trade, closes []models.Trade, target time.Time) float64 {
+	var pnl float64
+	for _, closeTrade := range closes {
+		if closeTrade.ExecutedAt.After(target) {
+			continue
+		}
+		if open.PositionID != "" {
+			if closeTrade.PositionID != open.PositionID {
+				continue
+			}
+		} else if closeTrade.Side != open.Side {
+			continue
+		}
+		pnl += closeTrade.Pnl
+	}
+	return pnl
+}
+
+// priceAt 评估时点所在K线的开盘价，评估时点早于可拉取的K线范围时返回0
+func (s *OutcomeService) priceAt(ctx context.Context, cache map[string][]*exchange.Kline, symbol string, target, now time.Time) (float64, error) {
+	bars := int(now.Sub(target)/outcomeKlineDuration) + 2
+	if bars > outcomeMaxKlines {
+		return 0, nil
+	}
+	klines, ok := cache[symbol]
+	if !ok || len(klines) == 0 || klines[0].OpenTime.After(target) {
+		fetched, err := s.exchange.GetKlines(ctx, symbol, outcomeKlineInterval, bars)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get klines for %s: %w", symbol, err)
+		}
+		cache[symbol] = fetched
+		klines = fetched
+	}
+	return klineOpenAt(klines, target), nil
+}
+
+// klineOpenAt 返回包含 target 的K线开盘价，没有时返回0
+func klineOpenAt(klines []*exchange.Kline, target time.Time) float64 {
+	for _, kline := range klines {
+		if !kline.OpenTime.After(target) && target.Before(kline.CloseTime) {
+			return kline.Open
+		}
+	}
+	return 0
+}
+
+// directionalMove 评估时点价格相对成交价在交易隐含方向上的变动（%）
+//
+// 开仓隐含持仓方向，平仓隐含持仓的反方向。
+func directionalMove(trade *models.Trade, price float64) (float64, bool) {
+	if trade.Price <= 0 || price <= 0 {
+		return 0, false
+	}
+	move := (price - trade.Price) / trade.Price * 100
+	bullish := trade.Side == "long"
+	if trade.Type == "close" {
+		bullish = !bullish
+	}
+	if !bullish {
+		move = -move
+	}
+	return move, true
+}
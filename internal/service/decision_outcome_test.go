@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// flatKlineExchange 返回截至当前、开盘价固定的15分钟K线
+type flatKlineExchange struct {
+	exchange.Exchange
+	open float64
+}
+
+func (e *flatKlineExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchange.Kline, error) {
+	end := time.Now().Truncate(outcomeKlineDuration)
+	klines := make([]*exchange.Kline, limit)
+	for i := range klines {
+		openTime := end.Add(-time.Duration(limit-1-i) * outcomeKlineDuration)
+		klines[i] = &exchange.Kline{OpenTime: openTime, CloseTime: openTime.Add(outcomeKlineDuration - time.Millisecond), Open: e.open, Close: e.open}
+	}
+	return klines, nil
+}
+
+func TestOutcomeServiceEvaluateDue(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.Decision{}, models.Trade{}, models.Event{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	conf := config.Default()
+	s := NewOutcomeService(db, &flatKlineExchange{open: 105}, &conf, zap.NewNop())
+	events := NewEventService(db, zap.NewNop())
+
+	ctx := context.Background()
+	now := time.Now()
+	executedAt := now.Add(-5 * time.Hour)
+	for _, decision := range []*models.Decision{
+		{ID: "d-long", ExecutedAt: executedAt},
+		{ID: "d-idle", ExecutedAt: executedAt},
+		{ID: "d-recent", ExecutedAt: now.Add(-time.Hour)},
+	} {
+		if err := s.decisionRepo.Create(ctx, decision); err != nil {
+			t.Fatalf("create decision: %v", err)
+		}
+	}
+
+	// 开多后价格上涨5%，并在评估窗口内平仓盈利
+	open := &models.Trade{ID: "t-open", Symbol: "BTCUSDT", Type: "open", Side: "long", Price: 100, Quantity: 1, ExecutedAt: executedAt}
+	closeTrade := &models.Trade{ID: "t-close", Symbol: "BTCUSDT", Type: "close", Side: "long", Price: 104, Quantity: 1, Pnl: 4, ExecutedAt: executedAt.Add(time.Hour)}
+	for _, trade := range []*models.Trade{open, closeTrade} {
+		if err := s.tradeRepo.Create(ctx, trade); err != nil {
+			t.Fatalf("create trade: %v", err)
+		}
+	}
+	events.Record(withEventDecision(ctx, "d-long"), models.EventTrade, "BTCUSDT", "open", map[string]interface{}{"trade_id": open.ID})
+
+	evaluated, err := s.EvaluateDue(ctx, now)
+	if err != nil || evaluated != 2 {
+		t.Fatalf("evaluated = %d, %v, want 2", evaluated, err)
+	}
+
+	long, _ := s.decisionRepo.FindById(ctx, "d-long")
+	if long.OutcomeScore == nil || *long.OutcomeScore < 4.99 || *long.OutcomeScore > 5.01 || long.OutcomeCorrect == nil || !*long.OutcomeCorrect || long.OutcomePnl != 4 {
+		t.Fatalf("unexpected long outcome: %+v", long)
+	}
+	idle, _ := s.decisionRepo.FindById(ctx, "d-idle")
+	if idle.OutcomeEvaluatedAt == nil || idle.OutcomeScore != nil {
+		t.Fatalf("decision without trades should be evaluated without a score: %+v", idle)
+	}
+	recent, _ := s.decisionRepo.FindById(ctx, "d-recent")
+	if recent.OutcomeEvaluatedAt != nil {
+		t.Fatal("decision inside the horizon should not be evaluated yet")
+	}
+
+	accuracy, err := s.decisionRepo.GetDecisionAccuracy(ctx, now.AddDate(0, 0, -1))
+	if err != nil || accuracy.Evaluated != 1 || accuracy.AccuracyPercent != 100 || len(accuracy.Daily) != 1 {
+		t.Fatalf("unexpected accuracy: %+v, %v", accuracy, err)
+	}
+
+	// 平空隐含看多
+	if move, ok := directionalMove(&models.Trade{Type: "close", Side: "short", Price: 100}, 98); !ok || move != -2 {
+		t.Fatalf("close short move = %v, %v", move, ok)
+	}
+}
+
+// delistedKlineExchange 指定交易对拉取K线失败，其余交易对返回固定开盘价
+type delistedKlineExchange struct {
+	flatKlineExchange
+	delisted string
+}
+
+func (e *delistedKlineExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchange.Kline, error) {
+	if symbol == e.delisted {
+		return nil, errors.New("invalid symbol")
+	}
+	return e.flatKlineExchange.GetKlines(ctx, symbol, interval, limit)
+}
+
+func TestOutcomeServiceSkipsFailingDecision(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.Decision{}, models.Trade{}, models.Event{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	conf := config.Default()
+	s := NewOutcomeService(db, &delistedKlineExchange{flatKlineExchange: flatKlineExchange{open: 105}, delisted: "OLDUSDT"}, &conf, zap.NewNop())
+	events := NewEventService(db, zap.NewNop())
+
+	ctx := context.Background()
+	now := time.Now()
+	executedAt := now.Add(-5 * time.Hour)
+	// 最早的决策交易的是已下架交易对，不能阻塞之后的决策
+	for _, decision := range []*models.Decision{
+		{ID: "d-delisted", ExecutedAt: executedAt.Add(-time.Hour)},
+		{ID: "d-long", ExecutedAt: executedAt},
+	} {
+		if err := s.decisionRepo.Create(ctx, decision); err != nil {
+			t.Fatalf("create decision: %v", err)
+		}
+	}
+	for _, trade := range []*models.Trade{
+		{ID: "t-old", Symbol: "OLDUSDT", Type: "open", Side: "long", Price: 1, Quantity: 1, ExecutedAt: executedAt.Add(-time.Hour)},
+		{ID: "t-open", Symbol: "BTCUSDT", Type: "open", Side: "long", Price: 100, Quantity: 1, PositionID: "p1", ExecutedAt: executedAt},
+	} {
+		if err := s.tradeRepo.Create(ctx, trade); err != nil {
+			t.Fatalf("create trade: %v", err)
+		}
+	}
+	events.Record(withEventDecision(ctx, "d-delisted"), models.EventTrade, "OLDUSDT", "open", map[string]interface{}{"trade_id": "t-old"})
+	events.Record(withEventDecision(ctx, "d-long"), models.EventTrade, "BTCUSDT", "open", map[string]interface{}{"trade_id": "t-open"})
+
+	evaluated, err := s.EvaluateDue(ctx, now)
+	if err != nil || evaluated != 2 {
+		t.Fatalf("evaluated = %d, %v, want 2", evaluated, err)
+	}
+	delisted, _ := s.decisionRepo.FindById(ctx, "d-delisted")
+	if delisted.OutcomeEvaluatedAt == nil || delisted.OutcomeScore != nil {
+		t.Fatalf("failing decision should be evaluated without a score: %+v", delisted)
+	}
+	long, _ := s.decisionRepo.FindById(ctx, "d-long")
+	if long.OutcomeScore == nil {
+		t.Fatalf("later decision should still be scored: %+v", long)
+	}
+}
+
+func TestClosedPnlOfFollowsPosition(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	target := base.Add(4 * time.Hour)
+	closes := []models.Trade{
+		{Side: "long", PositionID: "p1", Pnl: 4, ExecutedAt: base.Add(time.Hour)},
+		// 同方向的后一个持仓，不归属于 p1 的开仓
+		{Side: "long", PositionID: "p2", Pnl: -7, ExecutedAt: base.Add(3 * time.Hour)},
+		{Side: "short", PositionID: "p3", Pnl: 2, ExecutedAt: base.Add(2 * time.Hour)},
+		// 评估时点之后的平仓不计入
+		{Side: "long", PositionID: "p1", Pnl: 9, ExecutedAt: base.Add(5 * time.Hour)},
+	}
+
+	if got := closedPnlOf(&models.Trade{Side: "long", PositionID: "p1"}, closes, target); got != 4 {
+		t.Fatalf("position pnl = %v, want 4", got)
+	}
+	// 未关联持仓的旧交易按方向汇总
+	if got := closedPnlOf(&models.Trade{Side: "long"}, closes, target); got != -3 {
+		t.Fatalf("legacy pnl = %v, want -3", got)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// ErrDecisionPromptNotStored 决策记录中没有保存提示词（未开启 store_prompt 或早于该功能的决策）
+var ErrDecisionPromptNotStored = errors.New("decision prompt not stored")
+
+// SaveDecisionPrompt 在决策记录中保存本次决策完整渲染后的系统提示词和用户提示词，未开启 store_prompt 时不保存
+//
+// LLM日志按轮次记录，失败或取消的轮次不一定写入；提示词随决策保存一次，保证每个决策都能按原始输入复现。
+func (s *AgentService) SaveDecisionPrompt(ctx context.Context, decisionID, systemPrompt, userPrompt string) error {
+	if !s.conf.LLM.StorePrompt {
+		return nil
+	}
+	return s.DecisionRepo.UpdatePrompt(ctx, decisionID, systemPrompt, userPrompt)
+}
+
+// GetDecisionPrompt 获取保存了提示词的决策记录
+func (s *AgentService) GetDecisionPrompt(ctx context.Context, decisionID string) (*models.Decision, error) {
+	decision, exists, err := s.DecisionRepo.FindByIdExists(ctx, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrDecisionNotFound
+	}
+	if decision.SystemPrompt == "" && decision.UserPrompt == "" {
+		return nil, ErrDecisionPromptNotStored
+	}
+	return &decision, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestDecisionPrompt(t *testing.T) {
+	agent, _ := newTestAgent(t, 95000)
+	if err := agent.DecisionRepo.GetDB(context.Background()).AutoMigrate(models.Decision{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	ctx := context.Background()
+
+	decisionID, err := agent.SaveDecision(ctx, 1, 1000, 0, decisionPlaceholderContent, 0, 0)
+	if err != nil {
+		t.Fatalf("save decision: %v", err)
+	}
+	if _, err := agent.GetDecisionPrompt(ctx, decisionID); !errors.Is(err, ErrDecisionPromptNotStored) {
+		t.Fatalf("prompt before save: err = %v", err)
+	}
+
+	if err := agent.SaveDecisionPrompt(ctx, decisionID, "system rules", "market snapshot"); err != nil {
+		t.Fatalf("save prompt: %v", err)
+	}
+	// 决策完成后更新内容，不应覆盖已保存的提示词
+	if err := agent.UpdateDecision(ctx, decisionID, "done", "", 100, 20); err != nil {
+		t.Fatalf("update decision: %v", err)
+	}
+	decision, err := agent.GetDecisionPrompt(ctx, decisionID)
+	if err != nil {
+		t.Fatalf("get prompt: %v", err)
+	}
+	if decision.SystemPrompt != "system rules" || decision.UserPrompt != "market snapshot" || decision.DecisionContent != "done" {
+		t.Errorf("decision = %+v", decision)
+	}
+
+	if _, err := agent.GetDecisionPrompt(ctx, "missing"); !errors.Is(err, ErrDecisionNotFound) {
+		t.Errorf("missing decision: err = %v", err)
+	}
+
+	// 关闭 store_prompt 时不保存
+	agent.conf.LLM.StorePrompt = false
+	otherID, err := agent.SaveDecision(ctx, 2, 1000, 0, decisionPlaceholderContent, 0, 0)
+	if err != nil {
+		t.Fatalf("save decision: %v", err)
+	}
+	if err := agent.SaveDecisionPrompt(ctx, otherID, "system rules", "market snapshot"); err != nil {
+		t.Fatalf("save prompt: %v", err)
+	}
+	if _, err := agent.GetDecisionPrompt(ctx, otherID); !errors.Is(err, ErrDecisionPromptNotStored) {
+		t.Errorf("prompt with store_prompt disabled: err = %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxReplayKlines 回放单次拉取的K线数量上限（币安限制1500根）
+const maxReplayKlines = 1500
+
+// replayIntervals 回放使用的K线周期，按决策距今的时长选择能覆盖全程的最小周期
+var replayIntervals = []struct {
+	interval string
+	duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"4h", 4 * time.Hour},
+}
+
+// 回放的操作类型
+const (
+	ReplayActionOpen  = "open"
+	ReplayActionClose = "close"
+)
+
+// 回放操作的退出方式
+const (
+	replayExitStopLoss   = "stop_loss"
+	replayExitTakeProfit = "take_profit"
+	replayExitStillOpen  = "still_open" // 至今未触发止损止盈，按最新价计算浮动盈亏
+	replayExitClosed     = "closed"     // 决策时立即平仓
+)
+
+var (
+	ErrDecisionNotFound = errors.New("decision not found")
+	ErrNoReplayActions  = errors.New("decision has no replayable actions")
+	ErrReplayTooOld     = errors.New("decision is too old to replay")
+)
+
+// ReplayAction 回放的单个交易操作
+type ReplayAction struct {
+	Action          string  `json:"action"`                      // open/close
+	Symbol          string  `json:"symbol"`                      // 交易对
+	Side            string  `json:"side,omitempty"`              // long/short，仅开仓
+	Leverage        int     `json:"leverage,omitempty"`          // 杠杆，仅开仓
+	Margin          float64 `json:"margin,omitempty"`            // 保证金（USDT），仅开仓
+	StopLossPrice   float64 `json:"stop_loss_price,omitempty"`   // 止损价，仅开仓
+	TakeProfitPrice float64 `json:"take_profit_price,omitempty"` // 止盈价，仅开仓
+}
+
+// ReplayActionResult 单个操作的回放结果
+type ReplayActionResult struct {
+	ReplayAction
+	EntryPrice      float64    `json:"entry_price"`       // 开仓价（平仓操作为原持仓开仓价）
+	Quantity        float64    `json:"quantity"`          // 数量
+	ExitPrice       float64    `json:"exit_price"`        // 平仓价或最新价
+	ExitReason      string     `json:"exit_reason"`       // stop_loss/take_profit/still_open/closed
+	ExitAt          *time.Time `json:"exit_at,omitempty"` // 触发止损止盈的K线时间
+	HypotheticalPnl float64    `json:"hypothetical_pnl"`  // 假设执行该操作的盈亏
+	Error           string     `json:"error,omitempty"`   // 无法回放的原因
+}
+
+// ReplaySymbolOutcome 单个交易对假设与实际的盈亏对比
+type ReplaySymbolOutcome struct {
+	Symbol          string  `json:"symbol"`
+	HypotheticalPnl float64 `json:"hypothetical_pnl"` // 回放操作的盈亏合计
+	ActualPnl       float64 `json:"actual_pnl"`       // 决策之后该交易对实际已实现盈亏 + 当前浮动盈亏
+}
+
+// DecisionReplay 决策反事实回放结果
+type DecisionReplay struct {
+	DecisionID      string                `json:"decision_id"`
+	Iteration       int                   `json:"iteration"`
+	ExecutedAt      time.Time             `json:"executed_at"`
+	ReplayedTo      time.Time             `json:"replayed_to"`
+	Interval        string                `json:"interval"`         // 回放使用的K线周期
+	Source          string                `json:"source"`           // logged：决策日志中的操作；custom：请求指定的操作
+	StartingBalance float64               `json:"starting_balance"` // 模拟账户初始余额（决策时账户价值）
+	Actions         []ReplayActionResult  `json:"actions"`
+	Symbols         []ReplaySymbolOutcome `json:"symbols"`
+	HypotheticalPnl float64               `json:"hypothetical_pnl"`
+	ActualPnl       float64               `json:"actual_pnl"`
+	Difference      float64               `json:"difference"` // 假设盈亏 - 实际盈亏
+}
+
+// ReplayDecision 在纸钱包上按历史K线回放某次决策的操作，对比假设盈亏与实际结果
+//
+// actions 为空时回放决策日志中 AI 调用的开平仓操作（包括被规则拒绝的），否则回放指定的操作。
+// 开仓在决策后第一根K线开盘价成交，之后逐根K线检查止损止盈，同一根K线同时触及时按止损处理；
+// 平仓按决策时原持仓的开仓价结算。实际盈亏为决策之后该交易对的已实现盈亏加当前浮动盈亏。
+func (s *AgentService) ReplayDecision(ctx context.Context, decisionID string, actions []ReplayAction) (*DecisionReplay, error) {
+	decision, exists, err := s.DecisionRepo.FindByIdExists(ctx, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrDecisionNotFound
+	}
+
+	source := "custom"
+	if len(actions) == 0 {
+		logs, err := s.LLMLogRepo.FindByDecisionID(ctx, decisionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get llm logs: %w", err)
+		}
+		actions = extractReplayActions(logs, s.conf.Trading.SizingMode, decision.AccountValue)
+		source = "logged"
+	}
+	if len(actions) == 0 {
+		return nil, ErrNoReplayActions
+	}
+
+	now := time.Now()
+	interval, limit, err := replayInterval(now.Sub(decision.ExecutedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	// 拉取各交易对自决策以来的K线
+	klinesBySymbol := make(map[string][]*exchange.Kline)
+	var symbols []string
+	for i := range actions {
+		actions[i].Symbol = exchange.NormalizeSymbol(actions[i].Symbol)
+		actions[i].Side = strings.ToLower(strings.TrimSpace(actions[i].Side))
+		symbol := actions[i].Symbol
+		if _, ok := klinesBySymbol[symbol]; ok || symbol == "" {
+			continue
+		}
+		klines, err := s.exchange.GetKlines(ctx, symbol, interval, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get klines for %s: %w", symbol, err)
+		}
+		klinesBySymbol[symbol] = klines
+		symbols = append(symbols, symbol)
+	}
+
+	startingBalance := decision.AccountValue
+	for _, action := range actions {
+		startingBalance = max(startingBalance, action.Margin)
+	}
+	sim := newReplaySimulator(exchange.NewPaperWallet(nil, startingBalance, s.logger))
+
+	// 先在决策时刻执行全部操作，再推进K线结算，保证各操作的余额检查基于同一时刻
+	results := make([]ReplayActionResult, len(actions))
+	for i, action := range actions {
+		results[i].ReplayAction = action
+		s.replayEnter(ctx, sim, decision.ExecutedAt, klinesBySymbol[action.Symbol], &results[i])
+	}
+	for i := range results {
+		if results[i].Error == "" && results[i].Action == ReplayActionOpen {
+			sim.settleOpen(ctx, klinesSince(klinesBySymbol[results[i].Symbol], decision.ExecutedAt), &results[i])
+		}
+	}
+
+	replay := &DecisionReplay{
+		DecisionID:      decision.ID,
+		Iteration:       decision.Iteration,
+		ExecutedAt:      decision.ExecutedAt,
+		ReplayedTo:      now,
+		Interval:        interval,
+		Source:          source,
+		StartingBalance: startingBalance,
+		Actions:         results,
+	}
+
+	unrealized := s.currentUnrealizedPnl(ctx)
+	for _, symbol := range symbols {
+		outcome := ReplaySymbolOutcome{Symbol: symbol}
+		for _, result := range results {
+			if result.Symbol == symbol {
+				outcome.HypotheticalPnl += result.HypotheticalPnl
+			}
+		}
+		trades, err := s.TradeRepo.FindCloseTradesSince(ctx, symbol, decision.ExecutedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get trades for %s: %w", symbol, err)
+		}
+		for _, trade := range trades {
+			outcome.ActualPnl += trade.Pnl
+		}
+		outcome.ActualPnl += unrealized[symbol]
+
+		replay.Symbols = append(replay.Symbols, outcome)
+		replay.HypotheticalPnl += outcome.HypotheticalPnl
+		replay.ActualPnl += outcome.ActualPnl
+	}
+	replay.Difference = replay.HypotheticalPnl - replay.ActualPnl
+
+	s.logger.Info("decision replayed",
+		zap.String("decision_id", decision.ID),
+		zap.String("source", source),
+		zap.String("interval", interval),
+		zap.Int("actions", len(results)),
+		zap.Float64("hypothetical_pnl", replay.HypotheticalPnl),
+		zap.Float64("actual_pnl", replay.ActualPnl))
+
+	return replay, nil
+}
+
+// replayEnter 在决策时刻执行单个操作
+func (s *AgentService) replayEnter(ctx context.Context, sim *replaySimulator, at time.Time, klines []*exchange.Kline, result *ReplayActionResult) {
+	price, ok := replayEntryPrice(klines, at)
+	if !ok {
+		result.Error = "no klines available for symbol"
+		return
+	}
+
+	switch result.Action {
+	case ReplayActionOpen:
+		if result.Side != "long" && result.Side != "short" {
+			result.Error = fmt.Sprintf("invalid side: %s", result.Side)
+			return
+		}
+		if result.Leverage <= 0 || result.Margin <= 0 {
+			result.Error = "leverage and margin must be positive"
+			return
+		}
+		quantity := result.Margin * float64(result.Leverage) / price
+		if err := sim.open(ctx, result.Symbol, result.Side, result.Leverage, quantity, price); err != nil {
+			result.Error = err.Error()
+			return
+		}
+		result.EntryPrice = price
+		result.Quantity = quantity
+	case ReplayActionClose:
+		// 按决策前最近一笔开仓还原当时的持仓
+		trade, err := s.TradeRepo.FindLatestOpenTradeBefore(ctx, result.Symbol, at)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result.Error = "no position held at decision time"
+			} else {
+				result.Error = err.Error()
+			}
+			return
+		}
+		result.Side = trade.Side
+		result.Leverage = trade.Leverage
+		if err := sim.open(ctx, result.Symbol, trade.Side, max(trade.Leverage, 1), trade.Quantity, trade.Price); err != nil {
+			result.Error = err.Error()
+			return
+		}
+		pnl, err := sim.close(ctx, result.Symbol, trade.Side, trade.Quantity, price)
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+		result.EntryPrice = trade.Price
+		result.Quantity = trade.Quantity
+		result.ExitPrice = price
+		result.ExitReason = replayExitClosed
+		result.HypotheticalPnl = pnl
+	default:
+		result.Error = fmt.Sprintf("unsupported action: %s", result.Action)
+	}
+}
+
+// currentUnrealizedPnl 当前各交易对的浮动盈亏，获取失败时视为0
+func (s *AgentService) currentUnrealizedPnl(ctx context.Context) map[string]float64 {
+	result := make(map[string]float64)
+	positions, err := s.exchange.GetPositions(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get positions for replay, ignoring unrealized pnl", zap.Error(err))
+		return result
+	}
+	for _, position := range positions {
+		result[position.Symbol] += position.UnrealizedProfit
+	}
+	return result
+}
+
+// replaySimulator 以历史价格驱动纸钱包
+type replaySimulator struct {
+	wallet *exchange.PaperWallet
+	prices map[string]float64 // 每个交易对当前回放到的价格
+}
+
+func newReplaySimulator(wallet *exchange.PaperWallet) *replaySimulator {
+	sim := &replaySimulator{
+		wallet: wallet,
+		prices: make(map[string]float64),
+	}
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		price, ok := sim.prices[symbol]
+		if !ok {
+			return 0, fmt.Errorf("no replay price for %s", symbol)
+		}
+		return price, nil
+	})
+	return sim
+}
+
+// open 按指定价格开仓
+func (r *replaySimulator) open(ctx context.Context, symbol, side string, leverage int, quantity, price float64) error {
+	if err := r.wallet.SetLeverage(ctx, symbol, leverage); err != nil {
+		return err
+	}
+	r.prices[symbol] = price
+	var err error
+	if side == "long" {
+		_, err = r.wallet.OpenLongPosition(ctx, symbol, quantity)
+	} else {
+		_, err = r.wallet.OpenShortPosition(ctx, symbol, quantity)
+	}
+	return err
+}
+
+// close 按指定价格平仓，返回已实现盈亏
+func (r *replaySimulator) close(ctx context.Context, symbol, side string, quantity, price float64) (float64, error) {
+	r.prices[symbol] = price
+	before := r.wallet.GetBalance()
+	var err error
+	if side == "long" {
+		_, err = r.wallet.CloseLongPosition(ctx, symbol, quantity)
+	} else {
+		_, err = r.wallet.CloseShortPosition(ctx, symbol, quantity)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return r.wallet.GetBalance() - before, nil
+}
+
+// settleOpen 推进K线结算开仓操作：触发止损止盈则平仓，否则按最新价计算浮动盈亏
+func (r *replaySimulator) settleOpen(ctx context.Context, path []*exchange.Kline, result *ReplayActionResult) {
+	if price, reason, at, ok := findStopExit(result.Side, result.StopLossPrice, result.TakeProfitPrice, path); ok {
+		pnl, err := r.close(ctx, result.Symbol, result.Side, result.Quantity, price)
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+		result.ExitPrice = price
+		result.ExitReason = reason
+		result.ExitAt = &at
+		result.HypotheticalPnl = pnl
+		return
+	}
+
+	last := result.EntryPrice
+	if len(path) > 0 {
+		last = path[len(path)-1].Close
+	}
+	result.ExitPrice = last
+	result.ExitReason = replayExitStillOpen
+	result.HypotheticalPnl = directionalPnl(result.Side, result.EntryPrice, last, result.Quantity)
+}
+
+// findStopExit 查找最先触发止损或止盈的K线，同一根K线同时触及时按止损处理（保守估计）
+func findStopExit(side string, stopLoss, takeProfit float64, path []*exchange.Kline) (float64, string, time.Time, bool) {
+	for _, k := range path {
+		if side == "long" {
+			if stopLoss > 0 && k.Low <= stopLoss {
+				return stopLoss, replayExitStopLoss, k.OpenTime, true
+			}
+			if takeProfit > 0 && k.High >= takeProfit {
+				return takeProfit, replayExitTakeProfit, k.OpenTime, true
+			}
+		} else {
+			if stopLoss > 0 && k.High >= stopLoss {
+				return stopLoss, replayExitStopLoss, k.OpenTime, true
+			}
+			if takeProfit > 0 && k.Low <= takeProfit {
+				return takeProfit, replayExitTakeProfit, k.OpenTime, true
+			}
+		}
+	}
+	return 0, "", time.Time{}, false
+}
+
+func directionalPnl(side string, entry, exit, quantity float64) float64 {
+	if side == "short" {
+		return (entry - exit) * quantity
+	}
+	return (exit - entry) * quantity
+}
+
+// replayInterval 选择能用一次请求覆盖决策至今时长的最小K线周期
+func replayInterval(elapsed time.Duration) (string, int, error) {
+	for _, candidate := range replayIntervals {
+		// 多取两根，覆盖决策所在K线和未收盘的最新K线
+		count := int(elapsed/candidate.duration) + 2
+		if count <= maxReplayKlines {
+			return candidate.interval, count, nil
+		}
+	}
+	return "", 0, ErrReplayTooOld
+}
+
+// klinesSince 返回在 at 之后开盘的K线
+func klinesSince(klines []*exchange.Kline, at time.Time) []*exchange.Kline {
+	for i, k := range klines {
+		if !k.OpenTime.Before(at) {
+			return klines[i:]
+		}
+	}
+	return nil
+}
+
+// replayEntryPrice 决策后第一根K线的开盘价；决策发生在最新K线内时使用最新价
+func replayEntryPrice(klines []*exchange.Kline, at time.Time) (float64, bool) {
+	if path := klinesSince(klines, at); len(path) > 0 {
+		return path[0].Open, true
+	}
+	if len(klines) > 0 {
+		return klines[len(klines)-1].Close, true
+	}
+	return 0, false
+}
+
+// extractReplayActions 从决策日志中提取 AI 调用的开平仓操作
+func extractReplayActions(logs []models.LLMLog, sizingMode string, accountValue float64) []ReplayAction {
+	var actions []ReplayAction
+	for _, log := range logs {
+		var calls []struct {
+			Function  string          `json:"function"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal([]byte(log.ToolCalls), &calls); err != nil {
+			continue
+		}
+		for _, call := range calls {
+			// 参数解析失败的调用以原始字符串记录，无法回放
+			var args map[string]interface{}
+			if err := json.Unmarshal(call.Arguments, &args); err != nil {
+				continue
+			}
+			symbol, _ := args["symbol"].(string)
+			switch call.Function {
+			case "openPosition":
+				side, _ := args["side"].(string)
+				leverage, _ := args["leverage"].(float64)
+				quantity, _ := args["quantity"].(float64)
+				stopLoss, _ := args["stop_loss_price"].(float64)
+				takeProfit, _ := args["take_profit_price"].(float64)
+				actions = append(actions, ReplayAction{
+					Action:          ReplayActionOpen,
+					Symbol:          symbol,
+					Side:            side,
+					Leverage:        int(leverage),
+					Margin:          marginFromQuantity(sizingMode, quantity, int(leverage), accountValue),
+					StopLossPrice:   stopLoss,
+					TakeProfitPrice: takeProfit,
+				})
+			case "closePosition":
+				actions = append(actions, ReplayAction{
+					Action: ReplayActionClose,
+					Symbol: symbol,
+				})
+			}
+		}
+	}
+	return actions
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+func TestReplayInterval(t *testing.T) {
+	tests := []struct {
+		elapsed  time.Duration
+		interval string
+	}{
+		{time.Hour, "5m"},
+		{10 * 24 * time.Hour, "15m"},
+		{30 * 24 * time.Hour, "1h"},
+		{200 * 24 * time.Hour, "4h"},
+	}
+	for _, tt := range tests {
+		interval, limit, err := replayInterval(tt.elapsed)
+		if err != nil || interval != tt.interval || limit > maxReplayKlines {
+			t.Errorf("elapsed %v: interval = %q, limit = %d, err = %v, want %q", tt.elapsed, interval, limit, err, tt.interval)
+		}
+	}
+	if _, _, err := replayInterval(365 * 24 * time.Hour); err != ErrReplayTooOld {
+		t.Fatalf("one year: err = %v, want ErrReplayTooOld", err)
+	}
+}
+
+func TestExtractReplayActions(t *testing.T) {
+	logs := []models.LLMLog{
+		{ToolCalls: `[{"function":"getKlines","arguments":{"symbol":"BTCUSDT"}},` +
+			`{"function":"openPosition","arguments":{"symbol":"BTCUSDT","side":"long","leverage":10,"quantity":1000,"stop_loss_price":95,"take_profit_price":120}}]`},
+		{ToolCalls: `[{"function":"closePosition","arguments":{"symbol":"ETHUSDT"}},` +
+			`{"function":"openPosition","arguments":"{broken","error":"参数解析失败"}]`},
+	}
+	actions := extractReplayActions(logs, SizingModeNotional, 5000)
+	if len(actions) != 2 {
+		t.Fatalf("actions = %+v, want 2", actions)
+	}
+	open := actions[0]
+	if open.Action != ReplayActionOpen || open.Symbol != "BTCUSDT" || open.Leverage != 10 ||
+		open.Margin != 100 || open.StopLossPrice != 95 || open.TakeProfitPrice != 120 {
+		t.Fatalf("open action = %+v", open)
+	}
+	if actions[1].Action != ReplayActionClose || actions[1].Symbol != "ETHUSDT" {
+		t.Fatalf("close action = %+v", actions[1])
+	}
+}
+
+func TestReplaySimulatorSettleOpen(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := []*exchange.Kline{
+		{OpenTime: start, Open: 100, High: 104, Low: 98, Close: 103},
+		{OpenTime: start.Add(time.Hour), Open: 103, High: 111, Low: 102, Close: 109},
+		{OpenTime: start.Add(2 * time.Hour), Open: 109, High: 110, Low: 90, Close: 92},
+	}
+
+	tests := []struct {
+		name       string
+		side       string
+		stopLoss   float64
+		takeProfit float64
+		reason     string
+		pnl        float64
+	}{
+		{"long take profit", "long", 95, 110, replayExitTakeProfit, 10},
+		{"long stop loss", "long", 99, 0, replayExitStopLoss, -1},
+		{"short stop loss", "short", 105, 90, replayExitStopLoss, -5},
+		{"long still open", "long", 0, 0, replayExitStillOpen, -8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sim := newReplaySimulator(exchange.NewPaperWallet(nil, 1000, zap.NewNop()))
+			if err := sim.open(ctx, "BTCUSDT", tt.side, 1, 1, 100); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			result := &ReplayActionResult{
+				ReplayAction: ReplayAction{Action: ReplayActionOpen, Symbol: "BTCUSDT", Side: tt.side,
+					StopLossPrice: tt.stopLoss, TakeProfitPrice: tt.takeProfit},
+				EntryPrice: 100,
+				Quantity:   1,
+			}
+			sim.settleOpen(ctx, path, result)
+			if result.Error != "" || result.ExitReason != tt.reason || result.HypotheticalPnl != tt.pnl {
+				t.Fatalf("result = %+v, want reason %q pnl %v", result, tt.reason, tt.pnl)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// guardDelistedSymbols 检查交易对是否已下架或暂停交易，返回本周期不再收集行情的交易对及其状态
+//
+// 持仓交易对不可交易时（按配置）立即尝试平仓；平仓失败或未启用自动平仓时冻结仓位并通知，
+// 同一仓位只在首次冻结时通知一次。交易对恢复交易后自动解除冻结。
+func (t *TradingLoop) guardDelistedSymbols(ctx context.Context, tradingConfig *models.TradingConfig) map[string]string {
+	statusCache := make(map[string]string)
+	unavailable := make(map[string]string)
+	check := func(symbol string) (string, bool) {
+		if status, ok := statusCache[symbol]; ok {
+			return status, unavailable[symbol] == ""
+		}
+		status, tradable, err := t.marketService.SymbolTradingStatus(ctx, symbol)
+		statusCache[symbol] = status
+		if err != nil {
+			// 查询失败不能说明交易对已下架，按正常处理
+			t.logger.Warn("[RISK] failed to check symbol status", zap.String("symbol", symbol), zap.Error(err))
+			return status, true
+		}
+		if !tradable {
+			unavailable[symbol] = status
+		}
+		return status, tradable
+	}
+
+	for _, symbol := range tradingConfig.Symbols {
+		if status, tradable := check(symbol); !tradable {
+			t.logger.Warn("[RISK] symbol is not trading, skip market data collection",
+				zap.String("symbol", symbol),
+				zap.String("status", status))
+		}
+	}
+
+	positions, err := t.positionService.GetAllPositions(ctx)
+	if err != nil {
+		t.logger.Warn("[RISK] failed to get positions for delisting check", zap.Error(err))
+		return unavailable
+	}
+
+	closed := false
+	for i := range positions {
+		position := &positions[i]
+		status, tradable := check(position.Symbol)
+		if tradable {
+			if position.IsFrozen() {
+				t.logger.Info("[RISK] symbol resumed trading, unfreeze position", zap.String("symbol", position.Symbol))
+				if err := t.positionService.SetDelistedStatus(ctx, position.ID, ""); err != nil {
+					t.logger.Warn("[RISK] failed to unfreeze position", zap.String("symbol", position.Symbol), zap.Error(err))
+				}
+			}
+			continue
+		}
+
+		t.logger.Warn("[RISK] position symbol is not trading",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.String("status", status))
+
+		var closeErr error
+		if t.conf.Trading.CloseDelistedPositions {
+			reason := fmt.Sprintf("%s 交易状态变为 %s（下架或暂停交易），系统自动平仓", position.Symbol, status)
+			trade, err := t.agentService.ReducePosition(ctx, position, 1, models.CloseReasonRiskManagement, reason)
+			if err == nil {
+				closed = true
+				t.notifyService.Notify(fmt.Sprintf("🚫 %s %s %s，盈亏 $%.2f", position.Symbol, position.Side, reason, trade.Pnl))
+				continue
+			}
+			closeErr = err
+			t.logger.Error("[RISK] failed to close delisted position",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+		}
+
+		if position.IsFrozen() {
+			continue
+		}
+		if err := t.positionService.SetDelistedStatus(ctx, position.ID, status); err != nil {
+			t.logger.Error("[RISK] failed to freeze delisted position", zap.String("symbol", position.Symbol), zap.Error(err))
+		}
+		msg := fmt.Sprintf("🚫 %s %s 交易状态变为 %s（下架或暂停交易），仓位已冻结", position.Symbol, position.Side, status)
+		if closeErr != nil {
+			msg += fmt.Sprintf("，自动平仓失败：%v", closeErr)
+		}
+		t.notifyService.Notify(msg + "。请尽快在交易所人工处理")
+	}
+
+	if closed {
+		if err := t.positionService.SyncPositions(ctx); err != nil {
+			t.logger.Warn("[RISK] failed to sync positions after delisted close", zap.Error(err))
+		}
+	}
+	return unavailable
+}
+
+// withoutSymbols 返回剔除指定交易对后的交易配置副本，用于跳过不可交易交易对的行情收集
+func withoutSymbols(tradingConfig *models.TradingConfig, excluded map[string]string) *models.TradingConfig {
+	if len(excluded) == 0 {
+		return tradingConfig
+	}
+	filtered := *tradingConfig
+	filtered.Symbols = slices.DeleteFunc(slices.Clone(tradingConfig.Symbols), func(symbol string) bool {
+		_, ok := excluded[symbol]
+		return ok
+	})
+	return &filtered
+}
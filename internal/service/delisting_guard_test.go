@@ -0,0 +1,24 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestWithoutSymbols(t *testing.T) {
+	tradingConfig := &models.TradingConfig{Symbols: []string{"BTCUSDT", "ETHUSDT", "XYZUSDT"}}
+
+	if got := withoutSymbols(tradingConfig, nil); got != tradingConfig {
+		t.Fatal("no excluded symbols should return the original config")
+	}
+
+	filtered := withoutSymbols(tradingConfig, map[string]string{"XYZUSDT": "SETTLING"})
+	if !slices.Equal(filtered.Symbols, []string{"BTCUSDT", "ETHUSDT"}) {
+		t.Fatalf("filtered symbols = %v", filtered.Symbols)
+	}
+	if len(tradingConfig.Symbols) != 3 {
+		t.Fatalf("original symbols should be untouched, got %v", tradingConfig.Symbols)
+	}
+}
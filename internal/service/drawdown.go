@@ -0,0 +1,115 @@
+package service
+
+import (
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// DrawdownPoint 水下曲线上的一个点
+type DrawdownPoint struct {
+	Timestamp       int64     `json:"timestamp"` // 秒时间戳
+	Time            time.Time `json:"time"`
+	Balance         float64   `json:"balance"`          // 当时净值
+	PeakBalance     float64   `json:"peak_balance"`     // 截至当时的峰值
+	DrawdownPercent float64   `json:"drawdown_percent"` // 距峰值回撤（<=0）
+}
+
+// DrawdownPeriod 一段回撤区间：从峰值开始，到重新回到峰值（或至今未恢复）结束
+type DrawdownPeriod struct {
+	PeakTime        time.Time  `json:"peak_time"`
+	PeakBalance     float64    `json:"peak_balance"`
+	TroughTime      time.Time  `json:"trough_time"`
+	TroughBalance   float64    `json:"trough_balance"`
+	RecoveryTime    *time.Time `json:"recovery_time,omitempty"` // 未恢复时为空
+	Recovered       bool       `json:"recovered"`
+	DrawdownPercent float64    `json:"drawdown_percent"` // 区间最大回撤（<=0）
+	DurationHours   float64    `json:"duration_hours"`   // 水下持续时间（未恢复时计算到最后一条记录）
+}
+
+// DrawdownAnalysis 回撤分析结果
+type DrawdownAnalysis struct {
+	Series            []DrawdownPoint `json:"series"`
+	MaxDrawdown       *DrawdownPeriod `json:"max_drawdown"`       // 回撤幅度最大的区间
+	LongestUnderwater *DrawdownPeriod `json:"longest_underwater"` // 水下时间最长的区间
+	CurrentDrawdown   float64         `json:"current_drawdown"`   // 当前距峰值回撤
+	PeriodCount       int             `json:"period_count"`       // 回撤区间数量
+}
+
+// analyzeDrawdown 基于按时间升序排列的账户历史计算水下曲线与回撤区间
+func analyzeDrawdown(histories []models.AccountHistory) *DrawdownAnalysis {
+	analysis := &DrawdownAnalysis{
+		Series: make([]DrawdownPoint, 0, len(histories)),
+	}
+	if len(histories) == 0 {
+		return analysis
+	}
+
+	var periods []*DrawdownPeriod
+	var current *DrawdownPeriod
+
+	peak := histories[0].TotalBalance
+	peakTime := histories[0].RecordedAt
+
+	for _, h := range histories {
+		balance := h.TotalBalance
+
+		if balance >= peak {
+			// 创新高或回到峰值，结束当前回撤区间
+			if current != nil {
+				recoveredAt := h.RecordedAt
+				current.RecoveryTime = &recoveredAt
+				current.Recovered = true
+				current.DurationHours = recoveredAt.Sub(current.PeakTime).Hours()
+				current = nil
+			}
+			peak = balance
+			peakTime = h.RecordedAt
+		}
+
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = (balance - peak) / peak * 100
+		}
+
+		if drawdown < 0 {
+			if current == nil {
+				current = &DrawdownPeriod{
+					PeakTime:      peakTime,
+					PeakBalance:   peak,
+					TroughTime:    h.RecordedAt,
+					TroughBalance: balance,
+				}
+				periods = append(periods, current)
+			}
+			if drawdown < current.DrawdownPercent {
+				current.DrawdownPercent = drawdown
+				current.TroughTime = h.RecordedAt
+				current.TroughBalance = balance
+			}
+			current.DurationHours = h.RecordedAt.Sub(current.PeakTime).Hours()
+		}
+
+		analysis.Series = append(analysis.Series, DrawdownPoint{
+			Timestamp:       h.RecordedAt.Unix(),
+			Time:            h.RecordedAt,
+			Balance:         balance,
+			PeakBalance:     peak,
+			DrawdownPercent: drawdown,
+		})
+	}
+
+	analysis.PeriodCount = len(periods)
+	analysis.CurrentDrawdown = analysis.Series[len(analysis.Series)-1].DrawdownPercent
+
+	for _, p := range periods {
+		if analysis.MaxDrawdown == nil || p.DrawdownPercent < analysis.MaxDrawdown.DrawdownPercent {
+			analysis.MaxDrawdown = p
+		}
+		if analysis.LongestUnderwater == nil || p.DurationHours > analysis.LongestUnderwater.DurationHours {
+			analysis.LongestUnderwater = p
+		}
+	}
+
+	return analysis
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// forcedFlatDrawdownOffset 强制清仓阈值比最大回撤高出的百分点
+const forcedFlatDrawdownOffset = 5
+
+// forcedFlatDrawdownPercent 强制清仓回撤阈值
+func forcedFlatDrawdownPercent(maxDrawdownPercent float64) float64 {
+	return maxDrawdownPercent + forcedFlatDrawdownOffset
+}
+
+// drawdownTier 回撤分级
+type drawdownTier int
+
+const (
+	drawdownTierNormal     drawdownTier = iota // 正常
+	drawdownTierWarning                        // 达到最大回撤：禁止开新仓并自动减仓
+	drawdownTierForcedFlat                     // 达到强制清仓阈值：平掉全部持仓
+)
+
+// evaluateDrawdownTier 根据最大回撤配置和当前距峰值回撤（负数百分比）判断所处分级，maxDrawdownPercent<=0 表示不限制
+func evaluateDrawdownTier(maxDrawdownPercent, drawdownFromPeak float64) drawdownTier {
+	if maxDrawdownPercent <= 0 {
+		return drawdownTierNormal
+	}
+	drawdown := -drawdownFromPeak
+	switch {
+	case drawdown >= forcedFlatDrawdownPercent(maxDrawdownPercent):
+		return drawdownTierForcedFlat
+	case drawdown >= maxDrawdownPercent:
+		return drawdownTierWarning
+	default:
+		return drawdownTierNormal
+	}
+}
+
+// enforceDrawdownLimits 按回撤分级强制执行风控，不依赖 AI 的决策
+//
+// 达到最大回撤时按配置比例对所有持仓减仓（同一峰值只减一次，新开仓由开仓规则拦截），
+// 达到强制清仓阈值时平掉全部持仓。返回是否执行了平仓操作。
+func (t *TradingLoop) enforceDrawdownLimits(ctx context.Context, tradingConfig *models.TradingConfig, metrics *AccountMetrics, positions []models.Position) bool {
+	if !t.conf.Trading.EnforceDrawdownLimits || metrics == nil || len(positions) == 0 {
+		return false
+	}
+
+	maxDrawdown := tradingConfig.MaxDrawdownPercent
+	drawdown := -metrics.DrawdownFromPeak
+
+	switch evaluateDrawdownTier(maxDrawdown, metrics.DrawdownFromPeak) {
+	case drawdownTierForcedFlat:
+		forcedFlat := forcedFlatDrawdownPercent(maxDrawdown)
+		reason := fmt.Sprintf("账户距峰值回撤 %.2f%% 达到强制清仓阈值 %.2f%%，系统自动清仓", drawdown, forcedFlat)
+		t.logger.Warn("[RISK] forced flat drawdown reached, closing all positions",
+			zap.Float64("drawdown", drawdown),
+			zap.Float64("forced_flat_percent", forcedFlat),
+			zap.Int("positions", len(positions)))
+
+		trades, err := t.agentService.CloseAllPositions(ctx, models.CloseReasonRiskManagement, reason)
+		if err != nil {
+			t.logger.Error("[RISK] failed to close all positions", zap.Error(err))
+		}
+		t.notifyService.Notify(fmt.Sprintf("🔴 %s，已平仓 %d/%d 个持仓%s",
+			reason, len(trades), len(positions), formatNotifyError(err)))
+		return len(trades) > 0
+
+	case drawdownTierWarning:
+		fraction := t.conf.Trading.DrawdownReduceFraction
+		if fraction <= 0 || !t.markDeleveraged(metrics.PeakBalance) {
+			return false
+		}
+		reason := fmt.Sprintf("账户距峰值回撤 %.2f%% 达到最大回撤 %.2f%%，系统自动减仓 %.0f%%", drawdown, maxDrawdown, fraction*100)
+		t.logger.Warn("[RISK] max drawdown reached, reducing all positions",
+			zap.Float64("drawdown", drawdown),
+			zap.Float64("max_drawdown_percent", maxDrawdown),
+			zap.Float64("fraction", fraction))
+
+		var reduced []string
+		var errs []error
+		for i := range positions {
+			position := &positions[i]
+			if _, err := t.agentService.ReducePosition(ctx, position, fraction, models.CloseReasonRiskManagement, reason); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", position.Symbol, err))
+				continue
+			}
+			reduced = append(reduced, position.Symbol)
+		}
+		if err := t.positionService.SyncPositions(ctx); err != nil {
+			t.logger.Warn("[RISK] failed to sync positions after deleverage", zap.Error(err))
+		}
+
+		err := errors.Join(errs...)
+		if err != nil {
+			t.logger.Error("[RISK] failed to reduce some positions", zap.Error(err))
+		}
+		t.notifyService.Notify(fmt.Sprintf("⚠️ %s，已减仓：%s%s", reason, strings.Join(reduced, ", "), formatNotifyError(err)))
+		return len(reduced) > 0
+	}
+	return false
+}
+
+// markDeleveraged 记录已在该峰值净值下自动减仓，已记录过时返回 false，避免回撤持续期间每个周期反复减仓
+func (t *TradingLoop) markDeleveraged(peakBalance float64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.deleveragedPeak == peakBalance {
+		return false
+	}
+	t.deleveragedPeak = peakBalance
+	return true
+}
+
+// formatNotifyError 通知中附带的错误说明
+func formatNotifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("（部分失败：%v）", err)
+}
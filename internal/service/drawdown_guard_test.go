@@ -0,0 +1,39 @@
+package service
+
+import "testing"
+
+func TestEvaluateDrawdownTier(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxDrawdown      float64
+		drawdownFromPeak float64
+		want             drawdownTier
+	}{
+		{"disabled", 0, -50, drawdownTierNormal},
+		{"below warning", 10, -9.99, drawdownTierNormal},
+		{"at warning", 10, -10, drawdownTierWarning},
+		{"between tiers", 10, -14.5, drawdownTierWarning},
+		{"at forced flat", 10, -15, drawdownTierForcedFlat},
+		{"new peak", 10, 0, drawdownTierNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluateDrawdownTier(tt.maxDrawdown, tt.drawdownFromPeak); got != tt.want {
+				t.Errorf("evaluateDrawdownTier(%v, %v) = %v, want %v", tt.maxDrawdown, tt.drawdownFromPeak, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkDeleveragedOncePerPeak(t *testing.T) {
+	loop := &TradingLoop{}
+	if !loop.markDeleveraged(1000) {
+		t.Fatal("first deleverage at a peak should be allowed")
+	}
+	if loop.markDeleveraged(1000) {
+		t.Fatal("second deleverage at the same peak should be skipped")
+	}
+	if !loop.markDeleveraged(1200) {
+		t.Fatal("deleverage after a new peak should be allowed")
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// hourlyHistory 按小时间隔生成账户历史
+func hourlyHistory(balances ...float64) []models.AccountHistory {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	histories := make([]models.AccountHistory, 0, len(balances))
+	for i, balance := range balances {
+		histories = append(histories, models.AccountHistory{TotalBalance: balance, RecordedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+	return histories
+}
+
+func TestAnalyzeDrawdown(t *testing.T) {
+	type period struct {
+		peak, trough, percent, hours float64
+		recovered                    bool
+	}
+	tests := []struct {
+		name     string
+		balances []float64
+		periods  int
+		current  float64
+		max      *period
+		longest  *period
+	}{
+		{name: "empty history"},
+		{name: "single record", balances: []float64{100}},
+		{
+			name:     "recovered then still underwater",
+			balances: []float64{100, 90, 80, 100, 110, 99, 105},
+			periods:  2,
+			current:  (105.0 - 110) / 110 * 100,
+			max:      &period{peak: 100, trough: 80, percent: -20, hours: 3, recovered: true},
+			longest:  &period{peak: 100, trough: 80, percent: -20, hours: 3, recovered: true},
+		},
+		{
+			name:     "deepest and longest are different periods",
+			balances: []float64{100, 95, 95, 95, 95, 100, 70, 100},
+			periods:  2,
+			max:      &period{peak: 100, trough: 70, percent: -30, hours: 2, recovered: true},
+			longest:  &period{peak: 100, trough: 95, percent: -5, hours: 5, recovered: true},
+		},
+	}
+
+	check := func(t *testing.T, label string, got *DrawdownPeriod, want *period) {
+		t.Helper()
+		if want == nil {
+			if got != nil {
+				t.Fatalf("%s = %+v, want none", label, got)
+			}
+			return
+		}
+		if got == nil {
+			t.Fatalf("%s missing, want %+v", label, want)
+		}
+		if got.PeakBalance != want.peak || got.TroughBalance != want.trough || math.Abs(got.DrawdownPercent-want.percent) > 1e-9 ||
+			got.DurationHours != want.hours || got.Recovered != want.recovered || (got.RecoveryTime != nil) != want.recovered {
+			t.Fatalf("%s = %+v, want %+v", label, got, want)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := analyzeDrawdown(hourlyHistory(tt.balances...))
+			if len(analysis.Series) != len(tt.balances) {
+				t.Fatalf("series = %d points, want %d", len(analysis.Series), len(tt.balances))
+			}
+			if analysis.PeriodCount != tt.periods || math.Abs(analysis.CurrentDrawdown-tt.current) > 1e-9 {
+				t.Fatalf("periods = %d, current = %v, want %d and %v", analysis.PeriodCount, analysis.CurrentDrawdown, tt.periods, tt.current)
+			}
+			check(t, "max drawdown", analysis.MaxDrawdown, tt.max)
+			check(t, "longest underwater", analysis.LongestUnderwater, tt.longest)
+		})
+	}
+}
+
+// 未恢复的回撤区间持续时间计算到最后一条记录
+func TestAnalyzeDrawdownOpenPeriod(t *testing.T) {
+	analysis := analyzeDrawdown(hourlyHistory(100, 120, 90, 96))
+	got := analysis.MaxDrawdown
+	if got == nil || got.Recovered || got.RecoveryTime != nil || got.DurationHours != 2 || got.PeakBalance != 120 || got.TroughBalance != 90 {
+		t.Fatalf("open period = %+v, want unrecovered 120 -> 90 lasting 2h", got)
+	}
+	if point := analysis.Series[3]; point.PeakBalance != 120 || math.Abs(point.DrawdownPercent-(-20)) > 1e-9 {
+		t.Fatalf("last point = %+v, want peak 120 and -20%%", point)
+	}
+}
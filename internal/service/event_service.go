@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/repo"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// eventContextKey 事件所属周期和决策在 context 中的键
+type eventContextKey int
+
+const (
+	eventIterationKey eventContextKey = iota
+	eventDecisionKey
+)
+
+// withEventIteration 标记 ctx 属于指定交易周期，之后记录的事件带上周期编号
+func withEventIteration(ctx context.Context, iteration int) context.Context {
+	return context.WithValue(ctx, eventIterationKey, iteration)
+}
+
+// withEventDecision 标记 ctx 属于指定决策，之后记录的事件带上决策ID
+func withEventDecision(ctx context.Context, decisionID string) context.Context {
+	return context.WithValue(ctx, eventDecisionKey, decisionID)
+}
+
+// EventService 交易循环运行事件的记录和查询
+type EventService struct {
+	logger *zap.Logger
+	*repo.EventRepo
+}
+
+// NewEventService 创建运行事件服务
+func NewEventService(db *gorm.DB, logger *zap.Logger) *EventService {
+	return &EventService{
+		logger:    logger,
+		EventRepo: repo.NewEventRepo(db),
+	}
+}
+
+// Record 追加一条运行事件，周期编号和决策ID从 ctx 读取；data 序列化为 JSON 保存
+//
+// 写入失败只记录日志，不影响交易流程。
+func (s *EventService) Record(ctx context.Context, eventType models.EventType, symbol, message string, data interface{}) {
+	if s == nil {
+		return
+	}
+	event := &models.Event{
+		ID:         ulid.Make().String(),
+		Type:       eventType,
+		Symbol:     symbol,
+		Message:    message,
+		OccurredAt: time.Now(),
+	}
+	event.Iteration, _ = ctx.Value(eventIterationKey).(int)
+	event.DecisionID, _ = ctx.Value(eventDecisionKey).(string)
+	if data != nil {
+		if raw, err := json.Marshal(data); err == nil {
+			event.Data = string(raw)
+		}
+	}
+	if err := s.EventRepo.Create(context.WithoutCancel(ctx), event); err != nil {
+		s.logger.Warn("failed to record event",
+			zap.String("type", string(eventType)),
+			zap.Error(err))
+	}
+}
+
+// History 查询时间区间内的运行事件，按发生时间升序
+func (s *EventService) History(ctx context.Context, from, to time.Time, limit int) ([]models.Event, error) {
+	return s.EventRepo.FindBetween(ctx, from, to, limit)
+}
+
+// recordToolCallEvent 记录一次工具调用及其结果
+func (s *AgentService) recordToolCallEvent(ctx context.Context, action, summary string, args map[string]interface{}, result *ToolResult) {
+	symbol, _ := args["symbol"].(string)
+	data := map[string]interface{}{
+		"action":  action,
+		"args":    args,
+		"success": result != nil && result.Success,
+	}
+	if message := result.errorMessage(); message != "" {
+		data["error"] = message
+	}
+	s.events.Record(ctx, models.EventToolCall, symbol, summary, data)
+}
+
+// recordTradeEvent 记录市价成交
+func (s *AgentService) recordTradeEvent(ctx context.Context, trade *models.Trade) {
+	s.events.Record(ctx, models.EventTrade, trade.Symbol,
+		fmt.Sprintf("%s %s %.6g @ %.6g", trade.Type, trade.Side, trade.Quantity, trade.Price),
+		map[string]interface{}{
+			"trade_id":    trade.ID,
+			"order_id":    trade.OrderID,
+			"type":        trade.Type,
+			"side":        trade.Side,
+			"price":       trade.Price,
+			"quantity":    trade.Quantity,
+			"pnl":         trade.Pnl,
+			"reason_code": trade.ReasonCode,
+			"reason":      trade.Reason,
+		})
+}
+
+// recordOrderEvent 记录挂出的止损止盈单
+func (s *AgentService) recordOrderEvent(ctx context.Context, order *models.Order) {
+	s.events.Record(ctx, models.EventOrderPlaced, order.Symbol,
+		fmt.Sprintf("%s %s @ %.6g", order.OrderType, order.PositionSide, order.TriggerPrice),
+		map[string]interface{}{
+			"order_id":      order.ID,
+			"exchange_id":   order.ExchangeID,
+			"position_id":   order.PositionID,
+			"order_type":    order.OrderType,
+			"trigger_price": order.TriggerPrice,
+			"limit_price":   order.LimitPrice,
+			"quantity":      order.Quantity,
+			"reason":        order.Reason,
+		})
+}
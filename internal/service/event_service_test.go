@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestEventServiceRecord(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+
+	ctx := withEventDecision(withEventIteration(context.Background(), 7), "decision-1")
+	agent.events.Record(ctx, models.EventToolCall, "BTCUSDT", "openPosition", map[string]interface{}{"success": true})
+	agent.events.Record(context.Background(), models.EventError, "", "boom", nil)
+
+	now := time.Now()
+	events, err := agent.GetEventHistory(context.Background(), now.Add(-time.Minute), now.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	first := events[0]
+	if first.Type != models.EventToolCall || first.Iteration != 7 || first.DecisionID != "decision-1" || first.Data != `{"success":true}` {
+		t.Fatalf("unexpected event: %+v", first)
+	}
+	if events[1].Iteration != 0 || events[1].DecisionID != "" {
+		t.Fatalf("event without context should not carry iteration: %+v", events[1])
+	}
+
+	past, err := agent.GetEventHistory(context.Background(), now.Add(-2*time.Hour), now.Add(-time.Hour), 10)
+	if err != nil || len(past) != 0 {
+		t.Fatalf("events outside the window should be excluded: %d %v", len(past), err)
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// ExchangeHealthService 交易所调用健康监控，失败率超过阈值时告警，恢复后再通知一次
+type ExchangeHealthService struct {
+	logger        *zap.Logger
+	binanceClient *exchange.BinanceClient
+	notifyService *NotifyService
+	cooldown      time.Duration // 两次降级告警的最小间隔，避免在故障边缘反复告警
+	maxTimeDrift  time.Duration // 本地时钟与服务器时间的告警阈值
+
+	mu           sync.Mutex
+	lastAlertAt  time.Time
+	alerted      bool // 已发送降级告警，恢复时需要通知
+	driftAlerted bool // 已发送时钟偏差告警，恢复时需要通知
+}
+
+// NewExchangeHealthService 创建交易所健康监控，未启用失败率监控时只返回空状态
+func NewExchangeHealthService(binanceClient *exchange.BinanceClient, notifyService *NotifyService,
+	conf *config.Config, logger *zap.Logger) *ExchangeHealthService {
+	s := &ExchangeHealthService{
+		logger:        logger,
+		binanceClient: binanceClient,
+		notifyService: notifyService,
+		cooldown:      conf.Binance.ErrorAlert.Cooldown(),
+		maxTimeDrift:  conf.Binance.MaxTimeDrift(),
+	}
+	if monitor := binanceClient.ErrorMonitor(); monitor != nil {
+		monitor.OnStateChange(s.handleStateChange)
+	}
+	return s
+}
+
+// Status 返回交易所调用失败率状态
+func (s *ExchangeHealthService) Status() exchange.ErrorRateStatus {
+	return s.binanceClient.ErrorRate()
+}
+
+// handleStateChange 处理降级状态变化
+func (s *ExchangeHealthService) handleStateChange(status exchange.ErrorRateStatus) {
+	windowMinutes := status.WindowSeconds / 60
+	if status.Degraded {
+		s.logger.Warn("exchange calls degraded",
+			zap.Int("calls", status.Calls),
+			zap.Int("failures", status.Failures),
+			zap.Float64("failure_rate", status.FailureRate),
+			zap.String("last_error", status.LastError))
+
+		s.mu.Lock()
+		now := time.Now()
+		if !s.lastAlertAt.IsZero() && now.Sub(s.lastAlertAt) < s.cooldown {
+			s.mu.Unlock()
+			s.logger.Info("exchange degraded alert throttled", zap.Time("last_alert_at", s.lastAlertAt))
+			return
+		}
+		s.lastAlertAt = now
+		s.alerted = true
+		s.mu.Unlock()
+
+		s.notifyService.Notify(fmt.Sprintf("⚠️ 交易所调用异常：最近%d分钟失败率 %.1f%%（%d/%d），系统可能无法正常交易，请检查 API Key、IP 限制或交易所维护状态。最近错误：%s",
+			windowMinutes, status.FailureRate, status.Failures, status.Calls, status.LastError))
+		return
+	}
+
+	s.logger.Info("exchange calls recovered",
+		zap.Int("calls", status.Calls),
+		zap.Float64("failure_rate", status.FailureRate))
+
+	s.mu.Lock()
+	alerted := s.alerted
+	s.alerted = false
+	s.mu.Unlock()
+	if alerted {
+		s.notifyService.Notify(fmt.Sprintf("✅ 交易所调用已恢复：最近%d分钟失败率 %.1f%%", windowMinutes, status.FailureRate))
+	}
+}
+
+// StartTimeSyncWorker 启动与交易所服务器对时的worker
+//
+// 启动时立即对时一次，之后按间隔重新对时；偏移写入交易所客户端，签名请求和成交时间换算随之校正。
+func (s *ExchangeHealthService) StartTimeSyncWorker(ctx context.Context, interval time.Duration) {
+	s.logger.Info("starting time sync worker", zap.Duration("interval", interval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.syncServerTime(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.syncServerTime(ctx)
+			case <-ctx.Done():
+				s.logger.Info("time sync worker stopped by context")
+				return
+			}
+		}
+	}()
+}
+
+// syncServerTime 与服务器对时，偏差超过阈值时告警，回落到阈值以内后通知一次
+func (s *ExchangeHealthService) syncServerTime(ctx context.Context) {
+	offset, err := s.binanceClient.SyncServerTime(ctx)
+	if err != nil {
+		s.logger.Warn("failed to sync exchange server time", zap.Error(err))
+		return
+	}
+
+	drifted := offset.Abs() > s.maxTimeDrift
+	s.mu.Lock()
+	notify := drifted != s.driftAlerted
+	s.driftAlerted = drifted
+	s.mu.Unlock()
+
+	if !drifted {
+		s.logger.Debug("exchange server time synced", zap.Duration("offset", offset))
+		if notify {
+			s.notifyService.Notify(fmt.Sprintf("✅ 本地时钟与交易所时间偏差已恢复：%dms", offset.Milliseconds()))
+		}
+		return
+	}
+
+	s.logger.Warn("local clock drifts from exchange server time",
+		zap.Duration("offset", offset),
+		zap.Duration("max_drift", s.maxTimeDrift))
+	if notify {
+		s.notifyService.Notify(fmt.Sprintf("⚠️ 本地时钟与交易所时间偏差 %dms（阈值 %dms），已按服务器时间校正签名时间戳，建议检查服务器的 NTP 同步",
+			offset.Milliseconds(), s.maxTimeDrift.Milliseconds()))
+	}
+}
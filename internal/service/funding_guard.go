@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// fundingCostPercent 累计资金费支出占保证金的百分比，净收入或保证金未知时为0
+func fundingCostPercent(accrued, margin float64) float64 {
+	if accrued >= 0 || margin <= 0 {
+		return 0
+	}
+	return -accrued / margin * 100
+}
+
+// fundingLimitExceeded 累计资金费支出占保证金是否超过上限，limitPercent 不大于0时不检查
+func fundingLimitExceeded(accrued, margin, limitPercent float64) bool {
+	return limitPercent > 0 && fundingCostPercent(accrued, margin) > limitPercent
+}
+
+// enforceFundingLimit 开启自动平仓时平掉累计资金费支出超过上限的仓位，返回是否执行了平仓操作
+func (t *TradingLoop) enforceFundingLimit(ctx context.Context, positions []models.Position) bool {
+	limit := t.conf.Trading.MaxCumulativeFundingPercent
+	if limit <= 0 || !t.conf.Trading.CloseOnFundingLimit || len(positions) == 0 {
+		return false
+	}
+
+	funding := t.accountService.PositionFunding(ctx, positions)
+	closed := false
+	for i := range positions {
+		position := &positions[i]
+		accrued, ok := funding[position.ID]
+		if !ok || !fundingLimitExceeded(accrued, position.Margin, limit) {
+			continue
+		}
+
+		costPercent := fundingCostPercent(accrued, position.Margin)
+		reason := fmt.Sprintf("累计资金费支出 $%.2f 占保证金 %.2f%%，超过上限 %.2f%%，系统自动平仓", -accrued, costPercent, limit)
+		t.logger.Warn("[RISK] cumulative funding limit reached, closing position",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.Float64("accrued_funding", accrued),
+			zap.Float64("funding_cost_percent", costPercent),
+			zap.Float64("limit_percent", limit))
+
+		trade, err := t.agentService.ReducePosition(ctx, position, 1, models.CloseReasonRiskManagement, reason)
+		if err != nil {
+			t.logger.Error("[RISK] failed to close position over funding limit",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+			t.notifyService.Notify(fmt.Sprintf("💸 %s %s %s，但平仓失败：%v", position.Symbol, position.Side, reason, err))
+			continue
+		}
+		closed = true
+		t.notifyService.Notify(fmt.Sprintf("💸 %s %s %s，盈亏 $%.2f", position.Symbol, position.Side, reason, trade.Pnl))
+	}
+
+	if closed {
+		if err := t.positionService.SyncPositions(ctx); err != nil {
+			t.logger.Warn("[RISK] failed to sync positions after funding limit close", zap.Error(err))
+		}
+	}
+	return closed
+}
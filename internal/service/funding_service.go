@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/repo"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/go-orz/orz"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	fundingSyncPageSize    = 1000
+	fundingSyncMaxPages    = 10
+	fundingInitialLookback = 30 * 24 * time.Hour // 首次同步时回溯的时间范围
+)
+
+// FundingService 资金流水服务，定期从交易所拉取资金费结算和出入金记录
+type FundingService struct {
+	logger *zap.Logger
+
+	*orz.Service
+	*repo.FundingPaymentRepo
+
+	capitalFlowRepo *repo.CapitalFlowRepo
+	exchange        exchange.Exchange
+
+	syncMutex sync.Mutex // 防止并发同步
+	stopChan  chan struct{}
+	stopped   bool
+}
+
+// NewFundingService 创建资金费服务
+func NewFundingService(db *gorm.DB, exchange exchange.Exchange, logger *zap.Logger) *FundingService {
+	return &FundingService{
+		logger:             logger,
+		Service:            orz.NewService(db),
+		FundingPaymentRepo: repo.NewFundingPaymentRepo(db),
+		capitalFlowRepo:    repo.NewCapitalFlowRepo(db),
+		exchange:           exchange,
+	}
+}
+
+// nextIncomePageStart 整页返回后下一页的起始时间
+//
+// 从本页最后一条记录的时间戳开始而不是其后1毫秒，同一毫秒内跨页的记录不会漏掉，重复返回的记录按流水ID去重。
+// 整页记录都在 startTime 同一毫秒时无法按时间推进，只能跳过该毫秒，避免反复请求同一页。
+func nextIncomePageStart(startTime, pageEnd int64) int64 {
+	if pageEnd > startTime {
+		return pageEnd
+	}
+	return startTime + 1
+}
+
+// SyncFundingPayments 增量同步资金费结算记录
+func (s *FundingService) SyncFundingPayments(ctx context.Context) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	latest, err := s.FundingPaymentRepo.FindLatestPaidAt(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get latest funding payment: %w", err)
+	}
+
+	startTime := time.Now().Add(-fundingInitialLookback).UnixMilli()
+	if !latest.IsZero() {
+		startTime = latest.UnixMilli() + 1
+	}
+
+	synced := 0
+	for page := 0; page < fundingSyncMaxPages; page++ {
+		records, err := s.exchange.GetIncomeHistory(ctx, exchange.IncomeTypeFundingFee, startTime, fundingSyncPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get funding income history: %w", err)
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		pageEnd := startTime
+		payments := make([]models.FundingPayment, 0, len(records))
+		for _, r := range records {
+			payments = append(payments, models.FundingPayment{
+				ID:     ulid.Make().String(),
+				TranID: r.TranID,
+				Symbol: r.Symbol,
+				Amount: r.Income,
+				Asset:  r.Asset,
+				PaidAt: time.UnixMilli(r.Time),
+			})
+			pageEnd = max(pageEnd, r.Time)
+		}
+
+		if err := s.FundingPaymentRepo.CreateIgnoreDuplicates(ctx, payments); err != nil {
+			return fmt.Errorf("failed to save funding payments: %w", err)
+		}
+		synced += len(payments)
+
+		if len(records) < fundingSyncPageSize {
+			break
+		}
+		startTime = nextIncomePageStart(startTime, pageEnd)
+	}
+
+	if synced > 0 {
+		s.logger.Info("funding payments synced", zap.Int("count", synced))
+	}
+	return nil
+}
+
+// SyncCapitalFlows 增量同步合约账户的划入划出记录，作为出入金用于计算净投入资金
+func (s *FundingService) SyncCapitalFlows(ctx context.Context) error {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	latest, err := s.capitalFlowRepo.FindLatestOccurredAt(ctx, models.CapitalFlowSourceExchange)
+	if err != nil {
+		return fmt.Errorf("failed to get latest capital flow: %w", err)
+	}
+
+	startTime := time.Now().Add(-fundingInitialLookback).UnixMilli()
+	if !latest.IsZero() {
+		startTime = latest.UnixMilli() + 1
+	}
+
+	synced := 0
+	for page := 0; page < fundingSyncMaxPages; page++ {
+		records, err := s.exchange.GetIncomeHistory(ctx, exchange.IncomeTypeTransfer, startTime, fundingSyncPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get transfer income history: %w", err)
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		pageEnd := startTime
+		flows := make([]models.CapitalFlow, 0, len(records))
+		for _, r := range records {
+			tranID := r.TranID
+			flows = append(flows, models.CapitalFlow{
+				ID:         ulid.Make().String(),
+				TranID:     &tranID,
+				Source:     models.CapitalFlowSourceExchange,
+				Amount:     r.Income,
+				Asset:      r.Asset,
+				Note:       r.Info,
+				OccurredAt: time.UnixMilli(r.Time),
+			})
+			pageEnd = max(pageEnd, r.Time)
+		}
+
+		if err := s.capitalFlowRepo.CreateIgnoreDuplicates(ctx, flows); err != nil {
+			return fmt.Errorf("failed to save capital flows: %w", err)
+		}
+		synced += len(flows)
+
+		if len(records) < fundingSyncPageSize {
+			break
+		}
+		startTime = nextIncomePageStart(startTime, pageEnd)
+	}
+
+	if synced > 0 {
+		s.logger.Info("capital flows synced", zap.Int("count", synced))
+	}
+	return nil
+}
+
+// syncAll 同步资金费和出入金记录，互不影响
+func (s *FundingService) syncAll(ctx context.Context) {
+	if err := s.SyncFundingPayments(ctx); err != nil {
+		s.logger.Error("failed to sync funding payments", zap.Error(err))
+	}
+	if err := s.SyncCapitalFlows(ctx); err != nil {
+		s.logger.Error("failed to sync capital flows", zap.Error(err))
+	}
+}
+
+// GetCumulativeFunding 获取累计资金费（正数为净收入，负数为净支出）
+func (s *FundingService) GetCumulativeFunding(ctx context.Context) (float64, error) {
+	return s.FundingPaymentRepo.SumAmount(ctx)
+}
+
+// StartSyncWorker 启动后台资金费同步worker
+func (s *FundingService) StartSyncWorker(ctx context.Context, interval time.Duration) {
+	s.stopChan = make(chan struct{})
+	s.stopped = false
+
+	s.logger.Info("starting funding sync worker", zap.Duration("interval", interval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// 立即执行一次同步
+		s.syncAll(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.syncAll(ctx)
+			case <-s.stopChan:
+				s.logger.Info("funding sync worker stopped")
+				return
+			case <-ctx.Done():
+				s.logger.Info("funding sync worker stopped by context")
+				return
+			}
+		}
+	}()
+}
+
+// StopSyncWorker 停止后台资金费同步worker
+func (s *FundingService) StopSyncWorker() {
+	if !s.stopped && s.stopChan != nil {
+		close(s.stopChan)
+		s.stopped = true
+		s.logger.Info("funding sync worker stop signal sent")
+	}
+}
+
+// estimatePositionFunding 估算持仓在下一次结算时的资金费（正数为收到，负数为支付）
+// 资金费率为正时多头支付空头，为负时空头支付多头
+func estimatePositionFunding(position *models.Position, fundingRate float64) float64 {
+	notional := position.Quantity * position.CurrentPrice
+	if position.Side == "short" {
+		return notional * fundingRate
+	}
+	return -notional * fundingRate
+}
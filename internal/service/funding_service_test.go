@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// incomeHistoryExchange 按时间顺序分页返回资金费流水，并记录每次请求的 startTime
+//
+// overlap 大于0时返回的记录从 startTime 前 overlap 毫秒开始，模拟交易所在分页边界重复返回上一页的记录
+type incomeHistoryExchange struct {
+	exchange.Exchange
+	records    []*exchange.IncomeRecord
+	overlap    int64
+	startTimes []int64
+}
+
+func (e *incomeHistoryExchange) GetIncomeHistory(ctx context.Context, incomeType string, startTime int64, limit int) ([]*exchange.IncomeRecord, error) {
+	e.startTimes = append(e.startTimes, startTime)
+	var page []*exchange.IncomeRecord
+	for _, r := range e.records {
+		if r.IncomeType == incomeType && r.Time >= startTime-e.overlap && len(page) < limit {
+			page = append(page, r)
+		}
+	}
+	return page, nil
+}
+
+func TestSyncFundingPayments(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.FundingPayment{}, models.CapitalFlow{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	// 超过一页的资金费流水，每条 -0.01 USDT，间隔1秒
+	count := fundingSyncPageSize + fundingSyncPageSize/2
+	base := time.Now().Add(-time.Hour).UnixMilli()
+	ex := &incomeHistoryExchange{overlap: 1}
+	for i := 0; i < count; i++ {
+		ex.records = append(ex.records, &exchange.IncomeRecord{TranID: int64(i + 1), Symbol: "BTCUSDT",
+			IncomeType: exchange.IncomeTypeFundingFee, Income: -0.01, Asset: "USDT", Time: base + int64(i)*1000})
+	}
+	last := ex.records[count-1].Time
+	fundingService := NewFundingService(db, ex, zap.NewNop())
+	ctx := context.Background()
+
+	if err := fundingService.SyncFundingPayments(ctx); err != nil {
+		t.Fatalf("sync funding payments: %v", err)
+	}
+	if len(ex.startTimes) != 2 {
+		t.Fatalf("requests = %d, want 2 pages", len(ex.startTimes))
+	}
+	if want := ex.records[fundingSyncPageSize-1].Time; ex.startTimes[1] != want {
+		t.Fatalf("second page start = %d, want %d (the last record of the first page)", ex.startTimes[1], want)
+	}
+
+	var stored int64
+	if err := db.Model(&models.FundingPayment{}).Count(&stored).Error; err != nil || stored != int64(count) {
+		t.Fatalf("stored = %d, err = %v, want %d without the duplicated boundary record", stored, err, count)
+	}
+	total, err := fundingService.GetCumulativeFunding(ctx)
+	if err != nil || math.Abs(total-(-0.01*float64(count))) > 1e-9 {
+		t.Fatalf("cumulative funding = %v, err = %v, want %v", total, err, -0.01*float64(count))
+	}
+
+	// 再次同步从最后一条记录之后开始，重复返回的记录按流水ID去重
+	if err := fundingService.SyncFundingPayments(ctx); err != nil {
+		t.Fatalf("sync funding payments again: %v", err)
+	}
+	if got := ex.startTimes[len(ex.startTimes)-1]; got != last+1 {
+		t.Fatalf("incremental start = %d, want %d", got, last+1)
+	}
+	if err := db.Model(&models.FundingPayment{}).Count(&stored).Error; err != nil || stored != int64(count) {
+		t.Fatalf("stored after resync = %d, err = %v, want %d", stored, err, count)
+	}
+}
+
+// 分页边界落在同一毫秒的一组记录中间时，下一页从该毫秒重新开始，不漏掉跨页的记录
+func TestSyncFundingPaymentsSameMillisecondBoundary(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.FundingPayment{}, models.CapitalFlow{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	// 第一页最后两条和第二页前三条都在同一毫秒结算
+	count := fundingSyncPageSize + 10
+	base := time.Now().Add(-time.Hour).UnixMilli()
+	ex := &incomeHistoryExchange{}
+	for i := 0; i < count; i++ {
+		paidAt := base + int64(i)*1000
+		if i >= fundingSyncPageSize-2 && i < fundingSyncPageSize+3 {
+			paidAt = base + int64(fundingSyncPageSize-2)*1000
+		}
+		ex.records = append(ex.records, &exchange.IncomeRecord{TranID: int64(i + 1), Symbol: "BTCUSDT",
+			IncomeType: exchange.IncomeTypeFundingFee, Income: -0.01, Asset: "USDT", Time: paidAt})
+		ex.records = append(ex.records, &exchange.IncomeRecord{TranID: int64(count + i + 1),
+			IncomeType: exchange.IncomeTypeTransfer, Income: 1, Asset: "USDT", Time: paidAt})
+	}
+	fundingService := NewFundingService(db, ex, zap.NewNop())
+	ctx := context.Background()
+
+	if err := fundingService.SyncFundingPayments(ctx); err != nil {
+		t.Fatalf("sync funding payments: %v", err)
+	}
+	var stored int64
+	if err := db.Model(&models.FundingPayment{}).Count(&stored).Error; err != nil || stored != int64(count) {
+		t.Fatalf("stored funding payments = %d, err = %v, want %d", stored, err, count)
+	}
+
+	if err := fundingService.SyncCapitalFlows(ctx); err != nil {
+		t.Fatalf("sync capital flows: %v", err)
+	}
+	if err := db.Model(&models.CapitalFlow{}).Count(&stored).Error; err != nil || stored != int64(count) {
+		t.Fatalf("stored capital flows = %d, err = %v, want %d", stored, err, count)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// enforceMaxHoldingTime 平掉持仓时间达到最长持仓小时数的仓位，返回是否执行了平仓操作
+func (t *TradingLoop) enforceMaxHoldingTime(ctx context.Context, tradingConfig *models.TradingConfig, positions []models.Position) bool {
+	maxHours := tradingConfig.MaxHoldingHours
+	if maxHours <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	closed := false
+	for i := range positions {
+		position := &positions[i]
+		if !position.IsHoldingExpired(maxHours, now) {
+			continue
+		}
+
+		reason := fmt.Sprintf("持仓时间 %s 达到最长持仓 %d 小时，系统自动平仓", position.CalculateHoldingStr(), maxHours)
+		t.logger.Warn("[RISK] max holding time reached, closing position",
+			zap.String("symbol", position.Symbol),
+			zap.String("side", position.Side),
+			zap.Time("opened_at", position.OpenedAt),
+			zap.Int("max_holding_hours", maxHours))
+
+		trade, err := t.agentService.ReducePosition(ctx, position, 1, models.CloseReasonTimeExit, reason)
+		if err != nil {
+			t.logger.Error("[RISK] failed to close expired position",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+			t.notifyService.Notify(fmt.Sprintf("⏰ %s %s %s，但平仓失败：%v", position.Symbol, position.Side, reason, err))
+			continue
+		}
+		closed = true
+		t.notifyService.Notify(fmt.Sprintf("⏰ %s %s %s，盈亏 $%.2f", position.Symbol, position.Side, reason, trade.Pnl))
+	}
+
+	if closed {
+		if err := t.positionService.SyncPositions(ctx); err != nil {
+			t.logger.Warn("[RISK] failed to sync positions after max holding close", zap.Error(err))
+		}
+	}
+	return closed
+}
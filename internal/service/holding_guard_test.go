@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// newHoldingGuardLoop 开多1个BTC，返回交易循环和持仓开仓时间设为 openedAgo 之前的本地持仓
+func newHoldingGuardLoop(t *testing.T, openedAgo time.Duration) (*TradingLoop, []models.Position) {
+	t.Helper()
+	agent, wallet := newTestAgent(t, 100)
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	positions[0].OpenedAt = time.Now().Add(-openedAgo)
+
+	logger := zap.NewNop()
+	loop := &TradingLoop{
+		logger:          logger,
+		agentService:    agent,
+		positionService: agent.positionService,
+		notifyService:   NewNotifyService(nil, agent.conf, logger),
+	}
+	return loop, positions
+}
+
+func TestEnforceMaxHoldingTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxHours   int
+		openedAgo  time.Duration
+		wantClosed bool
+	}{
+		{"expired position is closed", 36, 37 * time.Hour, true},
+		{"position within the limit is kept", 36, 35 * time.Hour, false},
+		{"zero means unlimited", 0, 100 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop, positions := newHoldingGuardLoop(t, tt.openedAgo)
+			ctx := context.Background()
+			closed := loop.enforceMaxHoldingTime(ctx, &models.TradingConfig{MaxHoldingHours: tt.maxHours}, positions)
+			if closed != tt.wantClosed {
+				t.Fatalf("closed = %v, want %v", closed, tt.wantClosed)
+			}
+
+			remaining, err := loop.agentService.exchange.GetPositions(ctx)
+			if err != nil {
+				t.Fatalf("exchange positions: %v", err)
+			}
+			if tt.wantClosed != (len(remaining) == 0) {
+				t.Fatalf("exchange positions = %d, want closed %v", len(remaining), tt.wantClosed)
+			}
+			if tt.wantClosed {
+				closes := closeTrades(t, loop.agentService)
+				if len(closes) != 1 || closes[0].ReasonCode != models.CloseReasonTimeExit {
+					t.Fatalf("close trades = %+v, want one time exit", closes)
+				}
+			}
+		})
+	}
+}
+
+// 0 表示不限制，新建配置时不能被列默认值替换
+func TestMaxHoldingHoursZeroPersists(t *testing.T) {
+	agent, _ := newTestAgent(t, 100)
+	ctx := context.Background()
+	tradingConfig := DefaultTradingConfig
+	tradingConfig.MaxHoldingHours = 0
+	if err := agent.adminConfigService.tradingConfigRepo.Create(ctx, &tradingConfig); err != nil {
+		t.Fatalf("create trading config: %v", err)
+	}
+	saved, err := agent.adminConfigService.GetTradingConfig(ctx)
+	if err != nil || saved.MaxHoldingHours != 0 {
+		t.Fatalf("max holding hours = %v, err = %v, want 0", saved, err)
+	}
+	if DefaultTradingConfig.MaxHoldingHours != 36 {
+		t.Fatalf("default max holding hours = %d, want 36", DefaultTradingConfig.MaxHoldingHours)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import "math"
+
+// 逆大级别趋势开仓的处理方式，由 trading.htf_trend_guard 配置
+const (
+	HTFTrendGuardOff     = "off"     // 不检查，默认
+	HTFTrendGuardBlock   = "block"   // 拒绝逆强趋势开仓
+	HTFTrendGuardJustify = "justify" // 逆强趋势开仓必须在 counter_trend_reason 中说明理由
+)
+
+// 大级别趋势方向
+const (
+	HTFTrendUp   = "up"
+	HTFTrendDown = "down"
+	HTFTrendNone = "none"
+)
+
+// defaultHTFTrendADX 未配置时判断强趋势的1小时ADX阈值
+const defaultHTFTrendADX = 25.0
+
+// HigherTimeframeTrend 开仓时交易对1小时级别的趋势状态
+type HigherTimeframeTrend struct {
+	Timeframe string  `json:"timeframe"`
+	Direction string  `json:"direction"` // up、down 或 none（均线未排列）
+	Strong    bool    `json:"strong"`    // ADX 不低于阈值且均线排列清晰
+	ADX       float64 `json:"adx"`
+	Threshold float64 `json:"adx_threshold"`
+	Price     float64 `json:"price"`
+	EMAFast   float64 `json:"ema_fast"`
+	EMASlow   float64 `json:"ema_slow"`
+}
+
+// normalizeHTFTrendGuard 规范化逆趋势开仓的处理方式，未知值按不检查处理
+func normalizeHTFTrendGuard(mode string) string {
+	switch mode {
+	case HTFTrendGuardBlock, HTFTrendGuardJustify:
+		return mode
+	default:
+		return HTFTrendGuardOff
+	}
+}
+
+// determineHTFTrend 根据1小时指标判断大级别趋势
+//
+// 价格、快速EMA、慢速EMA依次向上排列为上升趋势，依次向下排列为下降趋势，快慢EMA间距需达到 regimeMinEMASpread；
+// 趋势方向明确且 ADX 不低于阈值时视为强趋势。指标缺失时返回 nil。
+func determineHTFTrend(ind *TimeframeIndicators, adxThreshold float64) *HigherTimeframeTrend {
+	if ind == nil || ind.EMASlow <= 0 || ind.EMAFast <= 0 {
+		return nil
+	}
+	if adxThreshold <= 0 {
+		adxThreshold = defaultHTFTrendADX
+	}
+	trend := &HigherTimeframeTrend{
+		Timeframe: regimeTimeframe,
+		Direction: HTFTrendNone,
+		ADX:       ind.ADX,
+		Threshold: adxThreshold,
+		Price:     ind.Price,
+		EMAFast:   ind.EMAFast,
+		EMASlow:   ind.EMASlow,
+	}
+
+	spread := (ind.EMAFast - ind.EMASlow) / ind.EMASlow * 100
+	switch {
+	case ind.Price > ind.EMAFast && spread >= regimeMinEMASpread:
+		trend.Direction = HTFTrendUp
+	case ind.Price < ind.EMAFast && spread <= -regimeMinEMASpread:
+		trend.Direction = HTFTrendDown
+	}
+	trend.Strong = trend.Direction != HTFTrendNone && !math.IsNaN(ind.ADX) && ind.ADX >= adxThreshold
+	return trend
+}
+
+// Against 开仓方向是否与强趋势相反
+func (t *HigherTimeframeTrend) Against(side string) bool {
+	if t == nil || !t.Strong {
+		return false
+	}
+	return (side == "long" && t.Direction == HTFTrendDown) || (side == "short" && t.Direction == HTFTrendUp)
+}
@@ -1,10 +1,31 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/pkg/exchange"
 	"github.com/dushixiang/prism/pkg/ta"
 )
 
+// 指标周期约束
+const (
+	minIndicatorKlines = 50  // 计算指标所需的最少K线数量
+	minIndicatorPeriod = 2   // 指标周期下限
+	maxIndicatorPeriod = 200 // 指标周期上限，受单次拉取K线数量限制
+)
+
+// MACD 固定参数，未开放配置
+const (
+	macdFastPeriod   = 12
+	macdSlowPeriod   = 26
+	macdSignalPeriod = 9
+)
+
+// ErrInvalidIndicatorParams 指标周期配置不合法
+var ErrInvalidIndicatorParams = errors.New("invalid indicator params")
+
 // IndicatorService 技术指标计算服务
 type IndicatorService struct{}
 
@@ -13,42 +34,99 @@ func NewIndicatorService() *IndicatorService {
 	return &IndicatorService{}
 }
 
-// TimeframeIndicators 单个时间框架的指标
+// TimeframeIndicators 单个时间框架的指标，各指标周期见 Params
 type TimeframeIndicators struct {
-	Timeframe    string  `json:"timeframe"` // 5m/15m/30m/1h
-	Price        float64 `json:"price"`
-	EMA20        float64 `json:"ema20"`
-	EMA50        float64 `json:"ema50"`
-	MACD         float64 `json:"macd"`
-	MACDSignal   float64 `json:"macd_signal"`
-	MACDHist     float64 `json:"macd_hist"`
-	RSI7         float64 `json:"rsi7"`
-	RSI14        float64 `json:"rsi14"`
-	ATR3         float64 `json:"atr3"`
-	ATR14        float64 `json:"atr14"`
-	ADX14        float64 `json:"adx14"` // ADX 平均趋向指标（14周期）
-	Volume       float64 `json:"volume"`
-	AvgVolume    float64 `json:"avg_volume"`
-	BBandsUpper  float64 `json:"bbands_upper"`  // 布林带上轨
-	BBandsMiddle float64 `json:"bbands_middle"` // 布林带中轨
-	BBandsLower  float64 `json:"bbands_lower"`  // 布林带下轨
+	Timeframe    string                 `json:"timeframe"` // 5m/15m/30m/1h
+	Params       models.IndicatorParams `json:"params"`    // 计算所用的指标周期
+	Price        float64                `json:"price"`
+	EMAFast      float64                `json:"ema_fast"`
+	EMASlow      float64                `json:"ema_slow"`
+	MACD         float64                `json:"macd"`
+	MACDSignal   float64                `json:"macd_signal"`
+	MACDHist     float64                `json:"macd_hist"`
+	RSIFast      float64                `json:"rsi_fast"`
+	RSISlow      float64                `json:"rsi_slow"`
+	ATRFast      float64                `json:"atr_fast"`
+	ATRSlow      float64                `json:"atr_slow"`
+	ADX          float64                `json:"adx"` // ADX 平均趋向指标
+	Volume       float64                `json:"volume"`
+	AvgVolume    float64                `json:"avg_volume"`
+	BBandsUpper  float64                `json:"bbands_upper"`  // 布林带上轨
+	BBandsMiddle float64                `json:"bbands_middle"` // 布林带中轨
+	BBandsLower  float64                `json:"bbands_lower"`  // 布林带下轨
 }
 
 // TimeSeriesData 时序数据（最近50个数据点，约12.5小时的15分钟K线）
 type TimeSeriesData struct {
-	OpenPrices  []float64 `json:"open_prices"`
-	ClosePrices []float64 `json:"close_prices"`
-	HighPrices  []float64 `json:"high_prices"`
-	LowPrices   []float64 `json:"low_prices"`
-	EMA20Series []float64 `json:"ema20_series"`
-	MACDSeries  []float64 `json:"macd_series"`
-	RSI7Series  []float64 `json:"rsi7_series"`
-	RSI14Series []float64 `json:"rsi14_series"`
+	OpenPrices    []float64 `json:"open_prices"`
+	ClosePrices   []float64 `json:"close_prices"`
+	HighPrices    []float64 `json:"high_prices"`
+	LowPrices     []float64 `json:"low_prices"`
+	EMAFastSeries []float64 `json:"ema_fast_series"`
+	MACDSeries    []float64 `json:"macd_series"`
+	RSIFastSeries []float64 `json:"rsi_fast_series"`
+	RSISlowSeries []float64 `json:"rsi_slow_series"`
+}
+
+// RequiredKlines 按指标周期计算所需的最少K线数量
+//
+// ADX 需要两倍周期完成平滑，RSI/ATR 需要额外一根K线计算差值。
+func RequiredKlines(params models.IndicatorParams) int {
+	return max(minIndicatorKlines,
+		params.EMAFast, params.EMASlow, params.BBands,
+		macdSlowPeriod+macdSignalPeriod,
+		params.RSIFast+1, params.RSISlow+1,
+		params.ATRFast+1, params.ATRSlow+1,
+		params.ADX*2)
+}
+
+// ValidateIndicatorParams 校验已设置的指标周期，0表示沿用默认值
+func ValidateIndicatorParams(params models.IndicatorParams) error {
+	periods := []struct {
+		name  string
+		value int
+	}{
+		{"ema_fast", params.EMAFast},
+		{"ema_slow", params.EMASlow},
+		{"rsi_fast", params.RSIFast},
+		{"rsi_slow", params.RSISlow},
+		{"atr_fast", params.ATRFast},
+		{"atr_slow", params.ATRSlow},
+		{"adx", params.ADX},
+		{"bbands", params.BBands},
+	}
+	for _, p := range periods {
+		if p.value == 0 {
+			continue
+		}
+		if p.value < minIndicatorPeriod || p.value > maxIndicatorPeriod {
+			return fmt.Errorf("%w: %s 周期必须在 %d-%d 之间，当前为 %d",
+				ErrInvalidIndicatorParams, p.name, minIndicatorPeriod, maxIndicatorPeriod, p.value)
+		}
+	}
+
+	// 快慢周期同时生效后再比较，避免只设置一侧时与默认值冲突被漏掉
+	merged := models.DefaultIndicatorParams.Merge(params)
+	pairs := []struct {
+		name       string
+		fast, slow int
+	}{
+		{"ema", merged.EMAFast, merged.EMASlow},
+		{"rsi", merged.RSIFast, merged.RSISlow},
+		{"atr", merged.ATRFast, merged.ATRSlow},
+	}
+	for _, p := range pairs {
+		if p.fast >= p.slow {
+			return fmt.Errorf("%w: %s 快速周期(%d)必须小于慢速周期(%d)",
+				ErrInvalidIndicatorParams, p.name, p.fast, p.slow)
+		}
+	}
+	return nil
 }
 
-// CalculateIndicators 计算所有技术指标
-func (s *IndicatorService) CalculateIndicators(klines []*exchange.Kline) *TimeframeIndicators {
-	if len(klines) < 50 {
+// CalculateIndicators 按指标周期计算所有技术指标，K线数量不足时返回nil
+func (s *IndicatorService) CalculateIndicators(klines []*exchange.Kline, params models.IndicatorParams) *TimeframeIndicators {
+	if len(klines) < RequiredKlines(params) {
 		return nil
 	}
 
@@ -66,25 +144,25 @@ func (s *IndicatorService) CalculateIndicators(klines []*exchange.Kline) *Timefr
 	}
 
 	// 计算EMA
-	ema20 := ta.EMA(closes, 20)
-	ema50 := ta.EMA(closes, 50)
+	emaFast := ta.EMA(closes, params.EMAFast)
+	emaSlow := ta.EMA(closes, params.EMASlow)
 
 	// 计算MACD
-	macd, signal, hist := ta.MACD(closes, 12, 26, 9)
+	macd, signal, hist := ta.MACD(closes, macdFastPeriod, macdSlowPeriod, macdSignalPeriod)
 
 	// 计算RSI
-	rsi7 := ta.RSI(closes, 7)
-	rsi14 := ta.RSI(closes, 14)
+	rsiFast := ta.RSI(closes, params.RSIFast)
+	rsiSlow := ta.RSI(closes, params.RSISlow)
 
 	// 计算ATR
-	atr3 := ta.ATR(highs, lows, closes, 3)
-	atr14 := ta.ATR(highs, lows, closes, 14)
+	atrFast := ta.ATR(highs, lows, closes, params.ATRFast)
+	atrSlow := ta.ATR(highs, lows, closes, params.ATRSlow)
 
 	// 计算ADX
-	adx14 := ta.ADX(highs, lows, closes, 14)
+	adx := ta.ADX(highs, lows, closes, params.ADX)
 
 	// 计算布林带
-	upper, middle, lower := ta.BBands(closes, 20, 2.0, 2.0, 0)
+	upper, middle, lower := ta.BBands(closes, params.BBands, 2.0, 2.0, 0)
 
 	// 计算平均成交量
 	avgVolume := 0.0
@@ -97,17 +175,18 @@ func (s *IndicatorService) CalculateIndicators(klines []*exchange.Kline) *Timefr
 	lastIdx := len(closes) - 1
 
 	return &TimeframeIndicators{
+		Params:       params,
 		Price:        closes[lastIdx],
-		EMA20:        ta.Last(ema20, 0),
-		EMA50:        ta.Last(ema50, 0),
+		EMAFast:      ta.Last(emaFast, 0),
+		EMASlow:      ta.Last(emaSlow, 0),
 		MACD:         ta.Last(macd, 0),
 		MACDSignal:   ta.Last(signal, 0),
 		MACDHist:     ta.Last(hist, 0),
-		RSI7:         ta.Last(rsi7, 0),
-		RSI14:        ta.Last(rsi14, 0),
-		ATR3:         ta.Last(atr3, 0),
-		ATR14:        ta.Last(atr14, 0),
-		ADX14:        ta.Last(adx14, 0),
+		RSIFast:      ta.Last(rsiFast, 0),
+		RSISlow:      ta.Last(rsiSlow, 0),
+		ATRFast:      ta.Last(atrFast, 0),
+		ATRSlow:      ta.Last(atrSlow, 0),
+		ADX:          ta.Last(adx, 0),
 		Volume:       volumes[lastIdx],
 		AvgVolume:    avgVolume,
 		BBandsUpper:  ta.Last(upper, 0),
@@ -117,8 +196,8 @@ func (s *IndicatorService) CalculateIndicators(klines []*exchange.Kline) *Timefr
 }
 
 // CalculateTimeSeries 计算时序数据（日内5分钟级别，最近10个数据点）
-func (s *IndicatorService) CalculateTimeSeries(klines []*exchange.Kline) *TimeSeriesData {
-	if len(klines) < 50 {
+func (s *IndicatorService) CalculateTimeSeries(klines []*exchange.Kline, params models.IndicatorParams) *TimeSeriesData {
+	if len(klines) < RequiredKlines(params) {
 		return nil
 	}
 
@@ -136,10 +215,10 @@ func (s *IndicatorService) CalculateTimeSeries(klines []*exchange.Kline) *TimeSe
 	}
 
 	// 计算指标序列（使用全部数据）
-	ema20Series := ta.EMA(closes, 20)
-	macdSeries, _, _ := ta.MACD(closes, 12, 26, 9)
-	rsi7Series := ta.RSI(closes, 7)
-	rsi14Series := ta.RSI(closes, 14)
+	emaFastSeries := ta.EMA(closes, params.EMAFast)
+	macdSeries, _, _ := ta.MACD(closes, macdFastPeriod, macdSlowPeriod, macdSignalPeriod)
+	rsiFastSeries := ta.RSI(closes, params.RSIFast)
+	rsiSlowSeries := ta.RSI(closes, params.RSISlow)
 
 	// 返回最近48个数据点（约12小时）
 	size := 48
@@ -148,14 +227,14 @@ func (s *IndicatorService) CalculateTimeSeries(klines []*exchange.Kline) *TimeSe
 	}
 
 	return &TimeSeriesData{
-		OpenPrices:  ta.LastValues(opens, size),
-		ClosePrices: ta.LastValues(closes, size),
-		HighPrices:  ta.LastValues(highs, size),
-		LowPrices:   ta.LastValues(lows, size),
-		EMA20Series: ta.LastValues(ema20Series, size),
-		MACDSeries:  ta.LastValues(macdSeries, size),
-		RSI7Series:  ta.LastValues(rsi7Series, size),
-		RSI14Series: ta.LastValues(rsi14Series, size),
+		OpenPrices:    ta.LastValues(opens, size),
+		ClosePrices:   ta.LastValues(closes, size),
+		HighPrices:    ta.LastValues(highs, size),
+		LowPrices:     ta.LastValues(lows, size),
+		EMAFastSeries: ta.LastValues(emaFastSeries, size),
+		MACDSeries:    ta.LastValues(macdSeries, size),
+		RSIFastSeries: ta.LastValues(rsiFastSeries, size),
+		RSISlowSeries: ta.LastValues(rsiSlowSeries, size),
 	}
 }
 
@@ -169,16 +248,16 @@ func (s *IndicatorService) ValidateIndicators(indicators *TimeframeIndicators) [
 	}
 
 	// 验证EMA
-	if indicators.EMA20 <= 0 {
-		issues = append(issues, "invalid EMA20")
+	if indicators.EMAFast <= 0 {
+		issues = append(issues, fmt.Sprintf("invalid EMA%d", indicators.Params.EMAFast))
 	}
-	if indicators.EMA50 <= 0 {
-		issues = append(issues, "invalid EMA50")
+	if indicators.EMASlow <= 0 {
+		issues = append(issues, fmt.Sprintf("invalid EMA%d", indicators.Params.EMASlow))
 	}
 
 	// 验证RSI
-	if indicators.RSI14 < 0 || indicators.RSI14 > 100 {
-		issues = append(issues, "RSI14 out of range")
+	if indicators.RSISlow < 0 || indicators.RSISlow > 100 {
+		issues = append(issues, fmt.Sprintf("RSI%d out of range", indicators.Params.RSISlow))
 	}
 
 	// 验证成交量
@@ -200,7 +279,7 @@ func (s *IndicatorService) DetectMultiTimeframeConfluence(indicators map[string]
 		isBearish := false
 
 		// EMA趋势
-		if ind.EMA20 > ind.EMA50 {
+		if ind.EMAFast > ind.EMASlow {
 			isBullish = true
 		} else {
 			isBearish = true
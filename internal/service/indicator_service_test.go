@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestIndicatorParamsFor(t *testing.T) {
+	cfg := &models.TradingConfig{
+		Indicators: models.IndicatorParams{EMAFast: 21, EMASlow: 55},
+		SymbolIndicators: map[string]models.IndicatorParams{
+			"BTCUSDT": {EMAFast: 9, RSISlow: 21},
+		},
+	}
+
+	eth := cfg.IndicatorParamsFor("ETHUSDT")
+	if eth.EMAFast != 21 || eth.EMASlow != 55 || eth.RSISlow != models.DefaultIndicatorParams.RSISlow {
+		t.Fatalf("ETHUSDT params = %+v", eth)
+	}
+	btc := cfg.IndicatorParamsFor("BTCUSDT")
+	if btc.EMAFast != 9 || btc.EMASlow != 55 || btc.RSISlow != 21 || btc.ADX != models.DefaultIndicatorParams.ADX {
+		t.Fatalf("BTCUSDT params = %+v", btc)
+	}
+}
+
+func TestValidateIndicatorParams(t *testing.T) {
+	valid := []models.IndicatorParams{
+		{},
+		{EMAFast: 21, EMASlow: 55},
+		{RSISlow: 21},
+	}
+	for _, params := range valid {
+		if err := ValidateIndicatorParams(params); err != nil {
+			t.Errorf("params %+v: unexpected error %v", params, err)
+		}
+	}
+
+	invalid := []models.IndicatorParams{
+		{EMAFast: 1},
+		{ADX: maxIndicatorPeriod + 1},
+		{EMAFast: 60},              // 大于默认慢速EMA50
+		{RSIFast: 14, RSISlow: 14}, // 快慢周期相同
+	}
+	for _, params := range invalid {
+		if err := ValidateIndicatorParams(params); !errors.Is(err, ErrInvalidIndicatorParams) {
+			t.Errorf("params %+v: err = %v, want ErrInvalidIndicatorParams", params, err)
+		}
+	}
+}
+
+func TestRequiredKlines(t *testing.T) {
+	if got := RequiredKlines(models.DefaultIndicatorParams); got != minIndicatorKlines {
+		t.Fatalf("default required klines = %d, want %d", got, minIndicatorKlines)
+	}
+	params := models.DefaultIndicatorParams.Merge(models.IndicatorParams{EMASlow: 100, ADX: 60})
+	if got := RequiredKlines(params); got != 120 {
+		t.Fatalf("required klines = %d, want 120", got)
+	}
+}
+
+func TestDetermineMarketRegime(t *testing.T) {
+	s := NewIndicatorService()
+	cases := []struct {
+		name string
+		ind  *TimeframeIndicators
+		want MarketRegime
+	}{
+		{"missing", nil, MarketRegimeUncertain},
+		{"bullish trend", &TimeframeIndicators{Price: 105, EMAFast: 103, EMASlow: 100, ADX: 30}, MarketRegimeTrending},
+		{"bearish trend", &TimeframeIndicators{Price: 95, EMAFast: 97, EMASlow: 100, ADX: 30}, MarketRegimeTrending},
+		{"strong adx but price against fast ema", &TimeframeIndicators{Price: 101, EMAFast: 103, EMASlow: 100, ADX: 30}, MarketRegimeUncertain},
+		{"emas tangled", &TimeframeIndicators{Price: 101, EMAFast: 100.05, EMASlow: 100, ADX: 30}, MarketRegimeUncertain},
+		{"moderate adx", &TimeframeIndicators{Price: 105, EMAFast: 103, EMASlow: 100, ADX: 22}, MarketRegimeUncertain},
+		{"ranging", &TimeframeIndicators{Price: 105, EMAFast: 103, EMASlow: 100, ADX: 15}, MarketRegimeRanging},
+	}
+	for _, c := range cases {
+		if got := s.DetermineMarketRegime(c.ind); got != c.want {
+			t.Errorf("%s: regime = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"go.uber.org/zap"
+)
+
+// llmCompletionReserveTokens 判断上下文窗口时为模型输出预留的 token 数
+const llmCompletionReserveTokens = 4096
+
+// estimateTokens 粗略估算文本的 token 数
+//
+// 不依赖具体模型的分词器：ASCII 字符按约4个一个 token，其余字符（中文等）按每个字符一个 token，
+// 结果偏保守，只用于判断请求是否接近模型的上下文窗口。
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// estimateRequestTokens 估算一次对话请求的输入 token 数，包括全部消息和工具定义
+func estimateRequestTokens(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolParam) int {
+	total := 0
+	if data, err := json.Marshal(messages); err == nil {
+		total += estimateTokens(string(data))
+	}
+	if data, err := json.Marshal(tools); err == nil {
+		total += estimateTokens(string(data))
+	}
+	return total
+}
+
+// contextLimit 模型配置的上下文窗口（token），未配置时返回0
+func (s *AgentService) contextLimit(model string) int {
+	return s.conf.LLM.MaxContextTokens[model]
+}
+
+// fitsContext 估算的输入加上输出预留是否在模型的上下文窗口内，未配置窗口的模型视为放得下
+func (s *AgentService) fitsContext(model string, estimated int) bool {
+	limit := s.contextLimit(model)
+	return limit <= 0 || estimated+llmCompletionReserveTokens <= limit
+}
+
+// selectModel 按估算的输入 token 数选择本次调用使用的模型
+//
+// 主模型放不下且配置了 fallback_model 时改用备用模型；两者都放不下时使用上下文窗口更大的一个并告警，
+// 由模型服务决定是否接受请求。
+func (s *AgentService) selectModel(estimated int) string {
+	primary := s.model
+	if s.fitsContext(primary, estimated) {
+		return primary
+	}
+
+	fallback := strings.TrimSpace(s.conf.LLM.FallbackModel)
+	if fallback == "" || fallback == primary {
+		s.logger.Warn("prompt may exceed model context window and no fallback model is configured",
+			zap.String("model", primary),
+			zap.Int("estimated_tokens", estimated),
+			zap.Int("context_tokens", s.contextLimit(primary)))
+		return primary
+	}
+
+	if s.fitsContext(fallback, estimated) {
+		s.logger.Warn("prompt exceeds model context window, using fallback model",
+			zap.String("model", primary),
+			zap.String("fallback_model", fallback),
+			zap.Int("estimated_tokens", estimated),
+			zap.Int("context_tokens", s.contextLimit(primary)))
+		return fallback
+	}
+
+	selected := primary
+	if s.contextLimit(fallback) > s.contextLimit(primary) {
+		selected = fallback
+	}
+	s.logger.Warn("prompt may exceed context window of both primary and fallback models",
+		zap.String("model", primary),
+		zap.String("fallback_model", fallback),
+		zap.String("selected_model", selected),
+		zap.Int("estimated_tokens", estimated))
+	return selected
+}
+
+// usedModels 合并一次决策各轮使用的模型，按首次使用的顺序去重
+func usedModels(names []string) string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, model := range names {
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		unique = append(unique, model)
+	}
+	return strings.Join(unique, ",")
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/dushixiang/prism/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens("abcdefgh"); got != 2 {
+		t.Fatalf("ascii tokens = %d, want 2", got)
+	}
+	if got := estimateTokens("市场全景 BTC"); got != 5 {
+		t.Fatalf("mixed tokens = %d, want 5", got)
+	}
+}
+
+func TestSelectModel(t *testing.T) {
+	conf := config.Default()
+	conf.LLM.MaxContextTokens = map[string]int{"small": 32000, "large": 128000}
+	s := &AgentService{logger: zap.NewNop(), model: "small", conf: &conf}
+
+	if got := s.selectModel(10000); got != "small" {
+		t.Fatalf("fits primary: got %s", got)
+	}
+	// 未配置备用模型时仍使用主模型
+	if got := s.selectModel(30000); got != "small" {
+		t.Fatalf("no fallback: got %s", got)
+	}
+
+	conf.LLM.FallbackModel = "large"
+	if got := s.selectModel(30000); got != "large" {
+		t.Fatalf("over primary limit: got %s, want large", got)
+	}
+	if got := s.selectModel(200000); got != "large" {
+		t.Fatalf("over both limits should pick the larger window: got %s", got)
+	}
+
+	// 未配置上下文窗口的模型不检查
+	s.model = "unknown"
+	if got := s.selectModel(1000000); got != "unknown" {
+		t.Fatalf("unconfigured model: got %s", got)
+	}
+
+	if got := usedModels([]string{"small", "large", "small", ""}); got != "small,large" {
+		t.Fatalf("used models = %q", got)
+	}
+}
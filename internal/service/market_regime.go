@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// MarketRegime 市场状态
+type MarketRegime string
+
+const (
+	MarketRegimeTrending  MarketRegime = "trending"  // 趋势行情
+	MarketRegimeRanging   MarketRegime = "ranging"   // 震荡行情
+	MarketRegimeUncertain MarketRegime = "uncertain" // 无法判断（趋势强度中等、均线未排列或数据不足）
+)
+
+// 市场状态判断阈值
+const (
+	regimeTimeframe    = "1h" // 判断市场状态所用的时间框架
+	regimeKlineLimit   = 120  // 判断市场状态时拉取的K线数量
+	regimeTrendingADX  = 25.0 // ADX 不低于该值且均线多空排列视为趋势
+	regimeRangingADX   = 20.0 // ADX 低于该值视为震荡
+	regimeMinEMASpread = 0.1  // 快慢EMA的最小间距（占慢速EMA的%），低于该值视为均线缠绕
+)
+
+// DetermineMarketRegime 根据 ADX 和均线排列判断市场状态
+//
+// ADX 达到趋势阈值且价格、快速EMA、慢速EMA依次排列（多头或空头）时为趋势；
+// ADX 低于震荡阈值时为震荡；其余情况（含指标缺失）为不确定。
+func (s *IndicatorService) DetermineMarketRegime(ind *TimeframeIndicators) MarketRegime {
+	if ind == nil || ind.ADX <= 0 || ind.EMASlow <= 0 {
+		return MarketRegimeUncertain
+	}
+	if ind.ADX < regimeRangingADX {
+		return MarketRegimeRanging
+	}
+	if ind.ADX < regimeTrendingADX {
+		return MarketRegimeUncertain
+	}
+
+	spread := (ind.EMAFast - ind.EMASlow) / ind.EMASlow * 100
+	bullish := ind.Price > ind.EMAFast && spread >= regimeMinEMASpread
+	bearish := ind.Price < ind.EMAFast && spread <= -regimeMinEMASpread
+	if bullish || bearish {
+		return MarketRegimeTrending
+	}
+	return MarketRegimeUncertain
+}
+
+// currentRegimeIndicators 拉取交易对的1小时K线实时计算指标，行情获取失败或数据不足时返回 nil
+func (s *AgentService) currentRegimeIndicators(ctx context.Context, symbol string, params models.IndicatorParams) *TimeframeIndicators {
+	klines, err := s.exchange.GetKlines(ctx, symbol, regimeTimeframe, max(regimeKlineLimit, RequiredKlines(params)))
+	if err != nil {
+		s.logger.Warn("failed to get klines for market regime",
+			zap.String("symbol", symbol),
+			zap.Error(err))
+		return nil
+	}
+	return s.indicatorService.CalculateIndicators(klines, params)
+}
+
+// marketRegimeOf 由1小时指标判断市场状态，指标缺失时为不确定
+func (s *AgentService) marketRegimeOf(ind *TimeframeIndicators) (MarketRegime, float64) {
+	if ind == nil {
+		return MarketRegimeUncertain, 0
+	}
+	return s.indicatorService.DetermineMarketRegime(ind), ind.ADX
+}
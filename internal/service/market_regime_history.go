@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// RegimeSegment 同一交易对连续处于同一市场状态的时间段
+type RegimeSegment struct {
+	Symbol  string       `json:"symbol"`
+	Regime  MarketRegime `json:"regime"`
+	StartAt time.Time    `json:"start_at"` // 第一次记录为该状态的时间
+	EndAt   time.Time    `json:"end_at"`   // 最后一次记录为该状态的时间
+	Cycles  int          `json:"cycles"`   // 该状态持续的记录次数
+	AvgADX  float64      `json:"avg_adx"`
+}
+
+// RecordRegimes 记录本周期各交易对的市场状态，未开启记录时跳过
+//
+// 写入失败只记录日志，不影响交易流程。
+func (s *MarketService) RecordRegimes(ctx context.Context, iteration int, marketData map[string]*MarketData) {
+	if !s.conf.Trading.RecordMarketRegimes || len(marketData) == 0 {
+		return
+	}
+	now := time.Now()
+	records := make([]models.MarketRegimeRecord, 0, len(marketData))
+	for symbol, data := range marketData {
+		records = append(records, models.MarketRegimeRecord{
+			ID:         ulid.Make().String(),
+			Iteration:  iteration,
+			Symbol:     symbol,
+			Regime:     string(data.Regime),
+			ADX:        data.RegimeADX,
+			Price:      data.CurrentPrice,
+			RecordedAt: now,
+		})
+	}
+	if err := s.regimeRepo.CreateInBatches(ctx, records, len(records)); err != nil {
+		s.logger.Warn("failed to record market regimes", zap.Error(err))
+	}
+}
+
+// RegimeTimeline 市场状态时间线
+type RegimeTimeline struct {
+	Records  []models.MarketRegimeRecord `json:"records"`
+	Segments []RegimeSegment             `json:"segments"`
+}
+
+// GetRegimeTimeline 查询时间区间内的市场状态记录并合并为连续区间，symbol 为空时查询全部交易对
+func (s *MarketService) GetRegimeTimeline(ctx context.Context, symbol string, from, to time.Time, limit int) (*RegimeTimeline, error) {
+	records, err := s.regimeRepo.FindTimeline(ctx, symbol, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &RegimeTimeline{Records: records, Segments: regimeSegments(records)}, nil
+}
+
+// regimeSegments 将按时间升序的市场状态记录按交易对合并为连续的状态区间，结果按交易对、开始时间排序
+func regimeSegments(records []models.MarketRegimeRecord) []RegimeSegment {
+	segments := []RegimeSegment{}
+	last := make(map[string]int) // 交易对 -> 最近一个区间在 segments 中的下标
+	for _, record := range records {
+		regime := MarketRegime(record.Regime)
+		if i, ok := last[record.Symbol]; ok && segments[i].Regime == regime {
+			segment := &segments[i]
+			segment.AvgADX = (segment.AvgADX*float64(segment.Cycles) + record.ADX) / float64(segment.Cycles+1)
+			segment.Cycles++
+			segment.EndAt = record.RecordedAt
+			continue
+		}
+		last[record.Symbol] = len(segments)
+		segments = append(segments, RegimeSegment{
+			Symbol:  record.Symbol,
+			Regime:  regime,
+			StartAt: record.RecordedAt,
+			EndAt:   record.RecordedAt,
+			Cycles:  1,
+			AvgADX:  record.ADX,
+		})
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Symbol < segments[j].Symbol
+	})
+	return segments
+}
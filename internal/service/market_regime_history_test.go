@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestRecordRegimes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.MarketRegimeRecord{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	conf := config.Default()
+	s := NewMarketService(db, nil, NewIndicatorService(), &conf, zap.NewNop())
+	ctx := context.Background()
+
+	s.RecordRegimes(ctx, 1, map[string]*MarketData{
+		"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 95000, Regime: MarketRegimeTrending, RegimeADX: 31},
+		"ETHUSDT": {Symbol: "ETHUSDT", CurrentPrice: 3500, Regime: MarketRegimeRanging, RegimeADX: 15},
+	})
+
+	now := time.Now()
+	timeline, err := s.GetRegimeTimeline(ctx, "BTCUSDT", now.Add(-time.Minute), now.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("timeline: %v", err)
+	}
+	if len(timeline.Records) != 1 || timeline.Records[0].Regime != string(MarketRegimeTrending) || timeline.Records[0].Iteration != 1 {
+		t.Fatalf("unexpected records: %+v", timeline.Records)
+	}
+
+	// 关闭记录后不再写入
+	conf.Trading.RecordMarketRegimes = false
+	s.RecordRegimes(ctx, 2, map[string]*MarketData{"BTCUSDT": {Regime: MarketRegimeRanging}})
+	if all, _ := s.GetRegimeTimeline(ctx, "", now.Add(-time.Minute), now.Add(time.Minute), 10); len(all.Records) != 2 {
+		t.Fatalf("records = %d, want 2", len(all.Records))
+	}
+}
+
+func TestRegimeSegments(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * 15 * time.Minute) }
+	records := []models.MarketRegimeRecord{
+		{Symbol: "ETHUSDT", Regime: "ranging", ADX: 14, RecordedAt: at(0)},
+		{Symbol: "BTCUSDT", Regime: "trending", ADX: 30, RecordedAt: at(0)},
+		{Symbol: "BTCUSDT", Regime: "trending", ADX: 34, RecordedAt: at(1)},
+		{Symbol: "ETHUSDT", Regime: "ranging", ADX: 16, RecordedAt: at(1)},
+		{Symbol: "BTCUSDT", Regime: "uncertain", ADX: 22, RecordedAt: at(2)},
+	}
+
+	segments := regimeSegments(records)
+	if len(segments) != 3 {
+		t.Fatalf("segments = %+v", segments)
+	}
+	btc := segments[0]
+	if btc.Symbol != "BTCUSDT" || btc.Regime != MarketRegimeTrending || btc.Cycles != 2 || btc.AvgADX != 32 || !btc.EndAt.Equal(at(1)) {
+		t.Fatalf("unexpected first BTC segment: %+v", btc)
+	}
+	if segments[1].Regime != MarketRegimeUncertain || segments[2].Symbol != "ETHUSDT" || segments[2].Cycles != 2 {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+}
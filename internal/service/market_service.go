@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/internal/repo"
 	"github.com/dushixiang/prism/pkg/exchange"
 	"github.com/dushixiang/prism/pkg/ta"
 	"github.com/go-orz/orz"
@@ -19,16 +24,20 @@ type MarketService struct {
 
 	exchange         exchange.Exchange
 	indicatorService *IndicatorService
+	regimeRepo       *repo.MarketRegimeRepo
+	conf             *config.Config
 }
 
 // NewMarketService 创建市场数据服务
 func NewMarketService(db *gorm.DB, exchange exchange.Exchange,
-	indicatorService *IndicatorService, logger *zap.Logger) *MarketService {
+	indicatorService *IndicatorService, conf *config.Config, logger *zap.Logger) *MarketService {
 	return &MarketService{
 		logger:           logger,
 		Service:          orz.NewService(db),
 		exchange:         exchange,
 		indicatorService: indicatorService,
+		regimeRepo:       repo.NewMarketRegimeRepo(db),
+		conf:             conf,
 	}
 }
 
@@ -42,19 +51,31 @@ type MarketData struct {
 	LongerTermData *LongerTermContext              `json:"longer_term_data"` // 1小时更长期上下文
 	RecentHigh     float64                         `json:"recent_high"`      // 近期高点
 	RecentLow      float64                         `json:"recent_low"`       // 近期低点
+	Supports       []PriceLevel                    `json:"supports"`         // 1小时枢轴聚类得到的关键支撑，由近到远
+	Resistances    []PriceLevel                    `json:"resistances"`      // 1小时枢轴聚类得到的关键阻力，由近到远
+	Regime         MarketRegime                    `json:"regime"`           // 按1小时指标判断的市场状态
+	RegimeADX      float64                         `json:"regime_adx"`       // 判断市场状态所用的1小时ADX
+	Breakout       *SignalConfirmation             `json:"breakout"`         // 15分钟突破的持续情况，未开启突破确认时为 nil
+	Volatility     *VolatilitySpike                `json:"volatility"`       // 1小时短周期ATR相对长周期ATR的放大情况，未开启检查时为 nil
 }
 
 // LongerTermContext 更长期上下文（1小时级别）
 type LongerTermContext struct {
-	EMA20vsEMA50 string    `json:"ema20_vs_ema50"` // "above" or "below"
-	ATR3vsATR14  string    `json:"atr3_vs_atr14"`  // "higher" or "lower"
-	VolumeVsAvg  string    `json:"volume_vs_avg"`  // "above" or "below"
-	MACDSeries   []float64 `json:"macd_series"`    // 最近10个MACD值
-	RSI14Series  []float64 `json:"rsi14_series"`   // 最近10个RSI14值
+	Params        models.IndicatorParams `json:"params"`           // 计算所用的指标周期
+	EMAFastVsSlow string                 `json:"ema_fast_vs_slow"` // "above" or "below"
+	ATRFastVsSlow string                 `json:"atr_fast_vs_slow"` // "higher" or "lower"
+	VolumeVsAvg   string                 `json:"volume_vs_avg"`    // "above" or "below"
+	MACDSeries    []float64              `json:"macd_series"`      // 最近10个MACD值
+	RSISlowSeries []float64              `json:"rsi_slow_series"`  // 最近10个长周期RSI值
 }
 
-// CollectMarketData 收集指定币种的市场数据（所有时间框架）
-func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*MarketData, error) {
+// CollectMarketData 按指标周期收集指定币种的市场数据（所有时间框架），1小时K线历史不足时返回 ErrInsufficientHistory
+func (s *MarketService) CollectMarketData(ctx context.Context, symbol string, params models.IndicatorParams) (*MarketData, error) {
+	return s.collectMarketData(ctx, symbol, params, true)
+}
+
+// collectMarketData 收集市场数据，requireHistory 为 false 时K线历史不足也返回能计算出的部分数据
+func (s *MarketService) collectMarketData(ctx context.Context, symbol string, params models.IndicatorParams, requireHistory bool) (*MarketData, error) {
 	s.logger.Info("collecting market data", zap.String("symbol", symbol))
 
 	// 定义需要获取的时间框架 (移除5m减少噪音)
@@ -77,9 +98,18 @@ func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*
 	var shortestFrame string
 	var klines1h []*exchange.Kline
 	var klines15m []*exchange.Kline
+	fetched1h := false
+	requiredKlines := RequiredKlines(params)
+	minHistory := s.minHistoryKlines(params)
+	levelLookback := s.conf.Trading.KeyLevelLookback
 
 	for _, tf := range timeframes {
-		klines, err := s.exchange.GetKlines(ctx, symbol, tf.interval, tf.limit)
+		// 指标周期较长时多拉取K线，保证指标有足够的数据
+		limit := max(tf.limit, requiredKlines)
+		if tf.name == "1h" {
+			limit = max(limit, levelLookback, minHistory)
+		}
+		klines, err := s.exchange.GetKlines(ctx, symbol, tf.interval, limit)
 		if err != nil {
 			s.logger.Error("failed to get klines",
 				zap.String("symbol", symbol),
@@ -95,13 +125,20 @@ func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*
 		// 保存特定时间框架的数据用于后续处理
 		if tf.name == "1h" {
 			klines1h = klines
+			fetched1h = true
 		} else if tf.name == "15m" {
 			klines15m = klines
 		}
 
 		// 计算技术指标
-		indicators := s.indicatorService.CalculateIndicators(klines)
-		if indicators != nil {
+		indicators := s.indicatorService.CalculateIndicators(klines, params)
+		if indicators == nil {
+			s.logger.Warn("not enough klines for indicators",
+				zap.String("symbol", symbol),
+				zap.String("timeframe", tf.name),
+				zap.Int("klines", len(klines)),
+				zap.Int("required", requiredKlines))
+		} else {
 			indicators.Timeframe = tf.name
 			marketData.Timeframes[tf.name] = indicators
 
@@ -116,6 +153,17 @@ func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*
 		}
 	}
 
+	// 新上线的交易对K线历史不足，指标不可靠，整体剔除而不是带着残缺的时间框架参与决策
+	if fetched1h && len(klines1h) < minHistory {
+		if requireHistory {
+			return nil, fmt.Errorf("%w: %s 1h klines %d < %d", ErrInsufficientHistory, symbol, len(klines1h), minHistory)
+		}
+		s.logger.Warn("[RISK] held symbol has too little kline history, collecting partial data for position management",
+			zap.String("symbol", symbol),
+			zap.Int("klines", len(klines1h)),
+			zap.Int("required", minHistory))
+	}
+
 	// 计算近期高低点 (基于15m K线，周期96根 ≈ 24小时)
 	if len(klines15m) > 0 {
 		recentKlines := klines15m
@@ -134,6 +182,33 @@ func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*
 		}
 	}
 
+	// 计算关键支撑/阻力（基于1h K线枢轴点聚类）
+	if levelLookback > 0 && len(klines1h) > 0 {
+		levelKlines := klines1h
+		if len(levelKlines) > levelLookback {
+			levelKlines = levelKlines[len(levelKlines)-levelLookback:]
+		}
+		atr := 0.0
+		if ind, ok := marketData.Timeframes["1h"]; ok {
+			atr = ind.ATRSlow
+		}
+		marketData.Supports, marketData.Resistances = findKeyLevels(levelKlines, marketData.CurrentPrice, atr)
+	}
+
+	// 按1小时指标判断市场状态
+	marketData.Regime = s.indicatorService.DetermineMarketRegime(marketData.Timeframes[regimeTimeframe])
+	if ind, ok := marketData.Timeframes[regimeTimeframe]; ok {
+		marketData.RegimeADX = ind.ADX
+	}
+
+	// 按1小时短周期/长周期ATR判断波动是否异常放大
+	marketData.Volatility = detectVolatilitySpike(marketData.Timeframes[regimeTimeframe], s.conf.Trading.VolatilitySpikeRatio)
+
+	// 计算15分钟突破的持续情况
+	if required := s.conf.Trading.ConfirmationCandles; required > 0 && len(klines15m) > 0 {
+		marketData.Breakout = breakoutConfirmation(klines15m, required, time.Now())
+	}
+
 	// 获取资金费率
 	fundingRate, err := s.exchange.GetFundingRate(ctx, symbol)
 	if err != nil {
@@ -144,24 +219,20 @@ func (s *MarketService) CollectMarketData(ctx context.Context, symbol string) (*
 
 	// 计算日内时序数据（使用15分钟K线以减少噪音）
 	if len(klines15m) > 0 {
-		marketData.IntradaySeries = s.indicatorService.CalculateTimeSeries(klines15m)
+		marketData.IntradaySeries = s.indicatorService.CalculateTimeSeries(klines15m, params)
 	}
 
 	// 计算更长期上下文（使用1小时K线）
 	if len(klines1h) > 0 {
-		marketData.LongerTermData = s.calculateLongerTermContext(klines1h)
+		marketData.LongerTermData = s.calculateLongerTermContext(klines1h, params)
 	}
 
 	return marketData, nil
 }
 
 // calculateLongerTermContext 计算更长期上下文
-func (s *MarketService) calculateLongerTermContext(klines []*exchange.Kline) *LongerTermContext {
-	if len(klines) < 50 {
-		return nil
-	}
-
-	indicators := s.indicatorService.CalculateIndicators(klines)
+func (s *MarketService) calculateLongerTermContext(klines []*exchange.Kline, params models.IndicatorParams) *LongerTermContext {
+	indicators := s.indicatorService.CalculateIndicators(klines, params)
 	if indicators == nil {
 		return nil
 	}
@@ -173,33 +244,34 @@ func (s *MarketService) calculateLongerTermContext(klines []*exchange.Kline) *Lo
 	}
 
 	// 计算MACD与RSI序列，返回最近10个数据点
-	macdSeries, _, _ := ta.MACD(closes, 12, 26, 9)
-	rsi14Series := ta.RSI(closes, 14)
+	macdSeries, _, _ := ta.MACD(closes, macdFastPeriod, macdSlowPeriod, macdSignalPeriod)
+	rsiSlowSeries := ta.RSI(closes, params.RSISlow)
 
 	seriesSize := 10
 	if len(macdSeries) < seriesSize {
 		seriesSize = len(macdSeries)
 	}
 	lastMACD := ta.LastValues(macdSeries, seriesSize)
-	lastRSI14 := ta.LastValues(rsi14Series, seriesSize)
+	lastRSISlow := ta.LastValues(rsiSlowSeries, seriesSize)
 
 	longerTermCtx := &LongerTermContext{
-		MACDSeries:  lastMACD,
-		RSI14Series: lastRSI14,
+		Params:        params,
+		MACDSeries:    lastMACD,
+		RSISlowSeries: lastRSISlow,
 	}
 
-	// EMA20 vs EMA50
-	if indicators.EMA20 > indicators.EMA50 {
-		longerTermCtx.EMA20vsEMA50 = "above"
+	// 快速EMA vs 慢速EMA
+	if indicators.EMAFast > indicators.EMASlow {
+		longerTermCtx.EMAFastVsSlow = "above"
 	} else {
-		longerTermCtx.EMA20vsEMA50 = "below"
+		longerTermCtx.EMAFastVsSlow = "below"
 	}
 
-	// ATR3 vs ATR14
-	if indicators.ATR3 > indicators.ATR14 {
-		longerTermCtx.ATR3vsATR14 = "higher"
+	// 短周期ATR vs 长周期ATR
+	if indicators.ATRFast > indicators.ATRSlow {
+		longerTermCtx.ATRFastVsSlow = "higher"
 	} else {
-		longerTermCtx.ATR3vsATR14 = "lower"
+		longerTermCtx.ATRFastVsSlow = "lower"
 	}
 
 	// Volume vs AvgVolume
@@ -212,12 +284,68 @@ func (s *MarketService) calculateLongerTermContext(klines []*exchange.Kline) *Lo
 	return longerTermCtx
 }
 
-// CollectAllSymbols 收集所有交易对的市场数据
-func (s *MarketService) CollectAllSymbols(ctx context.Context, symbols []string) (map[string]*MarketData, error) {
+// MultiTimeframeSnapshot 单个交易对的多时间框架指标快照，与交易AI看到的数据一致
+type MultiTimeframeSnapshot struct {
+	Symbol          string                          `json:"symbol"`
+	CurrentPrice    float64                         `json:"current_price"`
+	Timeframes      map[string]*TimeframeIndicators `json:"timeframes"`
+	Confluence      string                          `json:"confluence"`       // bullish/bearish/neutral
+	ConfluenceCount int                             `json:"confluence_count"` // 方向一致的时间框架数量
+	Regime          MarketRegime                    `json:"regime"`           // 按1小时指标判断的市场状态
+	Supports        []PriceLevel                    `json:"supports"`
+	Resistances     []PriceLevel                    `json:"resistances"`
+	CollectedAt     time.Time                       `json:"collected_at"`
+}
+
+// GetMultiTimeframeSnapshot 收集交易对的多时间框架指标并计算共振方向
+func (s *MarketService) GetMultiTimeframeSnapshot(ctx context.Context, symbol string, params models.IndicatorParams) (*MultiTimeframeSnapshot, error) {
+	data, err := s.CollectMarketData(ctx, symbol, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Timeframes) == 0 {
+		return nil, fmt.Errorf("no timeframe indicators available for %s", symbol)
+	}
+
+	confluence, count := s.indicatorService.DetectMultiTimeframeConfluence(data.Timeframes)
+	return &MultiTimeframeSnapshot{
+		Symbol:          data.Symbol,
+		CurrentPrice:    data.CurrentPrice,
+		Timeframes:      data.Timeframes,
+		Confluence:      confluence,
+		ConfluenceCount: count,
+		Regime:          data.Regime,
+		Supports:        data.Supports,
+		Resistances:     data.Resistances,
+		CollectedAt:     time.Now(),
+	}, nil
+}
+
+// CollectAllSymbols 收集所有交易对的市场数据，指标周期按交易对配置解析
+//
+// 按交易对顺序收集，ctx 到期（如超出收集时限）时停止收集剩余交易对并返回已收集的数据。
+// held 中已有持仓的交易对K线历史不足时仍收集能计算出的数据，保证持仓管理有行情可用。
+func (s *MarketService) CollectAllSymbols(ctx context.Context, tradingConfig *models.TradingConfig, held map[string]bool) (map[string]*MarketData, error) {
 	result := make(map[string]*MarketData)
 
-	for _, symbol := range symbols {
-		data, err := s.CollectMarketData(ctx, symbol)
+	var skipped []string
+	for i, symbol := range tradingConfig.Symbols {
+		if ctx.Err() != nil {
+			skipped = append(skipped, tradingConfig.Symbols[i:]...)
+			break
+		}
+		data, err := s.collectMarketData(ctx, symbol, tradingConfig.IndicatorParamsFor(symbol), !held[symbol])
+		if err != nil && ctx.Err() != nil {
+			// 收集中途到期，该交易对同样视为跳过
+			skipped = append(skipped, symbol)
+			continue
+		}
+		if errors.Is(err, ErrInsufficientHistory) {
+			s.logger.Warn("[RISK] symbol has too little kline history, excluded from trading",
+				zap.String("symbol", symbol),
+				zap.Error(err))
+			continue
+		}
 		if err != nil {
 			s.logger.Error("failed to collect market data",
 				zap.String("symbol", symbol),
@@ -227,9 +355,71 @@ func (s *MarketService) CollectAllSymbols(ctx context.Context, symbols []string)
 		result[symbol] = data
 	}
 
+	if len(skipped) > 0 {
+		s.logger.Warn("market data collection deadline reached, skipped remaining symbols",
+			zap.Int("collected", len(result)),
+			zap.Strings("skipped", skipped),
+			zap.Error(ctx.Err()))
+	}
+
 	if len(result) == 0 {
 		return nil, fmt.Errorf("failed to collect market data for any symbol")
 	}
 
 	return result, nil
 }
+
+// symbolStatusDelisted 交易所信息中已不存在该交易对时使用的状态
+const symbolStatusDelisted = "DELISTED"
+
+// SymbolTradingStatus 查询交易对的交易状态，交易对已从交易所信息中移除时返回 DELISTED
+func (s *MarketService) SymbolTradingStatus(ctx context.Context, symbol string) (string, bool, error) {
+	info, err := s.exchange.GetSymbolInfo(ctx, symbol)
+	if errors.Is(err, exchange.ErrSymbolNotFound) {
+		return symbolStatusDelisted, false, nil
+	}
+	if err != nil {
+		return "", true, err
+	}
+	return info.Status, info.Tradable(), nil
+}
+
+// StartSymbolInfoWorker 启动交易对信息预热worker
+//
+// 启动时一次拉取交易所信息缓存所有配置的交易对，之后按间隔刷新，开平仓时无需再临时请求 exchangeInfo。
+// symbols 每次刷新时调用，交易对配置变更后下一次刷新即生效。
+func (s *MarketService) StartSymbolInfoWorker(ctx context.Context, interval time.Duration, symbols func(ctx context.Context) ([]string, error)) {
+	s.logger.Info("starting symbol info worker", zap.Duration("interval", interval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// 立即预热一次
+		s.warmSymbolInfo(ctx, symbols)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.warmSymbolInfo(ctx, symbols)
+			case <-ctx.Done():
+				s.logger.Info("symbol info worker stopped by context")
+				return
+			}
+		}
+	}()
+}
+
+// warmSymbolInfo 预热当前配置交易对的交易对信息缓存
+func (s *MarketService) warmSymbolInfo(ctx context.Context, symbols func(ctx context.Context) ([]string, error)) {
+	list, err := symbols(ctx)
+	if err != nil {
+		s.logger.Error("failed to load symbols for symbol info warmup", zap.Error(err))
+		return
+	}
+	if err := s.exchange.WarmSymbolInfo(ctx, list); err != nil {
+		s.logger.Error("failed to warm symbol info", zap.Strings("symbols", list), zap.Error(err))
+		return
+	}
+	s.logger.Debug("symbol info warmed", zap.Strings("symbols", list))
+}
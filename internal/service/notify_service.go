@@ -0,0 +1,34 @@
+package service
+
+import (
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/telegram"
+	"go.uber.org/zap"
+)
+
+// NotifyService 运行告警通知服务，未启用 Telegram 时只写日志
+type NotifyService struct {
+	logger *zap.Logger
+	tg     *telegram.Telegram
+	chatID string
+}
+
+// NewNotifyService 创建通知服务，tg 为 nil 时通知只记录日志
+func NewNotifyService(tg *telegram.Telegram, conf *config.Config, logger *zap.Logger) *NotifyService {
+	return &NotifyService{
+		logger: logger,
+		tg:     tg,
+		chatID: conf.Telegram.ChatID,
+	}
+}
+
+// Notify 发送通知，发送失败只记录日志，不影响调用方流程
+func (s *NotifyService) Notify(msg string) {
+	s.logger.Info("notify", zap.String("message", msg))
+	if s.tg == nil || s.chatID == "" {
+		return
+	}
+	if err := s.tg.Notify(s.chatID, msg); err != nil {
+		s.logger.Warn("failed to send notification", zap.Error(err))
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// OpenWindowUsage 滚动时间窗口内的开仓次数，用于限制跨决策的整体交易频率
+type OpenWindowUsage struct {
+	Limit   int           // 窗口内最多开仓次数
+	Window  time.Duration // 滚动窗口长度
+	Count   int           // 窗口内已开仓次数
+	ResetAt time.Time     // 达到上限时，窗口内开仓次数降到上限以下的时间；未达到上限时为零值
+}
+
+// Exhausted 窗口内开仓次数是否已达到上限
+func (u *OpenWindowUsage) Exhausted() bool {
+	return u != nil && u.Count >= u.Limit
+}
+
+// newOpenWindowUsage 按窗口内的开仓交易（执行时间升序）计算使用情况
+func newOpenWindowUsage(opens []models.Trade, limit int, window time.Duration) *OpenWindowUsage {
+	usage := &OpenWindowUsage{Limit: limit, Window: window, Count: len(opens)}
+	if usage.Exhausted() {
+		// 第 Count-Limit+1 早的开仓移出窗口后，次数回到上限以下
+		usage.ResetAt = opens[usage.Count-limit].ExecutedAt.Add(window)
+	}
+	return usage
+}
+
+// OpenWindowUsage 当前滚动窗口内的开仓次数，未配置上限时返回 nil
+func (s *AgentService) OpenWindowUsage(ctx context.Context) (*OpenWindowUsage, error) {
+	limit := s.conf.Trading.MaxOpensPerWindow
+	if limit <= 0 {
+		return nil, nil
+	}
+	window := time.Duration(max(s.conf.Trading.OpenWindowHours, 1)) * time.Hour
+	opens, err := s.TradeRepo.FindOpenTradesSince(ctx, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	return newOpenWindowUsage(opens, limit, window), nil
+}
+
+// untilReset 距窗口恢复开仓的剩余时间（精确到分钟）
+func (u *OpenWindowUsage) untilReset(now time.Time) string {
+	remaining := max(u.ResetAt.Sub(now), time.Minute)
+	str, _ := strings.CutSuffix(remaining.Round(time.Minute).String(), "0s")
+	return str
+}
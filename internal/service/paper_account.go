@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrNotPaperMode 当前不是纸钱包模式，不允许重置模拟账户
+var ErrNotPaperMode = errors.New("paper account operations are only available in paper mode")
+
+// paperResetModels 重置模拟账户时清空的表，资金曲线、持仓和决策记录从零开始
+var paperResetModels = []interface{}{
+	&models.Position{},
+	&models.Order{},
+	&models.Trade{},
+	&models.Decision{},
+	&models.LLMLog{},
+	&models.AccountHistory{},
+	&models.FundingPayment{},
+	&models.CapitalFlow{},
+}
+
+// PaperAccountState 模拟账户状态
+type PaperAccountState struct {
+	InitialBalance float64 `json:"initial_balance"`
+	Balance        float64 `json:"balance"`
+}
+
+// paperWallet 返回当前使用的纸钱包，实盘模式返回 ErrNotPaperMode
+func (s *TradingAccountService) paperWallet() (*exchange.PaperWallet, error) {
+	wallet, ok := s.exchange.(*exchange.PaperWallet)
+	if !ok || s.conf.IsLive() {
+		return nil, ErrNotPaperMode
+	}
+	return wallet, nil
+}
+
+// GetPaperAccount 获取模拟账户的初始资金和当前余额
+func (s *TradingAccountService) GetPaperAccount() (*PaperAccountState, error) {
+	wallet, err := s.paperWallet()
+	if err != nil {
+		return nil, err
+	}
+	return &PaperAccountState{InitialBalance: wallet.GetInitialBalance(), Balance: wallet.GetBalance()}, nil
+}
+
+// ResetPaperAccount 重置模拟账户并清空持仓、订单、交易、决策和账户历史
+//
+// initialBalance 大于0时同时修改初始资金，为0时沿用原初始资金。管理后台设置了初始资金锚点时同步更新为新的初始资金，
+// 使收益率和资金曲线从重置时刻重新计算。调用方需先停止交易循环。
+func (s *TradingAccountService) ResetPaperAccount(ctx context.Context, initialBalance float64) (*PaperAccountState, error) {
+	wallet, err := s.paperWallet()
+	if err != nil {
+		return nil, err
+	}
+	if initialBalance < 0 || math.IsNaN(initialBalance) || math.IsInf(initialBalance, 0) {
+		return nil, fmt.Errorf("初始资金必须为正数")
+	}
+
+	err = s.Transaction(ctx, func(ctx context.Context) error {
+		db := s.AccountHistoryRepo.GetDB(ctx).Unscoped().Session(&gorm.Session{AllowGlobalUpdate: true})
+		for _, model := range paperResetModels {
+			if err := db.Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to clear %T: %w", model, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if initialBalance > 0 {
+		wallet.SetInitialBalance(initialBalance)
+	} else {
+		wallet.Reset()
+	}
+
+	if err := s.adminConfigService.ResetBalanceAnchor(ctx, wallet.GetInitialBalance(), time.Now()); err != nil {
+		s.logger.Warn("failed to reset initial balance anchor", zap.Error(err))
+	}
+
+	s.logger.Info("paper account reset", zap.Float64("initial_balance", wallet.GetInitialBalance()))
+	return &PaperAccountState{InitialBalance: wallet.GetInitialBalance(), Balance: wallet.GetBalance()}, nil
+}
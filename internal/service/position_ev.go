@@ -0,0 +1,83 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// evDefaultHorizonHours 未限制持仓时间时估算期望值使用的窗口（小时）
+const evDefaultHorizonHours = 24
+
+// PositionExpectedValue 持仓从当前价格出发的期望值粗估
+//
+// 以1小时ATR作为每根1小时K线的价格波动幅度，把价格视为无漂移的随机游走：
+//   - 当前价格到止盈、止损的距离折算为ATR倍数 d，窗口为到最长持仓时间的剩余小时数 n（未限制时按24小时）
+//   - 窗口内触及距离为 d 的价位的概率 P(d) = 2 × (1 - Φ(d/√n)) = erfc(d/√(2n))（反射原理）
+//   - 两个概率之和超过1时按比例归一，使 P止盈 + P止损 ≤ 1
+//   - 期望值 = P止盈 × 剩余收益 - P止损 × 剩余风险，剩余收益、风险为从当前价格到止盈、止损的盈亏
+//
+// 只用于比较从当前价格看的剩余盈亏比，不代表真实胜率。
+type PositionExpectedValue struct {
+	Reward                float64 `json:"reward"`                  // 到止盈的剩余收益（USDT）
+	Risk                  float64 `json:"risk"`                    // 到止损的剩余亏损（USDT，正数）
+	RewardATR             float64 `json:"reward_atr"`              // 到止盈的距离（1小时ATR倍数）
+	RiskATR               float64 `json:"risk_atr"`                // 到止损的距离（1小时ATR倍数）
+	TakeProfitProbability float64 `json:"take_profit_probability"` // 窗口内触及止盈的估算概率（0-1）
+	StopProbability       float64 `json:"stop_probability"`        // 窗口内触及止损的估算概率（0-1）
+	HorizonHours          int     `json:"horizon_hours"`           // 估算窗口（小时）
+	ExpectedValue         float64 `json:"expected_value"`          // 期望值（USDT）
+}
+
+// RewardRisk 剩余盈亏比（剩余收益 / 剩余风险）
+func (ev PositionExpectedValue) RewardRisk() float64 {
+	if ev.Risk <= 0 {
+		return 0
+	}
+	return ev.Reward / ev.Risk
+}
+
+// computePositionExpectedValue 估算持仓的期望值，atr 为1小时ATR
+//
+// 未同时设置止盈和止损、止盈止损不在当前价格两侧或缺少ATR时返回 false。
+func computePositionExpectedValue(pos *models.Position, atr float64, maxHoldingHours int, now time.Time) (PositionExpectedValue, bool) {
+	if pos.CurrentPrice <= 0 || pos.Quantity <= 0 || pos.StopLoss <= 0 || pos.TakeProfit <= 0 || atr <= 0 {
+		return PositionExpectedValue{}, false
+	}
+
+	rewardDistance := pos.TakeProfit - pos.CurrentPrice
+	riskDistance := pos.CurrentPrice - pos.StopLoss
+	if pos.Side == "short" {
+		rewardDistance, riskDistance = -rewardDistance, -riskDistance
+	}
+	if rewardDistance <= 0 || riskDistance <= 0 {
+		return PositionExpectedValue{}, false
+	}
+
+	horizon := evDefaultHorizonHours
+	if deadline, ok := pos.HoldingDeadline(maxHoldingHours); ok {
+		horizon = max(int(math.Ceil(deadline.Sub(now).Hours())), 1)
+	}
+
+	ev := PositionExpectedValue{
+		Reward:       rewardDistance * pos.Quantity,
+		Risk:         riskDistance * pos.Quantity,
+		RewardATR:    rewardDistance / atr,
+		RiskATR:      riskDistance / atr,
+		HorizonHours: horizon,
+	}
+	ev.TakeProfitProbability = touchProbability(ev.RewardATR, horizon)
+	ev.StopProbability = touchProbability(ev.RiskATR, horizon)
+	if total := ev.TakeProfitProbability + ev.StopProbability; total > 1 {
+		ev.TakeProfitProbability /= total
+		ev.StopProbability /= total
+	}
+	ev.ExpectedValue = ev.TakeProfitProbability*ev.Reward - ev.StopProbability*ev.Risk
+	return ev, true
+}
+
+// touchProbability 无漂移随机游走在 bars 根K线内触及 distance 个ATR 之外价位的概率
+func touchProbability(distance float64, bars int) float64 {
+	return clamp01(math.Erfc(distance / math.Sqrt(2*float64(bars))))
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestComputePositionExpectedValue(t *testing.T) {
+	now := time.Now()
+	pos := &models.Position{
+		Side:         "long",
+		EntryPrice:   100,
+		CurrentPrice: 100,
+		Quantity:     2,
+		StopLoss:     98,
+		TakeProfit:   106,
+		OpenedAt:     now,
+	}
+
+	ev, ok := computePositionExpectedValue(pos, 1, 0, now)
+	if !ok {
+		t.Fatal("expected value should be computed")
+	}
+	if ev.Reward != 12 || ev.Risk != 4 || ev.RewardATR != 6 || ev.RiskATR != 2 {
+		t.Fatalf("unexpected distances: %+v", ev)
+	}
+	if ev.HorizonHours != evDefaultHorizonHours {
+		t.Fatalf("horizon = %d, want %d", ev.HorizonHours, evDefaultHorizonHours)
+	}
+	if ev.StopProbability <= ev.TakeProfitProbability {
+		t.Fatalf("nearer stop should be more likely to be touched: %+v", ev)
+	}
+	want := ev.TakeProfitProbability*12 - ev.StopProbability*4
+	if math.Abs(ev.ExpectedValue-want) > 1e-9 || math.Abs(ev.RewardRisk()-3) > 1e-9 {
+		t.Fatalf("unexpected ev: %+v", ev)
+	}
+
+	// 剩余时间越短，触及远处止盈的概率越低
+	short, _ := computePositionExpectedValue(pos, 1, 1, now.Add(30*time.Minute))
+	if short.HorizonHours != 1 || short.TakeProfitProbability >= ev.TakeProfitProbability {
+		t.Fatalf("short horizon should lower take profit probability: %+v", short)
+	}
+
+	// 空单方向相反
+	shortPos := *pos
+	shortPos.Side = "short"
+	shortPos.StopLoss, shortPos.TakeProfit = 102, 94
+	if sev, ok := computePositionExpectedValue(&shortPos, 1, 0, now); !ok || sev.Reward != 12 || sev.Risk != 4 {
+		t.Fatalf("unexpected short ev: %+v ok=%v", sev, ok)
+	}
+
+	// 缺少止盈或ATR时不计算
+	noTarget := *pos
+	noTarget.TakeProfit = 0
+	if _, ok := computePositionExpectedValue(&noTarget, 1, 0, now); ok {
+		t.Fatal("position without take profit should be skipped")
+	}
+	if _, ok := computePositionExpectedValue(pos, 0, 0, now); ok {
+		t.Fatal("missing ATR should be skipped")
+	}
+}
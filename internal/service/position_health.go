@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// 健康分各因子的满分阈值
+const (
+	healthSafeLiquidationPercent = 20.0 // 当前价格距强平价格达到该百分比时强平因子满分
+	healthFundingCostCapPercent  = 1.0  // 每次资金费支出占保证金达到该百分比时资金费因子为0
+)
+
+// PositionHealth 持仓健康分（0-100，越高越健康）及各因子得分（0-1）
+//
+// 健康分 = 100 × Σ(权重 × 因子得分) / Σ权重，各因子：
+//   - liquidation: 当前价格距强平价格的百分比 / 20%，无强平价格时为1
+//   - stop: 当前价格距止损价的距离 / 开仓时的初始风险（|开仓价-止损价|），回到开仓价或更好时为1，未设置止损时为0
+//   - time: 1 - 已持仓时间 / 最长持仓时间，未限制持仓时间时为1
+//   - giveback: 1 - (峰值盈亏% - 当前盈亏%) / 峰值盈亏%，峰值未盈利时为1
+//   - funding: 1 - 每次资金费支出占保证金% / 1%，收取资金费时为1
+//
+// 减仓时优先处理健康分最低的持仓。
+type PositionHealth struct {
+	Score       float64 `json:"score"`
+	Liquidation float64 `json:"liquidation"`
+	Stop        float64 `json:"stop"`
+	Time        float64 `json:"time"`
+	Giveback    float64 `json:"giveback"`
+	Funding     float64 `json:"funding"`
+}
+
+// computePositionHealth 按配置的权重计算持仓健康分，fundingRate 为当前资金费率（小数）
+func computePositionHealth(pos *models.Position, maxHoldingHours int, fundingRate float64, weights config.PositionHealthWeights, now time.Time) PositionHealth {
+	health := PositionHealth{
+		Liquidation: 1,
+		Time:        1,
+		Giveback:    1,
+		Funding:     1,
+	}
+
+	if pos.LiquidationPrice > 0 && pos.CurrentPrice > 0 {
+		distance := math.Abs(pos.CurrentPrice-pos.LiquidationPrice) / pos.CurrentPrice * 100
+		health.Liquidation = clamp01(distance / healthSafeLiquidationPercent)
+	}
+
+	if pos.StopLoss > 0 {
+		initialRisk := pos.RiskPerUnit
+		if initialRisk <= 0 {
+			initialRisk = math.Abs(pos.EntryPrice - pos.StopLoss)
+		}
+		if initialRisk > 0 {
+			distance, _ := pos.StopRisk()
+			if pos.Quantity > 0 {
+				distance /= pos.Quantity
+			}
+			health.Stop = clamp01(distance / initialRisk)
+		}
+	}
+
+	if deadline, ok := pos.HoldingDeadline(maxHoldingHours); ok {
+		total := deadline.Sub(pos.OpenedAt)
+		health.Time = clamp01(deadline.Sub(now).Hours() / total.Hours())
+	}
+
+	if pos.PeakPnlPercent > 0 {
+		giveback := (pos.PeakPnlPercent - pos.CalculatePnlPercent()) / pos.PeakPnlPercent
+		health.Giveback = clamp01(1 - giveback)
+	}
+
+	if pos.Margin > 0 {
+		if cost := -estimatePositionFunding(pos, fundingRate); cost > 0 {
+			health.Funding = clamp01(1 - cost/pos.Margin*100/healthFundingCostCapPercent)
+		}
+	}
+
+	w := weights.Normalized()
+	weighted := w.Liquidation*health.Liquidation + w.Stop*health.Stop + w.Time*health.Time +
+		w.Giveback*health.Giveback + w.Funding*health.Funding
+	health.Score = weighted / w.Sum() * 100
+	return health
+}
+
+// clamp01 将值限制在 [0,1]
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// PositionsHealth 计算持仓的健康分，按持仓ID索引；资金费率获取失败时按0计算
+func (s *AgentService) PositionsHealth(ctx context.Context, positions []models.Position) map[string]PositionHealth {
+	maxHoldingHours := 0
+	if tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx); err != nil {
+		s.logger.Warn("failed to get trading config for position health", zap.Error(err))
+	} else {
+		maxHoldingHours = tradingConfig.MaxHoldingHours
+	}
+
+	now := time.Now()
+	fundingRates := make(map[string]float64)
+	result := make(map[string]PositionHealth, len(positions))
+	for i := range positions {
+		pos := &positions[i]
+		rate, ok := fundingRates[pos.Symbol]
+		if !ok && !pos.IsFrozen() {
+			var err error
+			if rate, err = s.exchange.GetFundingRate(ctx, pos.Symbol); err != nil {
+				s.logger.Warn("failed to get funding rate for position health", zap.String("symbol", pos.Symbol), zap.Error(err))
+				rate = 0
+			}
+			fundingRates[pos.Symbol] = rate
+		}
+		result[pos.ID] = computePositionHealth(pos, maxHoldingHours, rate, s.conf.Trading.PositionHealthWeights, now)
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+)
+
+func TestComputePositionHealth(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	weights := config.DefaultPositionHealthWeights
+
+	healthy := &models.Position{
+		Symbol:         "BTCUSDT",
+		Side:           "long",
+		Quantity:       1,
+		EntryPrice:     100,
+		CurrentPrice:   110,
+		StopLoss:       95,
+		RiskPerUnit:    5,
+		Leverage:       5,
+		Margin:         20,
+		PeakPnlPercent: 50,
+		OpenedAt:       now,
+	}
+	got := computePositionHealth(healthy, 0, 0, weights, now)
+	if math.Abs(got.Score-100) > 1e-9 {
+		t.Fatalf("healthy position score = %v, want 100 (%+v)", got.Score, got)
+	}
+
+	// 触及止损、盈利全部回吐、持仓时间用尽、无止损
+	unhealthy := &models.Position{
+		Symbol:         "BTCUSDT",
+		Side:           "long",
+		Quantity:       1,
+		EntryPrice:     100,
+		CurrentPrice:   100,
+		Leverage:       5,
+		Margin:         20,
+		PeakPnlPercent: 20,
+		OpenedAt:       now.Add(-10 * time.Hour),
+	}
+	got = computePositionHealth(unhealthy, 10, 0, weights, now)
+	if got.Stop != 0 || got.Giveback != 0 || got.Time != 0 {
+		t.Fatalf("unexpected components %+v", got)
+	}
+	want := (weights.Liquidation + weights.Funding) / weights.Sum() * 100
+	if math.Abs(got.Score-want) > 1e-9 {
+		t.Fatalf("score = %v, want %v", got.Score, want)
+	}
+
+	// 全部为0的权重使用默认权重
+	zero := computePositionHealth(unhealthy, 10, 0, config.PositionHealthWeights{}, now)
+	if math.Abs(zero.Score-want) > 1e-9 {
+		t.Fatalf("zero weights score = %v, want %v", zero.Score, want)
+	}
+
+	// 只看强平距离：当前价距强平价 10%，得分一半
+	liq := &models.Position{Side: "long", Quantity: 1, EntryPrice: 100, CurrentPrice: 100, LiquidationPrice: 90, OpenedAt: now}
+	got = computePositionHealth(liq, 0, 0, config.PositionHealthWeights{Liquidation: 1}, now)
+	if math.Abs(got.Score-50) > 1e-9 {
+		t.Fatalf("liquidation score = %v, want 50", got.Score)
+	}
+}
@@ -3,11 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dushixiang/prism/internal/config"
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/repo"
 	"github.com/dushixiang/prism/pkg/exchange"
@@ -27,6 +29,10 @@ type PositionService struct {
 	exchange  exchange.Exchange
 	orderRepo *repo.OrderRepo
 	tradeRepo *repo.TradeRepo
+	conf      *config.Config
+
+	// 分批止盈某档成交且持仓仍有剩余时的回调，用于调整剩余仓位的止损
+	ladderFillHandler func(ctx context.Context, order *models.Order, position *models.Position)
 
 	// 后台同步相关
 	syncMutex sync.Mutex // 防止并发同步
@@ -35,7 +41,7 @@ type PositionService struct {
 }
 
 // NewPositionService 创建持仓服务
-func NewPositionService(db *gorm.DB, exchange exchange.Exchange, orderRepo *repo.OrderRepo, tradeRepo *repo.TradeRepo, logger *zap.Logger) *PositionService {
+func NewPositionService(db *gorm.DB, exchange exchange.Exchange, orderRepo *repo.OrderRepo, tradeRepo *repo.TradeRepo, logger *zap.Logger, conf *config.Config) *PositionService {
 	return &PositionService{
 		logger:       logger,
 		Service:      orz.NewService(db),
@@ -43,9 +49,15 @@ func NewPositionService(db *gorm.DB, exchange exchange.Exchange, orderRepo *repo
 		exchange:     exchange,
 		orderRepo:    orderRepo,
 		tradeRepo:    tradeRepo,
+		conf:         conf,
 	}
 }
 
+// SetLadderFillHandler 设置分批止盈成交后的回调
+func (s *PositionService) SetLadderFillHandler(handler func(ctx context.Context, order *models.Order, position *models.Position)) {
+	s.ladderFillHandler = handler
+}
+
 // SyncPositions 同步持仓数据
 func (s *PositionService) SyncPositions(ctx context.Context) error {
 	// 使用互斥锁避免并发同步
@@ -71,24 +83,25 @@ func (s *PositionService) SyncPositions(ctx context.Context) error {
 		existingMap[key] = pos
 	}
 
+	tolerance := s.syncTolerance()
+
 	err = s.Transaction(ctx, func(ctx context.Context) error {
 		seen := make(map[string]struct{}, len(positions))
 
 		// 更新或新增持仓
 		for _, p := range positions {
-			// 计算保证金
-			margin := 0.0
-			if p.Leverage != 0 {
-				margin = p.EntryPrice * p.PositionAmount / float64(p.Leverage)
-			}
-			if margin < 0 {
-				margin = -margin
-			}
+			margin := exchange.InitialMargin(p.EntryPrice, p.PositionAmount, p.Leverage)
 
 			key := fmt.Sprintf("%s|%s", p.Symbol, p.Side)
 			if existingPos, ok := existingMap[key]; ok {
+				previous := *existingPos
+
+				// 开仓价以开仓成交为准，只有加仓导致持仓数量增加时才采用交易所的加权均价，避免开仓价漂移
+				if existingPos.EntryPrice <= 0 ||
+					(p.PositionAmount > existingPos.Quantity && !withinTolerance(p.PositionAmount, existingPos.Quantity, tolerance)) {
+					existingPos.EntryPrice = p.EntryPrice
+				}
 				existingPos.Quantity = p.PositionAmount
-				existingPos.EntryPrice = p.EntryPrice
 				existingPos.CurrentPrice = p.MarkPrice
 				existingPos.LiquidationPrice = p.LiquidationPrice
 				existingPos.UnrealizedPnl = p.UnrealizedProfit
@@ -100,8 +113,11 @@ func (s *PositionService) SyncPositions(ctx context.Context) error {
 					existingPos.PeakPnlPercent = pnlPercent
 				}
 
-				if err := s.PositionRepo.Save(ctx, existingPos); err != nil {
-					return fmt.Errorf("failed to update position %s %s: %w", p.Symbol, p.Side, err)
+				// 变化都在容差内时不写库，减少每3秒一次同步带来的写入
+				if positionChanged(&previous, existingPos, tolerance) {
+					if err := s.PositionRepo.Save(ctx, existingPos); err != nil {
+						return fmt.Errorf("failed to update position %s %s: %w", p.Symbol, p.Side, err)
+					}
 				}
 			} else {
 				position := &models.Position{
@@ -109,7 +125,7 @@ func (s *PositionService) SyncPositions(ctx context.Context) error {
 					Symbol:           p.Symbol,
 					Side:             p.Side,
 					Quantity:         p.PositionAmount,
-					EntryPrice:       p.EntryPrice,
+					EntryPrice:       s.resolveEntryPrice(ctx, p),
 					CurrentPrice:     p.MarkPrice,
 					LiquidationPrice: p.LiquidationPrice,
 					UnrealizedPnl:    p.UnrealizedProfit,
@@ -158,6 +174,60 @@ func (s *PositionService) SyncPositions(ctx context.Context) error {
 	return nil
 }
 
+// positionEntryTradeWindow 新建本地持仓时向前查找开仓成交的时间窗口，开仓后会立即同步持仓
+const positionEntryTradeWindow = time.Minute
+
+// resolveEntryPrice 新建本地持仓的开仓价：优先使用刚记录的开仓成交价，找不到时（如手动开仓）使用交易所开仓均价
+func (s *PositionService) resolveEntryPrice(ctx context.Context, p *exchange.Position) float64 {
+	if s.tradeRepo == nil {
+		return p.EntryPrice
+	}
+	trade, err := s.tradeRepo.FindLatestOpenTrade(ctx, p.Symbol, p.Side, time.Now().Add(-positionEntryTradeWindow))
+	if err != nil || trade.Price <= 0 {
+		return p.EntryPrice
+	}
+	return trade.Price
+}
+
+// syncTolerance 持仓同步的相对容差（比例）
+func (s *PositionService) syncTolerance() float64 {
+	if s.conf == nil || s.conf.Trading.PositionSyncTolerancePercent < 0 {
+		return 0
+	}
+	return s.conf.Trading.PositionSyncTolerancePercent / 100
+}
+
+// withinTolerance a 与 b 的相对差不超过 tolerance 时视为相等
+func withinTolerance(a, b, tolerance float64) bool {
+	diff := math.Abs(a - b)
+	if diff == 0 {
+		return true
+	}
+	return diff <= tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// positionChanged 判断同步后的持仓相对之前是否有超出容差的变化
+func positionChanged(before, after *models.Position, tolerance float64) bool {
+	if before.Leverage != after.Leverage {
+		return true
+	}
+	pairs := [][2]float64{
+		{before.Quantity, after.Quantity},
+		{before.EntryPrice, after.EntryPrice},
+		{before.CurrentPrice, after.CurrentPrice},
+		{before.LiquidationPrice, after.LiquidationPrice},
+		{before.UnrealizedPnl, after.UnrealizedPnl},
+		{before.Margin, after.Margin},
+		{before.PeakPnlPercent, after.PeakPnlPercent},
+	}
+	for _, pair := range pairs {
+		if !withinTolerance(pair[0], pair[1], tolerance) {
+			return true
+		}
+	}
+	return false
+}
+
 // parseExchangeOrderID 解析交易所订单ID字符串为int64
 func (s *PositionService) parseExchangeOrderID(exchangeID string) (int64, error) {
 	if exchangeID == "" {
@@ -277,12 +347,67 @@ func (s *PositionService) syncOrderStatus(ctx context.Context) error {
 
 	// 检查每个持仓的订单状态
 	for posID, orders := range ordersByPosition {
+		orders = s.cancelDuplicateOrders(ctx, posID, orders)
 		s.checkPositionOrders(ctx, posID, orders)
 	}
 
 	return nil
 }
 
+// duplicateOrderKey 判断重复订单的分组：订单类型和分批止盈档位，不同档位的止盈单不算重复
+type duplicateOrderKey struct {
+	orderType   models.OrderType
+	ladderLevel int
+}
+
+// splitDuplicateOrders 按订单类型（分批止盈按档位）去重，同一类型只保留最新创建的订单，返回保留的订单和多余的旧订单
+func splitDuplicateOrders(orders []models.Order) (kept []models.Order, stale []models.Order) {
+	latest := make(map[duplicateOrderKey]int)
+	for i := range orders {
+		key := duplicateOrderKey{orders[i].OrderType, orders[i].LadderLevel}
+		j, exists := latest[key]
+		if !exists || orders[i].CreatedAt.After(orders[j].CreatedAt) {
+			latest[key] = i
+		}
+	}
+
+	for i := range orders {
+		if latest[duplicateOrderKey{orders[i].OrderType, orders[i].LadderLevel}] == i {
+			kept = append(kept, orders[i])
+		} else {
+			stale = append(stale, orders[i])
+		}
+	}
+	return kept, stale
+}
+
+// cancelDuplicateOrders 检测同一持仓存在多个活跃止损（或止盈）单的异常，取消旧订单只保留最新的
+// 避免多个止损同时触发导致超额平仓甚至反向开仓
+func (s *PositionService) cancelDuplicateOrders(ctx context.Context, positionID string, orders []models.Order) []models.Order {
+	kept, stale := splitDuplicateOrders(orders)
+	if len(stale) == 0 {
+		return orders
+	}
+
+	s.logger.Warn("detected duplicate active stop orders for position, cancelling stale ones",
+		zap.String("position_id", positionID),
+		zap.String("symbol", orders[0].Symbol),
+		zap.Int("active_orders", len(orders)),
+		zap.Int("stale_orders", len(stale)))
+
+	for i := range stale {
+		order := &stale[i]
+		if err := s.cancelOrderOnExchange(ctx, order, "duplicate order"); err != nil {
+			// 取消失败（可能已成交），保留该订单继续同步状态
+			kept = append(kept, *order)
+			continue
+		}
+		s.updateOrderStatusToCanceled(ctx, order.ID)
+	}
+
+	return kept
+}
+
 // queryExchangeOrderStatus 查询单个订单在交易所的状态
 func (s *PositionService) queryExchangeOrderStatus(ctx context.Context, order *models.Order) (string, error) {
 	if order.ExchangeID == "" {
@@ -351,7 +476,9 @@ func (s *PositionService) checkPositionOrders(ctx context.Context, positionID st
 	}
 
 	var triggeredOrder *models.Order
+	var ladderFills []*models.Order
 	var updates []orderStatusUpdate
+	filled := make(map[string]bool)
 
 	// 逐个查询交易所订单状态
 	for i := range orders {
@@ -371,9 +498,14 @@ func (s *PositionService) checkPositionOrders(ctx context.Context, positionID st
 			exchangeStatus: exchangeStatus,
 		})
 
-		// 检查是否有订单被触发
+		// 检查是否有订单被触发，分批止盈单成交后持仓可能仍有剩余，单独处理
 		if exchangeStatus == "FILLED" {
-			triggeredOrder = order
+			filled[order.ID] = true
+			if order.IsTakeProfit() && order.LadderLevel > 0 {
+				ladderFills = append(ladderFills, order)
+			} else {
+				triggeredOrder = order
+			}
 		}
 	}
 
@@ -382,10 +514,67 @@ func (s *PositionService) checkPositionOrders(ctx context.Context, positionID st
 		s.syncSingleOrderStatus(ctx, update.order, update.exchangeStatus)
 	}
 
-	// 如果有订单被触发，需要额外处理
+	// 同一轮可能有多档同时成交，剩余数量要扣除本轮已处理的全部档位
+	ladderFilled := 0.0
+	for _, order := range ladderFills {
+		if triggeredOrder == nil && s.handleLadderFill(ctx, order, orders, ladderFilled) {
+			ladderFilled += order.Quantity
+			continue
+		}
+		// 持仓已全部平掉（最后一档成交或止损同时触发），按普通触发处理
+		if triggeredOrder == nil {
+			triggeredOrder = order
+		} else if s.tradeRepo != nil {
+			s.recordTriggeredOrderTrade(ctx, order)
+		}
+	}
+
+	// 如果有订单被触发，需要额外处理；已成交的订单不再取消
 	if triggeredOrder != nil {
-		s.handleTriggeredOrder(ctx, triggeredOrder, orders)
+		pending := make([]models.Order, 0, len(orders))
+		for i := range orders {
+			if orders[i].ID == triggeredOrder.ID || !filled[orders[i].ID] {
+				pending = append(pending, orders[i])
+			}
+		}
+		s.handleTriggeredOrder(ctx, triggeredOrder, pending)
+	}
+}
+
+// handleLadderFill 处理分批止盈某一档成交：持仓仍有剩余时只记录成交并按剩余数量调整止损，不取消其他订单
+//
+// previouslyFilled 为本轮已处理的其他档位成交数量；持仓已不存在或该档成交后没有剩余数量时返回 false，
+// 由调用方按普通触发处理。
+func (s *PositionService) handleLadderFill(ctx context.Context, order *models.Order, orders []models.Order, previouslyFilled float64) bool {
+	position, err := s.PositionRepo.FindById(ctx, order.PositionID)
+	if err != nil || position.Quantity <= 0 {
+		return false
+	}
+	for i := range orders {
+		if orders[i].IsStopLoss() {
+			position.Quantity = ladderRemainingQuantity(orders[i].Quantity, previouslyFilled+order.Quantity, position.Quantity)
+			break
+		}
+	}
+	if position.Quantity <= 0 {
+		return false
+	}
+
+	s.logger.Info("take profit level filled, position partially closed",
+		zap.String("order_id", order.ID),
+		zap.String("symbol", order.Symbol),
+		zap.Int("level", order.LadderLevel),
+		zap.Float64("trigger_price", order.TriggerPrice),
+		zap.Float64("quantity", order.Quantity),
+		zap.Float64("remaining_quantity", position.Quantity))
+
+	if s.tradeRepo != nil {
+		s.recordTriggeredOrderTrade(ctx, order)
+	}
+	if s.ladderFillHandler != nil {
+		s.ladderFillHandler(ctx, order, &position)
 	}
+	return true
 }
 
 // mapExchangeStatusToLocal 映射交易所订单状态到本地状态
@@ -445,6 +634,18 @@ func (s *PositionService) syncSingleOrderStatus(ctx context.Context, order *mode
 	}
 }
 
+// triggeredOrderReasonCode 根据触发的订单类型确定平仓原因分类
+func triggeredOrderReasonCode(order *models.Order) models.CloseReasonCode {
+	switch {
+	case order.IsStopLoss():
+		return models.CloseReasonStopLoss
+	case order.IsTakeProfit():
+		return models.CloseReasonTakeProfit
+	default:
+		return models.CloseReasonOther
+	}
+}
+
 // recordTriggeredOrderTrade 记录由订单触发的平仓交易
 // 使用交易所的交易历史获取准确的成交价格、数量和手续费
 // 将多笔成交合并为一条记录,使用最后一笔成交的时间
@@ -509,9 +710,27 @@ func (s *PositionService) recordTriggeredOrderTrade(ctx context.Context, order *
 		Fee:        totalCommission,
 		Pnl:        totalRealizedPnl,
 		Reason:     fmt.Sprintf("订单触发: %s @ $%.2f", order.OrderType, avgPrice),
+		ReasonCode: triggeredOrderReasonCode(order),
 		OrderID:    order.ExchangeID,
 		PositionID: order.PositionID,
-		ExecutedAt: time.UnixMilli(lastTradeTime),
+		Mode:       s.conf.TradingMode(),
+		ExecutedAt: time.UnixMilli(lastTradeTime).Add(s.exchange.TimeOffset()),
+	}
+	// 触发单的参考价格为触发价，市价触发时可能远离触发价成交
+	trade.ApplySlippage(order.TriggerPrice)
+	if limit := s.conf.Trading.MaxFillSlippagePercent; limit > 0 && trade.SlippagePercent > limit {
+		s.logger.Warn("triggered order filled with excessive slippage",
+			zap.String("symbol", order.Symbol),
+			zap.String("order_type", string(order.OrderType)),
+			zap.Float64("trigger_price", order.TriggerPrice),
+			zap.Float64("avg_price", avgPrice),
+			zap.Float64("slippage_percent", trade.SlippagePercent),
+			zap.Float64("max_slippage_percent", limit))
+	}
+	// 沿用持仓的开仓信心，便于按信心统计平仓结果（持仓可能已被软删除）
+	if positions, err := s.PositionRepo.FindByIDsUnscoped(ctx, []string{order.PositionID}); err == nil && len(positions) > 0 {
+		trade.Confidence = positions[0].Confidence
+		trade.ApplyPositionRisk(&positions[0])
 	}
 
 	if err := s.tradeRepo.Create(ctx, trade); err != nil {
@@ -570,12 +789,12 @@ func (s *PositionService) DeletePosition(ctx context.Context, id string) error {
 	return s.PositionRepo.DeleteById(ctx, id)
 }
 
-// UpdatePositionPlan 更新持仓的开仓理由与退出计划
-func (s *PositionService) UpdatePositionPlan(ctx context.Context, symbol, side, entryReason, exitPlan string) error {
+// UpdatePositionPlan 更新持仓的开仓理由、退出计划、论点失效价格与开仓信心
+func (s *PositionService) UpdatePositionPlan(ctx context.Context, symbol, side, entryReason, exitPlan string, invalidationPrice float64, confidence int) error {
 	entryReason = strings.TrimSpace(entryReason)
 	exitPlan = strings.TrimSpace(exitPlan)
 
-	if entryReason == "" && exitPlan == "" {
+	if entryReason == "" && exitPlan == "" && invalidationPrice <= 0 && confidence <= 0 {
 		return nil
 	}
 
@@ -593,6 +812,14 @@ func (s *PositionService) UpdatePositionPlan(ctx context.Context, symbol, side,
 		position.ExitPlan = exitPlan
 		updated = true
 	}
+	if invalidationPrice > 0 && position.InvalidationPrice != invalidationPrice {
+		position.InvalidationPrice = invalidationPrice
+		updated = true
+	}
+	if confidence > 0 && position.Confidence != confidence {
+		position.Confidence = confidence
+		updated = true
+	}
 
 	if !updated {
 		return nil
@@ -601,6 +828,85 @@ func (s *PositionService) UpdatePositionPlan(ctx context.Context, symbol, side,
 	return s.PositionRepo.Save(ctx, &position)
 }
 
+// RestorePositionPlan 把持仓的交易计划恢复为 prior 中的值，用于加仓回滚后撤销本次开仓对计划的覆盖
+func (s *PositionService) RestorePositionPlan(ctx context.Context, positionID string, prior *models.Position) error {
+	position, err := s.PositionRepo.FindById(ctx, positionID)
+	if err != nil {
+		return err
+	}
+	position.EntryReason = prior.EntryReason
+	position.ExitPlan = prior.ExitPlan
+	position.InvalidationPrice = prior.InvalidationPrice
+	position.Confidence = prior.Confidence
+	position.EntrySlippagePercent = prior.EntrySlippagePercent
+	return s.PositionRepo.Save(ctx, &position)
+}
+
+// LinkOpenTrade 把开仓交易关联到同步后的本地持仓，之后的平仓盈亏按持仓归集到该笔开仓
+func (s *PositionService) LinkOpenTrade(ctx context.Context, trade *models.Trade) error {
+	position, err := s.PositionRepo.FindActiveBySymbolAndSide(ctx, trade.Symbol, trade.Side)
+	if err != nil {
+		return err
+	}
+	trade.PositionID = position.ID
+	return s.tradeRepo.UpdateColumnsById(ctx, trade.ID, map[string]interface{}{"position_id": position.ID})
+}
+
+// RecordRiskPlan 记录持仓开仓时的计划盈亏比和每单位初始风险，已记录过的持仓（如加仓）保持首次开仓的计划
+func (s *PositionService) RecordRiskPlan(ctx context.Context, symbol, side string, stopLoss, takeProfit float64) error {
+	position, err := s.PositionRepo.FindActiveBySymbolAndSide(ctx, symbol, side)
+	if err != nil {
+		return err
+	}
+	if position.RiskPerUnit > 0 || position.EntryPrice <= 0 || stopLoss <= 0 {
+		return nil
+	}
+
+	position.RiskPerUnit = math.Abs(position.EntryPrice - stopLoss)
+	position.PlannedRR = models.PlannedRewardRisk(side, position.EntryPrice, stopLoss, takeProfit)
+	return s.PositionRepo.Save(ctx, &position)
+}
+
+// FlagEntrySlippage 记录开仓滑点超限的持仓，提示词和持仓接口据此提示复核执行质量
+func (s *PositionService) FlagEntrySlippage(ctx context.Context, symbol, side string, slippagePercent float64) error {
+	position, err := s.PositionRepo.FindActiveBySymbolAndSide(ctx, symbol, side)
+	if err != nil {
+		return err
+	}
+	position.EntrySlippagePercent = slippagePercent
+	return s.PositionRepo.Save(ctx, &position)
+}
+
+// SetDelistedStatus 标记或清除持仓的交易对下架状态，status 为空表示交易对已恢复正常交易
+func (s *PositionService) SetDelistedStatus(ctx context.Context, positionID, status string) error {
+	position, err := s.PositionRepo.FindById(ctx, positionID)
+	if err != nil {
+		return err
+	}
+	if position.DelistedStatus == status {
+		return nil
+	}
+
+	position.DelistedStatus = status
+	return s.PositionRepo.Save(ctx, &position)
+}
+
+// UpdateLeverage 更新持仓的杠杆、保证金与强平价（调整杠杆后立即生效，不必等待下一次同步）
+func (s *PositionService) UpdateLeverage(ctx context.Context, positionID string, leverage int, margin, liquidationPrice float64) error {
+	position, err := s.PositionRepo.FindById(ctx, positionID)
+	if err != nil {
+		return err
+	}
+
+	position.Leverage = leverage
+	position.Margin = margin
+	if liquidationPrice > 0 {
+		position.LiquidationPrice = liquidationPrice
+	}
+
+	return s.PositionRepo.Save(ctx, &position)
+}
+
 // StartSyncWorker 启动后台持仓同步worker
 func (s *PositionService) StartSyncWorker(ctx context.Context, interval time.Duration) {
 	s.stopChan = make(chan struct{})
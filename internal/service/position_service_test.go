@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/spf13/cast"
+)
+
+func TestSplitDuplicateOrders(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		{ID: "sl-old", OrderType: models.OrderTypeStopLoss, CreatedAt: base},
+		{ID: "tp", OrderType: models.OrderTypeTakeProfit, CreatedAt: base},
+		{ID: "sl-new", OrderType: models.OrderTypeStopLoss, CreatedAt: base.Add(time.Minute)},
+		{ID: "sl-mid", OrderType: models.OrderTypeStopLoss, CreatedAt: base.Add(30 * time.Second)},
+	}
+
+	kept, stale := splitDuplicateOrders(orders)
+
+	keptIDs := map[string]bool{}
+	for _, o := range kept {
+		keptIDs[o.ID] = true
+	}
+	if len(kept) != 2 || !keptIDs["sl-new"] || !keptIDs["tp"] {
+		t.Fatalf("unexpected kept orders: %+v", kept)
+	}
+	if len(stale) != 2 {
+		t.Fatalf("expected 2 stale orders, got %d", len(stale))
+	}
+	for _, o := range stale {
+		if o.ID != "sl-old" && o.ID != "sl-mid" {
+			t.Fatalf("unexpected stale order %s", o.ID)
+		}
+	}
+
+	if _, stale := splitDuplicateOrders(orders[:2]); len(stale) != 0 {
+		t.Fatalf("expected no stale orders, got %+v", stale)
+	}
+
+	// 分批止盈的不同档位不算重复
+	ladder := []models.Order{
+		{ID: "tp1", OrderType: models.OrderTypeTakeProfit, LadderLevel: 1, CreatedAt: base},
+		{ID: "tp2", OrderType: models.OrderTypeTakeProfit, LadderLevel: 2, CreatedAt: base.Add(time.Second)},
+	}
+	if _, stale := splitDuplicateOrders(ladder); len(stale) != 0 {
+		t.Fatalf("ladder levels should not be duplicates, got stale %+v", stale)
+	}
+}
+
+func TestPositionChangedTolerance(t *testing.T) {
+	before := &models.Position{Quantity: 0.5, EntryPrice: 95000, CurrentPrice: 96000, UnrealizedPnl: 500, Leverage: 10, Margin: 4750}
+
+	jitter := *before
+	jitter.CurrentPrice = 96000.5
+	if positionChanged(before, &jitter, 0.0001) {
+		t.Fatal("mark price jitter within tolerance should not count as a change")
+	}
+
+	moved := *before
+	moved.CurrentPrice = 96100
+	if !positionChanged(before, &moved, 0.0001) {
+		t.Fatal("price move beyond tolerance should count as a change")
+	}
+
+	releveraged := *before
+	releveraged.Leverage = 5
+	if !positionChanged(before, &releveraged, 0.0001) {
+		t.Fatal("leverage change should always count as a change")
+	}
+
+	if !positionChanged(before, &jitter, 0) {
+		t.Fatal("zero tolerance should detect any difference")
+	}
+}
+
+// 同步后的持仓保证金与纸钱包可用余额使用同一口径，名义价值按当前价格计算
+func TestSyncPositionsMarginMatchesPaperWallet(t *testing.T) {
+	ctx := context.Background()
+	agent, wallet := newTestAgent(t, 100)
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 4); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenShortPosition(ctx, "BTCUSDT", 2); err != nil {
+		t.Fatalf("open short: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+
+	positions, err := agent.positionService.GetAllPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	pos := positions[0]
+	if pos.Margin != 50 || pos.Notional() != 200 {
+		t.Fatalf("margin = %v, notional = %v, want 50 and 200", pos.Margin, pos.Notional())
+	}
+
+	info, err := wallet.GetAccountInfo(ctx)
+	if err != nil {
+		t.Fatalf("account info: %v", err)
+	}
+	if used := info.MarginBalance - info.AvailableBalance; used != pos.Margin {
+		t.Fatalf("wallet used margin = %v, position margin = %v", used, pos.Margin)
+	}
+}
+
+// ladderSyncExchange 在纸钱包之上模拟条件单成交：指定订单返回 FILLED 和对应的成交记录，其余订单保持 NEW
+type ladderSyncExchange struct {
+	*exchange.PaperWallet
+	filled    map[int64]*exchange.TradeHistory
+	cancelled map[int64]bool
+}
+
+func (e *ladderSyncExchange) GetOrderStatus(ctx context.Context, symbol string, orderID int64) (*exchange.OrderResult, error) {
+	status := "NEW"
+	if e.cancelled[orderID] {
+		status = "CANCELED"
+	} else if e.filled[orderID] != nil {
+		status = "FILLED"
+	}
+	return &exchange.OrderResult{OrderID: orderID, Symbol: symbol, Status: status}, nil
+}
+
+func (e *ladderSyncExchange) GetTradeHistory(ctx context.Context, symbol string, orderID int64, limit int) ([]*exchange.TradeHistory, error) {
+	if fill := e.filled[orderID]; fill != nil {
+		return []*exchange.TradeHistory{fill}, nil
+	}
+	return nil, nil
+}
+
+func (e *ladderSyncExchange) CancelOrder(ctx context.Context, symbol string, orderID int64) error {
+	e.cancelled[orderID] = true
+	return nil
+}
+
+func (e *ladderSyncExchange) FormatQuantity(ctx context.Context, symbol string, quantity float64) (float64, error) {
+	return math.Floor(quantity*1000+1e-9) / 1000, nil
+}
+
+func (e *ladderSyncExchange) FormatPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	return price, nil
+}
+
+// fill 模拟订单在交易所成交，并从纸钱包平掉对应数量
+func (e *ladderSyncExchange) fill(t *testing.T, order models.Order, price float64) {
+	t.Helper()
+	id := cast.ToInt64(order.ExchangeID)
+	e.filled[id] = &exchange.TradeHistory{OrderID: id, Symbol: order.Symbol, Price: price, Quantity: order.Quantity,
+		RealizedPnl: (price - 100) * order.Quantity, Time: time.Now().UnixMilli()}
+	if _, err := e.CloseLongPosition(context.Background(), order.Symbol, order.Quantity); err != nil {
+		t.Fatalf("close filled quantity: %v", err)
+	}
+}
+
+// newLadderPosition 开多1个BTC，设置止损95和分批止盈 ladder，返回交易所和按档位索引的活跃订单
+func newLadderPosition(t *testing.T, ladder takeProfitLadder) (*AgentService, *ladderSyncExchange) {
+	t.Helper()
+	agent, wallet := newTestAgent(t, 100)
+	ex := &ladderSyncExchange{PaperWallet: wallet, filled: map[int64]*exchange.TradeHistory{}, cancelled: map[int64]bool{}}
+	agent.exchange = ex
+	agent.positionService.exchange = ex
+	agent.positionService.SetLadderFillHandler(agent.ResizeStopAfterLadderFill)
+
+	ctx := context.Background()
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := agent.positionService.SyncPositions(ctx); err != nil {
+		t.Fatalf("sync positions: %v", err)
+	}
+	if err := agent.createStopLossOrder(ctx, "BTCUSDT", "long", 1, 95, agent.defaultStopExecution()); err != nil {
+		t.Fatalf("create stop loss: %v", err)
+	}
+	for _, level := range agent.createTakeProfitLadder(ctx, "BTCUSDT", "long", 1, ladder) {
+		if !level.Created {
+			t.Fatalf("take profit level not created: %+v", level)
+		}
+	}
+	if err := agent.positionService.UpdateStopPrices(ctx, "BTCUSDT", "long", 95, ladder.target()); err != nil {
+		t.Fatalf("update stop prices: %v", err)
+	}
+	return agent, ex
+}
+
+// ladderOrders 返回持仓的活跃止损单和按档位索引的活跃止盈单
+func ladderOrders(t *testing.T, agent *AgentService) (*models.Order, map[int]models.Order) {
+	t.Helper()
+	positions, err := agent.positionService.GetAllPositions(context.Background())
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	orders, err := agent.OrderRepo.FindActiveByPositionID(context.Background(), positions[0].ID)
+	if err != nil {
+		t.Fatalf("find orders: %v", err)
+	}
+	var stop *models.Order
+	levels := make(map[int]models.Order)
+	for i := range orders {
+		if orders[i].IsStopLoss() {
+			if stop != nil {
+				t.Fatalf("multiple active stops: %+v", orders)
+			}
+			stop = &orders[i]
+		} else if orders[i].LadderLevel > 0 {
+			levels[orders[i].LadderLevel] = orders[i]
+		}
+	}
+	return stop, levels
+}
+
+func closeTrades(t *testing.T, agent *AgentService) []models.Trade {
+	t.Helper()
+	trades, err := agent.TradeRepo.FindTradesSince(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("find trades: %v", err)
+	}
+	var closes []models.Trade
+	for _, trade := range trades {
+		if trade.Type == "close" {
+			closes = append(closes, trade)
+		}
+	}
+	return closes
+}
+
+// 第一档成交后持仓仍有剩余：不取消其他档位，止损按剩余数量重设，持仓止盈移到第二档；最后一档成交后按普通触发处理
+func TestSyncOrdersLadderFill(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newLadderPosition(t, takeProfitLadder{
+		{Price: 105, AllocationPercent: 50},
+		{Price: 110, AllocationPercent: 50},
+	})
+	_, levels := ladderOrders(t, agent)
+
+	ex.fill(t, levels[1], 105)
+	if err := agent.positionService.syncOrderStatus(ctx); err != nil {
+		t.Fatalf("sync order status: %v", err)
+	}
+
+	stop, remaining := ladderOrders(t, agent)
+	if stop == nil || stop.Quantity != 0.5 || stop.TriggerPrice != 95 {
+		t.Fatalf("stop after level 1 = %+v, want 0.5 @ 95", stop)
+	}
+	if tp2, ok := remaining[2]; !ok || len(remaining) != 1 || ex.cancelled[cast.ToInt64(tp2.ExchangeID)] {
+		t.Fatalf("level 2 should stay active, remaining = %+v", remaining)
+	}
+	if closes := closeTrades(t, agent); len(closes) != 1 || closes[0].Quantity != 0.5 || closes[0].ReasonCode != models.CloseReasonTakeProfit {
+		t.Fatalf("close trades after level 1 = %+v", closes)
+	}
+	positions, _ := agent.positionService.GetAllPositions(ctx)
+	if positions[0].TakeProfit != 110 || positions[0].StopLoss != 95 {
+		t.Fatalf("position stops = %v / %v, want 95 / 110", positions[0].StopLoss, positions[0].TakeProfit)
+	}
+
+	// 最后一档成交、持仓已平：取消剩余止损，不再调整
+	ex.fill(t, remaining[2], 110)
+	if err := agent.positionService.syncOrderStatus(ctx); err != nil {
+		t.Fatalf("sync order status: %v", err)
+	}
+	if !ex.cancelled[cast.ToInt64(stop.ExchangeID)] {
+		t.Fatal("resized stop should be cancelled after the last level fills")
+	}
+	orders, err := agent.OrderRepo.FindAllActive(ctx)
+	if err != nil || len(orders) != 0 {
+		t.Fatalf("active orders after last level = %+v, err = %v", orders, err)
+	}
+	if closes := closeTrades(t, agent); len(closes) != 2 {
+		t.Fatalf("close trades after last level = %+v", closes)
+	}
+}
+
+// 同一轮两档同时成交，止损按扣除两档后的剩余数量设置
+func TestSyncOrdersLadderFillsInOneTick(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newLadderPosition(t, takeProfitLadder{
+		{Price: 105, AllocationPercent: 40},
+		{Price: 110, AllocationPercent: 30},
+		{Price: 115, AllocationPercent: 30},
+	})
+	_, levels := ladderOrders(t, agent)
+
+	ex.fill(t, levels[1], 105)
+	ex.fill(t, levels[2], 110)
+	if err := agent.positionService.syncOrderStatus(ctx); err != nil {
+		t.Fatalf("sync order status: %v", err)
+	}
+
+	stop, remaining := ladderOrders(t, agent)
+	if stop == nil || math.Abs(stop.Quantity-0.3) > 1e-9 {
+		t.Fatalf("stop after two levels = %+v, want quantity 0.3", stop)
+	}
+	if _, ok := remaining[3]; !ok || len(remaining) != 1 {
+		t.Fatalf("only level 3 should stay active, remaining = %+v", remaining)
+	}
+	positions, _ := agent.positionService.GetAllPositions(ctx)
+	if positions[0].TakeProfit != 115 {
+		t.Fatalf("position take profit = %v, want 115", positions[0].TakeProfit)
+	}
+	if closes := closeTrades(t, agent); len(closes) != 2 {
+		t.Fatalf("close trades = %+v, want both levels recorded", closes)
+	}
+}
+
+// 止损与某档止盈同一轮成交：两笔成交都记录，剩余档位取消，不再调整止损
+func TestSyncOrdersStopAndLadderFillTogether(t *testing.T) {
+	ctx := context.Background()
+	agent, ex := newLadderPosition(t, takeProfitLadder{
+		{Price: 105, AllocationPercent: 50},
+		{Price: 110, AllocationPercent: 50},
+	})
+	stop, levels := ladderOrders(t, agent)
+
+	ex.fill(t, levels[1], 105)
+	half := *stop
+	half.Quantity = 0.5
+	ex.fill(t, half, 95)
+	if err := agent.positionService.syncOrderStatus(ctx); err != nil {
+		t.Fatalf("sync order status: %v", err)
+	}
+
+	if !ex.cancelled[cast.ToInt64(levels[2].ExchangeID)] {
+		t.Fatal("remaining level should be cancelled when the stop fills")
+	}
+	if ex.cancelled[cast.ToInt64(stop.ExchangeID)] || ex.cancelled[cast.ToInt64(levels[1].ExchangeID)] {
+		t.Fatal("filled orders must not be cancelled")
+	}
+	orders, err := agent.OrderRepo.FindAllActive(ctx)
+	if err != nil || len(orders) != 0 {
+		t.Fatalf("active orders = %+v, err = %v", orders, err)
+	}
+	closes := closeTrades(t, agent)
+	if len(closes) != 2 {
+		t.Fatalf("close trades = %+v, want stop and level 1", closes)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"github.com/dushixiang/prism/internal/config"
+)
+
+const (
+	minConfidence = 1
+	maxConfidence = 10
+)
+
+// openPosition 的 quantity 参数含义，由 trading.sizing_mode 配置
+const (
+	SizingModeMargin        = "margin"         // 保证金（USDT），默认
+	SizingModeNotional      = "notional"       // 名义价值（USDT）
+	SizingModePercentEquity = "percent_equity" // 用作保证金的可用余额百分比
+)
+
+// normalizeSizingMode 规范化仓位计价方式，未知值按保证金处理
+func normalizeSizingMode(mode string) string {
+	switch mode {
+	case SizingModeNotional, SizingModePercentEquity:
+		return mode
+	default:
+		return SizingModeMargin
+	}
+}
+
+// sizingModeQuantityKey 计价方式对应的 quantity 参数说明
+func sizingModeQuantityKey(mode string) string {
+	switch normalizeSizingMode(mode) {
+	case SizingModeNotional:
+		return "tool.openPosition.quantity.notional"
+	case SizingModePercentEquity:
+		return "tool.openPosition.quantity.equity"
+	default:
+		return "tool.openPosition.quantity"
+	}
+}
+
+// marginFromQuantity 按计价方式将 quantity 换算为保证金（USDT），无法换算时返回0交由开仓规则拒绝
+func marginFromQuantity(mode string, quantity float64, leverage int, availableBalance float64) float64 {
+	switch normalizeSizingMode(mode) {
+	case SizingModeNotional:
+		if leverage <= 0 {
+			return 0
+		}
+		return quantity / float64(leverage)
+	case SizingModePercentEquity:
+		if availableBalance <= 0 {
+			return 0
+		}
+		return availableBalance * quantity / 100
+	default:
+		return quantity
+	}
+}
+
+// autoLeverageResult 自动杠杆计算结果
+type autoLeverageResult struct {
+	RequestedLeverage int     `json:"requested_leverage"`   // AI请求的杠杆
+	Leverage          int     `json:"leverage"`             // 系统选定的杠杆
+	RequestedMargin   float64 `json:"requested_margin"`     // AI请求的保证金
+	Margin            float64 `json:"margin"`               // 调整后的保证金
+	TargetRiskPercent float64 `json:"target_risk_percent"`  // 目标单笔风险（占净值%）
+	RiskPercent       float64 `json:"implied_risk_percent"` // 实际单笔风险（占净值%）
+	RiskAmount        float64 `json:"implied_risk_amount"`  // 实际单笔风险金额（USDT）
+}
+
+// stopDistancePercent 止损距离占入场价的比例（0-1）
+func stopDistancePercent(price, stopLossPrice float64) float64 {
+	if price <= 0 || stopLossPrice <= 0 {
+		return 0
+	}
+	return math.Abs(price-stopLossPrice) / price
+}
+
+// impliedRiskPercent 止损触发时的亏损占净值百分比
+func impliedRiskPercent(req *openRequest) float64 {
+	if req.TotalBalance <= 0 {
+		return 0
+	}
+	return req.Notional() * stopDistancePercent(req.Price, req.StopLossPrice) / req.TotalBalance * 100
+}
+
+// applyAutoLeverage 根据目标单笔风险和止损距离计算杠杆，覆盖AI选择的杠杆
+//
+// 目标名义价值 = 净值 × 风险% / 止损距离，杠杆 = 目标名义价值 / 保证金（向下取整并限制在允许范围内）。
+// 当杠杆已处于下限仍超出目标风险时，缩减保证金以满足目标风险。
+// 缺少净值或止损信息时不做调整，返回 nil。
+func applyAutoLeverage(req *openRequest, targetRiskPercent float64) *autoLeverageResult {
+	distance := stopDistancePercent(req.Price, req.StopLossPrice)
+	if targetRiskPercent <= 0 || distance <= 0 || req.TotalBalance <= 0 || req.Margin <= 0 {
+		return nil
+	}
+
+	result := &autoLeverageResult{
+		RequestedLeverage: req.Leverage,
+		RequestedMargin:   req.Margin,
+		TargetRiskPercent: targetRiskPercent,
+	}
+
+	targetNotional := req.TotalBalance * targetRiskPercent / 100 / distance
+
+	leverage := int(math.Floor(targetNotional / req.Margin))
+	if leverage > req.MaxLeverage {
+		leverage = req.MaxLeverage
+	}
+	if leverage < req.MinLeverage {
+		leverage = req.MinLeverage
+		// 最低杠杆下风险仍超出目标，缩减保证金
+		if req.Margin*float64(leverage) > targetNotional {
+			req.Margin = targetNotional / float64(leverage)
+		}
+	}
+	req.Leverage = leverage
+
+	result.Leverage = req.Leverage
+	result.Margin = req.Margin
+	result.RiskPercent = impliedRiskPercent(req)
+	result.RiskAmount = req.TotalBalance * result.RiskPercent / 100
+	return result
+}
+
+// confidenceSizeMultiplier 按开仓信心返回保证金缩放系数
+//
+// 档位按 MaxConfidence 升序匹配第一个 信心 <= MaxConfidence 的档位，系数限制在 (0, 1]，
+// 信心高于所有档位或未记录信心时不缩减。
+func confidenceSizeMultiplier(confidence int, tiers []config.ConfidenceSizeTier) float64 {
+	if confidence <= 0 || len(tiers) == 0 {
+		return 1
+	}
+
+	sorted := make([]config.ConfidenceSizeTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MaxConfidence < sorted[j].MaxConfidence
+	})
+
+	for _, tier := range sorted {
+		if confidence > tier.MaxConfidence {
+			continue
+		}
+		if tier.SizeMultiplier <= 0 || tier.SizeMultiplier > 1 {
+			return 1
+		}
+		return tier.SizeMultiplier
+	}
+	return 1
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/config"
+)
+
+func TestApplyAutoLeverage(t *testing.T) {
+	tests := []struct {
+		name         string
+		margin       float64
+		stopLoss     float64
+		wantLeverage int
+		wantMargin   float64
+	}{
+		// 净值1000，风险2% => 风险20U；止损2% => 目标名义价值1000U；保证金100 => 10x
+		{"within range", 100, 98000, 10, 100},
+		// 止损1% => 目标名义价值2000U；保证金100 => 20x，限制到上限10x
+		{"clamped to max", 100, 99000, 10, 100},
+		// 止损10% => 目标名义价值200U；保证金100 => 2x，低于下限3x，缩减保证金至 200/3
+		{"clamped to min shrinks margin", 100, 90000, 3, 200.0 / 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validOpenRequest()
+			req.TotalBalance = 1000
+			req.Margin = tt.margin
+			req.StopLossPrice = tt.stopLoss
+
+			result := applyAutoLeverage(req, 2)
+			if result == nil {
+				t.Fatal("expected auto leverage result")
+			}
+			if req.Leverage != tt.wantLeverage || result.Leverage != tt.wantLeverage {
+				t.Fatalf("leverage = %d, want %d", req.Leverage, tt.wantLeverage)
+			}
+			if math.Abs(req.Margin-tt.wantMargin) > 1e-9 {
+				t.Fatalf("margin = %.4f, want %.4f", req.Margin, tt.wantMargin)
+			}
+			if result.RiskPercent > 2+1e-9 {
+				t.Fatalf("implied risk %.4f%% exceeds target", result.RiskPercent)
+			}
+		})
+	}
+
+	req := validOpenRequest()
+	req.TotalBalance = 0
+	if applyAutoLeverage(req, 2) != nil {
+		t.Fatal("expected nil result when balance unknown")
+	}
+}
+
+func TestConfidenceSizeMultiplier(t *testing.T) {
+	// 故意乱序，验证按 MaxConfidence 升序匹配
+	tiers := []config.ConfidenceSizeTier{
+		{MaxConfidence: 6, SizeMultiplier: 0.75},
+		{MaxConfidence: 4, SizeMultiplier: 0.5},
+	}
+
+	tests := []struct {
+		confidence int
+		want       float64
+	}{
+		{0, 1},
+		{1, 0.5},
+		{4, 0.5},
+		{5, 0.75},
+		{6, 0.75},
+		{7, 1},
+		{10, 1},
+	}
+	for _, tt := range tests {
+		if got := confidenceSizeMultiplier(tt.confidence, tiers); got != tt.want {
+			t.Errorf("confidence %d: multiplier = %v, want %v", tt.confidence, got, tt.want)
+		}
+	}
+
+	if got := confidenceSizeMultiplier(3, nil); got != 1 {
+		t.Errorf("no tiers: multiplier = %v, want 1", got)
+	}
+}
+
+func TestMarginFromQuantity(t *testing.T) {
+	tests := []struct {
+		mode      string
+		quantity  float64
+		leverage  int
+		available float64
+		want      float64
+	}{
+		{SizingModeMargin, 100, 10, 1000, 100},
+		{"", 100, 10, 1000, 100},
+		{"unknown", 100, 10, 1000, 100},
+		{SizingModeNotional, 1000, 10, 1000, 100},
+		{SizingModeNotional, 1000, 0, 1000, 0},
+		{SizingModePercentEquity, 20, 10, 1000, 200},
+		{SizingModePercentEquity, 20, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := marginFromQuantity(tt.mode, tt.quantity, tt.leverage, tt.available); got != tt.want {
+			t.Errorf("mode %q quantity %v: margin = %v, want %v", tt.mode, tt.quantity, got, tt.want)
+		}
+	}
+}
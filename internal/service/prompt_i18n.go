@@ -0,0 +1,560 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 提示词语言
+const (
+	PromptLanguageZh = "zh"
+	PromptLanguageEn = "en"
+)
+
+// normalizePromptLanguage 规范化提示词语言配置，未配置或不支持的语言回退为中文
+func normalizePromptLanguage(lang string) string {
+	switch strings.ToLower(strings.TrimSpace(lang)) {
+	case PromptLanguageEn, "english":
+		return PromptLanguageEn
+	default:
+		return PromptLanguageZh
+	}
+}
+
+// localize 返回指定语言的文本，目标语言缺失该文本时回退中文
+func localize(lang, key string) string {
+	if text, ok := promptMessages[normalizePromptLanguage(lang)][key]; ok {
+		return text
+	}
+	if text, ok := promptMessages[PromptLanguageZh][key]; ok {
+		return text
+	}
+	return key
+}
+
+// localizef 格式化指定语言的文本
+func localizef(lang, key string, args ...interface{}) string {
+	return fmt.Sprintf(localize(lang, key), args...)
+}
+
+// localizeError 返回指定语言的错误信息
+func localizeError(lang, key string, args ...interface{}) error {
+	if len(args) == 0 {
+		return errors.New(localize(lang, key))
+	}
+	return fmt.Errorf(localize(lang, key), args...)
+}
+
+// promptMessages 提示词段落、工具描述和校验信息的多语言文本
+// 同一个 key 在各语言中的格式化占位符必须保持一致（顺序和类型）
+var promptMessages = map[string]map[string]string{
+	PromptLanguageZh: {
+		// 提示词
+		"context.header":           "**时间**: %s | **周期**: #%d | **运行**: %.0f分钟\n\n",
+		"context.close_only":       "⛔ **只平仓模式**：系统禁止新开仓（openPosition 会被拒绝），本周期只管理现有持仓：按计划调整止损止盈或平仓\n\n",
+		"market.title":             "## 市场全景\n\n",
+		"market.empty":             "暂无可用的市场数据。\n\n",
+		"market.omitted":           "以下按相关度从高到低排列；另有 %d 个交易对因展示数量限制未列出（%s），本周期不要对它们开仓\n\n",
+		"market.price_funding":     "💰 $%s | 📊 资金费率 %.4f%%\n",
+		"market.high_low":          "**24h高低点**: $%s / $%s\n",
+		"market.regime":            "**市场状态** (1h): %s（ADX %.1f）\n",
+		"market.regime_blocked":    "⚠️ 非趋势行情，系统禁止新开仓；确需开仓时必须在 regime_override_reason 中说明理由\n",
+		"regime.trending":          "趋势",
+		"regime.ranging":           "震荡",
+		"regime.uncertain":         "不确定",
+		"breakout.confirmed":       "**突破确认** (%s): 向%s突破 $%s，已连续 %d 根已收盘K线收在区间外（需 %d 根），突破已确认\n",
+		"breakout.unconfirmed":     "**突破确认** (%s): 向%s突破 $%s，仅 %d 根已收盘K线收在区间外（需 %d 根），尚未确认，可能是单根K线噪音\n",
+		"breakout.blocked":         "⚠️ 突破尚未确认，系统禁止顺突破方向开仓\n",
+		"volatility.spike":         "⚠️ **波动异常放大** (%s): ATR%d 为 ATR%d 的 %.2f 倍（阈值 %.2f 倍），可能处于消息行情或连环爆仓，常规距离的止损容易被击穿\n",
+		"volatility.block":         "⚠️ 波动异常放大期间系统禁止新开仓\n",
+		"volatility.reduce":        "⚠️ 波动异常放大期间新开仓的保证金会被系统缩减\n",
+		"volatility.widen":         "⚠️ 波动异常放大期间新开仓的止损必须留出足够的短周期ATR距离，否则会被拒绝\n",
+		"breakout.up":              "上",
+		"breakout.down":            "下",
+		"htf_trend.up":             "上升",
+		"htf_trend.down":           "下降",
+		"htf_trend.none":           "无明确",
+		"market.key_levels":        "**关键支撑/阻力** (1h枢轴聚类):\n",
+		"market.resistance":        "- 阻力 $%s | 距离 %+.2f%%%s | 触及%d次\n",
+		"market.support":           "- 支撑 $%s | 距离 %+.2f%%%s | 触及%d次\n",
+		"market.level_atr":         " / %+.1f ATR",
+		"market.timeframes":        "**多周期指标**\n",
+		"market.ema_deviation":     " 偏离EMA%d %+.2f%%",
+		"market.volume_ratio":      " (%.2fx均值)",
+		"market.tf_price":          "  - 价格: $%s%s\n",
+		"market.tf_ema":            "  - 均线: EMA%d=$%s / EMA%d=$%s\n",
+		"market.tf_bbands":         "  - 布林带: U=$%s M=$%s L=$%s\n",
+		"market.tf_indicators":     "  - 指标: MACD=%s | RSI%d=%.1f | ATR%d=%s\n",
+		"market.tf_volume":         "  - 成交量: %s (均值: %s)%s\n",
+		"market.intraday":          "**价格走势 (15m周期, %.1f小时)**: ",
+		"market.intraday_range":    "起 %s → 终 %s (%+.2f%%) | 区间 [%s-%s] 波幅%.2f%%\n",
+		"market.recent_closes":     "- 近期收盘价(最近%d根): %s\n",
+		"market.h1_title":          "**1小时趋势**\n",
+		"market.ema_above":         "EMA%d 在 EMA%d 上方",
+		"market.ema_below":         "EMA%d 在 EMA%d 下方",
+		"market.ema_near":          "EMA%d 与 EMA%d 接近",
+		"market.h1_ema_relation":   "- **1h 均线关系**: %s | **ADX%d**: %.1f",
+		"market.h1_ema_strength":   "- **均线偏离度**: %.2f%% (EMA%d vs EMA%d)\n",
+		"market.current_volume":    "当前成交量",
+		"market.avg_volume":        "均值",
+		"market.status_above":      "高于",
+		"market.status_below":      "低于",
+		"market.status_equal":      "等于",
+		"market.volatility":        "- 波动与成交量: %s | %s\n",
+		"market.macd_series":       "- MACD序列: ",
+		"market.rsi_series":        "- RSI%d序列: ",
+		"account.title":            "## 账户状态\n\n",
+		"account.empty":            "暂无账户数据。\n\n",
+		"account.funds":            "**资金**: 净值 $%.2f (初始$%.2f, 峰值$%.2f) | 可用 $%.2f (%.1f%%)\n",
+		"account.capital":          "**资金使用率**: 持仓已用保证金 $%.2f，占净值 %.1f%%\n",
+		"account.returns":          "**收益**: %s %+.2f%% | 未实现盈亏 $%+.2f | 累计资金费 $%+.2f\n",
+		"account.stop_risk":        "**止损风险**: 全部持仓触及止损合计亏损 $%.2f（占净值 %.2f%%）%s\n",
+		"account.unprotected":      " | ⚠️ %d 个持仓未设置止损，风险未计入",
+		"account.forced_flat":      " | 已达到强制清仓阈值%s%%（系统规则）",
+		"account.drawdown_warn":    " | 已达到警戒线%s%%（系统规则）",
+		"account.risk":             "**风险**: %s 回撤 %.2f%%(峰值) / %.2f%%(初始) | %s 夏普比率 %s%s\n\n",
+		"position.title":           "## 当前持仓\n\n",
+		"position.count":           "**持仓: %d/%d**\n\n",
+		"position.empty":           "当前无持仓，最多可开 %d 个仓位\n\n",
+		"position.price":           "- 价格: 入场$%s → 当前$%s\n",
+		"position.pnl":             "- 盈亏: $%+.2f (%+.2f%%)",
+		"position.peak_pnl":        " | 峰值盈亏 %+.2f%%",
+		"position.leverage":        "- 杠杆: %dx | 保证金: $%.2f | 名义价值: $%.2f | 数量: %.4f\n",
+		"position.liquidation":     "- 强平价格: $%s (距当前价格 %+.2f%%)\n",
+		"position.stop_risk":       "- 止损: $%s | 触发时亏损 $%.2f（占净值 %.2f%%）\n",
+		"position.no_stop":         "- ⚠️ 未设置止损\n",
+		"position.funding":         "- 预估下次资金费: $%+.4f (费率 %.4f%%)\n",
+		"position.accrued_funding": "- 开仓以来累计资金费: $%+.2f（支出占保证金 %.2f%%）\n",
+		"position.funding_limit":   "⚠️ **资金费侵蚀**：累计资金费支出已占保证金 %.2f%%，超过上限 %.2f%%，除非趋势仍明确支持持有，否则应平仓\n",
+		"position.holding":         "- 持仓时间: %s",
+		"position.deadline":        " | 距强制平仓: %s（最长持仓%d小时）",
+		"position.expected_value":  "- 期望值(粗估): %+.2f USDT | 止盈剩余 +$%.2f（%.1f ATR，触及概率 %.0f%%）| 止损剩余 -$%.2f（%.1f ATR，触及概率 %.0f%%）| 剩余盈亏比 %.2f | 窗口 %d 小时。按1小时ATR距离和随机游走估算，剩余盈亏比差时考虑减仓而不是锚定开仓价\n",
+		"position.health":          "- 健康分: %.0f/100（强平距离 %.0f | 止损距离 %.0f | 剩余时间 %.0f | 盈利保持 %.0f | 资金费 %.0f），需要减仓时优先处理健康分最低的持仓\n",
+		"position.entry_reason":    "**开仓理由**: %s\n\n",
+		"position.exit_plan":       "**退出计划**: %s\n\n",
+		"position.invalidation":    "**论点失效价**: $%s (距当前价格 %+.2f%%)\n\n",
+		"position.invalidated":     "⚠️ **论点已失效，应考虑离场**：当前价格已越过开仓时承诺的失效价格\n\n",
+		"position.entry_slippage":  "⚠️ 开仓成交滑点 %.3f%% 超过上限，实际开仓价不如预期，请按实际开仓价复核止损和盈亏比\n",
+		"position.frozen":          "🚫 **仓位已冻结**：交易对状态为 %s（下架或暂停交易），系统未能自动平仓，无法获取行情或下单。不要对该交易对调用任何工具，等待人工在交易所处理，行情数据与盈亏可能已过时\n",
+		"capacity.title":           "## 仓位容量\n\n",
+		"capacity.slots":           "**剩余可开仓位**: %d个（最大%d个）\n",
+		"capacity.available":       "**当前可用余额**: $%.2f\n",
+		"capacity.open_window":     "**滚动%d小时开仓次数**: %d/%d%s\n\n",
+		"capacity.window_full":     "（已达上限，约 %s 后恢复，期间开仓会被拒绝）",
+		"capacity.leverage_caps":   "**交易对杠杆上限**: %s（其余交易对最大 %dx），开仓和调整杠杆超出上限会被拒绝\n\n",
+		"orders.title":             "## 活跃限价单\n\n",
+		"orders.empty":             "当前无活跃限价单\n\n",
+		"orders.position":          "### 持仓#%d %s %s\n",
+		"orders.stop_loss":         "- **止损**: $%s (距当前价格 %+.2f%%) | 创建于 %s",
+		"orders.take_profit":       "- **止盈**: $%s (距当前价格 %+.2f%%) | 创建于 %s",
+		"orders.reason":            " | 原因: %s",
+		"trades.title":             "## 历史交易记录（最近%d笔）\n\n",
+		"trades.empty":             "暂无交易记录\n\n",
+		"trades.stats":             "**统计**: 胜率 %.0f%% (%d胜/%d负) | 净盈亏 $%.2f | 累计手续费 $%.2f\n\n",
+		"trades.item":              "%d. [%s] %s %s, 价格=$%s, 数量=%.4f, 杠杆=%dx, 手续费=$%.2f",
+		"trades.pnl":               ", 盈亏=%s$%.2f",
+		"trades.reason":            ", 原因: %s",
+		"trades.entry_reason":      "   - 开仓理由: %s\n",
+		"trades.exit_plan":         "   - 退出计划: %s\n",
+
+		// 工具描述
+		"tool.openPosition":                    "开仓交易（做多或做空）。开仓后将自动在交易所创建止损单作为最后防线。",
+		"tool.openPosition.symbol":             "交易对，如 BTCUSDT",
+		"tool.openPosition.side":               "方向：long（做多）或 short（做空）",
+		"tool.openPosition.leverage":           "杠杆倍数（3-15），必须根据信号强度选择",
+		"tool.openPosition.quantity":           "保证金金额（USDT）。注意：实际开仓的名义价值 = 保证金 × 杠杆。例如用100 USDT保证金，10倍杠杆，实际开仓价值1000 USDT。名义价值必须满足交易对的最小名义价值要求",
+		"tool.openPosition.quantity.notional":  "开仓名义价值（USDT，不是保证金）。系统按 保证金 = 名义价值 / 杠杆 计算保证金，例如填1000表示开1000 USDT价值的币，10倍杠杆时占用100 USDT保证金。名义价值必须满足交易对的最小名义价值要求",
+		"tool.openPosition.quantity.equity":    "用作保证金的可用余额百分比（0-100]，例如填20表示使用可用余额的20%作为保证金，名义价值 = 保证金 × 杠杆。换算后的名义价值必须满足交易对的最小名义价值要求",
+		"tool.openPosition.stop_loss_price":    "【与 stop_loss_percent 二选一】止损价格。开仓后会立即在交易所创建止损单。做多时必须低于当前价，做空时必须高于当前价。建议：根据ATR、关键支撑阻力位或风险承受度设置，通常为入场价的3-5%（考虑杠杆后的账户风险）。",
+		"tool.openPosition.take_profit_price":  "【可选】止盈价格。如果设置，开仓后会在交易所创建止盈单。做多时必须高于当前价，做空时必须低于当前价。建议：基于关键阻力位或风险回报比设置（如2:1或3:1）。不设置则由AI动态管理。",
+		"tool.openPosition.stop_percent":       "【与 stop_loss_price 二选一】止损距开仓价的百分比（价格距离，不含杠杆），如 2 表示做多止损在成交价下方2%、做空在上方2%。由系统按实际成交价和方向计算止损价格，不会放错方向，推荐使用。不能与 stop_loss_price 同时设置。",
+		"tool.openPosition.target_percent":     "【可选，与 take_profit_price 二选一】止盈距开仓价的百分比（价格距离，不含杠杆），如 6 表示做多止盈在成交价上方6%、做空在下方6%。由系统按实际成交价和方向计算止盈价格。不能与 take_profit_price 同时设置。",
+		"tool.openPosition.take_profit_levels": "【可选】分批止盈，最多5档，不能与 take_profit_price / take_profit_percent 同时设置。每档给出 price 或 percent 之一和 allocation_percent（平掉开仓数量的比例），如 [{percent:3, allocation_percent:50}, {percent:6, allocation_percent:30}] 表示3%处平一半、6%处平30%，剩余20%不设止盈作为跟踪仓位。某档成交后系统自动把止损调整为剩余数量。",
+		"tool.openPosition.level_price":        "该档止盈价格，与 percent 二选一",
+		"tool.openPosition.level_percent":      "该档止盈距开仓价的百分比（价格距离，不含杠杆），按实际成交价计算，与 price 二选一",
+		"tool.openPosition.level_allocation":   "该档平掉的仓位占开仓数量的比例（%），各档合计不超过100",
+		"tool.openPosition.reason":             "开仓理由，说明信号来源和时间框架共振情况，需提及具体时间框架（如 1h、4h）和具体信号（如突破、均线、RSI），过于简单会被拒绝",
+		"tool.openPosition.exit_plan":          "详细的退出计划，必须明确包含以下至少一种条件：1)止损条件（价格/百分比/指标）；2)止盈条件（目标价/阻力位）；3)结构破坏条件；4)时间条件。必须写明具体止损价位。平仓时的 reason 必须明确对应这些条件之一。",
+		"tool.openPosition.regime_override":    "【可选】在震荡或不确定行情中开仓的理由。系统开启只做趋势行情时，非趋势行情的开仓必须提供该理由，否则会被拒绝；请说明为何该交易不依赖趋势（如区间边界反转且有明确止损）。",
+		"tool.openPosition.counter_trend":      "【可选】逆1小时强趋势开仓（如1h强上升趋势中做空）的理由。系统要求逆趋势开仓给出理由时，缺少该理由会被拒绝；请说明反转依据和为何止损足以应对趋势延续。",
+		"tool.openPosition.confidence":         "【必填】开仓信心（1-10的整数），反映信号强度与时间框架共振程度。低信心交易会按系统配置缩减保证金，统计中会按信心分组展示胜率，请如实评估。",
+		"tool.openPosition.invalidation_price": "【必填】论点失效价格：价格到达该位置即说明开仓逻辑不再成立（如关键结构被跌破/突破），区别于保护性止损。做多时必须低于当前价，做空时必须高于当前价。价格越过该位置后，持仓信息中会提示论点已失效。",
+		"tool.closePosition":                   "平仓指定持仓。重要：平仓理由必须严格对应该仓位开仓时设置的退出计划(exit_plan)中的具体条件，不能随意平仓。",
+		"tool.closePosition.symbol":            "交易对",
+		"tool.closePosition.execution":         "执行方式：immediate（默认，一笔市价单全部平仓）或 twap（拆成 %d 笔市价子订单在 %d 秒内均匀平仓，降低大仓位的市场冲击；仓位期间被止损等订单平掉时自动停止）。紧急止损请用 immediate。",
+		"tool.closePosition.reason":            "平仓理由。必须明确说明触发了该仓位退出计划中的哪个具体条件（如止损、止盈、结构破坏等）。理由必须包含退出计划中的关键要素（价格、指标、条件等）。示例：\"触发止损，价格跌破 $95,000\" 或 \"达到目标价 $105,000，突破阻力位\" 或 \"市场结构破坏，跌破上升趋势线\"。不能使用模糊或无关的理由。",
+		"tool.closePosition.reason_code":       "平仓原因分类：stop_loss（止损）、take_profit（止盈）、structure_break（结构破坏）、time_exit（时间退出）、thesis_invalidated（论点失效）、risk_management（风险管理）、other（其他）。必须与 reason 中说明的条件一致，用于按退出类型统计盈亏。",
+		"tool.closeAllPositions":               "一次性市价平掉全部持仓并取消各自的止损止盈单，用于行情急剧恶化、需要立即清仓避险的场景。正常退出请逐个使用 closePosition。",
+		"tool.closeAllPositions.reason":        "清仓理由，说明为什么需要同时平掉所有持仓（如：大盘急跌，BTC 1h 跌破关键支撑 $90,000，全部持仓风险暴露过大）。不能使用模糊或无关的理由。",
+		"tool.closeAllPositions.reason_code":   "平仓原因分类，默认 risk_management（风险管理），取值同 closePosition。",
+		"tool.updateStopOrders":                "更新持仓的止损止盈单。用于移动止损保护利润、调整止盈目标等。会取消旧的止损止盈单并创建新的。",
+		"tool.updateStopOrders.symbol":         "交易对",
+		"tool.updateStopOrders.stop_loss":      "【可选】新的止损价格。如果不提供则保持原止损不变。常见场景：持仓盈利后移动止损到盈亏平衡点或更高位置保护利润。",
+		"tool.updateStopOrders.take_profit":    "【可选】新的止盈价格。如果不提供则保持原止盈不变（或取消止盈单让AI灵活管理）。设为0表示取消止盈单。持仓设置了分批止盈时，传入新止盈会取消全部剩余档位，改为按剩余全部数量设置单一止盈；设为0取消全部档位；只调整止损时各档止盈保持不变。",
+		"tool.stop_type":                       "【可选】止损单触发后的执行方式：market 市价成交（默认，成交确定，但流动性差时可能远离触发价成交）；limit 按限价挂单（滑点可控，但价格快速越过限价时可能不成交，持仓失去保护）。流动性较差的品种可考虑 limit。不提供时沿用当前止损单或系统默认设置。",
+		"tool.stop_limit_offset":               "【可选】限价止损的限价相对触发价向不利方向的偏移百分比（如0.5表示做多止损限价为触发价的99.5%），仅 stop_type=limit 时生效，不提供时使用系统默认值。",
+		"tool.updateStopOrders.reason":         "更新理由。说明为什么要调整止损止盈（如：持仓盈利5%，移动止损至盈亏平衡点；市场环境变化，调高止盈目标等）。",
+		"tool.adjustLeverage":                  "调整已有持仓的杠杆倍数，不平仓。降低杠杆会占用更多保证金、降低强平风险，用于行情波动加大时为持仓降风险；保证金不足时调整会被拒绝。",
+		"tool.adjustLeverage.symbol":           "交易对",
+		"tool.adjustLeverage.leverage":         "新的杠杆倍数，必须在系统允许的杠杆范围内",
+		"tool.adjustLeverage.reason":           "调整理由，说明为什么要改变杠杆（如：波动率上升，降低杠杆减少强平风险）",
+		"tool.getRecentDecisions":              "查询最近几次决策的摘要（迭代编号、执行的操作、分析理由）。用于回顾之前的交易论点，保持决策连贯，避免反复开平仓。",
+		"tool.getRecentDecisions.limit":        "返回的决策数量，默认3，最多10",
+		"tool.getKlines":                       "按需查询指定交易对和周期的历史K线，返回区间OHLCV汇总、关键指标（EMA/RSI/MACD/ATR/ADX/布林带）和最近几根K线。默认快照不足以判断时使用，例如在开仓前查看日线结构。",
+		"tool.getKlines.symbol":                "交易对，例如 BTCUSDT",
+		"tool.getKlines.interval":              "K线周期",
+		"tool.getKlines.limit":                 "K线数量，默认100，最少50，最多200",
+		"tool.getAccountRisk":                  "查询实时的账户整体风险：净值、可用余额、名义价值合计、总杠杆、合计止损风险、持仓数与上限、当前回撤。同一次决策中开仓后再开下一个仓位前使用，按最新状态而不是决策前的快照确定仓位大小。",
+		"tool.result":                          "返回JSON信封 {schema_version: %d, success, action, message, data, error: {message}}，success 为 false 时原因见 error.message。",
+		"result.openPosition":                  "data 字段：order_id、symbol、side、price（成交均价）、quantity（成交数量）、ordered_quantity（下单数量）、partial_fill（部分成交时为 true，止损止盈按成交数量设置）、leverage、stop_loss_price、stop_type、take_profit_price、invalidation_price、confidence、size_multiplier、sizing_mode、requested_quantity、stop_loss_order_id、take_profit_order_id、implied_risk_percent、auto_leverage（仅自动杠杆时）、htf_trend（开启逆趋势检查时的1小时趋势状态）、expected_price（下单前价格）、slippage_percent（成交滑点%，正数为不利）、stop_adjusted（滑点超限后止损已按成交价平移）、stop_loss_percent / take_profit_percent（按百分比设置止损止盈时，价格按成交价计算）、stop_reset（止损被滑点越过，已从成交价按ATR重设）、take_profit_dropped（止盈被成交价越过，未创建止盈单）、take_profit_levels（分批止盈时每档的 level、price、quantity、allocation_percent、created）、volatility_spike（开启波动检查时的1小时ATR放大情况）、volatility_margin（波动异常放大时保证金缩减到的比例）；被开仓规则拒绝时 data 为 symbol、side、rejected_rules、rule_results、htf_trend、volatility_spike。",
+		"result.closePosition":                 "data 字段：order_id、symbol、pnl、reason、reason_code、already_flat（交易所已无该持仓、只清理了遗留订单时为 true）；按 TWAP 执行时另有 execution、quantity（已平数量）、price（成交均价）、slices（各笔子订单的 order_id、quantity、price），中途停止时 success 为 false 且 data 为已成交部分。",
+		"result.closeAllPositions":             "data 字段：closed、failed、total_pnl（已实现盈亏合计）、reason、reason_code、results（每个持仓的 symbol、side、order_id、pnl、already_flat、error）；有持仓平仓失败时 success 为 false，失败的持仓仍由止损止盈单保护。",
+		"result.updateStopOrders":              "data 字段：symbol、old_stop_loss、new_stop_loss、old_take_profit、new_take_profit、stop_type、reason。",
+		"result.adjustLeverage":                "data 字段：symbol、old_leverage、leverage、old_margin、margin、liquidation_price、reason。",
+		"result.getRecentDecisions":            "data 字段：count、decisions（每项含 iteration、executed_at、account_value、position_count、actions、rationale）。",
+		"result.getKlines":                     "data 字段：symbol、interval、count、summary、candle_fields、recent_candles（按 candle_fields 顺序排列的数组）、indicators（K线不足以计算指标时省略）。",
+		"result.getAccountRisk":                "data 字段：equity（净值）、available、margin_used、unrealized_pnl、gross_notional（名义价值合计）、gross_leverage（名义价值合计/净值）、total_stop_risk（全部持仓触及止损时从当前价起的亏损，USDT）、total_stop_risk_pct（占净值%）、unprotected_count（未设止损的持仓数，未计入止损风险）、position_count、max_positions（0表示不限制）、drawdown_from_peak（%，负数为回撤）、max_drawdown_percent、close_only（只平仓模式时为 true）。",
+
+		// 校验信息
+		"rule.symbol_side_required":  "symbol 和 side 不能为空",
+		"rule.invalid_side":          "side 必须为 long 或 short，当前为 %s",
+		"rule.margin_positive":       "保证金 quantity 必须大于0，当前 %.8f USDT",
+		"sizing.invalid_percent":     "按可用余额百分比开仓时 quantity 必须在 (0, 100] 之间，当前 %.2f",
+		"sizing.balance_unknown":     "无法获取可用余额，不能按余额百分比计算仓位",
+		"stop.percent_ambiguous":     "%s 和 %s 不能同时设置，请只保留其中一个",
+		"stop.percent_invalid":       "%s 必须在 (0, 100) 之间，当前 %.2f",
+		"ladder.conflict":            "take_profit_levels 不能与 take_profit_price 或 take_profit_percent 同时设置",
+		"ladder.too_many":            "take_profit_levels 最多 %d 档，当前 %d 档",
+		"ladder.invalid_level":       "take_profit_levels 第 %d 档必须给出 price 或 percent 之一（percent 在 (0, 100) 之间）以及大于0的 allocation_percent",
+		"ladder.allocation_sum":      "take_profit_levels 的 allocation_percent 合计 %.2f%% 超过100%%",
+		"rule.stop_loss_required":    "必须通过 stop_loss_price 或 stop_loss_percent 设置止损，且止损价格大于0",
+		"rule.reason_required":       "开仓理由 reason 不能为空",
+		"rule.exit_plan_required":    "退出计划 exit_plan 不能为空，请明确止损与退出逻辑",
+		"rule.reason_too_short":      "开仓理由过于简单（当前 %d 字符，至少 %d 字符），请说明依据的时间框架与具体信号",
+		"rule.exit_plan_too_short":   "退出计划过于简单（当前 %d 字符，至少 %d 字符），请写明具体止损价位和止盈/退出条件",
+		"rule.explanation_missing":   "开仓说明缺少：%s。reason/exit_plan 需提及时间框架（如 1h、4h）、具体信号（如突破、均线、RSI），并在 exit_plan 中写明具体止损价位",
+		"rule.explanation_timeframe": "时间框架",
+		"rule.explanation_signal":    "具体信号",
+		"rule.explanation_stop":      "具体止损价位",
+		"rule.confidence_range":      "开仓信心 confidence 必须为 %d-%d 的整数，当前为 %d",
+		"rule.leverage_range":        "杠杆 %dx 超出允许范围 %d-%dx",
+		"rule.symbol_leverage_cap":   "杠杆 %dx 超出 %s 单独配置的上限 %dx，请使用不超过该上限的杠杆",
+		"rule.invalidation_required": "论点失效价格 invalidation_price 必须设置且大于0，请明确价格到达何处说明开仓逻辑不再成立",
+		"rule.invalidation_long":     "做多时论点失效价格 %.4f 必须低于当前价格 %.4f",
+		"rule.invalidation_short":    "做空时论点失效价格 %.4f 必须高于当前价格 %.4f",
+		"rule.min_notional":          "%s 最小名义价值为 %.2f USDT，当前 %.2f USDT（保证金 %.2f × 杠杆 %dx），请至少使用 %.2f USDT 保证金",
+		"rule.available_margin":      "可用保证金不足：需要 %.2f USDT，可用 %.2f USDT",
+		"rule.volatility_blocked":    "%s 波动异常放大：%s ATR%d 为 ATR%d 的 %.2f 倍（阈值 %.2f 倍），系统禁止此时开仓，请等待波动回落",
+		"rule.volatility_stop":       "%s 波动异常放大（%s ATR%d 为 ATR%d 的 %.2f 倍），止损 %.4f 离开仓价太近，需至少留出 %.2f 倍 ATR%d，止损应设在 %.4f 或更远处",
+		"rule.max_positions":         "已达到最大持仓数量 %d 个（当前 %d 个），请先平仓再开新仓",
+		"rule.max_drawdown":          "账户距峰值回撤 %.2f%% 已达到最大回撤限制 %.2f%%，禁止开新仓",
+		"rule.regime_blocked":        "%s 当前为%s行情（1h ADX %.1f），系统只允许在趋势行情中开仓；如确有把握，请在 regime_override_reason 中说明理由后重试",
+		"rule.htf_trend_blocked":     "%s 的 %s 处于强%s趋势（ADX %.1f ≥ %.1f，EMA快线 %.4f / 慢线 %.4f），系统禁止逆大级别趋势开仓",
+		"rule.breakout_pending":      "%s 的 %s 向%s突破 $%.4f 仅 %d 根已收盘K线确认（需 %d 根），系统禁止在突破确认前顺势开仓",
+		"rule.htf_trend_justify":     "%s 的 %s 处于强%s趋势（ADX %.1f ≥ %.1f，EMA快线 %.4f / 慢线 %.4f），逆大级别趋势开仓必须在 counter_trend_reason 中说明理由",
+		"rule.open_window":           "滚动 %d 小时内已开仓 %d 次，达到上限 %d 次，约 %s 后才能再次开仓；请把有限的开仓机会留给质量最高的信号",
+		"rule.close_only":            "系统处于只平仓模式，不允许新开仓；请只管理、调整止损或平掉现有持仓",
+		"rule.max_spread":            "%s 当前买卖价差 %.3f%% 超过允许的最大价差 %.3f%%，盘口流动性不足，市价开仓成本过高；请等价差收窄后再开仓，或选择流动性更好的交易对",
+		"rule.rejected":              "开仓被拒绝：",
+		"rule.separator":             "；",
+		"stop.long_stop_loss":        "做多时止损价%.2f必须低于当前价%.2f",
+		"stop.long_take_profit":      "做多时止盈价%.2f必须高于当前价%.2f",
+		"stop.short_stop_loss":       "做空时止损价%.2f必须高于当前价%.2f",
+		"stop.short_take_profit":     "做空时止盈价%.2f必须低于当前价%.2f",
+		"close.reason_required":      "平仓理由不能为空",
+		"close.reason_too_short":     "平仓理由过于简单（当前 %d 字符），请详细说明触发的退出条件（至少 20 字符）",
+		"close.exit_plan_mismatch":   "平仓理由未体现退出计划的关键条件（如止损、止盈、支撑/阻力、结构破坏等）",
+		"close.invalid_reason_code":  "平仓原因代码 reason_code 无效：%q，可选值：%s",
+		"close.twap_interrupted":     "%s TWAP 平仓中断，已分 %d 笔平掉 %v，剩余仓位仍由止损止盈单保护：%v",
+		"close.twap_done":            "成功分 %d 笔平仓 %s，均价 $%v，盈亏 $%.2f",
+		"close.reason_suffix":        "（理由：%s）",
+		"close.done":                 "成功平仓 %s，盈亏 $%.2f",
+		"close.already_flat":         "%s 仓位已不存在，已清理相关订单",
+		"close_all.none":             "当前没有持仓",
+		"close_all.partial":          "已平掉 %d 个持仓，%s 平仓失败，仍由止损止盈单保护",
+		"close_all.done":             "已平掉全部 %d 个持仓，合计盈亏 $%.2f",
+		"close_all.separator":        "、",
+		"open.done":                  "成功开仓 %s %s，杠杆 %dx，保证金 %.2fU，价格 %.2f，止损 %.2f",
+		"open.take_profit":           "，止盈 %.2f",
+		"open.take_profit_ladder":    "，分批止盈 %s",
+		"open.level_not_created":     "（未创建）",
+		"open.auto_leverage":         "（自动杠杆：请求 %dx → 使用 %dx，止损风险约 %.2f%%）",
+		"open.confidence_scaled":     "（信心 %d/10，保证金按 %.2f 倍缩减）",
+		"open.volatility_scaled":     "（%s ATR%d 为 ATR%d 的 %.2f 倍，波动异常放大，保证金按 %.2f 倍缩减）",
+		"open.partial_fill":          "（部分成交：下单 %v，成交 %v，止损止盈按成交数量设置）",
+		"open.slippage":              "（成交滑点 %.3f%% 超过 %.3f%%）",
+		"open.slippage_stop_shifted": "（成交滑点 %.3f%% 超过 %.3f%%，止损已按成交价平移以保持计划风险）",
+		"open.stop_reset":            "（成交价 %.2f 已越过原止损，止损已从成交价重设为 %.2f）",
+		"open.take_profit_dropped":   "（止盈 %.2f 已被成交价 %.2f 越过，未创建止盈单）",
+		"open.levels_dropped":        "（%d 档止盈已被成交价 %.2f 越过，未创建，对应仓位不设止盈）",
+		"klines.invalid_interval":    "不支持的K线周期 %q，可选值：%s",
+		"leverage.unchanged":         "%s 当前杠杆已是 %dx，无需调整",
+		"leverage.margin_short":      "降低杠杆至 %dx 需要追加保证金 %.2f USDT，可用余额仅 %.2f USDT",
+		"leverage.adjusted":          "%s 杠杆已从 %dx 调整为 %dx，保证金 $%.2f",
+		"update.sl_kept":             "新止损单 %.4f 创建失败（%v），原止损单 %.4f 未取消，持仓仍有止损保护",
+		"update.sl_unprotected":      "新止损单 %.4f 创建失败（%v），该持仓当前没有止损单，请立即重新设置止损或平仓",
+		"update.tp_failed":           "新止盈单 %.4f 创建失败（%v），原止盈单未取消",
+		"update.sl_done":             "止损已更新为 %.4f",
+		"budget.tool_calls":          "本次决策的工具调用已达上限 %d 次，请停止调用工具并总结本次决策",
+		"budget.opens":               "本次决策已开仓 %d 次，达到单次决策的开仓上限，不能继续开仓",
+		"budget.closes":              "本次决策已平仓 %d 次，达到单次决策的平仓上限，不能继续平仓",
+		"rollback.close_failed":      "止损单创建失败（%v），自动平仓也失败（%v）：%s 当前持仓没有止损保护，请立即平仓或重新设置止损",
+		"rollback.closed":            "止损单创建失败（%v），系统已自动平掉 %s %s 仓位（盈亏 %.2f USDT），本次开仓未生效",
+		"rollback.trade_reason":      "止损单创建失败，系统自动平仓：%v",
+	},
+	PromptLanguageEn: {
+		// Prompt
+		"context.header":           "**Time**: %s | **Cycle**: #%d | **Uptime**: %.0f min\n\n",
+		"context.close_only":       "⛔ **Close-only mode**: new positions are disabled (openPosition will be rejected). This cycle only manages existing positions: adjust stops/targets or close as planned\n\n",
+		"market.title":             "## Market Overview\n\n",
+		"market.empty":             "No market data available.\n\n",
+		"market.omitted":           "Symbols below are ordered by relevance; %d more were left out by the display limit (%s), do not open them this cycle\n\n",
+		"market.price_funding":     "💰 $%s | 📊 Funding rate %.4f%%\n",
+		"market.high_low":          "**24h High/Low**: $%s / $%s\n",
+		"market.regime":            "**Market regime** (1h): %s (ADX %.1f)\n",
+		"market.regime_blocked":    "⚠️ Not trending: new opens are blocked unless regime_override_reason justifies the trade\n",
+		"regime.trending":          "trending",
+		"regime.ranging":           "ranging",
+		"regime.uncertain":         "uncertain",
+		"breakout.confirmed":       "**Breakout confirmation** (%s): %s breakout of $%s held for %d closed candles (%d required), confirmed\n",
+		"breakout.unconfirmed":     "**Breakout confirmation** (%s): %s breakout of $%s held for only %d closed candles (%d required), not yet confirmed and may be single-candle noise\n",
+		"breakout.blocked":         "⚠️ Breakout not yet confirmed: opens in the breakout direction are blocked\n",
+		"volatility.spike":         "⚠️ **Volatility spike** (%s): ATR%d / ATR%d ratio is %.2fx (threshold %.2fx), likely a news move or liquidation cascade where normal stop distances get blown through\n",
+		"volatility.block":         "⚠️ New opens are blocked during the volatility spike\n",
+		"volatility.reduce":        "⚠️ Margin of new opens is scaled down by the system during the volatility spike\n",
+		"volatility.widen":         "⚠️ During the volatility spike new opens must leave enough short-term ATR distance to the stop or they are rejected\n",
+		"breakout.up":              "upside",
+		"breakout.down":            "downside",
+		"htf_trend.up":             "up",
+		"htf_trend.down":           "down",
+		"htf_trend.none":           "no clear",
+		"market.key_levels":        "**Key Support/Resistance** (1h pivot clusters):\n",
+		"market.resistance":        "- Resistance $%s | distance %+.2f%%%s | %d touches\n",
+		"market.support":           "- Support $%s | distance %+.2f%%%s | %d touches\n",
+		"market.level_atr":         " / %+.1f ATR",
+		"market.timeframes":        "**Multi-timeframe Indicators**\n",
+		"market.ema_deviation":     " vs EMA%d %+.2f%%",
+		"market.volume_ratio":      " (%.2fx avg)",
+		"market.tf_price":          "  - Price: $%s%s\n",
+		"market.tf_ema":            "  - MAs: EMA%d=$%s / EMA%d=$%s\n",
+		"market.tf_bbands":         "  - Bollinger Bands: U=$%s M=$%s L=$%s\n",
+		"market.tf_indicators":     "  - Indicators: MACD=%s | RSI%d=%.1f | ATR%d=%s\n",
+		"market.tf_volume":         "  - Volume: %s (avg: %s)%s\n",
+		"market.intraday":          "**Price Action (15m, %.1fh)**: ",
+		"market.intraday_range":    "open %s → last %s (%+.2f%%) | range [%s-%s] amplitude %.2f%%\n",
+		"market.recent_closes":     "- Recent closes (last %d): %s\n",
+		"market.h1_title":          "**1h Trend**\n",
+		"market.ema_above":         "EMA%d above EMA%d",
+		"market.ema_below":         "EMA%d below EMA%d",
+		"market.ema_near":          "EMA%d close to EMA%d",
+		"market.h1_ema_relation":   "- **1h MA structure**: %s | **ADX%d**: %.1f",
+		"market.h1_ema_strength":   "- **MA spread**: %.2f%% (EMA%d vs EMA%d)\n",
+		"market.current_volume":    "current volume",
+		"market.avg_volume":        "average",
+		"market.status_above":      "above",
+		"market.status_below":      "below",
+		"market.status_equal":      "equal to",
+		"market.volatility":        "- Volatility & volume: %s | %s\n",
+		"market.macd_series":       "- MACD series: ",
+		"market.rsi_series":        "- RSI%d series: ",
+		"account.title":            "## Account Status\n\n",
+		"account.empty":            "No account data available.\n\n",
+		"account.funds":            "**Funds**: equity $%.2f (initial $%.2f, peak $%.2f) | available $%.2f (%.1f%%)\n",
+		"account.capital":          "**Capital utilization**: margin used by positions $%.2f, %.1f%% of equity\n",
+		"account.returns":          "**Returns**: %s %+.2f%% | unrealized PnL $%+.2f | cumulative funding $%+.2f\n",
+		"account.stop_risk":        "**Stop risk**: all positions hitting their stops would lose $%.2f in total (%.2f%% of equity)%s\n",
+		"account.unprotected":      " | ⚠️ %d positions have no stop loss and are not counted",
+		"account.forced_flat":      " | forced liquidation threshold %s%% reached (system rule)",
+		"account.drawdown_warn":    " | drawdown warning level %s%% reached (system rule)",
+		"account.risk":             "**Risk**: %s drawdown %.2f%% (from peak) / %.2f%% (from initial) | %s Sharpe ratio %s%s\n\n",
+		"position.title":           "## Open Positions\n\n",
+		"position.count":           "**Positions: %d/%d**\n\n",
+		"position.empty":           "No open positions, up to %d positions allowed\n\n",
+		"position.price":           "- Price: entry $%s → current $%s\n",
+		"position.pnl":             "- PnL: $%+.2f (%+.2f%%)",
+		"position.peak_pnl":        " | peak PnL %+.2f%%",
+		"position.leverage":        "- Leverage: %dx | Margin: $%.2f | Notional: $%.2f | Quantity: %.4f\n",
+		"position.liquidation":     "- Liquidation price: $%s (%+.2f%% from current)\n",
+		"position.stop_risk":       "- Stop loss: $%s | loss if hit $%.2f (%.2f%% of equity)\n",
+		"position.no_stop":         "- ⚠️ No stop loss set\n",
+		"position.funding":         "- Estimated next funding: $%+.4f (rate %.4f%%)\n",
+		"position.accrued_funding": "- Funding since open: $%+.2f (cost %.2f%% of margin)\n",
+		"position.funding_limit":   "⚠️ **Funding erosion**: accumulated funding cost is %.2f%% of margin, above the %.2f%% limit; close unless the trend still clearly supports holding\n",
+		"position.holding":         "- Holding time: %s",
+		"position.deadline":        " | forced close in: %s (max holding %d hours)",
+		"position.expected_value":  "- Expected value (rough): %+.2f USDT | to take profit +$%.2f (%.1f ATR, touch probability %.0f%%) | to stop -$%.2f (%.1f ATR, touch probability %.0f%%) | remaining R:R %.2f | horizon %dh. Estimated from 1h ATR distance as a random walk; when the remaining R:R is poor consider cutting instead of anchoring on the entry\n",
+		"position.health":          "- Health score: %.0f/100 (liquidation distance %.0f | stop distance %.0f | time left %.0f | profit kept %.0f | funding %.0f); when reducing exposure, handle the lowest-scoring position first\n",
+		"position.entry_reason":    "**Entry reason**: %s\n\n",
+		"position.exit_plan":       "**Exit plan**: %s\n\n",
+		"position.invalidation":    "**Invalidation price**: $%s (%+.2f%% from current)\n\n",
+		"position.invalidated":     "⚠️ **Thesis invalidated, consider exiting**: price has crossed the invalidation level committed at entry\n\n",
+		"position.entry_slippage":  "⚠️ Entry filled with %.3f%% slippage, above the limit; the actual entry is worse than planned, so re-check the stop and reward/risk from the actual entry\n",
+		"position.frozen":          "🚫 **Position frozen**: symbol status is %s (delisted or halted) and the system could not close it, so no market data or orders are possible. Do not call any tool for this symbol; wait for manual handling on the exchange. Price and PnL may be stale\n",
+		"capacity.title":           "## Position Capacity\n\n",
+		"capacity.slots":           "**Remaining slots**: %d (max %d)\n",
+		"capacity.available":       "**Available balance**: $%.2f\n",
+		"capacity.open_window":     "**Opens in rolling %dh window**: %d/%d%s\n\n",
+		"capacity.window_full":     " (limit reached, resets in about %s; opens are rejected until then)",
+		"capacity.leverage_caps":   "**Per-symbol leverage caps**: %s (other symbols max %dx); opening or adjusting leverage above the cap is rejected\n\n",
+		"orders.title":             "## Active Stop Orders\n\n",
+		"orders.empty":             "No active stop orders\n\n",
+		"orders.position":          "### Position #%d %s %s\n",
+		"orders.stop_loss":         "- **Stop loss**: $%s (%+.2f%% from current) | created %s",
+		"orders.take_profit":       "- **Take profit**: $%s (%+.2f%% from current) | created %s",
+		"orders.reason":            " | reason: %s",
+		"trades.title":             "## Trade History (last %d)\n\n",
+		"trades.empty":             "No trades yet\n\n",
+		"trades.stats":             "**Stats**: win rate %.0f%% (%dW/%dL) | net PnL $%.2f | total fees $%.2f\n\n",
+		"trades.item":              "%d. [%s] %s %s, price=$%s, qty=%.4f, leverage=%dx, fee=$%.2f",
+		"trades.pnl":               ", pnl=%s$%.2f",
+		"trades.reason":            ", reason: %s",
+		"trades.entry_reason":      "   - Entry reason: %s\n",
+		"trades.exit_plan":         "   - Exit plan: %s\n",
+
+		// Tools
+		"tool.openPosition":                    "Open a position (long or short). A stop-loss order is placed on the exchange right after the fill as the last line of defense.",
+		"tool.openPosition.symbol":             "Trading pair, e.g. BTCUSDT",
+		"tool.openPosition.side":               "Direction: long or short",
+		"tool.openPosition.leverage":           "Leverage (3-15), chosen according to signal strength",
+		"tool.openPosition.quantity":           "Margin amount in USDT. Note: notional = margin × leverage. For example 100 USDT margin at 10x opens 1000 USDT notional. The notional must meet the symbol's minimum notional.",
+		"tool.openPosition.quantity.notional":  "Position notional value in USDT (not margin). The system derives margin = notional / leverage, e.g. 1000 means opening 1000 USDT worth of coin; at 10x it uses 100 USDT margin. The notional must meet the symbol's minimum notional.",
+		"tool.openPosition.quantity.equity":    "Percentage of available balance to use as margin (0-100], e.g. 20 uses 20% of the available balance as margin; notional = margin × leverage. The resulting notional must meet the symbol's minimum notional.",
+		"tool.openPosition.stop_loss_price":    "[Either this or stop_loss_percent] Stop-loss price. A stop order is created on the exchange immediately after opening. Must be below the current price for longs and above it for shorts. Tip: derive it from ATR, key support/resistance or risk tolerance, typically 3-5% from entry (mind the leveraged account risk).",
+		"tool.openPosition.take_profit_price":  "[Optional] Take-profit price. If set, a take-profit order is created on the exchange after opening. Must be above the current price for longs and below it for shorts. Tip: base it on key resistance or a reward-to-risk ratio (e.g. 2:1 or 3:1). Leave empty to manage exits dynamically.",
+		"tool.openPosition.stop_percent":       "[Either this or stop_loss_price] Stop distance from entry in percent (price distance, not leveraged), e.g. 2 puts a long's stop 2% below the fill and a short's 2% above. The system computes the stop price from the actual fill and side so it can never be on the wrong side; preferred. Cannot be combined with stop_loss_price.",
+		"tool.openPosition.target_percent":     "[Optional, either this or take_profit_price] Take-profit distance from entry in percent (price distance, not leveraged), e.g. 6 puts a long's target 6% above the fill and a short's 6% below. The system computes the price from the actual fill and side. Cannot be combined with take_profit_price.",
+		"tool.openPosition.take_profit_levels": "[Optional] Scaled take profit, up to 5 levels; cannot be combined with take_profit_price / take_profit_percent. Each level sets either price or percent plus allocation_percent (share of the opened quantity to close), e.g. [{percent:3, allocation_percent:50}, {percent:6, allocation_percent:30}] closes half at 3% and 30% at 6%, leaving 20% as a runner without a target. After a level fills the system resizes the stop to the remaining quantity.",
+		"tool.openPosition.level_price":        "Take-profit price for this level, either this or percent",
+		"tool.openPosition.level_percent":      "Take-profit distance from entry in percent for this level (price distance, not leveraged), computed from the actual fill; either this or price",
+		"tool.openPosition.level_allocation":   "Share of the opened quantity closed at this level (%); all levels together must not exceed 100",
+		"tool.openPosition.reason":             "Entry reason: the signal source and how the timeframes line up. Mention a concrete timeframe (e.g. 1h, 4h) and signal (e.g. breakout, moving average, RSI); lazy justifications are rejected",
+		"tool.openPosition.exit_plan":          "Detailed exit plan that explicitly contains at least one of: 1) stop condition (price/percent/indicator); 2) profit target (price/resistance); 3) structure break condition; 4) time condition. It must state the concrete stop level. The reason given when closing must map to one of these conditions.",
+		"tool.openPosition.regime_override":    "[Optional] Justification for opening in a ranging or uncertain market. When the trending-only filter is enabled, opens outside trending regimes are rejected without it; explain why the trade does not depend on a trend (e.g. a range-edge reversal with a clear stop).",
+		"tool.openPosition.counter_trend":      "[Optional] Justification for opening against a strong 1h trend (e.g. shorting a strong 1h uptrend). When counter-trend opens require justification, they are rejected without it; explain the reversal evidence and why the stop handles a trend continuation.",
+		"tool.openPosition.confidence":         "[Required] Confidence in the trade (integer 1-10), reflecting signal strength and timeframe alignment. Low-confidence trades have their margin scaled down by system config, and stats report win rate per confidence level, so assess it honestly.",
+		"tool.openPosition.invalidation_price": "[Required] Thesis invalidation price: reaching it means the entry logic no longer holds (e.g. key structure broken), distinct from the protective stop. Must be below the current price for longs and above it for shorts. Once crossed, the position info will flag the thesis as invalidated.",
+		"tool.closePosition":                   "Close the given position. Important: the close reason must strictly match a concrete condition in the exit_plan set when the position was opened; do not close arbitrarily.",
+		"tool.closePosition.symbol":            "Trading pair",
+		"tool.closePosition.execution":         "Execution: immediate (default, one market order for the whole position) or twap (split into %d child market orders spread over %d seconds to reduce market impact on large positions; stops automatically if the position is closed by a stop or other order meanwhile). Use immediate for urgent exits.",
+		"tool.closePosition.reason":            "Close reason. State exactly which condition of the position's exit plan was triggered (stop, target, structure break, ...) and include its key elements (price, indicator, condition). Examples: \"stop hit, price broke below $95,000\", \"target $105,000 reached at resistance\", \"market structure broken, lost the rising trendline\". Vague or unrelated reasons are not allowed.",
+		"tool.closePosition.reason_code":       "Exit category: stop_loss, take_profit, structure_break, time_exit, thesis_invalidated, risk_management or other. Must match the condition described in reason; used to analyse PnL by exit type.",
+		"tool.closeAllPositions":               "Close every open position at market in one call and cancel their stop orders. Use it when conditions deteriorate sharply and you need to go flat immediately; for normal exits close positions one by one with closePosition.",
+		"tool.closeAllPositions.reason":        "Reason for flattening everything at once, e.g. broad market selloff, BTC lost the key $90,000 support on 1h and total exposure is too large. Vague or unrelated reasons are not allowed.",
+		"tool.closeAllPositions.reason_code":   "Exit category, defaults to risk_management; same values as closePosition.",
+		"tool.updateStopOrders":                "Update the position's stop-loss/take-profit orders, e.g. trail the stop to protect profit or adjust the target. Old stop orders are cancelled and new ones created.",
+		"tool.updateStopOrders.symbol":         "Trading pair",
+		"tool.updateStopOrders.stop_loss":      "[Optional] New stop-loss price. Omit to keep the current stop. Typical use: move the stop to break-even or higher once the position is in profit.",
+		"tool.updateStopOrders.take_profit":    "[Optional] New take-profit price. Omit to keep the current target. Set to 0 to cancel the take-profit order. On a position with scaled take-profit levels, a new price cancels all remaining levels and places a single take-profit for the full remaining quantity; 0 cancels all levels; updating only the stop keeps the levels unchanged.",
+		"tool.stop_type":                       "[Optional] How the stop executes once triggered: market fills at market (default, certain fill but may slip far from the trigger in thin books); limit places a limit order (bounded slippage, but may not fill if price gaps through the limit, leaving the position unprotected). Consider limit for illiquid symbols. Omit to keep the current stop's setting or the system default.",
+		"tool.stop_limit_offset":               "[Optional] Offset of the stop-limit price from the trigger, in percent, in the adverse direction (e.g. 0.5 puts a long's limit at 99.5% of the trigger). Only used with stop_type=limit; omit to use the system default.",
+		"tool.updateStopOrders.reason":         "Reason for the update, e.g. position up 5% so stop moved to break-even, or market conditions changed so the target was raised.",
+		"tool.adjustLeverage":                  "Change the leverage of an existing position without closing it. Lower leverage ties up more margin and lowers liquidation risk, useful for de-risking a position when volatility rises; rejected when available margin is insufficient.",
+		"tool.adjustLeverage.symbol":           "Trading pair",
+		"tool.adjustLeverage.leverage":         "New leverage, must be within the allowed leverage range",
+		"tool.adjustLeverage.reason":           "Reason for the change, e.g. volatility is rising so leverage is lowered to reduce liquidation risk",
+		"tool.getRecentDecisions":              "Fetch summaries of the most recent decisions (iteration, actions taken, rationale). Use it to revisit earlier trade theses, stay consistent, and avoid flip-flopping in and out of positions.",
+		"tool.getRecentDecisions.limit":        "Number of decisions to return, default 3, max 10",
+		"tool.getKlines":                       "Fetch historical klines for a symbol and interval on demand. Returns a range OHLCV summary, key indicators (EMA/RSI/MACD/ATR/ADX/Bollinger Bands) and the last few candles. Use it when the default snapshot is not enough, e.g. to check the daily structure before opening.",
+		"tool.getKlines.symbol":                "Trading pair, e.g. BTCUSDT",
+		"tool.getKlines.interval":              "Kline interval",
+		"tool.getKlines.limit":                 "Number of klines, default 100, min 50, max 200",
+		"tool.getAccountRisk":                  "Fetch the live aggregate account risk: equity, available balance, gross notional, gross leverage, total stop risk, position count vs limit and current drawdown. Use it after opening one position and before opening another in the same decision, so sizing reflects the current state rather than the pre-decision snapshot.",
+		"tool.result":                          "Returns a JSON envelope {schema_version: %d, success, action, message, data, error: {message}}; when success is false the reason is in error.message. ",
+		"result.openPosition":                  "data fields: order_id, symbol, side, price (average fill price), quantity (filled quantity), ordered_quantity, partial_fill (true when only partly filled; stops are sized to the fill), leverage, stop_loss_price, stop_type, take_profit_price, invalidation_price, confidence, size_multiplier, sizing_mode, requested_quantity, stop_loss_order_id, take_profit_order_id, implied_risk_percent, auto_leverage (auto leverage only), htf_trend (1h trend state when the counter-trend guard is on), expected_price (price before the order), slippage_percent (fill slippage %, positive is adverse), stop_adjusted (stop shifted by the fill after excessive slippage), stop_loss_percent / take_profit_percent (when stops were given as percentages; prices are computed from the fill), stop_reset (the fill slipped past the stop, which was reset from the fill by ATR), take_profit_dropped (the fill slipped past the take profit, no take-profit order was placed), take_profit_levels (with scaled take profit: level, price, quantity, allocation_percent, created per level), volatility_spike (1h ATR expansion when the volatility check is on), volatility_margin (margin multiplier applied during a volatility spike); when rejected by pre-trade rules data is symbol, side, rejected_rules, rule_results, htf_trend, volatility_spike.",
+		"result.closePosition":                 "data fields: order_id, symbol, pnl, reason, reason_code, already_flat (true when the exchange had no such position and only leftover orders were cleaned up); TWAP closes also return execution, quantity (closed quantity), price (average fill), slices (order_id, quantity, price of each child order), and when stopped midway success is false with data describing the filled part.",
+		"result.closeAllPositions":             "data fields: closed, failed, total_pnl (total realized PnL), reason, reason_code, results (symbol, side, order_id, pnl, already_flat, error per position); success is false when any position failed to close, and failed positions stay protected by their stop orders.",
+		"result.updateStopOrders":              "data fields: symbol, old_stop_loss, new_stop_loss, old_take_profit, new_take_profit, stop_type, reason.",
+		"result.adjustLeverage":                "data fields: symbol, old_leverage, leverage, old_margin, margin, liquidation_price, reason.",
+		"result.getRecentDecisions":            "data fields: count, decisions (each with iteration, executed_at, account_value, position_count, actions, rationale).",
+		"result.getKlines":                     "data fields: symbol, interval, count, summary, candle_fields, recent_candles (arrays ordered as candle_fields), indicators (omitted when there are too few klines).",
+		"result.getAccountRisk":                "data fields: equity, available, margin_used, unrealized_pnl, gross_notional (sum of position notionals), gross_leverage (gross notional / equity), total_stop_risk (loss from current prices if every position hits its stop, USDT), total_stop_risk_pct (% of equity), unprotected_count (positions without a stop, not counted in stop risk), position_count, max_positions (0 means unlimited), drawdown_from_peak (%, negative is a drawdown), max_drawdown_percent, close_only (true in close-only mode).",
+
+		// Validation
+		"rule.symbol_side_required":  "symbol and side are required",
+		"rule.invalid_side":          "side must be long or short, got %s",
+		"rule.margin_positive":       "margin quantity must be greater than 0, got %.8f USDT",
+		"sizing.invalid_percent":     "with percent_equity sizing, quantity must be a percentage of available balance in (0, 100], got %.2f",
+		"sizing.balance_unknown":     "available balance is unknown, cannot size the position as a percentage of it",
+		"stop.percent_ambiguous":     "%s and %s cannot both be set, keep only one of them",
+		"stop.percent_invalid":       "%s must be in (0, 100), got %.2f",
+		"ladder.conflict":            "take_profit_levels cannot be combined with take_profit_price or take_profit_percent",
+		"ladder.too_many":            "take_profit_levels allows at most %d levels, got %d",
+		"ladder.invalid_level":       "take_profit_levels level %d must set exactly one of price or percent (percent in (0, 100)) and a positive allocation_percent",
+		"ladder.allocation_sum":      "take_profit_levels allocation_percent adds up to %.2f%%, more than 100%%",
+		"rule.stop_loss_required":    "a stop loss is required: set stop_loss_price or stop_loss_percent, and the stop price must be greater than 0",
+		"rule.reason_required":       "entry reason is required",
+		"rule.exit_plan_required":    "exit_plan is required, describe the stop and exit logic",
+		"rule.reason_too_short":      "entry reason is too short (%d characters, at least %d), explain the timeframe and the concrete signal it is based on",
+		"rule.exit_plan_too_short":   "exit_plan is too short (%d characters, at least %d), state the concrete stop level and the take-profit/exit conditions",
+		"rule.explanation_missing":   "entry explanation is missing: %s. reason/exit_plan must mention a timeframe (e.g. 1h, 4h) and a concrete signal (e.g. breakout, moving average, RSI), and exit_plan must state a concrete stop level",
+		"rule.explanation_timeframe": "timeframe",
+		"rule.explanation_signal":    "concrete signal",
+		"rule.explanation_stop":      "concrete stop level",
+		"rule.confidence_range":      "confidence must be an integer between %d and %d, got %d",
+		"rule.leverage_range":        "leverage %dx is outside the allowed range %d-%dx",
+		"rule.symbol_leverage_cap":   "leverage %dx exceeds the %s cap of %dx, use leverage within the cap",
+		"rule.invalidation_required": "invalidation_price is required and must be greater than 0, state where the entry logic stops holding",
+		"rule.invalidation_long":     "for longs the invalidation price %.4f must be below the current price %.4f",
+		"rule.invalidation_short":    "for shorts the invalidation price %.4f must be above the current price %.4f",
+		"rule.min_notional":          "%s minimum notional is %.2f USDT, got %.2f USDT (margin %.2f × leverage %dx), use at least %.2f USDT margin",
+		"rule.available_margin":      "insufficient available margin: need %.2f USDT, available %.2f USDT",
+		"rule.volatility_blocked":    "%s volatility spike: %s ATR%d / ATR%d ratio is %.2fx (threshold %.2fx), opens are blocked until volatility settles",
+		"rule.volatility_stop":       "%s volatility spike (%s ATR%d / ATR%d ratio %.2fx): stop %.4f is too close to entry, leave at least %.2fx ATR%d and place the stop at %.4f or further",
+		"rule.max_positions":         "maximum of %d positions reached (currently %d), close a position before opening a new one",
+		"rule.max_drawdown":          "drawdown from peak %.2f%% has reached the limit of %.2f%%, opening new positions is disabled",
+		"rule.regime_blocked":        "%s is currently %s (1h ADX %.1f) and opens are only allowed in trending markets; if you have strong conviction, explain it in regime_override_reason and retry",
+		"rule.htf_trend_blocked":     "%s %s is in a strong %s trend (ADX %.1f >= %.1f, EMA fast %.4f / slow %.4f); opening against the higher-timeframe trend is not allowed",
+		"rule.breakout_pending":      "%s %s %s breakout of $%.4f is confirmed by only %d closed candles (%d required); opening in the breakout direction before confirmation is not allowed",
+		"rule.htf_trend_justify":     "%s %s is in a strong %s trend (ADX %.1f >= %.1f, EMA fast %.4f / slow %.4f); opening against the higher-timeframe trend requires a counter_trend_reason",
+		"rule.open_window":           "%d-hour rolling window already has %d opens, reaching the limit of %d; new opens are possible again in about %s. Save the limited entries for the highest-quality signals",
+		"rule.close_only":            "the system is in close-only mode and new positions are not allowed; only manage, adjust stops on or close existing positions",
+		"rule.max_spread":            "%s bid-ask spread is %.3f%%, above the allowed maximum of %.3f%%; the book is too thin and a market open would be costly. Wait for the spread to narrow or pick a more liquid symbol",
+		"rule.rejected":              "open rejected: ",
+		"rule.separator":             "; ",
+		"stop.long_stop_loss":        "for longs the stop loss %.2f must be below the current price %.2f",
+		"stop.long_take_profit":      "for longs the take profit %.2f must be above the current price %.2f",
+		"stop.short_stop_loss":       "for shorts the stop loss %.2f must be above the current price %.2f",
+		"stop.short_take_profit":     "for shorts the take profit %.2f must be below the current price %.2f",
+		"close.reason_required":      "close reason is required",
+		"close.reason_too_short":     "close reason is too short (%d characters), describe the triggered exit condition in detail (at least 20 characters)",
+		"close.exit_plan_mismatch":   "close reason does not reference a key exit plan condition (stop, target, support/resistance, structure break, ...)",
+		"close.invalid_reason_code":  "invalid reason_code %q, expected one of: %s",
+		"close.twap_interrupted":     "%s TWAP close interrupted after %d slices closed %v, the remaining position is still protected by its stop loss and take profit orders: %v",
+		"close.twap_done":            "closed in %d slices %s, average price $%v, pnl $%.2f",
+		"close.reason_suffix":        " (reason: %s)",
+		"close.done":                 "closed %s, pnl $%.2f",
+		"close.already_flat":         "%s position no longer exists, related orders were cleaned up",
+		"close_all.none":             "no open positions",
+		"close_all.partial":          "closed %d positions, failed to close %s, they are still protected by their stop loss and take profit orders",
+		"close_all.done":             "closed all %d positions, total pnl $%.2f",
+		"close_all.separator":        ", ",
+		"open.done":                  "opened %s %s, leverage %dx, margin %.2fU, price %.2f, stop loss %.2f",
+		"open.take_profit":           ", take profit %.2f",
+		"open.take_profit_ladder":    ", take profit ladder %s",
+		"open.level_not_created":     " (not created)",
+		"open.auto_leverage":         " (auto leverage: requested %dx, using %dx, risk at the stop about %.2f%%)",
+		"open.confidence_scaled":     " (confidence %d/10, margin scaled by %.2f)",
+		"open.volatility_scaled":     " (%s ATR%d vs ATR%d ratio %.2f, volatility spike, margin scaled by %.2f)",
+		"open.partial_fill":          " (partial fill: ordered %v, filled %v, stop loss and take profit sized to the filled quantity)",
+		"open.slippage":              " (fill slippage %.3f%% exceeds %.3f%%)",
+		"open.slippage_stop_shifted": " (fill slippage %.3f%% exceeds %.3f%%, the stop loss was shifted with the fill to keep the planned risk)",
+		"open.stop_reset":            " (fill price %.2f crossed the planned stop, the stop loss was reset from the fill to %.2f)",
+		"open.take_profit_dropped":   " (take profit %.2f was already crossed by the fill price %.2f, no take profit order created)",
+		"open.levels_dropped":        " (%d take profit levels were already crossed by the fill price %.2f and not created, that quantity has no take profit)",
+		"klines.invalid_interval":    "unsupported kline interval %q, expected one of: %s",
+		"leverage.unchanged":         "%s leverage is already %dx, nothing to adjust",
+		"leverage.margin_short":      "lowering leverage to %dx needs %.2f USDT extra margin, only %.2f USDT available",
+		"leverage.adjusted":          "%s leverage adjusted from %dx to %dx, margin $%.2f",
+		"update.sl_kept":             "new stop loss %.4f failed (%v), the existing stop loss %.4f was kept, the position is still protected",
+		"update.sl_unprotected":      "new stop loss %.4f failed (%v), the position currently has NO stop loss, set a stop or close it immediately",
+		"update.tp_failed":           "new take profit %.4f failed (%v), the existing take profit was kept",
+		"update.sl_done":             "stop loss updated to %.4f",
+		"budget.tool_calls":          "the tool call limit of %d per decision has been reached, stop calling tools and summarize this decision",
+		"budget.opens":               "%d positions were already opened in this decision, the per-decision open limit is reached",
+		"budget.closes":              "%d positions were already closed in this decision, the per-decision close limit is reached",
+		"rollback.close_failed":      "stop loss order failed (%v) and the automatic close also failed (%v): the %s position has no stop protection, close it or set a stop immediately",
+		"rollback.closed":            "stop loss order failed (%v), the system closed the %s %s position (pnl %.2f USDT), this open did not take effect",
+		"rollback.trade_reason":      "stop loss order failed, closed automatically by the system: %v",
+	},
+}
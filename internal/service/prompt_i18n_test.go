@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var formatVerbPattern = regexp.MustCompile(`%[+\-#0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+func TestPromptMessagesConsistent(t *testing.T) {
+	zh := promptMessages[PromptLanguageZh]
+	en := promptMessages[PromptLanguageEn]
+
+	for key, zhText := range zh {
+		enText, ok := en[key]
+		if !ok {
+			t.Errorf("key %q missing in en", key)
+			continue
+		}
+		zhVerbs := formatVerbPattern.FindAllString(zhText, -1)
+		enVerbs := formatVerbPattern.FindAllString(enText, -1)
+		if !reflect.DeepEqual(zhVerbs, enVerbs) {
+			t.Errorf("key %q verbs differ: zh %q, en %q", key, zhVerbs, enVerbs)
+		}
+	}
+	for key := range en {
+		if _, ok := zh[key]; !ok {
+			t.Errorf("key %q missing in zh", key)
+		}
+	}
+}
+
+func TestLocalizeFallback(t *testing.T) {
+	if got := localize("", "market.title"); got != promptMessages[PromptLanguageZh]["market.title"] {
+		t.Fatalf("empty language should fall back to zh, got %q", got)
+	}
+	if got := localize("EN", "market.title"); got != promptMessages[PromptLanguageEn]["market.title"] {
+		t.Fatalf("EN should select english, got %q", got)
+	}
+	if got := localize(PromptLanguageEn, "no.such.key"); got != "no.such.key" {
+		t.Fatalf("unknown key should be returned as-is, got %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// PromptPlaceholder 系统提示词中可用的占位符
+type PromptPlaceholder struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+
+	value func(cfg *models.TradingConfig) string
+}
+
+// systemPromptPlaceholders 系统提示词支持的全部占位符，渲染与保存校验均以此为准
+var systemPromptPlaceholders = []PromptPlaceholder{
+	{
+		Key:         "max_drawdown_percent",
+		Description: "最大允许回撤百分比",
+		value:       func(cfg *models.TradingConfig) string { return formatPromptFloat(cfg.MaxDrawdownPercent) },
+	},
+	{
+		Key:         "forced_flat_percent",
+		Description: "强制平仓回撤阈值，等于最大回撤 + 5",
+		value: func(cfg *models.TradingConfig) string {
+			return formatPromptFloat(forcedFlatDrawdownPercent(cfg.MaxDrawdownPercent))
+		},
+	},
+	{
+		Key:         "max_holding_hours",
+		Description: "最长持仓小时数，到期由系统强制平仓，0表示不限制",
+		value:       func(cfg *models.TradingConfig) string { return fmt.Sprintf("%d", cfg.MaxHoldingHours) },
+	},
+	{
+		Key:         "max_positions",
+		Description: "允许的最大持仓数量",
+		value:       func(cfg *models.TradingConfig) string { return fmt.Sprintf("%d", cfg.MaxPositions) },
+	},
+	{
+		Key:         "min_leverage",
+		Description: "最小杠杆倍数",
+		value:       func(cfg *models.TradingConfig) string { return fmt.Sprintf("%d", cfg.MinLeverage) },
+	},
+	{
+		Key:         "max_leverage",
+		Description: "最大杠杆倍数",
+		value:       func(cfg *models.TradingConfig) string { return fmt.Sprintf("%d", cfg.MaxLeverage) },
+	},
+	{
+		Key:         "interval_minutes",
+		Description: "交易周期间隔（分钟）",
+		value:       func(cfg *models.TradingConfig) string { return fmt.Sprintf("%d", cfg.IntervalMinutes) },
+	},
+}
+
+var placeholderPattern = regexp.MustCompile(`{{([^{}]*)}}`)
+
+// UnknownPlaceholdersError 提示词中包含未知占位符
+type UnknownPlaceholdersError struct {
+	Unknown []string
+}
+
+func (e *UnknownPlaceholdersError) Error() string {
+	return fmt.Sprintf("系统提示词包含未知占位符: %s", strings.Join(e.Unknown, ", "))
+}
+
+// SystemPromptPlaceholders 返回系统提示词支持的占位符列表
+func SystemPromptPlaceholders() []PromptPlaceholder {
+	return systemPromptPlaceholders
+}
+
+// buildPromptReplacements 根据交易配置生成占位符替换表
+func buildPromptReplacements(cfg *models.TradingConfig) map[string]interface{} {
+	replacements := make(map[string]interface{}, len(systemPromptPlaceholders))
+	for _, p := range systemPromptPlaceholders {
+		replacements[p.Key] = p.value(cfg)
+	}
+	return replacements
+}
+
+// validatePromptPlaceholders 校验提示词中的 {{...}} 占位符均为已知占位符
+func validatePromptPlaceholders(content string) error {
+	known := make(map[string]bool, len(systemPromptPlaceholders))
+	for _, p := range systemPromptPlaceholders {
+		known[p.Key] = true
+	}
+
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		// fasttemplate 按原样匹配标签，带空格的占位符同样无法被替换
+		key := match[1]
+		if known[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unknown = append(unknown, key)
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &UnknownPlaceholdersError{Unknown: unknown}
+	}
+	return nil
+}
+
+func formatPromptFloat(val float64) string {
+	str := fmt.Sprintf("%.2f", val)
+	str = strings.TrimRight(str, "0")
+	str = strings.TrimRight(str, ".")
+	if str == "" {
+		return "0"
+	}
+	return str
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatePromptPlaceholders(t *testing.T) {
+	if err := validatePromptPlaceholders(defaultSystemPrompt.Content); err != nil {
+		t.Fatalf("default prompt should be valid: %v", err)
+	}
+
+	err := validatePromptPlaceholders("最多 {{max_positions}} 个仓位，杠杆 {{max_leverge}}，回撤 {{ max_drawdown_percent }}，{{max_leverge}}")
+	var placeholderErr *UnknownPlaceholdersError
+	if !errors.As(err, &placeholderErr) {
+		t.Fatalf("expected UnknownPlaceholdersError, got %v", err)
+	}
+	want := []string{" max_drawdown_percent ", "max_leverge"}
+	if !reflect.DeepEqual(placeholderErr.Unknown, want) {
+		t.Fatalf("unknown = %q, want %q", placeholderErr.Unknown, want)
+	}
+}
+
+// 文档列出的占位符必须与渲染器支持的完全一致，照着文档写的提示词不能被保存校验拒绝
+func TestPromptPlaceholderDocMatches(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/system_prompt_variables.md")
+	if err != nil {
+		t.Fatalf("read doc: %v", err)
+	}
+	if err := validatePromptPlaceholders(string(doc)); err != nil {
+		t.Fatalf("doc lists unsupported placeholders: %v", err)
+	}
+	for _, p := range systemPromptPlaceholders {
+		if !strings.Contains(string(doc), "{{"+p.Key+"}}") {
+			t.Errorf("placeholder %s is not documented", p.Key)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	_ "embed"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dushixiang/prism/internal/config"
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/repo"
 	"github.com/valyala/fasttemplate"
@@ -17,27 +19,58 @@ import (
 type PromptService struct {
 	tradeRepo          *repo.TradeRepo
 	orderRepo          *repo.OrderRepo
+	positionRepo       *repo.PositionRepo
 	adminConfigService *AdminConfigService
+	language           string                       // 提示词语言
+	trendingRegimeOnly bool                         // 是否只允许在趋势行情中开仓
+	blockUnconfirmed   bool                         // 是否拒绝顺着未确认突破方向开仓
+	volatilityGuard    string                       // 波动异常放大时开仓的处理方式
+	location           *time.Location               // 提示词中展示时间所用的时区
+	healthWeights      config.PositionHealthWeights // 持仓健康分权重
+	maxSymbols         int                          // 行情部分最多展示的交易对数量，0表示不限制
+	maxFundingPercent  float64                      // 累计资金费支出占保证金的上限（%），0表示不检查
 }
 
 // NewPromptService 创建提示词服务
-func NewPromptService(tradeRepo *repo.TradeRepo, orderRepo *repo.OrderRepo, adminConfigService *AdminConfigService) *PromptService {
+func NewPromptService(tradeRepo *repo.TradeRepo, orderRepo *repo.OrderRepo, positionRepo *repo.PositionRepo, adminConfigService *AdminConfigService, conf *config.Config) *PromptService {
 	return &PromptService{
 		tradeRepo:          tradeRepo,
 		orderRepo:          orderRepo,
+		positionRepo:       positionRepo,
 		adminConfigService: adminConfigService,
+		language:           normalizePromptLanguage(conf.LLM.PromptLanguage),
+		trendingRegimeOnly: conf.Trading.TrendingRegimeOnly,
+		blockUnconfirmed:   conf.Trading.BlockUnconfirmedOpens,
+		volatilityGuard:    normalizeVolatilitySpikeGuard(conf.Trading.VolatilitySpikeGuard),
+		location:           conf.DisplayLocation(),
+		healthWeights:      conf.Trading.PositionHealthWeights,
+		maxSymbols:         conf.Trading.PromptMaxSymbols,
+		maxFundingPercent:  conf.Trading.MaxCumulativeFundingPercent,
 	}
 }
 
+// text 返回当前提示词语言的文本
+func (s *PromptService) text(key string) string {
+	return localize(s.language, key)
+}
+
+// textf 格式化当前提示词语言的文本
+func (s *PromptService) textf(key string, args ...interface{}) string {
+	return localizef(s.language, key, args...)
+}
+
 // PromptData 提示词数据
 type PromptData struct {
-	StartTime      time.Time
-	Iteration      int
-	AccountMetrics *AccountMetrics
-	MarketDataMap  map[string]*MarketData
-	Positions      []models.Position // 持仓列表（值切片）
-	RecentTrades   []models.Trade    // 最近交易（值切片）
-	ActiveOrders   []models.Order    // 活跃的限价订单（值切片）
+	StartTime       time.Time
+	Iteration       int
+	AccountMetrics  *AccountMetrics
+	MarketDataMap   map[string]*MarketData
+	Positions       []models.Position  // 持仓列表（值切片）
+	PositionFunding map[string]float64 // 各持仓开仓以来的累计资金费，键为持仓ID
+	RecentTrades    []models.Trade     // 最近交易（值切片）
+	ActiveOrders    []models.Order     // 活跃的限价订单（值切片）
+	CloseOnly       bool               // 只平仓模式，禁止新开仓
+	OpenWindow      *OpenWindowUsage   // 滚动窗口内的开仓次数，nil表示不限制
 }
 
 // GeneratePrompt 生成完整的AI提示词
@@ -57,23 +90,53 @@ func (s *PromptService) GeneratePrompt(ctx context.Context, data *PromptData) st
 
 	s.writeConversationContext(&sb, data)
 
-	s.writeMarketOverview(&sb, data.MarketDataMap)
+	s.writeMarketOverview(&sb, data.MarketDataMap, data.Positions)
 
 	s.writeAccountInfo(&sb, data.AccountMetrics, tradingConfig)
 
-	s.writePositionInfo(&sb, data.Positions, data.AccountMetrics, tradingConfig)
+	s.writePositionInfo(&sb, data.Positions, data.AccountMetrics, tradingConfig, data.MarketDataMap, data.PositionFunding)
+
+	s.writeOpenWindow(&sb, data.OpenWindow)
+
+	s.writeLeverageCaps(&sb, tradingConfig)
 
 	s.writeActiveOrders(&sb, data.ActiveOrders, data.Positions, data.MarketDataMap)
 
-	s.writeTradeHistory(&sb, data.RecentTrades)
+	s.writeTradeHistory(&sb, data.RecentTrades, s.loadTradePositions(ctx, data.RecentTrades))
 
 	return sb.String()
 }
 
+// tradePlanRecentCloses 交易历史中只为最近的几笔平仓附带开仓理由和退出计划，避免提示词过长
+const tradePlanRecentCloses = 5
+
+// loadTradePositions 加载最近几笔平仓交易对应的持仓记录（用于展示当时的开仓理由和退出计划），trades 按时间倒序
+func (s *PromptService) loadTradePositions(ctx context.Context, trades []models.Trade) map[string]models.Position {
+	ids := make([]string, 0, tradePlanRecentCloses)
+	for i := range trades {
+		if trades[i].Type == "close" && trades[i].PositionID != "" && len(ids) < tradePlanRecentCloses {
+			ids = append(ids, trades[i].PositionID)
+		}
+	}
+
+	result := make(map[string]models.Position, len(ids))
+	if len(ids) == 0 {
+		return result
+	}
+
+	positions, err := s.positionRepo.FindByIDsUnscoped(ctx, ids)
+	if err != nil {
+		return result
+	}
+	for _, pos := range positions {
+		result[pos.ID] = pos
+	}
+	return result
+}
+
 // writeConversationContext 写入通话背景
 func (s *PromptService) writeConversationContext(sb *strings.Builder, data *PromptData) {
-	now := time.Now().In(time.FixedZone("CST", 8*3600))
-	currentTime := now.Format("2006-01-02 15:04:05")
+	currentTime := time.Now().In(s.location).Format("2006-01-02 15:04:05 MST")
 
 	var minutesElapsed float64
 	// 使用第一笔交易时间作为起始时间，如果没有交易则使用启动时间
@@ -92,24 +155,84 @@ func (s *PromptService) writeConversationContext(sb *strings.Builder, data *Prom
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf("**时间**: %s | **周期**: #%d | **运行**: %.0f分钟\n\n",
-		currentTime, data.Iteration, minutesElapsed))
+	sb.WriteString(s.textf("context.header", currentTime, data.Iteration, minutesElapsed))
+	if data.CloseOnly {
+		sb.WriteString(s.text("context.close_only"))
+	}
+}
+
+// writeKeyLevels 写入关键支撑/阻力，距离以相对当前价格的百分比和1h ATR倍数表示
+func (s *PromptService) writeKeyLevels(sb *strings.Builder, data *MarketData, price func(float64) string) {
+	if len(data.Supports) == 0 && len(data.Resistances) == 0 {
+		return
+	}
+	atr := 0.0
+	if ind, ok := data.Timeframes["1h"]; ok {
+		atr = ind.ATRSlow
+	}
+	distance := func(level PriceLevel) (float64, string) {
+		percent := (level.Price - data.CurrentPrice) / data.CurrentPrice * 100
+		if atr <= 0 {
+			return percent, ""
+		}
+		return percent, s.textf("market.level_atr", (level.Price-data.CurrentPrice)/atr)
+	}
+
+	sb.WriteString(s.text("market.key_levels"))
+	for _, level := range data.Resistances {
+		percent, atrStr := distance(level)
+		sb.WriteString(s.textf("market.resistance", price(level.Price), percent, atrStr, level.Touches))
+	}
+	for _, level := range data.Supports {
+		percent, atrStr := distance(level)
+		sb.WriteString(s.textf("market.support", price(level.Price), percent, atrStr, level.Touches))
+	}
+}
+
+// writeBreakout 写入突破的确认情况，区间内没有突破时不展示
+func (s *PromptService) writeBreakout(sb *strings.Builder, breakout *SignalConfirmation, price func(float64) string) {
+	if breakout == nil || breakout.Direction == BreakoutNone {
+		return
+	}
+	direction := s.text("breakout." + breakout.Direction)
+	if breakout.Confirmed() {
+		sb.WriteString(s.textf("breakout.confirmed", breakout.Timeframe, direction, price(breakout.Level), breakout.Candles, breakout.Required))
+		return
+	}
+	sb.WriteString(s.textf("breakout.unconfirmed", breakout.Timeframe, direction, price(breakout.Level), breakout.Candles, breakout.Required))
+	if s.blockUnconfirmed {
+		sb.WriteString(s.text("breakout.blocked"))
+	}
+}
+
+// writeVolatilitySpike 写入波动异常放大的提示，未达到阈值时不展示
+func (s *PromptService) writeVolatilitySpike(sb *strings.Builder, spike *VolatilitySpike) {
+	if !spike.Active() {
+		return
+	}
+	sb.WriteString(s.textf("volatility.spike", spike.Timeframe, spike.FastPeriod, spike.SlowPeriod, spike.Ratio, spike.Threshold))
+	if s.volatilityGuard != VolatilitySpikeGuardOff {
+		sb.WriteString(s.text("volatility." + s.volatilityGuard))
+	}
 }
 
 // writeMarketOverview 写入市场数据
-func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap map[string]*MarketData) {
-	sb.WriteString("## 市场全景\n\n")
+func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap map[string]*MarketData, positions []models.Position) {
+	sb.WriteString(s.text("market.title"))
 
 	if len(marketDataMap) == 0 {
-		sb.WriteString("暂无可用的市场数据。\n\n")
+		sb.WriteString(s.text("market.empty"))
 		return
 	}
 
-	symbols := make([]string, 0, len(marketDataMap))
-	for symbol := range marketDataMap {
-		symbols = append(symbols, symbol)
+	held := make(map[string]bool, len(positions))
+	for i := range positions {
+		held[positions[i].Symbol] = true
+	}
+	symbols, omitted := rankPromptSymbols(marketDataMap, held, s.maxSymbols)
+	if len(omitted) > 0 {
+		sb.WriteString(s.textf("market.omitted", len(omitted), strings.Join(omitted, ", ")))
 	}
-	sort.Strings(symbols)
 
 	for _, symbol := range symbols {
 		data := marketDataMap[symbol]
@@ -119,19 +242,27 @@ func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap m
 
 		// 根据价格确定精度
 		pricePrecision := getPricePrecision(data.CurrentPrice)
-		priceFormat := fmt.Sprintf("%%.%df", pricePrecision)
+		price := func(v float64) string { return formatFixed(v, pricePrecision) }
 
 		sb.WriteString(fmt.Sprintf("### %s\n", symbol))
 
-		sb.WriteString(fmt.Sprintf("💰 $"+priceFormat+" | 📊 资金费率 %.4f%%\n",
-			data.CurrentPrice, data.FundingRate*100))
+		sb.WriteString(s.textf("market.price_funding", price(data.CurrentPrice), data.FundingRate*100))
 		if data.RecentHigh > 0 && data.RecentLow > 0 {
-			sb.WriteString(fmt.Sprintf("**24h高低点**: $"+priceFormat+" / $"+priceFormat+"\n", data.RecentHigh, data.RecentLow))
+			sb.WriteString(s.textf("market.high_low", price(data.RecentHigh), price(data.RecentLow)))
+		}
+		if data.Regime != "" {
+			sb.WriteString(s.textf("market.regime", s.text("regime."+string(data.Regime)), data.RegimeADX))
+			if s.trendingRegimeOnly && data.Regime != MarketRegimeTrending {
+				sb.WriteString(s.text("market.regime_blocked"))
+			}
 		}
+		s.writeBreakout(sb, data.Breakout, price)
+		s.writeVolatilitySpike(sb, data.Volatility)
+		s.writeKeyLevels(sb, data, price)
 		sb.WriteString("\n")
 
 		// 多时间框架指标（紧凑格式）
-		sb.WriteString("**多周期指标**\n")
+		sb.WriteString(s.text("market.timeframes"))
 		timeframes := []string{"15m", "30m", "1h"}
 		for _, tf := range timeframes {
 			if ind, ok := data.Timeframes[tf]; ok {
@@ -147,31 +278,31 @@ func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap m
 					macdPrecision = 6
 				}
 
-				// ⭐ 计算价格与 EMA20 的偏离度（建议1）
+				// ⭐ 计算价格与快速EMA的偏离度（建议1）
 				var emaDeviation float64
 				var emaDeviationStr string
-				if ind.EMA20 > 0 {
-					emaDeviation = (ind.Price - ind.EMA20) / ind.EMA20 * 100
-					emaDeviationStr = fmt.Sprintf(" 偏离EMA20 %+.2f%%", emaDeviation)
+				if ind.EMAFast > 0 {
+					emaDeviation = (ind.Price - ind.EMAFast) / ind.EMAFast * 100
+					emaDeviationStr = s.textf("market.ema_deviation", ind.Params.EMAFast, emaDeviation)
 				}
 
 				// 计算成交量比率（客观数据）
 				var volumeRatioStr string
 				if ind.AvgVolume > 0 {
 					volumeRatio := ind.Volume / ind.AvgVolume
-					volumeRatioStr = fmt.Sprintf(" (%.2fx均值)", volumeRatio)
+					volumeRatioStr = s.textf("market.volume_ratio", volumeRatio)
 				}
 
 				// 使用新的多行格式
 				sb.WriteString(fmt.Sprintf("- %s:\n", tf))
-				sb.WriteString(fmt.Sprintf("  - 价格: $"+priceFormat+"%s\n", ind.Price, emaDeviationStr))
-				sb.WriteString(fmt.Sprintf("  - 均线: EMA20=$"+priceFormat+" / EMA50=$"+priceFormat+"\n", ind.EMA20, ind.EMA50))
-				sb.WriteString(fmt.Sprintf("  - 布林带: U=$"+priceFormat+" M=$"+priceFormat+" L=$"+priceFormat+"\n", ind.BBandsUpper, ind.BBandsMiddle, ind.BBandsLower))
-
-				formatStr := fmt.Sprintf("  - 指标: MACD=%%.%df | RSI14=%%.1f | ATR14=%%.%df\n", macdPrecision, atrPrecision)
-				sb.WriteString(fmt.Sprintf(formatStr, ind.MACD, ind.RSI14, ind.ATR14))
-
-				sb.WriteString(fmt.Sprintf("  - 成交量: %s (均值: %s)%s\n",
+				sb.WriteString(s.textf("market.tf_price", price(ind.Price), emaDeviationStr))
+				sb.WriteString(s.textf("market.tf_ema",
+					ind.Params.EMAFast, price(ind.EMAFast), ind.Params.EMASlow, price(ind.EMASlow)))
+				sb.WriteString(s.textf("market.tf_bbands", price(ind.BBandsUpper), price(ind.BBandsMiddle), price(ind.BBandsLower)))
+				sb.WriteString(s.textf("market.tf_indicators",
+					formatFixed(ind.MACD, macdPrecision), ind.Params.RSISlow, ind.RSISlow,
+					ind.Params.ATRSlow, formatFixed(ind.ATRSlow, atrPrecision)))
+				sb.WriteString(s.textf("market.tf_volume",
 					formatVolume(ind.Volume), formatVolume(ind.AvgVolume), volumeRatioStr))
 			}
 		}
@@ -204,9 +335,9 @@ func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap m
 				}
 				volatility := (highPrice - lowPrice) / lowPrice * 100
 
-				sb.WriteString(fmt.Sprintf("**价格走势 (15m周期, %.1f小时)**: ", hours))
-				sb.WriteString(fmt.Sprintf("起 "+priceFormat+" → 终 "+priceFormat+" (%+.2f%%) | 区间 ["+priceFormat+"-"+priceFormat+"] 波幅%.2f%%\n",
-					startPrice, endPrice, priceChange, lowPrice, highPrice, volatility))
+				sb.WriteString(s.textf("market.intraday", hours))
+				sb.WriteString(s.textf("market.intraday_range",
+					price(startPrice), price(endPrice), priceChange, price(lowPrice), price(highPrice), volatility))
 
 				// 只显示最近16根K线的收盘价（约4小时），用于观察短期趋势
 				recentCount := 16
@@ -214,50 +345,53 @@ func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap m
 					recentCount = count
 				}
 				recentCloses := closes[count-recentCount:]
-				sb.WriteString(fmt.Sprintf("- 近期收盘价(最近%d根): %s\n",
-					recentCount, formatPriceArray(recentCloses)))
+				sb.WriteString(s.textf("market.recent_closes", recentCount, formatPriceArray(recentCloses)))
 			}
 			sb.WriteString("\n")
 		}
 
 		// 1小时趋势
 		if data.LongerTermData != nil {
-			sb.WriteString("**1小时趋势**\n")
+			sb.WriteString(s.text("market.h1_title"))
 
 			// 1小时均线结构（客观描述）
 			var trendDesc string
 			if ind1h, ok := data.Timeframes["1h"]; ok && ind1h.Price > 0 {
-				strength := (ind1h.EMA20 - ind1h.EMA50) / ind1h.Price * 100
-				adx := ind1h.ADX14
+				strength := (ind1h.EMAFast - ind1h.EMASlow) / ind1h.Price * 100
+				adx := ind1h.ADX
+				emaFast, emaSlow := ind1h.Params.EMAFast, ind1h.Params.EMASlow
 
 				// 均线位置关系（客观描述）
 				var emaRelation string
 				if strength > 0.05 { // 增加一个小的阈值避免过于频繁的波动
-					emaRelation = "EMA20 在 EMA50 上方"
+					emaRelation = s.textf("market.ema_above", emaFast, emaSlow)
 				} else if strength < -0.05 {
-					emaRelation = "EMA20 在 EMA50 下方"
+					emaRelation = s.textf("market.ema_below", emaFast, emaSlow)
 				} else {
-					emaRelation = "EMA20 与 EMA50 接近"
+					emaRelation = s.textf("market.ema_near", emaFast, emaSlow)
 				}
 
-				trendDesc = fmt.Sprintf("- **1h 均线关系**: %s | **ADX14**: %.1f", emaRelation, adx)
+				trendDesc = s.textf("market.h1_ema_relation", emaRelation, ind1h.Params.ADX, adx)
 				sb.WriteString(trendDesc + "\n")
-				sb.WriteString(fmt.Sprintf("- **均线偏离度**: %.2f%% (EMA20 vs EMA50)\n", strength))
+				sb.WriteString(s.textf("market.h1_ema_strength", strength, emaFast, emaSlow))
 			}
 
 			// 波动率和成交量状态（客观描述）
-			atrStatus := translateStatus(data.LongerTermData.ATR3vsATR14, "ATR3", "ATR14", "高于", "低于", "等于")
-			volStatus := translateStatus(data.LongerTermData.VolumeVsAvg, "当前成交量", "均值", "高于", "低于", "等于")
+			above, below, equal := s.text("market.status_above"), s.text("market.status_below"), s.text("market.status_equal")
+			params := data.LongerTermData.Params
+			atrStatus := translateStatus(data.LongerTermData.ATRFastVsSlow,
+				fmt.Sprintf("ATR%d", params.ATRFast), fmt.Sprintf("ATR%d", params.ATRSlow), above, below, equal)
+			volStatus := translateStatus(data.LongerTermData.VolumeVsAvg, s.text("market.current_volume"), s.text("market.avg_volume"), above, below, equal)
 
-			sb.WriteString(fmt.Sprintf("- 波动与成交量: %s | %s\n", atrStatus, volStatus))
+			sb.WriteString(s.textf("market.volatility", atrStatus, volStatus))
 
 			// 1小时序列数据（最近10点）
-			if len(data.LongerTermData.MACDSeries) > 0 || len(data.LongerTermData.RSI14Series) > 0 {
-				sb.WriteString("- MACD序列: ")
+			if len(data.LongerTermData.MACDSeries) > 0 || len(data.LongerTermData.RSISlowSeries) > 0 {
+				sb.WriteString(s.text("market.macd_series"))
 				sb.WriteString(formatFloatArray(data.LongerTermData.MACDSeries))
 				sb.WriteString("\n")
-				sb.WriteString("- RSI14序列: ")
-				sb.WriteString(formatFloatArray(data.LongerTermData.RSI14Series))
+				sb.WriteString(s.textf("market.rsi_series", params.RSISlow))
+				sb.WriteString(formatFloatArray(data.LongerTermData.RSISlowSeries))
 				sb.WriteString("\n")
 			}
 			sb.WriteString("\n")
@@ -267,10 +401,10 @@ func (s *PromptService) writeMarketOverview(sb *strings.Builder, marketDataMap m
 
 // writeAccountInfo 写入账户信息
 func (s *PromptService) writeAccountInfo(sb *strings.Builder, metrics *AccountMetrics, tradingConfig *models.TradingConfig) {
-	sb.WriteString("## 账户状态\n\n")
+	sb.WriteString(s.text("account.title"))
 
 	if metrics == nil {
-		sb.WriteString("暂无账户数据。\n\n")
+		sb.WriteString(s.text("account.empty"))
 		return
 	}
 
@@ -290,35 +424,47 @@ func (s *PromptService) writeAccountInfo(sb *strings.Builder, metrics *AccountMe
 	}
 
 	drawdownWarn := tradingConfig.MaxDrawdownPercent
-	forcedFlat := tradingConfig.MaxDrawdownPercent + 5
+	forcedFlat := forcedFlatDrawdownPercent(tradingConfig.MaxDrawdownPercent)
+	drawdown := -metrics.DrawdownFromPeak // DrawdownFromPeak 为负数百分比
 
 	// 资金情况
-	sb.WriteString(fmt.Sprintf("**资金**: 净值 $%.2f (初始$%.2f, 峰值$%.2f) | 可用 $%.2f (%.1f%%)\n",
+	sb.WriteString(s.textf("account.funds",
 		metrics.TotalBalance,
 		metrics.InitialBalance,
 		metrics.PeakBalance,
 		metrics.Available,
 		availablePercent))
+	sb.WriteString(s.textf("account.capital", metrics.MarginUsed, metrics.CapitalUtilization))
 
 	// 收益与风险
 	returnEmoji := "📈"
 	if metrics.ReturnPercent < 0 {
 		returnEmoji = "📉"
 	}
-	sb.WriteString(fmt.Sprintf("**收益**: %s %+.2f%% | 未实现盈亏 $%+.2f\n",
+	sb.WriteString(s.textf("account.returns",
 		returnEmoji,
 		metrics.ReturnPercent,
-		metrics.UnrealisedPnl))
+		metrics.UnrealisedPnl,
+		metrics.CumulativeFunding))
+
+	// 组合层面的止损风险：所有持仓同时触及止损时的合计亏损
+	if metrics.TotalStopRisk > 0 || metrics.UnprotectedCount > 0 {
+		unprotectedNote := ""
+		if metrics.UnprotectedCount > 0 {
+			unprotectedNote = s.textf("account.unprotected", metrics.UnprotectedCount)
+		}
+		sb.WriteString(s.textf("account.stop_risk", metrics.TotalStopRisk, metrics.TotalStopRiskPct, unprotectedNote))
+	}
 
 	// 回撤与夏普比率
 	drawdownEmoji := "✅"
 	riskNote := ""
-	if forcedFlat > 0 && metrics.DrawdownFromPeak >= forcedFlat {
+	if drawdownWarn > 0 && drawdown >= forcedFlat {
 		drawdownEmoji = "🔴"
-		riskNote = fmt.Sprintf(" | 已达到强制清仓阈值%s%%（系统规则）", formatPercent(forcedFlat))
-	} else if drawdownWarn > 0 && metrics.DrawdownFromPeak >= drawdownWarn {
+		riskNote = s.textf("account.forced_flat", formatPercent(forcedFlat))
+	} else if drawdownWarn > 0 && drawdown >= drawdownWarn {
 		drawdownEmoji = "⚠️"
-		riskNote = fmt.Sprintf(" | 已达到警戒线%s%%（系统规则）", formatPercent(drawdownWarn))
+		riskNote = s.textf("account.drawdown_warn", formatPercent(drawdownWarn))
 	}
 
 	sharpeEmoji := "📊"
@@ -332,7 +478,7 @@ func (s *PromptService) writeAccountInfo(sb *strings.Builder, metrics *AccountMe
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf("**风险**: %s 回撤 %.2f%%(峰值) / %.2f%%(初始) | %s 夏普比率 %s%s\n\n",
+	sb.WriteString(s.textf("account.risk",
 		drawdownEmoji,
 		metrics.DrawdownFromPeak,
 		metrics.DrawdownFromInitial,
@@ -342,18 +488,18 @@ func (s *PromptService) writeAccountInfo(sb *strings.Builder, metrics *AccountMe
 }
 
 // writePositionInfo 写入持仓信息
-func (s *PromptService) writePositionInfo(sb *strings.Builder, positions []models.Position, metrics *AccountMetrics, tradingConfig *models.TradingConfig) {
+func (s *PromptService) writePositionInfo(sb *strings.Builder, positions []models.Position, metrics *AccountMetrics, tradingConfig *models.TradingConfig, marketDataMap map[string]*MarketData, positionFunding map[string]float64) {
 	maxPositions := tradingConfig.MaxPositions
 	currentCount := len(positions)
 
-	sb.WriteString("## 当前持仓\n\n")
+	sb.WriteString(s.text("position.title"))
 
 	if currentCount > 0 {
-		sb.WriteString(fmt.Sprintf("**持仓: %d/%d**\n\n", currentCount, maxPositions))
+		sb.WriteString(s.textf("position.count", currentCount, maxPositions))
 	}
 
 	if len(positions) == 0 {
-		sb.WriteString(fmt.Sprintf("当前无持仓，最多可开 %d 个仓位\n\n", maxPositions))
+		sb.WriteString(s.textf("position.empty", maxPositions))
 	} else {
 		for i := range positions {
 			pos := &positions[i] // 取地址以便调用方法
@@ -361,24 +507,28 @@ func (s *PromptService) writePositionInfo(sb *strings.Builder, positions []model
 			holding := pos.CalculateHoldingStr()
 
 			pricePrecision := getPricePrecision(pos.CurrentPrice)
-			priceFormat := fmt.Sprintf("%%.%df", pricePrecision)
+			price := func(v float64) string { return formatFixed(v, pricePrecision) }
 
 			sb.WriteString(fmt.Sprintf("### %d. %s %s\n", i+1, pos.Symbol, strings.ToUpper(pos.Side)))
+			if pos.IsFrozen() {
+				sb.WriteString(s.textf("position.frozen", pos.DelistedStatus))
+			}
+			if pos.EntrySlippagePercent != 0 {
+				sb.WriteString(s.textf("position.entry_slippage", pos.EntrySlippagePercent))
+			}
 
 			// 基本信息
-			sb.WriteString(fmt.Sprintf("- 价格: 入场$"+priceFormat+" → 当前$"+priceFormat+"\n",
-				pos.EntryPrice, pos.CurrentPrice))
-			sb.WriteString(fmt.Sprintf("- 盈亏: $%+.2f (%+.2f%%)", pos.UnrealizedPnl, pnlPercent))
+			sb.WriteString(s.textf("position.price", price(pos.EntryPrice), price(pos.CurrentPrice)))
+			sb.WriteString(s.textf("position.pnl", pos.UnrealizedPnl, pnlPercent))
 
 			// 显示历史峰值盈亏（如果有）
 			if pos.PeakPnlPercent != 0 {
-				sb.WriteString(fmt.Sprintf(" | 峰值盈亏 %+.2f%%", pos.PeakPnlPercent))
+				sb.WriteString(s.textf("position.peak_pnl", pos.PeakPnlPercent))
 			}
 			sb.WriteString("\n")
 
 			// 杠杆和保证金
-			sb.WriteString(fmt.Sprintf("- 杠杆: %dx | 保证金: $%.2f | 数量: %.4f\n",
-				pos.Leverage, pos.Margin, pos.Quantity))
+			sb.WriteString(s.textf("position.leverage", pos.Leverage, pos.Margin, pos.Notional(), pos.Quantity))
 
 			// 强平价格和风险度
 			if pos.LiquidationPrice > 0 {
@@ -390,19 +540,79 @@ func (s *PromptService) writePositionInfo(sb *strings.Builder, positions []model
 						liquidationDistance = (pos.CurrentPrice - pos.LiquidationPrice) / pos.CurrentPrice * 100
 					}
 				}
-				sb.WriteString(fmt.Sprintf("- 强平价格: $"+priceFormat+" (距当前价格 %+.2f%%)\n",
-					pos.LiquidationPrice, liquidationDistance))
+				sb.WriteString(s.textf("position.liquidation", price(pos.LiquidationPrice), liquidationDistance))
 			}
 
-			// 持仓时间
-			sb.WriteString(fmt.Sprintf("- 持仓时间: %s\n\n", holding))
+			// 触及止损时的亏损
+			if stopRisk, ok := pos.StopRisk(); ok {
+				stopRiskPercent := 0.0
+				if metrics != nil && metrics.TotalBalance > 0 {
+					stopRiskPercent = stopRisk / metrics.TotalBalance * 100
+				}
+				sb.WriteString(s.textf("position.stop_risk", price(pos.StopLoss), stopRisk, stopRiskPercent))
+			} else {
+				sb.WriteString(s.text("position.no_stop"))
+			}
+
+			// 预估资金费（按当前费率估算下一次结算）
+			if data, ok := marketDataMap[pos.Symbol]; ok && data != nil && data.FundingRate != 0 {
+				sb.WriteString(s.textf("position.funding",
+					estimatePositionFunding(pos, data.FundingRate), data.FundingRate*100))
+			}
+
+			// 开仓以来的累计资金费，支出超过上限时重点提示
+			if accrued, ok := positionFunding[pos.ID]; ok && accrued != 0 {
+				sb.WriteString(s.textf("position.accrued_funding", accrued, fundingCostPercent(accrued, pos.Margin)))
+				if fundingLimitExceeded(accrued, pos.Margin, s.maxFundingPercent) {
+					sb.WriteString(s.textf("position.funding_limit", fundingCostPercent(accrued, pos.Margin), s.maxFundingPercent))
+				}
+			}
+
+			// 持仓时间与距强制平仓的剩余时间
+			sb.WriteString(s.textf("position.holding", holding))
+			if remaining := pos.RemainingHoldingStr(tradingConfig.MaxHoldingHours); remaining != "" {
+				sb.WriteString(s.textf("position.deadline", remaining, tradingConfig.MaxHoldingHours))
+			}
+			sb.WriteString("\n")
+
+			// 健康分（越低越应优先减仓）
+			fundingRate := 0.0
+			if data, ok := marketDataMap[pos.Symbol]; ok && data != nil {
+				fundingRate = data.FundingRate
+			}
+			health := computePositionHealth(pos, tradingConfig.MaxHoldingHours, fundingRate, s.healthWeights, time.Now())
+			sb.WriteString(s.textf("position.health", health.Score,
+				health.Liquidation*100, health.Stop*100, health.Time*100, health.Giveback*100, health.Funding*100))
+
+			// 从当前价格看的期望值（按1小时ATR距离粗估触及止盈/止损的概率）
+			if data, ok := marketDataMap[pos.Symbol]; ok && data != nil {
+				if ind, ok := data.Timeframes["1h"]; ok && ind != nil {
+					if ev, ok := computePositionExpectedValue(pos, ind.ATRSlow, tradingConfig.MaxHoldingHours, time.Now()); ok {
+						sb.WriteString(s.textf("position.expected_value", ev.ExpectedValue,
+							ev.Reward, ev.RewardATR, ev.TakeProfitProbability*100,
+							ev.Risk, ev.RiskATR, ev.StopProbability*100,
+							ev.RewardRisk(), ev.HorizonHours))
+					}
+				}
+			}
+			sb.WriteString("\n")
 
 			// 开仓理由和退出计划
 			if strings.TrimSpace(pos.EntryReason) != "" {
-				sb.WriteString(fmt.Sprintf("**开仓理由**: %s\n\n", pos.EntryReason))
+				sb.WriteString(s.textf("position.entry_reason", pos.EntryReason))
 			}
 			if strings.TrimSpace(pos.ExitPlan) != "" {
-				sb.WriteString(fmt.Sprintf("**退出计划**: %s\n\n", pos.ExitPlan))
+				sb.WriteString(s.textf("position.exit_plan", pos.ExitPlan))
+			}
+			if pos.InvalidationPrice > 0 {
+				invalidationDistance := 0.0
+				if pos.CurrentPrice > 0 {
+					invalidationDistance = (pos.InvalidationPrice - pos.CurrentPrice) / pos.CurrentPrice * 100
+				}
+				sb.WriteString(s.textf("position.invalidation", price(pos.InvalidationPrice), invalidationDistance))
+				if pos.IsThesisInvalidated() {
+					sb.WriteString(s.text("position.invalidated"))
+				}
 			}
 
 			sb.WriteString("\n")
@@ -412,19 +622,50 @@ func (s *PromptService) writePositionInfo(sb *strings.Builder, positions []model
 	// 仓位容量信息
 	remainingSlots := maxPositions - currentCount
 	if remainingSlots > 0 && metrics != nil && metrics.Available > 0 {
-		sb.WriteString("## 仓位容量\n\n")
+		sb.WriteString(s.text("capacity.title"))
+
+		sb.WriteString(s.textf("capacity.slots", remainingSlots, maxPositions))
+		sb.WriteString(s.textf("capacity.available", metrics.Available))
+	}
+}
+
+// writeOpenWindow 写入滚动窗口内的开仓次数，便于AI分配有限的开仓机会
+func (s *PromptService) writeOpenWindow(sb *strings.Builder, usage *OpenWindowUsage) {
+	if usage == nil {
+		return
+	}
+	note := ""
+	if usage.Exhausted() {
+		note = s.textf("capacity.window_full", usage.untilReset(time.Now()))
+	}
+	sb.WriteString(s.textf("capacity.open_window", int(usage.Window.Hours()), usage.Count, usage.Limit, note))
+}
 
-		sb.WriteString(fmt.Sprintf("**剩余可开仓位**: %d个（最大%d个）\n", remainingSlots, maxPositions))
-		sb.WriteString(fmt.Sprintf("**当前可用余额**: $%.2f\n", metrics.Available))
+// writeLeverageCaps 写入按交易对单独配置的杠杆上限，未配置时不输出
+func (s *PromptService) writeLeverageCaps(sb *strings.Builder, tradingConfig *models.TradingConfig) {
+	if len(tradingConfig.SymbolMaxLeverage) == 0 {
+		return
 	}
+	symbols := make([]string, 0, len(tradingConfig.SymbolMaxLeverage))
+	for symbol := range tradingConfig.SymbolMaxLeverage {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	caps := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		maxLeverage, _ := tradingConfig.MaxLeverageFor(symbol)
+		caps = append(caps, fmt.Sprintf("%s %dx", symbol, maxLeverage))
+	}
+	sb.WriteString(s.textf("capacity.leverage_caps", strings.Join(caps, ", "), tradingConfig.MaxLeverage))
 }
 
 // writeActiveOrders 写入活跃的限价订单信息
 func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.Order, positions []models.Position, marketDataMap map[string]*MarketData) {
-	sb.WriteString("## 活跃限价单\n\n")
+	sb.WriteString(s.text("orders.title"))
 
 	if len(orders) == 0 {
-		sb.WriteString("当前无活跃限价单\n\n")
+		sb.WriteString(s.text("orders.empty"))
 		return
 	}
 
@@ -437,7 +678,7 @@ func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.O
 	}
 
 	if len(ordersByPosition) == 0 {
-		sb.WriteString("当前无活跃限价单\n\n")
+		sb.WriteString(s.text("orders.empty"))
 		return
 	}
 
@@ -461,7 +702,7 @@ func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.O
 			currentPrice = marketData.CurrentPrice
 		}
 
-		sb.WriteString(fmt.Sprintf("### 持仓#%d %s %s\n", posIdx, pos.Symbol, strings.ToUpper(pos.Side)))
+		sb.WriteString(s.textf("orders.position", posIdx, pos.Symbol, strings.ToUpper(pos.Side)))
 
 		// 分类订单
 		var stopLossOrders []models.Order
@@ -479,15 +720,13 @@ func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.O
 			for i := range stopLossOrders {
 				order := &stopLossOrders[i]
 				distance := order.CalculateDistancePercent(currentPrice)
-				createdTime := order.CreatedAt.Format("01-02 15:04")
-				pricePrecision := getPricePrecision(order.TriggerPrice)
-				priceFormat := fmt.Sprintf("%%.%df", pricePrecision)
+				createdTime := order.CreatedAt.In(s.location).Format("01-02 15:04")
+				triggerPrice := formatFixed(order.TriggerPrice, getPricePrecision(order.TriggerPrice))
 
-				sb.WriteString(fmt.Sprintf("- **止损**: $"+priceFormat+" (距当前价格 %+.2f%%) | 创建于 %s",
-					order.TriggerPrice, distance, createdTime))
+				sb.WriteString(s.textf("orders.stop_loss", triggerPrice, distance, createdTime))
 
 				if order.Reason != "" {
-					sb.WriteString(fmt.Sprintf(" | 原因: %s", order.Reason))
+					sb.WriteString(s.textf("orders.reason", order.Reason))
 				}
 				sb.WriteString("\n")
 			}
@@ -498,15 +737,13 @@ func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.O
 			for i := range takeProfitOrders {
 				order := &takeProfitOrders[i]
 				distance := order.CalculateDistancePercent(currentPrice)
-				createdTime := order.CreatedAt.Format("01-02 15:04")
-				pricePrecision := getPricePrecision(order.TriggerPrice)
-				priceFormat := fmt.Sprintf("%%.%df", pricePrecision)
+				createdTime := order.CreatedAt.In(s.location).Format("01-02 15:04")
+				triggerPrice := formatFixed(order.TriggerPrice, getPricePrecision(order.TriggerPrice))
 
-				sb.WriteString(fmt.Sprintf("- **止盈**: $"+priceFormat+" (距当前价格 %+.2f%%) | 创建于 %s",
-					order.TriggerPrice, distance, createdTime))
+				sb.WriteString(s.textf("orders.take_profit", triggerPrice, distance, createdTime))
 
 				if order.Reason != "" {
-					sb.WriteString(fmt.Sprintf(" | 原因: %s", order.Reason))
+					sb.WriteString(s.textf("orders.reason", order.Reason))
 				}
 				sb.WriteString("\n")
 			}
@@ -517,12 +754,12 @@ func (s *PromptService) writeActiveOrders(sb *strings.Builder, orders []models.O
 	}
 }
 
-// writeTradeHistory 写入交易历史
-func (s *PromptService) writeTradeHistory(sb *strings.Builder, trades []models.Trade) {
-	sb.WriteString("## 历史交易记录（最近20笔）\n\n")
+// writeTradeHistory 写入交易历史，trades 按时间倒序
+func (s *PromptService) writeTradeHistory(sb *strings.Builder, trades []models.Trade, tradePositions map[string]models.Position) {
+	sb.WriteString(s.textf("trades.title", len(trades)))
 
 	if len(trades) == 0 {
-		sb.WriteString("暂无交易记录\n\n")
+		sb.WriteString(s.text("trades.empty"))
 		return
 	}
 
@@ -544,33 +781,46 @@ func (s *PromptService) writeTradeHistory(sb *strings.Builder, trades []models.T
 	closedTrades := wins + losses
 	if closedTrades > 0 {
 		winRate := float64(wins) / float64(closedTrades) * 100
-		sb.WriteString(fmt.Sprintf("**统计**: 胜率 %.0f%% (%d胜/%d负) | 净盈亏 $%.2f | 累计手续费 $%.2f\n\n",
+		sb.WriteString(s.textf("trades.stats",
 			winRate, wins, losses, totalPnl, totalFees))
 	}
 
 	// 交易列表
+	planCloses := 0
 	for i := range trades {
 		trade := &trades[i]
-		pricePrecision := getPricePrecision(trade.Price)
-		priceFormat := fmt.Sprintf("%%.%df", pricePrecision)
-		sb.WriteString(fmt.Sprintf("%d. [%s] %s %s, 价格=$"+priceFormat+", 数量=%.4f, 杠杆=%dx, 手续费=$%.2f",
-			i+1, trade.ExecutedAt.Format("01-02 15:04"), trade.Type, trade.Symbol,
-			trade.Price, trade.Quantity, trade.Leverage, trade.Fee))
+		tradePrice := formatFixed(trade.Price, getPricePrecision(trade.Price))
+		sb.WriteString(s.textf("trades.item",
+			i+1, trade.ExecutedAt.In(s.location).Format("01-02 15:04"), trade.Type, trade.Symbol,
+			tradePrice, trade.Quantity, trade.Leverage, trade.Fee))
 
 		if trade.Type == "close" && trade.Pnl != 0 {
 			pnlSign := ""
 			if trade.Pnl > 0 {
 				pnlSign = "+"
 			}
-			sb.WriteString(fmt.Sprintf(", 盈亏=%s$%.2f", pnlSign, trade.Pnl))
+			sb.WriteString(s.textf("trades.pnl", pnlSign, trade.Pnl))
 		}
 
 		// 添加交易原因,帮助AI了解盈亏原因
 		if strings.TrimSpace(trade.Reason) != "" {
-			sb.WriteString(fmt.Sprintf(", 原因: %s", trade.Reason))
+			sb.WriteString(s.textf("trades.reason", trade.Reason))
 		}
 
 		sb.WriteString("\n")
+
+		// 最近几笔平仓交易附带当时的开仓理由和退出计划，便于AI复盘计划与执行是否一致
+		if trade.Type == "close" && planCloses < tradePlanRecentCloses {
+			planCloses++
+			if pos, ok := tradePositions[trade.PositionID]; ok {
+				if strings.TrimSpace(pos.EntryReason) != "" {
+					sb.WriteString(s.textf("trades.entry_reason", pos.EntryReason))
+				}
+				if strings.TrimSpace(pos.ExitPlan) != "" {
+					sb.WriteString(s.textf("trades.exit_plan", pos.ExitPlan))
+				}
+			}
+		}
 	}
 	sb.WriteString("\n")
 }
@@ -589,6 +839,11 @@ func getPricePrecision(avgPrice float64) int {
 	}
 }
 
+// formatFixed 按固定小数位格式化数值
+func formatFixed(v float64, precision int) string {
+	return strconv.FormatFloat(v, 'f', precision, 64)
+}
+
 // formatVolume 格式化成交量，使其更具可读性
 func formatVolume(vol float64) string {
 	switch {
@@ -601,7 +856,7 @@ func formatVolume(vol float64) string {
 	}
 }
 
-// translateStatus 将英文状态关键字翻译为更具可读性的中文
+// translateStatus 将英文状态关键字翻译为提示词语言的描述
 func translateStatus(status, v1, v2, above, below, equal string) string {
 	switch status {
 	case "above", "higher":
@@ -664,24 +919,7 @@ func (s *PromptService) GetSystemInstructions(ctx context.Context) (string, erro
 		return "", fmt.Errorf("failed to get trading config: %w", err)
 	}
 
-	formatFloat := func(val float64) string {
-		str := fmt.Sprintf("%.2f", val)
-		str = strings.TrimRight(str, "0")
-		str = strings.TrimRight(str, ".")
-		if str == "" {
-			return "0"
-		}
-		return str
-	}
-
-	replacements := map[string]interface{}{
-		"max_drawdown_percent": formatFloat(tradingConfig.MaxDrawdownPercent),
-		"forced_flat_percent":  formatFloat(tradingConfig.MaxDrawdownPercent + 5),
-		"max_positions":        fmt.Sprintf("%d", tradingConfig.MaxPositions),
-		"min_leverage":         fmt.Sprintf("%d", tradingConfig.MinLeverage),
-		"max_leverage":         fmt.Sprintf("%d", tradingConfig.MaxLeverage),
-		"interval_minutes":     fmt.Sprintf("%d", tradingConfig.IntervalMinutes),
-	}
+	replacements := buildPromptReplacements(tradingConfig)
 
 	tmpl := fasttemplate.New(prompt.Content, "{{", "}}")
 	return tmpl.ExecuteString(replacements), nil
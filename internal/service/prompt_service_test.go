@@ -0,0 +1,37 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// 交易历史只为最近几笔平仓附带开仓理由和退出计划
+func TestWriteTradeHistoryLimitsTradePlans(t *testing.T) {
+	s := &PromptService{language: PromptLanguageZh, location: time.UTC}
+	now := time.Now()
+	var trades []models.Trade
+	positions := map[string]models.Position{}
+	for i := 0; i < tradePlanRecentCloses+3; i++ {
+		id := fmt.Sprintf("pos-%d", i)
+		trades = append(trades, models.Trade{ID: fmt.Sprintf("t-%d", i), Type: "close", Symbol: "BTCUSDT", Price: 100,
+			Quantity: 1, Leverage: 5, Pnl: 1, PositionID: id, ExecutedAt: now.Add(-time.Duration(i) * time.Hour)})
+		positions[id] = models.Position{ID: id, EntryReason: fmt.Sprintf("entry-%d", i), ExitPlan: fmt.Sprintf("plan-%d", i)}
+	}
+
+	var sb strings.Builder
+	s.writeTradeHistory(&sb, trades, positions)
+	prompt := sb.String()
+	for i := range trades {
+		want := i < tradePlanRecentCloses
+		if got := strings.Contains(prompt, fmt.Sprintf("entry-%d", i)); got != want {
+			t.Fatalf("trade %d entry reason shown = %v, want %v", i, got, want)
+		}
+		if got := strings.Contains(prompt, fmt.Sprintf("plan-%d", i)); got != want {
+			t.Fatalf("trade %d exit plan shown = %v, want %v", i, got, want)
+		}
+	}
+}
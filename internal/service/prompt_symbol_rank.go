@@ -0,0 +1,89 @@
+package service
+
+import (
+	"math"
+	"sort"
+)
+
+// 交易对相关度各因子的满分阈值
+const (
+	relevanceATRPercentCap  = 3.0 // 1小时ATR占价格的百分比达到该值时波动率因子满分
+	relevanceVolumeRatioCap = 3.0 // 1小时成交量达到均量的该倍数时成交量因子满分
+)
+
+// relevanceTimeframes 计算趋势一致性所用的时间框架
+var relevanceTimeframes = []string{"15m", "30m", "1h"}
+
+// symbolRelevance 交易对在提示词中的相关度（0-3，越高越值得关注）
+//
+// 相关度 = 波动率 + 成交量 + 趋势一致性，各因子为0-1：
+//   - 波动率: 1小时ATR占价格的百分比 / 3%
+//   - 成交量: (1小时成交量 / 均量 - 1) / (3 - 1)，不高于均量时为0
+//   - 趋势一致性: 15m/30m/1h 中EMA排列与MACD方向一致的时间框架数，按多空中较多的一方除以时间框架数
+func symbolRelevance(data *MarketData) float64 {
+	if data == nil {
+		return 0
+	}
+	score := 0.0
+	if ind, ok := data.Timeframes["1h"]; ok && ind != nil {
+		if ind.Price > 0 {
+			score += clamp01(ind.ATRSlow / ind.Price * 100 / relevanceATRPercentCap)
+		}
+		if ind.AvgVolume > 0 {
+			score += clamp01((ind.Volume/ind.AvgVolume - 1) / (relevanceVolumeRatioCap - 1))
+		}
+	}
+
+	bullish, bearish := 0, 0
+	for _, tf := range relevanceTimeframes {
+		ind, ok := data.Timeframes[tf]
+		if !ok || ind == nil {
+			continue
+		}
+		if ind.EMAFast > ind.EMASlow && ind.MACD > 0 {
+			bullish++
+		} else if ind.EMAFast < ind.EMASlow && ind.MACD < 0 {
+			bearish++
+		}
+	}
+	score += float64(max(bullish, bearish)) / float64(len(relevanceTimeframes))
+	return score
+}
+
+// rankPromptSymbols 确定行情部分展示的交易对及顺序
+//
+// 持仓交易对始终展示且排在最前，其余按相关度从高到低排列（相同时按交易对名称）；
+// limit 大于0时最多展示 limit 个交易对（持仓交易对超过 limit 时全部展示），返回未展示的交易对。
+func rankPromptSymbols(marketDataMap map[string]*MarketData, held map[string]bool, limit int) ([]string, []string) {
+	type rankedSymbol struct {
+		symbol string
+		held   bool
+		score  float64
+	}
+	ranked := make([]rankedSymbol, 0, len(marketDataMap))
+	for symbol, data := range marketDataMap {
+		if data == nil {
+			continue
+		}
+		ranked = append(ranked, rankedSymbol{symbol: symbol, held: held[symbol], score: symbolRelevance(data)})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].held != ranked[j].held {
+			return ranked[i].held
+		}
+		if math.Abs(ranked[i].score-ranked[j].score) > 1e-9 {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].symbol < ranked[j].symbol
+	})
+
+	var symbols, omitted []string
+	for _, r := range ranked {
+		if limit > 0 && len(symbols) >= limit && !r.held {
+			omitted = append(omitted, r.symbol)
+			continue
+		}
+		symbols = append(symbols, r.symbol)
+	}
+	return symbols, omitted
+}
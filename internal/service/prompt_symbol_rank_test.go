@@ -0,0 +1,45 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankPromptSymbols(t *testing.T) {
+	trending := func(price, atr, volumeRatio float64) *MarketData {
+		data := &MarketData{Timeframes: map[string]*TimeframeIndicators{}}
+		for _, tf := range relevanceTimeframes {
+			data.Timeframes[tf] = &TimeframeIndicators{Price: price, EMAFast: 2, EMASlow: 1, MACD: 1}
+		}
+		data.Timeframes["1h"].ATRSlow = atr
+		data.Timeframes["1h"].Volume = volumeRatio * 100
+		data.Timeframes["1h"].AvgVolume = 100
+		return data
+	}
+	quiet := &MarketData{Timeframes: map[string]*TimeframeIndicators{
+		"1h": {Price: 100, ATRSlow: 0.1, Volume: 50, AvgVolume: 100, EMAFast: 2, EMASlow: 1, MACD: -1},
+	}}
+
+	marketDataMap := map[string]*MarketData{
+		"AAAUSDT": quiet,
+		"BTCUSDT": trending(100, 1, 1),
+		"SOLUSDT": trending(100, 3, 3),
+		"ETHUSDT": quiet,
+	}
+
+	symbols, omitted := rankPromptSymbols(marketDataMap, map[string]bool{"ETHUSDT": true}, 0)
+	if want := []string{"ETHUSDT", "SOLUSDT", "BTCUSDT", "AAAUSDT"}; !reflect.DeepEqual(symbols, want) || len(omitted) != 0 {
+		t.Fatalf("symbols = %v omitted = %v, want %v", symbols, omitted, want)
+	}
+
+	symbols, omitted = rankPromptSymbols(marketDataMap, map[string]bool{"ETHUSDT": true}, 2)
+	if !reflect.DeepEqual(symbols, []string{"ETHUSDT", "SOLUSDT"}) || !reflect.DeepEqual(omitted, []string{"BTCUSDT", "AAAUSDT"}) {
+		t.Fatalf("limited symbols = %v omitted = %v", symbols, omitted)
+	}
+
+	// 持仓交易对超过上限时全部展示
+	symbols, _ = rankPromptSymbols(marketDataMap, map[string]bool{"ETHUSDT": true, "AAAUSDT": true}, 1)
+	if !reflect.DeepEqual(symbols, []string{"AAAUSDT", "ETHUSDT"}) {
+		t.Fatalf("held symbols should always be shown, got %v", symbols)
+	}
+}
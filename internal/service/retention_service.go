@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/repo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultRetentionBatchSize 未配置批大小时每批删除的行数
+const defaultRetentionBatchSize = 1000
+
+// RetentionService 数据保留服务，按保留天数分批清理LLM日志、决策记录并降采样账户历史
+type RetentionService struct {
+	logger *zap.Logger
+
+	llmLogRepo         *repo.LLMLogRepo
+	decisionRepo       *repo.DecisionRepo
+	accountHistoryRepo *repo.AccountHistoryRepo
+	marketRegimeRepo   *repo.MarketRegimeRepo
+	conf               *config.Config
+
+	mu          sync.Mutex
+	lastPruneAt time.Time
+	lastResult  *PruneResult
+}
+
+// NewRetentionService 创建数据保留服务
+func NewRetentionService(db *gorm.DB, conf *config.Config, logger *zap.Logger) *RetentionService {
+	return &RetentionService{
+		logger:             logger,
+		llmLogRepo:         repo.NewLLMLogRepo(db),
+		decisionRepo:       repo.NewDecisionRepo(db),
+		accountHistoryRepo: repo.NewAccountHistoryRepo(db),
+		marketRegimeRepo:   repo.NewMarketRegimeRepo(db),
+		conf:               conf,
+	}
+}
+
+// PruneResult 一次清理的结果
+type PruneResult struct {
+	LLMLogsDeleted          int64    `json:"llm_logs_deleted"`
+	DecisionsDeleted        int64    `json:"decisions_deleted"`
+	AccountHistoriesDeleted int64    `json:"account_histories_deleted"` // 降采样删除的账户历史条数
+	MarketRegimesDeleted    int64    `json:"market_regimes_deleted"`
+	DurationMillis          int64    `json:"duration_millis"`
+	Errors                  []string `json:"errors,omitempty"`
+}
+
+// RetentionStatus 各表当前行数和最近一次清理情况
+type RetentionStatus struct {
+	LLMLogs          int64                `json:"llm_logs"`
+	Decisions        int64                `json:"decisions"`
+	AccountHistories int64                `json:"account_histories"`
+	MarketRegimes    int64                `json:"market_regimes"`
+	LastPruneAt      *time.Time           `json:"last_prune_at,omitempty"`
+	LastResult       *PruneResult         `json:"last_result,omitempty"`
+	Config           config.RetentionConf `json:"config"`
+}
+
+// StartWorker 启动后台清理worker
+func (s *RetentionService) StartWorker(ctx context.Context, interval time.Duration) {
+	s.logger.Info("starting retention worker", zap.Duration("interval", interval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// 立即清理一次
+		s.Prune(ctx)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Prune(ctx)
+			case <-ctx.Done():
+				s.logger.Info("retention worker stopped by context")
+				return
+			}
+		}
+	}()
+}
+
+// Prune 按配置执行一次清理，单表失败不影响其他表
+func (s *RetentionService) Prune(ctx context.Context) *PruneResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conf := s.conf.Retention
+	batchSize := conf.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRetentionBatchSize
+	}
+	now := time.Now()
+	result := &PruneResult{}
+
+	if conf.LLMLogDays > 0 {
+		deleted, err := deleteInBatches(ctx, now.AddDate(0, 0, -conf.LLMLogDays), batchSize, s.llmLogRepo.DeleteExecutedBefore)
+		result.LLMLogsDeleted = deleted
+		if err != nil {
+			s.logger.Error("failed to prune LLM logs", zap.Error(err))
+			result.Errors = append(result.Errors, "llm_logs: "+err.Error())
+		}
+	}
+
+	if conf.DecisionDays > 0 {
+		deleted, err := deleteInBatches(ctx, now.AddDate(0, 0, -conf.DecisionDays), batchSize, s.decisionRepo.DeleteExecutedBefore)
+		result.DecisionsDeleted = deleted
+		if err != nil {
+			s.logger.Error("failed to prune decisions", zap.Error(err))
+			result.Errors = append(result.Errors, "decisions: "+err.Error())
+		}
+	}
+
+	if conf.MarketRegimeDays > 0 {
+		deleted, err := deleteInBatches(ctx, now.AddDate(0, 0, -conf.MarketRegimeDays), batchSize, s.marketRegimeRepo.DeleteRecordedBefore)
+		result.MarketRegimesDeleted = deleted
+		if err != nil {
+			s.logger.Error("failed to prune market regimes", zap.Error(err))
+			result.Errors = append(result.Errors, "market_regimes: "+err.Error())
+		}
+	}
+
+	if conf.AccountHistoryFullDays > 0 {
+		deleted, err := s.downsampleAccountHistory(ctx, now.AddDate(0, 0, -conf.AccountHistoryFullDays), batchSize)
+		result.AccountHistoriesDeleted = deleted
+		if err != nil {
+			s.logger.Error("failed to downsample account history", zap.Error(err))
+			result.Errors = append(result.Errors, "account_histories: "+err.Error())
+		}
+	}
+
+	result.DurationMillis = time.Since(now).Milliseconds()
+	s.lastPruneAt = now
+	s.lastResult = result
+
+	if result.LLMLogsDeleted > 0 || result.DecisionsDeleted > 0 || result.AccountHistoriesDeleted > 0 || result.MarketRegimesDeleted > 0 {
+		s.logger.Info("retention prune completed",
+			zap.Int64("llm_logs_deleted", result.LLMLogsDeleted),
+			zap.Int64("decisions_deleted", result.DecisionsDeleted),
+			zap.Int64("account_histories_deleted", result.AccountHistoriesDeleted),
+			zap.Int64("market_regimes_deleted", result.MarketRegimesDeleted),
+			zap.Int64("duration_ms", result.DurationMillis))
+	}
+	return result
+}
+
+// deleteInBatches 反复删除一批早于 cutoff 的记录，直到不足一批或上下文取消
+func deleteInBatches(ctx context.Context, cutoff time.Time, batchSize int,
+	deleteBatch func(ctx context.Context, cutoff time.Time, limit int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		deleted, err := deleteBatch(ctx, cutoff, batchSize)
+		total += deleted
+		if err != nil || deleted < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// downsampleAccountHistory 将早于 cutoff 的账户历史降采样为每天（UTC）一条
+//
+// 每天保留第一条记录，最早的记录（收益率基准）自然保留；净值最高的记录始终保留，避免峰值被删除后回撤失真。
+func (s *RetentionService) downsampleAccountHistory(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	histories, err := s.accountHistoryRepo.FindRecordedBefore(ctx, cutoff)
+	if err != nil || len(histories) == 0 {
+		return 0, err
+	}
+
+	highest := histories[0]
+	for _, history := range histories {
+		if history.TotalBalance > highest.TotalBalance {
+			highest = history
+		}
+	}
+
+	var ids []string
+	lastDay := ""
+	for _, history := range histories {
+		day := history.RecordedAt.UTC().Format(time.DateOnly)
+		if day != lastDay {
+			lastDay = day
+			continue
+		}
+		if history.ID != highest.ID {
+			ids = append(ids, history.ID)
+		}
+	}
+
+	var total int64
+	for start := 0; start < len(ids); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		deleted, err := s.accountHistoryRepo.HardDeleteByIdIn(ctx, ids[start:min(start+batchSize, len(ids))])
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Status 返回各表当前行数和最近一次清理情况
+func (s *RetentionService) Status(ctx context.Context) (*RetentionStatus, error) {
+	status := &RetentionStatus{Config: s.conf.Retention}
+
+	var err error
+	if status.LLMLogs, err = s.llmLogRepo.Count(ctx); err != nil {
+		return nil, err
+	}
+	if status.Decisions, err = s.decisionRepo.Count(ctx); err != nil {
+		return nil, err
+	}
+	if status.AccountHistories, err = s.accountHistoryRepo.Count(ctx); err != nil {
+		return nil, err
+	}
+	if status.MarketRegimes, err = s.marketRegimeRepo.Count(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastPruneAt.IsZero() {
+		at := s.lastPruneAt
+		status.LastPruneAt = &at
+		status.LastResult = s.lastResult
+	}
+	return status, nil
+}
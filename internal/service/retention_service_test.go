@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func newTestRetentionService(t *testing.T) *RetentionService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.LLMLog{}, models.Decision{}, models.AccountHistory{}, models.MarketRegimeRecord{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	conf := config.Default()
+	conf.Retention.BatchSize = 2
+	return NewRetentionService(db, &conf, zap.NewNop())
+}
+
+// 超过保留天数的日志和决策分批物理删除，保留期内的不受影响
+func TestPruneDeletesExpiredRows(t *testing.T) {
+	s := newTestRetentionService(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, executedAt := range []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -35), now.AddDate(0, 0, -31), now.AddDate(0, 0, -1)} {
+		log := &models.LLMLog{ID: string(rune('a' + i)), Iteration: 1, RoundNumber: 1, ExecutedAt: executedAt}
+		if err := s.llmLogRepo.Create(ctx, log); err != nil {
+			t.Fatalf("create log: %v", err)
+		}
+	}
+	for id, executedAt := range map[string]time.Time{"old": now.AddDate(-2, 0, 0), "recent": now.AddDate(0, -1, 0)} {
+		if err := s.decisionRepo.Create(ctx, &models.Decision{ID: id, Iteration: 1, ExecutedAt: executedAt}); err != nil {
+			t.Fatalf("create decision: %v", err)
+		}
+	}
+
+	result := s.Prune(ctx)
+	if result.LLMLogsDeleted != 3 || result.DecisionsDeleted != 1 || len(result.Errors) > 0 {
+		t.Fatalf("prune result = %+v", result)
+	}
+
+	status, err := s.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.LLMLogs != 1 || status.Decisions != 1 || status.LastPruneAt == nil {
+		t.Fatalf("status = %+v", status)
+	}
+	var total int64
+	s.llmLogRepo.GetDB(ctx).Unscoped().Model(&models.LLMLog{}).Count(&total)
+	if total != 1 {
+		t.Fatalf("rows including soft-deleted = %d, want 1 (hard delete)", total)
+	}
+}
+
+// 早于完整精度窗口的账户历史每天只保留第一条，峰值记录始终保留
+func TestDownsampleAccountHistory(t *testing.T) {
+	s := newTestRetentionService(t)
+	ctx := context.Background()
+	day := time.Now().AddDate(0, 0, -120).UTC().Truncate(24 * time.Hour)
+
+	histories := []models.AccountHistory{
+		{ID: "d1-first", TotalBalance: 1000, PeakBalance: 1000, RecordedAt: day.Add(time.Hour)},
+		{ID: "d1-peak", TotalBalance: 1200, PeakBalance: 1200, RecordedAt: day.Add(2 * time.Hour)},
+		{ID: "d1-other", TotalBalance: 1100, PeakBalance: 1200, RecordedAt: day.Add(3 * time.Hour)},
+		{ID: "d2-first", TotalBalance: 1050, PeakBalance: 1200, RecordedAt: day.Add(25 * time.Hour)},
+		{ID: "d2-other", TotalBalance: 1060, PeakBalance: 1200, RecordedAt: day.Add(26 * time.Hour)},
+		{ID: "d2-last", TotalBalance: 1065, PeakBalance: 1200, RecordedAt: day.Add(27 * time.Hour)},
+		{ID: "recent-1", TotalBalance: 1070, PeakBalance: 1200, RecordedAt: time.Now().Add(-2 * time.Hour)},
+		{ID: "recent-2", TotalBalance: 1080, PeakBalance: 1200, RecordedAt: time.Now().Add(-time.Hour)},
+	}
+	for i := range histories {
+		if err := s.accountHistoryRepo.Create(ctx, &histories[i]); err != nil {
+			t.Fatalf("create history: %v", err)
+		}
+	}
+
+	result := s.Prune(ctx)
+	if result.AccountHistoriesDeleted != 3 {
+		t.Fatalf("deleted = %d, want 3", result.AccountHistoriesDeleted)
+	}
+
+	remaining, err := s.accountHistoryRepo.FindAllOrderByRecordedAt(ctx)
+	if err != nil {
+		t.Fatalf("find histories: %v", err)
+	}
+	var ids []string
+	for _, h := range remaining {
+		ids = append(ids, h.ID)
+	}
+	want := []string{"d1-first", "d1-peak", "d2-first", "recent-1", "recent-2"}
+	if len(ids) != len(want) {
+		t.Fatalf("remaining = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("remaining = %v, want %v", ids, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// 突破信号确认参数
+const (
+	confirmationTimeframe   = "15m" // 判断突破所用的时间框架
+	confirmationRangeKlines = 20    // 突破前的区间长度（K线根数）
+	confirmationMaxCandles  = 12    // 最多回看的突破持续K线数
+)
+
+// 突破方向
+const (
+	BreakoutUp   = "up"
+	BreakoutDown = "down"
+	BreakoutNone = "none"
+)
+
+// SignalConfirmation 突破信号的持续情况
+//
+// 突破指已收盘K线的收盘价越过此前 confirmationRangeKlines 根K线的最高价（向上）或最低价（向下）；
+// 连续收在该区间之外的已收盘K线数达到 Required 时视为已确认，用于过滤单根K线的假突破。
+type SignalConfirmation struct {
+	Timeframe string  `json:"timeframe"`
+	Direction string  `json:"direction"` // up、down 或 none（收盘价仍在区间内）
+	Level     float64 `json:"level"`     // 被突破的区间高点或低点
+	Candles   int     `json:"candles"`   // 连续收在区间之外的已收盘K线数
+	Required  int     `json:"required"`  // 确认所需的K线数
+}
+
+// Confirmed 突破是否已确认
+func (c *SignalConfirmation) Confirmed() bool {
+	return c != nil && c.Direction != BreakoutNone && c.Candles >= c.Required
+}
+
+// Unconfirmed 开仓方向上是否存在尚未确认的突破
+func (c *SignalConfirmation) Unconfirmed(side string) bool {
+	if c == nil || c.Confirmed() {
+		return false
+	}
+	return (side == "long" && c.Direction == BreakoutUp) || (side == "short" && c.Direction == BreakoutDown)
+}
+
+// confirmationKlineLimit 计算突破持续情况需要拉取的K线数，多取一根未收盘K线
+func confirmationKlineLimit() int {
+	return confirmationRangeKlines + confirmationMaxCandles + 1
+}
+
+// breakoutConfirmation 根据已收盘K线计算突破的持续情况，required 不大于0或K线不足时返回 nil
+//
+// 对最近 n 根已收盘K线（n 不超过 confirmationMaxCandles），取它们之前区间的最高/最低价，
+// n 根收盘价全部越过该价位即为持续 n 根的突破，取满足条件的最大 n。
+func breakoutConfirmation(klines []*exchange.Kline, required int, now time.Time) *SignalConfirmation {
+	closed := klines
+	// 最新一根K线未收盘时不参与判断
+	if n := len(closed); n > 0 && closed[n-1].CloseTime.After(now) {
+		closed = closed[:n-1]
+	}
+	if required <= 0 || len(closed) < confirmationRangeKlines+1 {
+		return nil
+	}
+
+	result := &SignalConfirmation{
+		Timeframe: confirmationTimeframe,
+		Direction: BreakoutNone,
+		Required:  required,
+	}
+	if candles, level := breakoutStreak(closed, true); candles > 0 {
+		result.Direction, result.Candles, result.Level = BreakoutUp, candles, level
+	} else if candles, level := breakoutStreak(closed, false); candles > 0 {
+		result.Direction, result.Candles, result.Level = BreakoutDown, candles, level
+	}
+	return result
+}
+
+// breakoutStreak 返回收盘价持续越过前序区间的最多K线数及对应的区间价位，up 为向上突破
+func breakoutStreak(closed []*exchange.Kline, up bool) (int, float64) {
+	n := len(closed)
+	candles, level := 0, 0.0
+	for streak := 1; streak <= confirmationMaxCandles && n-streak-confirmationRangeKlines >= 0; streak++ {
+		rangeKlines := closed[n-streak-confirmationRangeKlines : n-streak]
+		bound := rangeKlines[0].High
+		if !up {
+			bound = rangeKlines[0].Low
+		}
+		for _, k := range rangeKlines[1:] {
+			if up {
+				bound = max(bound, k.High)
+			} else {
+				bound = min(bound, k.Low)
+			}
+		}
+
+		beyond := true
+		for _, k := range closed[n-streak:] {
+			if (up && k.Close <= bound) || (!up && k.Close >= bound) {
+				beyond = false
+				break
+			}
+		}
+		if beyond {
+			candles, level = streak, bound
+		}
+	}
+	return candles, level
+}
+
+// currentBreakoutConfirmation 拉取交易对的15分钟K线实时计算突破持续情况，行情获取失败时返回 nil
+func (s *AgentService) currentBreakoutConfirmation(ctx context.Context, symbol string, required int) *SignalConfirmation {
+	klines, err := s.exchange.GetKlines(ctx, symbol, confirmationTimeframe, confirmationKlineLimit())
+	if err != nil {
+		s.logger.Warn("failed to get klines for breakout confirmation",
+			zap.String("symbol", symbol),
+			zap.Error(err))
+		return nil
+	}
+	return breakoutConfirmation(klines, required, time.Now())
+}
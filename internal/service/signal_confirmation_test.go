@@ -0,0 +1,93 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+// rangeKlines 生成收盘价在 [99, 101] 区间内的15分钟K线，之后依次追加给定收盘价
+func rangeKlines(start time.Time, closes ...float64) []*exchange.Kline {
+	all := make([]float64, confirmationRangeKlines, confirmationRangeKlines+len(closes))
+	for i := range all {
+		all[i] = 100
+	}
+	all = append(all, closes...)
+
+	klines := make([]*exchange.Kline, 0, len(all))
+	for i, c := range all {
+		open := start.Add(time.Duration(i) * 15 * time.Minute)
+		klines = append(klines, &exchange.Kline{
+			OpenTime:  open,
+			CloseTime: open.Add(15*time.Minute - time.Second),
+			Open:      c,
+			High:      max(c, 101),
+			Low:       min(c, 99),
+			Close:     c,
+		})
+	}
+	return klines
+}
+
+func TestBreakoutConfirmation(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(klines []*exchange.Kline) time.Time { return klines[len(klines)-1].CloseTime }
+
+	// 单根K线突破尚未确认
+	klines := rangeKlines(start, 102)
+	got := breakoutConfirmation(klines, 2, at(klines))
+	if got.Direction != BreakoutUp || got.Candles != 1 || got.Level != 101 || got.Confirmed() || !got.Unconfirmed("long") || got.Unconfirmed("short") {
+		t.Fatalf("single candle breakout = %+v", got)
+	}
+
+	// 连续两根收在区间外后确认
+	klines = rangeKlines(start, 102, 102.5)
+	if got := breakoutConfirmation(klines, 2, at(klines)); got.Candles != 2 || !got.Confirmed() {
+		t.Fatalf("two candle breakout = %+v", got)
+	}
+
+	// 未收盘的最新K线不计入
+	if got := breakoutConfirmation(klines, 2, at(klines).Add(-time.Minute)); got.Candles != 1 {
+		t.Fatalf("open candle should be ignored: %+v", got)
+	}
+
+	// 回到区间内即失去突破
+	klines = rangeKlines(start, 102, 100)
+	if got := breakoutConfirmation(klines, 2, at(klines)); got.Direction != BreakoutNone || got.Unconfirmed("long") {
+		t.Fatalf("failed breakout = %+v", got)
+	}
+
+	// 向下突破
+	klines = rangeKlines(start, 98, 97, 96)
+	if got := breakoutConfirmation(klines, 2, at(klines)); got.Direction != BreakoutDown || got.Candles != 3 || got.Level != 99 || !got.Confirmed() {
+		t.Fatalf("downside breakout = %+v", got)
+	}
+
+	if got := breakoutConfirmation(klines[:confirmationRangeKlines], 2, at(klines)); got != nil {
+		t.Fatalf("too few klines should return nil, got %+v", got)
+	}
+}
+
+func TestCheckBreakoutConfirmation(t *testing.T) {
+	req := validOpenRequest()
+	req.Breakout = &SignalConfirmation{Timeframe: "15m", Direction: BreakoutUp, Level: 101, Candles: 1, Required: 2}
+	if err := checkBreakoutConfirmation(req); err != nil {
+		t.Fatalf("block disabled: %v", err)
+	}
+
+	req.BlockUnconfirmedOpens = true
+	if err := checkBreakoutConfirmation(req); err == nil || !strings.Contains(err.Error(), "突破确认前") {
+		t.Fatalf("unconfirmed long err = %v", err)
+	}
+	req.Side = "short"
+	if err := checkBreakoutConfirmation(req); err != nil {
+		t.Fatalf("fading the breakout should pass: %v", err)
+	}
+	req.Side = "long"
+	req.Breakout.Candles = 2
+	if err := checkBreakoutConfirmation(req); err != nil {
+		t.Fatalf("confirmed breakout: %v", err)
+	}
+}
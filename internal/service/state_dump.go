@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+)
+
+const (
+	defaultStateDumpDecisions = 10
+	maxStateDumpDecisions     = 100
+
+	redactedValue = "******"
+)
+
+// StateDumpPrompt 状态快照中的系统提示词信息
+type StateDumpPrompt struct {
+	ID        string    `json:"id"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StateDump 系统完整状态快照，便于在问题反馈中附上可复现的现场
+type StateDump struct {
+	GeneratedAt    time.Time              `json:"generated_at"`
+	Config         config.Config          `json:"config"`           // 启动配置，密钥已脱敏
+	TradingConfig  *models.TradingConfig  `json:"trading_config"`   // 后台可编辑的交易配置
+	SystemPrompt   *StateDumpPrompt       `json:"system_prompt"`    // 当前激活的系统提示词版本
+	Loop           map[string]interface{} `json:"loop"`             // 交易循环状态
+	Positions      []models.Position      `json:"positions"`        // 所有持仓
+	ActiveOrders   []models.Order         `json:"active_orders"`    // 所有活跃的止损止盈单
+	AccountMetrics *AccountMetrics        `json:"account_metrics"`  // 最新账户指标
+	Decisions      []*models.Decision     `json:"decisions"`        // 最近的决策
+	Errors         []string               `json:"errors,omitempty"` // 获取失败的部分，其余数据照常返回
+}
+
+// StateDump 收集系统完整状态快照，单项获取失败时记录在 Errors 中而不是中断整个快照
+//
+// decisionLimit 为附带的最近决策数量，不大于0时使用默认值10，最多100
+func (t *TradingLoop) StateDump(ctx context.Context, decisionLimit int) *StateDump {
+	if decisionLimit <= 0 {
+		decisionLimit = defaultStateDumpDecisions
+	}
+	decisionLimit = min(decisionLimit, maxStateDumpDecisions)
+
+	dump := &StateDump{
+		GeneratedAt: time.Now(),
+		Config:      redactConfig(*t.conf),
+	}
+	fail := func(part string, err error) {
+		dump.Errors = append(dump.Errors, fmt.Sprintf("%s: %v", part, err))
+	}
+
+	if tradingConfig, err := t.adminConfigService.GetTradingConfig(ctx); err != nil {
+		fail("trading_config", err)
+	} else {
+		dump.TradingConfig = tradingConfig
+	}
+
+	if prompt, err := t.adminConfigService.GetSystemPrompt(ctx); err != nil {
+		fail("system_prompt", err)
+	} else if prompt != nil {
+		dump.SystemPrompt = &StateDumpPrompt{ID: prompt.ID, Version: prompt.Version, UpdatedAt: prompt.UpdatedAt}
+	}
+
+	if status, err := t.GetStatus(ctx); err != nil {
+		fail("loop", err)
+	} else {
+		dump.Loop = status
+	}
+
+	if positions, err := t.positionService.GetAllPositions(ctx); err != nil {
+		fail("positions", err)
+	} else {
+		dump.Positions = positions
+	}
+
+	if orders, err := t.orderRepo.FindAllActive(ctx); err != nil {
+		fail("active_orders", err)
+	} else {
+		dump.ActiveOrders = orders
+	}
+
+	if metrics, err := t.accountService.GetAccountMetrics(ctx); err != nil {
+		fail("account_metrics", err)
+	} else {
+		dump.AccountMetrics = metrics
+	}
+
+	if decisions, err := t.agentService.GetRecentDecisions(ctx, decisionLimit); err != nil {
+		fail("decisions", err)
+	} else {
+		dump.Decisions = decisions
+	}
+
+	return dump
+}
+
+// redactConfig 脱敏配置中的密钥和代理地址中的账号密码
+func redactConfig(conf config.Config) config.Config {
+	conf.Telegram.Token = redact(conf.Telegram.Token)
+	conf.Binance.APIKey = redact(conf.Binance.APIKey)
+	conf.Binance.Secret = redact(conf.Binance.Secret)
+	conf.Binance.ProxyURL = redactProxyURL(conf.Binance.ProxyURL)
+	conf.LLM.APIKey = redact(conf.LLM.APIKey)
+	conf.LLM.ProxyURL = redactProxyURL(conf.LLM.ProxyURL)
+	conf.Admin.JWTSecret = redact(conf.Admin.JWTSecret)
+	return conf
+}
+
+// redact 非空时替换为占位符，保留“是否已配置”的信息
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactProxyURL 去掉代理地址中的用户名和密码
+func redactProxyURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		u.User = url.User(redactedValue)
+	}
+	return u.String()
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+const (
+	pivotStrength       = 3     // 枢轴点左右两侧各需要的K线数
+	maxKeyLevels        = 3     // 支撑和阻力各保留的价位数
+	levelToleranceATR   = 0.5   // 枢轴聚类容差（ATR倍数）
+	levelTolerancePrice = 0.003 // 没有ATR时的聚类容差（价格比例）
+)
+
+// PriceLevel 由多个枢轴点聚类得到的支撑/阻力位
+type PriceLevel struct {
+	Price   float64 `json:"price"`   // 聚类后枢轴点的平均价格
+	Touches int     `json:"touches"` // 构成该价位的枢轴点数量
+}
+
+// findKeyLevels 在K线中寻找枢轴高低点并按容差聚类
+//
+// 返回当前价格下方的支撑和上方的阻力，均按与当前价格的距离由近到远排序，各最多 maxKeyLevels 个。
+// atr 用于确定聚类容差，为0时按价格比例计算。
+func findKeyLevels(klines []*exchange.Kline, currentPrice, atr float64) (supports, resistances []PriceLevel) {
+	if currentPrice <= 0 || len(klines) < pivotStrength*2+1 {
+		return nil, nil
+	}
+
+	var pivots []float64
+	for i := pivotStrength; i < len(klines)-pivotStrength; i++ {
+		isHigh, isLow := true, true
+		for j := i - pivotStrength; j <= i+pivotStrength; j++ {
+			if j == i {
+				continue
+			}
+			if klines[j].High > klines[i].High {
+				isHigh = false
+			}
+			if klines[j].Low < klines[i].Low {
+				isLow = false
+			}
+		}
+		if isHigh {
+			pivots = append(pivots, klines[i].High)
+		}
+		if isLow {
+			pivots = append(pivots, klines[i].Low)
+		}
+	}
+
+	tolerance := atr * levelToleranceATR
+	if tolerance <= 0 {
+		tolerance = currentPrice * levelTolerancePrice
+	}
+
+	for _, level := range clusterLevels(pivots, tolerance) {
+		switch {
+		case level.Price < currentPrice:
+			supports = append(supports, level)
+		case level.Price > currentPrice:
+			resistances = append(resistances, level)
+		}
+	}
+
+	// clusterLevels 按价格升序返回，支撑从高到低、阻力从低到高即为由近到远
+	sort.Slice(supports, func(i, j int) bool { return supports[i].Price > supports[j].Price })
+	if len(supports) > maxKeyLevels {
+		supports = supports[:maxKeyLevels]
+	}
+	if len(resistances) > maxKeyLevels {
+		resistances = resistances[:maxKeyLevels]
+	}
+	return supports, resistances
+}
+
+// clusterLevels 将相邻价格差不超过 tolerance 的枢轴点合并为一个价位，按价格升序返回
+func clusterLevels(prices []float64, tolerance float64) []PriceLevel {
+	if len(prices) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), prices...)
+	sort.Float64s(sorted)
+
+	var levels []PriceLevel
+	sum, count := sorted[0], 1
+	for _, price := range sorted[1:] {
+		if price-sum/float64(count) <= tolerance {
+			sum += price
+			count++
+			continue
+		}
+		levels = append(levels, PriceLevel{Price: sum / float64(count), Touches: count})
+		sum, count = price, 1
+	}
+	return append(levels, PriceLevel{Price: sum / float64(count), Touches: count})
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dushixiang/prism/pkg/exchange"
+)
+
+func TestFindKeyLevels(t *testing.T) {
+	// 两次冲高到 110 附近、两次回落到 90 附近的震荡走势
+	mids := []float64{100, 103, 106, 109, 106, 103, 100, 97, 94, 91, 94, 97, 100,
+		103, 106, 109.2, 106, 103, 100, 97, 94, 90.9, 94, 97, 100}
+	klines := make([]*exchange.Kline, 0, len(mids))
+	for _, mid := range mids {
+		klines = append(klines, &exchange.Kline{Open: mid, High: mid + 1, Low: mid - 1, Close: mid})
+	}
+
+	supports, resistances := findKeyLevels(klines, 100, 1)
+	if len(supports) != 1 || len(resistances) != 1 {
+		t.Fatalf("supports = %+v, resistances = %+v", supports, resistances)
+	}
+	if math.Abs(supports[0].Price-89.95) > 1e-9 || supports[0].Touches != 2 {
+		t.Fatalf("support = %+v, want 89.95 with 2 touches", supports[0])
+	}
+	if math.Abs(resistances[0].Price-110.1) > 1e-9 || resistances[0].Touches != 2 {
+		t.Fatalf("resistance = %+v, want 110.1 with 2 touches", resistances[0])
+	}
+
+	// ATR 很小时两次高点不再合并，阻力按由近到远排序
+	_, resistances = findKeyLevels(klines, 100, 0.1)
+	if len(resistances) != 2 || resistances[0].Price != 110 || resistances[1].Price != 110.2 {
+		t.Fatalf("resistances = %+v, want 110 then 110.2", resistances)
+	}
+
+	if supports, resistances := findKeyLevels(klines[:5], 100, 1); supports != nil || resistances != nil {
+		t.Fatalf("too few klines should return no levels, got %+v %+v", supports, resistances)
+	}
+}
+
+func TestClusterLevels(t *testing.T) {
+	levels := clusterLevels([]float64{3, 1.4, 1, 1.2}, 0.5)
+	if len(levels) != 2 {
+		t.Fatalf("levels = %+v, want 2 clusters", levels)
+	}
+	if math.Abs(levels[0].Price-1.2) > 1e-9 || levels[0].Touches != 3 {
+		t.Fatalf("first cluster = %+v, want 1.2 with 3 touches", levels[0])
+	}
+	if levels[1].Price != 3 || levels[1].Touches != 1 {
+		t.Fatalf("second cluster = %+v, want 3 with 1 touch", levels[1])
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// quoteVolumeIssue 检查交易对24小时成交额，低于最小成交额时返回原因
+//
+// 未配置最小成交额时不限制，不请求行情统计。
+func (s *MarketService) quoteVolumeIssue(ctx context.Context, symbol string) (string, error) {
+	minVolume := s.conf.Trading.MinDailyQuoteVolume
+	if minVolume <= 0 {
+		return "", nil
+	}
+	stats, err := s.exchange.Get24hrStats(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	if stats.QuoteVolume >= minVolume {
+		return "", nil
+	}
+	return fmt.Sprintf("24小时成交额 %.0f USDT，低于最小成交额 %.0f USDT", stats.QuoteVolume, minVolume), nil
+}
+
+// excludeIlliquidSymbols 返回24小时成交额不足、本周期不参与交易的交易对及原因
+//
+// 已有持仓的交易对（held）仍需收集行情用于管理仓位，不剔除。
+func (t *TradingLoop) excludeIlliquidSymbols(ctx context.Context, tradingConfig *models.TradingConfig, held map[string]bool) map[string]string {
+	excluded := make(map[string]string)
+	if t.conf.Trading.MinDailyQuoteVolume <= 0 {
+		return excluded
+	}
+
+	for _, symbol := range tradingConfig.Symbols {
+		if held[symbol] {
+			continue
+		}
+		issue, err := t.marketService.quoteVolumeIssue(ctx, symbol)
+		if err != nil {
+			// 查询失败不能说明交易对流动性差，按正常处理
+			t.logger.Warn("[RISK] failed to check symbol quote volume", zap.String("symbol", symbol), zap.Error(err))
+			continue
+		}
+		if issue != "" {
+			t.logger.Warn("[RISK] symbol quote volume too low, excluded from trading",
+				zap.String("symbol", symbol),
+				zap.String("reason", issue))
+			excluded[symbol] = issue
+		}
+	}
+	return excluded
+}
+
+// IlliquidSymbols 检查配置的交易对中24小时成交额不足的交易对，返回交易对及原因，用于配置校验时提示
+func (t *TradingLoop) IlliquidSymbols(ctx context.Context, tradingConfig *models.TradingConfig) map[string]string {
+	result := make(map[string]string)
+	for _, symbol := range tradingConfig.Symbols {
+		issue, err := t.marketService.quoteVolumeIssue(ctx, symbol)
+		if err != nil {
+			t.logger.Warn("failed to check symbol quote volume", zap.String("symbol", symbol), zap.Error(err))
+			continue
+		}
+		if issue != "" {
+			result[symbol] = issue
+		}
+	}
+	return result
+}
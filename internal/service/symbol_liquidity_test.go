@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// quoteVolumeExchange 按交易对返回固定的24小时成交额
+type quoteVolumeExchange struct {
+	exchange.Exchange
+	volumes map[string]float64
+}
+
+func (e *quoteVolumeExchange) Get24hrStats(ctx context.Context, symbol string) (*exchange.Ticker24h, error) {
+	return &exchange.Ticker24h{Symbol: symbol, QuoteVolume: e.volumes[symbol]}, nil
+}
+
+func TestQuoteVolumeIssue(t *testing.T) {
+	conf := config.Default()
+	ex := &quoteVolumeExchange{volumes: map[string]float64{"BTCUSDT": 5e9, "THINUSDT": 2e5}}
+	s := NewMarketService(nil, ex, NewIndicatorService(), &conf, zap.NewNop())
+
+	// 未配置最小成交额时不限制
+	if issue, err := s.quoteVolumeIssue(context.Background(), "THINUSDT"); err != nil || issue != "" {
+		t.Fatalf("disabled filter should pass, got %q, %v", issue, err)
+	}
+
+	conf.Trading.MinDailyQuoteVolume = 1e6
+	issue, err := s.quoteVolumeIssue(context.Background(), "THINUSDT")
+	if err != nil || !strings.Contains(issue, "200000") {
+		t.Fatalf("quote volume issue = %q, %v", issue, err)
+	}
+	if issue, _ := s.quoteVolumeIssue(context.Background(), "BTCUSDT"); issue != "" {
+		t.Fatalf("liquid symbol should pass, got %q", issue)
+	}
+}
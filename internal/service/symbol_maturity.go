@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrInsufficientHistory 交易对K线历史不足（通常是刚上线的交易对），指标不可靠，不参与本周期决策
+var ErrInsufficientHistory = errors.New("insufficient kline history")
+
+// maturityTimeframe 判断K线历史是否充足所用的时间框架，与行情收集中最长的时间框架一致
+const maturityTimeframe = "1h"
+
+// minHistoryKlines 交易对至少需要的1小时K线根数，不低于指标计算所需的最少K线数
+func (s *MarketService) minHistoryKlines(params models.IndicatorParams) int {
+	return max(s.conf.Trading.MinKlineCount, RequiredKlines(params))
+}
+
+// listingAgeIssue 检查交易对上线时长，不足最小上线天数时返回原因
+//
+// 未配置最小上线天数或交易所未提供上线时间时不限制。
+func (s *MarketService) listingAgeIssue(ctx context.Context, symbol string, now time.Time) (string, error) {
+	minDays := s.conf.Trading.MinListingAgeDays
+	if minDays <= 0 {
+		return "", nil
+	}
+	info, err := s.exchange.GetSymbolInfo(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	if info.OnboardDate.IsZero() {
+		return "", nil
+	}
+	age := now.Sub(info.OnboardDate)
+	if age >= time.Duration(minDays)*24*time.Hour {
+		return "", nil
+	}
+	return fmt.Sprintf("上线仅 %.1f 天（%s），低于最小上线天数 %d 天",
+		age.Hours()/24, info.OnboardDate.Format("2006-01-02"), minDays), nil
+}
+
+// SymbolMaturityIssue 检查交易对上线时长和1小时K线历史，不满足要求时返回原因，满足时返回空字符串
+func (s *MarketService) SymbolMaturityIssue(ctx context.Context, symbol string, params models.IndicatorParams) (string, error) {
+	issue, err := s.listingAgeIssue(ctx, symbol, time.Now())
+	if err != nil || issue != "" {
+		return issue, err
+	}
+	required := s.minHistoryKlines(params)
+	klines, err := s.exchange.GetKlines(ctx, symbol, maturityTimeframe, required)
+	if err != nil {
+		return "", err
+	}
+	if len(klines) < required {
+		return fmt.Sprintf("1小时K线仅 %d 根，少于要求的 %d 根", len(klines), required), nil
+	}
+	return "", nil
+}
+
+// excludeNewListings 返回上线时长不足、本周期不参与交易的交易对及原因
+//
+// 只检查上线时间（交易对信息有缓存，不额外请求K线），K线根数在收集行情时检查。
+// 已有持仓的交易对（held）仍需收集行情用于管理仓位，不剔除。
+func (t *TradingLoop) excludeNewListings(ctx context.Context, tradingConfig *models.TradingConfig, held map[string]bool) map[string]string {
+	excluded := make(map[string]string)
+	if t.conf.Trading.MinListingAgeDays <= 0 {
+		return excluded
+	}
+
+	now := time.Now()
+	for _, symbol := range tradingConfig.Symbols {
+		if held[symbol] {
+			continue
+		}
+		issue, err := t.marketService.listingAgeIssue(ctx, symbol, now)
+		if err != nil {
+			// 查询失败不能说明交易对是新上线的，按正常处理
+			t.logger.Warn("[RISK] failed to check symbol listing age", zap.String("symbol", symbol), zap.Error(err))
+			continue
+		}
+		if issue != "" {
+			t.logger.Warn("[RISK] symbol listed too recently, excluded from trading",
+				zap.String("symbol", symbol),
+				zap.String("reason", issue))
+			excluded[symbol] = issue
+		}
+	}
+	return excluded
+}
+
+// heldSymbols 当前有持仓的交易对，查询失败时返回空集合
+func (t *TradingLoop) heldSymbols(ctx context.Context) map[string]bool {
+	held := make(map[string]bool)
+	positions, err := t.positionService.GetAllPositions(ctx)
+	if err != nil {
+		t.logger.Warn("[RISK] failed to get positions for held symbols", zap.Error(err))
+	}
+	for _, position := range positions {
+		held[position.Symbol] = true
+	}
+	return held
+}
+
+// ImmatureSymbols 检查配置的交易对中上线时长或K线历史不足的交易对，返回交易对及原因，用于配置校验时提示
+func (t *TradingLoop) ImmatureSymbols(ctx context.Context, tradingConfig *models.TradingConfig) map[string]string {
+	result := make(map[string]string)
+	for _, symbol := range tradingConfig.Symbols {
+		issue, err := t.marketService.SymbolMaturityIssue(ctx, symbol, tradingConfig.IndicatorParamsFor(symbol))
+		if err != nil {
+			t.logger.Warn("failed to check symbol history", zap.String("symbol", symbol), zap.Error(err))
+			continue
+		}
+		if issue != "" {
+			result[symbol] = issue
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"go.uber.org/zap"
+)
+
+// newListingExchange 模拟一个刚上线、只有少量K线历史的交易对
+type newListingExchange struct {
+	exchange.Exchange
+	onboard time.Time
+	klines  int
+}
+
+func (e *newListingExchange) GetSymbolInfo(ctx context.Context, symbol string) (*exchange.SymbolInfo, error) {
+	return &exchange.SymbolInfo{Symbol: symbol, Status: exchange.SymbolStatusTrading, OnboardDate: e.onboard}, nil
+}
+
+func (e *newListingExchange) GetKlines(ctx context.Context, symbol, interval string, limit int) ([]*exchange.Kline, error) {
+	n := min(e.klines, limit)
+	klines := make([]*exchange.Kline, n)
+	for i := range klines {
+		klines[i] = &exchange.Kline{Open: 100, High: 101, Low: 99, Close: 100, Volume: 10}
+	}
+	return klines, nil
+}
+
+func (e *newListingExchange) GetFundingRate(ctx context.Context, symbol string) (float64, error) {
+	return 0.0001, nil
+}
+
+func TestSymbolMaturity(t *testing.T) {
+	now := time.Now()
+	conf := config.Default()
+	conf.Trading.MinListingAgeDays = 7
+	ex := &newListingExchange{onboard: now.Add(-3 * 24 * time.Hour), klines: 72}
+	s := NewMarketService(nil, ex, NewIndicatorService(), &conf, zap.NewNop())
+	params := models.DefaultIndicatorParams
+
+	issue, err := s.listingAgeIssue(context.Background(), "NEWUSDT", now)
+	if err != nil || !strings.Contains(issue, "3.0") {
+		t.Fatalf("listing age issue = %q, %v", issue, err)
+	}
+
+	ex.onboard = now.Add(-30 * 24 * time.Hour)
+	if issue, _ := s.listingAgeIssue(context.Background(), "NEWUSDT", now); issue != "" {
+		t.Fatalf("mature listing should pass, got %q", issue)
+	}
+
+	// 上线时间满足但K线不足最小根数
+	conf.Trading.MinKlineCount = 100
+	issue, err = s.SymbolMaturityIssue(context.Background(), "NEWUSDT", params)
+	if err != nil || !strings.Contains(issue, "72") {
+		t.Fatalf("kline history issue = %q, %v", issue, err)
+	}
+	if _, err := s.CollectMarketData(context.Background(), "NEWUSDT", params); !errors.Is(err, ErrInsufficientHistory) {
+		t.Fatalf("collect market data error = %v, want ErrInsufficientHistory", err)
+	}
+
+	// 已有持仓的交易对K线不足时仍收集行情，未持仓的交易对剔除
+	tradingConfig := &models.TradingConfig{Symbols: []string{"NEWUSDT"}}
+	if _, err := s.CollectAllSymbols(context.Background(), tradingConfig, nil); err == nil {
+		t.Fatal("unheld short-history symbol should be excluded")
+	}
+	data, err := s.CollectAllSymbols(context.Background(), tradingConfig, map[string]bool{"NEWUSDT": true})
+	if err != nil || data["NEWUSDT"] == nil || data["NEWUSDT"].CurrentPrice != 100 {
+		t.Fatalf("held short-history symbol data = %v, %v", data, err)
+	}
+
+	conf.Trading.MinKlineCount = 0
+	if issue, _ := s.SymbolMaturityIssue(context.Background(), "NEWUSDT", params); issue != "" {
+		t.Fatalf("default min klines should follow indicator requirement, got %q", issue)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/dushixiang/prism/internal/models"
+	"go.uber.org/zap"
+)
+
+// rotateSymbols 选出本周期分析的交易对
+//
+// 全部交易对按 batchSize 分批，第 iteration 个周期取第 (iteration-1) % 批数 批，每个周期轮换到下一批；
+// 持仓交易对无论是否在本批都会加入，保证持仓管理不被跳过。batchSize 不大于0或不小于交易对总数时返回全部交易对。
+// 返回本周期的交易对以及批次序号（从1开始）和批次总数。
+func rotateSymbols(symbols []string, batchSize, iteration int, heldSymbols []string) ([]string, int, int) {
+	if batchSize <= 0 || batchSize >= len(symbols) {
+		return symbols, 1, 1
+	}
+
+	batches := (len(symbols) + batchSize - 1) / batchSize
+	batch := (max(iteration, 1) - 1) % batches
+	start := batch * batchSize
+	end := min(start+batchSize, len(symbols))
+
+	selected := slices.Clone(symbols[start:end])
+	for _, symbol := range heldSymbols {
+		if !slices.Contains(selected, symbol) {
+			selected = append(selected, symbol)
+		}
+	}
+	return selected, batch + 1, batches
+}
+
+// rotateCycleSymbols 启用交易对轮换时返回只包含本周期交易对的配置副本，持仓交易对（held）始终保留
+func (t *TradingLoop) rotateCycleSymbols(tradingConfig *models.TradingConfig, iteration int, held map[string]bool) *models.TradingConfig {
+	if tradingConfig.SymbolBatchSize <= 0 || tradingConfig.SymbolBatchSize >= len(tradingConfig.Symbols) {
+		return tradingConfig
+	}
+
+	symbols, batch, batches := rotateSymbols(tradingConfig.Symbols, tradingConfig.SymbolBatchSize, iteration, slices.Sorted(maps.Keys(held)))
+	t.logger.Info("symbol rotation applied",
+		zap.Int("batch", batch),
+		zap.Int("batches", batches),
+		zap.Strings("symbols", symbols))
+
+	rotated := *tradingConfig
+	rotated.Symbols = symbols
+	return &rotated
+}
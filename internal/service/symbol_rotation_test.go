@@ -0,0 +1,39 @@
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRotateSymbols(t *testing.T) {
+	symbols := []string{"A", "B", "C", "D", "E"}
+
+	if got, batch, batches := rotateSymbols(symbols, 0, 3, nil); !slices.Equal(got, symbols) || batch != 1 || batches != 1 {
+		t.Fatalf("disabled rotation = %v (%d/%d)", got, batch, batches)
+	}
+
+	tests := []struct {
+		iteration int
+		held      []string
+		want      []string
+		batch     int
+	}{
+		{1, nil, []string{"A", "B"}, 1},
+		{2, nil, []string{"C", "D"}, 2},
+		{3, nil, []string{"E"}, 3},
+		{4, nil, []string{"A", "B"}, 1},
+		// 持仓交易对始终包含，且不重复
+		{2, []string{"A", "C"}, []string{"C", "D", "A"}, 2},
+		// 持仓不在交易对列表中也要包含
+		{3, []string{"Z"}, []string{"E", "Z"}, 3},
+	}
+	for _, tt := range tests {
+		got, batch, batches := rotateSymbols(symbols, 2, tt.iteration, tt.held)
+		if !slices.Equal(got, tt.want) || batch != tt.batch || batches != 3 {
+			t.Errorf("iteration %d held %v = %v (%d/%d), want %v (%d/3)", tt.iteration, tt.held, got, batch, batches, tt.want, tt.batch)
+		}
+	}
+	if !slices.Equal(symbols, []string{"A", "B", "C", "D", "E"}) {
+		t.Fatalf("rotation must not modify the symbol list, got %v", symbols)
+	}
+}
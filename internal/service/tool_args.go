@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// parseToolArguments 解析工具调用参数
+//
+// 先按标准 JSON 解析；失败时做容错预处理（去掉 markdown 代码块、截取第一个完整的 JSON 对象、
+// 删除对象和数组末尾多余的逗号）后再解析，recovered 表示经过了容错处理。预处理后仍无法解析时返回最初的解析错误。
+func parseToolArguments(raw string) (args map[string]interface{}, recovered bool, err error) {
+	err = json.Unmarshal([]byte(raw), &args)
+	if err == nil {
+		return args, false, nil
+	}
+
+	cleaned := stripCodeFences(raw)
+	if object, ok := firstJSONObject(cleaned); ok {
+		cleaned = object
+	}
+	cleaned = removeTrailingCommas(cleaned)
+
+	var recoveredArgs map[string]interface{}
+	if json.Unmarshal([]byte(cleaned), &recoveredArgs) != nil {
+		return nil, false, err
+	}
+	return recoveredArgs, true, nil
+}
+
+// stripCodeFences 去掉包裹内容的 markdown 代码块标记（如 ```json ... ```）
+func stripCodeFences(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	// 去掉代码块语言标识所在的首行
+	if newline := strings.IndexByte(s, '\n'); newline >= 0 {
+		first := strings.TrimSpace(s[:newline])
+		if !strings.ContainsAny(first, "{[") {
+			s = s[newline+1:]
+		}
+	}
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
+
+// firstJSONObject 截取第一个括号配对完整的 JSON 对象，忽略字符串内的括号，未找到时返回 false
+func firstJSONObject(s string) (string, bool) {
+	start := strings.IndexByte(s, '{')
+	if start < 0 {
+		return "", false
+	}
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[start : i+1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// removeTrailingCommas 删除字符串之外紧跟在 } 或 ] 之前的逗号
+func removeTrailingCommas(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			sb.WriteByte(c)
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			next := i + 1
+			for next < len(s) && strings.IndexByte(" \t\r\n", s[next]) >= 0 {
+				next++
+			}
+			if next < len(s) && (s[next] == '}' || s[next] == ']') {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package service
+
+import "testing"
+
+func TestParseToolArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		recovered bool
+	}{
+		{"valid", `{"symbol":"BTCUSDT","leverage":5}`, false},
+		{"code fence", "```json\n{\"symbol\":\"BTCUSDT\",\"leverage\":5}\n```", true},
+		{"extra text", `好的，参数如下：{"symbol":"BTCUSDT","leverage":5} 以上`, true},
+		{"trailing comma", `{"symbol":"BTCUSDT","leverage":5,}`, true},
+		{"nested trailing comma", `{"symbol":"BTCUSDT","leverage":5,"tags":["a","b",],}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, recovered, err := parseToolArguments(tt.raw)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if recovered != tt.recovered {
+				t.Fatalf("recovered = %v, want %v", recovered, tt.recovered)
+			}
+			if args["symbol"] != "BTCUSDT" || args["leverage"] != 5.0 {
+				t.Fatalf("args = %v", args)
+			}
+		})
+	}
+
+	// 字符串内的括号和逗号保持原样
+	args, _, err := parseToolArguments("```\n{\"reason\":\"突破 {前高}, ]\",}\n```")
+	if err != nil || args["reason"] != "突破 {前高}, ]" {
+		t.Fatalf("args = %v, err = %v", args, err)
+	}
+
+	if _, _, err := parseToolArguments(`{"symbol": "BTCUSDT"`); err == nil {
+		t.Fatal("truncated JSON should fail")
+	}
+}
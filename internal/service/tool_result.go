@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dushixiang/prism/internal/models"
+)
+
+// toolResultSchemaVersion 工具结果结构的版本号，结果字段有不兼容变更时递增，便于分析历史 ToolResponses
+const toolResultSchemaVersion = 1
+
+// ToolResult 工具调用结果的统一信封
+//
+// 所有工具（含参数解析失败、规则拒绝和执行出错）都按该结构返回给模型，并原样记录到 LLM 日志。
+// Data 为各工具的结果结构，失败时 Error 不为空，Data 可能携带被拒绝时的上下文。
+type ToolResult struct {
+	SchemaVersion int          `json:"schema_version"`
+	Success       bool         `json:"success"`
+	Action        string       `json:"action"`            // 工具名
+	Message       string       `json:"message,omitempty"` // 执行结果的简要说明
+	Data          interface{}  `json:"data,omitempty"`
+	Error         *ErrorResult `json:"error,omitempty"`
+}
+
+// ErrorResult 工具执行失败或被拒绝的原因
+type ErrorResult struct {
+	Message string `json:"message"`
+}
+
+// OpenResult openPosition 成功时的结果
+type OpenResult struct {
+	OrderID            int64                 `json:"order_id"`
+	Symbol             string                `json:"symbol"`
+	Side               string                `json:"side"`
+	Price              float64               `json:"price"`
+	Quantity           float64               `json:"quantity"`         // 实际成交数量
+	OrderedQuantity    float64               `json:"ordered_quantity"` // 下单数量
+	PartialFill        bool                  `json:"partial_fill,omitempty"`
+	Leverage           int                   `json:"leverage"`
+	StopLossPrice      float64               `json:"stop_loss_price"`
+	StopType           string                `json:"stop_type"`
+	TakeProfitPrice    float64               `json:"take_profit_price"`
+	InvalidationPrice  float64               `json:"invalidation_price"`
+	Confidence         int                   `json:"confidence"`
+	SizeMultiplier     float64               `json:"size_multiplier"`
+	SizingMode         string                `json:"sizing_mode"`
+	RequestedQuantity  float64               `json:"requested_quantity"`
+	StopLossOrderID    int64                 `json:"stop_loss_order_id"`
+	TakeProfitOrderID  int64                 `json:"take_profit_order_id"`
+	ImpliedRiskPercent float64               `json:"implied_risk_percent"` // 按实际成交计算的止损风险占净值%
+	AutoLeverage       *autoLeverageResult   `json:"auto_leverage,omitempty"`
+	HTFTrend           *HigherTimeframeTrend `json:"htf_trend,omitempty"`     // 开启逆趋势检查时的1小时趋势状态
+	ExpectedPrice      float64               `json:"expected_price"`          // 下单前的参考价格
+	SlippagePercent    float64               `json:"slippage_percent"`        // 成交滑点（%），正数表示不利
+	StopAdjusted       bool                  `json:"stop_adjusted,omitempty"` // 滑点超限后止损已按成交价平移，stop_loss_price 为平移后的价格
+	StopLossPercent    float64               `json:"stop_loss_percent,omitempty"`
+	TakeProfitPercent  float64               `json:"take_profit_percent,omitempty"`
+	StopReset          bool                  `json:"stop_reset,omitempty"`          // 止损位于成交价错误一侧，已从成交价按ATR重设
+	TakeProfitDropped  bool                  `json:"take_profit_dropped,omitempty"` // 止盈位于成交价错误一侧，未创建止盈单
+	TakeProfitLevels   []LadderLevelResult   `json:"take_profit_levels,omitempty"`  // 分批止盈各档的价格、数量和创建情况
+	VolatilitySpike    *VolatilitySpike      `json:"volatility_spike,omitempty"`    // 开启波动检查时的1小时ATR放大情况
+	VolatilityMargin   float64               `json:"volatility_margin,omitempty"`   // 波动异常放大时保证金缩减到的比例，未缩减时省略
+}
+
+// LadderLevelResult openPosition 创建的一档分批止盈单
+type LadderLevelResult struct {
+	Level             int     `json:"level"`
+	Price             float64 `json:"price"`
+	Quantity          float64 `json:"quantity"`
+	AllocationPercent float64 `json:"allocation_percent"`
+	Created           bool    `json:"created"` // 止盈单是否已在交易所创建
+}
+
+// OpenRejectedResult openPosition 被开仓前规则拒绝时的上下文
+type OpenRejectedResult struct {
+	Symbol        string                `json:"symbol"`
+	Side          string                `json:"side"`
+	RejectedRules []RuleResult          `json:"rejected_rules"`
+	RuleResults   []RuleResult          `json:"rule_results"`
+	HTFTrend      *HigherTimeframeTrend `json:"htf_trend,omitempty"`        // 开启逆趋势检查时的1小时趋势状态
+	Volatility    *VolatilitySpike      `json:"volatility_spike,omitempty"` // 开启波动检查时的1小时ATR放大情况
+}
+
+// CloseResult closePosition 成功时的结果
+type CloseResult struct {
+	OrderID     int64                  `json:"order_id,omitempty"`
+	Symbol      string                 `json:"symbol"`
+	Pnl         float64                `json:"pnl"`
+	Reason      string                 `json:"reason"`
+	ReasonCode  models.CloseReasonCode `json:"reason_code"`
+	AlreadyFlat bool                   `json:"already_flat,omitempty"` // 交易所已无该持仓，只清理了遗留订单
+	Execution   string                 `json:"execution,omitempty"`    // 按 TWAP 执行时为 twap
+	Quantity    float64                `json:"quantity,omitempty"`     // TWAP 实际平掉的总数量
+	Price       float64                `json:"price,omitempty"`        // TWAP 子订单成交量加权均价
+	Slices      []CloseSlice           `json:"slices,omitempty"`       // TWAP 各笔子订单成交
+}
+
+// CloseAllResult closeAllPositions 的结果
+type CloseAllResult struct {
+	Closed     int                    `json:"closed"` // 已平掉（含交易所已无持仓、只清理订单）的持仓数
+	Failed     int                    `json:"failed"`
+	TotalPnl   float64                `json:"total_pnl"`
+	Reason     string                 `json:"reason"`
+	ReasonCode models.CloseReasonCode `json:"reason_code"`
+	Results    []CloseAllItem         `json:"results"`
+}
+
+// CloseAllItem closeAllPositions 中单个持仓的平仓结果
+type CloseAllItem struct {
+	Symbol      string  `json:"symbol"`
+	Side        string  `json:"side"`
+	OrderID     int64   `json:"order_id,omitempty"`
+	Pnl         float64 `json:"pnl"`
+	AlreadyFlat bool    `json:"already_flat,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// UpdateStopsResult updateStopOrders 成功时的结果
+type UpdateStopsResult struct {
+	Symbol        string  `json:"symbol"`
+	OldStopLoss   float64 `json:"old_stop_loss"`
+	NewStopLoss   float64 `json:"new_stop_loss"`
+	OldTakeProfit float64 `json:"old_take_profit"`
+	NewTakeProfit float64 `json:"new_take_profit"`
+	StopType      string  `json:"stop_type"`
+	Reason        string  `json:"reason"`
+}
+
+// AdjustLeverageResult adjustLeverage 成功时的结果
+type AdjustLeverageResult struct {
+	Symbol           string  `json:"symbol"`
+	OldLeverage      int     `json:"old_leverage"`
+	Leverage         int     `json:"leverage"`
+	OldMargin        float64 `json:"old_margin"`
+	Margin           float64 `json:"margin"`
+	LiquidationPrice float64 `json:"liquidation_price"`
+	Reason           string  `json:"reason"`
+}
+
+// RecentDecisionsResult getRecentDecisions 的结果
+type RecentDecisionsResult struct {
+	Count     int                      `json:"count"`
+	Decisions []map[string]interface{} `json:"decisions"`
+}
+
+// KlinesResult getKlines 的结果
+type KlinesResult struct {
+	Symbol        string                 `json:"symbol"`
+	Interval      string                 `json:"interval"`
+	Count         int                    `json:"count"`
+	Summary       map[string]interface{} `json:"summary"`
+	CandleFields  []string               `json:"candle_fields"`
+	RecentCandles [][]interface{}        `json:"recent_candles"`
+	Indicators    map[string]interface{} `json:"indicators,omitempty"` // K线不足以计算指标时为空
+}
+
+// AccountRiskResult getAccountRisk 的结果
+type AccountRiskResult struct {
+	Equity             float64 `json:"equity"`
+	Available          float64 `json:"available"`
+	MarginUsed         float64 `json:"margin_used"`
+	UnrealizedPnl      float64 `json:"unrealized_pnl"`
+	GrossNotional      float64 `json:"gross_notional"`
+	GrossLeverage      float64 `json:"gross_leverage"` // 名义价值合计 / 净值
+	TotalStopRisk      float64 `json:"total_stop_risk"`
+	TotalStopRiskPct   float64 `json:"total_stop_risk_pct"`
+	UnprotectedCount   int     `json:"unprotected_count"`
+	PositionCount      int     `json:"position_count"`
+	MaxPositions       int     `json:"max_positions"` // 0表示不限制
+	DrawdownFromPeak   float64 `json:"drawdown_from_peak"`
+	MaxDrawdownPercent float64 `json:"max_drawdown_percent"`
+	CloseOnly          bool    `json:"close_only,omitempty"`
+}
+
+// toolDescription 工具描述，附带返回结构说明，便于模型按固定字段解析结果
+func toolDescription(lang, action string) string {
+	return localize(lang, "tool."+action) + " " + localizef(lang, "tool.result", toolResultSchemaVersion) + localize(lang, "result."+action)
+}
+
+// newToolResult 构建工具执行成功的结果
+func newToolResult(action, message string, data interface{}) *ToolResult {
+	return &ToolResult{
+		SchemaVersion: toolResultSchemaVersion,
+		Success:       true,
+		Action:        action,
+		Message:       message,
+		Data:          data,
+	}
+}
+
+// toolErrorResult 构建工具执行失败的结果，data 为可选的失败上下文
+func toolErrorResult(action, message string, data interface{}) *ToolResult {
+	return &ToolResult{
+		SchemaVersion: toolResultSchemaVersion,
+		Success:       false,
+		Action:        action,
+		Data:          data,
+		Error:         &ErrorResult{Message: message},
+	}
+}
+
+// errorMessage 返回失败原因，成功时为空
+func (r *ToolResult) errorMessage() string {
+	if r == nil || r.Error == nil {
+		return ""
+	}
+	return r.Error.Message
+}
+
+// marshalToolResult 将工具结果序列化为返回给模型的 JSON
+func marshalToolResult(result *ToolResult) string {
+	data, err := json.Marshal(result)
+	if err != nil {
+		// 结果结构只包含可序列化的字段，这里只是兜底，保证模型总能拿到信封
+		data, _ = json.Marshal(toolErrorResult(result.Action, fmt.Sprintf("failed to marshal tool result: %v", err), nil))
+	}
+	return string(data)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToolResultEnvelope(t *testing.T) {
+	req := &openRequest{Symbol: "BTCUSDT", Side: "long", Language: PromptLanguageZh}
+	rejected := []RuleResult{{Rule: "max_positions", Message: "持仓数量已达上限"}}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(marshalToolResult(rejectedOpenResult(req, rejected, rejected))), &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded["schema_version"] != float64(toolResultSchemaVersion) || decoded["success"] != false || decoded["action"] != "openPosition" {
+		t.Fatalf("unexpected envelope: %v", decoded)
+	}
+	errObj, _ := decoded["error"].(map[string]interface{})
+	if msg, _ := errObj["message"].(string); msg == "" {
+		t.Fatalf("rejected result should carry error.message, got %v", decoded["error"])
+	}
+	data, _ := decoded["data"].(map[string]interface{})
+	if data["symbol"] != "BTCUSDT" || data["rejected_rules"] == nil {
+		t.Fatalf("rejected result should carry rule context, got %v", data)
+	}
+
+	ok := newToolResult("updateStopOrders", "done", &UpdateStopsResult{Symbol: "BTCUSDT", NewStopLoss: 98})
+	var decodedOK map[string]interface{}
+	if err := json.Unmarshal([]byte(marshalToolResult(ok)), &decodedOK); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decodedOK["success"] != true || decodedOK["error"] != nil || decodedOK["message"] != "done" {
+		t.Fatalf("unexpected success envelope: %v", decodedOK)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
+	"github.com/dushixiang/prism/internal/config"
 	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/repo"
 	"github.com/dushixiang/prism/pkg/exchange"
@@ -23,30 +25,95 @@ type TradingAccountService struct {
 	*orz.Service
 	*repo.AccountHistoryRepo
 
-	exchange exchange.Exchange
+	fundingRepo        *repo.FundingPaymentRepo
+	capitalFlowRepo    *repo.CapitalFlowRepo
+	positionRepo       *repo.PositionRepo
+	exchange           exchange.Exchange
+	adminConfigService *AdminConfigService
+	conf               *config.Config
 }
 
 // NewTradingAccountService 创建交易账户服务
-func NewTradingAccountService(db *gorm.DB, exchange exchange.Exchange, logger *zap.Logger) *TradingAccountService {
+func NewTradingAccountService(db *gorm.DB, exchange exchange.Exchange, adminConfigService *AdminConfigService,
+	conf *config.Config, logger *zap.Logger) *TradingAccountService {
 	return &TradingAccountService{
 		logger:             logger,
 		Service:            orz.NewService(db),
 		AccountHistoryRepo: repo.NewAccountHistoryRepo(db),
+		fundingRepo:        repo.NewFundingPaymentRepo(db),
+		capitalFlowRepo:    repo.NewCapitalFlowRepo(db),
+		positionRepo:       repo.NewPositionRepo(db),
 		exchange:           exchange,
+		adminConfigService: adminConfigService,
+		conf:               conf,
 	}
 }
 
+// PositionFunding 按资金费流水统计各持仓开仓以来的累计资金费（正数为净收入，负数为净支出），键为持仓ID
+//
+// 资金费流水只按交易对记录，同一交易对同时持有多空仓位时两者都计入该交易对的全部资金费。
+func (s *TradingAccountService) PositionFunding(ctx context.Context, positions []models.Position) map[string]float64 {
+	result := make(map[string]float64, len(positions))
+	for i := range positions {
+		position := &positions[i]
+		amount, err := s.fundingRepo.SumBySymbolSince(ctx, position.Symbol, position.OpenedAt)
+		if err != nil {
+			s.logger.Warn("failed to sum position funding",
+				zap.String("symbol", position.Symbol),
+				zap.Error(err))
+			continue
+		}
+		result[position.ID] = amount
+	}
+	return result
+}
+
 // AccountMetrics 账户指标
 type AccountMetrics struct {
-	TotalBalance        float64 `json:"total_balance"`         // 账户总净值（包含未实现盈亏）
+	TotalBalance        float64 `json:"total_balance"`         // 账户净值 = 保证金余额（钱包余额 + 未实现盈亏）
+	WalletBalance       float64 `json:"wallet_balance"`        // 钱包余额（不含未实现盈亏）
 	Available           float64 `json:"available"`             // 可用余额
 	UnrealisedPnl       float64 `json:"unrealised_pnl"`        // 未实现盈亏
-	InitialBalance      float64 `json:"initial_balance"`       // 初始资金
+	InitialBalance      float64 `json:"initial_balance"`       // 初始资金（已计入锚点之后的出入金）
+	NetDeposits         float64 `json:"net_deposits"`          // 锚点之后的净入金（负数为净出金）
 	PeakBalance         float64 `json:"peak_balance"`          // 峰值资金
 	ReturnPercent       float64 `json:"return_percent"`        // 收益率
 	DrawdownFromPeak    float64 `json:"drawdown_from_peak"`    // 从峰值的回撤
 	DrawdownFromInitial float64 `json:"drawdown_from_initial"` // 从初始的回撤
-	SharpeRatio         float64 `json:"sharpe_ratio"`          // 夏普比率
+	SharpeRatio         float64 `json:"sharpe_ratio"`          // 夏普比率（每周期，已扣除无风险利率）
+	SortinoRatio        float64 `json:"sortino_ratio"`         // 索提诺比率（每周期，已扣除无风险利率）
+	CumulativeFunding   float64 `json:"cumulative_funding"`    // 累计资金费（正数为净收入，负数为净支出）
+	TotalStopRisk       float64 `json:"total_stop_risk"`       // 所有持仓同时触及止损时从当前价格起的合计亏损(USDT)
+	TotalStopRiskPct    float64 `json:"total_stop_risk_pct"`   // 合计止损风险占净值%
+	UnprotectedCount    int     `json:"unprotected_count"`     // 未设置止损的持仓数，其风险未计入合计止损风险
+	MarginUsed          float64 `json:"margin_used"`           // 持仓占用的保证金合计(USDT)
+	CapitalUtilization  float64 `json:"capital_utilization"`   // 资金使用率：已用保证金占净值%
+	IdleCapital         float64 `json:"idle_capital"`          // 合约账户之外的闲置资金(USDT)，未配置时为0
+	TotalCapital        float64 `json:"total_capital"`         // 总资金 = 净值 + 闲置资金
+	TotalCapitalReturn  float64 `json:"total_capital_return"`  // 按总资金计算的收益率%：合约盈亏 / (初始资金 + 闲置资金)
+	Mode                string  `json:"mode"`                  // 交易模式：paper/live
+
+	SnapshotAt *time.Time `json:"snapshot_at,omitempty"` // 降级快照对应的账户历史记录时间，为空表示实时数据
+}
+
+// applyCapitalUsage 计算资金使用率，以及计入合约账户之外闲置资金后的总资金和收益率
+func (m *AccountMetrics) applyCapitalUsage(marginUsed, idleCapital float64) {
+	m.MarginUsed = marginUsed
+	if m.TotalBalance > 0 {
+		m.CapitalUtilization = marginUsed / m.TotalBalance * 100
+	}
+
+	idleCapital = max(idleCapital, 0)
+	m.IdleCapital = idleCapital
+	m.TotalCapital = m.TotalBalance + idleCapital
+	if base := m.InitialBalance + idleCapital; base > 0 {
+		m.TotalCapitalReturn = (m.TotalBalance - m.InitialBalance) / base * 100
+	}
+}
+
+// Degraded 是否为获取实时数据失败后使用的降级快照
+func (m *AccountMetrics) Degraded() bool {
+	return m.SnapshotAt != nil
 }
 
 // GetAccountMetrics 获取账户指标
@@ -57,34 +124,17 @@ func (s *TradingAccountService) GetAccountMetrics(ctx context.Context) (*Account
 		return nil, fmt.Errorf("failed to get account info: %w", err)
 	}
 
-	// 账户总净值（包含未实现盈亏）
-	// 注意：accountInfo.TotalBalance 从交易所返回时已经包含了未实现盈亏
-	totalBalance := accountInfo.TotalBalance
-
-	// 从数据库获取初始资金和峰值资金
-	// 获取初始资金（第一条记录）
-	firstHistory, err := s.AccountHistoryRepo.FindInitialBalance(ctx)
-
-	initialBalance := totalBalance
-	if err == nil {
-		initialBalance = firstHistory.TotalBalance
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		s.logger.Warn("failed to get initial balance", zap.Error(err))
-	}
+	// 账户净值统一使用保证金余额（钱包余额 + 未实现盈亏），实盘和纸钱包口径一致，
+	// 收益率、回撤和账户历史都基于该净值，避免未实现盈亏时有时无造成曲线跳变
+	totalBalance := accountInfo.MarginBalance
 
-	// 获取峰值资金
-	peakHistory, err := s.AccountHistoryRepo.FindPeakBalance(ctx)
+	// 初始资金 = 锚点资金 + 锚点之后的净入金，出入金不计入收益
+	initialBalance, netDeposits := s.capitalBase(ctx, totalBalance)
 
+	// 获取峰值资金，每条历史之后的出入金都计入该条净值再取最大值，避免出金被当作回撤
 	peakBalance := totalBalance
-	if err == nil && peakHistory.TotalBalance > totalBalance {
-		peakBalance = peakHistory.TotalBalance
-	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		s.logger.Warn("failed to get peak balance", zap.Error(err))
-	}
-
-	// 更新峰值（如果当前余额更高）
-	if totalBalance > peakBalance {
-		peakBalance = totalBalance
+	if peak, ok := s.flowAdjustedPeak(ctx); ok && peak > totalBalance {
+		peakBalance = peak
 	}
 
 	// 计算收益率
@@ -104,66 +154,354 @@ func (s *TradingAccountService) GetAccountMetrics(ctx context.Context) (*Account
 		drawdownFromInitial = (totalBalance - initialBalance) / initialBalance * 100
 	}
 
-	// 计算Sharpe Ratio
-	sharpeRatio := s.calculateSharpeRatio(ctx)
+	// 计算Sharpe Ratio和Sortino Ratio
+	sharpe, sortino := s.calculateRiskAdjustedRatios(ctx)
+
+	cumulativeFunding, err := s.fundingRepo.SumAmount(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get cumulative funding", zap.Error(err))
+	}
+
+	var totalStopRisk, totalStopRiskPct, marginUsed float64
+	var unprotectedCount int
+	if positions, err := s.positionRepo.FindAll(ctx); err != nil {
+		s.logger.Warn("failed to get positions for stop risk", zap.Error(err))
+	} else {
+		totalStopRisk, unprotectedCount = totalPositionStopRisk(positions)
+		marginUsed = totalPositionMargin(positions)
+		if totalBalance > 0 {
+			totalStopRiskPct = totalStopRisk / totalBalance * 100
+		}
+	}
 
 	metrics := &AccountMetrics{
 		TotalBalance:        totalBalance,
+		WalletBalance:       accountInfo.WalletBalance,
 		Available:           accountInfo.AvailableBalance,
 		UnrealisedPnl:       accountInfo.UnrealizedPnl,
 		InitialBalance:      initialBalance,
+		NetDeposits:         netDeposits,
 		PeakBalance:         peakBalance,
 		ReturnPercent:       returnPercent,
 		DrawdownFromPeak:    drawdownFromPeak,
 		DrawdownFromInitial: drawdownFromInitial,
-		SharpeRatio:         sharpeRatio,
+		SharpeRatio:         sharpe,
+		SortinoRatio:        sortino,
+		CumulativeFunding:   cumulativeFunding,
+		TotalStopRisk:       totalStopRisk,
+		TotalStopRiskPct:    totalStopRiskPct,
+		UnprotectedCount:    unprotectedCount,
+		Mode:                s.conf.TradingMode(),
 	}
+	metrics.applyCapitalUsage(marginUsed, s.conf.Trading.IdleCapital)
 
 	return metrics, nil
 }
 
-// calculateSharpeRatio 计算夏普比率
-func (s *TradingAccountService) calculateSharpeRatio(ctx context.Context) float64 {
-	histories, err := s.AccountHistoryRepo.FindAllOrderByRecordedAt(ctx)
+// DegradedAccountMetrics 使用最近一条账户历史构建降级的账户指标，用于交易所暂时获取不到账户信息时继续执行风控
+//
+// 账户历史早于 maxAge 时返回错误；止损风险和累计资金费按本地数据实时计算。
+func (s *TradingAccountService) DegradedAccountMetrics(ctx context.Context, maxAge time.Duration) (*AccountMetrics, error) {
+	history, err := s.AccountHistoryRepo.FindLatest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest account history: %w", err)
+	}
+	if age := time.Since(history.RecordedAt); age > maxAge {
+		return nil, fmt.Errorf("latest account history is %s old, exceeds %s", age.Round(time.Second), maxAge)
+	}
+
+	metrics := &AccountMetrics{
+		TotalBalance:        history.TotalBalance,
+		WalletBalance:       history.TotalBalance - history.UnrealisedPnl,
+		Available:           history.Available,
+		UnrealisedPnl:       history.UnrealisedPnl,
+		InitialBalance:      history.InitialBalance,
+		PeakBalance:         history.PeakBalance,
+		ReturnPercent:       history.ReturnPercent,
+		DrawdownFromPeak:    history.DrawdownFromPeak,
+		DrawdownFromInitial: history.DrawdownFromInitial,
+		SharpeRatio:         history.SharpeRatio,
+		Mode:                s.conf.TradingMode(),
+		SnapshotAt:          &history.RecordedAt,
+	}
+	if metrics.CumulativeFunding, err = s.fundingRepo.SumAmount(ctx); err != nil {
+		s.logger.Warn("failed to get cumulative funding", zap.Error(err))
+	}
+	marginUsed := 0.0
+	if positions, err := s.positionRepo.FindAll(ctx); err != nil {
+		s.logger.Warn("failed to get positions for stop risk", zap.Error(err))
+	} else {
+		metrics.TotalStopRisk, metrics.UnprotectedCount = totalPositionStopRisk(positions)
+		if metrics.TotalBalance > 0 {
+			metrics.TotalStopRiskPct = metrics.TotalStopRisk / metrics.TotalBalance * 100
+		}
+		marginUsed = totalPositionMargin(positions)
+	}
+	metrics.applyCapitalUsage(marginUsed, s.conf.Trading.IdleCapital)
+	return metrics, nil
+}
+
+// totalPositionMargin 汇总所有持仓占用的保证金
+func totalPositionMargin(positions []models.Position) float64 {
+	total := 0.0
+	for i := range positions {
+		total += positions[i].Margin
+	}
+	return total
+}
+
+// totalPositionStopRisk 汇总所有持仓触及止损时的亏损，返回合计亏损和未设置止损的持仓数
+func totalPositionStopRisk(positions []models.Position) (float64, int) {
+	total := 0.0
+	unprotected := 0
+	for i := range positions {
+		risk, ok := positions[i].StopRisk()
+		if !ok {
+			unprotected++
+			continue
+		}
+		total += risk
+	}
+	return total, unprotected
+}
+
+// capitalBase 计算收益率基准，返回计入出入金后的初始资金和锚点之后的净入金
+//
+// 管理后台设置了初始资金时以其为锚点（锚点时间之后的出入金计入），否则以第一条账户历史为锚点；
+// 都没有时以当前净值为基准。
+func (s *TradingAccountService) capitalBase(ctx context.Context, totalBalance float64) (float64, float64) {
+	tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get trading config for initial balance", zap.Error(err))
+	} else if tradingConfig.InitialBalance > 0 {
+		anchorAt := time.Time{}
+		if tradingConfig.InitialBalanceAt != nil {
+			anchorAt = *tradingConfig.InitialBalanceAt
+		}
+		netDeposits := s.netFlowsSince(ctx, anchorAt)
+		return tradingConfig.InitialBalance + netDeposits, netDeposits
+	}
+
+	firstHistory, err := s.AccountHistoryRepo.FindInitialBalance(ctx)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("failed to get initial balance", zap.Error(err))
+		}
+		return totalBalance, 0
+	}
+	netDeposits := s.netFlowsSince(ctx, firstHistory.RecordedAt)
+	return firstHistory.TotalBalance + netDeposits, netDeposits
+}
+
+// flowAdjustedPeak 查询账户历史和出入金计算调整后的峰值净值，没有历史或查询失败时返回 false
+func (s *TradingAccountService) flowAdjustedPeak(ctx context.Context) (float64, bool) {
+	histories, err := s.AccountHistoryRepo.FindBalanceSeries(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get balance history for peak", zap.Error(err))
+		return 0, false
+	}
+	if len(histories) == 0 {
+		return 0, false
+	}
+	flows, err := s.capitalFlowRepo.FindAllOrderByOccurredAt(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get capital flows for peak", zap.Error(err))
+	}
+	return peakBalanceNetOfFlows(histories, flows), true
+}
+
+// peakBalanceNetOfFlows 按当前资金口径计算历史峰值：每条历史净值加上其记录时间之后的净入金，取最大值
+//
+// 只按净值最高的一条记录调整会低估出金后的回撤：1000 出金500后涨到600再跌到550，
+// 峰值应为600（1000-500 与 600 取大），而不是 1000-500=500。
+func peakBalanceNetOfFlows(histories []models.AccountHistory, flows []models.CapitalFlow) float64 {
+	sorted := make([]models.CapitalFlow, len(flows))
+	copy(sorted, flows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OccurredAt.Before(sorted[j].OccurredAt)
+	})
+
+	// 从最近的历史往前遍历，累计记录时间之后的出入金
+	peak := math.Inf(-1)
+	after := 0.0
+	next := len(sorted) - 1
+	for i := len(histories) - 1; i >= 0; i-- {
+		for next >= 0 && sorted[next].OccurredAt.After(histories[i].RecordedAt) {
+			after += sorted[next].Amount
+			next--
+		}
+		peak = max(peak, histories[i].TotalBalance+after)
+	}
+	return peak
+}
+
+// netFlowsSince 统计指定时间之后的净入金，查询失败时按0处理
+func (s *TradingAccountService) netFlowsSince(ctx context.Context, since time.Time) float64 {
+	total, err := s.capitalFlowRepo.SumAmountSince(ctx, since)
+	if err != nil {
+		s.logger.Warn("failed to sum capital flows", zap.Error(err))
+		return 0
+	}
+	return total
+}
+
+// ListCapitalFlows 获取全部出入金记录
+func (s *TradingAccountService) ListCapitalFlows(ctx context.Context) ([]models.CapitalFlow, error) {
+	return s.capitalFlowRepo.FindAllOrderByOccurredAt(ctx)
+}
+
+// RecordCapitalFlow 手动录入一笔出入金，amount 正数为入金、负数为出金，occurredAt 为零值时使用当前时间
+func (s *TradingAccountService) RecordCapitalFlow(ctx context.Context, amount float64, note string, occurredAt time.Time) (*models.CapitalFlow, error) {
+	if amount == 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, fmt.Errorf("出入金金额必须为非零数字")
+	}
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	flow := &models.CapitalFlow{
+		ID:         ulid.Make().String(),
+		Source:     models.CapitalFlowSourceManual,
+		Amount:     amount,
+		Asset:      "USDT",
+		Note:       note,
+		OccurredAt: occurredAt,
+	}
+	if err := s.capitalFlowRepo.Create(ctx, flow); err != nil {
+		return nil, err
+	}
+	return flow, nil
+}
+
+// DeleteCapitalFlow 删除手动录入的出入金记录，交易所同步的记录不允许删除
+func (s *TradingAccountService) DeleteCapitalFlow(ctx context.Context, id string) error {
+	flow, err := s.capitalFlowRepo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if flow.Source != models.CapitalFlowSourceManual {
+		return fmt.Errorf("只能删除手动录入的出入金记录")
+	}
+	return s.capitalFlowRepo.DeleteById(ctx, id)
+}
+
+// minutesPerYear 年化换算使用的分钟数（365天，加密市场全年无休）
+const minutesPerYear = 365 * 24 * 60
+
+// calculateRiskAdjustedRatios 基于账户历史计算夏普比率和索提诺比率
+//
+// 收益率按相邻两条账户历史（即每个交易周期）计算，两个比率都是每周期口径，不做年化；
+// 配置的年化无风险利率按交易周期间隔折算成每周期利率后从每期收益中扣除。
+// 开启账户历史降采样时只使用保留完整精度的区间，降采样后每天一条的记录不再是相邻周期。
+func (s *TradingAccountService) calculateRiskAdjustedRatios(ctx context.Context) (float64, float64) {
+	var histories []models.AccountHistory
+	var err error
+	if fullDays := s.conf.Retention.AccountHistoryFullDays; fullDays > 0 {
+		histories, err = s.AccountHistoryRepo.FindRecordedSinceOrderByRecordedAt(ctx, time.Now().AddDate(0, 0, -fullDays))
+	} else {
+		histories, err = s.AccountHistoryRepo.FindAllOrderByRecordedAt(ctx)
+	}
 
 	if err != nil || len(histories) < 2 {
-		return 0.0
+		return 0.0, 0.0
 	}
 
-	// 计算每次的收益率
-	returns := make([]float64, 0, len(histories)-1)
+	flows, err := s.capitalFlowRepo.FindAllOrderByOccurredAt(ctx)
+	if err != nil {
+		s.logger.Warn("failed to get capital flows for risk-adjusted ratios", zap.Error(err))
+	}
+	returns := periodReturns(histories, flows)
+
+	riskFreeRate := 0.0
+	if s.conf.Trading.RiskFreeRatePercent != 0 {
+		tradingConfig, err := s.adminConfigService.GetTradingConfig(ctx)
+		if err != nil {
+			s.logger.Warn("failed to get trading config for risk-free rate", zap.Error(err))
+		} else {
+			riskFreeRate = periodRiskFreeRate(s.conf.Trading.RiskFreeRatePercent, tradingConfig.IntervalMinutes)
+		}
+	}
+
+	return sharpeRatio(returns, riskFreeRate), sortinoRatio(returns, riskFreeRate)
+}
+
+// periodReturns 按相邻两条账户历史计算每周期收益率，周期内发生的出入金从净值变化中扣除
+func periodReturns(histories []models.AccountHistory, flows []models.CapitalFlow) []float64 {
+	returns := make([]float64, 0, len(histories))
 	for i := 1; i < len(histories); i++ {
-		if histories[i-1].TotalBalance > 0 {
-			ret := (histories[i].TotalBalance - histories[i-1].TotalBalance) / histories[i-1].TotalBalance
-			returns = append(returns, ret)
+		prev, cur := histories[i-1], histories[i]
+		if prev.TotalBalance <= 0 {
+			continue
 		}
+		change := cur.TotalBalance - prev.TotalBalance
+		for _, flow := range flows {
+			if flow.OccurredAt.After(prev.RecordedAt) && !flow.OccurredAt.After(cur.RecordedAt) {
+				change -= flow.Amount
+			}
+		}
+		returns = append(returns, change/prev.TotalBalance)
+	}
+	return returns
+}
+
+// periodRiskFreeRate 将年化无风险利率（%）按复利折算为每个交易周期的利率：(1+年化)^(周期/一年)-1
+func periodRiskFreeRate(annualPercent float64, intervalMinutes int) float64 {
+	if annualPercent == 0 || intervalMinutes <= 0 {
+		return 0
 	}
+	return math.Pow(1+annualPercent/100, float64(intervalMinutes)/minutesPerYear) - 1
+}
 
+// sharpeRatio 计算每周期夏普比率：超额收益均值 / 超额收益标准差
+func sharpeRatio(returns []float64, riskFreeRate float64) float64 {
 	if len(returns) == 0 {
 		return 0.0
 	}
 
-	// 计算平均收益率
+	// 计算平均超额收益率
 	sum := 0.0
 	for _, r := range returns {
-		sum += r
+		sum += r - riskFreeRate
 	}
-	avgReturn := sum / float64(len(returns))
+	avgExcess := sum / float64(len(returns))
 
 	// 计算标准差
 	variance := 0.0
 	for _, r := range returns {
-		variance += math.Pow(r-avgReturn, 2)
+		variance += math.Pow(r-riskFreeRate-avgExcess, 2)
 	}
 	variance /= float64(len(returns))
 	stdDev := math.Sqrt(variance)
 
-	// 计算Sharpe Ratio（假设无风险利率为0）
 	if stdDev == 0 {
 		return 0.0
 	}
 
-	return avgReturn / stdDev
+	return avgExcess / stdDev
+}
+
+// sortinoRatio 计算每周期索提诺比率：超额收益均值 / 下行偏差，下行偏差只统计低于无风险利率的周期
+func sortinoRatio(returns []float64, riskFreeRate float64) float64 {
+	if len(returns) == 0 {
+		return 0.0
+	}
+
+	sum := 0.0
+	downside := 0.0
+	for _, r := range returns {
+		excess := r - riskFreeRate
+		sum += excess
+		if excess < 0 {
+			downside += excess * excess
+		}
+	}
+	avgExcess := sum / float64(len(returns))
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+
+	if downsideDev == 0 {
+		return 0.0
+	}
+
+	return avgExcess / downsideDev
 }
 
 // SaveAccountHistory 保存账户历史记录
@@ -191,6 +529,15 @@ func (s *TradingAccountService) GetAccountHistories(ctx context.Context) ([]mode
 	return s.AccountHistoryRepo.FindAllOrderByRecordedAt(ctx)
 }
 
+// GetDrawdownAnalysis 获取回撤分析（水下曲线、最长水下时间、最大回撤区间）
+func (s *TradingAccountService) GetDrawdownAnalysis(ctx context.Context) (*DrawdownAnalysis, error) {
+	histories, err := s.AccountHistoryRepo.FindAllOrderByRecordedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return analyzeDrawdown(histories), nil
+}
+
 // CheckStopLoss 检查账户止损线
 func (s *TradingAccountService) CheckStopLoss(metrics *AccountMetrics, stopLossUSDT float64) bool {
 	return metrics.TotalBalance <= stopLossUSDT
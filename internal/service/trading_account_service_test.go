@@ -0,0 +1,507 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
+	"github.com/dushixiang/prism/pkg/exchange"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestPeriodRiskFreeRate(t *testing.T) {
+	if got := periodRiskFreeRate(0, 15); got != 0 {
+		t.Fatalf("zero annual rate = %v, want 0", got)
+	}
+	if got := periodRiskFreeRate(5, 0); got != 0 {
+		t.Fatalf("zero interval = %v, want 0", got)
+	}
+
+	// 按周期复利一年后应回到年化利率
+	perPeriod := periodRiskFreeRate(5, 15)
+	periods := float64(minutesPerYear) / 15
+	if annual := math.Pow(1+perPeriod, periods) - 1; math.Abs(annual-0.05) > 1e-9 {
+		t.Fatalf("compounded annual rate = %v, want 0.05", annual)
+	}
+}
+
+func TestRiskAdjustedRatios(t *testing.T) {
+	returns := []float64{0.02, -0.01, 0.03, -0.02}
+
+	// 均值0.005，标准差 = sqrt((0.015²+0.015²+0.025²+0.025²)/4)
+	stdDev := math.Sqrt((0.000225*2 + 0.000625*2) / 4)
+	if got := sharpeRatio(returns, 0); math.Abs(got-0.005/stdDev) > 1e-9 {
+		t.Fatalf("sharpe without risk-free = %v, want %v", got, 0.005/stdDev)
+	}
+	// 扣除无风险利率只平移均值，不改变标准差
+	if got := sharpeRatio(returns, 0.005); math.Abs(got) > 1e-9 {
+		t.Fatalf("sharpe with risk-free = %v, want 0", got)
+	}
+
+	// 下行偏差 = sqrt((0.01²+0.02²)/4)
+	downside := math.Sqrt((0.0001 + 0.0004) / 4)
+	if got := sortinoRatio(returns, 0); math.Abs(got-0.005/downside) > 1e-9 {
+		t.Fatalf("sortino without risk-free = %v, want %v", got, 0.005/downside)
+	}
+	if got := sortinoRatio([]float64{0.01, 0.02}, 0); got != 0 {
+		t.Fatalf("sortino without downside = %v, want 0", got)
+	}
+	if got := sortinoRatio([]float64{0.01, 0.02}, 0.02); got >= 0 {
+		t.Fatalf("sortino below risk-free = %v, want negative", got)
+	}
+}
+
+func TestPeriodReturnsExcludesCapitalFlows(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	histories := []models.AccountHistory{
+		{TotalBalance: 1000, RecordedAt: base},
+		{TotalBalance: 1550, RecordedAt: base.Add(time.Hour)},
+		{TotalBalance: 1240, RecordedAt: base.Add(2 * time.Hour)},
+	}
+	flows := []models.CapitalFlow{
+		{Amount: 500, OccurredAt: base.Add(30 * time.Minute)},
+		{Amount: -300, OccurredAt: base.Add(90 * time.Minute)},
+	}
+
+	// 第一个周期入金500，实际收益50；第二个周期出金300，实际亏损10
+	returns := periodReturns(histories, flows)
+	if len(returns) != 2 || math.Abs(returns[0]-0.05) > 1e-9 || math.Abs(returns[1]+10.0/1550) > 1e-9 {
+		t.Fatalf("returns = %v, want [0.05 %v]", returns, -10.0/1550)
+	}
+}
+
+// 降采样区间内每天一条的记录不参与夏普/索提诺计算
+func TestRiskAdjustedRatiosSkipDownsampledHistory(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.CapitalFlow{}, models.TradingConfig{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	conf.Retention.AccountHistoryFullDays = 30
+	wallet := exchange.NewPaperWallet(nil, 1000, logger)
+	accountService := NewTradingAccountService(db, wallet, NewAdminConfigService(logger, db), &conf, logger)
+
+	now := time.Now()
+	histories := []models.AccountHistory{
+		// 降采样区间：相邻两条相隔一天，净值大幅下跌
+		{ID: "d1", TotalBalance: 2000, RecordedAt: now.AddDate(0, 0, -40)},
+		{ID: "d2", TotalBalance: 1000, RecordedAt: now.AddDate(0, 0, -39)},
+		// 完整精度区间：收益 +2%、-1%、+3%、-2%
+		{ID: "f1", TotalBalance: 1000, RecordedAt: now.Add(-4 * time.Hour)},
+		{ID: "f2", TotalBalance: 1020, RecordedAt: now.Add(-3 * time.Hour)},
+		{ID: "f3", TotalBalance: 1009.8, RecordedAt: now.Add(-2 * time.Hour)},
+		{ID: "f4", TotalBalance: 1040.094, RecordedAt: now.Add(-time.Hour)},
+		{ID: "f5", TotalBalance: 1019.29212, RecordedAt: now},
+	}
+	for i := range histories {
+		if err := accountService.AccountHistoryRepo.Create(ctx, &histories[i]); err != nil {
+			t.Fatalf("create history: %v", err)
+		}
+	}
+
+	returns := []float64{0.02, -0.01, 0.03, -0.02}
+	sharpe, sortino := accountService.calculateRiskAdjustedRatios(ctx)
+	if want := sharpeRatio(returns, 0); math.Abs(sharpe-want) > 1e-9 {
+		t.Fatalf("sharpe = %v, want %v from the full resolution range", sharpe, want)
+	}
+	if want := sortinoRatio(returns, 0); math.Abs(sortino-want) > 1e-9 {
+		t.Fatalf("sortino = %v, want %v from the full resolution range", sortino, want)
+	}
+}
+
+func TestResolveBalanceAnchor(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	earlier := now.Add(-24 * time.Hour)
+	current := &models.TradingConfig{InitialBalance: 1000, InitialBalanceAt: &earlier}
+
+	if balance, at := resolveBalanceAnchor(current, models.TradingConfig{}, now); balance != 0 || at != nil {
+		t.Fatalf("cleared anchor = %v %v, want 0 nil", balance, at)
+	}
+	if _, at := resolveBalanceAnchor(current, models.TradingConfig{InitialBalance: 1000}, now); at == nil || !at.Equal(earlier) {
+		t.Fatalf("unchanged balance should keep anchor time, got %v", at)
+	}
+	if _, at := resolveBalanceAnchor(current, models.TradingConfig{InitialBalance: 2000}, now); at == nil || !at.Equal(now) {
+		t.Fatalf("changed balance should anchor at now, got %v", at)
+	}
+	explicit := now.Add(-time.Hour)
+	if _, at := resolveBalanceAnchor(current, models.TradingConfig{InitialBalance: 2000, InitialBalanceAt: &explicit}, now); at == nil || !at.Equal(explicit) {
+		t.Fatalf("explicit anchor time should be used, got %v", at)
+	}
+}
+
+func TestAccountMetricsNetOfCapitalFlows(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.CapitalFlow{}, models.FundingPayment{}, models.TradingConfig{}, models.Position{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	// 账户当前净值1500，其中500是入金
+	wallet := exchange.NewPaperWallet(nil, 1500, logger)
+	adminConfigService := NewAdminConfigService(logger, db)
+	accountService := NewTradingAccountService(db, wallet, adminConfigService, &conf, logger)
+
+	start := time.Now().Add(-2 * time.Hour)
+	if err := accountService.AccountHistoryRepo.Create(ctx, &models.AccountHistory{
+		ID: "h1", TotalBalance: 1000, PeakBalance: 1000, RecordedAt: start,
+	}); err != nil {
+		t.Fatalf("create history: %v", err)
+	}
+	if _, err := accountService.RecordCapitalFlow(ctx, 500, "充值", start.Add(time.Hour)); err != nil {
+		t.Fatalf("record capital flow: %v", err)
+	}
+
+	metrics, err := accountService.GetAccountMetrics(ctx)
+	if err != nil {
+		t.Fatalf("account metrics: %v", err)
+	}
+	if metrics.InitialBalance != 1500 || metrics.NetDeposits != 500 || metrics.ReturnPercent != 0 || metrics.DrawdownFromPeak != 0 {
+		t.Fatalf("metrics with deposit = %+v", metrics)
+	}
+
+	// 管理员指定初始资金后以其为锚点，锚点之前的入金不再计入
+	anchor := start.Add(90 * time.Minute)
+	if err := adminConfigService.SetTradingConfig(ctx, models.TradingConfig{
+		Symbols: []string{"BTCUSDT"}, IntervalMinutes: 15, InitialBalance: 2000, InitialBalanceAt: &anchor,
+	}); err != nil {
+		t.Fatalf("set trading config: %v", err)
+	}
+	metrics, err = accountService.GetAccountMetrics(ctx)
+	if err != nil {
+		t.Fatalf("account metrics: %v", err)
+	}
+	if metrics.InitialBalance != 2000 || metrics.NetDeposits != 0 || metrics.ReturnPercent != -25 {
+		t.Fatalf("metrics with configured anchor = %+v", metrics)
+	}
+}
+
+func TestPeakBalanceNetOfFlows(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		histories []models.AccountHistory
+		flows     []models.CapitalFlow
+		want      float64
+	}{
+		{
+			name: "no flows",
+			histories: []models.AccountHistory{
+				{TotalBalance: 1000, RecordedAt: base},
+				{TotalBalance: 1200, RecordedAt: base.Add(time.Hour)},
+				{TotalBalance: 1100, RecordedAt: base.Add(2 * time.Hour)},
+			},
+			want: 1200,
+		},
+		{
+			// 1000 出金500后涨到600：峰值取 max(1000-500, 600)
+			name: "withdrawal before new high",
+			histories: []models.AccountHistory{
+				{TotalBalance: 1000, RecordedAt: base},
+				{TotalBalance: 600, RecordedAt: base.Add(2 * time.Hour)},
+			},
+			flows: []models.CapitalFlow{{Amount: -500, OccurredAt: base.Add(time.Hour)}},
+			want:  600,
+		},
+		{
+			// 入金后净值虽创新高，但扣除入金后的旧峰值更高
+			name: "deposit after peak",
+			histories: []models.AccountHistory{
+				{TotalBalance: 1000, RecordedAt: base},
+				{TotalBalance: 900, RecordedAt: base.Add(time.Hour)},
+				{TotalBalance: 1400, RecordedAt: base.Add(3 * time.Hour)},
+			},
+			flows: []models.CapitalFlow{
+				{Amount: 300, OccurredAt: base.Add(2 * time.Hour)},
+				{Amount: 200, OccurredAt: base.Add(150 * time.Minute)},
+			},
+			want: 1500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := peakBalanceNetOfFlows(tt.histories, tt.flows); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("peak = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountMetricsDrawdownAfterWithdrawal(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.CapitalFlow{}, models.FundingPayment{}, models.TradingConfig{}, models.Position{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	// 1000 → 出金500 → 涨到600 → 当前跌到550
+	wallet := exchange.NewPaperWallet(nil, 550, logger)
+	accountService := NewTradingAccountService(db, wallet, NewAdminConfigService(logger, db), &conf, logger)
+
+	start := time.Now().Add(-3 * time.Hour)
+	for _, history := range []models.AccountHistory{
+		{ID: "h1", TotalBalance: 1000, PeakBalance: 1000, RecordedAt: start},
+		{ID: "h2", TotalBalance: 600, PeakBalance: 1000, RecordedAt: start.Add(2 * time.Hour)},
+	} {
+		if err := accountService.AccountHistoryRepo.Create(ctx, &history); err != nil {
+			t.Fatalf("create history: %v", err)
+		}
+	}
+	if _, err := accountService.RecordCapitalFlow(ctx, -500, "提现", start.Add(time.Hour)); err != nil {
+		t.Fatalf("record capital flow: %v", err)
+	}
+
+	metrics, err := accountService.GetAccountMetrics(ctx)
+	if err != nil {
+		t.Fatalf("account metrics: %v", err)
+	}
+	if metrics.PeakBalance != 600 || math.Abs(metrics.DrawdownFromPeak+50.0/6) > 1e-9 {
+		t.Fatalf("peak = %v, drawdown = %v, want 600 and %v", metrics.PeakBalance, metrics.DrawdownFromPeak, -50.0/6)
+	}
+}
+
+// 重置模拟账户后纸钱包回到新的初始资金，历史记录清空，初始资金锚点同步更新
+func TestAccountMetricsUseMarginBalance(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.CapitalFlow{}, models.FundingPayment{}, models.TradingConfig{}, models.Position{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	price := 100.0
+	wallet := exchange.NewPaperWallet(nil, 1000, logger)
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		return price, nil
+	})
+	accountService := NewTradingAccountService(db, wallet, NewAdminConfigService(logger, db), &conf, logger)
+	if err := accountService.AccountHistoryRepo.Create(ctx, &models.AccountHistory{
+		ID: "h1", TotalBalance: 1000, PeakBalance: 1000, RecordedAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("create history: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+
+	// 价格下跌 50，钱包余额不变，净值按钱包余额 + 未实现盈亏计算
+	price = 50
+	metrics, err := accountService.GetAccountMetrics(ctx)
+	if err != nil {
+		t.Fatalf("account metrics: %v", err)
+	}
+	if metrics.WalletBalance != 1000 || metrics.UnrealisedPnl != -50 || metrics.TotalBalance != 950 {
+		t.Fatalf("balances = wallet %v, unrealized %v, total %v", metrics.WalletBalance, metrics.UnrealisedPnl, metrics.TotalBalance)
+	}
+	if metrics.ReturnPercent != -5 || metrics.DrawdownFromPeak != -5 {
+		t.Fatalf("return = %v, drawdown = %v, want -5 on margin balance", metrics.ReturnPercent, metrics.DrawdownFromPeak)
+	}
+}
+
+func TestResetPaperAccount(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.Position{}, models.Order{}, models.Trade{}, models.Decision{},
+		models.LLMLog{}, models.CapitalFlow{}, models.FundingPayment{}, models.TradingConfig{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	conf := config.Default()
+	wallet := exchange.NewPaperWallet(nil, 1000, logger)
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) { return 100, nil })
+	adminConfigService := NewAdminConfigService(logger, db)
+	accountService := NewTradingAccountService(db, wallet, adminConfigService, &conf, logger)
+
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 2); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 1); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+	if err := accountService.AccountHistoryRepo.Create(ctx, &models.AccountHistory{ID: "h1", TotalBalance: 1000, RecordedAt: time.Now()}); err != nil {
+		t.Fatalf("create history: %v", err)
+	}
+	if err := db.Create(&models.Trade{ID: "t1", Symbol: "BTCUSDT", Type: "open", Side: "long", Price: 100, Quantity: 1, ExecutedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("create trade: %v", err)
+	}
+	if err := adminConfigService.SetTradingConfig(ctx, models.TradingConfig{
+		Symbols: []string{"BTCUSDT"}, IntervalMinutes: 15, InitialBalance: 1000,
+	}); err != nil {
+		t.Fatalf("set trading config: %v", err)
+	}
+
+	state, err := accountService.ResetPaperAccount(ctx, 5000)
+	if err != nil {
+		t.Fatalf("reset paper account: %v", err)
+	}
+	if state.InitialBalance != 5000 || state.Balance != 5000 {
+		t.Fatalf("state = %+v", state)
+	}
+	if positions, _ := wallet.GetPositions(ctx); len(positions) != 0 {
+		t.Fatalf("wallet positions after reset = %v", positions)
+	}
+
+	var histories, trades int64
+	db.Unscoped().Model(&models.AccountHistory{}).Count(&histories)
+	db.Unscoped().Model(&models.Trade{}).Count(&trades)
+	if histories != 0 || trades != 0 {
+		t.Fatalf("histories = %d, trades = %d after reset", histories, trades)
+	}
+
+	tradingConfig, err := adminConfigService.GetTradingConfig(ctx)
+	if err != nil {
+		t.Fatalf("get trading config: %v", err)
+	}
+	if tradingConfig.InitialBalance != 5000 || tradingConfig.InitialBalanceAt == nil {
+		t.Fatalf("balance anchor = %v at %v, want 5000", tradingConfig.InitialBalance, tradingConfig.InitialBalanceAt)
+	}
+
+	// 实盘模式下不允许重置
+	conf.Exchange.Mode = config.TradingModeLive
+	if _, err := accountService.ResetPaperAccount(ctx, 0); !errors.Is(err, ErrNotPaperMode) {
+		t.Fatalf("reset in live mode err = %v", err)
+	}
+}
+
+func TestTotalPositionStopRisk(t *testing.T) {
+	positions := []models.Position{
+		{Symbol: "BTCUSDT", Side: "long", Quantity: 0.5, CurrentPrice: 100, StopLoss: 90}, // 5
+		{Symbol: "ETHUSDT", Side: "short", Quantity: 2, CurrentPrice: 50, StopLoss: 53},   // 6
+		{Symbol: "SOLUSDT", Side: "long", Quantity: 10, CurrentPrice: 20, StopLoss: 21},   // 已越过止损，按0计算
+		{Symbol: "BNBUSDT", Side: "long", Quantity: 1, CurrentPrice: 300, StopLoss: 0},    // 未设置止损
+	}
+	total, unprotected := totalPositionStopRisk(positions)
+	if math.Abs(total-11) > 1e-9 {
+		t.Fatalf("total stop risk = %v, want 11", total)
+	}
+	if unprotected != 1 {
+		t.Fatalf("unprotected = %d, want 1", unprotected)
+	}
+}
+
+func TestApplyCapitalUsage(t *testing.T) {
+	metrics := &AccountMetrics{TotalBalance: 1100, InitialBalance: 1000}
+	metrics.applyCapitalUsage(275, 0)
+	if metrics.MarginUsed != 275 || math.Abs(metrics.CapitalUtilization-25) > 1e-9 {
+		t.Fatalf("margin used = %v, utilization = %v, want 275, 25", metrics.MarginUsed, metrics.CapitalUtilization)
+	}
+	if metrics.TotalCapital != 1100 || math.Abs(metrics.TotalCapitalReturn-10) > 1e-9 {
+		t.Fatalf("without idle capital: total = %v, return = %v", metrics.TotalCapital, metrics.TotalCapitalReturn)
+	}
+
+	// 计入闲置资金后，同样的合约盈利按更大的资金基数计算收益率
+	metrics.applyCapitalUsage(275, 4000)
+	if metrics.IdleCapital != 4000 || metrics.TotalCapital != 5100 || math.Abs(metrics.TotalCapitalReturn-2) > 1e-9 {
+		t.Fatalf("with idle capital: idle = %v, total = %v, return = %v", metrics.IdleCapital, metrics.TotalCapital, metrics.TotalCapitalReturn)
+	}
+}
+
+// 持仓累计资金费只统计该交易对开仓之后的结算
+func TestPositionFunding(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.FundingPayment{}, models.TradingConfig{}, models.Position{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	logger := zap.NewNop()
+	conf := config.Default()
+	accountService := NewTradingAccountService(db, exchange.NewPaperWallet(nil, 1000, logger), NewAdminConfigService(logger, db), &conf, logger)
+
+	openedAt := time.Now().Add(-24 * time.Hour)
+	payments := []models.FundingPayment{
+		{ID: "f1", TranID: 1, Symbol: "BTCUSDT", Amount: -1, PaidAt: openedAt.Add(-8 * time.Hour)}, // 开仓前
+		{ID: "f2", TranID: 2, Symbol: "BTCUSDT", Amount: -2, PaidAt: openedAt.Add(8 * time.Hour)},
+		{ID: "f3", TranID: 3, Symbol: "BTCUSDT", Amount: -1.5, PaidAt: openedAt.Add(16 * time.Hour)},
+		{ID: "f4", TranID: 4, Symbol: "ETHUSDT", Amount: -5, PaidAt: openedAt.Add(8 * time.Hour)},
+	}
+	if err := accountService.fundingRepo.CreateIgnoreDuplicates(ctx, payments); err != nil {
+		t.Fatalf("create funding payments: %v", err)
+	}
+
+	funding := accountService.PositionFunding(ctx, []models.Position{{ID: "p1", Symbol: "BTCUSDT", OpenedAt: openedAt}})
+	if math.Abs(funding["p1"]+3.5) > 1e-9 {
+		t.Fatalf("funding = %v, want -3.5", funding["p1"])
+	}
+	if cost := fundingCostPercent(funding["p1"], 50); math.Abs(cost-7) > 1e-9 {
+		t.Fatalf("funding cost = %v%%, want 7%%", cost)
+	}
+	if !fundingLimitExceeded(funding["p1"], 50, 5) || fundingLimitExceeded(funding["p1"], 50, 0) || fundingLimitExceeded(3.5, 50, 5) {
+		t.Fatal("funding limit should only trigger on net cost above an enabled limit")
+	}
+}
+
+func TestDegradedAccountMetrics(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "prism.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(models.AccountHistory{}, models.FundingPayment{}, models.TradingConfig{}, models.Position{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	logger := zap.NewNop()
+	conf := config.Default()
+	accountService := NewTradingAccountService(db, exchange.NewPaperWallet(nil, 1000, logger), NewAdminConfigService(logger, db), &conf, logger)
+
+	if _, err := accountService.DegradedAccountMetrics(ctx, time.Hour); err == nil {
+		t.Fatal("fallback without account history should fail")
+	}
+
+	recordedAt := time.Now().Add(-10 * time.Minute)
+	history := &models.AccountHistory{ID: "h1", TotalBalance: 950, UnrealisedPnl: -20, PeakBalance: 1000, DrawdownFromPeak: -5, RecordedAt: recordedAt}
+	if err := accountService.AccountHistoryRepo.Create(ctx, history); err != nil {
+		t.Fatalf("create account history: %v", err)
+	}
+	position := &models.Position{ID: "p1", Symbol: "BTCUSDT", Side: "long", Quantity: 1, EntryPrice: 100, CurrentPrice: 100, StopLoss: 95}
+	if err := accountService.positionRepo.Create(ctx, position); err != nil {
+		t.Fatalf("create position: %v", err)
+	}
+
+	metrics, err := accountService.DegradedAccountMetrics(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("degraded metrics: %v", err)
+	}
+	if !metrics.Degraded() || metrics.TotalBalance != 950 || metrics.WalletBalance != 970 || metrics.DrawdownFromPeak != -5 {
+		t.Fatalf("unexpected degraded metrics: %+v", metrics)
+	}
+	if math.Abs(metrics.TotalStopRisk-5) > 1e-9 {
+		t.Fatalf("stop risk = %v, want 5", metrics.TotalStopRisk)
+	}
+
+	if _, err := accountService.DegradedAccountMetrics(ctx, 5*time.Minute); err == nil {
+		t.Fatal("stale account history should not be used")
+	}
+}
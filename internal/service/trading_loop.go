@@ -2,10 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dushixiang/prism/internal/config"
+	"github.com/dushixiang/prism/internal/models"
 	"github.com/dushixiang/prism/internal/repo"
+	"github.com/dushixiang/prism/pkg/exchange"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 )
@@ -20,7 +26,12 @@ type TradingLoop struct {
 	orderRepo          *repo.OrderRepo
 	logger             *zap.Logger
 	adminConfigService *AdminConfigService
+	notifyService      *NotifyService
+	exchangeHealth     *ExchangeHealthService
+	conf               *config.Config
 
+	// mu 保护以下运行状态，Start/Stop、cron 任务与 HTTP 查询会并发访问
+	mu        sync.Mutex
 	startTime time.Time
 	iteration int
 	isRunning bool
@@ -28,8 +39,50 @@ type TradingLoop struct {
 	cron      *cron.Cron
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	deleveragedPeak float64         // 已触发过自动减仓的峰值净值，同一峰值只减仓一次
+	activeDecision  *activeDecision // 正在执行的AI决策，nil表示当前没有
+	lastCycleStart  time.Time       // 上一个交易周期的开始时间，用于最小间隔检查
+	belowMinBalance bool            // 上一周期账户净值低于最低运行资金，已跳过AI决策
+	lastDigestAt    time.Time       // 上次发送周期摘要的时间
+	digestPositions int             // 上次周期摘要时的持仓数量
+	digestSkipped   bool            // 上次周期摘要时周期是否被跳过
+
+	// cycleMu 保证同一时间只有一个交易周期在执行，重启循环时旧周期可能尚未结束
+	cycleMu sync.Mutex
+}
+
+// activeDecision 正在执行的AI决策
+type activeDecision struct {
+	ID        string
+	StartedAt time.Time
+	cancel    context.CancelCauseFunc
 }
 
+// ErrNoActiveDecision 当前没有正在执行的决策
+var ErrNoActiveDecision = errors.New("no decision in flight")
+
+// ErrCycleInProgress 已有交易周期正在执行
+var ErrCycleInProgress = errors.New("a trading cycle is already running")
+
+// CycleSummary 一个交易周期的执行结果摘要
+type CycleSummary struct {
+	Iteration        int     `json:"iteration"`
+	DecisionID       string  `json:"decision_id"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	ToolsCalled      int     `json:"tools_called"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Balance          float64 `json:"balance"`
+	ReturnPercent    float64 `json:"return_percent"`
+	PositionCount    int     `json:"position_count"`
+	DecisionPreview  string  `json:"decision_preview"`
+	Skipped          string  `json:"skipped,omitempty"` // 跳过AI决策的原因
+}
+
+// decisionCanceledPrefix 被手动取消的决策内容前缀
+const decisionCanceledPrefix = "**已手动取消**"
+
 // NewTradingLoop 创建交易循环
 func NewTradingLoop(
 	marketService *MarketService,
@@ -38,8 +91,11 @@ func NewTradingLoop(
 	promptService *PromptService,
 	agentService *AgentService,
 	adminConfigService *AdminConfigService,
+	notifyService *NotifyService,
+	exchangeHealth *ExchangeHealthService,
 	orderRepo *repo.OrderRepo,
 	logger *zap.Logger,
+	conf *config.Config,
 ) *TradingLoop {
 	return &TradingLoop{
 		marketService:      marketService,
@@ -48,8 +104,11 @@ func NewTradingLoop(
 		promptService:      promptService,
 		agentService:       agentService,
 		adminConfigService: adminConfigService,
+		notifyService:      notifyService,
+		exchangeHealth:     exchangeHealth,
 		orderRepo:          orderRepo,
 		logger:             logger,
+		conf:               conf,
 		startTime:          time.Now(),
 		iteration:          0,
 		isRunning:          false,
@@ -57,59 +116,100 @@ func NewTradingLoop(
 	}
 }
 
-// Start 启动交易循环
-func (t *TradingLoop) Start(ctx context.Context) error {
+// begin 将循环标记为运行中，并为本次运行创建新的停止信号
+func (t *TradingLoop) begin(ctx context.Context) (chan struct{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	if t.isRunning {
-		return fmt.Errorf("trading loop is already running")
+		return nil, fmt.Errorf("trading loop is already running")
 	}
 
 	t.isRunning = true
 	t.startTime = time.Now()
+	t.stopChan = make(chan struct{})
 	t.ctx, t.cancel = context.WithCancel(ctx)
+	return t.stopChan, nil
+}
 
-	// 加载最近一次执行的迭代编号，避免重启后从 0 开始
-	if lastIteration, err := t.agentService.GetLatestIteration(ctx); err != nil {
-		t.logger.Warn("failed to load latest iteration, fallback to 0", zap.Error(err))
-	} else {
-		t.iteration = lastIteration
-		t.logger.Info("resume iteration counter from history", zap.Int("iteration", t.iteration))
+// abort 启动失败时回滚运行状态（仅回滚本次 begin 产生的状态）
+func (t *TradingLoop) abort(stopChan chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.isRunning || t.stopChan != stopChan {
+		return
+	}
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.isRunning = false
+	t.cron = nil
+	t.cancel = nil
+}
+
+// Start 启动交易循环
+func (t *TradingLoop) Start(ctx context.Context) error {
+	// 实盘模式必须显式确认，避免把模拟配置误当成实盘或误开实盘
+	if err := t.CheckLiveConfirmed(); err != nil {
+		t.logger.Error("refusing to start trading loop", zap.Error(err))
+		return err
+	}
+
+	stopChan, err := t.begin(ctx)
+	if err != nil {
+		return err
 	}
 
+	// 加载最近一次执行的迭代编号，避免重启后从 0 开始
+	t.resumeIteration(ctx)
+
 	tradingConfig, err := t.adminConfigService.GetTradingConfig(ctx)
 	if err != nil {
 		t.logger.Warn("failed to get trading config", zap.Error(err))
 	}
 
-	// 生成 cron 表达式：每 N 分钟的整点执行
-	// 例如 interval=10: "*/10 * * * *" 表示每小时的 0, 10, 20, 30, 40, 50 分执行
-	cronExpr := fmt.Sprintf("*/%d * * * *", tradingConfig.IntervalMinutes)
+	if tradingConfig.IntervalMinutes <= 0 {
+		t.abort(stopChan)
+		return fmt.Errorf("invalid interval minutes: %d", tradingConfig.IntervalMinutes)
+	}
+
+	// 对齐到K线收盘时间：每 N 分钟的K线收盘后延迟若干秒执行
+	// 例如 interval=15, delay=5s: 每小时的 0:05, 15:05, 30:05, 45:05 执行
+	schedule := newCandleCloseSchedule(
+		time.Duration(tradingConfig.IntervalMinutes)*time.Minute,
+		time.Duration(t.conf.Trading.CandleCloseDelaySeconds)*time.Second,
+	)
 
 	t.logger.Info("trading loop started",
 		zap.Strings("symbols", tradingConfig.Symbols),
 		zap.Int("interval_minutes", tradingConfig.IntervalMinutes),
-		zap.String("cron_expression", cronExpr))
+		zap.Duration("candle_close_delay", schedule.delay),
+		zap.Time("next_run", schedule.Next(time.Now())))
 
-	// 创建 cron 调度器（使用秒级精度）
-	t.cron = cron.New()
+	// 创建 cron 调度器
+	scheduler := cron.New()
 
 	// 添加定时任务
-	_, err = t.cron.AddFunc(cronExpr, func() {
+	scheduler.Schedule(schedule, cron.FuncJob(func() {
 		if err := t.ExecuteCycle(context.Background()); err != nil {
 			t.logger.Error("cycle execution failed", zap.Error(err))
 		}
-	})
+	}))
 
-	if err != nil {
-		t.isRunning = false
-		return fmt.Errorf("failed to add cron job: %w", err)
+	// 启动 cron 调度器；若启动期间已被 Stop，则不再启动
+	t.mu.Lock()
+	if !t.isRunning || t.stopChan != stopChan {
+		t.mu.Unlock()
+		return nil
 	}
-
-	// 启动 cron 调度器
-	t.cron.Start()
+	t.cron = scheduler
+	scheduler.Start()
+	t.mu.Unlock()
 
 	// 等待停止信号
 	select {
-	case <-t.stopChan:
+	case <-stopChan:
 		t.logger.Info("trading loop stopped by user")
 		return nil
 	case <-ctx.Done():
@@ -120,57 +220,207 @@ func (t *TradingLoop) Start(ctx context.Context) error {
 
 // Stop 停止交易循环
 func (t *TradingLoop) Stop() {
+	t.mu.Lock()
 	if !t.isRunning {
+		t.mu.Unlock()
 		return
 	}
 
+	scheduler := t.cron
+	cancel := t.cancel
+	stopChan := t.stopChan
+	t.isRunning = false
+	t.cron = nil
+	t.cancel = nil
+	t.mu.Unlock()
+
 	t.logger.Info("stopping trading loop...")
 
-	// 停止 cron 调度器
-	if t.cron != nil {
-		ctx := t.cron.Stop()
+	// 停止 cron 调度器（在锁外等待，避免与正在执行的周期互相阻塞）
+	if scheduler != nil {
+		ctx := scheduler.Stop()
 		<-ctx.Done() // 等待所有任务完成
 		t.logger.Info("cron scheduler stopped")
 	}
 
 	// 取消 context
-	if t.cancel != nil {
-		t.cancel()
+	if cancel != nil {
+		cancel()
 	}
 
-	t.isRunning = false
-	close(t.stopChan)
+	close(stopChan)
 	t.logger.Info("trading loop stopped")
 }
 
+// resumeIteration 从历史决策加载最近一次执行的迭代编号
+func (t *TradingLoop) resumeIteration(ctx context.Context) {
+	lastIteration, err := t.agentService.GetLatestIteration(ctx)
+	if err != nil {
+		t.logger.Warn("failed to load latest iteration, fallback to 0", zap.Error(err))
+		return
+	}
+	t.mu.Lock()
+	t.iteration = max(t.iteration, lastIteration)
+	t.mu.Unlock()
+	t.logger.Info("resume iteration counter from history", zap.Int("iteration", lastIteration))
+}
+
+// nextIteration 递增并返回本次周期的迭代编号
+func (t *TradingLoop) nextIteration() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.iteration++
+	return t.iteration
+}
+
+// snapshot 返回运行状态的一致快照
+func (t *TradingLoop) snapshot() (isRunning bool, iteration int, startTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.isRunning, t.iteration, t.startTime
+}
+
+// minCycleSpacing 两个交易周期开始时间的最小间隔，不超过交易周期的一半，避免正常调度被误判为过密
+func (t *TradingLoop) minCycleSpacing(intervalMinutes int) time.Duration {
+	spacing := time.Duration(t.conf.Trading.MinCycleSpacingSeconds) * time.Second
+	if spacing <= 0 {
+		return 0
+	}
+	if intervalMinutes > 0 {
+		spacing = min(spacing, time.Duration(intervalMinutes)*time.Minute/2)
+	}
+	return spacing
+}
+
+// claimCycleSlot 检查距上一周期开始是否已满最小间隔，满足时记录本次开始时间并返回 true，否则返回距上一周期的时长
+func (t *TradingLoop) claimCycleSlot(now time.Time, minSpacing time.Duration) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.lastCycleStart.IsZero() {
+		if elapsed := now.Sub(t.lastCycleStart); elapsed < minSpacing {
+			return elapsed, false
+		}
+	}
+	t.lastCycleStart = now
+	return 0, true
+}
+
 // ExecuteCycle 执行一个完整的交易周期（7步流程）
+//
+// 上一周期尚未结束或距上一周期开始不足最小间隔时跳过本次执行，避免并发决策争抢同一批持仓
 func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
-	t.iteration++
-	cycleStart := time.Now()
+	if !t.cycleMu.TryLock() {
+		t.logger.Warn("previous trading cycle still running, skip this cycle")
+		return nil
+	}
+	defer t.cycleMu.Unlock()
 
+	_, err := t.runCycle(ctx, false)
+	return err
+}
+
+// RunOnce 立即执行一个交易周期并等待其结束，不受定时调度和最小间隔限制，用于验证配置或提示词修改
+//
+// 已有周期正在执行时返回 ErrCycleInProgress，不会与定时周期并发执行。
+func (t *TradingLoop) RunOnce(ctx context.Context) (*CycleSummary, error) {
+	if err := t.CheckLiveConfirmed(); err != nil {
+		return nil, err
+	}
+	if !t.cycleMu.TryLock() {
+		return nil, ErrCycleInProgress
+	}
+	defer t.cycleMu.Unlock()
+
+	// 循环未启动时迭代编号尚未从历史加载
+	if isRunning, _, _ := t.snapshot(); !isRunning {
+		t.resumeIteration(ctx)
+	}
+	t.logger.Info("running a single trading cycle on demand")
+	return t.runCycle(ctx, true)
+}
+
+// runCycle 执行交易周期，调用方需持有 cycleMu；manual 为手动触发，跳过最小间隔检查。
+// 因间隔不足跳过时返回 nil 摘要
+func (t *TradingLoop) runCycle(ctx context.Context, manual bool) (summary *CycleSummary, err error) {
 	tradingConfig, err := t.adminConfigService.GetTradingConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get trading config: %w", err)
+		return nil, fmt.Errorf("failed to get trading config: %w", err)
+	}
+
+	cycleStart := time.Now()
+	minSpacing := t.minCycleSpacing(tradingConfig.IntervalMinutes)
+	if manual {
+		// 仍记录开始时间，紧随其后的定时周期按最小间隔跳过
+		minSpacing = 0
 	}
+	if elapsed, ok := t.claimCycleSlot(cycleStart, minSpacing); !ok {
+		t.logger.Warn("trading cycle triggered too soon after previous cycle, skip this cycle",
+			zap.Duration("since_last_cycle", elapsed),
+			zap.Duration("min_spacing", minSpacing))
+		return nil, nil
+	}
+
+	iteration := t.nextIteration()
+	_, _, startTime := t.snapshot()
+
+	// 周期内的事件都带上周期编号，出错时记录错误事件
+	ctx = withEventIteration(ctx, iteration)
+	events := t.agentService.events
+	events.Record(ctx, models.EventCycleStart, "", "交易周期开始", map[string]interface{}{"manual": manual})
+	defer func() {
+		if err != nil {
+			events.Record(ctx, models.EventError, "", err.Error(), nil)
+		} else if summary != nil {
+			events.Record(ctx, models.EventCycleEnd, "", "交易周期结束", summary)
+		}
+	}()
 
 	t.logger.Info("========== TRADING CYCLE START ==========",
-		zap.Int("iteration", t.iteration),
+		zap.Int("iteration", iteration),
 		zap.Time("start_time", cycleStart))
 
+	// 交易对下架或暂停交易时先处理相关持仓，并跳过这些交易对的行情收集
+	unavailableSymbols := t.guardDelistedSymbols(ctx, tradingConfig)
+	// 下架处理可能已平掉部分持仓，之后的筛选和行情收集都使用同一份持仓交易对
+	held := t.heldSymbols(ctx)
+	// 刚上线的交易对价格历史太短，不参与交易
+	for symbol, reason := range t.excludeNewListings(ctx, tradingConfig, held) {
+		unavailableSymbols[symbol] = reason
+	}
+	// 24小时成交额太低的交易对信号不可靠、成交差，不参与交易
+	for symbol, reason := range t.excludeIlliquidSymbols(ctx, tradingConfig, held) {
+		unavailableSymbols[symbol] = reason
+	}
+
 	// ========== Step 1: 收集市场数据 ==========
 	t.logger.Info("[STEP 1/6] Collecting market data...")
-	marketData, err := t.marketService.CollectAllSymbols(ctx, tradingConfig.Symbols)
+	cycleConfig := t.prioritizeCycleSymbols(withoutSymbols(t.rotateCycleSymbols(tradingConfig, iteration, held), unavailableSymbols), held)
+	collectCtx, cancelCollect := t.collectionContext(ctx, tradingConfig.IntervalMinutes)
+	marketData, err := t.marketService.CollectAllSymbols(collectCtx, cycleConfig, held)
+	cancelCollect()
 	if err != nil {
-		return fmt.Errorf("step 1 failed - collect market data: %w", err)
+		return nil, fmt.Errorf("step 1 failed - collect market data: %w", err)
 	}
 	t.logger.Info("[STEP 1/6] Market data collected",
 		zap.Int("symbols_count", len(marketData)))
+	t.marketService.RecordRegimes(ctx, iteration, marketData)
+	events.Record(ctx, models.EventMarketData, "", fmt.Sprintf("收集 %d 个交易对的行情", len(marketData)),
+		map[string]interface{}{"symbols_count": len(marketData), "requested": len(cycleConfig.Symbols), "unavailable": unavailableSymbols})
 
 	// ========== Step 2: 获取账户信息 ==========
 	t.logger.Info("[STEP 2/6] Getting account metrics...")
 	accountMetrics, err := t.accountService.GetAccountMetrics(ctx)
 	if err != nil {
-		return fmt.Errorf("step 2 failed - get account metrics: %w", err)
+		// 账户信息短暂获取失败时用最近的账户历史继续同步持仓和执行风控，只跳过AI决策
+		degraded, fallbackErr := t.degradedAccountMetrics(ctx)
+		if fallbackErr != nil {
+			return nil, fmt.Errorf("step 2 failed - get account metrics: %w (fallback: %v)", err, fallbackErr)
+		}
+		t.logger.Warn("[RISK] account metrics unavailable, running risk checks on last account snapshot",
+			zap.Error(err),
+			zap.Time("snapshot_at", *degraded.SnapshotAt))
+		accountMetrics = degraded
 	}
 	t.logger.Info("[STEP 2/6] Account metrics retrieved",
 		zap.Float64("total_balance", accountMetrics.TotalBalance),
@@ -181,17 +431,79 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 	// ========== Step 3: 同步持仓数据 ==========
 	t.logger.Info("[STEP 3/6] Syncing positions...")
 	if err := t.positionService.SyncPositions(ctx); err != nil {
-		return fmt.Errorf("step 3 failed - sync positions: %w", err)
+		return nil, fmt.Errorf("step 3 failed - sync positions: %w", err)
 	}
 	positions, _ := t.positionService.GetAllPositions(ctx)
 	t.logger.Info("[STEP 3/6] Positions synced",
 		zap.Int("position_count", len(positions)))
 
+	// 回撤分级、最长持仓时间和累计资金费上限由系统强制执行，平仓后刷新账户和持仓，保证提示词反映真实状态
+	riskClosed := false
+	if t.enforceDrawdownLimits(ctx, tradingConfig, accountMetrics, positions) {
+		riskClosed = true
+		positions, _ = t.positionService.GetAllPositions(ctx)
+	}
+	if t.enforceMaxHoldingTime(ctx, tradingConfig, positions) {
+		riskClosed = true
+		positions, _ = t.positionService.GetAllPositions(ctx)
+	}
+	if t.enforceFundingLimit(ctx, positions) {
+		riskClosed = true
+		positions, _ = t.positionService.GetAllPositions(ctx)
+	}
+	// 盈利达到触发值后自动把止损移到开仓价，使用平仓后的持仓
+	if t.enforceBreakevenStops(ctx, positions) {
+		positions, _ = t.positionService.GetAllPositions(ctx)
+	}
+	if riskClosed {
+		if metrics, err := t.accountService.GetAccountMetrics(ctx); err != nil {
+			t.logger.Warn("failed to refresh account metrics after risk enforcement", zap.Error(err))
+		} else {
+			accountMetrics = metrics
+		}
+		positions, _ = t.positionService.GetAllPositions(ctx)
+	}
+
+	// 降级快照不能反映实时净值，不交给AI决策，也不写入账户历史
+	if accountMetrics.Degraded() {
+		summary := &CycleSummary{
+			Iteration:       iteration,
+			DurationSeconds: time.Since(cycleStart).Seconds(),
+			Balance:         accountMetrics.TotalBalance,
+			ReturnPercent:   accountMetrics.ReturnPercent,
+			PositionCount:   len(positions),
+			Skipped:         fmt.Sprintf("account metrics unavailable, used snapshot from %s", accountMetrics.SnapshotAt.Format(time.RFC3339)),
+		}
+		t.sendCycleDigest(ctx, cycleStart, summary, accountMetrics, positions)
+		return summary, nil
+	}
+
+	// 净值低于最低运行资金时连最小仓位也开不了，跳过AI决策，只保存账户历史
+	if t.belowOperatingBalance(accountMetrics.TotalBalance) {
+		if err := t.accountService.SaveAccountHistory(ctx, accountMetrics, iteration); err != nil {
+			t.logger.Error("failed to save account history", zap.Error(err))
+		}
+		summary := &CycleSummary{
+			Iteration:       iteration,
+			DurationSeconds: time.Since(cycleStart).Seconds(),
+			Balance:         accountMetrics.TotalBalance,
+			ReturnPercent:   accountMetrics.ReturnPercent,
+			PositionCount:   len(positions),
+			Skipped:         fmt.Sprintf("balance %.2f below operating minimum %.2f", accountMetrics.TotalBalance, t.conf.Trading.MinOperatingBalance),
+		}
+		t.sendCycleDigest(ctx, cycleStart, summary, accountMetrics, positions)
+		return summary, nil
+	}
+
 	// ========== Step 4: 生成AI提示词 ==========
 	t.logger.Info("[STEP 4/6] Generating LLM prompt...")
 
-	// 获取历史交易(最近20笔)
-	recentTrades, _ := t.agentService.GetRecentTrades(ctx, 20)
+	// 获取最近的历史交易
+	tradesLimit := t.conf.Trading.PromptRecentTradesLimit
+	if tradesLimit <= 0 {
+		tradesLimit = 20
+	}
+	recentTrades, _ := t.agentService.GetRecentTrades(ctx, tradesLimit)
 
 	// 获取所有活跃订单
 	activeOrders, err := t.orderRepo.FindAllActive(ctx)
@@ -200,20 +512,28 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 		activeOrders = nil
 	}
 
+	openWindow, err := t.agentService.OpenWindowUsage(ctx)
+	if err != nil {
+		t.logger.Warn("failed to count opens in window for prompt", zap.Error(err))
+	}
+
 	promptData := &PromptData{
-		StartTime:      t.startTime,
-		Iteration:      t.iteration,
-		AccountMetrics: accountMetrics,
-		MarketDataMap:  marketData,
-		Positions:      positions,
-		RecentTrades:   recentTrades,
-		ActiveOrders:   activeOrders,
+		StartTime:       startTime,
+		Iteration:       iteration,
+		AccountMetrics:  accountMetrics,
+		MarketDataMap:   marketData,
+		Positions:       positions,
+		PositionFunding: t.accountService.PositionFunding(ctx, positions),
+		RecentTrades:    recentTrades,
+		ActiveOrders:    activeOrders,
+		CloseOnly:       t.agentService.IsCloseOnly(),
+		OpenWindow:      openWindow,
 	}
 
 	prompt := t.promptService.GeneratePrompt(ctx, promptData)
 	systemInstructions, err := t.promptService.GetSystemInstructions(ctx)
 	if err != nil {
-		return fmt.Errorf("step 4 failed - get system instructions: %w", err)
+		return nil, fmt.Errorf("step 4 failed - get system instructions: %w", err)
 	}
 
 	t.logger.Info("[STEP 4/6] LLM prompt generated",
@@ -223,28 +543,47 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 	t.logger.Info("[STEP 5/6] Executing LLM decision...")
 
 	// 先创建决策记录以获取决策ID（先保存一个占位记录）
-	decisionID, err := t.agentService.SaveDecision(ctx, t.iteration, accountMetrics.TotalBalance,
-		len(positions), "执行中...", 0, 0)
+	decisionID, err := t.agentService.SaveDecision(ctx, iteration, accountMetrics.TotalBalance,
+		len(positions), decisionPlaceholderContent, 0, 0)
 	if err != nil {
 		t.logger.Error("[STEP 5/6] Failed to create decision record", zap.Error(err))
-		return fmt.Errorf("step 5 failed - create decision: %w", err)
+		return nil, fmt.Errorf("step 5 failed - create decision: %w", err)
+	}
+	ctx = withEventDecision(ctx, decisionID)
+	if err := t.agentService.SaveDecisionPrompt(ctx, decisionID, systemInstructions, prompt); err != nil {
+		t.logger.Warn("failed to save decision prompt", zap.String("decision_id", decisionID), zap.Error(err))
+	}
+	events.Record(ctx, models.EventDecisionCreated, "", "创建决策记录",
+		map[string]interface{}{"prompt_length": len(prompt), "position_count": len(positions)})
+
+	// 执行LLM决策，登记为当前决策以便运维手动取消
+	decisionCtx, cancelDecision := context.WithCancelCause(ctx)
+	t.setActiveDecision(decisionID, cancelDecision)
+	decision, err := t.agentService.ExecuteDecision(decisionCtx, decisionID, systemInstructions, prompt, accountMetrics)
+	t.clearActiveDecision(decisionID)
+	cancelDecision(nil)
+	if errors.Is(err, ErrDecisionCanceled) {
+		// 已执行的工具照常进入后处理，决策记录标记为已取消
+		t.logger.Warn("[STEP 5/6] LLM decision canceled by operator",
+			zap.String("decision_id", decisionID),
+			zap.Int("tools_called", decision.ToolsCalled))
+		decision.DecisionText = strings.TrimSpace(decisionCanceledPrefix + "\n\n" + decision.DecisionText)
+		err = nil
 	}
-
-	// 执行LLM决策
-	decision, err := t.agentService.ExecuteDecision(ctx, decisionID, systemInstructions, prompt, accountMetrics)
 	if err != nil {
 		t.logger.Error("[STEP 5/6] LLM decision failed", zap.Error(err))
-		return fmt.Errorf("step 5 failed - LLM decision: %w", err)
+		return nil, fmt.Errorf("step 5 failed - LLM decision: %w", err)
 	}
 
 	t.logger.Info("[STEP 5/6] LLM decision executed",
 		zap.Int("tools_called", decision.ToolsCalled),
 		zap.Int("prompt_tokens", decision.PromptTokens),
 		zap.Int("completion_tokens", decision.CompletionTokens),
+		zap.String("model", decision.Model),
 		zap.String("decision_preview", truncateString(decision.DecisionText, 200)))
 
 	// 更新决策记录为完整内容
-	if err := t.agentService.UpdateDecision(ctx, decisionID, decision.DecisionText, decision.PromptTokens, decision.CompletionTokens); err != nil {
+	if err := t.agentService.UpdateDecision(ctx, decisionID, decision.DecisionText, decision.Model, decision.PromptTokens, decision.CompletionTokens); err != nil {
 		t.logger.Error("failed to update decision", zap.Error(err))
 	}
 
@@ -263,7 +602,7 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 		finalAccountMetrics = accountMetrics
 	} else {
 		// 6c. 保存账户历史
-		if err := t.accountService.SaveAccountHistory(ctx, finalAccountMetrics, t.iteration); err != nil {
+		if err := t.accountService.SaveAccountHistory(ctx, finalAccountMetrics, iteration); err != nil {
 			t.logger.Error("failed to save account history", zap.Error(err))
 		}
 	}
@@ -276,7 +615,7 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 	// ========== 周期总结 ==========
 	cycleDuration := time.Since(cycleStart)
 	t.logger.Info("========== TRADING CYCLE END ==========",
-		zap.Int("iteration", t.iteration),
+		zap.Int("iteration", iteration),
 		zap.Duration("duration", cycleDuration),
 		zap.Float64("balance", finalAccountMetrics.TotalBalance),
 		zap.Float64("return_percent", finalAccountMetrics.ReturnPercent),
@@ -297,27 +636,137 @@ func (t *TradingLoop) ExecuteCycle(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	summary = &CycleSummary{
+		Iteration:        iteration,
+		DecisionID:       decisionID,
+		DurationSeconds:  cycleDuration.Seconds(),
+		ToolsCalled:      decision.ToolsCalled,
+		PromptTokens:     decision.PromptTokens,
+		CompletionTokens: decision.CompletionTokens,
+		Balance:          finalAccountMetrics.TotalBalance,
+		ReturnPercent:    finalAccountMetrics.ReturnPercent,
+		PositionCount:    len(finalPositions),
+		DecisionPreview:  truncateString(decision.DecisionText, 500),
+	}
+	t.sendCycleDigest(ctx, cycleStart, summary, finalAccountMetrics, finalPositions)
+	return summary, nil
+}
+
+// degradedAccountMetrics 获取账户指标失败时使用的降级快照，未开启降级时返回错误
+func (t *TradingLoop) degradedAccountMetrics(ctx context.Context) (*AccountMetrics, error) {
+	maxAge := time.Duration(t.conf.Trading.AccountFallbackMaxAgeMinutes) * time.Minute
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("account metrics fallback disabled")
+	}
+	return t.accountService.DegradedAccountMetrics(ctx, maxAge)
+}
+
+// belowOperatingBalance 检查账户净值是否低于最低运行资金并记录状态，净值恢复后自动继续决策
+func (t *TradingLoop) belowOperatingBalance(balance float64) bool {
+	minBalance := t.conf.Trading.MinOperatingBalance
+	below := minBalance > 0 && balance < minBalance
+
+	t.mu.Lock()
+	wasBelow := t.belowMinBalance
+	t.belowMinBalance = below
+	t.mu.Unlock()
+
+	if below {
+		t.logger.Warn("[RISK] balance below operating minimum, skip LLM decision",
+			zap.Float64("balance", balance),
+			zap.Float64("min_operating_balance", minBalance))
+	} else if wasBelow {
+		t.logger.Info("[RISK] balance recovered above operating minimum, resume LLM decision",
+			zap.Float64("balance", balance),
+			zap.Float64("min_operating_balance", minBalance))
+	}
+	return below
+}
+
+// setActiveDecision 登记正在执行的决策
+func (t *TradingLoop) setActiveDecision(decisionID string, cancel context.CancelCauseFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.activeDecision = &activeDecision{ID: decisionID, StartedAt: time.Now(), cancel: cancel}
+}
+
+// clearActiveDecision 决策结束后清除登记
+func (t *TradingLoop) clearActiveDecision(decisionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.activeDecision != nil && t.activeDecision.ID == decisionID {
+		t.activeDecision = nil
+	}
+}
+
+// CancelCurrentDecision 取消正在执行的决策，返回被取消的决策ID
+//
+// 进行中的LLM调用会被中断，剩余工具不再执行；已经开始的下单操作会执行完毕。
+func (t *TradingLoop) CancelCurrentDecision() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.activeDecision == nil {
+		return "", ErrNoActiveDecision
+	}
+	t.activeDecision.cancel(ErrDecisionCanceled)
+	t.logger.Warn("canceling in-flight decision",
+		zap.String("decision_id", t.activeDecision.ID),
+		zap.Duration("elapsed", time.Since(t.activeDecision.StartedAt)))
+	return t.activeDecision.ID, nil
 }
 
 // IsRunning 检查是否正在运行
 func (t *TradingLoop) IsRunning() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.isRunning
 }
 
+// CheckLiveConfirmed 实盘模式下未显式确认时返回错误，启动交易循环前调用
+func (t *TradingLoop) CheckLiveConfirmed() error {
+	return t.conf.CheckLiveConfirmed()
+}
+
 // GetStatus 获取状态信息
 func (t *TradingLoop) GetStatus(ctx context.Context) (map[string]interface{}, error) {
 	tradingConfig, err := t.adminConfigService.GetTradingConfig(ctx)
 	if err != nil {
 		return nil, err
 	}
+	isRunning, iteration, startTime := t.snapshot()
+
+	var currentDecision map[string]interface{}
+	t.mu.Lock()
+	belowMinBalance := t.belowMinBalance
+	if t.activeDecision != nil {
+		currentDecision = map[string]interface{}{
+			"id":              t.activeDecision.ID,
+			"started_at":      t.activeDecision.StartedAt,
+			"elapsed_seconds": time.Since(t.activeDecision.StartedAt).Seconds(),
+		}
+	}
+	t.mu.Unlock()
+
+	var exchangeHealth exchange.ErrorRateStatus
+	if t.exchangeHealth != nil {
+		exchangeHealth = t.exchangeHealth.Status()
+	}
+
 	return map[string]interface{}{
-		"is_running":       t.isRunning,
-		"iteration":        t.iteration,
-		"start_time":       t.startTime,
-		"elapsed_hours":    time.Since(t.startTime).Hours(),
-		"symbols":          tradingConfig.Symbols,
-		"interval_minutes": tradingConfig.IntervalMinutes,
+		"mode":                  t.conf.TradingMode(),
+		"display_timezone":      t.conf.DisplayLocation().String(),
+		"close_only":            t.agentService.IsCloseOnly(),
+		"below_min_balance":     belowMinBalance,
+		"min_operating_balance": t.conf.Trading.MinOperatingBalance,
+		"is_running":            isRunning,
+		"iteration":             iteration,
+		"start_time":            startTime,
+		"elapsed_hours":         time.Since(startTime).Hours(),
+		"symbols":               tradingConfig.Symbols,
+		"interval_minutes":      tradingConfig.IntervalMinutes,
+		"current_decision":      currentDecision,
+		"degraded":              exchangeHealth.Degraded,
+		"exchange_health":       exchangeHealth,
 	}, nil
 }
 
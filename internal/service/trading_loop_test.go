@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dushixiang/prism/internal/config"
+	"go.uber.org/zap"
+)
+
+// 使用 go test -race 运行以检测数据竞争
+func TestTradingLoopConcurrentStateAccess(t *testing.T) {
+	loop := &TradingLoop{logger: zap.NewNop()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = loop.begin(context.Background())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				loop.Stop()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = loop.IsRunning()
+				_, _, _ = loop.snapshot()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				loop.nextIteration()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, iteration, _ := loop.snapshot(); iteration != 8*100 {
+		t.Fatalf("iteration = %d, want %d", iteration, 8*100)
+	}
+
+	loop.Stop()
+	if loop.IsRunning() {
+		t.Fatal("loop should be stopped")
+	}
+	if _, err := loop.begin(context.Background()); err != nil {
+		t.Fatalf("begin after stop: %v", err)
+	}
+	if _, err := loop.begin(context.Background()); err == nil {
+		t.Fatal("second begin should fail while running")
+	}
+	loop.Stop()
+}
+
+func TestCancelCurrentDecision(t *testing.T) {
+	loop := &TradingLoop{logger: zap.NewNop()}
+	if _, err := loop.CancelCurrentDecision(); !errors.Is(err, ErrNoActiveDecision) {
+		t.Fatalf("expected ErrNoActiveDecision, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	loop.setActiveDecision("d1", cancel)
+
+	id, err := loop.CancelCurrentDecision()
+	if err != nil || id != "d1" {
+		t.Fatalf("cancel = (%q, %v), want (d1, nil)", id, err)
+	}
+	if !isDecisionCanceled(ctx) {
+		t.Fatalf("decision context should be canceled with ErrDecisionCanceled, cause = %v", context.Cause(ctx))
+	}
+
+	// 旧决策结束时不应清掉新登记的决策
+	loop.setActiveDecision("d2", func(error) {})
+	loop.clearActiveDecision("d1")
+	if _, err := loop.CancelCurrentDecision(); err != nil {
+		t.Fatalf("d2 should still be active: %v", err)
+	}
+	loop.clearActiveDecision("d2")
+	if _, err := loop.CancelCurrentDecision(); !errors.Is(err, ErrNoActiveDecision) {
+		t.Fatalf("expected no active decision after clear, got %v", err)
+	}
+}
+
+func TestCycleSpacing(t *testing.T) {
+	conf := config.Default()
+	loop := &TradingLoop{logger: zap.NewNop(), conf: &conf}
+
+	if got := loop.minCycleSpacing(15); got != time.Minute {
+		t.Fatalf("min spacing = %v, want 1m", got)
+	}
+	// 最小间隔不超过交易周期的一半
+	if got := loop.minCycleSpacing(1); got != 30*time.Second {
+		t.Fatalf("min spacing for 1m interval = %v, want 30s", got)
+	}
+
+	base := time.Date(2025, 1, 1, 0, 0, 5, 0, time.UTC)
+	if _, ok := loop.claimCycleSlot(base, time.Minute); !ok {
+		t.Fatal("first cycle should run")
+	}
+	if elapsed, ok := loop.claimCycleSlot(base.Add(10*time.Second), time.Minute); ok || elapsed != 10*time.Second {
+		t.Fatalf("cycle 10s after previous = (%v, %v), want skipped", elapsed, ok)
+	}
+	if _, ok := loop.claimCycleSlot(base.Add(15*time.Minute), time.Minute); !ok {
+		t.Fatal("scheduled cycle should run")
+	}
+
+	conf.Trading.MinCycleSpacingSeconds = 0
+	if got := loop.minCycleSpacing(15); got != 0 {
+		t.Fatalf("disabled min spacing = %v, want 0", got)
+	}
+}
+
+func TestExecuteCycleSkipsWhileRunning(t *testing.T) {
+	loop := &TradingLoop{logger: zap.NewNop()}
+	loop.cycleMu.Lock()
+	defer loop.cycleMu.Unlock()
+
+	// 上一周期未结束时直接跳过，不会访问任何依赖
+	if err := loop.ExecuteCycle(context.Background()); err != nil {
+		t.Fatalf("overlapping cycle should be skipped, got %v", err)
+	}
+	if _, iteration, _ := loop.snapshot(); iteration != 0 {
+		t.Fatalf("iteration = %d, skipped cycle should not advance it", iteration)
+	}
+}
+
+func TestRunOnceRejectsWhileCycleRunning(t *testing.T) {
+	conf := config.Default()
+	loop := &TradingLoop{logger: zap.NewNop(), conf: &conf}
+	loop.cycleMu.Lock()
+	defer loop.cycleMu.Unlock()
+
+	// 手动触发不会静默跳过，而是明确告知已有周期在执行
+	if _, err := loop.RunOnce(context.Background()); !errors.Is(err, ErrCycleInProgress) {
+		t.Fatalf("expected ErrCycleInProgress, got %v", err)
+	}
+}
+
+func TestBelowOperatingBalance(t *testing.T) {
+	conf := config.Default()
+	loop := &TradingLoop{logger: zap.NewNop(), conf: &conf}
+	if loop.belowOperatingBalance(1) {
+		t.Fatal("no minimum configured should never skip")
+	}
+
+	conf.Trading.MinOperatingBalance = 50
+	if !loop.belowOperatingBalance(20) || !loop.belowMinBalance {
+		t.Fatal("balance below minimum should skip decision")
+	}
+	// 净值恢复后自动继续
+	if loop.belowOperatingBalance(60) || loop.belowMinBalance {
+		t.Fatal("recovered balance should resume decision")
+	}
+}
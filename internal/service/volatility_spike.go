@@ -0,0 +1,110 @@
+package service
+
+import "math"
+
+// 波动异常放大时开仓的处理方式，由 trading.volatility_spike_guard 配置
+const (
+	VolatilitySpikeGuardOff    = "off"    // 只在提示词中标注，默认
+	VolatilitySpikeGuardBlock  = "block"  // 拒绝开仓
+	VolatilitySpikeGuardReduce = "reduce" // 按 volatility_spike_margin 缩减保证金
+	VolatilitySpikeGuardWiden  = "widen"  // 止损距离不得小于 volatility_spike_stop_atr 倍短周期ATR
+)
+
+// 未配置时缩减保证金的比例和加宽止损的ATR倍数
+const (
+	defaultVolatilitySpikeMargin  = 0.5
+	defaultVolatilitySpikeStopATR = 1.5
+)
+
+// VolatilitySpike 交易对1小时短周期ATR相对长周期ATR的放大情况
+//
+// 短周期ATR（默认ATR3）远高于长周期基准（默认ATR14）通常意味着消息行情或连环爆仓，
+// 按常规距离设置的止损容易被瞬间击穿。
+type VolatilitySpike struct {
+	Timeframe  string  `json:"timeframe"`
+	FastPeriod int     `json:"fast_period"`
+	SlowPeriod int     `json:"slow_period"`
+	ATRFast    float64 `json:"atr_fast"`
+	ATRSlow    float64 `json:"atr_slow"`
+	Ratio      float64 `json:"ratio"`     // ATRFast / ATRSlow
+	Threshold  float64 `json:"threshold"` // 视为异常放大的倍数
+}
+
+// Active 短周期ATR是否已达到异常放大的倍数
+func (v *VolatilitySpike) Active() bool {
+	return v != nil && v.Threshold > 0 && v.Ratio >= v.Threshold
+}
+
+// normalizeVolatilitySpikeGuard 规范化波动异常放大时的处理方式，未知值按只标注处理
+func normalizeVolatilitySpikeGuard(mode string) string {
+	switch mode {
+	case VolatilitySpikeGuardBlock, VolatilitySpikeGuardReduce, VolatilitySpikeGuardWiden:
+		return mode
+	default:
+		return VolatilitySpikeGuardOff
+	}
+}
+
+// detectVolatilitySpike 根据1小时指标计算短周期ATR的放大倍数，threshold 不大于0或指标缺失时返回 nil
+func detectVolatilitySpike(ind *TimeframeIndicators, threshold float64) *VolatilitySpike {
+	if threshold <= 0 || ind == nil || ind.ATRSlow <= 0 || math.IsNaN(ind.ATRFast) {
+		return nil
+	}
+	return &VolatilitySpike{
+		Timeframe:  regimeTimeframe,
+		FastPeriod: ind.Params.ATRFast,
+		SlowPeriod: ind.Params.ATRSlow,
+		ATRFast:    ind.ATRFast,
+		ATRSlow:    ind.ATRSlow,
+		Ratio:      ind.ATRFast / ind.ATRSlow,
+		Threshold:  threshold,
+	}
+}
+
+// volatilitySpikeMinStop 加宽止损模式下允许的最近止损价：开仓价按方向让出 multiple 倍短周期ATR
+func volatilitySpikeMinStop(side string, price, atrFast, multiple float64) float64 {
+	if side == "short" {
+		return price + atrFast*multiple
+	}
+	return price - atrFast*multiple
+}
+
+// volatilitySpikeMarginMultiplier 缩减保证金模式下的保证金倍数，未配置或超出 (0, 1] 时使用默认值
+func volatilitySpikeMarginMultiplier(configured float64) float64 {
+	if configured <= 0 || configured > 1 {
+		return defaultVolatilitySpikeMargin
+	}
+	return configured
+}
+
+// volatilitySpikeStopATR 加宽止损模式下止损距离的短周期ATR倍数，未配置时使用默认值
+func volatilitySpikeStopATR(configured float64) float64 {
+	if configured <= 0 {
+		return defaultVolatilitySpikeStopATR
+	}
+	return configured
+}
+
+// checkVolatilitySpike 交易对波动异常放大时按配置拒绝开仓，或要求止损让出足够的ATR距离
+func checkVolatilitySpike(req *openRequest) error {
+	spike := req.VolatilitySpike
+	if !spike.Active() {
+		return nil
+	}
+	switch req.VolatilitySpikeGuard {
+	case VolatilitySpikeGuardBlock:
+		return localizeError(req.Language, "rule.volatility_blocked",
+			req.Symbol, spike.Timeframe, spike.FastPeriod, spike.SlowPeriod, spike.Ratio, spike.Threshold)
+	case VolatilitySpikeGuardWiden:
+		if req.StopLossPrice <= 0 || req.Price <= 0 {
+			return nil
+		}
+		multiple := volatilitySpikeStopATR(req.VolatilitySpikeStopATR)
+		minStop := volatilitySpikeMinStop(req.Side, req.Price, spike.ATRFast, multiple)
+		if (req.Side == "short" && req.StopLossPrice < minStop) || (req.Side != "short" && req.StopLossPrice > minStop) {
+			return localizeError(req.Language, "rule.volatility_stop",
+				req.Symbol, spike.Timeframe, spike.FastPeriod, spike.SlowPeriod, spike.Ratio, req.StopLossPrice, multiple, spike.FastPeriod, minStop)
+		}
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ const (
 var (
 	handlerSet = wire.NewSet(
 		handler.NewTradingHandler,
+		handler.NewMarketHandler,
 		handler.NewAdminHandler,
 		handler.NewAuthHandler,
 		handler.NewSetupHandler,
@@ -44,6 +45,7 @@ var (
 		provideOpenAIClient,
 		repo.NewTradeRepo,
 		repo.NewOrderRepo,
+		repo.NewPositionRepo,
 		repo.NewTradingConfigRepo,
 		repo.NewSystemPromptRepo,
 		repo.NewAdminUserRepo,
@@ -51,6 +53,11 @@ var (
 		service.NewMarketService,
 		service.NewTradingAccountService,
 		service.NewPositionService,
+		service.NewFundingService,
+		service.NewRetentionService,
+		service.NewOutcomeService,
+		service.NewNotifyService,
+		service.NewExchangeHealthService,
 		service.NewPromptService,
 		service.NewAgentService,
 		service.NewTradingLoop,
@@ -100,6 +107,18 @@ func provideBinanceClient(conf *config.Config, logger *zap.Logger) *exchange.Bin
 		conf.Binance.Testnet,
 	)
 
+	if conf.Binance.WeightPerMinute > 0 {
+		client.SetWeightPerMinute(conf.Binance.WeightPerMinute)
+	}
+	if interval := conf.Binance.SymbolInfoRefreshInterval(); interval > 0 {
+		// 缓存有效期覆盖两个刷新周期，单次刷新失败时不会退回到下单路径上临时拉取
+		client.SetSymbolInfoTTL(2 * interval)
+	}
+
+	if alert := conf.Binance.ErrorAlert; alert.Enabled {
+		client.SetErrorMonitor(exchange.NewErrorMonitor(alert.Window(), alert.ThresholdPercent, alert.MinCalls))
+	}
+
 	if conf.Binance.APIKey == "" || conf.Binance.Secret == "" {
 		logger.Warn("Binance API credentials not configured; some private endpoints may fail")
 	}
@@ -113,7 +132,7 @@ func provideBinanceClient(conf *config.Config, logger *zap.Logger) *exchange.Bin
 
 // provideExchange provides Exchange interface based on configuration
 func provideExchange(conf *config.Config, binanceClient *exchange.BinanceClient, logger *zap.Logger) exchange.Exchange {
-	if conf.Trading.Enabled {
+	if conf.IsLive() {
 		// 真实交易模式
 		logger.Info("Using real trading mode (Binance)")
 		return binanceClient
@@ -30,25 +30,33 @@ func InitializeApp(logger *zap.Logger, db *gorm.DB, conf *config.Config) (*AppCo
 	binanceClient := provideBinanceClient(conf, logger)
 	exchange := provideExchange(conf, binanceClient, logger)
 	indicatorService := service.NewIndicatorService()
-	marketService := service.NewMarketService(db, exchange, indicatorService, logger)
-	tradingAccountService := service.NewTradingAccountService(db, exchange, logger)
+	marketService := service.NewMarketService(db, exchange, indicatorService, conf, logger)
+	adminConfigService := service.NewAdminConfigService(logger, db)
+	tradingAccountService := service.NewTradingAccountService(db, exchange, adminConfigService, conf, logger)
 	orderRepo := repo.NewOrderRepo(db)
 	tradeRepo := repo.NewTradeRepo(db)
-	positionService := service.NewPositionService(db, exchange, orderRepo, tradeRepo, logger)
-	adminConfigService := service.NewAdminConfigService(logger, db)
-	promptService := service.NewPromptService(tradeRepo, orderRepo, adminConfigService)
+	positionService := service.NewPositionService(db, exchange, orderRepo, tradeRepo, logger, conf)
+	positionRepo := repo.NewPositionRepo(db)
+	promptService := service.NewPromptService(tradeRepo, orderRepo, positionRepo, adminConfigService, conf)
 	client := provideOpenAIClient(conf, logger)
-	agentService := service.NewAgentService(logger, db, client, exchange, positionService, adminConfigService, conf)
-	tradingLoop := service.NewTradingLoop(marketService, tradingAccountService, positionService, promptService, agentService, adminConfigService, orderRepo, logger)
-	tradingHandler := handler.NewTradingHandler(tradingLoop, tradingAccountService, positionService, agentService, logger)
-	adminHandler := handler.NewAdminHandler(logger, adminConfigService)
+	agentService := service.NewAgentService(logger, db, client, exchange, indicatorService, positionService, tradingAccountService, adminConfigService, conf)
+	telegram := provideTelegram(logger, conf)
+	notifyService := service.NewNotifyService(telegram, conf, logger)
+	exchangeHealthService := service.NewExchangeHealthService(binanceClient, notifyService, conf, logger)
+	tradingLoop := service.NewTradingLoop(marketService, tradingAccountService, positionService, promptService, agentService, adminConfigService, notifyService, exchangeHealthService, orderRepo, logger, conf)
+	tradingHandler := handler.NewTradingHandler(tradingLoop, tradingAccountService, positionService, agentService, binanceClient, logger)
+	marketHandler := handler.NewMarketHandler(logger, marketService, adminConfigService)
+	retentionService := service.NewRetentionService(db, conf, logger)
+	adminHandler := handler.NewAdminHandler(logger, adminConfigService, tradingAccountService, retentionService, tradingLoop)
 	string2 := provideJWTSecret(conf)
 	authService := service.NewAuthService(logger, db, string2)
 	authHandler := handler.NewAuthHandler(logger, authService)
 	setupHandler := handler.NewSetupHandler(logger, authService)
-	telegram := provideTelegram(logger, conf)
+	fundingService := service.NewFundingService(db, exchange, logger)
+	outcomeService := service.NewOutcomeService(db, exchange, conf, logger)
 	appComponents := &AppComponents{
 		TradingHandler:        tradingHandler,
+		MarketHandler:         marketHandler,
 		AdminHandler:          adminHandler,
 		AuthHandler:           authHandler,
 		SetupHandler:          setupHandler,
@@ -59,6 +67,10 @@ func InitializeApp(logger *zap.Logger, db *gorm.DB, conf *config.Config) (*AppCo
 		AgentService:          agentService,
 		AuthService:           authService,
 		AdminConfigService:    adminConfigService,
+		FundingService:        fundingService,
+		RetentionService:      retentionService,
+		OutcomeService:        outcomeService,
+		ExchangeHealthService: exchangeHealthService,
 		tg:                    telegram,
 	}
 	return appComponents, nil
@@ -74,12 +86,12 @@ const (
 )
 
 var (
-	handlerSet = wire.NewSet(handler.NewTradingHandler, handler.NewAdminHandler, handler.NewAuthHandler, handler.NewSetupHandler)
+	handlerSet = wire.NewSet(handler.NewTradingHandler, handler.NewMarketHandler, handler.NewAdminHandler, handler.NewAuthHandler, handler.NewSetupHandler)
 
 	tradingSet = wire.NewSet(
 		provideBinanceClient,
 		provideExchange,
-		provideOpenAIClient, repo.NewTradeRepo, repo.NewOrderRepo, repo.NewTradingConfigRepo, repo.NewSystemPromptRepo, repo.NewAdminUserRepo, service.NewIndicatorService, service.NewMarketService, service.NewTradingAccountService, service.NewPositionService, service.NewPromptService, service.NewAgentService, service.NewTradingLoop, service.NewAdminConfigService, service.NewAuthService, provideJWTSecret,
+		provideOpenAIClient, repo.NewTradeRepo, repo.NewOrderRepo, repo.NewPositionRepo, repo.NewTradingConfigRepo, repo.NewSystemPromptRepo, repo.NewAdminUserRepo, service.NewIndicatorService, service.NewMarketService, service.NewTradingAccountService, service.NewPositionService, service.NewFundingService, service.NewRetentionService, service.NewOutcomeService, service.NewNotifyService, service.NewExchangeHealthService, service.NewPromptService, service.NewAgentService, service.NewTradingLoop, service.NewAdminConfigService, service.NewAuthService, provideJWTSecret,
 	)
 )
 
@@ -112,6 +124,18 @@ func provideBinanceClient(conf *config.Config, logger *zap.Logger) *exchange.Bin
 		conf.Binance.Testnet,
 	)
 
+	if conf.Binance.WeightPerMinute > 0 {
+		client.SetWeightPerMinute(conf.Binance.WeightPerMinute)
+	}
+	if interval := conf.Binance.SymbolInfoRefreshInterval(); interval > 0 {
+
+		client.SetSymbolInfoTTL(2 * interval)
+	}
+
+	if alert := conf.Binance.ErrorAlert; alert.Enabled {
+		client.SetErrorMonitor(exchange.NewErrorMonitor(alert.Window(), alert.ThresholdPercent, alert.MinCalls))
+	}
+
 	if conf.Binance.APIKey == "" || conf.Binance.Secret == "" {
 		logger.Warn("Binance API credentials not configured; some private endpoints may fail")
 	}
@@ -122,7 +146,7 @@ func provideBinanceClient(conf *config.Config, logger *zap.Logger) *exchange.Bin
 
 // provideExchange provides Exchange interface based on configuration
 func provideExchange(conf *config.Config, binanceClient *exchange.BinanceClient, logger *zap.Logger) exchange.Exchange {
-	if conf.Trading.Enabled {
+	if conf.IsLive() {
 
 		logger.Info("Using real trading mode (Binance)")
 		return binanceClient
@@ -2,12 +2,17 @@ package exchange
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adshao/go-binance/v2/common"
 	"github.com/adshao/go-binance/v2/futures"
 )
 
@@ -54,11 +59,27 @@ func fromBinanceOrderStatus(status futures.OrderStatusType) OrderStatus {
 	}
 }
 
+// defaultSymbolInfoTTL 交易对信息默认缓存有效期
+const defaultSymbolInfoTTL = 5 * time.Minute
+
 // BinanceClient Binance期货API客户端
 type BinanceClient struct {
-	client         *futures.Client
+	client         atomic.Pointer[futures.Client] // go-binance 客户端，修改配置时整体替换，进行中的请求继续使用旧实例
+	clientLock     sync.Mutex                     // 串行化对 client 的替换
 	symbolInfoMap  map[string]*SymbolInfo
 	symbolInfoLock sync.RWMutex
+	symbolInfoTTL  time.Duration // 交易对信息缓存有效期
+	refreshLock    sync.Mutex
+	refreshCall    *exchangeInfoCall // 进行中的 exchangeInfo 请求，没有时为nil
+	limiter        *WeightLimiter    // 所有REST请求共享的权重限流器
+	errorMonitor   *ErrorMonitor     // 调用失败率监控，未设置时不统计
+	timeOffset     atomic.Int64      // 本地时钟减去服务器时间（毫秒），由 SyncServerTime 更新
+}
+
+// exchangeInfoCall 一次进行中的 exchangeInfo 请求，并发的缓存未命中共享同一次请求的结果
+type exchangeInfoCall struct {
+	done chan struct{}
+	err  error
 }
 
 // SymbolInfo 交易对信息
@@ -69,10 +90,21 @@ type SymbolInfo struct {
 	MinQuantity       float64
 	MaxQuantity       float64
 	StepSize          float64
+	TickSize          float64 // 价格最小变动单位（PRICE_FILTER）
 	MinNotional       float64
+	Status            string    // 交易状态，TRADING 为正常交易，下架或暂停时为 SETTLING、CLOSE 等
+	OnboardDate       time.Time // 上线时间，交易所未提供时为零值
 	lastUpdated       time.Time
 }
 
+// SymbolStatusTrading 交易对正常交易的状态
+const SymbolStatusTrading = "TRADING"
+
+// Tradable 交易对是否处于正常交易状态，状态未知时视为可交易
+func (i *SymbolInfo) Tradable() bool {
+	return i.Status == "" || i.Status == SymbolStatusTrading
+}
+
 // NewBinanceClient 创建Binance客户端
 func NewBinanceClient(apiKey, secretKey, proxyURL string, testnet bool) *BinanceClient {
 	if testnet {
@@ -87,10 +119,125 @@ func NewBinanceClient(apiKey, secretKey, proxyURL string, testnet bool) *Binance
 		client = futures.NewClient(apiKey, secretKey)
 	}
 
-	return &BinanceClient{
-		client:        client,
+	b := &BinanceClient{
 		symbolInfoMap: make(map[string]*SymbolInfo),
+		symbolInfoTTL: defaultSymbolInfoTTL,
+		limiter:       NewWeightLimiter(DefaultWeightPerMinute),
+	}
+	b.client.Store(client)
+	return b
+}
+
+// api 返回当前的 go-binance 客户端
+func (b *BinanceClient) api() *futures.Client {
+	return b.client.Load()
+}
+
+// updateAPI 复制当前的 go-binance 客户端修改后整体替换
+//
+// go-binance 在签名请求时不加锁读取 TimeOffset 等字段，直接修改会与并发请求产生数据竞争
+func (b *BinanceClient) updateAPI(update func(client *futures.Client)) {
+	b.clientLock.Lock()
+	defer b.clientLock.Unlock()
+	client := *b.client.Load()
+	update(&client)
+	b.client.Store(&client)
+}
+
+// SetWeightPerMinute 设置每分钟请求权重上限
+func (b *BinanceClient) SetWeightPerMinute(weightPerMinute int) {
+	b.limiter = NewWeightLimiter(weightPerMinute)
+}
+
+// RateLimitUsage 返回请求权重限流器的使用情况
+func (b *BinanceClient) RateLimitUsage() RateLimitUsage {
+	if b.limiter == nil {
+		return RateLimitUsage{}
+	}
+	return b.limiter.Usage()
+}
+
+// SetErrorMonitor 设置调用失败率监控，在HTTP层统计所有REST请求的成败
+func (b *BinanceClient) SetErrorMonitor(monitor *ErrorMonitor) {
+	b.updateAPI(func(client *futures.Client) {
+		base := client.HTTPClient
+		if base == nil {
+			base = http.DefaultClient
+		}
+		// 复制一份客户端，避免修改共享的 http.DefaultClient
+		httpClient := *base
+		httpClient.Transport = monitor.Transport(base.Transport)
+		client.HTTPClient = &httpClient
+	})
+	b.errorMonitor = monitor
+}
+
+// ErrorMonitor 返回调用失败率监控，未设置时为nil
+func (b *BinanceClient) ErrorMonitor() *ErrorMonitor {
+	return b.errorMonitor
+}
+
+// ErrorRate 返回调用失败率监控状态
+func (b *BinanceClient) ErrorRate() ErrorRateStatus {
+	if b.errorMonitor == nil {
+		return ErrorRateStatus{}
+	}
+	return b.errorMonitor.Status()
+}
+
+// GetServerTime 获取交易所服务器时间
+func (b *BinanceClient) GetServerTime(ctx context.Context) (time.Time, error) {
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return time.Time{}, err
 	}
+	serverTime, err := b.api().NewServerTimeService().Do(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get server time: %w", err)
+	}
+	return time.UnixMilli(serverTime), nil
+}
+
+// clockOffset 根据请求发出、收到响应的本地时间和服务器时间计算本地时钟偏移（本地减服务器）
+// 服务器时间视为在请求往返的中点生成，抵消网络延迟的影响，误差不超过往返时间的一半；
+// 请求期间本地时钟回拨导致 received 早于 sent 时按往返时间为0处理
+func clockOffset(sent, received, serverTime time.Time) time.Duration {
+	roundTrip := max(received.Sub(sent), 0)
+	return sent.Add(roundTrip / 2).Sub(serverTime)
+}
+
+// SyncServerTime 查询服务器时间并更新本地时钟偏移，返回偏移量
+//
+// 偏移同时写入 go-binance 客户端，之后签名请求的 timestamp 按服务器时间生成，避免时钟漂移导致
+// 超出 recvWindow 的签名错误。
+func (b *BinanceClient) SyncServerTime(ctx context.Context) (time.Duration, error) {
+	sent := time.Now()
+	serverTime, err := b.GetServerTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	offset := clockOffset(sent, time.Now(), serverTime)
+	b.timeOffset.Store(offset.Milliseconds())
+	b.updateAPI(func(client *futures.Client) {
+		client.TimeOffset = offset.Milliseconds()
+	})
+	return offset, nil
+}
+
+// TimeOffset 返回最近一次同步得到的本地时钟偏移（本地减服务器），未同步时为0
+// 交易所返回的毫秒时间戳加上该偏移即为对应的本地时间
+func (b *BinanceClient) TimeOffset() time.Duration {
+	return time.Duration(b.timeOffset.Load()) * time.Millisecond
+}
+
+// waitWeight 请求前等待限流器放行，超出权重上限时短暂阻塞而不是报错
+func (b *BinanceClient) waitWeight(ctx context.Context, weight int) error {
+	if b.limiter == nil {
+		return nil
+	}
+	if err := b.limiter.Wait(ctx, weight); err != nil {
+		return fmt.Errorf("rate limiter wait canceled: %w", err)
+	}
+	return nil
 }
 
 // Kline K线数据
@@ -106,7 +253,10 @@ type Kline struct {
 
 // GetKlines 获取K线数据
 func (b *BinanceClient) GetKlines(ctx context.Context, symbol string, interval string, limit int) ([]*Kline, error) {
-	klines, err := b.client.NewKlinesService().
+	if err := b.waitWeight(ctx, klinesWeight(limit)); err != nil {
+		return nil, err
+	}
+	klines, err := b.api().NewKlinesService().
 		Symbol(symbol).
 		Interval(interval).
 		Limit(limit).
@@ -139,28 +289,44 @@ func (b *BinanceClient) GetKlines(ctx context.Context, symbol string, interval s
 }
 
 // AccountInfo 账户信息
+//
+// 实盘和纸钱包使用相同的口径：MarginBalance = WalletBalance + UnrealizedPnl，即账户净值，
+// 收益率、回撤和仓位风险都以 MarginBalance 为基准。
 type AccountInfo struct {
-	TotalBalance     float64 // 总余额
-	AvailableBalance float64 // 可用余额
+	WalletBalance    float64 // 钱包余额：已实现盈亏、手续费、资金费和出入金的累计，不含未实现盈亏
+	MarginBalance    float64 // 保证金余额（账户净值）= 钱包余额 + 未实现盈亏
+	AvailableBalance float64 // 可用余额 = 保证金余额 - 已占用保证金
 	UnrealizedPnl    float64 // 未实现盈亏
 }
 
 // GetAccountInfo 获取账户信息
 func (b *BinanceClient) GetAccountInfo(ctx context.Context) (*AccountInfo, error) {
-	account, err := b.client.NewGetAccountService().Do(ctx)
+	if err := b.waitWeight(ctx, weightAccount); err != nil {
+		return nil, err
+	}
+	account, err := b.api().NewGetAccountService().Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get account info: %w", err)
 	}
+	return parseAccountInfo(account), nil
+}
 
-	totalBalance, _ := strconv.ParseFloat(account.TotalMarginBalance, 64)
+// parseAccountInfo 解析交易所账户信息，保证金余额缺失时按钱包余额 + 未实现盈亏计算
+func parseAccountInfo(account *futures.Account) *AccountInfo {
+	walletBalance, _ := strconv.ParseFloat(account.TotalWalletBalance, 64)
 	availableBalance, _ := strconv.ParseFloat(account.AvailableBalance, 64)
 	unrealizedPnl, _ := strconv.ParseFloat(account.TotalUnrealizedProfit, 64)
+	marginBalance, err := strconv.ParseFloat(account.TotalMarginBalance, 64)
+	if err != nil {
+		marginBalance = walletBalance + unrealizedPnl
+	}
 
 	return &AccountInfo{
-		TotalBalance:     totalBalance,
+		WalletBalance:    walletBalance,
+		MarginBalance:    marginBalance,
 		AvailableBalance: availableBalance,
 		UnrealizedPnl:    unrealizedPnl,
-	}, nil
+	}
 }
 
 // Position 持仓信息
@@ -175,9 +341,20 @@ type Position struct {
 	LiquidationPrice float64 // 强平价格
 }
 
+// InitialMargin 按开仓均价计算持仓占用的初始保证金，纸钱包和实盘持仓同步统一使用该口径
+func InitialMargin(entryPrice, amount float64, leverage int) float64 {
+	if leverage <= 0 {
+		return 0
+	}
+	return math.Abs(entryPrice*amount) / float64(leverage)
+}
+
 // GetPositions 获取当前持仓
 func (b *BinanceClient) GetPositions(ctx context.Context) ([]*Position, error) {
-	positions, err := b.client.NewGetPositionRiskService().Do(ctx)
+	if err := b.waitWeight(ctx, weightPositionRisk); err != nil {
+		return nil, err
+	}
+	positions, err := b.api().NewGetPositionRiskService().Do(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
@@ -220,7 +397,10 @@ func (b *BinanceClient) GetPositions(ctx context.Context) ([]*Position, error) {
 
 // SetLeverage 设置杠杆倍数
 func (b *BinanceClient) SetLeverage(ctx context.Context, symbol string, leverage int) error {
-	_, err := b.client.NewChangeLeverageService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return err
+	}
+	_, err := b.api().NewChangeLeverageService().
 		Symbol(symbol).
 		Leverage(leverage).
 		Do(ctx)
@@ -235,7 +415,10 @@ func (b *BinanceClient) SetLeverage(ctx context.Context, symbol string, leverage
 // SetMarginType 设置保证金模式
 func (b *BinanceClient) SetMarginType(ctx context.Context, symbol string, marginType MarginType) error {
 	binanceMarginType := toBinanceMarginType(marginType)
-	err := b.client.NewChangeMarginTypeService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return err
+	}
+	err := b.api().NewChangeMarginTypeService().
 		Symbol(symbol).
 		MarginType(binanceMarginType).
 		Do(ctx)
@@ -280,7 +463,10 @@ func (b *BinanceClient) CreateMarketOrder(ctx context.Context, symbol string, si
 	quantityStr := strconv.FormatFloat(formattedQty, 'f', info.QuantityPrecision, 64)
 
 	binanceSide := toBinanceSideType(side)
-	service := b.client.NewCreateOrderService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return nil, err
+	}
+	service := b.api().NewCreateOrderService().
 		Symbol(symbol).
 		Side(binanceSide).
 		Type(futures.OrderTypeMarket).
@@ -292,6 +478,9 @@ func (b *BinanceClient) CreateMarketOrder(ctx context.Context, symbol string, si
 
 	order, err := service.Do(ctx)
 	if err != nil {
+		if reduceOnly && isReduceOnlyRejected(err) {
+			return nil, fmt.Errorf("%w: %v", ErrReduceOnlyRejected, err)
+		}
 		return nil, fmt.Errorf("failed to create market order: %w", err)
 	}
 
@@ -311,6 +500,18 @@ func (b *BinanceClient) CreateMarketOrder(ctx context.Context, symbol string, si
 	}, nil
 }
 
+// binanceCodeReduceOnlyRejected 币安拒绝只减仓订单的错误码（持仓已平或方向不符）
+const binanceCodeReduceOnlyRejected = -2022
+
+// isReduceOnlyRejected 判断是否为只减仓订单被拒绝
+func isReduceOnlyRejected(err error) bool {
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == binanceCodeReduceOnlyRejected
+	}
+	return strings.Contains(err.Error(), "ReduceOnly Order is rejected")
+}
+
 // OpenLongPosition 开多仓
 func (b *BinanceClient) OpenLongPosition(ctx context.Context, symbol string, quantity float64) (*OrderResult, error) {
 	return b.CreateMarketOrder(ctx, symbol, OrderSideBuy, quantity, false)
@@ -333,7 +534,10 @@ func (b *BinanceClient) CloseShortPosition(ctx context.Context, symbol string, q
 
 // GetCurrentPrice 获取当前价格
 func (b *BinanceClient) GetCurrentPrice(ctx context.Context, symbol string) (float64, error) {
-	prices, err := b.client.NewListPricesService().Symbol(symbol).Do(ctx)
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return 0, err
+	}
+	prices, err := b.api().NewListPricesService().Symbol(symbol).Do(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get current price: %w", err)
 	}
@@ -346,9 +550,54 @@ func (b *BinanceClient) GetCurrentPrice(ctx context.Context, symbol string) (flo
 	return price, nil
 }
 
+// GetBookTicker 获取盘口最优买卖价
+func (b *BinanceClient) GetBookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	if err := b.waitWeight(ctx, weightBookTicker); err != nil {
+		return nil, err
+	}
+	tickers, err := b.api().NewListBookTickersService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book ticker: %w", err)
+	}
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no book ticker for symbol %s", symbol)
+	}
+
+	ticker := &BookTicker{Symbol: tickers[0].Symbol}
+	ticker.BidPrice, _ = strconv.ParseFloat(tickers[0].BidPrice, 64)
+	ticker.BidQty, _ = strconv.ParseFloat(tickers[0].BidQuantity, 64)
+	ticker.AskPrice, _ = strconv.ParseFloat(tickers[0].AskPrice, 64)
+	ticker.AskQty, _ = strconv.ParseFloat(tickers[0].AskQuantity, 64)
+	return ticker, nil
+}
+
+// Get24hrStats 获取24小时行情统计
+func (b *BinanceClient) Get24hrStats(ctx context.Context, symbol string) (*Ticker24h, error) {
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return nil, err
+	}
+	stats, err := b.api().NewListPriceChangeStatsService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get 24hr stats: %w", err)
+	}
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("no 24hr stats for symbol %s", symbol)
+	}
+
+	ticker := &Ticker24h{Symbol: stats[0].Symbol}
+	ticker.LastPrice, _ = strconv.ParseFloat(stats[0].LastPrice, 64)
+	ticker.PriceChangePercent, _ = strconv.ParseFloat(stats[0].PriceChangePercent, 64)
+	ticker.Volume, _ = strconv.ParseFloat(stats[0].Volume, 64)
+	ticker.QuoteVolume, _ = strconv.ParseFloat(stats[0].QuoteVolume, 64)
+	return ticker, nil
+}
+
 // GetFundingRate 获取资金费率
 func (b *BinanceClient) GetFundingRate(ctx context.Context, symbol string) (float64, error) {
-	rates, err := b.client.NewFundingRateService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return 0, err
+	}
+	rates, err := b.api().NewFundingRateService().
 		Symbol(symbol).
 		Limit(1).
 		Do(ctx)
@@ -367,7 +616,10 @@ func (b *BinanceClient) GetFundingRate(ctx context.Context, symbol string) (floa
 
 // CancelOrder 取消订单
 func (b *BinanceClient) CancelOrder(ctx context.Context, symbol string, orderID int64) error {
-	_, err := b.client.NewCancelOrderService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return err
+	}
+	_, err := b.api().NewCancelOrderService().
 		Symbol(symbol).
 		OrderID(orderID).
 		Do(ctx)
@@ -381,7 +633,10 @@ func (b *BinanceClient) CancelOrder(ctx context.Context, symbol string, orderID
 
 // GetOrderStatus 获取订单状态
 func (b *BinanceClient) GetOrderStatus(ctx context.Context, symbol string, orderID int64) (*OrderResult, error) {
-	order, err := b.client.NewGetOrderService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return nil, err
+	}
+	order, err := b.api().NewGetOrderService().
 		Symbol(symbol).
 		OrderID(orderID).
 		Do(ctx)
@@ -410,62 +665,147 @@ func (b *BinanceClient) GetOrderStatus(ctx context.Context, symbol string, order
 
 // GetSymbolInfo 获取交易对信息
 func (b *BinanceClient) GetSymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error) {
-	// 检查缓存（5分钟有效期）
+	// 检查缓存（默认5分钟有效期，启用预热刷新时按刷新间隔延长）
 	b.symbolInfoLock.RLock()
 	if info, exists := b.symbolInfoMap[symbol]; exists {
-		if time.Since(info.lastUpdated) < 5*time.Minute {
+		if time.Since(info.lastUpdated) < b.symbolInfoTTL {
 			b.symbolInfoLock.RUnlock()
 			return info, nil
 		}
 	}
 	b.symbolInfoLock.RUnlock()
 
-	// 获取交易对信息
-	exchangeInfo, err := b.client.NewExchangeInfoService().Do(ctx)
+	if err := b.WarmSymbolInfo(ctx, []string{symbol}); err != nil {
+		return nil, err
+	}
+
+	b.symbolInfoLock.RLock()
+	defer b.symbolInfoLock.RUnlock()
+	return b.symbolInfoMap[symbol], nil
+}
+
+// refreshSymbolInfo 拉取交易所信息并替换全部交易对缓存
+//
+// exchangeInfo 响应很大且包含所有交易对，启动或并发收集行情时多个交易对同时未命中缓存，
+// 只由第一个调用方发起请求，其余调用方等待并共享同一次请求的结果。
+func (b *BinanceClient) refreshSymbolInfo(ctx context.Context) error {
+	b.refreshLock.Lock()
+	if call := b.refreshCall; call != nil {
+		b.refreshLock.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &exchangeInfoCall{done: make(chan struct{})}
+	b.refreshCall = call
+	b.refreshLock.Unlock()
+
+	call.err = b.fetchSymbolInfo(ctx)
+
+	b.refreshLock.Lock()
+	b.refreshCall = nil
+	b.refreshLock.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// fetchSymbolInfo 请求 exchangeInfo 并缓存所有交易对，已下架的交易对随之从缓存中移除
+func (b *BinanceClient) fetchSymbolInfo(ctx context.Context) error {
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return err
+	}
+	exchangeInfo, err := b.api().NewExchangeInfoService().Do(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get exchange info: %w", err)
+		return fmt.Errorf("failed to get exchange info: %w", err)
 	}
 
-	// 查找指定交易对
+	now := time.Now()
+	symbolInfoMap := make(map[string]*SymbolInfo, len(exchangeInfo.Symbols))
 	for _, s := range exchangeInfo.Symbols {
-		if s.Symbol == symbol {
-			info := &SymbolInfo{
-				Symbol:            s.Symbol,
-				QuantityPrecision: s.QuantityPrecision,
-				PricePrecision:    s.PricePrecision,
-				lastUpdated:       time.Now(),
-			}
+		info := parseSymbolInfo(s)
+		info.lastUpdated = now
+		symbolInfoMap[s.Symbol] = info
+	}
 
-			// 解析过滤器
-			for _, filter := range s.Filters {
-				switch filter["filterType"] {
-				case "LOT_SIZE":
-					if minQty, ok := filter["minQty"].(string); ok {
-						info.MinQuantity, _ = strconv.ParseFloat(minQty, 64)
-					}
-					if maxQty, ok := filter["maxQty"].(string); ok {
-						info.MaxQuantity, _ = strconv.ParseFloat(maxQty, 64)
-					}
-					if stepSize, ok := filter["stepSize"].(string); ok {
-						info.StepSize, _ = strconv.ParseFloat(stepSize, 64)
-					}
-				case "MIN_NOTIONAL":
-					if notional, ok := filter["notional"].(string); ok {
-						info.MinNotional, _ = strconv.ParseFloat(notional, 64)
-					}
-				}
-			}
+	b.symbolInfoLock.Lock()
+	b.symbolInfoMap = symbolInfoMap
+	b.symbolInfoLock.Unlock()
+	return nil
+}
+
+// SetSymbolInfoTTL 设置交易对信息缓存有效期
+func (b *BinanceClient) SetSymbolInfoTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultSymbolInfoTTL
+	}
+	b.symbolInfoTTL = ttl
+}
 
-			// 缓存信息
-			b.symbolInfoLock.Lock()
-			b.symbolInfoMap[symbol] = info
-			b.symbolInfoLock.Unlock()
+// WarmSymbolInfo 一次拉取交易所信息并缓存所有交易对，避免下单路径上临时请求 exchangeInfo
+//
+// 指定的交易对中有不存在的时返回错误并列出缺失的交易对
+func (b *BinanceClient) WarmSymbolInfo(ctx context.Context, symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
 
-			return info, nil
+	if err := b.refreshSymbolInfo(ctx); err != nil {
+		return err
+	}
+
+	var missing []string
+	b.symbolInfoLock.RLock()
+	for _, symbol := range symbols {
+		if _, ok := b.symbolInfoMap[symbol]; !ok {
+			missing = append(missing, symbol)
 		}
 	}
+	b.symbolInfoLock.RUnlock()
 
-	return nil, fmt.Errorf("symbol %s not found", symbol)
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrSymbolNotFound, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseSymbolInfo 从交易所信息中解析精度与 LOT_SIZE/PRICE_FILTER/MIN_NOTIONAL 过滤器
+func parseSymbolInfo(s futures.Symbol) *SymbolInfo {
+	info := &SymbolInfo{
+		Symbol:            s.Symbol,
+		QuantityPrecision: s.QuantityPrecision,
+		PricePrecision:    s.PricePrecision,
+		Status:            s.Status,
+	}
+	if s.OnboardDate > 0 {
+		info.OnboardDate = time.UnixMilli(s.OnboardDate)
+	}
+
+	for _, filter := range s.Filters {
+		switch filter["filterType"] {
+		case "LOT_SIZE":
+			if minQty, ok := filter["minQty"].(string); ok {
+				info.MinQuantity, _ = strconv.ParseFloat(minQty, 64)
+			}
+			if maxQty, ok := filter["maxQty"].(string); ok {
+				info.MaxQuantity, _ = strconv.ParseFloat(maxQty, 64)
+			}
+			if stepSize, ok := filter["stepSize"].(string); ok {
+				info.StepSize, _ = strconv.ParseFloat(stepSize, 64)
+			}
+		case "PRICE_FILTER":
+			if tickSize, ok := filter["tickSize"].(string); ok {
+				info.TickSize, _ = strconv.ParseFloat(tickSize, 64)
+			}
+		case "MIN_NOTIONAL":
+			if notional, ok := filter["notional"].(string); ok {
+				info.MinNotional, _ = strconv.ParseFloat(notional, 64)
+			}
+		}
+	}
+	return info
 }
 
 // FormatQuantity 根据交易对精度格式化数量
@@ -475,14 +815,15 @@ func (b *BinanceClient) FormatQuantity(ctx context.Context, symbol string, quant
 		return 0, err
 	}
 
-	// 根据 stepSize 调整数量
+	// 根据 stepSize 调整数量（加入极小的容差，避免 0.003/0.001=2.9999999 这类浮点误差被截断掉一档）
+	const epsilon = 1e-9
 	if info.StepSize > 0 {
-		quantity = math.Floor(quantity/info.StepSize) * info.StepSize
+		quantity = math.Floor(quantity/info.StepSize+epsilon) * info.StepSize
 	}
 
 	// 根据精度截断
 	precision := math.Pow10(info.QuantityPrecision)
-	quantity = math.Floor(quantity*precision) / precision
+	quantity = math.Floor(quantity*precision+epsilon) / precision
 
 	// 验证范围
 	if quantity < info.MinQuantity {
@@ -495,8 +836,57 @@ func (b *BinanceClient) FormatQuantity(ctx context.Context, symbol string, quant
 	return quantity, nil
 }
 
-// CreateStopLossOrder 创建止损单（STOP_MARKET）
-func (b *BinanceClient) CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64) (*OrderResult, error) {
+// FormatPrice 根据交易对 tickSize 将价格四舍五入到合法的价格档位
+func (b *BinanceClient) FormatPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	info, err := b.GetSymbolInfo(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	return roundToTickSize(price, info.TickSize, info.PricePrecision), nil
+}
+
+// roundToTickSize 将价格四舍五入到 tickSize 的整数倍
+// tickSize 未知时退回到按 PricePrecision 四舍五入
+func roundToTickSize(price, tickSize float64, pricePrecision int) float64 {
+	if price <= 0 {
+		return price
+	}
+
+	precision := pricePrecision
+	if tickSize > 0 {
+		price = math.Round(price/tickSize) * tickSize
+		// 以 tickSize 的小数位数为准，消除浮点误差（如 0.0001234000000001）
+		precision = decimalPlaces(tickSize)
+	}
+
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(price, 'f', precision, 64), 64)
+	if err != nil {
+		return price
+	}
+	return rounded
+}
+
+// decimalPlaces 计算数值的小数位数，如 0.0001 -> 4
+func decimalPlaces(v float64) int {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		return len(s) - idx - 1
+	}
+	return 0
+}
+
+// formatPriceString 将价格格式化为提交给交易所的字符串
+func formatPriceString(price float64, info *SymbolInfo) string {
+	precision := info.PricePrecision
+	if info.TickSize > 0 {
+		precision = decimalPlaces(info.TickSize)
+	}
+	return strconv.FormatFloat(roundToTickSize(price, info.TickSize, info.PricePrecision), 'f', precision, 64)
+}
+
+// CreateStopLossOrder 创建止损单，limitPrice 为0时为 STOP_MARKET，否则为 STOP（限价止损）
+func (b *BinanceClient) CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64, limitPrice float64) (*OrderResult, error) {
 	// 格式化数量和价格
 	formattedQty, err := b.FormatQuantity(ctx, symbol, quantity)
 	if err != nil {
@@ -511,19 +901,30 @@ func (b *BinanceClient) CreateStopLossOrder(ctx context.Context, symbol string,
 
 	// 格式化数量和止损价
 	quantityStr := strconv.FormatFloat(formattedQty, 'f', info.QuantityPrecision, 64)
-	stopPriceStr := strconv.FormatFloat(stopPrice, 'f', info.PricePrecision, 64)
+	stopPriceStr := formatPriceString(stopPrice, info)
 
 	binanceSide := toBinanceSideType(side)
 
-	// 创建 STOP_MARKET 订单（止损市价单）
-	order, err := b.client.NewCreateOrderService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return nil, err
+	}
+	service := b.api().NewCreateOrderService().
 		Symbol(symbol).
 		Side(binanceSide).
-		Type(futures.OrderTypeStopMarket).
 		Quantity(quantityStr).
 		StopPrice(stopPriceStr).
-		ReduceOnly(true). // 止损单只平仓不开仓
-		Do(ctx)
+		ReduceOnly(true) // 止损单只平仓不开仓
+	if limitPrice > 0 {
+		// 创建 STOP 订单（触发后按限价挂单）
+		service = service.
+			Type(futures.OrderTypeStop).
+			Price(formatPriceString(limitPrice, info)).
+			TimeInForce(futures.TimeInForceTypeGTC)
+	} else {
+		// 创建 STOP_MARKET 订单（止损市价单）
+		service = service.Type(futures.OrderTypeStopMarket)
+	}
+	order, err := service.Do(ctx)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stop loss order: %w", err)
@@ -562,12 +963,15 @@ func (b *BinanceClient) CreateTakeProfitOrder(ctx context.Context, symbol string
 
 	// 格式化数量和止盈价
 	quantityStr := strconv.FormatFloat(formattedQty, 'f', info.QuantityPrecision, 64)
-	takeProfitPriceStr := strconv.FormatFloat(takeProfitPrice, 'f', info.PricePrecision, 64)
+	takeProfitPriceStr := formatPriceString(takeProfitPrice, info)
 
 	binanceSide := toBinanceSideType(side)
 
 	// 创建 TAKE_PROFIT_MARKET 订单（止盈市价单）
-	order, err := b.client.NewCreateOrderService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return nil, err
+	}
+	order, err := b.api().NewCreateOrderService().
 		Symbol(symbol).
 		Side(binanceSide).
 		Type(futures.OrderTypeTakeProfitMarket).
@@ -599,7 +1003,10 @@ func (b *BinanceClient) CreateTakeProfitOrder(ctx context.Context, symbol string
 
 // CancelAllOrders 取消指定交易对的所有挂单
 func (b *BinanceClient) CancelAllOrders(ctx context.Context, symbol string) error {
-	err := b.client.NewCancelAllOpenOrdersService().
+	if err := b.waitWeight(ctx, weightDefault); err != nil {
+		return err
+	}
+	err := b.api().NewCancelAllOpenOrdersService().
 		Symbol(symbol).
 		Do(ctx)
 
@@ -613,7 +1020,10 @@ func (b *BinanceClient) CancelAllOrders(ctx context.Context, symbol string) erro
 // GetTradeHistory 获取交易历史
 // 如果指定了 orderId，则返回该订单的成交记录；否则返回最近的成交记录
 func (b *BinanceClient) GetTradeHistory(ctx context.Context, symbol string, orderId int64, limit int) ([]*TradeHistory, error) {
-	service := b.client.NewListAccountTradeService().
+	if err := b.waitWeight(ctx, weightUserTrades); err != nil {
+		return nil, err
+	}
+	service := b.api().NewListAccountTradeService().
 		Symbol(symbol)
 
 	if orderId > 0 {
@@ -655,3 +1065,43 @@ func (b *BinanceClient) GetTradeHistory(ctx context.Context, symbol string, orde
 
 	return result, nil
 }
+
+// GetIncomeHistory 获取资金流水（如资金费），startTime 为毫秒时间戳，0 表示不限制
+func (b *BinanceClient) GetIncomeHistory(ctx context.Context, incomeType string, startTime int64, limit int) ([]*IncomeRecord, error) {
+	if err := b.waitWeight(ctx, weightIncomeHistory); err != nil {
+		return nil, err
+	}
+	service := b.api().NewGetIncomeHistoryService().
+		IncomeType(incomeType)
+
+	if startTime > 0 {
+		service.StartTime(startTime)
+	}
+
+	if limit > 0 && limit <= 1000 {
+		service.Limit(int64(limit))
+	} else {
+		service.Limit(100) // 默认限制100条
+	}
+
+	incomes, err := service.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income history: %w", err)
+	}
+
+	result := make([]*IncomeRecord, 0, len(incomes))
+	for _, in := range incomes {
+		income, _ := strconv.ParseFloat(in.Income, 64)
+		result = append(result, &IncomeRecord{
+			TranID:     in.TranID,
+			Symbol:     in.Symbol,
+			IncomeType: in.IncomeType,
+			Income:     income,
+			Asset:      in.Asset,
+			Info:       in.Info,
+			Time:       in.Time,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,278 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+func TestRoundToTickSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		price     float64
+		tickSize  float64
+		precision int
+		want      float64
+	}{
+		{"btc", 95123.456, 0.1, 2, 95123.5},
+		{"low price coin", 0.0001234567, 0.0000001, 7, 0.0001235},
+		{"very small tick", 0.000012345678, 0.000000001, 9, 0.000012346},
+		{"integer tick", 123.6, 1, 0, 124},
+		{"tick not power of ten", 0.2374, 0.0005, 4, 0.2375},
+		{"no tick size", 1.234567, 0, 3, 1.235},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := roundToTickSize(tt.price, tt.tickSize, tt.precision)
+			if got != tt.want {
+				t.Errorf("roundToTickSize(%v, %v, %d) = %v, want %v", tt.price, tt.tickSize, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPriceString(t *testing.T) {
+	info := &SymbolInfo{PricePrecision: 5, TickSize: 0.0000001}
+	if got := formatPriceString(0.000123456, info); got != "0.0001235" {
+		t.Errorf("formatPriceString() = %s, want 0.0001235", got)
+	}
+}
+
+func TestBinanceClientFormatPrice(t *testing.T) {
+	client := NewBinanceClient("", "", "", false)
+	client.symbolInfoMap["1000PEPEUSDT"] = &SymbolInfo{
+		Symbol:         "1000PEPEUSDT",
+		PricePrecision: 7,
+		TickSize:       0.0000001,
+		lastUpdated:    time.Now(),
+	}
+
+	got, err := client.FormatPrice(context.Background(), "1000PEPEUSDT", 0.01234567)
+	if err != nil {
+		t.Fatalf("FormatPrice() error = %v", err)
+	}
+	if got != 0.0123457 {
+		t.Errorf("FormatPrice() = %v, want 0.0123457", got)
+	}
+}
+
+func TestBinanceClientFormatQuantityStepBoundary(t *testing.T) {
+	client := NewBinanceClient("", "", "", false)
+	client.symbolInfoMap["BTCUSDT"] = &SymbolInfo{
+		Symbol:            "BTCUSDT",
+		QuantityPrecision: 3,
+		StepSize:          0.001,
+		MinQuantity:       0.001,
+		lastUpdated:       time.Now(),
+	}
+
+	// 0.1+0.2 在浮点下为 0.30000000000000004，0.3 本身除以 0.001 可能略小于 300
+	for _, qty := range []float64{0.3, 0.1 + 0.2, 3 * 0.001} {
+		got, err := client.FormatQuantity(context.Background(), "BTCUSDT", qty)
+		if err != nil {
+			t.Fatalf("FormatQuantity(%v) error = %v", qty, err)
+		}
+		want := roundToTickSize(qty, 0.001, 3)
+		if got != want {
+			t.Errorf("FormatQuantity(%v) = %v, want %v", qty, got, want)
+		}
+	}
+}
+
+func TestParseSymbolInfo(t *testing.T) {
+	info := parseSymbolInfo(futures.Symbol{
+		Symbol:            "BTCUSDT",
+		QuantityPrecision: 3,
+		PricePrecision:    2,
+		Filters: []map[string]interface{}{
+			{"filterType": "PRICE_FILTER", "tickSize": "0.10"},
+			{"filterType": "LOT_SIZE", "minQty": "0.001", "maxQty": "1000", "stepSize": "0.001"},
+			{"filterType": "MIN_NOTIONAL", "notional": "100"},
+		},
+	})
+	if info.Symbol != "BTCUSDT" || info.QuantityPrecision != 3 || info.PricePrecision != 2 {
+		t.Fatalf("unexpected precision: %+v", info)
+	}
+	if info.TickSize != 0.1 || info.MinQuantity != 0.001 || info.MaxQuantity != 1000 || info.StepSize != 0.001 {
+		t.Fatalf("unexpected filters: %+v", info)
+	}
+	if info.MinNotional != 100 {
+		t.Fatalf("MinNotional = %v, want 100", info.MinNotional)
+	}
+}
+
+func TestParseAccountInfo(t *testing.T) {
+	info := parseAccountInfo(&futures.Account{
+		TotalWalletBalance:    "1000",
+		TotalMarginBalance:    "950.5",
+		TotalUnrealizedProfit: "-49.5",
+		AvailableBalance:      "800",
+	})
+	if info.WalletBalance != 1000 || info.MarginBalance != 950.5 || info.UnrealizedPnl != -49.5 || info.AvailableBalance != 800 {
+		t.Fatalf("account info = %+v", info)
+	}
+
+	// 缺少保证金余额时按钱包余额 + 未实现盈亏计算
+	info = parseAccountInfo(&futures.Account{TotalWalletBalance: "1000", TotalUnrealizedProfit: "25"})
+	if info.MarginBalance != 1025 {
+		t.Fatalf("MarginBalance = %v, want 1025", info.MarginBalance)
+	}
+}
+
+func TestIsReduceOnlyRejected(t *testing.T) {
+	if !isReduceOnlyRejected(fmt.Errorf("wrapped: %w", &common.APIError{Code: -2022, Message: "ReduceOnly Order is rejected."})) {
+		t.Error("code -2022 should be detected")
+	}
+	if !isReduceOnlyRejected(errors.New("<APIError> code=-2022, msg=ReduceOnly Order is rejected.")) {
+		t.Error("rejection message should be detected")
+	}
+	if isReduceOnlyRejected(&common.APIError{Code: -2019, Message: "Margin is insufficient."}) {
+		t.Error("other API errors should not be detected")
+	}
+}
+
+func TestSymbolInfoTradable(t *testing.T) {
+	info := parseSymbolInfo(futures.Symbol{Symbol: "XYZUSDT", Status: "SETTLING"})
+	if info.Tradable() {
+		t.Error("settling symbol should not be tradable")
+	}
+	if !parseSymbolInfo(futures.Symbol{Symbol: "BTCUSDT", Status: SymbolStatusTrading}).Tradable() {
+		t.Error("trading symbol should be tradable")
+	}
+	if !(&SymbolInfo{Symbol: "BTCUSDT"}).Tradable() {
+		t.Error("unknown status should be treated as tradable")
+	}
+}
+
+// 多个交易对同时未命中缓存时只请求一次 exchangeInfo
+func TestGetSymbolInfoCoalescesExchangeInfo(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/exchangeInfo" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"symbols":[
+			{"symbol":"BTCUSDT","status":"TRADING","pricePrecision":2,"quantityPrecision":3,"filters":[]},
+			{"symbol":"ETHUSDT","status":"TRADING","pricePrecision":2,"quantityPrecision":3,"filters":[]}
+		]}`)
+	}))
+	defer server.Close()
+
+	client := NewBinanceClient("", "", "", false)
+	client.updateAPI(func(api *futures.Client) { api.BaseURL = server.URL })
+
+	symbols := []string{"BTCUSDT", "ETHUSDT", "BTCUSDT", "ETHUSDT", "BTCUSDT", "ETHUSDT"}
+	var wg sync.WaitGroup
+	errs := make([]error, len(symbols))
+	for i, symbol := range symbols {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = client.GetSymbolInfo(context.Background(), symbol)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("GetSymbolInfo(%s) error = %v", symbols[i], err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("exchangeInfo requests = %d, want 1", got)
+	}
+
+	// 不存在的交易对返回 ErrSymbolNotFound
+	if _, err := client.GetSymbolInfo(context.Background(), "DOGEUSDT"); !errors.Is(err, ErrSymbolNotFound) {
+		t.Fatalf("GetSymbolInfo(DOGEUSDT) error = %v, want ErrSymbolNotFound", err)
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	sent := time.UnixMilli(1_700_000_000_000)
+	tests := []struct {
+		name       string
+		received   time.Time
+		serverTime time.Time
+		want       time.Duration
+	}{
+		{"in sync", sent.Add(200 * time.Millisecond), sent.Add(100 * time.Millisecond), 0},
+		{"local clock ahead", sent.Add(200 * time.Millisecond), sent.Add(-1900 * time.Millisecond), 2 * time.Second},
+		{"local clock behind", sent.Add(100 * time.Millisecond), sent.Add(1550 * time.Millisecond), -1500 * time.Millisecond},
+		// 请求去程快、回程慢：服务器时间早于往返中点，偏差不超过往返时间的一半
+		{"slow response leg", sent.Add(2 * time.Second), sent.Add(200 * time.Millisecond), 800 * time.Millisecond},
+		// 请求去程慢、回程快：服务器时间晚于往返中点
+		{"slow request leg", sent.Add(2 * time.Second), sent.Add(1800 * time.Millisecond), -800 * time.Millisecond},
+		// 请求期间本地时钟回拨，按往返时间为0处理
+		{"clock stepped back", sent.Add(-300 * time.Millisecond), sent.Add(-1 * time.Second), time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clockOffset(sent, tt.received, tt.serverTime); got != tt.want {
+				t.Errorf("clockOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 对时与签名请求并发执行时不能修改进行中请求使用的客户端（配合 go test -race 检查）
+func TestSyncServerTimeConcurrentRequests(t *testing.T) {
+	serverTime := time.Now().Add(-3 * time.Second).UnixMilli()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/fapi/v1/time" {
+			fmt.Fprintf(w, `{"serverTime":%d}`, serverTime)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	client := NewBinanceClient("key", "secret", "", false)
+	client.limiter = nil
+	client.updateAPI(func(api *futures.Client) { api.BaseURL = server.URL })
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := client.SyncServerTime(ctx); err != nil {
+					t.Errorf("SyncServerTime() error = %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, _ = client.GetPositions(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if offset := client.TimeOffset(); offset < 2*time.Second || offset > 4*time.Second {
+		t.Fatalf("TimeOffset() = %v, want about 3s", offset)
+	}
+	if got := client.api().TimeOffset; got != client.TimeOffset().Milliseconds() {
+		t.Fatalf("go-binance TimeOffset = %d, want %d", got, client.TimeOffset().Milliseconds())
+	}
+}
@@ -0,0 +1,202 @@
+package exchange
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrorRateStatus 交易所调用失败率监控状态
+type ErrorRateStatus struct {
+	Degraded         bool       `json:"degraded"`                 // 失败率超过阈值，交易所调用处于降级状态
+	DegradedSince    *time.Time `json:"degraded_since,omitempty"` // 进入降级状态的时间
+	WindowSeconds    int        `json:"window_seconds"`           // 统计窗口
+	Calls            int        `json:"calls"`                    // 窗口内调用次数
+	Failures         int        `json:"failures"`                 // 窗口内失败次数
+	FailureRate      float64    `json:"failure_rate"`             // 窗口内失败率（%）
+	ThresholdPercent float64    `json:"threshold_percent"`        // 降级阈值（%）
+	LastError        string     `json:"last_error,omitempty"`     // 最近一次失败原因
+	LastErrorAt      *time.Time `json:"last_error_at,omitempty"`  // 最近一次失败时间
+}
+
+// callEvent 一次交易所调用的结果
+type callEvent struct {
+	at     time.Time
+	failed bool
+}
+
+// ErrorMonitor 在滑动窗口内统计交易所调用失败率
+//
+// 窗口内调用次数达到 minCalls 且失败率达到阈值时进入降级状态，失败率回落到阈值一半以下时自动恢复，
+// 避免在阈值附近来回切换。状态变化时回调 onChange。
+type ErrorMonitor struct {
+	mu               sync.Mutex
+	window           time.Duration
+	thresholdPercent float64
+	minCalls         int
+	events           []callEvent
+
+	degraded      bool
+	degradedSince time.Time
+	lastError     string
+	lastErrorAt   time.Time
+	onChange      func(status ErrorRateStatus)
+
+	now func() time.Time
+}
+
+// NewErrorMonitor 创建失败率监控
+func NewErrorMonitor(window time.Duration, thresholdPercent float64, minCalls int) *ErrorMonitor {
+	return &ErrorMonitor{
+		window:           window,
+		thresholdPercent: thresholdPercent,
+		minCalls:         max(minCalls, 1),
+		now:              time.Now,
+	}
+}
+
+// OnStateChange 设置进入或退出降级状态时的回调，回调在锁外执行
+func (m *ErrorMonitor) OnStateChange(fn func(status ErrorRateStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Record 记录一次调用结果，err 为 nil 表示成功
+func (m *ErrorMonitor) Record(err error) {
+	m.mu.Lock()
+	now := m.now()
+	m.events = append(m.events, callEvent{at: now, failed: err != nil})
+	if err != nil {
+		m.lastError = err.Error()
+		m.lastErrorAt = now
+	}
+	m.pruneEvents(now)
+
+	calls, failures := m.counts()
+	rate := failureRate(calls, failures)
+	changed := false
+	if !m.degraded && calls >= m.minCalls && rate >= m.thresholdPercent {
+		m.degraded = true
+		m.degradedSince = now
+		changed = true
+	} else if m.degraded && rate < m.thresholdPercent/2 {
+		m.degraded = false
+		m.degradedSince = time.Time{}
+		changed = true
+	}
+
+	status := m.statusLocked(calls, failures)
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(status)
+	}
+}
+
+// Status 返回当前监控状态
+func (m *ErrorMonitor) Status() ErrorRateStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneEvents(m.now())
+	calls, failures := m.counts()
+	return m.statusLocked(calls, failures)
+}
+
+// pruneEvents 清理统计窗口之外的记录，调用方需持有锁
+func (m *ErrorMonitor) pruneEvents(now time.Time) {
+	cutoff := now.Add(-m.window)
+	i := 0
+	for i < len(m.events) && !m.events[i].at.After(cutoff) {
+		i++
+	}
+	m.events = m.events[i:]
+}
+
+// counts 统计窗口内的调用次数和失败次数，调用方需持有锁
+func (m *ErrorMonitor) counts() (int, int) {
+	failures := 0
+	for _, e := range m.events {
+		if e.failed {
+			failures++
+		}
+	}
+	return len(m.events), failures
+}
+
+// statusLocked 生成状态快照，调用方需持有锁
+func (m *ErrorMonitor) statusLocked(calls, failures int) ErrorRateStatus {
+	status := ErrorRateStatus{
+		Degraded:         m.degraded,
+		WindowSeconds:    int(m.window.Seconds()),
+		Calls:            calls,
+		Failures:         failures,
+		FailureRate:      failureRate(calls, failures),
+		ThresholdPercent: m.thresholdPercent,
+		LastError:        m.lastError,
+	}
+	if m.degraded {
+		since := m.degradedSince
+		status.DegradedSince = &since
+	}
+	if !m.lastErrorAt.IsZero() {
+		at := m.lastErrorAt
+		status.LastErrorAt = &at
+	}
+	return status
+}
+
+func failureRate(calls, failures int) float64 {
+	if calls == 0 {
+		return 0
+	}
+	return float64(failures) / float64(calls) * 100
+}
+
+// Transport 包装 HTTP Transport，按响应结果记录调用成败
+func (m *ErrorMonitor) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &errorMonitorTransport{base: base, monitor: m}
+}
+
+type errorMonitorTransport struct {
+	base    http.RoundTripper
+	monitor *ErrorMonitor
+}
+
+func (t *errorMonitorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	// 调用方主动取消（如停止交易循环）不是交易所故障
+	if req.Context().Err() != nil {
+		return resp, err
+	}
+	if err != nil {
+		t.monitor.Record(err)
+		return resp, err
+	}
+	t.monitor.Record(classifyStatus(resp.StatusCode))
+	return resp, nil
+}
+
+// classifyStatus 判断HTTP状态码是否属于交易所故障
+//
+// 一般的 4xx（参数错误、订单不存在等）是业务错误，不计入失败率；
+// 鉴权失败、IP 封禁、限流和 5xx 说明交易所当前不可用。
+func classifyStatus(code int) error {
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return fmt.Errorf("binance auth rejected (HTTP %d)", code)
+	case code == http.StatusTeapot:
+		return fmt.Errorf("binance IP banned (HTTP %d)", code)
+	case code == http.StatusTooManyRequests:
+		return fmt.Errorf("binance rate limited (HTTP %d)", code)
+	case code >= http.StatusInternalServerError:
+		return fmt.Errorf("binance server error (HTTP %d)", code)
+	default:
+		return nil
+	}
+}
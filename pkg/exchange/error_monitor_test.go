@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorMonitorStateChange(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewErrorMonitor(10*time.Minute, 50, 4)
+	m.now = func() time.Time { return now }
+
+	var changes []ErrorRateStatus
+	m.OnStateChange(func(status ErrorRateStatus) { changes = append(changes, status) })
+
+	failure := errors.New("binance server error (HTTP 503)")
+	// 调用次数不足 minCalls 时不判断失败率
+	for i := 0; i < 3; i++ {
+		m.Record(failure)
+	}
+	if len(changes) != 0 || m.Status().Degraded {
+		t.Fatalf("should not degrade before min calls, changes = %+v", changes)
+	}
+
+	m.Record(failure)
+	if len(changes) != 1 || !changes[0].Degraded || changes[0].Failures != 4 {
+		t.Fatalf("expected single degraded change, got %+v", changes)
+	}
+	// 持续失败不重复回调
+	m.Record(failure)
+	if len(changes) != 1 {
+		t.Fatalf("degraded change should fire once, got %d", len(changes))
+	}
+
+	// 窗口滑过旧的失败记录后，成功调用使失败率回落，自动恢复
+	now = now.Add(11 * time.Minute)
+	m.Record(nil)
+	if len(changes) != 2 || changes[1].Degraded {
+		t.Fatalf("expected recovery change, got %+v", changes)
+	}
+	status := m.Status()
+	if status.Degraded || status.Calls != 1 || status.LastError != failure.Error() {
+		t.Fatalf("status after recovery = %+v", status)
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	failures := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTeapot,
+		http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable}
+	for _, code := range failures {
+		if classifyStatus(code) == nil {
+			t.Errorf("HTTP %d should count as failure", code)
+		}
+	}
+	for _, code := range []int{http.StatusOK, http.StatusBadRequest, http.StatusNotFound} {
+		if err := classifyStatus(code); err != nil {
+			t.Errorf("HTTP %d should not count as failure, got %v", code, err)
+		}
+	}
+}
@@ -1,6 +1,16 @@
 package exchange
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrReduceOnlyRejected 只减仓订单被拒绝：持仓已被其他订单（止损单触发、手动平仓等）减少或平掉
+var ErrReduceOnlyRejected = errors.New("reduce-only order rejected, position already flat")
+
+// ErrSymbolNotFound 交易所信息中不存在该交易对（如已下架）
+var ErrSymbolNotFound = errors.New("symbol not found")
 
 // Exchange 交易所接口，定义所有交易所需要实现的方法
 // 使用通用类型，便于支持多个交易所（币安、OKX、Bybit等）
@@ -9,6 +19,8 @@ type Exchange interface {
 	GetKlines(ctx context.Context, symbol string, interval string, limit int) ([]*Kline, error)
 	GetCurrentPrice(ctx context.Context, symbol string) (float64, error)
 	GetFundingRate(ctx context.Context, symbol string) (float64, error)
+	GetBookTicker(ctx context.Context, symbol string) (*BookTicker, error)
+	Get24hrStats(ctx context.Context, symbol string) (*Ticker24h, error)
 
 	// 账户信息
 	GetAccountInfo(ctx context.Context) (*AccountInfo, error)
@@ -28,14 +40,22 @@ type Exchange interface {
 	GetOrderStatus(ctx context.Context, symbol string, orderID int64) (*OrderResult, error)
 
 	// 止损止盈订单（限价单/止损单）
-	CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64) (*OrderResult, error)
+	// limitPrice 为0时创建触发后市价成交的止损单，大于0时创建触发后按该价格挂限价单的止损单
+	CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64, limitPrice float64) (*OrderResult, error)
 	CreateTakeProfitOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, takeProfitPrice float64) (*OrderResult, error)
 	CancelAllOrders(ctx context.Context, symbol string) error
 
 	// 交易历史
 	GetTradeHistory(ctx context.Context, symbol string, orderId int64, limit int) ([]*TradeHistory, error)
+	GetIncomeHistory(ctx context.Context, incomeType string, startTime int64, limit int) ([]*IncomeRecord, error)
 
 	// 交易对信息
 	GetSymbolInfo(ctx context.Context, symbol string) (*SymbolInfo, error)
+	WarmSymbolInfo(ctx context.Context, symbols []string) error
 	FormatQuantity(ctx context.Context, symbol string, quantity float64) (float64, error)
+	FormatPrice(ctx context.Context, symbol string, price float64) (float64, error)
+
+	// 时钟同步
+	// TimeOffset 本地时钟减去交易所服务器时间，交易所时间戳加上该偏移换算为本地时间
+	TimeOffset() time.Duration
 }
@@ -4,13 +4,18 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// PriceSource 价格来源，用于以历史价格驱动纸钱包
+type PriceSource func(ctx context.Context, symbol string) (float64, error)
+
 // PaperWallet 纸钱包（模拟交易）
 type PaperWallet struct {
 	binanceClient *BinanceClient // 用于获取真实市场数据
+	priceSource   PriceSource    // 自定义价格来源，设置后替代实时价格
 	logger        *zap.Logger
 
 	// 模拟账户数据
@@ -42,11 +47,36 @@ func (p *PaperWallet) GetKlines(ctx context.Context, symbol string, interval str
 	return p.binanceClient.GetKlines(ctx, symbol, interval, limit)
 }
 
-// GetCurrentPrice 获取当前价格（使用真实数据）
+// SetPriceSource 设置自定义价格来源（如回放时的历史K线价格），需在下单前设置
+func (p *PaperWallet) SetPriceSource(source PriceSource) {
+	p.priceSource = source
+}
+
+// GetCurrentPrice 获取当前价格（默认使用真实数据）
 func (p *PaperWallet) GetCurrentPrice(ctx context.Context, symbol string) (float64, error) {
+	if p.priceSource != nil {
+		return p.priceSource(ctx, symbol)
+	}
 	return p.binanceClient.GetCurrentPrice(ctx, symbol)
 }
 
+// GetBookTicker 获取盘口最优买卖价（默认使用真实数据），自定义价格来源时没有盘口，按无价差处理
+func (p *PaperWallet) GetBookTicker(ctx context.Context, symbol string) (*BookTicker, error) {
+	if p.priceSource != nil {
+		price, err := p.priceSource(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		return &BookTicker{Symbol: symbol, BidPrice: price, AskPrice: price}, nil
+	}
+	return p.binanceClient.GetBookTicker(ctx, symbol)
+}
+
+// Get24hrStats 获取24小时行情统计（使用真实数据）
+func (p *PaperWallet) Get24hrStats(ctx context.Context, symbol string) (*Ticker24h, error) {
+	return p.binanceClient.Get24hrStats(ctx, symbol)
+}
+
 // GetFundingRate 获取资金费率（使用真实数据）
 func (p *PaperWallet) GetFundingRate(ctx context.Context, symbol string) (float64, error) {
 	return p.binanceClient.GetFundingRate(ctx, symbol)
@@ -57,38 +87,56 @@ func (p *PaperWallet) GetAccountInfo(ctx context.Context) (*AccountInfo, error)
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	// 计算未实现盈亏
-	unrealizedPnl := 0.0
-	for _, pos := range p.positions {
-		unrealizedPnl += pos.UnrealizedProfit
-	}
-
-	// 计算可用余额（余额 + 未实现盈亏）
-	totalBalance := p.balance + unrealizedPnl
-
-	// 计算已用保证金
-	usedMargin := 0.0
-	for _, pos := range p.positions {
-		// 保证金 = 持仓价值 / 杠杆
-		positionValue := pos.PositionAmount * pos.EntryPrice
-		usedMargin += positionValue / float64(pos.Leverage)
-	}
-
-	availableBalance := totalBalance - usedMargin
+	marginBalance, availableBalance, unrealizedPnl := p.balancesLocked(ctx)
 
 	p.logger.Debug("paper wallet account info",
 		zap.Float64("balance", p.balance),
 		zap.Float64("unrealized_pnl", unrealizedPnl),
-		zap.Float64("total_balance", totalBalance),
+		zap.Float64("margin_balance", marginBalance),
 		zap.Float64("available_balance", availableBalance))
 
+	// 纸钱包的余额即钱包余额（平仓盈亏已计入），净值口径与交易所的保证金余额一致
 	return &AccountInfo{
-		TotalBalance:     totalBalance,
+		WalletBalance:    p.balance,
+		MarginBalance:    marginBalance,
 		AvailableBalance: availableBalance,
 		UnrealizedPnl:    unrealizedPnl,
 	}, nil
 }
 
+// balancesLocked 按当前价格计算账户净值、可用余额和未实现盈亏，调用方需持有锁
+//
+// 纸钱包开仓不从余额中扣除保证金，可用余额 = 余额 + 未实现盈亏 - 已用保证金，与交易所全仓模式的口径一致。
+func (p *PaperWallet) balancesLocked(ctx context.Context) (total, available, unrealizedPnl float64) {
+	usedMargin := 0.0
+	for _, pos := range p.positions {
+		unrealizedPnl += p.markToMarket(ctx, pos).UnrealizedProfit
+		usedMargin += InitialMargin(pos.EntryPrice, pos.PositionAmount, pos.Leverage)
+	}
+	total = p.balance + unrealizedPnl
+	return total, total - usedMargin, unrealizedPnl
+}
+
+// markToMarket 返回按当前价格更新标记价格和未实现盈亏后的持仓副本，获取价格失败时沿用上次的标记价格
+func (p *PaperWallet) markToMarket(ctx context.Context, pos *Position) *Position {
+	currentPrice, err := p.GetCurrentPrice(ctx, pos.Symbol)
+	if err != nil {
+		p.logger.Warn("failed to get current price for position",
+			zap.String("symbol", pos.Symbol),
+			zap.Error(err))
+		currentPrice = pos.MarkPrice
+	}
+
+	updatedPos := *pos
+	updatedPos.MarkPrice = currentPrice
+	if pos.Side == "long" {
+		updatedPos.UnrealizedProfit = (currentPrice - pos.EntryPrice) * pos.PositionAmount
+	} else {
+		updatedPos.UnrealizedProfit = (pos.EntryPrice - currentPrice) * pos.PositionAmount
+	}
+	return &updatedPos
+}
+
 // GetPositions 获取模拟持仓
 func (p *PaperWallet) GetPositions(ctx context.Context) ([]*Position, error) {
 	p.mu.RLock()
@@ -97,29 +145,7 @@ func (p *PaperWallet) GetPositions(ctx context.Context) ([]*Position, error) {
 	// 更新所有持仓的未实现盈亏
 	result := make([]*Position, 0, len(p.positions))
 	for _, pos := range p.positions {
-		// 获取当前价格
-		currentPrice, err := p.GetCurrentPrice(ctx, pos.Symbol)
-		if err != nil {
-			p.logger.Warn("failed to get current price for position",
-				zap.String("symbol", pos.Symbol),
-				zap.Error(err))
-			currentPrice = pos.MarkPrice // 使用上次的标记价格
-		}
-
-		// 更新标记价格
-		updatedPos := *pos
-		updatedPos.MarkPrice = currentPrice
-
-		// 计算未实现盈亏
-		pnl := 0.0
-		if pos.Side == "long" {
-			pnl = (currentPrice - pos.EntryPrice) * pos.PositionAmount
-		} else {
-			pnl = (pos.EntryPrice - currentPrice) * pos.PositionAmount
-		}
-		updatedPos.UnrealizedProfit = pnl
-
-		result = append(result, &updatedPos)
+		result = append(result, p.markToMarket(ctx, pos))
 	}
 
 	return result, nil
@@ -131,6 +157,10 @@ func (p *PaperWallet) SetLeverage(ctx context.Context, symbol string, leverage i
 	defer p.mu.Unlock()
 
 	p.symbolLeverages[symbol] = leverage
+	// 与交易所一致，调整杠杆同时作用于该交易对的已有持仓
+	if pos, exists := p.positions[symbol]; exists {
+		pos.Leverage = leverage
+	}
 	p.logger.Info("paper wallet: set leverage",
 		zap.String("symbol", symbol),
 		zap.Int("leverage", leverage))
@@ -185,7 +215,7 @@ func (p *PaperWallet) CreateMarketOrder(ctx context.Context, symbol string, side
 		// 平仓操作
 		pos, exists := p.positions[symbol]
 		if !exists {
-			return nil, fmt.Errorf("no position to close for %s", symbol)
+			return nil, fmt.Errorf("%w: no position to close for %s", ErrReduceOnlyRejected, symbol)
 		}
 
 		// 计算盈亏
@@ -216,17 +246,13 @@ func (p *PaperWallet) CreateMarketOrder(ctx context.Context, symbol string, side
 		}
 	} else {
 		// 开仓操作
-		positionValue := price * quantity
-		requiredMargin := positionValue / float64(leverage)
+		requiredMargin := InitialMargin(price, quantity, leverage)
 
-		// 检查余额是否足够
-		if requiredMargin > p.balance {
-			return nil, fmt.Errorf("insufficient balance: required %.2f, available %.2f", requiredMargin, p.balance)
+		// 检查可用余额是否足够，已有持仓占用的保证金不能重复使用
+		if _, available, _ := p.balancesLocked(ctx); requiredMargin > available {
+			return nil, fmt.Errorf("insufficient balance: required %.2f, available %.2f", requiredMargin, available)
 		}
 
-		// 扣除保证金（暂不扣除，因为保证金计算在GetAccountInfo中）
-		// p.balance -= requiredMargin
-
 		positionSide := "long"
 		if side == OrderSideSell {
 			positionSide = "short"
@@ -331,11 +357,21 @@ func (p *PaperWallet) GetSymbolInfo(ctx context.Context, symbol string) (*Symbol
 	return p.binanceClient.GetSymbolInfo(ctx, symbol)
 }
 
+// WarmSymbolInfo 预热交易对信息缓存（使用真实数据）
+func (p *PaperWallet) WarmSymbolInfo(ctx context.Context, symbols []string) error {
+	return p.binanceClient.WarmSymbolInfo(ctx, symbols)
+}
+
 // FormatQuantity 格式化数量（使用真实规则）
 func (p *PaperWallet) FormatQuantity(ctx context.Context, symbol string, quantity float64) (float64, error) {
 	return p.binanceClient.FormatQuantity(ctx, symbol, quantity)
 }
 
+// FormatPrice 格式化价格（使用真实规则）
+func (p *PaperWallet) FormatPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	return p.binanceClient.FormatPrice(ctx, symbol, price)
+}
+
 // GetBalance 获取当前余额（用于测试和调试）
 func (p *PaperWallet) GetBalance() float64 {
 	p.mu.RLock()
@@ -362,8 +398,17 @@ func (p *PaperWallet) Reset() {
 		zap.Float64("initial_balance", p.initialBalance))
 }
 
+// SetInitialBalance 修改初始余额并重置纸钱包
+func (p *PaperWallet) SetInitialBalance(initialBalance float64) {
+	p.mu.Lock()
+	p.initialBalance = initialBalance
+	p.mu.Unlock()
+
+	p.Reset()
+}
+
 // CreateStopLossOrder 创建止损单（模拟）
-func (p *PaperWallet) CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64) (*OrderResult, error) {
+func (p *PaperWallet) CreateStopLossOrder(ctx context.Context, symbol string, side OrderSide, quantity float64, stopPrice float64, limitPrice float64) (*OrderResult, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -375,17 +420,22 @@ func (p *PaperWallet) CreateStopLossOrder(ctx context.Context, symbol string, si
 		zap.String("side", string(side)),
 		zap.Float64("quantity", quantity),
 		zap.Float64("stop_price", stopPrice),
+		zap.Float64("limit_price", limitPrice),
 		zap.Int64("order_id", orderID))
 
 	// 纸钱包模式：止损单不会真实执行
 	// 实际的止损逻辑由AI在每15分钟的循环中检查并手动平仓
 	// 这里只是记录止损单已创建
+	orderType := "STOP_MARKET"
+	if limitPrice > 0 {
+		orderType = "STOP"
+	}
 
 	return &OrderResult{
 		OrderID:     orderID,
 		Symbol:      symbol,
 		Side:        string(side),
-		Type:        "STOP_MARKET",
+		Type:        orderType,
 		Quantity:    quantity,
 		Price:       stopPrice,
 		AvgPrice:    0,
@@ -443,3 +493,13 @@ func (p *PaperWallet) GetTradeHistory(ctx context.Context, symbol string, orderI
 	// 交易记录在应用层通过 Trade 表管理
 	return []*TradeHistory{}, nil
 }
+
+// TimeOffset 纸钱包的成交时间由本地时钟生成，无需换算
+func (p *PaperWallet) TimeOffset() time.Duration {
+	return 0
+}
+
+// GetIncomeHistory 获取资金流水（纸钱包模式不结算资金费，返回空）
+func (p *PaperWallet) GetIncomeHistory(ctx context.Context, incomeType string, startTime int64, limit int) ([]*IncomeRecord, error) {
+	return []*IncomeRecord{}, nil
+}
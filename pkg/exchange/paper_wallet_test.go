@@ -0,0 +1,77 @@
+package exchange
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestInitialMargin(t *testing.T) {
+	if got := InitialMargin(100, 10, 5); got != 200 {
+		t.Fatalf("margin = %v, want 200", got)
+	}
+	// 空头持仓数量为负时保证金仍为正
+	if got := InitialMargin(100, -10, 5); got != 200 {
+		t.Fatalf("short margin = %v, want 200", got)
+	}
+	if got := InitialMargin(100, 10, 0); got != 0 {
+		t.Fatalf("zero leverage margin = %v, want 0", got)
+	}
+}
+
+func TestPaperWalletMarginAccounting(t *testing.T) {
+	ctx := context.Background()
+	price := 100.0
+	wallet := NewPaperWallet(nil, 1000, zap.NewNop())
+	wallet.SetPriceSource(func(ctx context.Context, symbol string) (float64, error) {
+		return price, nil
+	})
+	if err := wallet.SetLeverage(ctx, "BTCUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenLongPosition(ctx, "BTCUSDT", 10); err != nil {
+		t.Fatalf("open long: %v", err)
+	}
+
+	assertAccount := func(total, available, unrealized float64) {
+		t.Helper()
+		info, err := wallet.GetAccountInfo(ctx)
+		if err != nil {
+			t.Fatalf("account info: %v", err)
+		}
+		if math.Abs(info.MarginBalance-total) > 1e-9 || math.Abs(info.WalletBalance+info.UnrealizedPnl-info.MarginBalance) > 1e-9 || math.Abs(info.AvailableBalance-available) > 1e-9 ||
+			math.Abs(info.UnrealizedPnl-unrealized) > 1e-9 {
+			t.Fatalf("account = %+v, want total %v available %v unrealized %v", info, total, available, unrealized)
+		}
+	}
+
+	// 名义价值 1000，5 倍杠杆占用保证金 200
+	assertAccount(1000, 800, 0)
+
+	// 价格上涨 10%，未实现盈亏计入净值和可用余额，保证金仍按开仓价计算
+	price = 110
+	assertAccount(1100, 900, 100)
+
+	positions, err := wallet.GetPositions(ctx)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("positions = %v, err = %v", positions, err)
+	}
+	if got := InitialMargin(positions[0].EntryPrice, positions[0].PositionAmount, positions[0].Leverage); got != 200 {
+		t.Fatalf("position margin = %v, want 200", got)
+	}
+
+	// 已占用的保证金不能再次用于开仓：可用 900，需要 110*50/5 = 1100
+	if err := wallet.SetLeverage(ctx, "ETHUSDT", 5); err != nil {
+		t.Fatalf("set leverage: %v", err)
+	}
+	if _, err := wallet.OpenShortPosition(ctx, "ETHUSDT", 50); err == nil {
+		t.Fatal("open beyond available balance should fail")
+	}
+	if _, err := wallet.OpenShortPosition(ctx, "ETHUSDT", 40); err != nil {
+		t.Fatalf("open within available balance: %v", err)
+	}
+	// 空头按 110 开仓，占用 880 保证金
+	assertAccount(1100, 20, 100)
+}
@@ -0,0 +1,171 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWeightPerMinute 默认每分钟请求权重上限
+// 币安U本位合约的IP限制为2400/分钟，这里保守使用一半，为其他客户端和突发请求留出余量
+const DefaultWeightPerMinute = 1200
+
+// 币安U本位合约各接口的请求权重
+const (
+	weightDefault       = 1
+	weightBookTicker    = 2
+	weightAccount       = 5
+	weightPositionRisk  = 5
+	weightUserTrades    = 5
+	weightIncomeHistory = 30
+)
+
+// klinesWeight 根据K线数量计算请求权重
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	default:
+		return 10
+	}
+}
+
+// RateLimitUsage 限流器当前使用情况
+type RateLimitUsage struct {
+	WeightPerMinute int     `json:"weight_per_minute"` // 每分钟权重上限
+	Available       float64 `json:"available"`         // 当前可用权重
+	UsedLastMinute  int     `json:"used_last_minute"`  // 最近一分钟已使用的权重
+	TotalRequests   int64   `json:"total_requests"`    // 累计请求数
+	ThrottledCount  int64   `json:"throttled_count"`   // 因限流而等待的请求数
+	TotalWaitMillis int64   `json:"total_wait_millis"` // 累计等待时间（毫秒）
+}
+
+// weightEvent 一次请求消耗的权重，用于统计最近一分钟的使用量
+type weightEvent struct {
+	at     time.Time
+	weight int
+}
+
+// WeightLimiter 基于令牌桶的请求权重限流器，令牌不足时阻塞等待而不是报错
+type WeightLimiter struct {
+	mu              sync.Mutex
+	weightPerMinute int
+	tokens          float64
+	lastRefill      time.Time
+	events          []weightEvent
+
+	totalRequests   int64
+	throttledCount  int64
+	totalWaitMillis int64
+
+	now func() time.Time
+}
+
+// NewWeightLimiter 创建限流器，weightPerMinute <= 0 时使用默认值
+func NewWeightLimiter(weightPerMinute int) *WeightLimiter {
+	if weightPerMinute <= 0 {
+		weightPerMinute = DefaultWeightPerMinute
+	}
+	l := &WeightLimiter{
+		weightPerMinute: weightPerMinute,
+		tokens:          float64(weightPerMinute),
+		now:             time.Now,
+	}
+	l.lastRefill = l.now()
+	return l
+}
+
+// refill 按流逝时间补充令牌，调用方需持有锁
+func (l *WeightLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+	l.tokens += elapsed.Minutes() * float64(l.weightPerMinute)
+	if l.tokens > float64(l.weightPerMinute) {
+		l.tokens = float64(l.weightPerMinute)
+	}
+	l.lastRefill = now
+}
+
+// reserve 预占权重，返回需要等待的时间；令牌允许透支，等待结束后即视为可用
+func (l *WeightLimiter) reserve(weight int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.refill(now)
+
+	if weight > l.weightPerMinute {
+		weight = l.weightPerMinute
+	}
+
+	l.tokens -= float64(weight)
+	l.totalRequests++
+	l.events = append(l.events, weightEvent{at: now, weight: weight})
+	l.pruneEvents(now)
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	wait := time.Duration(-l.tokens / float64(l.weightPerMinute) * float64(time.Minute))
+	l.throttledCount++
+	l.totalWaitMillis += wait.Milliseconds()
+	return wait
+}
+
+// pruneEvents 清理一分钟之前的权重记录，调用方需持有锁
+func (l *WeightLimiter) pruneEvents(now time.Time) {
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(l.events) && !l.events[i].at.After(cutoff) {
+		i++
+	}
+	l.events = l.events[i:]
+}
+
+// Wait 等待直到可以发送消耗 weight 权重的请求，ctx 取消时返回错误
+func (l *WeightLimiter) Wait(ctx context.Context, weight int) error {
+	wait := l.reserve(weight)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Usage 返回限流器当前使用情况
+func (l *WeightLimiter) Usage() RateLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.refill(now)
+	l.pruneEvents(now)
+
+	used := 0
+	for _, e := range l.events {
+		used += e.weight
+	}
+
+	return RateLimitUsage{
+		WeightPerMinute: l.weightPerMinute,
+		Available:       l.tokens,
+		UsedLastMinute:  used,
+		TotalRequests:   l.totalRequests,
+		ThrottledCount:  l.throttledCount,
+		TotalWaitMillis: l.totalWaitMillis,
+	}
+}
@@ -0,0 +1,46 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeightLimiterReserve(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewWeightLimiter(60) // 每秒补充1个权重
+	l.now = func() time.Time { return now }
+	l.lastRefill = now
+
+	if wait := l.reserve(50); wait != 0 {
+		t.Fatalf("first reserve should not wait, got %s", wait)
+	}
+	if wait := l.reserve(10); wait != 0 {
+		t.Fatalf("reserve within capacity should not wait, got %s", wait)
+	}
+	// 令牌耗尽后需要等待 5 秒补充 5 个权重
+	if wait := l.reserve(5); wait != 5*time.Second {
+		t.Fatalf("wait = %s, want 5s", wait)
+	}
+
+	usage := l.Usage()
+	if usage.UsedLastMinute != 65 || usage.ThrottledCount != 1 || usage.TotalRequests != 3 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	// 一分钟后令牌补满，旧的使用记录过期
+	now = now.Add(2 * time.Minute)
+	if wait := l.reserve(1); wait != 0 {
+		t.Fatalf("reserve after refill should not wait, got %s", wait)
+	}
+	if usage := l.Usage(); usage.UsedLastMinute != 1 {
+		t.Fatalf("used last minute = %d, want 1", usage.UsedLastMinute)
+	}
+}
+
+func TestKlinesWeight(t *testing.T) {
+	for limit, want := range map[int]int{50: 1, 100: 2, 499: 2, 500: 5, 1000: 5, 1500: 10} {
+		if got := klinesWeight(limit); got != want {
+			t.Errorf("klinesWeight(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import "strings"
+
+// SymbolFormat 交易对展示格式
+type SymbolFormat string
+
+const (
+	SymbolFormatNative SymbolFormat = "native" // 内部格式：BTCUSDT
+	SymbolFormatSlash  SymbolFormat = "slash"  // BTC/USDT
+	SymbolFormatDash   SymbolFormat = "dash"   // BTC-USDT
+	SymbolFormatCCXT   SymbolFormat = "ccxt"   // CCXT 永续合约格式：BTC/USDT:USDT
+)
+
+// quoteAssets 已知的计价资产，按长度从长到短匹配，避免 FDUSD 被识别为 USD
+var quoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "USD", "BTC", "ETH", "BNB"}
+
+// NormalizeSymbol 将各种常见格式的交易对统一转换为内部格式（如 BTCUSDT）
+// 支持 BTCUSDT、btcusdt、BTC/USDT、BTC-USDT、BTC_USDT、BTC/USDT:USDT
+func NormalizeSymbol(symbol string) string {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	if s == "" {
+		return ""
+	}
+
+	// CCXT 合约格式的结算币种后缀（:USDT）
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		s = s[:idx]
+	}
+
+	return strings.NewReplacer("/", "", "-", "", "_", "", " ", "").Replace(s)
+}
+
+// DenormalizeSymbol 将内部格式的交易对转换为指定的展示格式
+// 无法识别计价资产时原样返回
+func DenormalizeSymbol(symbol string, format SymbolFormat) string {
+	normalized := NormalizeSymbol(symbol)
+
+	if format == "" || format == SymbolFormatNative {
+		return normalized
+	}
+
+	base, quote, ok := SplitSymbol(normalized)
+	if !ok {
+		return normalized
+	}
+
+	switch format {
+	case SymbolFormatSlash:
+		return base + "/" + quote
+	case SymbolFormatDash:
+		return base + "-" + quote
+	case SymbolFormatCCXT:
+		return base + "/" + quote + ":" + quote
+	default:
+		return normalized
+	}
+}
+
+// SplitSymbol 将内部格式的交易对拆分为基础资产和计价资产
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	normalized := NormalizeSymbol(symbol)
+	for _, q := range quoteAssets {
+		if strings.HasSuffix(normalized, q) && len(normalized) > len(q) {
+			return strings.TrimSuffix(normalized, q), q, true
+		}
+	}
+	return "", "", false
+}
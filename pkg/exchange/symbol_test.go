@@ -0,0 +1,51 @@
+package exchange
+
+import "testing"
+
+func TestNormalizeSymbol(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"BTCUSDT", "BTCUSDT"},
+		{"btcusdt", "BTCUSDT"},
+		{"BTC/USDT", "BTCUSDT"},
+		{"BTC-USDT", "BTCUSDT"},
+		{"btc_usdt", "BTCUSDT"},
+		{" ETH/USDT ", "ETHUSDT"},
+		{"BTC/USDT:USDT", "BTCUSDT"},
+		{"1000PEPE/USDT", "1000PEPEUSDT"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeSymbol(tt.input); got != tt.want {
+			t.Errorf("NormalizeSymbol(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDenormalizeSymbol(t *testing.T) {
+	tests := []struct {
+		input  string
+		format SymbolFormat
+		want   string
+	}{
+		{"BTCUSDT", SymbolFormatNative, "BTCUSDT"},
+		{"BTCUSDT", "", "BTCUSDT"},
+		{"BTCUSDT", SymbolFormatSlash, "BTC/USDT"},
+		{"ETHUSDT", SymbolFormatDash, "ETH-USDT"},
+		{"SOLUSDT", SymbolFormatCCXT, "SOL/USDT:USDT"},
+		{"BTCFDUSD", SymbolFormatSlash, "BTC/FDUSD"},
+		{"ETHBTC", SymbolFormatSlash, "ETH/BTC"},
+		{"BTC-USDT", SymbolFormatSlash, "BTC/USDT"},
+		{"USDT", SymbolFormatSlash, "USDT"},
+		{"XYZ", SymbolFormatDash, "XYZ"},
+	}
+
+	for _, tt := range tests {
+		if got := DenormalizeSymbol(tt.input, tt.format); got != tt.want {
+			t.Errorf("DenormalizeSymbol(%q, %q) = %q, want %q", tt.input, tt.format, got, tt.want)
+		}
+	}
+}
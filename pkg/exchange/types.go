@@ -19,6 +19,14 @@ const (
 	PositionSideShort PositionSide = "short"
 )
 
+// StopExecutionType 止损单触发后的执行方式
+type StopExecutionType string
+
+const (
+	StopExecutionMarket StopExecutionType = "market" // 触发后按市价成交（STOP_MARKET），成交确定但流动性差时滑点较大
+	StopExecutionLimit  StopExecutionType = "limit"  // 触发后按限价挂单（STOP），滑点可控但价格跳空时可能不成交
+)
+
 // MarginType 保证金类型
 type MarginType string
 
@@ -68,6 +76,43 @@ func (o OrderStatus) String() string {
 	return string(o)
 }
 
+// Final 订单是否已结束（全部成交、取消、拒绝或过期），结束后成交数量不会再变化
+func (o OrderStatus) Final() bool {
+	switch o {
+	case OrderStatusFilled, OrderStatusCanceled, OrderStatusRejected, OrderStatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// BookTicker 盘口最优买卖价
+type BookTicker struct {
+	Symbol   string
+	BidPrice float64
+	BidQty   float64
+	AskPrice float64
+	AskQty   float64
+}
+
+// Ticker24h 24小时行情统计
+type Ticker24h struct {
+	Symbol             string
+	LastPrice          float64
+	PriceChangePercent float64
+	Volume             float64 // 24小时成交量（基础币）
+	QuoteVolume        float64 // 24小时成交额（计价币，USDT）
+}
+
+// SpreadPercent 买卖价差占中间价的百分比，盘口不完整时返回0
+func (t *BookTicker) SpreadPercent() float64 {
+	if t.BidPrice <= 0 || t.AskPrice <= 0 || t.AskPrice < t.BidPrice {
+		return 0
+	}
+	mid := (t.BidPrice + t.AskPrice) / 2
+	return (t.AskPrice - t.BidPrice) / mid * 100
+}
+
 // TradeHistory 交易历史记录
 type TradeHistory struct {
 	TradeID         int64   // 交易ID
@@ -81,3 +126,20 @@ type TradeHistory struct {
 	RealizedPnl     float64 // 实现盈亏
 	Time            int64   // 成交时间戳(毫秒)
 }
+
+// IncomeType 资金流水类型
+const (
+	IncomeTypeFundingFee = "FUNDING_FEE" // 资金费
+	IncomeTypeTransfer   = "TRANSFER"    // 合约账户划入划出（充值、提现）
+)
+
+// IncomeRecord 资金流水记录（资金费、手续费、已实现盈亏等）
+type IncomeRecord struct {
+	TranID     int64   // 流水ID
+	Symbol     string  // 交易对
+	IncomeType string  // 流水类型
+	Income     float64 // 金额，正数为收入，负数为支出
+	Asset      string  // 资产
+	Info       string  // 备注
+	Time       int64   // 时间戳(毫秒)
+}
@@ -1,7 +1,7 @@
 import {useMemo} from 'react';
 import {useQuery} from '@tanstack/react-query';
 import {fetcher} from '../utils/api';
-import {formatCurrency, getErrorMessage} from '../utils/formatters';
+import {formatCurrency, getErrorMessage, setDisplayTimeZone} from '../utils/formatters';
 import {cardClass} from '../constants/styles';
 import {EquityCurveChart} from './charts/EquityCurveChart';
 import {Header} from './layout/Header';
@@ -24,6 +24,7 @@ export const Dashboard = () => {
         queryFn: () => fetcher<TradingStatusResponse>('/api/trading/status'),
         refetchInterval: 3000,
     });
+    setDisplayTimeZone(statusData?.loop?.display_timezone);
 
     const {
         data: accountData,
@@ -105,6 +106,11 @@ export const Dashboard = () => {
                                 <span>
                                     初始: {formatCurrency(accountMetrics?.initial_balance)}
                                 </span>
+                                {!!accountMetrics?.net_deposits && (
+                                    <span>
+                                        净入金: {formatCurrency(accountMetrics.net_deposits)}
+                                    </span>
+                                )}
                                 <span>
                                     峰值: {formatCurrency(accountMetrics?.peak_balance)}
                                 </span>
@@ -144,6 +150,7 @@ export const Dashboard = () => {
                         decisionsError={decisionsError}
                         decisionsCount={decisionsData?.count ?? 0}
                         stats={statsData}
+                        symbols={statusData?.loop?.symbols ?? []}
                     />
                 </div>
             </div>
@@ -11,6 +11,17 @@ interface SystemPromptVersion {
     updated_at: string;
 }
 
+interface IndicatorParams {
+    ema_fast?: number;
+    ema_slow?: number;
+    rsi_fast?: number;
+    rsi_slow?: number;
+    atr_fast?: number;
+    atr_slow?: number;
+    adx?: number;
+    bbands?: number;
+}
+
 interface TradingConfig {
     symbols: string[];
     interval_minutes: number;
@@ -18,6 +29,13 @@ interface TradingConfig {
     max_positions: number;
     max_leverage: number;
     min_leverage: number;
+    max_holding_hours: number;
+    symbol_batch_size: number;
+    initial_balance: number;
+    initial_balance_at?: string | null;
+    indicators?: IndicatorParams;
+    symbol_indicators?: Record<string, IndicatorParams> | null;
+    symbol_max_leverage?: Record<string, number> | null;
 }
 
 interface TradingConfigForm {
@@ -27,8 +45,28 @@ interface TradingConfigForm {
     max_positions: string;
     max_leverage: string;
     min_leverage: string;
+    max_holding_hours: string;
+    symbol_batch_size: string;
+    initial_balance: string;
+    initial_balance_at: string;
+    indicators: string;
+    symbol_indicators: string;
+    symbol_max_leverage: string;
 }
 
+// toDateTimeLocal 将 ISO 时间转换为 datetime-local 输入框使用的本地时间格式
+const toDateTimeLocal = (value?: string | null) => {
+    if (!value) {
+        return '';
+    }
+    const date = new Date(value);
+    if (Number.isNaN(date.getTime())) {
+        return '';
+    }
+    const offset = date.getTimezoneOffset() * 60000;
+    return new Date(date.getTime() - offset).toISOString().slice(0, 16);
+};
+
 export function ConfigManagement() {
     const [activeTab, setActiveTab] = useState<'system_prompt' | 'trading'>('system_prompt');
     const [editingConfig, setEditingConfig] = useState<string>('');
@@ -173,11 +211,19 @@ export function ConfigManagement() {
             }
             return response.json();
         },
-        onSuccess: () => {
+        onSuccess: (data: { immature_symbols?: Record<string, string>; illiquid_symbols?: Record<string, string> }) => {
             queryClient.invalidateQueries({queryKey: ['admin-trading-config'], exact: true});
             setIsEditing(false);
             setTradingForm(null);
             setRemark('');
+            const immature = Object.entries(data?.immature_symbols ?? {});
+            if (immature.length > 0) {
+                alert(`以下交易对上线时间或K线历史不足，交易循环将暂时跳过：\n${immature.map(([symbol, reason]) => `${symbol}：${reason}`).join('\n')}`);
+            }
+            const illiquid = Object.entries(data?.illiquid_symbols ?? {});
+            if (illiquid.length > 0) {
+                alert(`以下交易对24小时成交额不足，交易循环将暂时跳过：\n${illiquid.map(([symbol, reason]) => `${symbol}：${reason}`).join('\n')}`);
+            }
         },
     });
 
@@ -225,6 +271,20 @@ export function ConfigManagement() {
         let maxPositions: number;
         let maxLeverage: number;
         let minLeverage: number;
+        let maxHoldingHours: number;
+        let symbolBatchSize: number;
+        let initialBalance: number;
+        let indicators: IndicatorParams;
+        let symbolIndicators: Record<string, IndicatorParams>;
+        let symbolMaxLeverage: Record<string, number>;
+
+        const parseJSON = (value: string, fieldLabel: string) => {
+            try {
+                return JSON.parse(value.trim() || '{}');
+            } catch {
+                throw new Error(`${fieldLabel} 请输入有效的 JSON`);
+            }
+        };
 
         try {
             intervalMinutes = parseNumber(tradingForm.interval_minutes, '交易周期', false);
@@ -232,6 +292,12 @@ export function ConfigManagement() {
             maxPositions = parseNumber(tradingForm.max_positions, '最大持仓数', false);
             maxLeverage = parseNumber(tradingForm.max_leverage, '最大杠杆', false);
             minLeverage = parseNumber(tradingForm.min_leverage, '最小杠杆', false);
+            maxHoldingHours = parseNumber(tradingForm.max_holding_hours, '最长持仓小时数', false);
+            symbolBatchSize = parseNumber(tradingForm.symbol_batch_size, '每批交易对数量', false);
+            initialBalance = parseNumber(tradingForm.initial_balance, '初始资金', true);
+            indicators = parseJSON(tradingForm.indicators, '技术指标周期');
+            symbolIndicators = parseJSON(tradingForm.symbol_indicators, '按交易对覆盖的指标周期');
+            symbolMaxLeverage = parseJSON(tradingForm.symbol_max_leverage, '按交易对的最大杠杆');
         } catch (error) {
             if (error instanceof Error) {
                 alert(error.message);
@@ -246,6 +312,13 @@ export function ConfigManagement() {
             max_positions: maxPositions,
             max_leverage: maxLeverage,
             min_leverage: minLeverage,
+            max_holding_hours: maxHoldingHours,
+            symbol_batch_size: symbolBatchSize,
+            initial_balance: initialBalance,
+            initial_balance_at: tradingForm.initial_balance_at ? new Date(tradingForm.initial_balance_at).toISOString() : null,
+            indicators,
+            symbol_indicators: symbolIndicators,
+            symbol_max_leverage: symbolMaxLeverage,
         });
     };
 
@@ -276,6 +349,13 @@ export function ConfigManagement() {
                 max_positions: tradingConfig.max_positions?.toString() ?? '',
                 max_leverage: tradingConfig.max_leverage?.toString() ?? '',
                 min_leverage: tradingConfig.min_leverage?.toString() ?? '',
+                max_holding_hours: tradingConfig.max_holding_hours?.toString() ?? '',
+                symbol_batch_size: tradingConfig.symbol_batch_size?.toString() ?? '0',
+                initial_balance: tradingConfig.initial_balance?.toString() ?? '0',
+                initial_balance_at: toDateTimeLocal(tradingConfig.initial_balance_at),
+                indicators: JSON.stringify(tradingConfig.indicators ?? {}, null, 2),
+                symbol_indicators: JSON.stringify(tradingConfig.symbol_indicators ?? {}, null, 2),
+                symbol_max_leverage: JSON.stringify(tradingConfig.symbol_max_leverage ?? {}, null, 2),
             });
         }
         setRemark('');
@@ -520,6 +600,101 @@ export function ConfigManagement() {
                                                 className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
                                             />
                                         </div>
+                                        <div>
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                最长持仓小时数（0 表示不限制）
+                                            </label>
+                                            <input
+                                                type="number"
+                                                min={0}
+                                                value={tradingForm.max_holding_hours}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, max_holding_hours: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div>
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                每批交易对数量（轮换模式，0 表示每个周期分析全部交易对；持仓交易对始终包含）
+                                            </label>
+                                            <input
+                                                type="number"
+                                                min={0}
+                                                value={tradingForm.symbol_batch_size}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, symbol_batch_size: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div>
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                初始资金（USDT，计算收益率的基准，0 表示使用第一条账户历史）
+                                            </label>
+                                            <input
+                                                type="number"
+                                                min={0}
+                                                step="any"
+                                                value={tradingForm.initial_balance}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, initial_balance: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div>
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                初始资金锚点时间（之后的出入金计入初始资金，留空时修改初始资金以当前时间为锚点）
+                                            </label>
+                                            <input
+                                                type="datetime-local"
+                                                value={tradingForm.initial_balance_at}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, initial_balance_at: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div className="md:col-span-2">
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                技术指标周期（JSON，未填写或为 0 的周期使用默认值：ema_fast 20、ema_slow 50、rsi_fast 7、rsi_slow 14、atr_fast 3、atr_slow 14、adx 14、bbands 20）
+                                            </label>
+                                            <textarea
+                                                rows={4}
+                                                value={tradingForm.indicators}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, indicators: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md font-mono text-sm focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div className="md:col-span-2">
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                按交易对覆盖的指标周期（JSON，例如 {'{"BTCUSDT": {"ema_fast": 21, "ema_slow": 55}}'}）
+                                            </label>
+                                            <textarea
+                                                rows={4}
+                                                value={tradingForm.symbol_indicators}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, symbol_indicators: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md font-mono text-sm focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
+                                        <div className="md:col-span-2">
+                                            <label className="block text-sm font-medium text-gray-700 mb-2">
+                                                按交易对的最大杠杆（JSON，覆盖全局最大杠杆，例如 {'{"BTCUSDT": 20, "DOGEUSDT": 5}'}）
+                                            </label>
+                                            <textarea
+                                                rows={3}
+                                                value={tradingForm.symbol_max_leverage}
+                                                onChange={(e) =>
+                                                    setTradingForm({...tradingForm, symbol_max_leverage: e.target.value})
+                                                }
+                                                className="w-full px-3 py-2 border border-gray-300 rounded-md font-mono text-sm focus:outline-none focus:ring-2 focus:ring-blue-500"
+                                            />
+                                        </div>
                                     </div>
                                     <div className="flex space-x-3">
                                         <button
@@ -584,6 +759,44 @@ export function ConfigManagement() {
                                         {tradingConfig?.min_leverage ?? '-'}
                                     </p>
                                 </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">最长持仓小时数</h4>
+                                    <p className="text-sm text-gray-600">
+                                        {tradingConfig?.max_holding_hours ?? '-'}
+                                    </p>
+                                </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">每批交易对数量</h4>
+                                    <p className="text-sm text-gray-600">
+                                        {tradingConfig?.symbol_batch_size ? tradingConfig.symbol_batch_size : '不轮换'}
+                                    </p>
+                                </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">初始资金</h4>
+                                    <p className="text-sm text-gray-600">
+                                        {tradingConfig?.initial_balance
+                                            ? `${tradingConfig.initial_balance} USDT（锚点 ${tradingConfig.initial_balance_at ? new Date(tradingConfig.initial_balance_at).toLocaleString() : '-'}）`
+                                            : '使用第一条账户历史'}
+                                    </p>
+                                </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">技术指标周期</h4>
+                                    <p className="text-sm text-gray-600 font-mono break-all">
+                                        {JSON.stringify(tradingConfig?.indicators ?? {})}
+                                    </p>
+                                </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">按交易对覆盖的指标周期</h4>
+                                    <p className="text-sm text-gray-600 font-mono break-all">
+                                        {JSON.stringify(tradingConfig?.symbol_indicators ?? {})}
+                                    </p>
+                                </div>
+                                <div className="space-y-1">
+                                    <h4 className="text-sm font-semibold text-gray-700">按交易对的最大杠杆</h4>
+                                    <p className="text-sm text-gray-600 font-mono break-all">
+                                        {JSON.stringify(tradingConfig?.symbol_max_leverage ?? {})}
+                                    </p>
+                                </div>
                             </div>
                         )}
                     </>
@@ -1,10 +1,10 @@
 import {useState} from 'react';
 import {useQuery} from '@tanstack/react-query';
 import {fetcher, tradingControlAPI} from '@/utils/api';
-import type {TradingStatusResponse} from '@/types/trading';
+import type {PaperAccountState, TradingStatusResponse} from '@/types/trading';
 
 export function SystemControl() {
-    const [loading, setLoading] = useState<'start' | 'stop' | 'restart' | null>(null);
+    const [loading, setLoading] = useState<'start' | 'stop' | 'restart' | 'run-once' | null>(null);
     const [error, setError] = useState<string | null>(null);
     const [success, setSuccess] = useState<string | null>(null);
 
@@ -33,8 +33,93 @@ export function SystemControl() {
         }
     };
 
+    const handleRunOnce = async () => {
+        setLoading('run-once');
+        setError(null);
+        setSuccess(null);
+        try {
+            const summary = await tradingControlAPI.runOnce();
+            setSuccess(summary.skipped
+                ? `周期 #${summary.iteration} 已跳过AI决策：${summary.skipped}`
+                : `周期 #${summary.iteration} 执行完成，调用工具 ${summary.tools_called} 次，耗时 ${summary.duration_seconds.toFixed(1)} 秒`);
+            refetch();
+        } catch (err) {
+            setError(err instanceof Error ? err.message : '执行失败');
+        } finally {
+            setLoading(null);
+        }
+    };
+
+    const handleCloseOnly = async (enabled: boolean) => {
+        if (enabled && !window.confirm('开启只平仓模式后 AI 将不能新开仓，只能管理和平掉现有持仓，确定开启吗？')) {
+            return;
+        }
+        setError(null);
+        setSuccess(null);
+        try {
+            await tradingControlAPI.setCloseOnly(enabled);
+            setSuccess(enabled ? '已开启只平仓模式' : '已关闭只平仓模式');
+            refetch();
+        } catch (err) {
+            setError(err instanceof Error ? err.message : '操作失败');
+        }
+    };
+
     const isRunning = statusData?.loop?.is_running ?? false;
     const loopStatus = statusData?.loop;
+    const isPaper = loopStatus?.mode === 'paper';
+    const closeOnly = loopStatus?.close_only ?? false;
+
+    const token = localStorage.getItem('admin_token');
+    const [paperBalanceInput, setPaperBalanceInput] = useState<string>('');
+    const [paperLoading, setPaperLoading] = useState(false);
+
+    const {data: paperAccount, refetch: refetchPaper} = useQuery<PaperAccountState>({
+        queryKey: ['admin-paper-account'],
+        queryFn: async () => {
+            const response = await fetch('/api/admin/paper', {
+                headers: {
+                    'Authorization': `Bearer ${token}`,
+                },
+            });
+            if (!response.ok) throw new Error('Failed to fetch paper account');
+            return response.json();
+        },
+        enabled: isPaper,
+    });
+
+    const handlePaperReset = async (initialBalance?: number) => {
+        const message = initialBalance
+            ? `确定将模拟账户初始资金设置为 ${initialBalance} USDT 并重置吗？所有持仓、交易和资金曲线将被清空。`
+            : '确定重置模拟账户吗？所有持仓、交易和资金曲线将被清空。';
+        if (!window.confirm(message)) {
+            return;
+        }
+        setPaperLoading(true);
+        setError(null);
+        setSuccess(null);
+        try {
+            const response = await fetch(initialBalance ? '/api/admin/paper/balance' : '/api/admin/paper/reset', {
+                method: initialBalance ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${token}`,
+                },
+                body: initialBalance ? JSON.stringify({initial_balance: initialBalance}) : undefined,
+            });
+            if (!response.ok) {
+                const data = await response.json();
+                throw new Error(data.error || '重置失败');
+            }
+            setSuccess('模拟账户已重置');
+            setPaperBalanceInput('');
+            refetchPaper();
+        } catch (err) {
+            setError(err instanceof Error ? err.message : '重置失败');
+        } finally {
+            setPaperLoading(false);
+        }
+    };
 
     return (
         <div className="px-4 py-6 sm:px-0">
@@ -59,6 +144,12 @@ export function SystemControl() {
                             </div>
                         </div>
 
+                        {loopStatus?.below_min_balance && (
+                            <div className="mb-4 rounded-md border border-rose-200 bg-rose-50 px-3 py-2 text-sm text-rose-700">
+                                账户净值低于最低运行资金 {loopStatus.min_operating_balance} USDT，已暂停AI决策（仍同步和监控现有持仓），净值恢复后自动继续
+                            </div>
+                        )}
+
                         {loopStatus && (
                             <div className="grid grid-cols-1 md:grid-cols-2 gap-4 text-sm">
                                 <div>
@@ -185,8 +276,72 @@ export function SystemControl() {
                                     </button>
                                 </>
                             )}
+                            <button
+                                onClick={handleRunOnce}
+                                disabled={loading !== null}
+                                className="flex items-center gap-2 rounded-lg border border-blue-300 px-6 py-3 text-base font-medium text-blue-700 transition-all hover:bg-blue-50 disabled:cursor-not-allowed disabled:opacity-50"
+                            >
+                                {loading === 'run-once' ? '执行中...' : '立即执行一次'}
+                            </button>
+                        </div>
+
+                        {/* 只平仓模式 */}
+                        <div className={`mt-6 rounded-lg border p-4 ${closeOnly ? 'border-rose-200 bg-rose-50' : 'border-gray-200 bg-gray-50'}`}>
+                            <div className="flex flex-wrap items-center justify-between gap-3">
+                                <div>
+                                    <h4 className="text-sm font-semibold text-gray-900">
+                                        只平仓模式{closeOnly && <span className="ml-2 text-rose-600">已开启</span>}
+                                    </h4>
+                                    <p className="mt-1 text-xs text-gray-600">开启后 AI 仍可调整止损和平仓，但不允许新开仓，适合重大事件或计划维护前收缩仓位</p>
+                                </div>
+                                <button
+                                    onClick={() => handleCloseOnly(!closeOnly)}
+                                    className={`rounded-md px-4 py-2 text-sm font-medium text-white ${closeOnly ? 'bg-emerald-500 hover:bg-emerald-600' : 'bg-rose-500 hover:bg-rose-600'}`}
+                                >
+                                    {closeOnly ? '恢复正常交易' : '开启只平仓'}
+                                </button>
+                            </div>
                         </div>
 
+                        {/* 模拟账户 */}
+                        {isPaper && (
+                            <div className="mt-6 rounded-lg border border-amber-200 bg-amber-50 p-4">
+                                <h4 className="text-sm font-semibold text-amber-900 mb-2">模拟账户</h4>
+                                <div className="grid grid-cols-1 md:grid-cols-2 gap-2 text-sm text-amber-900">
+                                    <div>初始资金: <span className="font-semibold">{paperAccount?.initial_balance?.toFixed(2) ?? 'N/A'} USDT</span></div>
+                                    <div>当前余额: <span className="font-semibold">{paperAccount?.balance?.toFixed(2) ?? 'N/A'} USDT</span></div>
+                                </div>
+                                <div className="mt-3 flex flex-wrap items-center gap-3">
+                                    <input
+                                        type="number"
+                                        min="0"
+                                        step="100"
+                                        value={paperBalanceInput}
+                                        onChange={(e) => setPaperBalanceInput(e.target.value)}
+                                        placeholder="新的初始资金"
+                                        className="w-40 rounded-md border border-amber-300 px-3 py-2 text-sm focus:border-amber-500 focus:outline-none"
+                                    />
+                                    <button
+                                        onClick={() => handlePaperReset(Number(paperBalanceInput))}
+                                        disabled={paperLoading || isRunning || !(Number(paperBalanceInput) > 0)}
+                                        className="rounded-md bg-amber-500 px-4 py-2 text-sm font-medium text-white hover:bg-amber-600 disabled:cursor-not-allowed disabled:opacity-50"
+                                    >
+                                        设置资金并重置
+                                    </button>
+                                    <button
+                                        onClick={() => handlePaperReset()}
+                                        disabled={paperLoading || isRunning}
+                                        className="rounded-md border border-amber-400 px-4 py-2 text-sm font-medium text-amber-800 hover:bg-amber-100 disabled:cursor-not-allowed disabled:opacity-50"
+                                    >
+                                        重置模拟账户
+                                    </button>
+                                </div>
+                                {isRunning && (
+                                    <p className="mt-2 text-xs text-amber-700">请先停止交易系统再重置模拟账户</p>
+                                )}
+                            </div>
+                        )}
+
                         {/* 操作说明 */}
                         <div className="mt-6 bg-blue-50 rounded-lg p-4 border border-blue-100">
                             <h4 className="text-sm font-semibold text-blue-900 mb-2">操作说明</h4>
@@ -194,6 +349,7 @@ export function SystemControl() {
                                 <li>• <strong>启动系统</strong>: 开始运行交易循环，系统将按配置的时间间隔执行交易决策</li>
                                 <li>• <strong>停止系统</strong>: 优雅地停止交易循环，等待当前任务完成后停止</li>
                                 <li>• <strong>重启系统</strong>: 停止当前运行并重新启动，应用最新配置</li>
+                                <li>• <strong>立即执行一次</strong>: 不等待定时调度，立即执行一个完整的交易周期，便于验证配置或提示词修改；已有周期在执行时会被拒绝</li>
                             </ul>
                         </div>
                     </div>
@@ -8,6 +8,8 @@ interface HeaderProps {
 
 export const Header = ({loopStatus, accountMetrics}: HeaderProps) => {
     const isRunning = loopStatus?.is_running ?? false;
+    const mode = loopStatus?.mode ?? accountMetrics?.mode;
+    const isLive = mode === 'live';
 
     return (
         <header className="shrink-0 border-b border-slate-200 bg-white/95 backdrop-blur">
@@ -18,6 +20,17 @@ export const Header = ({loopStatus, accountMetrics}: HeaderProps) => {
                         <div className="flex items-center gap-3">
                             <h1 className="text-xl font-semibold text-slate-900 sm:text-2xl">Prism 交易监控</h1>
 
+                            {/* 交易模式标识 */}
+                            {mode && (
+                                <span
+                                    className={`rounded px-2 py-0.5 text-xs font-semibold sm:text-sm ${
+                                        isLive ? 'bg-rose-600 text-white' : 'bg-amber-100 text-amber-800'
+                                    }`}
+                                >
+                                    {isLive ? '实盘' : '模拟盘'}
+                                </span>
+                            )}
+
                             {/* 运行状态指示器 */}
                             <div className="flex items-center gap-2">
                                 <div className={`h-2 w-2 rounded-full ${isRunning ? 'bg-emerald-500 animate-pulse' : 'bg-slate-400'}`}/>
@@ -82,6 +95,17 @@ export const Header = ({loopStatus, accountMetrics}: HeaderProps) => {
                                     {formatPercent(accountMetrics.drawdown_from_peak)}
                                 </span>
                             </div>
+                            <div className="flex flex-col gap-1 text-right"
+                                 title={accountMetrics.unprotected_count ? `${accountMetrics.unprotected_count} 个持仓未设置止损，未计入` : '所有持仓同时触及止损时的合计亏损'}>
+                                <span className="text-xs uppercase tracking-[0.2em] text-slate-400">止损风险</span>
+                                <span className="font-mono text-base font-semibold text-amber-600 sm:text-lg">
+                                    {formatCurrency(accountMetrics.total_stop_risk)}
+                                    <span className="ml-1 text-xs text-slate-400">
+                                        {(accountMetrics.total_stop_risk_pct ?? 0).toFixed(2)}%
+                                    </span>
+                                    {accountMetrics.unprotected_count ? <span className="ml-1 text-xs text-rose-600">⚠️</span> : null}
+                                </span>
+                            </div>
                         </>
                     )}
                 </div>
@@ -4,6 +4,7 @@ import {formatCurrency} from '@/utils/formatters.ts';
 import {PositionsList} from '../trading/PositionsList';
 import {TradesList} from '../trading/TradesList';
 import {DecisionsList} from '../trading/DecisionsList';
+import {MultiTimeframePanel} from '../trading/MultiTimeframePanel';
 import type {Decision, Position, Trade, TradeStats} from '@/types/trading.ts';
 
 interface SidebarProps {
@@ -16,6 +17,7 @@ interface SidebarProps {
     decisionsError: unknown;
     decisionsCount: number;
     stats: TradeStats | undefined;
+    symbols: string[];
 }
 
 export const Sidebar = ({
@@ -28,8 +30,9 @@ export const Sidebar = ({
                             decisionsError,
                             decisionsCount,
                             stats,
+                            symbols,
                         }: SidebarProps) => {
-    const [activeTab, setActiveTab] = useState<'positions' | 'trades' | 'decisions'>('positions');
+    const [activeTab, setActiveTab] = useState<'positions' | 'trades' | 'decisions' | 'market'>('positions');
 
     return (
         <div className={`${cardClass} flex h-full min-h-0 flex-col lg:w-[380px] lg:min-w-[360px]`}>
@@ -57,7 +60,7 @@ export const Sidebar = ({
                 </button>
                 <button
                     onClick={() => setActiveTab('decisions')}
-                    className={`flex-1 px-3 py-2 text-xs font-medium transition sm:px-4 sm:py-3 sm:text-sm ${
+                    className={`flex-1 border-r border-slate-200 px-3 py-2 text-xs font-medium transition sm:px-4 sm:py-3 sm:text-sm ${
                         activeTab === 'decisions'
                             ? 'bg-blue-50 text-blue-700'
                             : 'text-slate-600 hover:bg-slate-50'
@@ -65,6 +68,16 @@ export const Sidebar = ({
                 >
                     决策
                 </button>
+                <button
+                    onClick={() => setActiveTab('market')}
+                    className={`flex-1 px-3 py-2 text-xs font-medium transition sm:px-4 sm:py-3 sm:text-sm ${
+                        activeTab === 'market'
+                            ? 'bg-blue-50 text-blue-700'
+                            : 'text-slate-600 hover:bg-slate-50'
+                    }`}
+                >
+                    多周期
+                </button>
             </div>
 
             {/* 内容头部 */}
@@ -74,11 +87,13 @@ export const Sidebar = ({
                         {activeTab === 'positions' && '当前持仓'}
                         {activeTab === 'trades' && '交易历史'}
                         {activeTab === 'decisions' && 'AI决策记录'}
+                        {activeTab === 'market' && '多时间框架指标'}
                     </h3>
                     <span className="text-xs text-slate-500">
                         {activeTab === 'positions' && `共 ${positions.length} 个`}
                         {activeTab === 'trades' && `共 ${stats?.total_trades ?? 0} 笔`}
                         {activeTab === 'decisions' && `最近 ${decisionsCount} 次`}
+                        {activeTab === 'market' && '与AI决策所用数据一致'}
                     </span>
                 </div>
                 {activeTab === 'trades' && stats && stats.total_trades > 0 && (
@@ -95,6 +110,22 @@ export const Sidebar = ({
                         <span className={stats.total_pnl > 0 ? 'text-emerald-600' : stats.total_pnl < 0 ? 'text-rose-600' : 'text-slate-600'}>
                             总盈亏 {formatCurrency(stats.total_pnl)}
                         </span>
+                        {stats.r_trades !== undefined && stats.r_trades > 0 && stats.expectancy !== undefined && (
+                            <span
+                                className={stats.expectancy > 0 ? 'text-emerald-600' : stats.expectancy < 0 ? 'text-rose-600' : 'text-slate-600'}
+                                title={`基于 ${stats.r_trades} 笔记录了初始风险的平仓交易`}
+                            >
+                                期望 {stats.expectancy >= 0 ? '+' : ''}{stats.expectancy.toFixed(2)}R
+                            </span>
+                        )}
+                        {stats.slippage_trades !== undefined && stats.slippage_trades > 0 && (
+                            <span
+                                className="text-slate-600"
+                                title={`基于 ${stats.slippage_trades} 笔记录了参考价的交易，最大不利滑点 ${(stats.max_slippage_percent ?? 0).toFixed(3)}%`}
+                            >
+                                平均滑点 {(stats.avg_slippage_percent ?? 0).toFixed(3)}% · 成本 {formatCurrency(stats.total_slippage_cost ?? 0)}
+                            </span>
+                        )}
                     </div>
                 )}
             </div>
@@ -122,6 +153,10 @@ export const Sidebar = ({
                         error={decisionsError}
                     />
                 )}
+
+                {activeTab === 'market' && (
+                    <MultiTimeframePanel symbols={symbols}/>
+                )}
             </div>
         </div>
     );
@@ -257,6 +257,16 @@ export const LLMLogViewer = ({decisionId}: LLMLogViewerProps) => {
                                                         <span>{log.error}</span>
                                                     </div>
                                                 )}
+
+                                                {/* 原始响应 */}
+                                                {log.raw_response && (
+                                                    <details className="text-xs text-slate-600">
+                                                        <summary className="cursor-pointer select-none">原始响应</summary>
+                                                        <pre className="mt-2 max-h-80 overflow-auto whitespace-pre-wrap break-all rounded bg-slate-50 p-3 font-mono">
+                                                            {log.raw_response}
+                                                        </pre>
+                                                    </details>
+                                                )}
                                             </div>
                                         </div>
                                     )}
@@ -42,6 +42,9 @@ export const DecisionsList = ({decisions, error}: DecisionsListProps) => {
                         <span>账户: {formatCurrency(decision.account_value)}</span>
                         <span>持仓: {decision.position_count}</span>
                         <span>令牌: {decision.prompt_tokens}/{decision.completion_tokens}</span>
+                        {decision.temperature !== undefined && <span>温度: {decision.temperature}</span>}
+                        {decision.top_p !== undefined && <span>Top P: {decision.top_p}</span>}
+                        {decision.seed !== undefined && <span>种子: {decision.seed}</span>}
                     </div>
 
                     {/* LLM 日志查看器 */}
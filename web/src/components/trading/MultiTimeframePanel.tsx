@@ -0,0 +1,159 @@
+import {useEffect, useState} from 'react';
+import {useQuery} from '@tanstack/react-query';
+import {fetcher} from '@/utils/api';
+import {formatDateTime, formatNumber, formatPrice, getErrorMessage} from '@/utils/formatters';
+import {cardClass} from '@/constants/styles';
+import type {MarketRegime, MultiTimeframeResponse} from '@/types/trading';
+
+interface MultiTimeframePanelProps {
+    symbols: string[];
+}
+
+const timeframeOrder = ['15m', '30m', '1h'];
+
+const confluenceLabels: Record<MultiTimeframeResponse['confluence'], { text: string; className: string }> = {
+    bullish: {text: '多头共振', className: 'bg-emerald-50 text-emerald-700'},
+    bearish: {text: '空头共振', className: 'bg-rose-50 text-rose-700'},
+    neutral: {text: '无共振', className: 'bg-slate-100 text-slate-600'},
+};
+
+const regimeLabels: Record<MarketRegime, { text: string; className: string }> = {
+    trending: {text: '趋势', className: 'bg-blue-50 text-blue-700'},
+    ranging: {text: '震荡', className: 'bg-amber-50 text-amber-700'},
+    uncertain: {text: '不确定', className: 'bg-slate-100 text-slate-600'},
+};
+
+export const MultiTimeframePanel = ({symbols}: MultiTimeframePanelProps) => {
+    const [symbol, setSymbol] = useState(symbols[0] ?? '');
+
+    useEffect(() => {
+        if (symbols.length > 0 && !symbols.includes(symbol)) {
+            setSymbol(symbols[0]);
+        }
+    }, [symbols, symbol]);
+
+    const {data, isLoading, error} = useQuery<MultiTimeframeResponse>({
+        queryKey: ['market-multi-timeframe', symbol],
+        queryFn: () => fetcher<MultiTimeframeResponse>(`/api/market/multi-timeframe?symbol=${encodeURIComponent(symbol)}`),
+        enabled: symbol !== '',
+        refetchInterval: 60000,
+    });
+
+    if (symbols.length === 0) {
+        return <p className="text-sm text-slate-500">未配置交易对</p>;
+    }
+
+    const timeframes = data
+        ? timeframeOrder.filter((tf) => data.timeframes[tf]).map((tf) => data.timeframes[tf])
+        : [];
+    const confluence = data ? confluenceLabels[data.confluence] ?? confluenceLabels.neutral : undefined;
+    const regime = data?.regime ? regimeLabels[data.regime] : undefined;
+
+    return (
+        <div className="space-y-3">
+            <div className="flex items-center justify-between gap-2">
+                <select
+                    value={symbol}
+                    onChange={(e) => setSymbol(e.target.value)}
+                    className="rounded border border-slate-200 px-2 py-1 font-mono text-sm text-slate-900"
+                >
+                    {symbols.map((s) => (
+                        <option key={s} value={s}>{s}</option>
+                    ))}
+                </select>
+                <div className="flex items-center gap-2">
+                    {regime && (
+                        <span className={`rounded px-2 py-1 text-xs font-medium ${regime.className}`}>
+                            {regime.text}
+                        </span>
+                    )}
+                    {data && confluence && (
+                        <span className={`rounded px-2 py-1 text-xs font-medium ${confluence.className}`}>
+                            {confluence.text}{data.confluence_count > 0 && ` (${data.confluence_count})`}
+                        </span>
+                    )}
+                </div>
+            </div>
+
+            {error ? (
+                <p className="text-sm text-rose-500">{getErrorMessage(error)}</p>
+            ) : isLoading || !data ? (
+                <p className="text-sm text-slate-500">加载中...</p>
+            ) : (
+                <>
+                    <div className="flex justify-between text-xs text-slate-500">
+                        <span>当前价格: <span className="font-mono text-slate-900">{formatPrice(data.current_price, 4)}</span></span>
+                        <span>{formatDateTime(data.collected_at)}</span>
+                    </div>
+
+                    {timeframes.map((ind) => {
+                        const trendUp = ind.ema_fast > ind.ema_slow;
+                        return (
+                            <div key={ind.timeframe} className={`${cardClass} p-3`}>
+                                <div className="mb-2 flex items-center justify-between">
+                                    <span className="font-mono text-sm font-semibold text-slate-900">{ind.timeframe}</span>
+                                    <span className={`text-xs font-medium ${trendUp ? 'text-emerald-600' : 'text-rose-600'}`}>
+                                        {trendUp ? 'EMA多头' : 'EMA空头'} · MACD {ind.macd > 0 ? '>' : '≤'} 0
+                                    </span>
+                                </div>
+                                <div className="grid grid-cols-2 gap-x-4 gap-y-1 text-xs text-slate-700">
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">EMA{ind.params.ema_fast}:</span>
+                                        <span className="font-mono">{formatPrice(ind.ema_fast, 4)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">EMA{ind.params.ema_slow}:</span>
+                                        <span className="font-mono">{formatPrice(ind.ema_slow, 4)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">RSI{ind.params.rsi_fast}:</span>
+                                        <span className="font-mono">{formatNumber(ind.rsi_fast, 1)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">RSI{ind.params.rsi_slow}:</span>
+                                        <span className="font-mono">{formatNumber(ind.rsi_slow, 1)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">MACD:</span>
+                                        <span className="font-mono">{formatNumber(ind.macd, 4)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">ADX:</span>
+                                        <span className="font-mono">{formatNumber(ind.adx, 1)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">ATR:</span>
+                                        <span className="font-mono">{formatPrice(ind.atr_slow, 4)}</span>
+                                    </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-slate-500">量/均量:</span>
+                                        <span className="font-mono">
+                                            {ind.avg_volume > 0 ? formatNumber(ind.volume / ind.avg_volume, 2) : '-'}
+                                        </span>
+                                    </div>
+                                </div>
+                            </div>
+                        );
+                    })}
+
+                    {((data.resistances?.length ?? 0) > 0 || (data.supports?.length ?? 0) > 0) && (
+                        <div className="space-y-1 text-xs text-slate-700">
+                            {data.resistances?.map((level) => (
+                                <div key={`r-${level.price}`} className="flex justify-between">
+                                    <span className="text-rose-600">阻力</span>
+                                    <span className="font-mono">{formatPrice(level.price, 4)} ({level.touches}次)</span>
+                                </div>
+                            ))}
+                            {data.supports?.map((level) => (
+                                <div key={`s-${level.price}`} className="flex justify-between">
+                                    <span className="text-emerald-600">支撑</span>
+                                    <span className="font-mono">{formatPrice(level.price, 4)} ({level.touches}次)</span>
+                                </div>
+                            ))}
+                        </div>
+                    )}
+                </>
+            )}
+        </div>
+    );
+};
@@ -47,6 +47,18 @@ export const PositionsList = ({positions, isLoading, error}: PositionsListProps)
                             )}
                         </div>
 
+                        {position.delisted_status && (
+                            <div className="mb-2 rounded bg-rose-50 px-2 py-1 text-xs text-rose-700">
+                                交易对状态 {position.delisted_status}（下架或暂停交易），仓位已冻结，请在交易所人工处理
+                            </div>
+                        )}
+
+                        {position.entry_slippage_percent !== undefined && position.entry_slippage_percent !== 0 && (
+                            <div className="mb-2 rounded bg-amber-50 px-2 py-1 text-xs text-amber-700">
+                                开仓滑点 {position.entry_slippage_percent.toFixed(3)}% 超过上限，请复核执行质量
+                            </div>
+                        )}
+
                         <div className="space-y-1 text-xs text-slate-700">
                             <div className="flex justify-between">
                                 <span className="text-slate-500">开仓价格:</span>
@@ -58,6 +70,15 @@ export const PositionsList = ({positions, isLoading, error}: PositionsListProps)
                                 <span
                                     className="font-mono">{formatPrice(position.current_price, 4)}</span>
                             </div>
+                            {position.health && (
+                                <div className="flex justify-between">
+                                    <span className="text-slate-500">健康分:</span>
+                                    <span
+                                        className={`font-mono ${position.health.score < 40 ? 'text-rose-600' : position.health.score < 70 ? 'text-amber-600' : 'text-emerald-600'}`}>
+                                        {position.health.score.toFixed(0)}/100
+                                    </span>
+                                </div>
+                            )}
                             {position?.stop_loss > 0 && (
                                 <div className="flex justify-between">
                                     <span className="text-slate-500">止损价格:</span>
@@ -91,6 +112,13 @@ export const PositionsList = ({positions, isLoading, error}: PositionsListProps)
                                         className="font-mono">{formatCurrency(position.margin)}</span>
                                 </div>
                             )}
+                            {position?.notional > 0 && (
+                                <div className="flex justify-between">
+                                    <span className="text-slate-500">名义价值:</span>
+                                    <span
+                                        className="font-mono">{formatCurrency(position.notional)}</span>
+                                </div>
+                            )}
                             <div className="flex justify-between">
                                 <span className="text-slate-500">持仓时间:</span>
                                 <span
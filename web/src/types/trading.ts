@@ -1,4 +1,11 @@
+export type TradingMode = 'paper' | 'live';
+
 export type TradingLoopStatus = {
+    mode?: TradingMode;
+    display_timezone?: string;
+    close_only?: boolean;
+    below_min_balance?: boolean;
+    min_operating_balance?: number;
     is_running: boolean;
     iteration: number;
     start_time: string;
@@ -9,17 +16,29 @@ export type TradingLoopStatus = {
 
 export type AccountMetrics = {
     total_balance: number;
+    wallet_balance?: number;
     available: number;
     unrealised_pnl: number;
     initial_balance: number;
+    net_deposits?: number;
     peak_balance: number;
     return_percent: number;
     drawdown_from_peak: number;
     drawdown_from_initial: number;
     sharpe_ratio: number;
+    sortino_ratio?: number;
+    total_stop_risk?: number;
+    total_stop_risk_pct?: number;
+    unprotected_count?: number;
+    mode?: TradingMode;
     warnings?: string[];
 };
 
+export type PaperAccountState = {
+    initial_balance: number;
+    balance: number;
+};
+
 export type Position = {
     id: string;
     symbol: string;
@@ -32,6 +51,7 @@ export type Position = {
     pnl_percent?: number;
     leverage?: number;
     margin?: number;
+    notional?: number;
     peak_pnl_percent?: number;
     holding?: string;
     opened_at?: string;
@@ -40,6 +60,19 @@ export type Position = {
     exit_plan?: string;
     stop_loss?: number;
     take_profit?: number;
+    delisted_status?: string;
+    entry_slippage_percent?: number;
+    health?: PositionHealth;
+};
+
+// 持仓健康分，score 为 0-100，各因子为 0-1
+export type PositionHealth = {
+    score: number;
+    liquidation: number;
+    stop: number;
+    time: number;
+    giveback: number;
+    funding: number;
 };
 
 export type Decision = {
@@ -51,6 +84,10 @@ export type Decision = {
     prompt_tokens: number;
     completion_tokens: number;
     model: string;
+    temperature?: number;
+    top_p?: number;
+    seed?: number;
+    mode?: TradingMode;
     executed_at: string;
 };
 
@@ -71,6 +108,7 @@ export type LLMLog = {
     finish_reason: string;
     duration: number;
     error: string;
+    raw_response?: string;
     executed_at: string;
 };
 
@@ -89,6 +127,10 @@ export type Trade = {
     fee: number;
     pnl: number;
     reason: string;
+    planned_rr?: number;
+    initial_risk?: number;
+    r_multiple?: number;
+    mode?: TradingMode;
     executed_at: string;
 };
 
@@ -145,6 +187,69 @@ export type TradeStats = {
     largest_win: number;
     largest_loss: number;
     profit_factor: number;
+    r_trades?: number;
+    expectancy?: number;
+    slippage_trades?: number;
+    avg_slippage_percent?: number;
+    max_slippage_percent?: number;
+    total_slippage_cost?: number;
+    by_planned_rr?: PlannedRRStats[];
+};
+
+export type PlannedRRStats = {
+    bucket: string;
+    trades: number;
+    winning_trades: number;
+    win_rate: number;
+    total_pnl: number;
+    avg_pnl: number;
+    r_trades: number;
+    avg_r: number;
 };
 
 export type StatsResponse = TradeStats;
+
+export type TimeframeIndicators = {
+    timeframe: string;
+    price: number;
+    ema_fast: number;
+    ema_slow: number;
+    macd: number;
+    macd_signal: number;
+    macd_hist: number;
+    rsi_fast: number;
+    rsi_slow: number;
+    atr_fast: number;
+    atr_slow: number;
+    adx: number;
+    volume: number;
+    avg_volume: number;
+    bbands_upper: number;
+    bbands_middle: number;
+    bbands_lower: number;
+    params: {
+        ema_fast: number;
+        ema_slow: number;
+        rsi_fast: number;
+        rsi_slow: number;
+    };
+};
+
+export type PriceLevel = {
+    price: number;
+    touches: number;
+};
+
+export type MarketRegime = 'trending' | 'ranging' | 'uncertain';
+
+export type MultiTimeframeResponse = {
+    symbol: string;
+    current_price: number;
+    timeframes: Record<string, TimeframeIndicators>;
+    confluence: 'bullish' | 'bearish' | 'neutral';
+    confluence_count: number;
+    regime?: MarketRegime;
+    supports?: PriceLevel[] | null;
+    resistances?: PriceLevel[] | null;
+    collected_at: string;
+};
@@ -65,4 +65,37 @@ export const tradingControlAPI = {
         }
         return response.json();
     },
+
+    // 立即执行一个交易周期
+    runOnce: async () => {
+        const response = await fetch('/api/trading/run-once', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${localStorage.getItem('admin_token')}`,
+            },
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.error || '执行失败');
+        }
+        return response.json();
+    },
+
+    // 切换只平仓模式
+    setCloseOnly: async (enabled: boolean) => {
+        const response = await fetch('/api/trading/close-only', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${localStorage.getItem('admin_token')}`,
+            },
+            body: JSON.stringify({enabled}),
+        });
+        if (!response.ok) {
+            const error = await response.json();
+            throw new Error(error.error || '切换只平仓模式失败');
+        }
+        return response.json();
+    },
 };
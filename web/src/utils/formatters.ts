@@ -44,6 +44,15 @@ export const formatPrice = (value: number | undefined, fractionDigits = 4) => {
     return formatWithUnit(value, fractionDigits);
 };
 
+// 展示时间所用的时区，由后端配置 display_timezone 决定
+let displayTimeZone = 'UTC';
+
+export const setDisplayTimeZone = (timeZone?: string) => {
+    if (timeZone) {
+        displayTimeZone = timeZone;
+    }
+};
+
 export const formatDateTime = (value?: string) => {
     if (!value) {
         return '-';
@@ -53,7 +62,7 @@ export const formatDateTime = (value?: string) => {
         return value;
     }
     return date.toLocaleString('zh-CN', {
-        timeZone: 'Asia/Shanghai',
+        timeZone: displayTimeZone,
         month: '2-digit',
         day: '2-digit',
         hour: '2-digit',
@@ -67,7 +76,7 @@ export const formatTimestampToCST = (epochSeconds: number) => {
         return '-';
     }
     return new Date(epochSeconds * 1000).toLocaleString('zh-CN', {
-        timeZone: 'Asia/Shanghai',
+        timeZone: displayTimeZone,
         month: '2-digit',
         day: '2-digit',
         hour: '2-digit',